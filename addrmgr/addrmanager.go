@@ -26,6 +26,7 @@ import (
 
 	"github.com/james-ray/hcd/chaincfg/chainhash"
 	"github.com/james-ray/hcd/wire"
+	"golang.org/x/crypto/sha3"
 )
 
 // AddrManager provides a concurrency safe address manager for caching potential
@@ -56,6 +57,11 @@ type serializedKnownAddress struct {
 	TimeStamp   int64
 	LastAttempt int64
 	LastSuccess int64
+	// The networks of the address and source are only set for CJDNS
+	// addresses, which can't be told apart from IPv6 addresses by their
+	// host.
+	AddrNetwork wire.NetworkID `json:",omitempty"`
+	SrcNetwork  wire.NetworkID `json:",omitempty"`
 	// no refcount or tried, that is available from context.
 }
 
@@ -156,9 +162,18 @@ const (
 	getAddrPercent = 23
 
 	// serialisationVersion is the current version of the on-disk format.
-	serialisationVersion = 1
+	// Version 2 added Tor v3, I2P and CJDNS addresses, which are not
+	// understood by older versions.  Version 1 files are still loaded.
+	serialisationVersion = 2
+
+	// torV3Version is the version byte of Tor v3 onion addresses.
+	torV3Version = 0x03
 )
 
+// i2pEncoding is the base32 encoding used by I2P addresses, which are not
+// padded.
+var i2pEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
 // updateAddress is a helper function to either update an address already known
 // to the address manager, or to add the address if not already known.
 func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddress) {
@@ -380,6 +395,12 @@ func (a *AddrManager) savePeers() {
 		ska.Attempts = v.attempts
 		ska.LastAttempt = v.lastattempt.Unix()
 		ska.LastSuccess = v.lastsuccess.Unix()
+		if IsCJDNS(v.na) {
+			ska.AddrNetwork = wire.NetworkCJDNS
+		}
+		if IsCJDNS(v.srcAddr) {
+			ska.SrcNetwork = wire.NetworkCJDNS
+		}
 		// Tried and refs are implicit in the rest of the structure
 		// and will be worked out from context on unserialisation.
 		sam.Addresses[i] = ska
@@ -456,7 +477,7 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		return fmt.Errorf("error reading %s: %v", filePath, err)
 	}
 
-	if sam.Version != serialisationVersion {
+	if sam.Version != 1 && sam.Version != serialisationVersion {
 		return fmt.Errorf("unknown version %v in serialized "+
 			"addrmanager", sam.Version)
 	}
@@ -464,12 +485,12 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 
 	for _, v := range sam.Addresses {
 		ka := new(KnownAddress)
-		ka.na, err = a.DeserializeNetAddress(v.Addr)
+		ka.na, err = a.deserializeNetAddress(v.Addr, v.AddrNetwork)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize netaddress "+
 				"%s: %v", v.Addr, err)
 		}
-		ka.srcAddr, err = a.DeserializeNetAddress(v.Src)
+		ka.srcAddr, err = a.deserializeNetAddress(v.Src, v.SrcNetwork)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize netaddress "+
 				"%s: %v", v.Src, err)
@@ -539,6 +560,25 @@ func (a *AddrManager) DeserializeNetAddress(addr string) (*wire.NetAddress, erro
 	return a.HostToNetAddress(host, uint16(port), wire.SFNodeNetwork)
 }
 
+// deserializeNetAddress converts a given address string of the provided network
+// to a *wire.NetAddress.  The network is only needed for CJDNS addresses, since
+// their host is an IPv6 address.
+func (a *AddrManager) deserializeNetAddress(addr string, network wire.NetworkID) (*wire.NetAddress, error) {
+	na, err := a.DeserializeNetAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	if network != wire.NetworkCJDNS {
+		return na, nil
+	}
+	if len(na.IP) != net.IPv6len || na.IP[0] != 0xfc {
+		return nil, fmt.Errorf("CJDNS address %s is not in the "+
+			"fc00::/8 range", addr)
+	}
+	return wire.NewNetAddressV2(wire.NetworkCJDNS, na.IP, na.Port,
+		na.Services), nil
+}
+
 // Start begins the core address handler which manages a pool of known
 // addresses, timeouts, and interval based writes.
 func (a *AddrManager) Start() {
@@ -709,10 +749,76 @@ func (a *AddrManager) reset() {
 	}
 }
 
+// torV3Checksum returns the checksum of the Tor v3 onion address of the passed
+// hidden service public key.
+func torV3Checksum(pubKey []byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte(".onion checksum"))
+	h.Write(pubKey)
+	h.Write([]byte{torV3Version})
+	return h.Sum(nil)[:2]
+}
+
+// decodeTorV3 returns the hidden service public key of the passed Tor v3 onion
+// address without the ".onion" suffix after ensuring its version and checksum
+// are valid.
+func decodeTorV3(host string) ([]byte, error) {
+	data, err := base32.StdEncoding.DecodeString(strings.ToUpper(host))
+	if err != nil {
+		return nil, err
+	}
+	// The address is made up of the 32-byte public key, a 2-byte checksum
+	// and the version.
+	if len(data) != 35 || data[34] != torV3Version {
+		return nil, fmt.Errorf("invalid tor v3 address %s.onion", host)
+	}
+	pubKey := data[:32]
+	checksum := torV3Checksum(pubKey)
+	if data[32] != checksum[0] || data[33] != checksum[1] {
+		return nil, fmt.Errorf("bad checksum for tor v3 address "+
+			"%s.onion", host)
+	}
+	return pubKey, nil
+}
+
+// encodeTorV3 returns the Tor v3 onion address of the passed hidden service
+// public key.
+func encodeTorV3(pubKey []byte) string {
+	data := make([]byte, 0, 35)
+	data = append(data, pubKey...)
+	data = append(data, torV3Checksum(pubKey)...)
+	data = append(data, torV3Version)
+	return strings.ToLower(base32.StdEncoding.EncodeToString(data)) +
+		".onion"
+}
+
 // HostToNetAddress returns a netaddress given a host address. If the address is
-// a tor .onion address this will be taken care of. else if the host is not an
-// IP address it will be resolved (via tor if required).
+// a tor .onion address (v2 or v3) or an I2P .b32.i2p address this will be taken
+// care of. else if the host is not an IP address it will be resolved (via tor if
+// required).  IPv6 addresses in the fc00::/8 range used by CJDNS are returned as
+// IPv6 addresses, since they are also unique local addresses; CJDNS addresses
+// are only created for addresses received on that network in addrv2 messages.
 func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.ServiceFlag) (*wire.NetAddress, error) {
+	// tor v3 address is 56 char base32 + ".onion"
+	if len(host) == 62 && host[56:] == ".onion" {
+		pubKey, err := decodeTorV3(host[:56])
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressV2(wire.NetworkTorV3, pubKey, port,
+			services), nil
+	}
+
+	// i2p address is 52 char base32 + ".b32.i2p"
+	if len(host) == 60 && host[52:] == ".b32.i2p" {
+		data, err := i2pEncoding.DecodeString(strings.ToUpper(host[:52]))
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressV2(wire.NetworkI2P, data, port,
+			services), nil
+	}
+
 	// tor address is 16 char base32 + ".onion"
 	var ip net.IP
 	if len(host) == 22 && host[16:] == ".onion" {
@@ -742,8 +848,17 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.S
 
 // ipString returns a string for the ip from the provided NetAddress. If the
 // ip is in the range used for tor addresses then it will be transformed into
-// the relevant .onion address.
+// the relevant .onion address.  Tor v3 and I2P addresses are transformed into
+// their .onion and .b32.i2p addresses.
 func ipString(na *wire.NetAddress) string {
+	switch na.Network {
+	case wire.NetworkTorV3:
+		return encodeTorV3(na.Addr)
+	case wire.NetworkI2P:
+		return strings.ToLower(i2pEncoding.EncodeToString(na.Addr)) +
+			".b32.i2p"
+	}
+
 	if IsOnionCatTor(na) {
 		// We know now that na.IP is long enogh.
 		base32 := base32.StdEncoding.EncodeToString(na.IP[6:])
@@ -973,7 +1088,8 @@ func (a *AddrManager) Good(addr *wire.NetAddress) {
 // with the given priority.
 func (a *AddrManager) AddLocalAddress(na *wire.NetAddress, priority AddressPriority) error {
 	if !IsRoutable(na) {
-		return fmt.Errorf("address %s is not routable", na.IP)
+		return fmt.Errorf("address %s is not routable",
+			NetAddressKey(na))
 	}
 
 	a.lamtx.Lock()
@@ -1011,8 +1127,8 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Unreachable
 	}
 
-	if IsOnionCatTor(remoteAddr) {
-		if IsOnionCatTor(localAddr) {
+	if IsTor(remoteAddr) {
+		if IsTor(localAddr) {
 			return Private
 		}
 
@@ -1023,6 +1139,15 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Default
 	}
 
+	// I2P and CJDNS addresses are only reachable from the same network.
+	if IsI2P(remoteAddr) || IsCJDNS(remoteAddr) {
+		if localAddr.Network == remoteAddr.Network {
+			return Private
+		}
+
+		return Default
+	}
+
 	if IsRFC4380(remoteAddr) {
 		if !IsRoutable(localAddr) {
 			return Default
@@ -1100,7 +1225,7 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 
 		// Send something unroutable if nothing suitable.
 		var ip net.IP
-		if !IsIPv4(remoteAddr) && !IsOnionCatTor(remoteAddr) {
+		if !IsIPv4(remoteAddr) && !IsTor(remoteAddr) {
 			ip = net.IPv6zero
 		} else {
 			ip = net.IPv4zero
@@ -1125,6 +1250,19 @@ func New(dataDir string, lookupFunc func(string) ([]net.IP, error)) *AddrManager
 	return &am
 }
 
+// Services returns the services the given address is known to support or zero
+// when the address is unknown.
+func (a *AddrManager) Services(addr *wire.NetAddress) wire.ServiceFlag {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ka := a.find(addr)
+	if ka == nil {
+		return 0
+	}
+	return ka.NetAddress().Services
+}
+
 // SetServices sets the services for the giiven address to the provided value.
 func (a *AddrManager) SetServices(addr *wire.NetAddress, services wire.ServiceFlag) {
 	a.mtx.Lock()
@@ -119,6 +119,28 @@ func IsOnionCatTor(na *wire.NetAddress) bool {
 	return onionCatNet.Contains(na.IP)
 }
 
+// IsTorV3 returns whether or not the passed address is a Tor v3 hidden service
+// address.
+func IsTorV3(na *wire.NetAddress) bool {
+	return na.Network == wire.NetworkTorV3
+}
+
+// IsTor returns whether or not the passed address is a Tor hidden service
+// address, either a Tor v2 address in the OnionCat range or a Tor v3 address.
+func IsTor(na *wire.NetAddress) bool {
+	return IsOnionCatTor(na) || IsTorV3(na)
+}
+
+// IsI2P returns whether or not the passed address is an I2P address.
+func IsI2P(na *wire.NetAddress) bool {
+	return na.Network == wire.NetworkI2P
+}
+
+// IsCJDNS returns whether or not the passed address is a CJDNS address.
+func IsCJDNS(na *wire.NetAddress) bool {
+	return na.Network == wire.NetworkCJDNS
+}
+
 // IsRFC1918 returns whether or not the passed address is part of the IPv4
 // private network address space as defined by RFC1918 (10.0.0.0/8,
 // 172.16.0.0/12, or 192.168.0.0/16).
@@ -214,7 +236,16 @@ func IsRFC6598(na *wire.NetAddress) bool {
 // considered invalid under the following circumstances:
 // IPv4: It is either a zero or all bits set address.
 // IPv6: It is either a zero or RFC3849 documentation address.
+// Tor v3 and I2P: It is not 32 bytes.
+// CJDNS: It is not in the fc00::/8 range.
 func IsValid(na *wire.NetAddress) bool {
+	switch na.Network {
+	case wire.NetworkTorV3, wire.NetworkI2P:
+		return len(na.Addr) == 32
+	case wire.NetworkCJDNS:
+		return len(na.IP) == net.IPv6len && na.IP[0] == 0xfc
+	}
+
 	// IsUnspecified returns if address is 0, so only all bits set, and
 	// RFC3849 need to be explicitly checked.
 	return na.IP != nil && !(na.IP.IsUnspecified() ||
@@ -223,8 +254,12 @@ func IsValid(na *wire.NetAddress) bool {
 
 // IsRoutable returns whether or not the passed address is routable over
 // the public internet.  This is true as long as the address is valid and is not
-// in any reserved ranges.
+// in any reserved ranges.  Tor v3, I2P and CJDNS addresses are routable over
+// their respective networks as long as they are valid.
 func IsRoutable(na *wire.NetAddress) bool {
+	if na.NeedsAddrV2() {
+		return IsValid(na)
+	}
 	return IsValid(na) && !(IsRFC1918(na) || IsRFC2544(na) ||
 		IsRFC3927(na) || IsRFC4862(na) || IsRFC3849(na) ||
 		IsRFC4843(na) || IsRFC5737(na) || IsRFC6598(na) ||
@@ -234,8 +269,10 @@ func IsRoutable(na *wire.NetAddress) bool {
 // GroupKey returns a string representing the network group an address is part
 // of.  This is the /16 for IPv4, the /32 (/36 for he.net) for IPv6, the string
 // "local" for a local address, the string "tor:key" where key is the /4 of the
-// onion address for tor address, and the string "unroutable" for an unroutable
-// address.
+// onion address for tor address, the strings "torv3:key", "i2p:key" and
+// "cjdns:key" where key is the /4 of the address after any network prefix for
+// Tor v3, I2P and CJDNS addresses, and the string "unroutable" for an
+// unroutable address.
 func GroupKey(na *wire.NetAddress) string {
 	if IsLocal(na) {
 		return "local"
@@ -243,6 +280,14 @@ func GroupKey(na *wire.NetAddress) string {
 	if !IsRoutable(na) {
 		return "unroutable"
 	}
+	switch na.Network {
+	case wire.NetworkTorV3:
+		return fmt.Sprintf("torv3:%d", na.Addr[0]>>4)
+	case wire.NetworkI2P:
+		return fmt.Sprintf("i2p:%d", na.Addr[0]>>4)
+	case wire.NetworkCJDNS:
+		return fmt.Sprintf("cjdns:%d", na.IP[1]>>4)
+	}
 	if IsIPv4(na) {
 		return na.IP.Mask(net.CIDRMask(16, 32)).String()
 	}
@@ -7,7 +7,9 @@
 package addrmgr_test
 
 import (
+	"io/ioutil"
 	"net"
+	"os"
 	"testing"
 
 	"github.com/james-ray/hcd/addrmgr"
@@ -200,3 +202,122 @@ func TestGroupKey(t *testing.T) {
 		}
 	}
 }
+
+// TestAddrV2Networks ensures Tor v3 and I2P addresses are parsed from and
+// converted back to their host names and that Tor v3, I2P and CJDNS addresses
+// are routable and are grouped by network.
+func TestAddrV2Networks(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		network wire.NetworkID
+		group   string
+	}{
+		{
+			name:    "tor v3",
+			host:    "duckduckgogg42xjoc72x3sjasowoarfbgcmvfimaftt6twagswzczad.onion",
+			network: wire.NetworkTorV3,
+			group:   "torv3:1",
+		},
+		{
+			name:    "i2p",
+			host:    "ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnkdq.b32.i2p",
+			network: wire.NetworkI2P,
+			group:   "i2p:10",
+		},
+		{
+			name:    "cjdns",
+			host:    "fc32:17ea:e415:c3bf:9808:149d:b5a2:c9aa",
+			network: wire.NetworkCJDNS,
+			group:   "cjdns:3",
+		},
+	}
+
+	amgr := addrmgr.New("", nil)
+	for _, test := range tests {
+		var na *wire.NetAddress
+		var err error
+		if test.network == wire.NetworkCJDNS {
+			na = wire.NewNetAddressV2(test.network,
+				net.ParseIP(test.host), 9108, wire.SFNodeNetwork)
+		} else {
+			na, err = amgr.HostToNetAddress(test.host, 9108,
+				wire.SFNodeNetwork)
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if na.NetworkID() != test.network {
+			t.Errorf("%s: got network %v, want %v", test.name,
+				na.NetworkID(), test.network)
+		}
+		if !addrmgr.IsRoutable(na) {
+			t.Errorf("%s: address is not routable", test.name)
+		}
+		if key := addrmgr.GroupKey(na); key != test.group {
+			t.Errorf("%s: got group key '%s', want '%s'", test.name,
+				key, test.group)
+		}
+		want := net.JoinHostPort(test.host, "9108")
+		if key := addrmgr.NetAddressKey(na); key != want {
+			t.Errorf("%s: got key '%s', want '%s'", test.name, key,
+				want)
+		}
+	}
+
+	// Tor v3 addresses with a bad checksum must be rejected.
+	_, err := amgr.HostToNetAddress("duckduckgogg42xjoc72x3sjasowoarfbgcmvf"+
+		"imaftt6twagswzczab.onion", 9108, wire.SFNodeNetwork)
+	if err == nil {
+		t.Error("tor v3 bad checksum: did not receive expected error")
+	}
+
+	// Addresses in the fc00::/8 range used by CJDNS are unique local IPv6
+	// addresses unless they were received on the CJDNS network.
+	na, err := amgr.HostToNetAddress("fc32:17ea:e415:c3bf:9808:149d:b5a2:"+
+		"c9aa", 9108, wire.SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("fc00::/8 host: unexpected error: %v", err)
+	}
+	if na.NetworkID() != wire.NetworkIPv6 {
+		t.Errorf("fc00::/8 host: got network %v, want %v",
+			na.NetworkID(), wire.NetworkIPv6)
+	}
+	if addrmgr.IsRoutable(na) {
+		t.Error("fc00::/8 host: address is routable")
+	}
+}
+
+// TestSavePeersCJDNS ensures CJDNS addresses are still CJDNS addresses after
+// they are saved to and loaded from the peers file.
+func TestSavePeersCJDNS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testsavepeerscjdns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	amgr := addrmgr.New(dir, nil)
+	amgr.Start()
+	na := wire.NewNetAddressV2(wire.NetworkCJDNS,
+		net.ParseIP("fc32:17ea:e415:c3bf:9808:149d:b5a2:c9aa"), 9108,
+		wire.SFNodeNetwork)
+	src := wire.NewNetAddressIPPort(net.ParseIP("173.194.115.66"), 9108,
+		wire.SFNodeNetwork)
+	amgr.AddAddress(na, src)
+	if err := amgr.Stop(); err != nil {
+		t.Fatalf("Address Manager failed to stop: %v", err)
+	}
+
+	amgr = addrmgr.New(dir, nil)
+	amgr.Start()
+	defer amgr.Stop()
+	ka := amgr.GetAddress()
+	if ka == nil {
+		t.Fatal("CJDNS address was not loaded")
+	}
+	if got := ka.NetAddress().NetworkID(); got != wire.NetworkCJDNS {
+		t.Errorf("got network %v, want %v", got, wire.NetworkCJDNS)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// anchorsFilename is the name of the file in the data directory which houses
+// the addresses of the block-relay-only peers the server was connected to at
+// shutdown.  These anchors are connected to first at the next startup, which
+// makes it harder for an attacker to take over all of the outbound connections
+// of a restarted node.
+const anchorsFilename = "anchors.json"
+
+// loadAnchors returns the anchor addresses saved at the last shutdown and
+// removes the file they were saved to so they are only used once, which
+// prevents connecting to the same peers over and over should they misbehave
+// in a way that causes a crash.
+func loadAnchors(dataDir string) []string {
+	filePath := filepath.Join(dataDir, anchorsFilename)
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			srvrLog.Warnf("Unable to read anchors from %s: %v",
+				filePath, err)
+		}
+		return nil
+	}
+	if err := os.Remove(filePath); err != nil {
+		srvrLog.Warnf("Unable to remove %s: %v", filePath, err)
+	}
+
+	var anchors []string
+	if err := json.Unmarshal(data, &anchors); err != nil {
+		srvrLog.Warnf("Unable to parse anchors from %s: %v", filePath,
+			err)
+		return nil
+	}
+	if len(anchors) > defaultBlockRelayOnlyOutbound {
+		anchors = anchors[:defaultBlockRelayOnlyOutbound]
+	}
+	return anchors
+}
+
+// saveAnchors saves the passed anchor addresses so they are connected to at the
+// next startup.
+func saveAnchors(dataDir string, anchors []string) {
+	filePath := filepath.Join(dataDir, anchorsFilename)
+	data, err := json.Marshal(anchors)
+	if err != nil {
+		srvrLog.Errorf("Unable to encode anchors: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(filePath, data, 0600); err != nil {
+		srvrLog.Errorf("Unable to write anchors to %s: %v", filePath,
+			err)
+		return
+	}
+	srvrLog.Debugf("Saved %d anchors to %s", len(anchors), filePath)
+}
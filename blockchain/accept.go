@@ -249,6 +249,14 @@ func (b *BlockChain) maybeAcceptBlock(block *hcutil.Block, flags BehaviorFlags)
 		return false, err
 	}
 
+	// Reject blocks which were manually marked invalid or build on a block
+	// which was.
+	if _, ok := b.invalidBlocks[*block.Hash()]; ok || b.isInvalidated(prevNode) {
+		str := fmt.Sprintf("block %v is marked invalid or builds on a "+
+			"block which is", block.Hash())
+		return false, ruleError(ErrKnownInvalidBlock, str)
+	}
+
 	blockHeight := block.Height()
 
 	// The block must pass all of the validation rules which depend on the
@@ -0,0 +1,404 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/wire"
+)
+
+const (
+	// Version is the version of the bootstrap file format written by
+	// Writer.
+	Version = 1
+
+	// DefaultChunkSize is the default number of bytes of serialized blocks
+	// Writer collects in a chunk.
+	DefaultChunkSize = 4 * 1024 * 1024
+
+	// MaxChunkSize is the maximum chunk size that can be passed to
+	// NewWriter.  A chunk exceeds the chunk size by at most one block, so
+	// readers reject chunks larger than this plus the max block size.
+	MaxChunkSize = 64 * 1024 * 1024
+
+	// fileHeaderSize is the size of the serialized file header.
+	//
+	// The serialized file header format is:
+	//
+	//  [0:4]   Magic (4 bytes)
+	//  [4:8]   Version (4 bytes)
+	//  [8:12]  Network (4 bytes)
+	fileHeaderSize = 12
+
+	// chunkHeaderSize is the size of the serialized chunk header which
+	// precedes the serialized blocks of every chunk.
+	//
+	// The serialized chunk header format is:
+	//
+	//  [0:4]   Number of blocks (4 bytes)
+	//  [4:8]   Payload length (4 bytes)
+	//  [8:12]  Height of the first block (4 bytes)
+	//  [12:44] Hash of the last block (32 bytes)
+	//  [44:76] Chunk hash (32 bytes)
+	//
+	// The chunk hash commits to the preceding fields and the payload, which
+	// is the sequence of <block length (4 bytes)><serialized block> of the
+	// blocks in the chunk.  A chunk without blocks marks the end of the
+	// file.
+	chunkHeaderSize = 76
+
+	// chunkHashOffset is the offset of the chunk hash in the serialized
+	// chunk header.
+	chunkHashOffset = 44
+
+	// maxChunkPayload is the maximum payload length of a chunk.
+	maxChunkPayload = MaxChunkSize + 4 + wire.MaxBlockPayload
+)
+
+var (
+	// byteOrder is the preferred byte order used for serializing integers
+	// in bootstrap files.
+	byteOrder = binary.LittleEndian
+
+	// fileMagic identifies bootstrap files.  It differs from the network
+	// magic at the start of the flat files written by older versions of
+	// hcd, so both formats can be told apart.
+	fileMagic = [4]byte{'h', 'c', 'b', 's'}
+)
+
+// IsBootstrap returns whether or not the passed data, which must be at least
+// four bytes, is the start of a bootstrap file.
+func IsBootstrap(data []byte) bool {
+	return len(data) >= len(fileMagic) && bytes.Equal(data[:4], fileMagic[:])
+}
+
+// ChunkHeader describes a chunk of blocks of a bootstrap file.
+type ChunkHeader struct {
+	NumBlocks   uint32
+	PayloadLen  uint32
+	FirstHeight uint32
+	LastHash    chainhash.Hash
+	Hash        chainhash.Hash
+}
+
+// serializeChunkHeader returns the serialized chunk header for the passed
+// fields and payload including the chunk hash.
+func serializeChunkHeader(numBlocks, firstHeight uint32, lastHash *chainhash.Hash, payload []byte) []byte {
+	var serialized [chunkHeaderSize]byte
+	byteOrder.PutUint32(serialized[0:4], numBlocks)
+	byteOrder.PutUint32(serialized[4:8], uint32(len(payload)))
+	byteOrder.PutUint32(serialized[8:12], firstHeight)
+	copy(serialized[12:44], lastHash[:])
+	hash := chunkHash(serialized[:chunkHashOffset], payload)
+	copy(serialized[chunkHashOffset:], hash[:])
+	return serialized[:]
+}
+
+// chunkHash returns the hash committing to the passed serialized chunk header
+// fields and payload.
+func chunkHash(fields, payload []byte) chainhash.Hash {
+	data := make([]byte, 0, len(fields)+len(payload))
+	data = append(data, fields...)
+	data = append(data, payload...)
+	return chainhash.HashH(data)
+}
+
+// Writer writes blocks to a bootstrap file.  The blocks must be written in the
+// order of their heights without gaps.
+type Writer struct {
+	w          io.Writer
+	chunkSize  int
+	payload    bytes.Buffer
+	numBlocks  uint32
+	first      uint32
+	next       uint32
+	lastHash   chainhash.Hash
+	hasWritten bool
+	closed     bool
+}
+
+// NewWriter writes the bootstrap file header for the passed network to the
+// passed writer and returns a Writer which collects the blocks written to it
+// in chunks of about the passed size.
+func NewWriter(w io.Writer, net wire.CurrencyNet, chunkSize int) (*Writer, error) {
+	if chunkSize <= 0 || chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("chunk size %d is not between 1 and %d",
+			chunkSize, MaxChunkSize)
+	}
+
+	var header [fileHeaderSize]byte
+	copy(header[0:4], fileMagic[:])
+	byteOrder.PutUint32(header[4:8], Version)
+	byteOrder.PutUint32(header[8:12], uint32(net))
+	if _, err := w.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, chunkSize: chunkSize}, nil
+}
+
+// WriteBlock adds the passed block to the current chunk and writes the chunk
+// once it reaches the chunk size.
+func (w *Writer) WriteBlock(block *hcutil.Block) error {
+	if w.closed {
+		return errors.New("write to closed bootstrap writer")
+	}
+
+	height := block.MsgBlock().Header.Height
+	if w.hasWritten && height != w.next {
+		return fmt.Errorf("block %v has height %d, want %d",
+			block.Hash(), height, w.next)
+	}
+	serialized, err := block.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if w.numBlocks == 0 {
+		w.first = height
+	}
+	var blockLen [4]byte
+	byteOrder.PutUint32(blockLen[:], uint32(len(serialized)))
+	w.payload.Write(blockLen[:])
+	w.payload.Write(serialized)
+	w.numBlocks++
+	w.next = height + 1
+	w.lastHash = *block.Hash()
+	w.hasWritten = true
+
+	if w.payload.Len() < w.chunkSize {
+		return nil
+	}
+	return w.Flush()
+}
+
+// Flush writes the blocks collected in the current chunk, if any.
+func (w *Writer) Flush() error {
+	if w.numBlocks == 0 {
+		return nil
+	}
+
+	payload := w.payload.Bytes()
+	header := serializeChunkHeader(w.numBlocks, w.first, &w.lastHash,
+		payload)
+	if _, err := w.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(payload); err != nil {
+		return err
+	}
+	w.payload.Reset()
+	w.numBlocks = 0
+	return nil
+}
+
+// Close flushes the current chunk and writes the end of file marker.  It does
+// not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	w.closed = true
+	_, err := w.w.Write(serializeChunkHeader(0, 0, &chainhash.Hash{}, nil))
+	return err
+}
+
+// Reader reads the chunks of a bootstrap file.
+type Reader struct {
+	r      io.Reader
+	net    wire.CurrencyNet
+	offset int64
+
+	// header is the header of the current chunk and pending is set while
+	// its payload has not been read or skipped yet.
+	header  ChunkHeader
+	fields  [chunkHashOffset]byte
+	pending bool
+}
+
+// NewReader reads and validates the bootstrap file header from the passed
+// reader and returns a Reader for the chunks which follow it.
+//
+// An Error with ErrNotBootstrap is returned when the input does not start with
+// a bootstrap file header and with ErrUnsupportedVersion when the file format
+// version is not supported.
+func NewReader(r io.Reader) (*Reader, error) {
+	var header [fileHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			str := "input is too short for a bootstrap file"
+			return nil, makeError(ErrNotBootstrap, str, nil)
+		}
+		return nil, err
+	}
+	if !IsBootstrap(header[:]) {
+		str := fmt.Sprintf("input starts with %x instead of the "+
+			"bootstrap file magic %x", header[:4], fileMagic)
+		return nil, makeError(ErrNotBootstrap, str, nil)
+	}
+	version := byteOrder.Uint32(header[4:8])
+	if version != Version {
+		str := fmt.Sprintf("bootstrap file version %d is not "+
+			"supported -- the supported version is %d", version,
+			Version)
+		return nil, makeError(ErrUnsupportedVersion, str, nil)
+	}
+
+	return &Reader{
+		r:      r,
+		net:    wire.CurrencyNet(byteOrder.Uint32(header[8:12])),
+		offset: fileHeaderSize,
+	}, nil
+}
+
+// Network returns the network of the blocks in the bootstrap file.
+func (r *Reader) Network() wire.CurrencyNet {
+	return r.net
+}
+
+// Offset returns the number of bytes read from the underlying reader.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// Next reads the header of the next chunk.  The payload of the previous chunk
+// is skipped when it has not been read.  It returns io.EOF after the end of
+// file marker and an Error with ErrTruncated when the input ends before it.
+func (r *Reader) Next() (*ChunkHeader, error) {
+	if r.pending {
+		if err := r.Skip(); err != nil {
+			return nil, err
+		}
+	}
+
+	var serialized [chunkHeaderSize]byte
+	if _, err := io.ReadFull(r.r, serialized[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			str := fmt.Sprintf("bootstrap file ends at offset %d "+
+				"without an end of file marker", r.offset)
+			return nil, makeError(ErrTruncated, str, nil)
+		}
+		return nil, err
+	}
+	r.offset += chunkHeaderSize
+
+	header := ChunkHeader{
+		NumBlocks:   byteOrder.Uint32(serialized[0:4]),
+		PayloadLen:  byteOrder.Uint32(serialized[4:8]),
+		FirstHeight: byteOrder.Uint32(serialized[8:12]),
+	}
+	copy(header.LastHash[:], serialized[12:44])
+	copy(header.Hash[:], serialized[chunkHashOffset:])
+	if header.PayloadLen > maxChunkPayload {
+		str := fmt.Sprintf("chunk at height %d has a payload of %d "+
+			"bytes which exceeds the max of %d bytes",
+			header.FirstHeight, header.PayloadLen, maxChunkPayload)
+		return nil, makeError(ErrMalformedChunk, str, nil)
+	}
+
+	// The end of file marker has no blocks and no payload.
+	if header.NumBlocks == 0 {
+		if header.PayloadLen != 0 {
+			str := "end of file marker has a payload"
+			return nil, makeError(ErrMalformedChunk, str, nil)
+		}
+		if chunkHash(serialized[:chunkHashOffset], nil) != header.Hash {
+			str := "end of file marker hash does not match"
+			return nil, makeError(ErrChunkHash, str, nil)
+		}
+		return nil, io.EOF
+	}
+
+	r.header = header
+	copy(r.fields[:], serialized[:chunkHashOffset])
+	r.pending = true
+	return &header, nil
+}
+
+// ReadBlocks reads the payload of the current chunk, verifies it against the
+// chunk hash, and returns the serialized blocks it contains.
+func (r *Reader) ReadBlocks() ([][]byte, error) {
+	if !r.pending {
+		return nil, errors.New("no chunk to read")
+	}
+	r.pending = false
+
+	payload := make([]byte, r.header.PayloadLen)
+	if _, err := io.ReadFull(r.r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			str := fmt.Sprintf("bootstrap file ends within the "+
+				"chunk at height %d", r.header.FirstHeight)
+			return nil, makeError(ErrTruncated, str, nil)
+		}
+		return nil, err
+	}
+	r.offset += int64(len(payload))
+
+	if chunkHash(r.fields[:], payload) != r.header.Hash {
+		str := fmt.Sprintf("hash of the chunk at height %d does not "+
+			"match", r.header.FirstHeight)
+		return nil, makeError(ErrChunkHash, str, nil)
+	}
+
+	blocks := make([][]byte, 0, r.header.NumBlocks)
+	for len(payload) > 0 {
+		if len(payload) < 4 {
+			str := fmt.Sprintf("chunk at height %d ends within a "+
+				"block length", r.header.FirstHeight)
+			return nil, makeError(ErrMalformedChunk, str, nil)
+		}
+		blockLen := byteOrder.Uint32(payload[:4])
+		payload = payload[4:]
+		if blockLen > wire.MaxBlockPayload || blockLen > uint32(len(payload)) {
+			str := fmt.Sprintf("chunk at height %d has an invalid "+
+				"block length of %d bytes", r.header.FirstHeight,
+				blockLen)
+			return nil, makeError(ErrMalformedChunk, str, nil)
+		}
+		blocks = append(blocks, payload[:blockLen:blockLen])
+		payload = payload[blockLen:]
+	}
+	if uint32(len(blocks)) != r.header.NumBlocks {
+		str := fmt.Sprintf("chunk at height %d has %d blocks instead "+
+			"of %d", r.header.FirstHeight, len(blocks),
+			r.header.NumBlocks)
+		return nil, makeError(ErrMalformedChunk, str, nil)
+	}
+	return blocks, nil
+}
+
+// Skip skips the payload of the current chunk without verifying it.
+func (r *Reader) Skip() error {
+	if !r.pending {
+		return nil
+	}
+	r.pending = false
+
+	n := int64(r.header.PayloadLen)
+	if seeker, ok := r.r.(io.Seeker); ok {
+		if _, err := seeker.Seek(n, io.SeekCurrent); err != nil {
+			return err
+		}
+	} else if _, err := io.CopyN(ioutil.Discard, r.r, n); err != nil {
+		if err == io.EOF {
+			str := fmt.Sprintf("bootstrap file ends within the "+
+				"chunk at height %d", r.header.FirstHeight)
+			return makeError(ErrTruncated, str, nil)
+		}
+		return err
+	}
+	r.offset += n
+	return nil
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bootstrap
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/wire"
+)
+
+// testBlocks returns the passed number of minimal blocks with consecutive
+// heights starting at 1.
+func testBlocks(n int) []*hcutil.Block {
+	blocks := make([]*hcutil.Block, 0, n)
+	for i := 1; i <= n; i++ {
+		var msgBlock wire.MsgBlock
+		msgBlock.Header.Height = uint32(i)
+		msgBlock.Header.Nonce = uint32(i)
+		blocks = append(blocks, hcutil.NewBlock(&msgBlock))
+	}
+	return blocks
+}
+
+// writeTestFile returns a bootstrap file for the simulation network holding the
+// passed blocks in chunks of the passed size.
+func writeTestFile(t *testing.T, blocks []*hcutil.Block, chunkSize int) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, wire.SimNet, chunkSize)
+	if err != nil {
+		t.Fatalf("NewWriter: unexpected error: %v", err)
+	}
+	for _, block := range blocks {
+		if err := w.WriteBlock(block); err != nil {
+			t.Fatalf("WriteBlock: unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestRoundTrip ensures blocks written to a bootstrap file are read back in
+// order and with the expected chunk boundaries.
+func TestRoundTrip(t *testing.T) {
+	blocks := testBlocks(10)
+	blockSize := blocks[0].MsgBlock().SerializeSize()
+
+	// Each chunk is written once it holds at least three blocks.
+	data := writeTestFile(t, blocks, 3*(blockSize+4))
+	if !IsBootstrap(data) {
+		t.Fatal("IsBootstrap: written file is not detected")
+	}
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader: unexpected error: %v", err)
+	}
+	if r.Network() != wire.SimNet {
+		t.Fatalf("Network: got %v, want %v", r.Network(), wire.SimNet)
+	}
+
+	var read int
+	var numChunks int
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: unexpected error: %v", err)
+		}
+		numChunks++
+		if header.FirstHeight != uint32(read+1) {
+			t.Fatalf("chunk %d: got first height %d, want %d",
+				numChunks, header.FirstHeight, read+1)
+		}
+		raw, err := r.ReadBlocks()
+		if err != nil {
+			t.Fatalf("ReadBlocks: unexpected error: %v", err)
+		}
+		for _, serialized := range raw {
+			want, _ := blocks[read].Bytes()
+			if !bytes.Equal(serialized, want) {
+				t.Fatalf("block %d does not match", read+1)
+			}
+			read++
+		}
+		if header.LastHash != *blocks[read-1].Hash() {
+			t.Fatalf("chunk %d: got last hash %v, want %v", numChunks,
+				header.LastHash, blocks[read-1].Hash())
+		}
+	}
+	if read != len(blocks) {
+		t.Fatalf("got %d blocks, want %d", read, len(blocks))
+	}
+	if numChunks != 4 {
+		t.Fatalf("got %d chunks, want 4", numChunks)
+	}
+	if r.Offset() != int64(len(data)) {
+		t.Fatalf("Offset: got %d, want %d", r.Offset(), len(data))
+	}
+}
+
+// TestSkip ensures chunks can be skipped with and without the underlying
+// reader supporting seeks.
+func TestSkip(t *testing.T) {
+	blocks := testBlocks(6)
+	blockSize := blocks[0].MsgBlock().SerializeSize()
+	data := writeTestFile(t, blocks, 2*(blockSize+4))
+
+	readers := []struct {
+		name string
+		r    io.Reader
+	}{
+		{"seeker", bytes.NewReader(data)},
+		{"stream", bytes.NewBuffer(data)},
+	}
+	for _, test := range readers {
+		r, err := NewReader(test.r)
+		if err != nil {
+			t.Fatalf("%s: NewReader: unexpected error: %v", test.name, err)
+		}
+
+		// Skip the first chunk explicitly and the second one by moving on
+		// to the next chunk without reading it.
+		if _, err := r.Next(); err != nil {
+			t.Fatalf("%s: Next: unexpected error: %v", test.name, err)
+		}
+		if err := r.Skip(); err != nil {
+			t.Fatalf("%s: Skip: unexpected error: %v", test.name, err)
+		}
+		if _, err := r.Next(); err != nil {
+			t.Fatalf("%s: Next: unexpected error: %v", test.name, err)
+		}
+		header, err := r.Next()
+		if err != nil {
+			t.Fatalf("%s: Next: unexpected error: %v", test.name, err)
+		}
+		if header.FirstHeight != 5 {
+			t.Fatalf("%s: got first height %d, want 5", test.name,
+				header.FirstHeight)
+		}
+		raw, err := r.ReadBlocks()
+		if err != nil {
+			t.Fatalf("%s: ReadBlocks: unexpected error: %v", test.name,
+				err)
+		}
+		if len(raw) != 2 {
+			t.Fatalf("%s: got %d blocks, want 2", test.name, len(raw))
+		}
+		if _, err := r.Next(); err != io.EOF {
+			t.Fatalf("%s: Next: got %v, want io.EOF", test.name, err)
+		}
+	}
+}
+
+// TestWriteBlockHeights ensures the writer rejects blocks which do not follow
+// the previous block.
+func TestWriteBlockHeights(t *testing.T) {
+	blocks := testBlocks(3)
+	w, err := NewWriter(ioutil.Discard, wire.SimNet, DefaultChunkSize)
+	if err != nil {
+		t.Fatalf("NewWriter: unexpected error: %v", err)
+	}
+	if err := w.WriteBlock(blocks[0]); err != nil {
+		t.Fatalf("WriteBlock: unexpected error: %v", err)
+	}
+	if err := w.WriteBlock(blocks[2]); err == nil {
+		t.Fatal("WriteBlock: expected an error for a height gap")
+	}
+	if _, err := NewWriter(ioutil.Discard, wire.SimNet, 0); err == nil {
+		t.Fatal("NewWriter: expected an error for a zero chunk size")
+	}
+}
+
+// TestReadErrors ensures malformed bootstrap files are rejected with the
+// expected error codes.
+func TestReadErrors(t *testing.T) {
+	data := writeTestFile(t, testBlocks(3), DefaultChunkSize)
+
+	// mutate returns a copy of the test file modified by the passed func.
+	mutate := func(f func([]byte) []byte) []byte {
+		return f(append([]byte(nil), data...))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want ErrorCode
+	}{{
+		name: "empty",
+		data: nil,
+		want: ErrNotBootstrap,
+	}, {
+		name: "bad magic",
+		data: mutate(func(b []byte) []byte {
+			b[0] ^= 0xff
+			return b
+		}),
+		want: ErrNotBootstrap,
+	}, {
+		name: "unsupported version",
+		data: mutate(func(b []byte) []byte {
+			byteOrder.PutUint32(b[4:8], Version+1)
+			return b
+		}),
+		want: ErrUnsupportedVersion,
+	}, {
+		name: "corrupt payload",
+		data: mutate(func(b []byte) []byte {
+			b[fileHeaderSize+chunkHeaderSize+10] ^= 0x01
+			return b
+		}),
+		want: ErrChunkHash,
+	}, {
+		name: "corrupt last hash",
+		data: mutate(func(b []byte) []byte {
+			b[fileHeaderSize+12] ^= 0x01
+			return b
+		}),
+		want: ErrChunkHash,
+	}, {
+		name: "oversized payload",
+		data: mutate(func(b []byte) []byte {
+			byteOrder.PutUint32(b[fileHeaderSize+4:], maxChunkPayload+1)
+			return b
+		}),
+		want: ErrMalformedChunk,
+	}, {
+		name: "truncated chunk",
+		data: data[:fileHeaderSize+chunkHeaderSize+10],
+		want: ErrTruncated,
+	}, {
+		name: "missing end of file marker",
+		data: data[:len(data)-chunkHeaderSize],
+		want: ErrTruncated,
+	}}
+
+	for _, test := range tests {
+		err := readAll(test.data)
+		rErr, ok := err.(Error)
+		if !ok {
+			t.Errorf("%s: got error %v (%T), want an Error", test.name,
+				err, err)
+			continue
+		}
+		if rErr.ErrorCode != test.want {
+			t.Errorf("%s: got error code %v, want %v", test.name,
+				rErr.ErrorCode, test.want)
+		}
+	}
+}
+
+// readAll reads all chunks of the passed bootstrap file and returns the first
+// error.
+func readAll(data []byte) error {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := r.ReadBlocks(); err != nil {
+			return err
+		}
+	}
+}
+
+// TestErrorCodeStringer tests the stringized output for the ErrorCode type.
+func TestErrorCodeStringer(t *testing.T) {
+	tests := []struct {
+		in   ErrorCode
+		want string
+	}{
+		{ErrNotBootstrap, "ErrNotBootstrap"},
+		{ErrUnsupportedVersion, "ErrUnsupportedVersion"},
+		{ErrWrongNetwork, "ErrWrongNetwork"},
+		{ErrChunkHash, "ErrChunkHash"},
+		{ErrMalformedChunk, "ErrMalformedChunk"},
+		{ErrTruncated, "ErrTruncated"},
+		{ErrInvalidBlock, "ErrInvalidBlock"},
+		{ErrInterrupted, "ErrInterrupted"},
+		{0xffff, "Unknown ErrorCode (65535)"},
+	}
+
+	// Detect additional error codes that don't have the stringer added.
+	if len(tests)-1 != int(numErrorCodes) {
+		t.Errorf("It appears an error code was added without adding an " +
+			"associated stringer test")
+	}
+
+	for i, test := range tests {
+		result := test.in.String()
+		if result != test.want {
+			t.Errorf("String #%d\n got: %s want: %s", i, result,
+				test.want)
+		}
+	}
+}
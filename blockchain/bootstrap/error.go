@@ -0,0 +1,90 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bootstrap
+
+import "fmt"
+
+// ErrorCode identifies a kind of error.
+type ErrorCode int
+
+// These constants are used to identify a specific bootstrap Error.
+const (
+	// ErrNotBootstrap indicates the input does not start with a bootstrap
+	// file header.
+	ErrNotBootstrap ErrorCode = iota
+
+	// ErrUnsupportedVersion indicates the input uses a version of the
+	// bootstrap file format which is not supported.
+	ErrUnsupportedVersion
+
+	// ErrWrongNetwork indicates the bootstrap file holds blocks for a
+	// network other than the one being imported to.
+	ErrWrongNetwork
+
+	// ErrChunkHash indicates the hash of a chunk does not match the hash
+	// stored in its header.
+	ErrChunkHash
+
+	// ErrMalformedChunk indicates a chunk can't be parsed.
+	ErrMalformedChunk
+
+	// ErrTruncated indicates the input ends before the end of file marker.
+	ErrTruncated
+
+	// ErrInvalidBlock indicates a block of a chunk is invalid or does not
+	// extend the main chain.
+	ErrInvalidBlock
+
+	// ErrInterrupted indicates the import was interrupted.  The blocks
+	// imported until then are kept, so the import can be resumed.
+	ErrInterrupted
+
+	// numErrorCodes is the maximum error code number used in tests.
+	numErrorCodes
+)
+
+// Map of ErrorCode values back to their constant names for pretty printing.
+var errorCodeStrings = map[ErrorCode]string{
+	ErrNotBootstrap:       "ErrNotBootstrap",
+	ErrUnsupportedVersion: "ErrUnsupportedVersion",
+	ErrWrongNetwork:       "ErrWrongNetwork",
+	ErrChunkHash:          "ErrChunkHash",
+	ErrMalformedChunk:     "ErrMalformedChunk",
+	ErrTruncated:          "ErrTruncated",
+	ErrInvalidBlock:       "ErrInvalidBlock",
+	ErrInterrupted:        "ErrInterrupted",
+}
+
+// String returns the ErrorCode as a human-readable name.
+func (e ErrorCode) String() string {
+	if s := errorCodeStrings[e]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ErrorCode (%d)", int(e))
+}
+
+// Error provides a single type for errors that can happen while reading or
+// importing bootstrap files.
+//
+// The caller can use type assertions to determine if an error is an Error and
+// access the ErrorCode field to ascertain the specific reason for the failure.
+type Error struct {
+	ErrorCode   ErrorCode // Describes the kind of error
+	Description string    // Human readable description of the issue
+	Err         error     // Underlying error
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e Error) Error() string {
+	if e.Err != nil {
+		return e.Description + ": " + e.Err.Error()
+	}
+	return e.Description
+}
+
+// makeError creates an Error given a set of arguments.
+func makeError(c ErrorCode, desc string, err error) Error {
+	return Error{ErrorCode: c, Description: desc, Err: err}
+}
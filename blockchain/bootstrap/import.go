@@ -0,0 +1,531 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bootstrap
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/txscript"
+	"github.com/james-ray/hcd/wire"
+)
+
+const (
+	// defaultProgressInterval is the default minimum interval between two
+	// progress messages logged during an import.
+	defaultProgressInterval = 10 * time.Second
+
+	// precheckScriptFlags are the script flags used when checking the
+	// signatures of the imported blocks ahead of processing them.  They
+	// match the consensus flags used by the chain, so the signatures are
+	// found in the signature cache when the scripts are run again.
+	precheckScriptFlags = txscript.ScriptBip16 |
+		txscript.ScriptVerifyDERSignatures |
+		txscript.ScriptVerifyStrictEncoding |
+		txscript.ScriptVerifyMinimalData |
+		txscript.ScriptVerifyCleanStack |
+		txscript.ScriptVerifyCheckLockTimeVerify |
+		txscript.ScriptVerifyCheckSequenceVerify |
+		txscript.ScriptVerifySHA256
+)
+
+// zeroHash is the zero value for a chainhash.Hash and is defined as a package
+// level variable to avoid the need to create a new instance every time a check
+// is needed.
+var zeroHash chainhash.Hash
+
+// ImportConfig is a descriptor which specifies the bootstrap import
+// configuration.
+type ImportConfig struct {
+	// Chain is the chain the blocks are imported to.
+	Chain *blockchain.BlockChain
+
+	// ChainParams identifies which chain parameters the chain is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// TimeSource defines the median time source used by the context-free
+	// block checks.
+	TimeSource blockchain.MedianTimeSource
+
+	// SigCache defines the signature cache the signatures of the blocks
+	// after the latest checkpoint are checked against before they are
+	// processed.  It must be the signature cache used by the chain for the
+	// checks to speed up the import.  The signatures are not prechecked
+	// when it is nil.
+	SigCache *txscript.SigCache
+
+	// Workers is the number of goroutines deserializing and checking the
+	// blocks ahead of processing them.  It defaults to the number of CPUs.
+	Workers int
+
+	// Size is the size of the bootstrap file in bytes.  It is only used to
+	// report the progress and may be zero when it is unknown.
+	Size int64
+
+	// ProgressInterval is the minimum interval between two progress
+	// messages.  It defaults to 10 seconds and progress is not logged when
+	// it is negative.
+	ProgressInterval time.Duration
+
+	// Interrupt stops the import between blocks when it is closed.
+	Interrupt <-chan struct{}
+}
+
+// ImportResult houses the statistics of an import.
+type ImportResult struct {
+	// BlocksProcessed is the number of blocks read from the bootstrap file
+	// including the blocks which were already known.
+	BlocksProcessed int64
+
+	// BlocksImported is the number of blocks added to the chain.
+	BlocksImported int64
+
+	// Duration is the time the import took.
+	Duration time.Duration
+}
+
+// importChunk houses a chunk of a bootstrap file while it moves through the
+// import pipeline.  The done channel is closed once the blocks are prepared.
+type importChunk struct {
+	header  *ChunkHeader
+	skipped bool
+	offset  int64
+	raw     [][]byte
+	blocks  []*hcutil.Block
+	err     error
+	done    chan struct{}
+}
+
+// prevOutput houses the script of a transaction output spent by an imported
+// block.
+type prevOutput struct {
+	version  uint16
+	pkScript []byte
+}
+
+// importer houses the state of an import.
+type importer struct {
+	cfg              *ImportConfig
+	checkpointHeight int64
+	progressInterval time.Duration
+	quit             chan struct{}
+	result           ImportResult
+	startTime        time.Time
+
+	// These fields track the progress since the last progress message.
+	lastLogTime    time.Time
+	lastLogBlocks  int64
+	lastLogOffset  int64
+	lastHeight     int64
+	lastBlockTime  time.Time
+	receivedOffset int64
+}
+
+// readHandler reads the chunks of the bootstrap file and passes them to the
+// workers and, in order, to the processing loop.  Chunks whose last block is
+// already part of the main chain were imported before and are skipped without
+// reading them, which resumes an interrupted import.  It must be run as a
+// goroutine.
+func (imp *importer) readHandler(r *Reader, prepare, ordered chan<- *importChunk) {
+	defer close(ordered)
+	defer close(prepare)
+
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return
+		}
+		chunk := &importChunk{header: header, done: make(chan struct{})}
+		if err != nil {
+			chunk.err = err
+			close(chunk.done)
+			select {
+			case ordered <- chunk:
+			case <-imp.quit:
+			}
+			return
+		}
+
+		known, err := imp.cfg.Chain.MainChainHasBlock(&header.LastHash)
+		if err == nil && known {
+			chunk.skipped = true
+			chunk.err = r.Skip()
+		} else {
+			chunk.raw, chunk.err = r.ReadBlocks()
+		}
+		chunk.offset = r.Offset()
+
+		// The chunk must not be accessed once it is passed to the
+		// workers, so whether reading it failed is noted beforehand.
+		failed := chunk.err != nil
+		if chunk.skipped || failed {
+			close(chunk.done)
+		} else {
+			select {
+			case prepare <- chunk:
+			case <-imp.quit:
+				return
+			}
+		}
+		select {
+		case ordered <- chunk:
+		case <-imp.quit:
+			return
+		}
+		if failed {
+			return
+		}
+	}
+}
+
+// prepareHandler deserializes and checks the blocks of the chunks it receives
+// until the channel is closed.  It must be run as a goroutine.
+func (imp *importer) prepareHandler(prepare <-chan *importChunk) {
+	for chunk := range prepare {
+		chunk.blocks, chunk.err = imp.prepareChunk(chunk)
+		chunk.raw = nil
+		close(chunk.done)
+	}
+}
+
+// prepareChunk deserializes the blocks of the passed chunk and performs the
+// checks which do not depend on the chain state on them.  This also caches the
+// block and transaction hashes, so processing the blocks afterwards is faster.
+// The signatures of the blocks after the latest checkpoint are checked against
+// the signature cache when one is configured.
+func (imp *importer) prepareChunk(chunk *importChunk) ([]*hcutil.Block, error) {
+	blocks := make([]*hcutil.Block, 0, len(chunk.raw))
+	var outputs map[wire.OutPoint]prevOutput
+	for i, serialized := range chunk.raw {
+		height := chunk.header.FirstHeight + uint32(i)
+		block, err := hcutil.NewBlockFromBytes(serialized)
+		if err != nil {
+			str := fmt.Sprintf("unable to deserialize the block at "+
+				"height %d", height)
+			return nil, makeError(ErrInvalidBlock, str, err)
+		}
+		if block.MsgBlock().Header.Height != height {
+			str := fmt.Sprintf("block %v has height %d instead of %d",
+				block.Hash(), block.MsgBlock().Header.Height, height)
+			return nil, makeError(ErrInvalidBlock, str, nil)
+		}
+		err = blockchain.CheckBlockSanity(block, imp.cfg.TimeSource,
+			imp.cfg.ChainParams)
+		if err != nil {
+			str := fmt.Sprintf("block %v at height %d is invalid",
+				block.Hash(), height)
+			return nil, makeError(ErrInvalidBlock, str, err)
+		}
+
+		if imp.cfg.SigCache != nil && int64(height) > imp.checkpointHeight {
+			if outputs == nil {
+				outputs = make(map[wire.OutPoint]prevOutput)
+			}
+			imp.precheckSignatures(block, outputs)
+		}
+		blocks = append(blocks, block)
+	}
+
+	lastHash := blocks[len(blocks)-1].Hash()
+	if *lastHash != chunk.header.LastHash {
+		str := fmt.Sprintf("last block %v of the chunk at height %d "+
+			"does not match the hash %v in its header", lastHash,
+			chunk.header.FirstHeight, chunk.header.LastHash)
+		return nil, makeError(ErrMalformedChunk, str, nil)
+	}
+	return blocks, nil
+}
+
+// precheckSignatures runs the scripts of all inputs of the passed block whose
+// previous outputs are either created by the blocks of the same chunk, which
+// are tracked by the passed map, or are unspent in the chain.  Valid signatures
+// are added to the signature cache, so they do not have to be verified again
+// when the block is processed.  Script failures are ignored since the scripts
+// are run again when the block is connected.
+func (imp *importer) precheckSignatures(block *hcutil.Block, outputs map[wire.OutPoint]prevOutput) {
+	trees := []struct {
+		txs  []*hcutil.Tx
+		tree int8
+	}{
+		{block.Transactions(), wire.TxTreeRegular},
+		{block.STransactions(), wire.TxTreeStake},
+	}
+
+	// Add the outputs of the block first since transactions may spend the
+	// outputs of earlier transactions in the same block.
+	for _, t := range trees {
+		for _, tx := range t.txs {
+			for i, txOut := range tx.MsgTx().TxOut {
+				outpoint := wire.OutPoint{Hash: *tx.Hash(),
+					Index: uint32(i), Tree: t.tree}
+				outputs[outpoint] = prevOutput{
+					version:  txOut.Version,
+					pkScript: txOut.PkScript,
+				}
+			}
+		}
+	}
+
+	entries := make(map[chainhash.Hash]*blockchain.UtxoEntry)
+	for _, t := range trees {
+		for _, tx := range t.txs {
+			msgTx := tx.MsgTx()
+			for i, txIn := range msgTx.TxIn {
+				// Coinbase and stakebase inputs have no previous
+				// output.
+				prevOut := &txIn.PreviousOutPoint
+				if prevOut.Hash == zeroHash {
+					continue
+				}
+
+				output, ok := outputs[*prevOut]
+				if !ok {
+					entry, ok := entries[prevOut.Hash]
+					if !ok {
+						entry, _ = imp.cfg.Chain.FetchUtxoEntry(
+							&prevOut.Hash)
+						entries[prevOut.Hash] = entry
+					}
+					if entry == nil {
+						continue
+					}
+					pkScript := entry.PkScriptByIndex(prevOut.Index)
+					if pkScript == nil {
+						continue
+					}
+					output = prevOutput{
+						version:  entry.ScriptVersionByIndex(prevOut.Index),
+						pkScript: pkScript,
+					}
+				}
+
+				vm, err := txscript.NewEngine(output.pkScript, msgTx,
+					i, precheckScriptFlags, output.version,
+					imp.cfg.SigCache)
+				if err != nil {
+					continue
+				}
+				_ = vm.Execute()
+			}
+		}
+	}
+}
+
+// processBlock adds the passed block to the chain unless it is already known.
+// Blocks up to the latest checkpoint are added with the fast add flag since
+// they are verified by the checkpoint.  It returns whether or not the block was
+// imported.
+func (imp *importer) processBlock(block *hcutil.Block) (bool, error) {
+	chain := imp.cfg.Chain
+	blockHash := block.Hash()
+	exists, err := chain.HaveBlock(blockHash)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	// Don't bother trying to process orphans.
+	prevHash := &block.MsgBlock().Header.PrevBlock
+	exists, err = chain.HaveBlock(prevHash)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		str := fmt.Sprintf("block %v at height %d does not link to "+
+			"the available block chain", blockHash, block.Height())
+		return false, makeError(ErrInvalidBlock, str, nil)
+	}
+
+	flags := blockchain.BFNone
+	if block.Height() <= imp.checkpointHeight {
+		flags = blockchain.BFFastAdd
+	}
+	isMainChain, isOrphan, err := chain.ProcessBlock(block, flags)
+	if err != nil {
+		str := fmt.Sprintf("block %v at height %d was rejected",
+			blockHash, block.Height())
+		return false, makeError(ErrInvalidBlock, str, err)
+	}
+	if !isMainChain || isOrphan {
+		str := fmt.Sprintf("block %v at height %d does not extend the "+
+			"main chain", blockHash, block.Height())
+		return false, makeError(ErrInvalidBlock, str, nil)
+	}
+	return true, nil
+}
+
+// logProgress logs the import progress as an information message at most once
+// every progress interval.
+func (imp *importer) logProgress(force bool) {
+	if imp.progressInterval < 0 {
+		return
+	}
+	now := time.Now()
+	duration := now.Sub(imp.lastLogTime)
+	if !force && duration < imp.progressInterval {
+		return
+	}
+	blocks := imp.result.BlocksProcessed - imp.lastLogBlocks
+	if blocks == 0 {
+		return
+	}
+
+	// Truncate the duration to 10s of milliseconds.
+	durationMillis := int64(duration / time.Millisecond)
+	tDuration := 10 * time.Millisecond * time.Duration(durationMillis/10)
+
+	blockStr := "blocks"
+	if blocks == 1 {
+		blockStr = "block"
+	}
+	progress := ""
+	if !imp.lastBlockTime.IsZero() {
+		progress = fmt.Sprintf(", %s", imp.lastBlockTime)
+	}
+	if imp.cfg.Size > 0 {
+		progress += fmt.Sprintf(", %.1f%% of the file",
+			float64(imp.receivedOffset)*100/float64(imp.cfg.Size))
+
+		// Estimate the remaining time from the rate at which the file
+		// was consumed since the last message.
+		consumed := imp.receivedOffset - imp.lastLogOffset
+		remaining := imp.cfg.Size - imp.receivedOffset
+		if consumed > 0 && remaining > 0 {
+			eta := time.Duration(float64(duration) *
+				float64(remaining) / float64(consumed))
+			progress += fmt.Sprintf(", about %v remaining",
+				eta.Truncate(time.Second))
+		}
+	}
+	log.Infof("Processed %d %s in the last %s (height %d%s)", blocks,
+		blockStr, tDuration, imp.lastHeight, progress)
+
+	imp.lastLogTime = now
+	imp.lastLogBlocks = imp.result.BlocksProcessed
+	imp.lastLogOffset = imp.receivedOffset
+}
+
+// interrupted returns whether or not the import has been interrupted.
+func (imp *importer) interrupted() bool {
+	select {
+	case <-imp.cfg.Interrupt:
+		return true
+	default:
+		return false
+	}
+}
+
+// Import reads the bootstrap file from the passed reader and adds its blocks
+// to the chain.  The file is read, and the blocks are deserialized and checked,
+// by separate goroutines ahead of the blocks being processed in order by the
+// chain.
+//
+// Blocks which are already known are skipped, so an interrupted import is
+// resumed by importing the same file again.  Whole chunks are skipped without
+// reading them when their last block is part of the main chain.
+//
+// The statistics of the import are returned along with any error.  An Error
+// with ErrInterrupted is returned when the import is interrupted.
+func Import(r io.Reader, cfg *ImportConfig) (*ImportResult, error) {
+	imp := &importer{
+		cfg:         cfg,
+		quit:        make(chan struct{}),
+		startTime:   time.Now(),
+		lastLogTime: time.Now(),
+	}
+	imp.checkpointHeight = -1
+	imp.progressInterval = cfg.ProgressInterval
+	if imp.progressInterval == 0 {
+		imp.progressInterval = defaultProgressInterval
+	}
+	if checkpoint := cfg.Chain.LatestCheckpoint(); checkpoint != nil {
+		imp.checkpointHeight = checkpoint.Height
+	}
+	result := func() *ImportResult {
+		imp.result.Duration = time.Since(imp.startTime)
+		return &imp.result
+	}
+
+	br, err := NewReader(r)
+	if err != nil {
+		return result(), err
+	}
+	if br.Network() != cfg.ChainParams.Net {
+		str := fmt.Sprintf("bootstrap file is for network %v instead of "+
+			"%v", br.Network(), cfg.ChainParams.Net)
+		return result(), makeError(ErrWrongNetwork, str, nil)
+	}
+
+	// Start the reader and the workers.  They are stopped by closing the
+	// quit channel once the import is done.
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	prepare := make(chan *importChunk, workers)
+	ordered := make(chan *importChunk, workers*2)
+	defer close(imp.quit)
+	go imp.readHandler(br, prepare, ordered)
+	for i := 0; i < workers; i++ {
+		go imp.prepareHandler(prepare)
+	}
+
+	// Process the chunks in the order of the file.
+	for chunk := range ordered {
+		select {
+		case <-chunk.done:
+		case <-cfg.Interrupt:
+			str := "bootstrap import interrupted"
+			return result(), makeError(ErrInterrupted, str, nil)
+		}
+		if chunk.err != nil {
+			return result(), chunk.err
+		}
+
+		imp.receivedOffset = chunk.offset
+		if chunk.skipped {
+			imp.result.BlocksProcessed += int64(chunk.header.NumBlocks)
+			imp.lastHeight = int64(chunk.header.FirstHeight) +
+				int64(chunk.header.NumBlocks) - 1
+			imp.logProgress(false)
+			continue
+		}
+
+		for _, block := range chunk.blocks {
+			if imp.interrupted() {
+				str := "bootstrap import interrupted"
+				return result(), makeError(ErrInterrupted, str, nil)
+			}
+
+			imported, err := imp.processBlock(block)
+			if err != nil {
+				return result(), err
+			}
+			imp.result.BlocksProcessed++
+			if imported {
+				imp.result.BlocksImported++
+			}
+			imp.lastHeight = block.Height()
+			imp.lastBlockTime = block.MsgBlock().Header.Timestamp
+			imp.logProgress(false)
+		}
+	}
+
+	// The reader is done once the ordered channel is closed, so the offset
+	// including the end of file marker can be read safely.
+	imp.receivedOffset = br.Offset()
+	imp.logProgress(true)
+
+	return result(), nil
+}
@@ -247,6 +247,12 @@ type BlockChain struct {
 	nextCheckpoint  *chaincfg.Checkpoint
 	checkpointBlock *hcutil.Block
 
+	// invalidBlocks houses the hashes of the blocks which were manually
+	// marked invalid with InvalidateBlock.  Those blocks and any blocks
+	// building on them are rejected.  It is protected by the chain lock and
+	// is stored in the database so it persists across restarts.
+	invalidBlocks map[chainhash.Hash]struct{}
+
 	// The state is used as a fairly efficient way to cache information
 	// about the current best chain state that is returned to callers when
 	// requested.  It operates on the principle of MVCC such that any time a
@@ -1686,7 +1692,13 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List,
 	// at least a couple of ways accomplish that rollback, but both involve
 	// tweaking the chain and/or database.  This approach catches these
 	// issues before ever modifying the chain.
-	var topBlock *blockNode
+	//
+	// The fork point becomes the new best block when there are no blocks to
+	// attach, which is the case when blocks are only being disconnected.
+	topBlock := b.bestNode
+	if e := detachNodes.Back(); e != nil {
+		topBlock = e.Value.(*blockNode).parent
+	}
 	for e := attachNodes.Front(); e != nil; e = e.Next() {
 		n := e.Value.(*blockNode)
 		b.blockCacheLock.RLock()
@@ -1796,22 +1808,23 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List,
 	}
 
 	// Log the point where the chain forked.
-	firstAttachNode := attachNodes.Front().Value.(*blockNode)
-	forkNode, err := b.getPrevNodeFromNode(firstAttachNode)
-	if err == nil {
+	forkNode := topBlock
+	if e := attachNodes.Front(); e != nil {
+		forkNode, _ = b.getPrevNodeFromNode(e.Value.(*blockNode))
+	}
+	if forkNode != nil {
 		log.Infof("REORGANIZE: Chain forks at %v, height %v",
 			forkNode.hash,
 			forkNode.height)
 	}
 
 	// Log the old and new best chain heads.
-	lastAttachNode := attachNodes.Back().Value.(*blockNode)
 	log.Infof("REORGANIZE: Old best chain head was %v, height %v",
 		formerBestHash,
 		formerBestHeight)
 	log.Infof("REORGANIZE: New best chain head is %v, height %v",
-		lastAttachNode.hash,
-		lastAttachNode.height)
+		newHash,
+		newHeight)
 
 	return nil
 }
@@ -1918,6 +1931,231 @@ func (b *BlockChain) ForceHeadReorganization(formerBest chainhash.Hash, newBest
 	return b.forceHeadReorganization(formerBest, newBest)
 }
 
+// isInvalidated returns whether the passed block node or any of its side chain
+// ancestors was manually marked invalid with InvalidateBlock.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) isInvalidated(node *blockNode) bool {
+	if len(b.invalidBlocks) == 0 {
+		return false
+	}
+
+	for n := node; n != nil; n = n.parent {
+		if _, ok := b.invalidBlocks[n.hash]; ok {
+			return true
+		}
+		if n.inMainChain {
+			break
+		}
+	}
+	return false
+}
+
+// isDescendantOf returns whether the passed block node is the same as or
+// descends from the passed ancestor by following the block nodes in memory.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func isDescendantOf(node, ancestor *blockNode) bool {
+	for n := node; n != nil && n.height >= ancestor.height; n = n.parent {
+		if n.hash == ancestor.hash {
+			return true
+		}
+	}
+	return false
+}
+
+// bestValidTip returns the side chain tip with the most cumulative work that
+// forks from the main chain at or before the passed main chain block node and
+// can be reorganized to, which means all of its side chain blocks are cached
+// and none of them are marked invalid.  The passed node is returned when no
+// side chain has more work.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) bestValidTip(base *blockNode) *blockNode {
+	best := base
+	b.blockCacheLock.RLock()
+	defer b.blockCacheLock.RUnlock()
+	for hash := range b.blockCache {
+		node, ok := b.index[hash]
+		if !ok || node.inMainChain || node.workSum.Cmp(best.workSum) <= 0 {
+			continue
+		}
+
+		// Ensure the side chain leading to the node can be attached and
+		// forks from the main chain at or before the base node.
+		valid := true
+		n := node
+		for ; n != nil && !n.inMainChain; n = n.parent {
+			_, invalid := b.invalidBlocks[n.hash]
+			_, cached := b.blockCache[n.hash]
+			if invalid || !cached {
+				valid = false
+				break
+			}
+		}
+		if !valid || n == nil || n.height > base.height {
+			continue
+		}
+
+		best = node
+	}
+	return best
+}
+
+// reorganizeToNode reorganizes the chain so the passed block node becomes the
+// end of the main chain.  It does nothing when the node is already the end of
+// the main chain.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) reorganizeToNode(node *blockNode) error {
+	detachNodes, attachNodes, err := b.getReorganizeNodes(node)
+	if err != nil {
+		return err
+	}
+	if detachNodes.Len() == 0 && attachNodes.Len() == 0 {
+		return nil
+	}
+
+	return b.reorganizeChain(detachNodes, attachNodes, BFNone)
+}
+
+// invalidateBlock marks the block identified by the passed hash as invalid and,
+// when it is in the main chain, reorganizes the chain away from it.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) invalidateBlock(hash *chainhash.Hash) error {
+	if hash.IsEqual(b.chainParams.GenesisHash) {
+		return fmt.Errorf("the genesis block can't be invalidated")
+	}
+
+	var inMainChain, exists bool
+	err := b.db.View(func(dbTx database.Tx) error {
+		inMainChain = dbMainChainHasBlock(dbTx, hash)
+		var err error
+		exists, err = dbTx.HasBlock(hash)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if _, ok := b.index[*hash]; !ok && !exists {
+		return fmt.Errorf("block %v is not known", hash)
+	}
+
+	b.invalidBlocks[*hash] = struct{}{}
+
+	// Reorganize the chain to the best valid chain which does not include
+	// the block when it is in the main chain.  When the invalidated block
+	// is the current tip and the new best chain is a sibling of it, the
+	// reorganization is simply a forced head reorganization.
+	if inMainChain {
+		node, err := b.findNode(hash, 0)
+		if err != nil {
+			delete(b.invalidBlocks, *hash)
+			return err
+		}
+		base, err := b.getPrevNodeFromNode(node)
+		if err != nil {
+			delete(b.invalidBlocks, *hash)
+			return err
+		}
+
+		target := b.bestValidTip(base)
+		if target != base && node == b.bestNode && target.parent == base {
+			err = b.forceHeadReorganization(node.hash, target.hash)
+		} else {
+			err = b.reorganizeToNode(target)
+		}
+		if err != nil && target != base {
+			log.Warnf("Unable to reorganize to block %v after "+
+				"invalidating block %v: %v", target.hash, hash, err)
+			err = b.reorganizeToNode(base)
+		}
+		if err != nil {
+			delete(b.invalidBlocks, *hash)
+			return err
+		}
+	}
+
+	return b.db.Update(func(dbTx database.Tx) error {
+		return dbPutInvalidBlock(dbTx, hash)
+	})
+}
+
+// InvalidateBlock marks the block identified by the passed hash as invalid so
+// that it and any blocks building on it are rejected.  When the block is in
+// the main chain, the chain is reorganized to the best valid side chain that
+// does not include it, or to its parent when there is no such side chain.
+//
+// The mark is persisted in the database and can be removed with
+// ReconsiderBlock.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) InvalidateBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+	return b.invalidateBlock(hash)
+}
+
+// reconsiderBlock removes the invalid mark from the block identified by the
+// passed hash, along with any of its ancestors and descendants, and then
+// reorganizes the chain to the best valid chain.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) reconsiderBlock(hash *chainhash.Hash) error {
+	if _, ok := b.invalidBlocks[*hash]; !ok {
+		return fmt.Errorf("block %v is not marked invalid", hash)
+	}
+
+	// Find all of the invalidated blocks which are either ancestors or
+	// descendants of the block.
+	reconsidered := []chainhash.Hash{*hash}
+	if node, ok := b.index[*hash]; ok {
+		for invalidHash := range b.invalidBlocks {
+			n, ok := b.index[invalidHash]
+			if !ok || invalidHash == *hash {
+				continue
+			}
+			if isDescendantOf(n, node) || isDescendantOf(node, n) {
+				reconsidered = append(reconsidered, invalidHash)
+			}
+		}
+	}
+
+	err := b.db.Update(func(dbTx database.Tx) error {
+		for i := range reconsidered {
+			err := dbRemoveInvalidBlock(dbTx, &reconsidered[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, reconsideredHash := range reconsidered {
+		delete(b.invalidBlocks, reconsideredHash)
+	}
+
+	return b.reorganizeToNode(b.bestValidTip(b.bestNode))
+}
+
+// ReconsiderBlock removes the invalid mark set by InvalidateBlock from the block
+// identified by the passed hash, along with any of its invalidated ancestors and
+// descendants, and reorganizes the chain to the side chain with the most work
+// when it has more work than the current main chain.
+//
+// Note that the blocks disconnected by InvalidateBlock are only available to be
+// reorganized to until the chain instance is shut down.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ReconsiderBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+	return b.reconsiderBlock(hash)
+}
+
 // connectBestChain handles connecting the passed block to the chain while
 // respecting proper chain selection according to the chain with the most
 // proof of work.  In the typical case, the new block simply extends the main
@@ -2165,9 +2403,8 @@ func (b *BlockChain) BestSnapshot() *BestState {
 //
 // This function MUST be called with the chain state lock held (for reads).
 func (b *BlockChain) maxBlockSize(prevNode *blockNode) (int64, error) {
-	// Hard fork voting on block size is only enabled on testnet v1 and
-	// simnet.
-	if b.chainParams.Net != wire.SimNet {
+	// Hard fork voting on block size is only enabled on simnet and regnet.
+	if b.chainParams.Net != wire.SimNet && b.chainParams.Net != wire.RegTest {
 		return int64(b.chainParams.MaximumBlockSizes[0]), nil
 	}
 
@@ -2201,6 +2438,40 @@ func (b *BlockChain) MaxBlockSize() (int64, error) {
 	return maxSize, err
 }
 
+// isAutoRevocationsAgendaActive returns whether or not the version 8 stake vote
+// for the automatic ticket revocations agenda is active for the block AFTER the
+// provided node.  It always returns false for networks that do not define the
+// agenda.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) isAutoRevocationsAgendaActive(prevNode *blockNode) (bool, error) {
+	// NOTE: The choice field of the return threshold state is not examined
+	// here because there is only one possible choice that can be active
+	// for the agenda, which is yes, so there is no need to check it.
+	state, err := b.deploymentState(prevNode, 8,
+		chaincfg.VoteIDAutoRevocations)
+	if err != nil {
+		if _, ok := err.(DeploymentError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return state.State == ThresholdActive, nil
+}
+
+// IsAutoRevocationsAgendaActive returns whether or not the automatic ticket
+// revocations agenda is active for the block AFTER the end of the current best
+// chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsAutoRevocationsAgendaActive() (bool, error) {
+	b.chainLock.Lock()
+	isActive, err := b.isAutoRevocationsAgendaActive(b.bestNode)
+	b.chainLock.Unlock()
+	return isActive, err
+}
+
 // ChainWork returns the total work up to and including the block of the
 // provided block hash.
 func (b *BlockChain) ChainWork(hash *chainhash.Hash) (*big.Int, error) {
@@ -2329,6 +2600,16 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Load the blocks which were manually marked invalid.
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		b.invalidBlocks, err = dbFetchInvalidBlocks(dbTx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize and catch up all of the currently active optional indexes
 	// as needed.
 	if config.IndexManager != nil {
@@ -130,3 +130,170 @@ func TestBlockchainFunctions(t *testing.T) {
 			totalSubsidy)
 	}
 }
+
+// TestInvalidateReconsiderBlock ensures that invalidating blocks reorganizes the
+// chain to the best valid chain which does not include them, that blocks which
+// build on invalidated blocks are rejected, and that reconsidering them
+// restores the best chain.
+func TestInvalidateReconsiderBlock(t *testing.T) {
+	// Create a test harness initialized with the genesis block as the tip.
+	params := &chaincfg.SimNetParams
+	g, teardownFunc := newChaingenHarness(t, params, "invalidatetest")
+	defer teardownFunc()
+
+	// mustInvalidate and mustReconsider invalidate and reconsider the
+	// block associated with the given name and expect the current best
+	// chain tip to be the provided value afterwards.
+	mustInvalidate := func(blockName, tipName string) {
+		t.Helper()
+
+		hash := g.BlockByName(blockName).BlockHash()
+		if err := g.chain.InvalidateBlock(&hash); err != nil {
+			t.Fatalf("unable to invalidate block %q: %v", blockName,
+				err)
+		}
+		g.ExpectTip(tipName)
+	}
+	mustReconsider := func(blockName, tipName string) {
+		t.Helper()
+
+		hash := g.BlockByName(blockName).BlockHash()
+		if err := g.chain.ReconsiderBlock(&hash); err != nil {
+			t.Fatalf("unable to reconsider block %q: %v", blockName,
+				err)
+		}
+		g.ExpectTip(tipName)
+	}
+
+	// expectMainChain ensures whether or not the blocks associated with the
+	// given names are part of the main chain matches the provided value.
+	expectMainChain := func(want bool, blockNames ...string) {
+		t.Helper()
+
+		for _, blockName := range blockNames {
+			hash := g.BlockByName(blockName).BlockHash()
+			got, err := g.chain.MainChainHasBlock(&hash)
+			if err != nil {
+				t.Fatalf("unable to check main chain for block "+
+					"%q: %v", blockName, err)
+			}
+			if got != want {
+				t.Fatalf("block %q unexpected main chain flag -- "+
+					"got %v, want %v", blockName, got, want)
+			}
+		}
+	}
+
+	// ---------------------------------------------------------------------
+	// Generate and accept enough blocks to reach stake validation height.
+	// ---------------------------------------------------------------------
+
+	g.AdvanceToStakeValidationHeight()
+	forkName := g.TipName()
+
+	// ---------------------------------------------------------------------
+	// Create a main chain of three blocks along with a side chain of two
+	// blocks which forks from the same block.  The first block of each
+	// chain spends a different output so they are unique.
+	//
+	//   ... -> bsv# -> ba1 -> ba2 -> ba3
+	//               \-> bb1 -> bb2
+	// ---------------------------------------------------------------------
+
+	outs := g.OldestCoinbaseOuts()
+	g.NextBlock("ba1", &outs[0], nil)
+	g.AcceptTipBlock()
+	g.NextBlock("ba2", nil, nil)
+	g.AcceptTipBlock()
+	g.NextBlock("ba3", nil, nil)
+	g.AcceptTipBlock()
+
+	g.SetTip(forkName)
+	g.NextBlock("bb1", &outs[1], nil)
+	g.AcceptedToSideChainWithExpectedTip("ba3")
+	g.NextBlock("bb2", nil, nil)
+	g.AcceptedToSideChainWithExpectedTip("ba3")
+
+	// ---------------------------------------------------------------------
+	// Invalidate the current tip and ensure the chain is reorganized to its
+	// parent since the side chain does not have more work.  Then ensure a
+	// block building on the invalidated block is rejected.
+	//
+	//   ... -> bsv# -> ba1 -> ba2 -> (ba3) -> ba4
+	//               \-> bb1 -> bb2
+	// ---------------------------------------------------------------------
+
+	mustInvalidate("ba3", "ba2")
+	expectMainChain(false, "ba3")
+
+	g.SetTip("ba3")
+	g.NextBlock("ba4", nil, nil)
+	g.RejectTipBlock(blockchain.ErrKnownInvalidBlock)
+
+	// ---------------------------------------------------------------------
+	// Reconsider the invalidated tip and ensure it becomes the tip again.
+	// ---------------------------------------------------------------------
+
+	mustReconsider("ba3", "ba3")
+
+	// ---------------------------------------------------------------------
+	// Invalidate the first block of the main chain after the fork point
+	// and ensure the chain is reorganized to the side chain, which means
+	// all of the blocks after the fork point are disconnected.  Then ensure
+	// blocks building on any of the disconnected blocks are rejected.
+	//
+	//   ... -> bsv# -> (ba1) -> ba2 -> ba3 -> ba4
+	//               \-> bb1 -> bb2
+	// ---------------------------------------------------------------------
+
+	mustInvalidate("ba1", "bb2")
+	expectMainChain(false, "ba1", "ba2", "ba3")
+	expectMainChain(true, forkName, "bb1", "bb2")
+	g.RejectBlock("ba4", blockchain.ErrKnownInvalidBlock)
+
+	// ---------------------------------------------------------------------
+	// Invalidate a descendant of the invalidated block as well, reconsider
+	// it, and ensure the mark is removed from its invalidated ancestor too
+	// so the chain is reorganized back to the original main chain, which
+	// has more work.  Then ensure the block which was previously rejected
+	// is accepted.
+	//
+	//   ... -> bsv# -> ba1 -> ba2 -> ba3 -> ba4
+	//               \-> bb1 -> bb2
+	// ---------------------------------------------------------------------
+
+	mustInvalidate("ba3", "bb2")
+	mustReconsider("ba3", "ba3")
+	expectMainChain(true, "ba1", "ba2", "ba3")
+	expectMainChain(false, "bb1", "bb2")
+	g.AcceptBlock("ba4")
+
+	// ---------------------------------------------------------------------
+	// Invalidate a side chain block and ensure the main chain is unchanged
+	// while blocks building on the side chain are rejected.
+	//
+	//   ... -> bsv# -> ba1 -> ba2 -> ba3 -> ba4
+	//               \-> (bb1) -> bb2 -> bb3
+	// ---------------------------------------------------------------------
+
+	mustInvalidate("bb1", "ba4")
+	g.SetTip("bb2")
+	g.NextBlock("bb3", nil, nil)
+	g.RejectTipBlock(blockchain.ErrKnownInvalidBlock)
+
+	// ---------------------------------------------------------------------
+	// Ensure the genesis block can't be invalidated and blocks which are
+	// not marked invalid can't be reconsidered.
+	// ---------------------------------------------------------------------
+
+	if err := g.chain.InvalidateBlock(params.GenesisHash); err == nil {
+		t.Fatal("InvalidateBlock: did not receive expected error when " +
+			"invalidating the genesis block")
+	}
+	hash := g.BlockByName("ba4").BlockHash()
+	if err := g.chain.ReconsiderBlock(&hash); err == nil {
+		t.Fatal("ReconsiderBlock: did not receive expected error when " +
+			"reconsidering a block which is not marked invalid")
+	}
+	g.ExpectTip("ba4")
+}
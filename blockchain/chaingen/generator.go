@@ -430,14 +430,15 @@ func purchaseCommitmentScript(addr hcutil.Address, amount, voteFeeLimit, revocat
 	}
 
 	// The data consists of the 20-byte raw script address for the given
-	// address, 8 bytes for the amount to commit to (with the upper bit flag
-	// set to indicate a pay-to-script-hash address), and 2 bytes for the
-	// fee limits.
-	var data [30]byte
+	// address, 1 byte for the signature type of the address, which is
+	// unused for pay-to-script-hash addresses, 8 bytes for the amount to
+	// commit to (with the upper bit flag set to indicate a
+	// pay-to-script-hash address), and 2 bytes for the fee limits.
+	var data [31]byte
 	copy(data[:], addr.ScriptAddress())
-	binary.LittleEndian.PutUint64(data[20:], uint64(amount))
-	data[27] |= 1 << 7
-	binary.LittleEndian.PutUint16(data[28:], limits)
+	binary.LittleEndian.PutUint64(data[21:], uint64(amount))
+	data[28] |= 1 << 7
+	binary.LittleEndian.PutUint16(data[29:], limits)
 	script, err := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
 		AddData(data[:]).Script()
 	if err != nil {
@@ -41,6 +41,14 @@ var (
 	// deployment.
 	deploymentStateKeyName = []byte("deploymentstate")
 
+	// invalidBlocksBucketName is the name of the db bucket used to house
+	// the hashes of blocks which were manually marked invalid.
+	invalidBlocksBucketName = []byte("invalidblocks")
+
+	// reindexStateKeyName is the name of the db key used to store the state
+	// of a chain reindex which has not yet completed.
+	reindexStateKeyName = []byte("reindexstate")
+
 	// byteOrder is the preferred byte order used for serializing numeric
 	// fields for storage in the database.
 	byteOrder = binary.LittleEndian
@@ -54,7 +62,19 @@ const (
 
 	// currentDatabaseVersion indicates what the current database
 	// version is.
-	currentDatabaseVersion = 2
+	currentDatabaseVersion = 3
+
+	// uncompressedBlocksDatabaseVersion is the version of databases whose
+	// blocks are never stored compressed.  Such databases remain at this
+	// version so older software is still able to open them.
+	uncompressedBlocksDatabaseVersion = 2
+
+	// compressedBlocksDatabaseVersion is the first database version
+	// which allows the blocks to be stored compressed.  Databases are only
+	// upgraded to it once block compression is enabled.  Older software
+	// refuses to open databases with a newer version, so it never reads
+	// compressed blocks it does not understand.
+	compressedBlocksDatabaseVersion = 3
 )
 
 // errNotInMainChain signifies that a block hash or height that is not in the
@@ -1295,6 +1315,136 @@ func dbPutBestState(dbTx database.Tx, snapshot *BestState,
 	return dbTx.Metadata().Put(dbnamespace.ChainStateKeyName, serializedData)
 }
 
+// -----------------------------------------------------------------------------
+// The invalid blocks bucket houses the hashes of the blocks which were marked
+// invalid with InvalidateBlock.  The key is the block hash and the value is
+// empty.
+// -----------------------------------------------------------------------------
+
+// dbPutInvalidBlock uses an existing database transaction to mark the passed
+// block hash as invalid.
+func dbPutInvalidBlock(dbTx database.Tx, hash *chainhash.Hash) error {
+	bucket, err := dbTx.Metadata().CreateBucketIfNotExists(
+		invalidBlocksBucketName)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(hash[:], nil)
+}
+
+// dbRemoveInvalidBlock uses an existing database transaction to remove the
+// invalid mark of the passed block hash.
+func dbRemoveInvalidBlock(dbTx database.Tx, hash *chainhash.Hash) error {
+	bucket := dbTx.Metadata().Bucket(invalidBlocksBucketName)
+	if bucket == nil {
+		return nil
+	}
+
+	return bucket.Delete(hash[:])
+}
+
+// dbFetchInvalidBlocks uses an existing database transaction to fetch the set
+// of all block hashes which are marked invalid.
+func dbFetchInvalidBlocks(dbTx database.Tx) (map[chainhash.Hash]struct{}, error) {
+	invalidBlocks := make(map[chainhash.Hash]struct{})
+	bucket := dbTx.Metadata().Bucket(invalidBlocksBucketName)
+	if bucket == nil {
+		return invalidBlocks, nil
+	}
+
+	err := bucket.ForEach(func(k, v []byte) error {
+		if len(k) != chainhash.HashSize {
+			return database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt invalid block hash: "+
+					"expected %d bytes, got %d", chainhash.HashSize,
+					len(k)),
+			}
+		}
+
+		var hash chainhash.Hash
+		copy(hash[:], k)
+		invalidBlocks[hash] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invalidBlocks, nil
+}
+
+// -----------------------------------------------------------------------------
+// The reindex state is stored while the chain state is being rebuilt from the
+// stored blocks, so that an interrupted reindex is resumed on the next start.
+//
+// The serialized format is:
+//
+//   <target hash><state removed>
+//
+//   Field           Type             Size
+//   target hash     chainhash.Hash   chainhash.HashSize
+//   state removed   bool             1 byte
+// -----------------------------------------------------------------------------
+
+// reindexState houses the state of a chain reindex which has not completed.
+type reindexState struct {
+	target       chainhash.Hash
+	stateRemoved bool
+}
+
+// serializeReindexState returns the serialization of the passed reindex state.
+func serializeReindexState(state *reindexState) []byte {
+	serializedData := make([]byte, chainhash.HashSize+1)
+	copy(serializedData[0:chainhash.HashSize], state.target[:])
+	if state.stateRemoved {
+		serializedData[chainhash.HashSize] = 1
+	}
+	return serializedData
+}
+
+// deserializeReindexState deserializes the passed serialized reindex state.
+func deserializeReindexState(serializedData []byte) (*reindexState, error) {
+	if len(serializedData) != chainhash.HashSize+1 {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt reindex state: expected "+
+				"%d bytes, got %d", chainhash.HashSize+1,
+				len(serializedData)),
+		}
+	}
+
+	var state reindexState
+	copy(state.target[:], serializedData[0:chainhash.HashSize])
+	state.stateRemoved = serializedData[chainhash.HashSize] != 0
+	return &state, nil
+}
+
+// dbPutReindexState uses an existing database transaction to store the passed
+// reindex state.
+func dbPutReindexState(dbTx database.Tx, state *reindexState) error {
+	return dbTx.Metadata().Put(reindexStateKeyName,
+		serializeReindexState(state))
+}
+
+// dbFetchReindexState uses an existing database transaction to fetch the
+// reindex state.  It returns nil when no reindex is pending.
+func dbFetchReindexState(dbTx database.Tx) (*reindexState, error) {
+	serializedData := dbTx.Metadata().Get(reindexStateKeyName)
+	if serializedData == nil {
+		return nil, nil
+	}
+
+	return deserializeReindexState(serializedData)
+}
+
+// dbRemoveReindexState uses an existing database transaction to remove the
+// reindex state.
+func dbRemoveReindexState(dbTx database.Tx) error {
+	return dbTx.Metadata().Delete(reindexStateKeyName)
+}
+
 // createChainState initializes both the database and the chain state to the
 // genesis block.  This includes creating the necessary buckets and inserting
 // the genesis block, so it must only be called on an uninitialized database.
@@ -1328,8 +1478,18 @@ func (b *BlockChain) createChainState() error {
 			return err
 		}
 
+		// Only databases whose blocks are stored compressed are created
+		// with the version older software refuses to open.
+		version := uint32(uncompressedBlocksDatabaseVersion)
+		compressed, err := dbBlocksCompressed(dbTx)
+		if err != nil {
+			return err
+		}
+		if compressed {
+			version = compressedBlocksDatabaseVersion
+		}
 		b.dbInfo = &databaseInfo{
-			version:        currentDatabaseVersion,
+			version:        version,
 			compVer:        currentCompressionVersion,
 			date:           time.Now(),
 			upgradeStarted: false,
@@ -1387,8 +1547,10 @@ func (b *BlockChain) createChainState() error {
 			return err
 		}
 
-		// Store the genesis block into the database.
-		return dbTx.StoreBlock(genesisBlock)
+		// Store the genesis block into the database if needed.  It
+		// already exists when the chain state is being rebuilt from the
+		// stored blocks.
+		return dbMaybeStoreBlock(dbTx, genesisBlock)
 	})
 	return err
 }
@@ -1520,6 +1682,77 @@ func (b *BlockChain) initChainState() error {
 	return b.createChainState()
 }
 
+// removeChainState removes all of the chain state from the database, including
+// the utxo set, spend journal, block indexes and stake database, while leaving
+// the stored blocks intact.  Afterwards, the chain state is initialized to the
+// genesis block by initChainState as if the database were new.
+func removeChainState(db database.DB) error {
+	// Since the utxo set and spend journal can be so large, attempting to
+	// simply delete the buckets in a single database transaction would
+	// result in massive memory usage.  In order to avoid this, use a cursor
+	// to delete a maximum number of entries out of each bucket at a time.
+	const maxDeletions = 2000000
+	largeBuckets := [][]byte{
+		dbnamespace.UtxoSetBucketName,
+		dbnamespace.SpendJournalBucketName,
+		dbnamespace.HashIndexBucketName,
+		dbnamespace.HeightIndexBucketName,
+	}
+	for _, bucketName := range largeBuckets {
+		var totalDeleted uint64
+		for numDeleted := maxDeletions; numDeleted == maxDeletions; {
+			numDeleted = 0
+			err := db.Update(func(dbTx database.Tx) error {
+				bucket := dbTx.Metadata().Bucket(bucketName)
+				if bucket == nil {
+					return nil
+				}
+				cursor := bucket.Cursor()
+				for ok := cursor.First(); ok; ok = cursor.Next() &&
+					numDeleted < maxDeletions {
+
+					if err := cursor.Delete(); err != nil {
+						return err
+					}
+					numDeleted++
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if numDeleted > 0 {
+				totalDeleted += uint64(numDeleted)
+				log.Infof("Deleted %d keys (%d total) from %s",
+					numDeleted, totalDeleted, bucketName)
+			}
+		}
+	}
+
+	// Remove the now empty buckets along with the remaining chain and stake
+	// state.
+	return db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		buckets := append(largeBuckets,
+			dbnamespace.BlockChainDbInfoBucketName, thresholdBucketName)
+		for _, bucketName := range buckets {
+			if meta.Bucket(bucketName) == nil {
+				continue
+			}
+			if err := meta.DeleteBucket(bucketName); err != nil {
+				return err
+			}
+		}
+
+		if err := meta.Delete(dbnamespace.ChainStateKeyName); err != nil {
+			return err
+		}
+
+		return stake.RemoveDatabaseState(dbTx)
+	})
+}
+
 // dbFetchHeaderByHash uses an existing database transaction to retrieve the
 // block header for the provided hash.
 func dbFetchHeaderByHash(dbTx database.Tx, hash *chainhash.Hash) (*wire.BlockHeader, error) {
@@ -223,7 +223,7 @@ func TestStxoDecodeErrors(t *testing.T) {
 			stxo:       spentTxOut{},
 			serialized: hexToBytes("1400016edbc6c4d31bae9f1ccc38538a114bf42de65e86"),
 			errType:    errDeserialize(""),
-			bytesRead:  53,
+			bytesRead:  23,
 		},
 		{
 			name:       "no stakeextra data after script for ticket",
@@ -242,6 +242,26 @@ func TestStxoDecodeErrors(t *testing.T) {
 		},
 	}
 
+	for _, test := range tests {
+		// Ensure the expected error type is returned.
+		gotBytesRead, err := decodeSpentTxOut(test.serialized,
+			&test.stxo, test.stxo.amount, test.stxo.height,
+			test.stxo.index)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.errType) {
+			t.Errorf("decodeSpentTxOut (%s): expected error type "+
+				"does not match - got %T, want %T", test.name,
+				err, test.errType)
+			continue
+		}
+
+		// Ensure the expected number of bytes read is returned.
+		if gotBytesRead != test.bytesRead {
+			t.Errorf("decodeSpentTxOut (%s): unexpected number of "+
+				"bytes read - got %d, want %d", test.name,
+				gotBytesRead, test.bytesRead)
+			continue
+		}
+	}
 }
 
 // TestSpendJournalSerialization ensures serializing and deserializing spend
@@ -1223,3 +1243,65 @@ func TestBestChainStateDeserializeErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestReindexStateSerialization ensures serializing and deserializing the
+// reindex state works as expected, including the error paths.
+func TestReindexStateSerialization(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		state      reindexState
+		serialized []byte
+	}{
+		{
+			name: "state not removed",
+			state: reindexState{
+				target: *newHashFromStr("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"),
+			},
+			serialized: hexToBytes("6fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d619000000000000"),
+		},
+		{
+			name: "state removed",
+			state: reindexState{
+				target:       *newHashFromStr("00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048"),
+				stateRemoved: true,
+			},
+			serialized: hexToBytes("4860eb18bf1b1620e37e9490fc8a427514416fd75159ab86688e9a830000000001"),
+		},
+	}
+
+	for i, test := range tests {
+		// Ensure the state serializes to the expected value.
+		gotBytes := serializeReindexState(&test.state)
+		if !bytes.Equal(gotBytes, test.serialized) {
+			t.Errorf("serializeReindexState #%d (%s): mismatched "+
+				"bytes - got %x, want %x", i, test.name,
+				gotBytes, test.serialized)
+			continue
+		}
+
+		// Ensure the serialized bytes are decoded back to the expected
+		// state.
+		state, err := deserializeReindexState(test.serialized)
+		if err != nil {
+			t.Errorf("deserializeReindexState #%d (%s) "+
+				"unexpected error: %v", i, test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*state, test.state) {
+			t.Errorf("deserializeReindexState #%d (%s) "+
+				"mismatched state - got %v, want %v", i,
+				test.name, *state, test.state)
+		}
+	}
+
+	// Ensure short data is reported as corruption.
+	_, err := deserializeReindexState(hexToBytes("0000"))
+	if derr, ok := err.(database.Error); !ok ||
+		derr.ErrorCode != database.ErrCorruption {
+
+		t.Errorf("deserializeReindexState: unexpected error for short "+
+			"data - got %v, want %v", err, database.ErrCorruption)
+	}
+}
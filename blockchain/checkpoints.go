@@ -14,6 +14,7 @@ import (
 	"github.com/james-ray/hcd/database"
 	"github.com/james-ray/hcd/hcutil"
 	"github.com/james-ray/hcd/txscript"
+	"github.com/james-ray/hcd/wire"
 )
 
 // CheckpointConfirmations is the number of blocks before the end of the current
@@ -233,6 +234,82 @@ func isNonstandardTransaction(tx *hcutil.Tx) bool {
 	return false
 }
 
+// isVoteFinalized returns whether or not the votes of the block with the passed
+// next header finalize it.  Once the stake validation height is reached, the
+// votes included in the next block must be a majority of the tickets per block
+// and must approve the block, since the regular transactions of a disapproved
+// block are not applied.
+func isVoteFinalized(nextHeader *wire.BlockHeader, params *chaincfg.Params) bool {
+	if int64(nextHeader.Height) < params.StakeValidationHeight {
+		return true
+	}
+
+	majority := (params.TicketsPerBlock / 2) + 1
+	return nextHeader.Voters >= majority &&
+		hcutil.IsFlagSet16(nextHeader.VoteBits, hcutil.BlockValid)
+}
+
+// sideChain describes a known side chain by the heights of its tip and of the
+// main chain block it forks from.  The fork height is -1 when the fork point is
+// no longer in the block index.
+type sideChain struct {
+	tipHeight  int64
+	forkHeight int64
+}
+
+// sideChains returns the tips of all side chains in the block index along with
+// the heights they fork from the main chain.
+//
+// This function MUST be called with the chain lock held (for reads).
+func (b *BlockChain) sideChains() []sideChain {
+	// Side chain blocks which are the parent of another side chain block
+	// are not tips.
+	parents := make(map[*blockNode]struct{})
+	for _, node := range b.index {
+		if !node.inMainChain && node.parent != nil {
+			parents[node.parent] = struct{}{}
+		}
+	}
+
+	var chains []sideChain
+	for _, node := range b.index {
+		if node.inMainChain {
+			continue
+		}
+		if _, ok := parents[node]; ok {
+			continue
+		}
+
+		forkNode := node
+		for forkNode != nil && !forkNode.inMainChain {
+			forkNode = forkNode.parent
+		}
+		forkHeight := int64(-1)
+		if forkNode != nil {
+			forkHeight = forkNode.height
+		}
+		chains = append(chains, sideChain{
+			tipHeight:  node.height,
+			forkHeight: forkHeight,
+		})
+	}
+	return chains
+}
+
+// hasPendingSideChain returns whether or not one of the passed side chains
+// forks from the main chain before the passed height and extends to at least
+// that height, meaning a checkpoint at the height would reject a competing
+// chain that is still known.  A side chain whose fork point is no longer in the
+// block index is considered pending as well since its fork height is unknown.
+func hasPendingSideChain(chains []sideChain, height int64) bool {
+	for _, chain := range chains {
+		if chain.tipHeight >= height && chain.forkHeight < height {
+			return true
+		}
+	}
+	return false
+}
+
 // IsCheckpointCandidate returns whether or not the passed block is a good
 // checkpoint candidate.
 //
@@ -245,6 +322,10 @@ func isNonstandardTransaction(tx *hcutil.Tx) bool {
 //     (due to the median time allowance this is not always the case)
 //   - The block must not contain any strange transaction such as those with
 //     nonstandard scripts
+//   - Once stake validation is active, the next block must include a majority
+//     of votes which approve the block
+//   - No known side chain may fork from the main chain before the block and
+//     extend to its height or beyond
 //
 // The intent is that candidates are reviewed by a developer to make the final
 // decision and then manually added to the list of checkpoints for a network.
@@ -254,6 +335,15 @@ func (b *BlockChain) IsCheckpointCandidate(block *hcutil.Block) (bool, error) {
 	b.chainLock.RLock()
 	defer b.chainLock.RUnlock()
 
+	return b.isCheckpointCandidate(block, b.sideChains())
+}
+
+// isCheckpointCandidate returns whether or not the passed block is a good
+// checkpoint candidate given the passed side chains.  See IsCheckpointCandidate
+// for the factors used.
+//
+// This function MUST be called with the chain lock held (for reads).
+func (b *BlockChain) isCheckpointCandidate(block *hcutil.Block, sideChains []sideChain) (bool, error) {
 	// Checkpoints must be enabled.
 	if b.noCheckpoints {
 		return false, fmt.Errorf("checkpoints are disabled")
@@ -319,9 +409,68 @@ func (b *BlockChain) IsCheckpointCandidate(block *hcutil.Block) (bool, error) {
 			}
 		}
 
+		// A checkpoint must be finalized by the votes of the next
+		// block.
+		if !isVoteFinalized(nextHeader, b.chainParams) {
+			return nil
+		}
+
+		// A checkpoint must not reject a side chain which is still
+		// competing with the main chain.
+		if hasPendingSideChain(sideChains, blockHeight) {
+			return nil
+		}
+
 		// All of the checks passed, so the block is a candidate.
 		isCandidate = true
 		return nil
 	})
 	return isCandidate, err
 }
+
+// CheckpointCandidates searches the main chain backwards from the newest block
+// with the required number of confirmations for up to maxCandidates blocks
+// which pass IsCheckpointCandidate and returns them ordered from newest to
+// oldest.  The search stops at the latest checkpoint of the network since there
+// is no point in finding candidates before already existing checkpoints.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckpointCandidates(maxCandidates int) ([]chaincfg.Checkpoint, error) {
+	b.chainLock.RLock()
+	bestHeight := b.bestNode.height
+	noCheckpoints := b.noCheckpoints
+	var stopHeight int64
+	if checkpoint := b.latestCheckpoint(); checkpoint != nil {
+		stopHeight = checkpoint.Height
+	}
+	sideChains := b.sideChains()
+	b.chainLock.RUnlock()
+
+	// Checkpoints must be enabled.
+	if noCheckpoints {
+		return nil, fmt.Errorf("checkpoints are disabled")
+	}
+
+	// The genesis block and the latest checkpoint are never candidates.
+	var candidates []chaincfg.Checkpoint
+	height := bestHeight - CheckpointConfirmations
+	for ; height > stopHeight && len(candidates) < maxCandidates; height-- {
+		block, err := b.BlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		b.chainLock.RLock()
+		isCandidate, err := b.isCheckpointCandidate(block, sideChains)
+		b.chainLock.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		if isCandidate {
+			candidates = append(candidates, chaincfg.Checkpoint{
+				Height: block.Height(),
+				Hash:   block.Hash(),
+			})
+		}
+	}
+	return candidates, nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/wire"
+)
+
+// TestIsVoteFinalized ensures blocks are only considered finalized by the next
+// block once it includes a majority of approving votes.
+func TestIsVoteFinalized(t *testing.T) {
+	params := &chaincfg.SimNetParams
+	svh := uint32(params.StakeValidationHeight)
+	majority := (params.TicketsPerBlock / 2) + 1
+
+	tests := []struct {
+		name     string
+		height   uint32
+		voters   uint16
+		voteBits uint16
+		want     bool
+	}{{
+		name:   "before stake validation height",
+		height: svh - 1,
+		want:   true,
+	}, {
+		name:     "all votes approve",
+		height:   svh,
+		voters:   params.TicketsPerBlock,
+		voteBits: hcutil.BlockValid,
+		want:     true,
+	}, {
+		name:     "majority approves",
+		height:   svh + 10,
+		voters:   majority,
+		voteBits: hcutil.BlockValid,
+		want:     true,
+	}, {
+		name:     "less than majority of votes",
+		height:   svh,
+		voters:   majority - 1,
+		voteBits: hcutil.BlockValid,
+		want:     false,
+	}, {
+		name:   "block disapproved",
+		height: svh,
+		voters: params.TicketsPerBlock,
+		want:   false,
+	}}
+
+	for _, test := range tests {
+		header := &wire.BlockHeader{
+			Height:   test.height,
+			Voters:   test.voters,
+			VoteBits: test.voteBits,
+		}
+		if got := isVoteFinalized(header, params); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestHasPendingSideChain ensures side chains are only considered pending for
+// heights after their fork point which they reach.
+func TestHasPendingSideChain(t *testing.T) {
+	params := &chaincfg.SimNetParams
+	bc := newFakeChain(params)
+
+	// Create a main chain of 10 blocks after the genesis block.
+	node := bc.bestNode
+	mainChain := []*blockNode{node}
+	timestamp := time.Unix(params.GenesisBlock.Header.Timestamp.Unix(), 0)
+	for i := 0; i < 10; i++ {
+		timestamp = timestamp.Add(time.Second)
+		node = newFakeNode(node, 1, 0, 0, timestamp)
+		node.inMainChain = true
+		bc.index[node.hash] = node
+		mainChain = append(mainChain, node)
+	}
+	bc.bestNode = node
+
+	// Without side chains, no height has a pending side chain.
+	if hasPendingSideChain(bc.sideChains(), 1) {
+		t.Fatal("unexpected pending side chain without side chains")
+	}
+
+	// Fork a side chain of 3 blocks from the block at height 5.
+	node = mainChain[5]
+	var sideChain []*blockNode
+	for i := 0; i < 3; i++ {
+		// Use a different timestamp so the blocks differ from the main
+		// chain blocks.
+		node = newFakeNode(node, 1, 0, 0, timestamp.Add(time.Hour))
+		bc.index[node.hash] = node
+		sideChain = append(sideChain, node)
+	}
+
+	tests := []struct {
+		height int64
+		want   bool
+	}{
+		{height: 5, want: false}, // Fork point itself
+		{height: 6, want: true},  // First block of the side chain
+		{height: 8, want: true},  // Tip of the side chain
+		{height: 9, want: false}, // Past the tip of the side chain
+	}
+	sideChains := bc.sideChains()
+	if len(sideChains) != 1 {
+		t.Fatalf("got %d side chains, want 1", len(sideChains))
+	}
+	for _, test := range tests {
+		got := hasPendingSideChain(sideChains, test.height)
+		if got != test.want {
+			t.Errorf("height %d: got %v, want %v", test.height, got,
+				test.want)
+		}
+	}
+
+	// A side chain whose fork point was pruned from the index is always
+	// considered pending up to its tip.
+	sideChain[0].parent = nil
+	if !hasPendingSideChain(bc.sideChains(), 8) {
+		t.Error("side chain with unknown fork point is not pending")
+	}
+}
@@ -14,12 +14,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"testing"
 
 	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/chaingen"
 	"github.com/james-ray/hcd/chaincfg"
 	"github.com/james-ray/hcd/chaincfg/chainhash"
 	"github.com/james-ray/hcd/database"
 	_ "github.com/james-ray/hcd/database/ffldb"
+	"github.com/james-ray/hcd/hcutil"
 	"github.com/james-ray/hcd/txscript"
 	"github.com/james-ray/hcd/wire"
 )
@@ -58,36 +61,6 @@ func isSupportedDbType(dbType string) bool {
 	return false
 }
 
-// AcceptTipBlock processes the current tip block associated with the harness
-// generator and expects it to be accepted to the main chain.
-func (g *chaingenHarness) AcceptTipBlock() {
-	g.t.Helper()
-
-	g.AcceptBlock(g.TipName())
-}
-
-// chaingenHarness provides a test harness which encapsulates a test instance, a
-// chaingen generator instance, and a block chain instance to provide all of the
-// functionality of the aforementioned types as well as several convenience
-// functions such as block acceptance and rejection, expected tip checking, and
-// threshold state checking.
-//
-// The chaingen generator is embedded in the struct so callers can directly
-// access its method the same as if they were directly working with the
-// underlying generator.
-//
-// Since chaingen involves creating fully valid and solved blocks, which is
-// relatively expensive, only tests which actually require that functionality
-// should make use of this harness.  In many cases, a much faster synthetic
-// chain instance created by newFakeChain will suffice.
-type chaingenHarness struct {
-	*chaingen.Generator
-
-	t                  *testing.T
-	chain              *BlockChain
-	deploymentVersions map[string]uint32
-}
-
 // chainSetup is used to create a new db and chain instance with the genesis
 // block already inserted.  In addition to the new chain instance, it returns
 // a teardown function the caller should invoke when done testing to clean up.
@@ -161,6 +134,467 @@ func chainSetup(dbName string, params *chaincfg.Params) (*blockchain.BlockChain,
 	return chain, teardown, nil
 }
 
+// quickVoteActivationParams returns a set of test chain parameters which allow
+// for quicker vote activation as compared to various existing network params
+// by reducing the required rule change activation interval to two stake
+// version intervals along with a quorum to match.
+func quickVoteActivationParams() *chaincfg.Params {
+	params := cloneParams(&chaincfg.SimNetParams)
+	params.RuleChangeActivationInterval = uint32(params.StakeVersionInterval * 2)
+	params.RuleChangeActivationQuorum = params.RuleChangeActivationInterval *
+		uint32(params.TicketsPerBlock) / 10
+	return params
+}
+
+// chaingenHarness provides a test harness which encapsulates a test instance, a
+// chaingen generator instance, and a block chain instance to provide all of the
+// functionality of the aforementioned types as well as several convenience
+// functions such as block acceptance and rejection, expected tip checking, and
+// threshold state checking.
+//
+// The chaingen generator is embedded in the struct so callers can directly
+// access its method the same as if they were directly working with the
+// underlying generator.
+//
+// Since chaingen involves creating fully valid and solved blocks, which is
+// relatively expensive, only tests which actually require that functionality
+// should make use of this harness.
+type chaingenHarness struct {
+	*chaingen.Generator
+
+	t                  *testing.T
+	chain              *blockchain.BlockChain
+	deploymentVersions map[string]uint32
+}
+
+// newChaingenHarness creates and returns a new instance of a chaingen harness
+// that encapsulates the provided test instance along with a teardown function
+// the caller should invoke when done testing to clean up.
+//
+// See the documentation for the chaingenHarness type for more details.
+func newChaingenHarness(t *testing.T, params *chaincfg.Params, dbName string) (*chaingenHarness, func()) {
+	t.Helper()
+
+	// Create a test generator instance initialized with the genesis block
+	// as the tip.
+	g, err := chaingen.MakeGenerator(params)
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	// Create a new database and chain instance to run tests against.
+	chain, teardownFunc, err := chainSetup(dbName, params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+
+	harness := chaingenHarness{
+		Generator:          &g,
+		t:                  t,
+		chain:              chain,
+		deploymentVersions: make(map[string]uint32),
+	}
+	for version, deployments := range params.Deployments {
+		for _, deployment := range deployments {
+			harness.deploymentVersions[deployment.Vote.Id] = version
+		}
+	}
+	return &harness, teardownFunc
+}
+
+// AcceptBlock processes the block associated with the given name in the
+// harness generator and expects it to be accepted to the main chain.
+func (g *chaingenHarness) AcceptBlock(blockName string) {
+	g.t.Helper()
+
+	msgBlock := g.BlockByName(blockName)
+	blockHeight := msgBlock.Header.Height
+	block := hcutil.NewBlock(msgBlock)
+	g.t.Logf("Testing block %s (hash %s, height %d)", blockName,
+		block.Hash(), blockHeight)
+
+	isMainChain, isOrphan, err := g.chain.ProcessBlock(block,
+		blockchain.BFNone)
+	if err != nil {
+		g.t.Fatalf("block %q (hash %s, height %d) should have been "+
+			"accepted: %v", blockName, block.Hash(), blockHeight, err)
+	}
+
+	// Ensure the main chain and orphan flags match the values specified in
+	// the test.
+	if !isMainChain {
+		g.t.Fatalf("block %q (hash %s, height %d) unexpected main chain "+
+			"flag -- got %v, want true", blockName, block.Hash(),
+			blockHeight, isMainChain)
+	}
+	if isOrphan {
+		g.t.Fatalf("block %q (hash %s, height %d) unexpected orphan "+
+			"flag -- got %v, want false", blockName, block.Hash(),
+			blockHeight, isOrphan)
+	}
+}
+
+// AcceptTipBlock processes the current tip block associated with the harness
+// generator and expects it to be accepted to the main chain.
+func (g *chaingenHarness) AcceptTipBlock() {
+	g.t.Helper()
+
+	g.AcceptBlock(g.TipName())
+}
+
+// AcceptedToSideChainWithExpectedTip expects the current tip block associated
+// with the harness generator to be accepted to a side chain, but the current
+// best chain tip to be the provided value.
+func (g *chaingenHarness) AcceptedToSideChainWithExpectedTip(tipName string) {
+	g.t.Helper()
+
+	msgBlock := g.Tip()
+	blockHeight := msgBlock.Header.Height
+	block := hcutil.NewBlock(msgBlock)
+	g.t.Logf("Testing block %s (hash %s, height %d)", g.TipName(),
+		block.Hash(), blockHeight)
+
+	isMainChain, isOrphan, err := g.chain.ProcessBlock(block,
+		blockchain.BFNone)
+	if err != nil {
+		g.t.Fatalf("block %q (hash %s, height %d) should have been "+
+			"accepted: %v", g.TipName(), block.Hash(), blockHeight,
+			err)
+	}
+
+	// Ensure the main chain and orphan flags match the values specified in
+	// the test.
+	if isMainChain {
+		g.t.Fatalf("block %q (hash %s, height %d) unexpected main chain "+
+			"flag -- got %v, want false", g.TipName(), block.Hash(),
+			blockHeight, isMainChain)
+	}
+	if isOrphan {
+		g.t.Fatalf("block %q (hash %s, height %d) unexpected orphan "+
+			"flag -- got %v, want false", g.TipName(), block.Hash(),
+			blockHeight, isOrphan)
+	}
+
+	g.ExpectTip(tipName)
+}
+
+// RejectBlock expects the block associated with the given name in the harness
+// generator to be rejected with the provided error code.
+func (g *chaingenHarness) RejectBlock(blockName string, code blockchain.ErrorCode) {
+	g.t.Helper()
+
+	msgBlock := g.BlockByName(blockName)
+	blockHeight := msgBlock.Header.Height
+	block := hcutil.NewBlock(msgBlock)
+	g.t.Logf("Testing block %s (hash %s, height %d)", blockName,
+		block.Hash(), blockHeight)
+
+	_, _, err := g.chain.ProcessBlock(block, blockchain.BFNone)
+	if err == nil {
+		g.t.Fatalf("block %q (hash %s, height %d) should not have been "+
+			"accepted", blockName, block.Hash(), blockHeight)
+	}
+
+	// Ensure the error code is of the expected type and the reject code
+	// matches the value specified in the test instance.
+	rerr, ok := err.(blockchain.RuleError)
+	if !ok {
+		g.t.Fatalf("block %q (hash %s, height %d) returned unexpected "+
+			"error type -- got %T, want blockchain.RuleError",
+			blockName, block.Hash(), blockHeight, err)
+	}
+	if rerr.ErrorCode != code {
+		g.t.Fatalf("block %q (hash %s, height %d) does not have "+
+			"expected reject code -- got %v, want %v", blockName,
+			block.Hash(), blockHeight, rerr.ErrorCode, code)
+	}
+}
+
+// RejectTipBlock expects the current tip block associated with the harness
+// generator to be rejected with the provided error code.
+func (g *chaingenHarness) RejectTipBlock(code blockchain.ErrorCode) {
+	g.t.Helper()
+
+	g.RejectBlock(g.TipName(), code)
+}
+
+// ExpectTip expects the provided block to be the current tip of the main chain
+// associated with the harness generator.
+func (g *chaingenHarness) ExpectTip(tipName string) {
+	g.t.Helper()
+
+	// Ensure hash and height match.
+	wantTip := g.BlockByName(tipName)
+	best := g.chain.BestSnapshot()
+	if *best.Hash != wantTip.BlockHash() ||
+		best.Height != int64(wantTip.Header.Height) {
+		g.t.Fatalf("block %q (hash %s, height %d) should be the current "+
+			"tip -- got (hash %s, height %d)", tipName,
+			wantTip.BlockHash(), wantTip.Header.Height, best.Hash,
+			best.Height)
+	}
+}
+
+// TestThresholdState queries the threshold state from the current tip block
+// associated with the harness generator and expects the returned state to
+// match the provided value.
+func (g *chaingenHarness) TestThresholdState(id string, state blockchain.ThresholdState) {
+	g.t.Helper()
+
+	tipHash := g.Tip().BlockHash()
+	tipHeight := g.Tip().Header.Height
+	deploymentVer, ok := g.deploymentVersions[id]
+	if !ok {
+		g.t.Fatalf("block %q (hash %s, height %d) unable to find "+
+			"deployment version for %s", g.TipName(), tipHash,
+			tipHeight, id)
+	}
+
+	s, err := g.chain.ThresholdState(&tipHash, deploymentVer, id)
+	if err != nil {
+		g.t.Fatalf("block %q (hash %s, height %d) unexpected error when "+
+			"retrieving threshold state: %v", g.TipName(), tipHash,
+			tipHeight, err)
+	}
+
+	if s.State != state {
+		g.t.Fatalf("block %q (hash %s, height %d) unexpected threshold "+
+			"state for %s -- got %v, want %v", g.TipName(), tipHash,
+			tipHeight, id, s.State, state)
+	}
+}
+
+// AdvanceToStakeValidationHeight generates and accepts enough blocks to the
+// chain instance associated with the harness to reach stake validation height.
+//
+// The function will fail with a fatal test error if it is not called with the
+// harness at the genesis block.
+func (g *chaingenHarness) AdvanceToStakeValidationHeight() {
+	g.t.Helper()
+
+	// Only allow this to be called on a newly created harness.
+	if g.Tip().Header.Height != 0 {
+		g.t.Fatalf("chaingen harness instance must be at the genesis "+
+			"block to advance to stake validation height (current "+
+			"height %d)", g.Tip().Header.Height)
+	}
+
+	// Shorter versions of useful params for convenience.
+	params := g.Params()
+	ticketsPerBlock := params.TicketsPerBlock
+	coinbaseMaturity := params.CoinbaseMaturity
+	stakeEnabledHeight := params.StakeEnabledHeight
+	stakeValidationHeight := params.StakeValidationHeight
+
+	// Add the required premine block.
+	//
+	//   genesis -> bp
+	g.CreatePremineBlock("bp", 0)
+	g.AssertTipHeight(1)
+	g.AcceptTipBlock()
+
+	// Generate enough blocks to have mature coinbase outputs to work with.
+	//
+	//   genesis -> bp -> bm0 -> bm1 -> ... -> bm#
+	for i := uint16(0); i < coinbaseMaturity; i++ {
+		blockName := fmt.Sprintf("bm%d", i)
+		g.NextBlock(blockName, nil, nil)
+		g.SaveTipCoinbaseOuts()
+		g.AcceptTipBlock()
+	}
+	g.AssertTipHeight(uint32(coinbaseMaturity) + 1)
+
+	// Generate enough blocks to reach the stake enabled height while
+	// creating ticket purchases that spend from the coinbases matured above.
+	// This will also populate the pool of immature tickets.
+	//
+	//   ... -> bm# ... -> bse0 -> bse1 -> ... -> bse#
+	var ticketsPurchased int
+	for i := int64(0); int64(g.Tip().Header.Height) < stakeEnabledHeight; i++ {
+		outs := g.OldestCoinbaseOuts()
+		ticketOuts := outs[1:]
+		ticketsPurchased += len(ticketOuts)
+		blockName := fmt.Sprintf("bse%d", i)
+		g.NextBlock(blockName, nil, ticketOuts)
+		g.SaveTipCoinbaseOuts()
+		g.AcceptTipBlock()
+	}
+	g.AssertTipHeight(uint32(stakeEnabledHeight))
+
+	// Generate enough blocks to reach the stake validation height while
+	// continuing to purchase tickets using the coinbases matured above and
+	// allowing the immature tickets to mature and thus become live.
+	//
+	//   ... -> bse# -> bsv0 -> bsv1 -> ... -> bsv#
+	targetPoolSize := params.TicketPoolSize * ticketsPerBlock
+	for i := int64(0); int64(g.Tip().Header.Height) < stakeValidationHeight; i++ {
+		// Only purchase tickets until the target ticket pool size is
+		// reached.
+		outs := g.OldestCoinbaseOuts()
+		ticketOuts := outs[1:]
+		if ticketsPurchased+len(ticketOuts) > int(targetPoolSize) {
+			ticketsNeeded := int(targetPoolSize) - ticketsPurchased
+			if ticketsNeeded > 0 {
+				ticketOuts = ticketOuts[1 : ticketsNeeded+1]
+			} else {
+				ticketOuts = nil
+			}
+		}
+		ticketsPurchased += len(ticketOuts)
+
+		blockName := fmt.Sprintf("bsv%d", i)
+		g.NextBlock(blockName, nil, ticketOuts)
+		g.SaveTipCoinbaseOuts()
+		g.AcceptTipBlock()
+	}
+	g.AssertTipHeight(uint32(stakeValidationHeight))
+}
+
+// AdvanceFromSVHToActiveAgenda generates and accepts enough blocks with the
+// appropriate vote bits set to reach one block prior to the specified agenda
+// becoming active.
+//
+// The function will fail with a fatal test error if it is called when the
+// harness is not within the first stake version interval that starts at stake
+// validation height.
+//
+// WARNING: This function currently assumes the chain parameters were created
+// via the quickVoteActivationParams.  It should be updated in the future to
+// work with arbitrary params.
+func (g *chaingenHarness) AdvanceFromSVHToActiveAgenda(voteID string) {
+	g.t.Helper()
+
+	// Find the correct deployment for the provided ID along with the yes
+	// vote choice within it.
+	params := g.Params()
+	deploymentVer, ok := g.deploymentVersions[voteID]
+	if !ok {
+		g.t.Fatalf("unable to find deployment version for %s", voteID)
+	}
+	var deployment *chaincfg.ConsensusDeployment
+	deployments := params.Deployments[deploymentVer]
+	for deploymentID, depl := range deployments {
+		if depl.Vote.Id == voteID {
+			deployment = &deployments[deploymentID]
+			break
+		}
+	}
+	if deployment == nil {
+		g.t.Fatalf("Unable to find consensus deployment for %s", voteID)
+	}
+	var yesChoice *chaincfg.Choice
+	for _, choice := range deployment.Vote.Choices {
+		if !choice.IsAbstain && !choice.IsNo {
+			yesChoice = &choice
+			break
+		}
+	}
+	if yesChoice == nil {
+		g.t.Fatalf("Unable to find vote choice for id %q", voteID)
+	}
+
+	// Shorter versions of useful params for convenience.
+	stakeValidationHeight := params.StakeValidationHeight
+	stakeVerInterval := params.StakeVersionInterval
+	ruleChangeInterval := int64(params.RuleChangeActivationInterval)
+
+	// Only allow this to be called on a harness within the first stake
+	// version interval starting at SVH since the stake version is upgraded
+	// by the votes in the following interval.
+	tipHeight := int64(g.Tip().Header.Height)
+	if tipHeight < stakeValidationHeight ||
+		tipHeight >= stakeValidationHeight+stakeVerInterval-1 {
+
+		g.t.Fatalf("chaingen harness instance must be within the first "+
+			"stake version interval after stake validation height to "+
+			"advance to an active agenda (current height %d)",
+			tipHeight)
+	}
+
+	// ---------------------------------------------------------------------
+	// Generate enough blocks to reach one block before the next two stake
+	// version intervals with block and vote versions for the agenda and
+	// stake version 0.
+	//
+	// This will result in triggering enforcement of the stake version and
+	// that the stake version is the deployment version.  The threshold
+	// state for deployment will move to started since the next block also
+	// coincides with the start of a new rule change activation interval
+	// for the chosen parameters.
+	//
+	//   ... -> bsv# -> bvu0 -> bvu1 -> ... -> bvu#
+	// ---------------------------------------------------------------------
+
+	blocksNeeded := stakeValidationHeight + stakeVerInterval*2 - 1 -
+		int64(g.Tip().Header.Height)
+	for i := int64(0); i < blocksNeeded; i++ {
+		outs := g.OldestCoinbaseOuts()
+		blockName := fmt.Sprintf("bvu%d", i)
+		g.NextBlock(blockName, nil, outs[1:],
+			chaingen.ReplaceBlockVersion(int32(deploymentVer)),
+			chaingen.ReplaceVoteVersions(deploymentVer))
+		g.SaveTipCoinbaseOuts()
+		g.AcceptTipBlock()
+	}
+	g.TestThresholdState(voteID, blockchain.ThresholdStarted)
+
+	// ---------------------------------------------------------------------
+	// Generate enough blocks to reach the next rule change interval with
+	// block, stake, and vote versions for the agenda.  Also, set the vote
+	// bits to include yes votes for the agenda.
+	//
+	// This will result in moving the threshold state for the agenda to
+	// locked in.
+	//
+	//   ... -> bvu# -> bvli0 -> bvli1 -> ... -> bvli#
+	// ---------------------------------------------------------------------
+
+	blocksNeeded = stakeValidationHeight + ruleChangeInterval*2 - 1 -
+		int64(g.Tip().Header.Height)
+	for i := int64(0); i < blocksNeeded; i++ {
+		outs := g.OldestCoinbaseOuts()
+		blockName := fmt.Sprintf("bvli%d", i)
+		g.NextBlock(blockName, nil, outs[1:],
+			chaingen.ReplaceBlockVersion(int32(deploymentVer)),
+			chaingen.ReplaceStakeVersion(deploymentVer),
+			chaingen.ReplaceVotes(hcutil.BlockValid|yesChoice.Bits,
+				deploymentVer))
+		g.SaveTipCoinbaseOuts()
+		g.AcceptTipBlock()
+	}
+	g.AssertTipHeight(uint32(stakeValidationHeight + ruleChangeInterval*2 - 1))
+	g.AssertBlockVersion(int32(deploymentVer))
+	g.AssertStakeVersion(deploymentVer)
+	g.TestThresholdState(voteID, blockchain.ThresholdLockedIn)
+
+	// ---------------------------------------------------------------------
+	// Generate enough blocks to reach the next rule change interval with
+	// block, stake, and vote versions for the agenda.
+	//
+	// This will result in moving the threshold state for the agenda to
+	// active thereby activating it.
+	//
+	//   ... -> bvli# -> bva0 -> bva1 -> ... -> bva#
+	// ---------------------------------------------------------------------
+
+	blocksNeeded = stakeValidationHeight + ruleChangeInterval*3 - 1 -
+		int64(g.Tip().Header.Height)
+	for i := int64(0); i < blocksNeeded; i++ {
+		outs := g.OldestCoinbaseOuts()
+		blockName := fmt.Sprintf("bva%d", i)
+		g.NextBlock(blockName, nil, outs[1:],
+			chaingen.ReplaceBlockVersion(int32(deploymentVer)),
+			chaingen.ReplaceStakeVersion(deploymentVer),
+			chaingen.ReplaceVoteVersions(deploymentVer))
+		g.SaveTipCoinbaseOuts()
+		g.AcceptTipBlock()
+	}
+	g.AssertTipHeight(uint32(stakeValidationHeight + ruleChangeInterval*3 - 1))
+	g.AssertBlockVersion(int32(deploymentVer))
+	g.AssertStakeVersion(deploymentVer)
+	g.TestThresholdState(voteID, blockchain.ThresholdActive)
+}
+
 // loadUtxoView returns a utxo view loaded from a file.
 func loadUtxoView(filename string) (*blockchain.UtxoViewpoint, error) {
 	// The utxostore file format is:
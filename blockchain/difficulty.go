@@ -655,6 +655,18 @@ func calcNextStakeDiffV2(params *chaincfg.Params, nextHeight, curDiff, prevPoolS
 	return nextDiff
 }
 
+// CalcNextStakeDiffFromPoolSizes calculates the next stake difficulty using the
+// algorithm defined in DCP0001 from the current difficulty along with the pool
+// sizes, including immature tickets, at the previous and current retarget
+// intervals.  It allows the stake difficulty to be calculated without a chain
+// instance, such as when simulating the effect of parameter changes.
+//
+// This function is safe for concurrent access.
+func CalcNextStakeDiffFromPoolSizes(params *chaincfg.Params, nextHeight, curDiff, prevPoolSizeAll, curPoolSizeAll int64) int64 {
+	return calcNextStakeDiffV2(params, nextHeight, curDiff, prevPoolSizeAll,
+		curPoolSizeAll)
+}
+
 // calcNextRequiredStakeDifficultyV2 calculates the required stake difficulty
 // for the block after the passed previous block node based on the algorithm
 // defined in DCP0001.
@@ -456,6 +456,19 @@ const (
 
 	// ErrCheckExtraData indicates that a block header value between 144-148 not matches the condition
 	ErrCheckExtraData
+
+	// ErrMissingRevocation indicates that a block did not revoke a ticket
+	// that was missed or expired in its parent while the automatic ticket
+	// revocations agenda was active.
+	ErrMissingRevocation
+
+	// ErrInvalidAutoRevocation indicates that an unsigned revocation did
+	// not pay the exact ticket commitments without any fee.
+	ErrInvalidAutoRevocation
+
+	// ErrKnownInvalidBlock indicates that a block was marked invalid with
+	// InvalidateBlock or builds on a block which was.
+	ErrKnownInvalidBlock
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -557,6 +570,9 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrZeroValueOutputSpend:   "ErrZeroValueOutputSpend",
 	ErrInvalidEarlyVoteBits:   "ErrInvalidEarlyVoteBits",
 	ErrCheckExtraData:   "ErrCheckExtraData",
+	ErrMissingRevocation:      "ErrMissingRevocation",
+	ErrInvalidAutoRevocation:  "ErrInvalidAutoRevocation",
+	ErrKnownInvalidBlock:      "ErrKnownInvalidBlock",
 }
 
 // String returns the ErrorCode as a human-readable name.
@@ -0,0 +1,621 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/stake"
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/txscript"
+)
+
+const (
+	// ticketIndexName is the human-readable name for the index.
+	ticketIndexName = "ticket index"
+
+	// ticketKeyPrefix, ticketAddrKeyPrefix and ticketMissedKeyPrefix
+	// prefix the keys of the three kinds of entries which share the index
+	// bucket.
+	ticketKeyPrefix       = 't'
+	ticketAddrKeyPrefix   = 'a'
+	ticketMissedKeyPrefix = 'm'
+
+	// ticketEntrySize is the number of bytes a serialized ticket entry
+	// consumes.
+	ticketEntrySize = chainhash.HashSize + 4 + 8 + 1 + 1 +
+		chainhash.HashSize + 4 + chainhash.HashSize + 4 +
+		chainhash.HashSize + 8
+
+	// ticketAddrKeySize is the number of bytes an address entry key
+	// consumes.
+	ticketAddrKeySize = 1 + addrKeySize + 4 + chainhash.HashSize
+)
+
+var (
+	// ticketIndexKey is the key of the ticket index and the db bucket used
+	// to house it.
+	ticketIndexKey = []byte("ticketidx")
+)
+
+// TicketStatus describes the state of a ticket in its lifecycle.
+type TicketStatus byte
+
+// These constants define the possible states of a ticket.  A ticket which is
+// neither spent, missed nor expired is reported as TicketLive even while it is
+// still immature, since maturity only depends on the current height and is
+// derived from TicketInfo.MaturityHeight.
+const (
+	TicketLive TicketStatus = iota
+	TicketVoted
+	TicketMissed
+	TicketExpired
+	TicketRevoked
+)
+
+// ticketStatusStrings is a map of ticket states back to their constant names
+// for pretty printing.
+var ticketStatusStrings = map[TicketStatus]string{
+	TicketLive:    "live",
+	TicketVoted:   "voted",
+	TicketMissed:  "missed",
+	TicketExpired: "expired",
+	TicketRevoked: "revoked",
+}
+
+// String returns the TicketStatus in human-readable form.
+func (s TicketStatus) String() string {
+	if str, ok := ticketStatusStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown TicketStatus (%d)", byte(s))
+}
+
+// TicketInfo describes the lifecycle of a ticket as recorded by the ticket
+// index.
+type TicketInfo struct {
+	// Hash is the hash of the ticket purchase (SStx) transaction.
+	Hash chainhash.Hash
+
+	// PurchaseBlock and PurchaseHeight identify the block which mined the
+	// ticket purchase and Price is the amount locked by the ticket.
+	PurchaseBlock  chainhash.Hash
+	PurchaseHeight uint32
+	Price          int64
+
+	// MaturityHeight is the height at which the ticket becomes live and
+	// ExpiryHeight is the height at which it expires unless called to vote
+	// before.
+	MaturityHeight uint32
+	ExpiryHeight   uint32
+
+	// Status is the current state of the ticket.  Expired is set for
+	// tickets which expired, so that a revoked ticket can be told apart
+	// from one that was missed.
+	Status  TicketStatus
+	Expired bool
+
+	// MissedBlock and MissedHeight identify the block which missed or
+	// expired the ticket.  They are only set for missed, expired and
+	// revoked tickets.
+	MissedBlock  chainhash.Hash
+	MissedHeight uint32
+
+	// SpendBlock and SpendHeight identify the block which included the vote
+	// (SSGen) or revocation (SSRtx) transaction SpendTx.  Reward is the
+	// amount paid out by that transaction minus the ticket price.  They
+	// are only set for voted and revoked tickets.
+	SpendBlock  chainhash.Hash
+	SpendHeight uint32
+	SpendTx     chainhash.Hash
+	Reward      int64
+}
+
+// -----------------------------------------------------------------------------
+// The ticket index consists of three kinds of entries which are all stored in
+// the same bucket and told apart by the first byte of their key.
+//
+// The ticket entries map the hash of each ticket purchase to the serialized
+// lifecycle of the ticket:
+//
+//   <'t'><ticket hash> = <purchase block hash><purchase height><price>
+//                        <status><expired><missed block hash><missed height>
+//                        <spend block hash><spend height><spend tx hash>
+//                        <reward>
+//
+//   Field               Type             Size
+//   ticket hash         chainhash.Hash   32
+//   purchase block hash chainhash.Hash   32
+//   purchase height     uint32           4
+//   price               int64            8
+//   status              byte             1
+//   expired             bool             1
+//   missed block hash   chainhash.Hash   32
+//   missed height       uint32           4
+//   spend block hash    chainhash.Hash   32
+//   spend height        uint32           4
+//   spend tx hash       chainhash.Hash   32
+//   reward              int64            8
+//
+// The address entries have empty values and link the commitment addresses of
+// each ticket to the ticket.  The purchase height is stored big endian so that
+// iterating the entries of an address yields its tickets ordered by height:
+//
+//   <'a'><address key><purchase height><ticket hash> = <>
+//
+// The missed entries record the tickets which each block missed or expired, so
+// that they can be restored when the block is disconnected.  Unlike votes and
+// revocations, missed and expired tickets can't be determined from the block
+// itself:
+//
+//   <'m'><height> = <ticket hash>...
+// -----------------------------------------------------------------------------
+
+// serializeTicketEntry returns the passed ticket lifecycle serialized
+// according to the format described above.
+func serializeTicketEntry(info *TicketInfo) []byte {
+	serialized := make([]byte, ticketEntrySize)
+	offset := copy(serialized, info.PurchaseBlock[:])
+	byteOrder.PutUint32(serialized[offset:], info.PurchaseHeight)
+	offset += 4
+	byteOrder.PutUint64(serialized[offset:], uint64(info.Price))
+	offset += 8
+	serialized[offset] = byte(info.Status)
+	offset++
+	if info.Expired {
+		serialized[offset] = 1
+	}
+	offset++
+	offset += copy(serialized[offset:], info.MissedBlock[:])
+	byteOrder.PutUint32(serialized[offset:], info.MissedHeight)
+	offset += 4
+	offset += copy(serialized[offset:], info.SpendBlock[:])
+	byteOrder.PutUint32(serialized[offset:], info.SpendHeight)
+	offset += 4
+	offset += copy(serialized[offset:], info.SpendTx[:])
+	byteOrder.PutUint64(serialized[offset:], uint64(info.Reward))
+	return serialized
+}
+
+// deserializeTicketEntry decodes the passed serialized ticket entry of the
+// passed ticket.  The maturity and expiry heights are derived from the purchase
+// height and the passed network parameters.
+func deserializeTicketEntry(hash *chainhash.Hash, serialized []byte, params *chaincfg.Params) (*TicketInfo, error) {
+	if len(serialized) != ticketEntrySize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected ticket entry "+
+			"size %d for ticket %v", len(serialized), hash))
+	}
+
+	info := &TicketInfo{Hash: *hash}
+	offset := copy(info.PurchaseBlock[:], serialized)
+	info.PurchaseHeight = byteOrder.Uint32(serialized[offset:])
+	offset += 4
+	info.Price = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	info.Status = TicketStatus(serialized[offset])
+	offset++
+	info.Expired = serialized[offset] != 0
+	offset++
+	offset += copy(info.MissedBlock[:], serialized[offset:])
+	info.MissedHeight = byteOrder.Uint32(serialized[offset:])
+	offset += 4
+	offset += copy(info.SpendBlock[:], serialized[offset:])
+	info.SpendHeight = byteOrder.Uint32(serialized[offset:])
+	offset += 4
+	offset += copy(info.SpendTx[:], serialized[offset:])
+	info.Reward = int64(byteOrder.Uint64(serialized[offset:]))
+
+	info.MaturityHeight = info.PurchaseHeight + uint32(params.TicketMaturity)
+	info.ExpiryHeight = info.MaturityHeight + params.TicketExpiry
+	return info, nil
+}
+
+// ticketEntryKey returns the key of the ticket entry for the passed ticket.
+func ticketEntryKey(hash *chainhash.Hash) []byte {
+	key := make([]byte, 1+chainhash.HashSize)
+	key[0] = ticketKeyPrefix
+	copy(key[1:], hash[:])
+	return key
+}
+
+// ticketAddrEntryKey returns the key of the address entry which links the
+// passed address key to the passed ticket.
+func ticketAddrEntryKey(addrKey [addrKeySize]byte, height uint32, hash *chainhash.Hash) []byte {
+	key := make([]byte, ticketAddrKeySize)
+	key[0] = ticketAddrKeyPrefix
+	offset := 1 + copy(key[1:], addrKey[:])
+	binary.BigEndian.PutUint32(key[offset:], height)
+	copy(key[offset+4:], hash[:])
+	return key
+}
+
+// ticketMissedEntryKey returns the key of the missed entry for the block at
+// the passed height.
+func ticketMissedEntryKey(height uint32) []byte {
+	key := make([]byte, 1+4)
+	key[0] = ticketMissedKeyPrefix
+	binary.BigEndian.PutUint32(key[1:], height)
+	return key
+}
+
+// dbFetchTicketEntry fetches the ticket entry of the passed ticket.  When there
+// is no entry for the provided hash, nil will be returned for both the entry
+// and the error.
+func dbFetchTicketEntry(bucket database.Bucket, hash *chainhash.Hash, params *chaincfg.Params) (*TicketInfo, error) {
+	serialized := bucket.Get(ticketEntryKey(hash))
+	if serialized == nil {
+		return nil, nil
+	}
+	return deserializeTicketEntry(hash, serialized, params)
+}
+
+// dbFetchExistingTicketEntry fetches the ticket entry of the passed ticket and
+// returns an assertion error when it does not exist since every ticket which
+// is spent, missed or revoked by a block must have been purchased before.
+func dbFetchExistingTicketEntry(bucket database.Bucket, hash *chainhash.Hash, params *chaincfg.Params) (*TicketInfo, error) {
+	info, err := dbFetchTicketEntry(bucket, hash, params)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, AssertError(fmt.Sprintf("missing ticket index entry "+
+			"for ticket %v", hash))
+	}
+	return info, nil
+}
+
+// dbPutTicketEntry stores the passed ticket entry.
+func dbPutTicketEntry(bucket database.Bucket, info *TicketInfo) error {
+	return bucket.Put(ticketEntryKey(&info.Hash), serializeTicketEntry(info))
+}
+
+// TicketIndex implements a ticket lifecycle index.  It records, for every
+// ticket purchase, the block which mined it, the price it locked, and the
+// block which voted, missed, expired or revoked it along with the vote or
+// revocation transaction and its reward.  It also links the commitment
+// addresses of each ticket to the ticket so that the tickets of an address
+// can be queried over a range of purchase heights.
+type TicketIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+}
+
+// Ensure the TicketIndex type implements the Indexer interface.
+var _ Indexer = (*TicketIndex)(nil)
+
+// NewTicketIndex returns a new instance of an indexer that is used to create a
+// mapping of all ticket purchases to their lifecycle.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewTicketIndex(db database.DB, chainParams *chaincfg.Params) *TicketIndex {
+	return &TicketIndex{
+		db:          db,
+		chainParams: chainParams,
+	}
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *TicketIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *TicketIndex) Key() []byte {
+	return ticketIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *TicketIndex) Name() string {
+	return ticketIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the ticket
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *TicketIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(ticketIndexKey)
+	return err
+}
+
+// commitmentAddrKeys returns the unique address keys of the commitment
+// addresses of the passed ticket purchase.  Unsupported address types are
+// skipped.
+func (idx *TicketIndex) commitmentAddrKeys(tx *hcutil.Tx) [][addrKeySize]byte {
+	var keys [][addrKeySize]byte
+	seen := make(map[[addrKeySize]byte]struct{})
+	for _, txOut := range tx.MsgTx().TxOut {
+		if txscript.GetScriptClass(txOut.Version, txOut.PkScript) !=
+			txscript.NullDataTy {
+			continue
+		}
+		addr, err := stake.AddrFromSStxPkScrCommitment(txOut.PkScript,
+			idx.chainParams)
+		if err != nil {
+			continue
+		}
+		k, err := addrToKey(addr, idx.chainParams)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// spentTicket returns the ticket spent by the passed vote or revocation and
+// whether the transaction is a vote.  The returned hash is nil for all other
+// transactions.
+func spentTicket(tx *hcutil.Tx) (*chainhash.Hash, bool) {
+	msgTx := tx.MsgTx()
+	switch stake.DetermineTxType(msgTx) {
+	case stake.TxTypeSSGen:
+		return &msgTx.TxIn[1].PreviousOutPoint.Hash, true
+	case stake.TxTypeSSRtx:
+		return &msgTx.TxIn[0].PreviousOutPoint.Hash, false
+	}
+	return nil, false
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every ticket
+// purchased in the block and records the votes and revocations in the block as
+// well as the tickets the block missed or expired.
+//
+// This is part of the Indexer interface.
+func (idx *TicketIndex) ConnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(ticketIndexKey)
+	height := uint32(block.Height())
+
+	for _, stx := range block.STransactions() {
+		msgTx := stx.MsgTx()
+		if stake.DetermineTxType(msgTx) == stake.TxTypeSStx {
+			info := &TicketInfo{
+				Hash:           *stx.Hash(),
+				PurchaseBlock:  *block.Hash(),
+				PurchaseHeight: height,
+				Price:          msgTx.TxOut[0].Value,
+				Status:         TicketLive,
+			}
+			if err := dbPutTicketEntry(bucket, info); err != nil {
+				return err
+			}
+			for _, k := range idx.commitmentAddrKeys(stx) {
+				key := ticketAddrEntryKey(k, height, stx.Hash())
+				if err := bucket.Put(key, nil); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		ticket, isVote := spentTicket(stx)
+		if ticket == nil {
+			continue
+		}
+		info, err := dbFetchExistingTicketEntry(bucket, ticket,
+			idx.chainParams)
+		if err != nil {
+			return err
+		}
+		var paid int64
+		for _, txOut := range msgTx.TxOut {
+			paid += txOut.Value
+		}
+		info.Status = TicketRevoked
+		if isVote {
+			info.Status = TicketVoted
+		}
+		info.SpendBlock = *block.Hash()
+		info.SpendHeight = height
+		info.SpendTx = *stx.Hash()
+		info.Reward = paid - info.Price
+		if err := dbPutTicketEntry(bucket, info); err != nil {
+			return err
+		}
+	}
+
+	// The tickets missed and expired by the block are not visible in the
+	// block itself, so load them from the undo data the stake database
+	// stored for the block before the indexes are notified.
+	undoData, err := stake.FetchUndoData(dbTx, height)
+	if err != nil {
+		return err
+	}
+	var missed []byte
+	for _, undo := range undoData {
+		if !undo.Missed || undo.Revoked || undo.Spent {
+			continue
+		}
+		info, err := dbFetchExistingTicketEntry(bucket, &undo.TicketHash,
+			idx.chainParams)
+		if err != nil {
+			return err
+		}
+		info.Status = TicketMissed
+		if undo.Expired {
+			info.Status = TicketExpired
+			info.Expired = true
+		}
+		info.MissedBlock = *block.Hash()
+		info.MissedHeight = height
+		if err := dbPutTicketEntry(bucket, info); err != nil {
+			return err
+		}
+		missed = append(missed, undo.TicketHash[:]...)
+	}
+	if len(missed) == 0 {
+		return nil
+	}
+	return bucket.Put(ticketMissedEntryKey(height), missed)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer reverts every change made
+// when the block was connected in reverse order.
+//
+// This is part of the Indexer interface.
+func (idx *TicketIndex) DisconnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(ticketIndexKey)
+	height := uint32(block.Height())
+
+	// Restore the tickets missed and expired by the block to live.
+	missedKey := ticketMissedEntryKey(height)
+	missed := bucket.Get(missedKey)
+	if len(missed)%chainhash.HashSize != 0 {
+		return errDeserialize(fmt.Sprintf("unexpected missed ticket "+
+			"entry size %d at height %d", len(missed), height))
+	}
+	for offset := 0; offset < len(missed); offset += chainhash.HashSize {
+		var hash chainhash.Hash
+		copy(hash[:], missed[offset:])
+		info, err := dbFetchExistingTicketEntry(bucket, &hash,
+			idx.chainParams)
+		if err != nil {
+			return err
+		}
+		info.Status = TicketLive
+		info.Expired = false
+		info.MissedBlock = chainhash.Hash{}
+		info.MissedHeight = 0
+		if err := dbPutTicketEntry(bucket, info); err != nil {
+			return err
+		}
+	}
+	if missed != nil {
+		if err := bucket.Delete(missedKey); err != nil {
+			return err
+		}
+	}
+
+	stxns := block.STransactions()
+	for i := len(stxns) - 1; i >= 0; i-- {
+		stx := stxns[i]
+		if stake.DetermineTxType(stx.MsgTx()) == stake.TxTypeSStx {
+			for _, k := range idx.commitmentAddrKeys(stx) {
+				key := ticketAddrEntryKey(k, height, stx.Hash())
+				if err := bucket.Delete(key); err != nil {
+					return err
+				}
+			}
+			err := bucket.Delete(ticketEntryKey(stx.Hash()))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		ticket, isVote := spentTicket(stx)
+		if ticket == nil {
+			continue
+		}
+		info, err := dbFetchExistingTicketEntry(bucket, ticket,
+			idx.chainParams)
+		if err != nil {
+			return err
+		}
+		switch {
+		case isVote:
+			info.Status = TicketLive
+		case info.Expired:
+			info.Status = TicketExpired
+		default:
+			info.Status = TicketMissed
+		}
+		info.SpendBlock = chainhash.Hash{}
+		info.SpendHeight = 0
+		info.SpendTx = chainhash.Hash{}
+		info.Reward = 0
+		if err := dbPutTicketEntry(bucket, info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TicketInfo returns the lifecycle of the passed ticket.  When there is no
+// entry for the provided hash, nil will be returned for both the entry and the
+// error.
+//
+// This function is safe for concurrent access.
+func (idx *TicketIndex) TicketInfo(hash *chainhash.Hash) (*TicketInfo, error) {
+	var info *TicketInfo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(ticketIndexKey)
+		var err error
+		info, err = dbFetchTicketEntry(bucket, hash, idx.chainParams)
+		return err
+	})
+	return info, err
+}
+
+// TicketsForAddress returns the lifecycles of the tickets which commit to the
+// passed address and were purchased at heights from startHeight to endHeight
+// inclusive, ordered by purchase height.
+//
+// This function is safe for concurrent access.
+func (idx *TicketIndex) TicketsForAddress(addr hcutil.Address, startHeight, endHeight uint32) ([]*TicketInfo, error) {
+	addrKey, err := addrToKey(addr, idx.chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var tickets []*TicketInfo
+	err = idx.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(ticketIndexKey)
+		seek := ticketAddrEntryKey(addrKey, startHeight, &chainhash.Hash{})
+		prefixLen := 1 + addrKeySize
+		cursor := bucket.Cursor()
+		for ok := cursor.Seek(seek); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if len(key) != ticketAddrKeySize ||
+				string(key[:prefixLen]) != string(seek[:prefixLen]) {
+				break
+			}
+			height := binary.BigEndian.Uint32(key[prefixLen:])
+			if height > endHeight {
+				break
+			}
+
+			var hash chainhash.Hash
+			copy(hash[:], key[prefixLen+4:])
+			info, err := dbFetchExistingTicketEntry(bucket, &hash,
+				idx.chainParams)
+			if err != nil {
+				return err
+			}
+			tickets = append(tickets, info)
+		}
+		return nil
+	})
+	return tickets, err
+}
+
+// DropTicketIndex drops the ticket index from the provided database if it
+// exists.
+func DropTicketIndex(db database.DB) error {
+	return dropIndex(db, ticketIndexKey, ticketIndexName)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+)
+
+// TestTicketEntrySerialization ensures ticket entries round trip through
+// serialization and that the derived heights are computed from the network
+// parameters.
+func TestTicketEntrySerialization(t *testing.T) {
+	params := &chaincfg.RegNetParams
+	info := &TicketInfo{
+		Hash:           chainhash.Hash{0x01},
+		PurchaseBlock:  chainhash.Hash{0x02},
+		PurchaseHeight: 100,
+		Price:          2000000000,
+		Status:         TicketRevoked,
+		Expired:        true,
+		MissedBlock:    chainhash.Hash{0x03},
+		MissedHeight:   160,
+		SpendBlock:     chainhash.Hash{0x04},
+		SpendHeight:    170,
+		SpendTx:        chainhash.Hash{0x05},
+		Reward:         -10000,
+	}
+
+	serialized := serializeTicketEntry(info)
+	if len(serialized) != ticketEntrySize {
+		t.Fatalf("serializeTicketEntry: unexpected size - got %d, want %d",
+			len(serialized), ticketEntrySize)
+	}
+
+	got, err := deserializeTicketEntry(&info.Hash, serialized, params)
+	if err != nil {
+		t.Fatalf("deserializeTicketEntry: unexpected error: %v", err)
+	}
+	info.MaturityHeight = 100 + uint32(params.TicketMaturity)
+	info.ExpiryHeight = info.MaturityHeight + params.TicketExpiry
+	if !reflect.DeepEqual(got, info) {
+		t.Fatalf("deserializeTicketEntry: mismatched entry - got %+v, "+
+			"want %+v", got, info)
+	}
+
+	_, err = deserializeTicketEntry(&info.Hash, serialized[1:], params)
+	if !isDeserializeErr(err) {
+		t.Fatalf("deserializeTicketEntry: unexpected error for short "+
+			"entry: %v", err)
+	}
+}
+
+// TestTicketAddrEntryKeyOrder ensures the address entry keys of an address
+// sort by purchase height so that range queries can iterate them in order.
+func TestTicketAddrEntryKeyOrder(t *testing.T) {
+	var addrKey [addrKeySize]byte
+	addrKey[0] = addrKeyTypePubKeyHash
+	low := ticketAddrEntryKey(addrKey, 255, &chainhash.Hash{0xff})
+	high := ticketAddrEntryKey(addrKey, 256, &chainhash.Hash{0x00})
+	if bytes.Compare(low, high) >= 0 {
+		t.Fatalf("ticketAddrEntryKey: key for height 255 (%x) does not "+
+			"sort before key for height 256 (%x)", low, high)
+	}
+	if got := binary.BigEndian.Uint32(high[1+addrKeySize:]); got != 256 {
+		t.Fatalf("ticketAddrEntryKey: unexpected height %d", got)
+	}
+}
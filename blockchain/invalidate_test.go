@@ -0,0 +1,131 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/wire"
+)
+
+// TestBestValidTip ensures the best valid tip only considers side chains which
+// fork at or before the base node, have more work, and have all of their blocks
+// cached and not marked invalid.
+func TestBestValidTip(t *testing.T) {
+	params := &chaincfg.SimNetParams
+	bc := newFakeChain(params)
+	bc.blockCache = make(map[chainhash.Hash]*hcutil.Block)
+	bc.invalidBlocks = make(map[chainhash.Hash]struct{})
+
+	// extend creates the passed number of blocks on top of the passed node
+	// with the given timestamp offset so the blocks of different chains
+	// differ, adds them to the index, and returns them.
+	timestamp := time.Unix(params.GenesisBlock.Header.Timestamp.Unix(), 0)
+	extend := func(node *blockNode, numBlocks int, offset time.Duration) []*blockNode {
+		var nodes []*blockNode
+		for i := 0; i < numBlocks; i++ {
+			ts := timestamp.Add(time.Duration(node.height+1)*time.Second + offset)
+			node = newFakeNode(node, 1, 0, params.PowLimitBits, ts)
+			bc.index[node.hash] = node
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+	cache := func(nodes ...*blockNode) {
+		for _, node := range nodes {
+			bc.blockCache[node.hash] = hcutil.NewBlock(&wire.MsgBlock{
+				Header: node.header,
+			})
+		}
+	}
+
+	// Create a main chain of 5 blocks after the genesis block, a side chain
+	// of 5 blocks forking from the block at height 2, and a side chain of 5
+	// blocks forking from the block at height 4 so it has the most work.
+	//
+	//   genesis -> m1 -> m2 -> m3 -> m4 -> m5
+	//                     |           \-> b5 -> b6 -> b7 -> b8 -> b9
+	//                     \-> a3 -> a4 -> a5 -> a6 -> a7
+	mainChain := append([]*blockNode{bc.bestNode},
+		extend(bc.bestNode, 5, 0)...)
+	for _, node := range mainChain {
+		node.inMainChain = true
+	}
+	bc.bestNode = mainChain[5]
+	sideA := extend(mainChain[2], 5, time.Hour)
+	sideB := extend(mainChain[4], 5, 2*time.Hour)
+	cache(sideA...)
+	cache(sideB...)
+
+	tests := []struct {
+		name    string
+		base    *blockNode
+		setup   func()
+		want    *blockNode
+		wantStr string
+	}{{
+		name:    "side chain with most work",
+		base:    mainChain[5],
+		want:    sideB[4],
+		wantStr: "b9",
+	}, {
+		name:    "side chain forking after base is ignored",
+		base:    mainChain[3],
+		want:    sideA[4],
+		wantStr: "a7",
+	}, {
+		name: "tip not in block cache",
+		base: mainChain[5],
+		setup: func() {
+			delete(bc.blockCache, sideB[4].hash)
+		},
+		want:    sideB[3],
+		wantStr: "b8",
+	}, {
+		name: "ancestor not in block cache",
+		base: mainChain[5],
+		setup: func() {
+			delete(bc.blockCache, sideB[0].hash)
+		},
+		want:    sideA[4],
+		wantStr: "a7",
+	}, {
+		name: "invalid ancestor",
+		base: mainChain[5],
+		setup: func() {
+			bc.invalidBlocks[sideA[1].hash] = struct{}{}
+		},
+		want:    mainChain[5],
+		wantStr: "m5",
+	}, {
+		name: "no side chain with more work",
+		base: mainChain[5],
+		setup: func() {
+			delete(bc.invalidBlocks, sideA[1].hash)
+			delete(bc.blockCache, sideA[4].hash)
+			delete(bc.blockCache, sideA[3].hash)
+		},
+		want:    mainChain[5],
+		wantStr: "m5",
+	}}
+
+	// NOTE: The setup functions of the tests build on each other, so they
+	// must be run in order.
+	for _, test := range tests {
+		if test.setup != nil {
+			test.setup()
+		}
+		got := bc.bestValidTip(test.base)
+		if got != test.want {
+			t.Fatalf("%s: unexpected best valid tip -- got %v (height "+
+				"%d), want %s (%v)", test.name, got.hash, got.height,
+				test.wantStr, test.want.hash)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/james-ray/hcd/blockchain/internal/dbnamespace"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+	"github.com/james-ray/hcd/hcutil"
+)
+
+// IsReindexPending returns whether a chain reindex was started with
+// ResetChainState but has not yet been completed with Reindex.
+func IsReindexPending(db database.DB) (bool, error) {
+	var pending bool
+	err := db.View(func(dbTx database.Tx) error {
+		state, err := dbFetchReindexState(dbTx)
+		pending = state != nil
+		return err
+	})
+	return pending, err
+}
+
+// ResetChainState removes all of the chain state from the database, including
+// the utxo set, spend journal, block indexes and stake database, while keeping
+// the stored blocks so the chain state can be rebuilt from them with Reindex
+// once a new chain instance has been created.  The current best block is
+// recorded as the target of the reindex.
+//
+// Resetting is resumable, so calling it again after an interruption completes
+// the removal.  It does nothing when a reindex is already pending and the
+// chain state was already removed, or when the database has no chain state.
+func ResetChainState(db database.DB) error {
+	var state *reindexState
+	err := db.Update(func(dbTx database.Tx) error {
+		var err error
+		state, err = dbFetchReindexState(dbTx)
+		if err != nil || state != nil {
+			return err
+		}
+
+		// Nothing to reindex when the chain state has not been created
+		// yet.
+		serializedData := dbTx.Metadata().Get(dbnamespace.ChainStateKeyName)
+		if serializedData == nil {
+			return nil
+		}
+		best, err := deserializeBestChainState(serializedData)
+		if err != nil {
+			return err
+		}
+
+		state = &reindexState{target: best.hash}
+		return dbPutReindexState(dbTx, state)
+	})
+	if err != nil || state == nil || state.stateRemoved {
+		return err
+	}
+
+	log.Infof("Removing the chain state to reindex up to block %v.  This "+
+		"might take a while...", state.target)
+	if err := removeChainState(db); err != nil {
+		return err
+	}
+
+	// Mark the chain state as removed so it is not removed again when the
+	// reindex is resumed.
+	state.stateRemoved = true
+	return db.Update(func(dbTx database.Tx) error {
+		return dbPutReindexState(dbTx, state)
+	})
+}
+
+// Reindex rebuilds the chain state of a pending reindex by connecting all of
+// the stored blocks from the current best block up to the best block at the
+// time the chain state was reset with ResetChainState.  The provided progress
+// function, when not nil, is invoked after each block is connected.
+//
+// The reindex stops and returns an error when the interrupt channel is closed,
+// in which case it is resumed from the current best block on the next call.
+// It does nothing when no reindex is pending.
+func (b *BlockChain) Reindex(interrupt <-chan struct{}, progress func(*hcutil.Block)) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	// Nothing to do when no reindex is pending.
+	var state *reindexState
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		state, err = dbFetchReindexState(dbTx)
+		return err
+	})
+	if err != nil || state == nil {
+		return err
+	}
+	if !state.stateRemoved {
+		return fmt.Errorf("the chain state for the reindex has not been " +
+			"removed")
+	}
+
+	// Find the hashes of the blocks to connect by walking the stored block
+	// headers back from the target until the current best block.
+	var hashes []chainhash.Hash
+	err = b.db.View(func(dbTx database.Tx) error {
+		hash := state.target
+		for hash != b.bestNode.hash {
+			header, err := dbFetchHeaderByHash(dbTx, &hash)
+			if err != nil {
+				return err
+			}
+			if header.Height == 0 {
+				return fmt.Errorf("block %v is not an ancestor of "+
+					"the reindex target %v", b.bestNode.hash,
+					state.target)
+			}
+			hashes = append(hashes, hash)
+			hash = header.PrevBlock
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Reindexing %d blocks from height %d", len(hashes),
+		b.bestNode.height+1)
+	for i := len(hashes) - 1; i >= 0; i-- {
+		select {
+		case <-interrupt:
+			return fmt.Errorf("reindex interrupted at height %d",
+				b.bestNode.height)
+		default:
+		}
+
+		var block *hcutil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			blockBytes, err := dbTx.FetchBlock(&hashes[i])
+			if err != nil {
+				return err
+			}
+			block, err = hcutil.NewBlockFromBytes(blockBytes)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// The blocks are already stored in the database, so they are
+		// connected directly rather than through ProcessBlock, which
+		// would reject them as duplicates.
+		err = checkBlockSanity(block, b.timeSource, BFNone, b.chainParams)
+		if err == nil {
+			_, err = b.maybeAcceptBlock(block, BFNone)
+		}
+		if err != nil {
+			// Give up on the rest of the chain since it builds on an
+			// invalid block, and leave the chain at its parent.
+			log.Errorf("Failed to reindex block %v: %v", block.Hash(),
+				err)
+			rerr := b.db.Update(dbRemoveReindexState)
+			if rerr != nil {
+				return rerr
+			}
+			return fmt.Errorf("block %v at height %d failed "+
+				"validation; the chain state was rebuilt up to "+
+				"height %d", block.Hash(), block.Height(),
+				b.bestNode.height)
+		}
+
+		if progress != nil {
+			progress(block)
+		}
+	}
+
+	err = b.db.Update(dbRemoveReindexState)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Reindex completed at height %d", b.bestNode.height)
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"math"
 	"runtime"
 
+	"github.com/james-ray/hcd/blockchain/stake"
 	"github.com/james-ray/hcd/hcutil"
 	"github.com/james-ray/hcd/txscript"
 	"github.com/james-ray/hcd/wire"
@@ -223,8 +224,11 @@ func ValidateTransactionScripts(tx *hcutil.Tx, utxoView *UtxoViewpoint, flags tx
 // checkBlockScripts executes and validates the scripts for all transactions in
 // the passed block using multiple goroutines.
 // txTree = true is TxTreeRegular, txTree = false is TxTreeStake.
+// The unsigned inputs of automatic revocations are skipped when
+// allowAutoRevocations is set, since they are validated by their amounts.
 func checkBlockScripts(block *hcutil.Block, utxoView *UtxoViewpoint, txTree bool,
-	scriptFlags txscript.ScriptFlags, sigCache *txscript.SigCache) error {
+	allowAutoRevocations bool, scriptFlags txscript.ScriptFlags,
+	sigCache *txscript.SigCache) error {
 
 	// Collect all of the transaction inputs and required information for
 	// validation for all transactions in the block into a single slice.
@@ -243,6 +247,13 @@ func checkBlockScripts(block *hcutil.Block, utxoView *UtxoViewpoint, txTree bool
 	}
 	txValItems := make([]*txValidateItem, 0, numInputs)
 	for _, tx := range txs {
+		// Skip automatic revocations since they carry no signature.
+		if allowAutoRevocations && !txTree &&
+			stake.IsAutoRevocation(tx.MsgTx()) &&
+			stake.DetermineTxType(tx.MsgTx()) == stake.TxTypeSSRtx {
+			continue
+		}
+
 		for txInIdx, txIn := range tx.MsgTx().TxIn {
 			// Skip coinbases.
 			if txIn.PreviousOutPoint.Index == math.MaxUint32 {
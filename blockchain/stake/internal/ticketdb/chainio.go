@@ -715,3 +715,28 @@ func DbCreate(dbTx database.Tx) error {
 	_, err = meta.CreateBucket(dbnamespace.TicketsInBlockBucketName)
 	return err
 }
+
+// DbRemove removes all the buckets of the database along with its best state,
+// so it can be initialized again with DbCreate.  Buckets which do not exist are
+// ignored, so it may also be used on a partially created database.
+func DbRemove(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	buckets := [][]byte{
+		dbnamespace.StakeDbInfoBucketName,
+		dbnamespace.LiveTicketsBucketName,
+		dbnamespace.MissedTicketsBucketName,
+		dbnamespace.RevokedTicketsBucketName,
+		dbnamespace.StakeBlockUndoDataBucketName,
+		dbnamespace.TicketsInBlockBucketName,
+	}
+	for _, bucketName := range buckets {
+		if meta.Bucket(bucketName) == nil {
+			continue
+		}
+		if err := meta.DeleteBucket(bucketName); err != nil {
+			return err
+		}
+	}
+
+	return meta.Delete(dbnamespace.StakeChainStateKeyName)
+}
@@ -7,6 +7,7 @@ package ticketdb
 import (
 	"bytes"
 	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -505,4 +506,30 @@ func TestLiveDatabase(t *testing.T) {
 	if !reflect.DeepEqual(ticketMap, ticketMap2) {
 		t.Fatalf("not same ticket maps")
 	}
+
+	// Remove the database and ensure it can be created again afterwards.
+	err = testDb.Update(func(dbTx database.Tx) error {
+		if err := DbRemove(dbTx); err != nil {
+			return err
+		}
+		if dbTx.Metadata().Bucket(dbnamespace.LiveTicketsBucketName) != nil {
+			return fmt.Errorf("live tickets bucket still exists after " +
+				"removing the database")
+		}
+		return DbCreate(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	err = testDb.View(func(dbTx database.Tx) error {
+		treap, err = DbLoadAllTickets(dbTx, dbnamespace.LiveTicketsBucketName)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if treap.Len() != 0 {
+		t.Fatalf("unexpected %d live tickets after recreating the "+
+			"database", treap.Len())
+	}
 }
@@ -1110,6 +1110,11 @@ func IsSSGen(tx *wire.MsgTx) (bool, error) {
 //
 //	MaxInputsPerSStx [index MaxOutputsPerSSRtx - 1]
 //
+// Revocations are not required to be signed nor to pay a fee here, so
+// automatic revocations created by miners while the automatic ticket
+// revocations agenda is active are identified as SSRtx as well.  See
+// IsAutoRevocation.
+//
 // The errors in this function can be ignored if you want to use it in to
 // identify SSRtx from a list of stake tx.
 func IsSSRtx(tx *wire.MsgTx) (bool, error) {
@@ -1184,6 +1189,57 @@ func IsSSRtx(tx *wire.MsgTx) (bool, error) {
 	return true, nil
 }
 
+// IsAutoRevocation returns whether or not the passed SSRtx is an automatic
+// revocation, that is a revocation that spends the ticket with an empty
+// signature script.  Automatic revocations are only valid while the automatic
+// ticket revocations agenda is active and must pay the ticket commitments
+// without any fee.
+func IsAutoRevocation(tx *wire.MsgTx) bool {
+	return len(tx.TxIn) == NumInputsPerSSRtx &&
+		len(tx.TxIn[0].SignatureScript) == 0
+}
+
+// CreateAutoRevocation creates an automatic revocation for the ticket with the
+// passed hash and minimal outputs.  The revocation pays the exact amounts
+// committed to by the ticket back to its commitment addresses without any fee
+// and does not carry a signature.  The fraud proof fields of the input are left
+// for the caller to fill in.
+func CreateAutoRevocation(ticketHash *chainhash.Hash,
+	ticketOuts []*MinimalOutput) (*wire.MsgTx, error) {
+
+	if len(ticketOuts) == 0 {
+		return nil, stakeRuleError(ErrSSRtxNoOutputs, "ticket has no "+
+			"outputs to revoke")
+	}
+
+	isP2SH, pkhs, amounts, _, _, _, sigTypes := SStxStakeOutputInfo(ticketOuts)
+	if len(pkhs) == 0 || len(pkhs) > MaxOutputsPerSSRtx {
+		str := fmt.Sprintf("ticket has an invalid number of commitments "+
+			"(%d)", len(pkhs))
+		return nil, stakeRuleError(ErrSSRtxTooManyOutputs, str)
+	}
+	rewards := CalculateRewards(amounts, ticketOuts[0].Value, 0)
+
+	mtx := wire.NewMsgTx()
+	prevOut := wire.NewOutPoint(ticketHash, 0, wire.TxTreeStake)
+	mtx.AddTxIn(wire.NewTxIn(prevOut, nil))
+	for i, pkh := range pkhs {
+		var script []byte
+		var err error
+		if isP2SH[i] {
+			script, err = txscript.PayToSSRtxSHDirect(pkh, int(sigTypes[i]))
+		} else {
+			script, err = txscript.PayToSSRtxPKHDirect(pkh, int(sigTypes[i]))
+		}
+		if err != nil {
+			return nil, err
+		}
+		mtx.AddTxOut(wire.NewTxOut(rewards[i], script))
+	}
+
+	return mtx, nil
+}
+
 // DetermineTxType determines the type of stake transaction a transaction is; if
 // none, it returns that it is an assumed regular tx.
 func DetermineTxType(tx *wire.MsgTx) TxType {
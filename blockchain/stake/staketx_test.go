@@ -625,6 +625,48 @@ func TestIsSSRtx(t *testing.T) {
 	}
 }
 
+// TestCreateAutoRevocation ensures automatic revocations created for a ticket
+// are identified as unsigned revocations that pay the exact ticket
+// commitments.
+func TestCreateAutoRevocation(t *testing.T) {
+	ticketHash := sstxMsgTx.TxHash()
+	minOuts := stake.ConvertToMinimalOutputs(sstxMsgTx)
+	revocation, err := stake.CreateAutoRevocation(&ticketHash, minOuts)
+	if err != nil {
+		t.Fatalf("CreateAutoRevocation: unexpected error: %v", err)
+	}
+
+	if is, err := stake.IsSSRtx(revocation); !is || err != nil {
+		t.Fatalf("IsSSRtx should have returned true,<nil> but instead "+
+			"returned %v,%v", is, err)
+	}
+	if !stake.IsAutoRevocation(revocation) {
+		t.Fatal("IsAutoRevocation should have returned true")
+	}
+	if revocation.TxIn[0].PreviousOutPoint.Hash != ticketHash {
+		t.Fatalf("revocation spends %v instead of ticket %v",
+			revocation.TxIn[0].PreviousOutPoint.Hash, ticketHash)
+	}
+
+	_, _, amounts, _, _, _, _ := stake.SStxStakeOutputInfo(minOuts)
+	want := stake.CalculateRewards(amounts, minOuts[0].Value, 0)
+	if len(revocation.TxOut) != len(want) {
+		t.Fatalf("revocation has %d outputs instead of %d",
+			len(revocation.TxOut), len(want))
+	}
+	for i, txOut := range revocation.TxOut {
+		if txOut.Value != want[i] {
+			t.Errorf("revocation output %d pays %v instead of %v", i,
+				txOut.Value, want[i])
+		}
+	}
+
+	if stake.IsAutoRevocation(ssrtxMsgTx) {
+		t.Error("IsAutoRevocation should have returned false for a " +
+			"signed revocation")
+	}
+}
+
 func TestIsSSRtxErrors(t *testing.T) {
 	// Initialize the buffer for later manipulation
 	var buf bytes.Buffer
@@ -98,6 +98,21 @@ func (sn *Node) MissedByBlock() []chainhash.Hash {
 	return missed
 }
 
+// NewlyMissedByBlock returns the tickets that were missed or expired in this
+// block.  Unlike MissedByBlock, tickets that were revoked in this block are not
+// included, so these are exactly the tickets that become revocable once this
+// block is connected.
+func (sn *Node) NewlyMissedByBlock() []chainhash.Hash {
+	var missed []chainhash.Hash
+	for _, undo := range sn.databaseUndoUpdate {
+		if undo.Missed && !undo.Revoked {
+			missed = append(missed, undo.TicketHash)
+		}
+	}
+
+	return missed
+}
+
 // ExistsLiveTicket returns whether or not a ticket exists in the live ticket
 // treap for this stake node.
 func (sn *Node) ExistsLiveTicket(ticket chainhash.Hash) bool {
@@ -222,6 +237,13 @@ func genesisNode(params *chaincfg.Params) *Node {
 	}
 }
 
+// GenesisNode returns a pointer to an in-memory stake node for the genesis
+// block that is not backed by the ticket database.  It is primarily useful for
+// simulating the ticket pool by connecting synthetic blocks to it.
+func GenesisNode(params *chaincfg.Params) *Node {
+	return genesisNode(params)
+}
+
 // InitDatabaseState initializes the chain with the best state being the
 // genesis block.
 func InitDatabaseState(dbTx database.Tx, params *chaincfg.Params) (*Node, error) {
@@ -264,6 +286,12 @@ func InitDatabaseState(dbTx database.Tx, params *chaincfg.Params) (*Node, error)
 	return genesis, nil
 }
 
+// RemoveDatabaseState removes all of the ticket database state, so that it can
+// be initialized again with InitDatabaseState and rebuilt from the blocks.
+func RemoveDatabaseState(dbTx database.Tx) error {
+	return ticketdb.DbRemove(dbTx)
+}
+
 // LoadBestNode is used when the blockchain is initialized, to get the initial
 // stake node from the database bucket.  The blockchain must pass the height
 // and the blockHash to confirm that the ticket database is on the same
@@ -1053,3 +1081,12 @@ func WriteDisconnectedBestNode(dbTx database.Tx, node *Node, hash chainhash.Hash
 		NextWinners: nextWinners,
 	})
 }
+
+// FetchUndoData returns the ticket undo data stored in the database for the
+// main chain block at the passed height.  It describes the tickets which
+// matured, were spent, missed, expired or revoked by the block, so it may be
+// used by callers such as indexers to learn how the block changed the state of
+// the ticket database.
+func FetchUndoData(dbTx database.Tx, height uint32) (UndoTicketDataSlice, error) {
+	return ticketdb.DbFetchBlockUndoData(dbTx, height)
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stake
+
+import (
+	"fmt"
+
+	"github.com/james-ray/hcd/blockchain/stake/internal/dbnamespace"
+	"github.com/james-ray/hcd/blockchain/stake/internal/ticketdb"
+	"github.com/james-ray/hcd/blockchain/stake/internal/tickettreap"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+)
+
+// VerifyReportFunc is the function VerifyTicketDB passes every inconsistency it
+// finds to along with whether or not it was repaired.
+type VerifyReportFunc func(issue string, repaired bool)
+
+// verifyTicketBucket checks that all tickets in the passed ticket bucket can be
+// loaded, that none of them was purchased after the passed best height, that
+// their state flags satisfy the passed function, and that their number matches
+// the count recorded in the stake best chain state.
+func verifyTicketBucket(dbTx database.Tx, bucketName []byte, height uint32, wantCount uint64, validFlags func(v *tickettreap.Value) bool, report VerifyReportFunc) {
+	if dbTx.Metadata().Bucket(bucketName) == nil {
+		report(fmt.Sprintf("the %s bucket does not exist", bucketName),
+			false)
+		return
+	}
+
+	tickets, err := ticketdb.DbLoadAllTickets(dbTx, bucketName)
+	if err != nil {
+		report(fmt.Sprintf("unable to load the %s bucket: %v",
+			bucketName, err), false)
+		return
+	}
+
+	tickets.ForEach(func(k tickettreap.Key, v *tickettreap.Value) bool {
+		hash := chainhash.Hash(k)
+		if v.Height > height {
+			report(fmt.Sprintf("ticket %v in the %s bucket was "+
+				"purchased at height %d after the best height %d",
+				hash, bucketName, v.Height, height), false)
+		}
+		if !validFlags(v) {
+			report(fmt.Sprintf("ticket %v in the %s bucket has "+
+				"invalid state flags (missed %v, revoked %v, "+
+				"spent %v, expired %v)", hash, bucketName,
+				v.Missed, v.Revoked, v.Spent, v.Expired), false)
+		}
+		return true
+	})
+
+	if uint64(tickets.Len()) != wantCount {
+		report(fmt.Sprintf("the %s bucket has %d tickets, but the stake "+
+			"chain state records %d", bucketName, tickets.Len(),
+			wantCount), false)
+	}
+}
+
+// VerifyTicketDB checks the ticket database for consistency with the main chain
+// tip identified by the passed hash and height.  It ensures the stake best
+// chain state matches the tip, the live, missed, and revoked ticket buckets
+// match the counts recorded in it, and that the block undo data and new
+// tickets entries exist and deserialize for every height up to the tip.
+//
+// Every inconsistency found is passed to the report function.  When repair is
+// set, entries for heights after the tip, which are left behind by blocks that
+// were disconnected without removing them, are deleted.  The other
+// inconsistencies can't be repaired without reprocessing the chain.  Repairing
+// requires a writable transaction.
+func VerifyTicketDB(dbTx database.Tx, hash chainhash.Hash, height uint32, repair bool, report VerifyReportFunc) error {
+	state, err := ticketdb.DbFetchBestState(dbTx)
+	if err != nil {
+		report(fmt.Sprintf("unable to load the stake chain state: %v",
+			err), false)
+		return nil
+	}
+	if state.Hash != hash || state.Height != height {
+		report(fmt.Sprintf("the stake chain state is at block %v "+
+			"(height %d), but the main chain tip is block %v "+
+			"(height %d)", state.Hash, state.Height, hash, height),
+			false)
+	}
+
+	// Check the ticket buckets against the stake chain state.  Live
+	// tickets have no state flags set while missed tickets are never
+	// revoked and revoked tickets are always missed.
+	verifyTicketBucket(dbTx, dbnamespace.LiveTicketsBucketName, height,
+		uint64(state.Live), func(v *tickettreap.Value) bool {
+			return !v.Missed && !v.Revoked && !v.Spent && !v.Expired
+		}, report)
+	verifyTicketBucket(dbTx, dbnamespace.MissedTicketsBucketName, height,
+		state.Missed, func(v *tickettreap.Value) bool {
+			return v.Missed && !v.Revoked
+		}, report)
+	verifyTicketBucket(dbTx, dbnamespace.RevokedTicketsBucketName, height,
+		state.Revoked, func(v *tickettreap.Value) bool {
+			return v.Missed && v.Revoked
+		}, report)
+
+	// The block undo data and new tickets are stored for every height of
+	// the main chain including the genesis block.
+	for h := uint32(0); h <= height; h++ {
+		if _, err := ticketdb.DbFetchBlockUndoData(dbTx, h); err != nil {
+			report(fmt.Sprintf("invalid block undo data at height "+
+				"%d: %v", h, err), false)
+		}
+		if _, err := ticketdb.DbFetchNewTickets(dbTx, h); err != nil {
+			report(fmt.Sprintf("invalid new tickets at height %d: %v",
+				h, err), false)
+		}
+	}
+
+	// Find and optionally remove the entries for heights after the tip.
+	// The keys are collected first since buckets must not be modified
+	// while iterating them.
+	for _, bucketName := range [][]byte{
+		dbnamespace.StakeBlockUndoDataBucketName,
+		dbnamespace.TicketsInBlockBucketName,
+	} {
+		bucket := dbTx.Metadata().Bucket(bucketName)
+		if bucket == nil {
+			report(fmt.Sprintf("the %s bucket does not exist",
+				bucketName), false)
+			continue
+		}
+
+		var staleKeys [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			if len(k) != 4 {
+				report(fmt.Sprintf("invalid key %x in the %s "+
+					"bucket", k, bucketName), false)
+				return nil
+			}
+			if dbnamespace.ByteOrder.Uint32(k) > height {
+				staleKeys = append(staleKeys, append([]byte(nil),
+					k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range staleKeys {
+			issue := fmt.Sprintf("the %s bucket has an entry for "+
+				"height %d after the best height %d", bucketName,
+				dbnamespace.ByteOrder.Uint32(k), height)
+			if !repair {
+				report(issue, false)
+				continue
+			}
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+			report(issue, true)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stake
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/james-ray/hcd/blockchain/stake/internal/dbnamespace"
+	"github.com/james-ray/hcd/blockchain/stake/internal/ticketdb"
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+)
+
+// TestVerifyTicketDB ensures VerifyTicketDB reports inconsistencies in the
+// ticket database and only repairs the ones it is able to.
+func TestVerifyTicketDB(t *testing.T) {
+	params := &chaincfg.SimNetParams
+	dbPath := filepath.Join(testDbRoot, "ffldb_verifytest")
+	_ = os.RemoveAll(dbPath)
+	testDb, err := database.Create(testDbType, dbPath, params.Net)
+	if err != nil {
+		t.Fatalf("error creating db: %v", err)
+	}
+	defer os.RemoveAll(testDbRoot)
+	defer testDb.Close()
+
+	genesisHash := params.GenesisBlock.BlockHash()
+	err = testDb.Update(func(dbTx database.Tx) error {
+		_, err := InitDatabaseState(dbTx, params)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to initialize the ticket database: %v", err)
+	}
+
+	// verify runs VerifyTicketDB against the genesis block and returns the
+	// number of reported and repaired inconsistencies.
+	verify := func(repair bool) (int, int) {
+		var numIssues, numRepaired int
+		err := testDb.Update(func(dbTx database.Tx) error {
+			return VerifyTicketDB(dbTx, genesisHash, 0, repair,
+				func(issue string, repaired bool) {
+					numIssues++
+					if repaired {
+						numRepaired++
+					}
+				})
+		})
+		if err != nil {
+			t.Fatalf("VerifyTicketDB: unexpected error: %v", err)
+		}
+		return numIssues, numRepaired
+	}
+
+	// A freshly initialized database is consistent.
+	if numIssues, _ := verify(false); numIssues != 0 {
+		t.Fatalf("unexpected issues in a new database: %d", numIssues)
+	}
+
+	// Add undo data for a height after the tip along with a live ticket
+	// the chain state does not account for.
+	ticket := chainhash.Hash{0x01}
+	err = testDb.Update(func(dbTx database.Tx) error {
+		err := ticketdb.DbPutBlockUndoData(dbTx, 5, nil)
+		if err != nil {
+			return err
+		}
+		return ticketdb.DbPutTicket(dbTx,
+			dbnamespace.LiveTicketsBucketName, &ticket, 0, false,
+			false, false, false)
+	})
+	if err != nil {
+		t.Fatalf("unable to corrupt the ticket database: %v", err)
+	}
+
+	// Both inconsistencies are reported without repair.
+	if numIssues, numRepaired := verify(false); numIssues != 2 ||
+		numRepaired != 0 {
+
+		t.Fatalf("unexpected results without repair - got %d issues "+
+			"(%d repaired), want 2 issues (0 repaired)", numIssues,
+			numRepaired)
+	}
+
+	// Only the stale undo data is repaired.
+	if numIssues, numRepaired := verify(true); numIssues != 2 ||
+		numRepaired != 1 {
+
+		t.Fatalf("unexpected results with repair - got %d issues "+
+			"(%d repaired), want 2 issues (1 repaired)", numIssues,
+			numRepaired)
+	}
+	if numIssues, _ := verify(false); numIssues != 1 {
+		t.Fatalf("unexpected issues after repair - got %d, want 1",
+			numIssues)
+	}
+}
@@ -68,6 +68,19 @@ func (b *BlockChain) LotteryDataForBlock(hash *chainhash.Hash) ([]chainhash.Hash
 	return b.lotteryDataForBlock(hash)
 }
 
+// TicketsToRevoke returns the tickets that were missed or expired by the
+// current best block and therefore must be revoked by the next block while the
+// automatic ticket revocations agenda is active.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) TicketsToRevoke() ([]chainhash.Hash, error) {
+	b.chainLock.RLock()
+	sn := b.bestNode.stakeNode
+	b.chainLock.RUnlock()
+
+	return sn.NewlyMissedByBlock(), nil
+}
+
 // LiveTickets returns all currently live tickets from the stake database.
 //
 // This function is NOT safe for concurrent access.
@@ -7,6 +7,8 @@
 package blockchain
 
 import (
+	"fmt"
+
 	"github.com/james-ray/hcd/blockchain/internal/progresslog"
 	"github.com/james-ray/hcd/blockchain/stake"
 	"github.com/james-ray/hcd/chaincfg/chainhash"
@@ -111,3 +113,52 @@ func (b *BlockChain) upgrade() error {
 
 	return nil
 }
+
+// dbBlocksCompressed returns whether or not the database of the passed
+// transaction is set to store its blocks compressed.
+func dbBlocksCompressed(dbTx database.Tx) (bool, error) {
+	compressor, ok := dbTx.(database.BlockCompressor)
+	if !ok {
+		return false, nil
+	}
+	compression, err := compressor.BlockCompression()
+	if err != nil {
+		return false, err
+	}
+	return compression != database.BlockCompressionNone, nil
+}
+
+// UpgradeForCompressedBlocks upgrades the chain state in the database of the
+// passed transaction to version 3, which allows the blocks to be stored
+// compressed.  None of the existing data changes, so only the new database
+// version is written, which prevents older software that does not understand
+// compressed blocks from opening the database.  It must be called by the
+// transaction which enables block compression.
+//
+// Databases without any chain state are left as they are since the chain
+// state is created with version 3 when the blocks are compressed.  Version 1
+// databases must be upgraded by starting the software once before.
+func UpgradeForCompressedBlocks(dbTx database.Tx) error {
+	dbInfo, err := dbFetchDatabaseInfo(dbTx)
+	if err != nil {
+		return err
+	}
+	if dbInfo == nil || dbInfo.version >= compressedBlocksDatabaseVersion {
+		return nil
+	}
+	if dbInfo.version < uncompressedBlocksDatabaseVersion {
+		return fmt.Errorf("the blockchain database's version is %v and "+
+			"must be upgraded before the blocks can be compressed -- "+
+			"start hcd once without block compression to upgrade it",
+			dbInfo.version)
+	}
+
+	dbInfo.version = compressedBlocksDatabaseVersion
+	if err := dbPutDatabaseInfo(dbTx, dbInfo); err != nil {
+		return err
+	}
+	log.Infof("Upgraded the blockchain database to version %d to allow "+
+		"compressed blocks.  Older versions of the software can no "+
+		"longer open the database", dbInfo.version)
+	return nil
+}
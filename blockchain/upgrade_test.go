@@ -0,0 +1,178 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/james-ray/hcd/blockchain/internal/dbnamespace"
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/database"
+	_ "github.com/james-ray/hcd/database/ffldb"
+	"github.com/james-ray/hcd/wire"
+)
+
+// fetchDatabaseVersion returns the version of the chain state stored in the
+// passed database.
+func fetchDatabaseVersion(t *testing.T, db database.DB) uint32 {
+	t.Helper()
+	var version uint32
+	err := db.View(func(dbTx database.Tx) error {
+		dbInfo, err := dbFetchDatabaseInfo(dbTx)
+		if err != nil {
+			return err
+		}
+		version = dbInfo.version
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to fetch database info: %v", err)
+	}
+	return version
+}
+
+// TestUpgradeForCompressedBlocks ensures that the chain state is only upgraded
+// to version 3 once block compression is enabled.
+func TestUpgradeForCompressedBlocks(t *testing.T) {
+	dbPath := filepath.Join(os.TempDir(), "upgradeforcompressedblocks")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", dbPath, wire.SimNet)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	// Databases without any chain state are left as they are.
+	if err := db.Update(UpgradeForCompressedBlocks); err != nil {
+		t.Fatalf("UpgradeForCompressedBlocks: unexpected error: %v", err)
+	}
+	err = db.View(func(dbTx database.Tx) error {
+		dbInfo, err := dbFetchDatabaseInfo(dbTx)
+		if err == nil && dbInfo != nil {
+			t.Fatalf("database info created for empty database")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to fetch database info: %v", err)
+	}
+
+	// Store the information of a version 2 database.
+	b := &BlockChain{
+		db:          db,
+		chainParams: &chaincfg.SimNetParams,
+		dbInfo: &databaseInfo{
+			version: 2,
+			compVer: currentCompressionVersion,
+			date:    time.Unix(time.Now().Unix(), 0),
+		},
+	}
+	err = db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		_, err := meta.CreateBucket(dbnamespace.BlockChainDbInfoBucketName)
+		if err != nil {
+			return err
+		}
+		return dbPutDatabaseInfo(dbTx, b.dbInfo)
+	})
+	if err != nil {
+		t.Fatalf("Failed to store database info: %v", err)
+	}
+
+	// The upgrades applied on start up must leave it at version 2.
+	if err := b.upgrade(); err != nil {
+		t.Fatalf("upgrade: unexpected error: %v", err)
+	}
+	if version := fetchDatabaseVersion(t, db); version != 2 {
+		t.Fatalf("version after upgrade: got %d, want 2", version)
+	}
+
+	// Enabling compression upgrades it to version 3, and doing so again
+	// leaves it there.
+	for i := 0; i < 2; i++ {
+		if err := db.Update(UpgradeForCompressedBlocks); err != nil {
+			t.Fatalf("UpgradeForCompressedBlocks: unexpected error: "+
+				"%v", err)
+		}
+		version := fetchDatabaseVersion(t, db)
+		if version != compressedBlocksDatabaseVersion {
+			t.Fatalf("version after UpgradeForCompressedBlocks: got "+
+				"%d, want %d", version,
+				compressedBlocksDatabaseVersion)
+		}
+	}
+
+	// Version 1 databases must be upgraded on start up first.
+	b.dbInfo.version = 1
+	err = db.Update(func(dbTx database.Tx) error {
+		return dbPutDatabaseInfo(dbTx, b.dbInfo)
+	})
+	if err != nil {
+		t.Fatalf("Failed to store database info: %v", err)
+	}
+	if err := db.Update(UpgradeForCompressedBlocks); err == nil {
+		t.Fatalf("UpgradeForCompressedBlocks: upgraded version 1 " +
+			"database")
+	}
+	if version := fetchDatabaseVersion(t, db); version != 1 {
+		t.Fatalf("version after failed upgrade: got %d, want 1", version)
+	}
+}
+
+// TestCreateChainStateVersion ensures new chain states are only created with
+// version 3 when the blocks of the database are stored compressed.
+func TestCreateChainStateVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression database.BlockCompression
+		want        uint32
+	}{{
+		name:        "uncompressed",
+		compression: database.BlockCompressionNone,
+		want:        uncompressedBlocksDatabaseVersion,
+	}, {
+		name:        "compressed",
+		compression: database.BlockCompressionSnappy,
+		want:        compressedBlocksDatabaseVersion,
+	}}
+
+	for _, test := range tests {
+		dbPath := filepath.Join(os.TempDir(), "createchainstateversion")
+		_ = os.RemoveAll(dbPath)
+		db, err := database.Create("ffldb", dbPath, wire.SimNet)
+		if err != nil {
+			t.Fatalf("%s: failed to create database: %v", test.name,
+				err)
+		}
+		err = db.Update(func(dbTx database.Tx) error {
+			compressor := dbTx.(database.BlockCompressor)
+			return compressor.SetBlockCompression(test.compression)
+		})
+		if err == nil {
+			_, err = New(&Config{
+				DB:          db,
+				ChainParams: &chaincfg.SimNetParams,
+				TimeSource:  NewMedianTime(),
+			})
+		}
+		if err != nil {
+			db.Close()
+			os.RemoveAll(dbPath)
+			t.Fatalf("%s: failed to create chain: %v", test.name, err)
+		}
+
+		version := fetchDatabaseVersion(t, db)
+		db.Close()
+		os.RemoveAll(dbPath)
+		if version != test.want {
+			t.Errorf("%s: unexpected version - got %d, want %d",
+				test.name, version, test.want)
+		}
+	}
+}
@@ -403,6 +403,48 @@ func CheckProofOfWork(block *hcutil.Block, powLimit *big.Int) error {
 	return checkProofOfWork(&block.MsgBlock().Header, powLimit, BFNone)
 }
 
+// CheckHeaderProofOfWork ensures the passed block header bits which indicate
+// the target difficulty is in min/max range and that the header hash is less
+// than the target difficulty as claimed.  It allows headers to be checked
+// before the full block is available.
+func CheckHeaderProofOfWork(header *wire.BlockHeader, powLimit *big.Int) error {
+	return checkProofOfWork(header, powLimit, BFNone)
+}
+
+// CheckHeaderDifficulty ensures the target difficulty claimed by the passed
+// block header is not lower than the easiest difficulty the retarget rules
+// allow given the difficulty of the passed known good header it builds on,
+// such as a checkpoint or a block of the main chain, and the time elapsed
+// since then.  The header timestamp must not be too far in the future, since
+// the elapsed time could otherwise be forged to claim an arbitrarily low
+// difficulty.  It allows the difficulty of a chain of headers to be checked
+// before the full blocks, and therefore the ancestors needed to calculate the
+// exact required difficulty, are available.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckHeaderDifficulty(header, refHeader *wire.BlockHeader) error {
+	maxTimestamp := b.timeSource.AdjustedTime().Add(time.Second *
+		MaxTimeOffsetSeconds)
+	if header.Timestamp.After(maxTimestamp) {
+		str := fmt.Sprintf("block timestamp of %v is too far in the "+
+			"future", header.Timestamp)
+		return ruleError(ErrTimeTooNew, str)
+	}
+
+	duration := header.Timestamp.Sub(refHeader.Timestamp)
+	requiredTarget := CompactToBig(b.calcEasiestDifficulty(refHeader.Bits,
+		duration))
+	currentTarget := CompactToBig(header.Bits)
+	if currentTarget.Cmp(requiredTarget) > 0 {
+		str := fmt.Sprintf("block target difficulty of %064x is too "+
+			"low when compared to block %v", currentTarget,
+			refHeader.BlockHash())
+		return ruleError(ErrDifficultyTooLow, str)
+	}
+
+	return nil
+}
+
 // checkBlockHeaderSanity performs some preliminary checks on a block header to
 // ensure it is sane before continuing with processing.  These checks are
 // context free.
@@ -1225,7 +1267,10 @@ func (b *BlockChain) CheckBlockStakeSanity(stakeValidationHeight int64, node *bl
 	// PER BLOCK
 	// 3. Check and make sure that we have the same number of SSRtx tx as
 	//    we do revocations.
+	// 4. Ensure that every ticket missed or expired by the parent block is
+	//    revoked when the automatic ticket revocations agenda is active.
 	numSSRtxTx := 0
+	revokedTickets := make(map[chainhash.Hash]struct{})
 	for _, staketx := range stakeTransactions {
 		msgTx := staketx.MsgTx()
 		if is, _ := stake.IsSSRtx(msgTx); is {
@@ -1235,6 +1280,7 @@ func (b *BlockChain) CheckBlockStakeSanity(stakeValidationHeight int64, node *bl
 			// transaction.
 			sstxIn := msgTx.TxIn[0] // sstx input
 			sstxHash := sstxIn.PreviousOutPoint.Hash
+			revokedTickets[sstxHash] = struct{}{}
 
 			ticketMissed := false
 
@@ -1256,6 +1302,27 @@ func (b *BlockChain) CheckBlockStakeSanity(stakeValidationHeight int64, node *bl
 	// 3. Check and make sure that we have the same number of SSRtx tx as
 	//    we do revocations.  Already checked in checkBlockSanity.
 
+	// 4. Ensure the tickets missed or expired by the parent block are all
+	//    revoked by this block once automatic revocations are enforced.
+	if node.height >= stakeValidationHeight {
+		autoRevocationsActive, err := b.isAutoRevocationsAgendaActive(
+			node.parent)
+		if err != nil {
+			return err
+		}
+		if autoRevocationsActive {
+			for _, ticket := range parentStakeNode.NewlyMissedByBlock() {
+				if _, ok := revokedTickets[ticket]; ok {
+					continue
+				}
+				errStr := fmt.Sprintf("block %v does not revoke "+
+					"ticket %v which was missed or expired by "+
+					"its parent", blockHash, ticket)
+				return ruleError(ErrMissingRevocation, errStr)
+			}
+		}
+	}
+
 	// -------------------------------------------------------------------
 	// Final Checks
 	// -------------------------------------------------------------------
@@ -1653,6 +1720,21 @@ func CheckTransactionInputs(subsidyCache *SubsidyCache, tx *hcutil.Tx, txHeight
 			return 0, ruleError(ErrSSRtxPayees, errStr)
 		}
 
+		// Automatic revocations are not signed, so they must pay
+		// exactly the calculated amounts without deducting any fee.
+		if stake.IsAutoRevocation(msgTx) {
+			for i, amt := range ssrtxAmts {
+				if amt != ssrtxCalcAmts[i] {
+					errStr := fmt.Sprintf("automatic "+
+						"revocation %v pays %v at output "+
+						"%d instead of the expected %v",
+						txHash, amt, i, ssrtxCalcAmts[i])
+					return 0, ruleError(
+						ErrInvalidAutoRevocation, errStr)
+				}
+			}
+		}
+
 		// 2. Check to make sure that the second input was an OP_SSTX
 		//    tagged output from the referenced SStx.
 		if txscript.GetScriptClass(utxoEntrySstx.ScriptVersionByIndex(0),
@@ -2465,12 +2547,19 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *hcutil.Block, utx
 		runScripts = false
 	}
 	var scriptFlags txscript.ScriptFlags
+	var autoRevocationsActive bool
 	if runScripts {
 		var err error
 		scriptFlags, err = b.consensusScriptVerifyFlags(node)
 		if err != nil {
 			return err
 		}
+
+		autoRevocationsActive, err = b.isAutoRevocationsAgendaActive(
+			node.parent)
+		if err != nil {
+			return err
+		}
 	}
 
 	// The number of signature operations must be less than the maximum
@@ -2561,8 +2650,8 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *hcutil.Block, utx
 	}
 
 	if runScripts {
-		err = checkBlockScripts(block, utxoView, false, scriptFlags,
-			b.sigCache)
+		err = checkBlockScripts(block, utxoView, false,
+			autoRevocationsActive, scriptFlags, b.sigCache)
 		if err != nil {
 			log.Tracef("checkBlockScripts failed; error returned "+
 				"on txtreestake of cur block: %v", err)
@@ -2612,7 +2701,7 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *hcutil.Block, utx
 	}
 
 	if runScripts {
-		err = checkBlockScripts(block, utxoView, true,
+		err = checkBlockScripts(block, utxoView, true, false,
 			scriptFlags, b.sigCache)
 		if err != nil {
 			log.Tracef("checkBlockScripts failed; error returned "+
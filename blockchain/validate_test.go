@@ -17,6 +17,8 @@ import (
 	"time"
 
 	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/chaingen"
+	"github.com/james-ray/hcd/blockchain/stake"
 	"github.com/james-ray/hcd/chaincfg"
 	"github.com/james-ray/hcd/chaincfg/chainhash"
 	"github.com/james-ray/hcd/database"
@@ -2149,6 +2151,206 @@ func TestTxValidationErrors(t *testing.T) {
 	}
 }
 
+// TestAutoRevocations ensures that blocks are required to revoke all of the
+// tickets that were missed or expired by their parent once the automatic ticket
+// revocations agenda is active, that unsigned revocations are only accepted when
+// they pay the exact amounts committed to by the ticket, and that they are only
+// exempt from script validation once the agenda is active.
+func TestAutoRevocations(t *testing.T) {
+	// Create a test harness initialized with the genesis block as the tip.
+	params := quickVoteActivationParams()
+	g, teardownFunc := newChaingenHarness(t, params, "autorevocationstest")
+	defer teardownFunc()
+
+	// Shorter versions of useful params for convenience.
+	voteID := chaincfg.VoteIDAutoRevocations
+	deploymentVer := g.deploymentVersions[voteID]
+
+	// autoRevocations returns automatic revocations with the fraud proofs
+	// filled in for all of the tickets that were missed or expired by the
+	// current best block of the chain.
+	autoRevocations := func() []*wire.MsgTx {
+		t.Helper()
+
+		ticketHashes, err := g.chain.TicketsToRevoke()
+		if err != nil {
+			t.Fatalf("unable to get tickets to revoke: %v", err)
+		}
+		revocations := make([]*wire.MsgTx, 0, len(ticketHashes))
+		for i := range ticketHashes {
+			ticketHash := &ticketHashes[i]
+			entry, err := g.chain.FetchUtxoEntry(ticketHash)
+			if err != nil || entry == nil {
+				t.Fatalf("unable to fetch ticket %v: %v", ticketHash,
+					err)
+			}
+			revocation, err := stake.CreateAutoRevocation(ticketHash,
+				blockchain.ConvertUtxosToMinimalOutputs(entry))
+			if err != nil {
+				t.Fatalf("unable to create revocation for ticket "+
+					"%v: %v", ticketHash, err)
+			}
+			txIn := revocation.TxIn[0]
+			txIn.ValueIn = entry.AmountByIndex(0)
+			txIn.BlockHeight = uint32(entry.BlockHeight())
+			txIn.BlockIndex = entry.BlockIndex()
+			revocations = append(revocations, revocation)
+		}
+		return revocations
+	}
+
+	// addRevocations returns a function that itself takes a block and
+	// modifies it by adding the provided revocations to the stake tree and
+	// updating the number of revocations in the header accordingly.
+	addRevocations := func(revocations []*wire.MsgTx) func(*wire.MsgBlock) {
+		return func(b *wire.MsgBlock) {
+			for _, revocation := range revocations {
+				b.AddSTransaction(revocation)
+			}
+			b.Header.Revocations += uint8(len(revocations))
+		}
+	}
+
+	// replaceVersions returns a function that itself takes a block and
+	// modifies it by replacing the block, stake, and vote versions with
+	// the deployment version of the agenda.
+	replaceVersions := func(b *wire.MsgBlock) {
+		chaingen.ReplaceBlockVersion(int32(deploymentVer))(b)
+		chaingen.ReplaceStakeVersion(deploymentVer)(b)
+		chaingen.ReplaceVoteVersions(deploymentVer)(b)
+	}
+
+	// ---------------------------------------------------------------------
+	// Generate and accept enough blocks to reach stake validation height.
+	// ---------------------------------------------------------------------
+
+	g.AdvanceToStakeValidationHeight()
+
+	// ---------------------------------------------------------------------
+	// Create a block that only includes four votes so one of the winning
+	// tickets is missed.
+	//
+	// NOTE: Tickets are not purchased in blocks that replace the votes
+	// since the generator tracks the positions of the purchases in the
+	// stake tree based on the default number of votes.
+	//
+	//   ... -> bsv# -> bpremiss
+	// ---------------------------------------------------------------------
+
+	g.NextBlock("bpremiss", nil, nil, g.ReplaceWithNVotes(4))
+	g.SaveTipCoinbaseOuts()
+	g.AcceptTipBlock()
+	g.TestThresholdState(voteID, blockchain.ThresholdDefined)
+
+	// ---------------------------------------------------------------------
+	// Create a block that revokes the missed ticket with an automatic
+	// revocation before the agenda is active.
+	//
+	// The block should be rejected because automatic revocations are not
+	// signed and thus fail the script checks until the agenda is active.
+	//
+	//   ... -> bpremiss
+	//                  \-> bpreautorev
+	// ---------------------------------------------------------------------
+
+	revocations := autoRevocations()
+	if len(revocations) != 1 {
+		t.Fatalf("unexpected number of tickets to revoke -- got %d, "+
+			"want 1", len(revocations))
+	}
+	g.NextBlock("bpreautorev", nil, nil, addRevocations(revocations))
+	g.RejectTipBlock(blockchain.ErrScriptMalformed)
+
+	// ---------------------------------------------------------------------
+	// Create a block that does not revoke the missed ticket.
+	//
+	// The block should be accepted because revocations are not required
+	// until the agenda is active.
+	//
+	//   ... -> bpremiss -> bprenorev
+	// ---------------------------------------------------------------------
+
+	g.SetTip("bpremiss")
+	outs := g.OldestCoinbaseOuts()
+	g.NextBlock("bprenorev", nil, outs[1:])
+	g.SaveTipCoinbaseOuts()
+	g.AcceptTipBlock()
+
+	// ---------------------------------------------------------------------
+	// Generate and accept enough blocks with the appropriate vote bits set
+	// to reach one block prior to the agenda becoming active.
+	// ---------------------------------------------------------------------
+
+	g.AdvanceFromSVHToActiveAgenda(voteID)
+
+	// ---------------------------------------------------------------------
+	// Create a block that revokes any tickets missed or expired by its
+	// parent and only includes four votes so one of the winning tickets is
+	// missed.
+	//
+	//   ... -> bva# -> bmiss
+	// ---------------------------------------------------------------------
+
+	g.NextBlock("bmiss", nil, nil, g.ReplaceWithNVotes(4),
+		replaceVersions, addRevocations(autoRevocations()))
+	g.SaveTipCoinbaseOuts()
+	g.AcceptTipBlock()
+
+	// ---------------------------------------------------------------------
+	// Create a block that does not revoke the missed ticket.
+	//
+	// The block should be rejected because the agenda is active and thus
+	// all tickets missed or expired by the parent must be revoked.
+	//
+	//   ... -> bmiss
+	//               \-> bnorev
+	// ---------------------------------------------------------------------
+
+	revocations = autoRevocations()
+	if len(revocations) == 0 {
+		t.Fatal("no tickets to revoke after a block missed a vote")
+	}
+	g.NextBlock("bnorev", nil, nil, replaceVersions)
+	g.RejectTipBlock(blockchain.ErrMissingRevocation)
+
+	// ---------------------------------------------------------------------
+	// Create a block that revokes the missed ticket with an automatic
+	// revocation that pays one atom less than the ticket commitment.
+	//
+	// The block should be rejected because automatic revocations must pay
+	// the exact committed amounts since they do not pay a fee.
+	//
+	//   ... -> bmiss
+	//               \-> bbadrevamt
+	// ---------------------------------------------------------------------
+
+	g.SetTip("bmiss")
+	badRevocations := autoRevocations()
+	badRevocations[0].TxOut[0].Value--
+	g.NextBlock("bbadrevamt", nil, nil, replaceVersions,
+		addRevocations(badRevocations))
+	g.RejectTipBlock(blockchain.ErrInvalidAutoRevocation)
+
+	// ---------------------------------------------------------------------
+	// Create a block that revokes the missed ticket with an automatic
+	// revocation that pays the exact committed amounts.
+	//
+	// The block should be accepted because the automatic revocation is not
+	// subject to script validation once the agenda is active even though
+	// it does not have a signature.
+	//
+	//   ... -> bmiss -> bautorev
+	// ---------------------------------------------------------------------
+
+	g.SetTip("bmiss")
+	outs = g.OldestCoinbaseOuts()
+	g.NextBlock("bautorev", nil, outs[1:], replaceVersions,
+		addRevocations(autoRevocations()))
+	g.SaveTipCoinbaseOuts()
+	g.AcceptTipBlock()
+	g.ExpectTip("bautorev")
+}
+
 // badBlock is an intentionally bad block that should fail the context-less
 // sanity checks.
 var badBlock = wire.MsgBlock{
@@ -0,0 +1,386 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/james-ray/hcd/blockchain/internal/dbnamespace"
+	"github.com/james-ray/hcd/blockchain/stake"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/wire"
+)
+
+// errVerifyInterrupted is returned by VerifyChainState when it is interrupted.
+var errVerifyInterrupted = errors.New("chain state verification interrupted")
+
+// chainVerifier houses the state used to check the chain state buckets.
+type chainVerifier struct {
+	dbTx      database.Tx
+	repair    bool
+	report    stake.VerifyReportFunc
+	interrupt <-chan struct{}
+
+	state     bestChainState
+	mainChain map[chainhash.Hash]int64
+}
+
+// interrupted returns whether or not the verification has been interrupted.
+func (v *chainVerifier) interrupted() bool {
+	select {
+	case <-v.interrupt:
+		return true
+	default:
+		return false
+	}
+}
+
+// deleteKeys reports the passed issue for each of the passed keys of the
+// bucket with the passed name and deletes them when repairing.
+func (v *chainVerifier) deleteKeys(bucketName []byte, keys [][]byte, issue func(k []byte) string) error {
+	bucket := v.dbTx.Metadata().Bucket(bucketName)
+	for _, k := range keys {
+		if !v.repair {
+			v.report(issue(k), false)
+			continue
+		}
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+		v.report(issue(k), true)
+	}
+	return nil
+}
+
+// hashKeyString returns the passed bucket key formatted as a hash when it has
+// the size of one.
+func hashKeyString(k []byte) string {
+	if len(k) != chainhash.HashSize {
+		return fmt.Sprintf("%x", k)
+	}
+	var hash chainhash.Hash
+	copy(hash[:], k)
+	return hash.String()
+}
+
+// verifyMainChain checks every block of the main chain as recorded by the
+// height index.  The block must exist, be linked to the previous one, be
+// recorded at the same height in the hash index, and its spend journal entry
+// must deserialize.  Entries missing from the hash index are restored from the
+// height index when repairing.
+func (v *chainVerifier) verifyMainChain() error {
+	meta := v.dbTx.Metadata()
+	hashIndex := meta.Bucket(dbnamespace.HashIndexBucketName)
+	heightIndex := meta.Bucket(dbnamespace.HeightIndexBucketName)
+	spendBucket := meta.Bucket(dbnamespace.SpendJournalBucketName)
+
+	bestHeight := int64(v.state.height)
+	var parent *hcutil.Block
+	for height := int64(0); height <= bestHeight; height++ {
+		if v.interrupted() {
+			return errVerifyInterrupted
+		}
+		if height > 0 && height%10000 == 0 {
+			log.Infof("Verified %d of %d main chain blocks", height,
+				bestHeight+1)
+		}
+
+		var serializedHeight [4]byte
+		dbnamespace.ByteOrder.PutUint32(serializedHeight[:],
+			uint32(height))
+		hashBytes := heightIndex.Get(serializedHeight[:])
+		if len(hashBytes) != chainhash.HashSize {
+			v.report(fmt.Sprintf("the height index has no valid "+
+				"entry for height %d", height), false)
+			parent = nil
+			continue
+		}
+		var hash chainhash.Hash
+		copy(hash[:], hashBytes)
+		v.mainChain[hash] = height
+
+		if height == bestHeight && hash != v.state.hash {
+			v.report(fmt.Sprintf("the height index has block %v at "+
+				"the best height %d, but the chain state has "+
+				"block %v", hash, height, v.state.hash), false)
+		}
+
+		// Ensure the hash index agrees with the height index.
+		gotHeight := hashIndex.Get(hash[:])
+		if len(gotHeight) != 4 ||
+			dbnamespace.ByteOrder.Uint32(gotHeight) != uint32(height) {
+
+			issue := fmt.Sprintf("the hash index entry for main "+
+				"chain block %v at height %d is missing or wrong",
+				hash, height)
+			if v.repair {
+				err := hashIndex.Put(hash[:], serializedHeight[:])
+				if err != nil {
+					return err
+				}
+			}
+			v.report(issue, v.repair)
+		}
+
+		// Load the block and ensure it is linked to the previous one.
+		blockBytes, err := v.dbTx.FetchBlock(&hash)
+		if err != nil {
+			v.report(fmt.Sprintf("unable to load main chain block %v "+
+				"at height %d: %v", hash, height, err), false)
+			parent = nil
+			continue
+		}
+		block, err := hcutil.NewBlockFromBytes(blockBytes)
+		if err != nil {
+			v.report(fmt.Sprintf("unable to deserialize main chain "+
+				"block %v at height %d: %v", hash, height, err),
+				false)
+			parent = nil
+			continue
+		}
+		header := &block.MsgBlock().Header
+		if *block.Hash() != hash {
+			v.report(fmt.Sprintf("the data of main chain block %v at "+
+				"height %d hashes to %v", hash, height,
+				block.Hash()), false)
+		}
+		if int64(header.Height) != height {
+			v.report(fmt.Sprintf("main chain block %v at height %d "+
+				"has height %d in its header", hash, height,
+				header.Height), false)
+		}
+		if parent != nil && header.PrevBlock != *parent.Hash() {
+			v.report(fmt.Sprintf("main chain block %v at height %d "+
+				"does not connect to block %v at height %d", hash,
+				height, parent.Hash(), height-1), false)
+		}
+
+		// The spend journal entry of a block can only be checked along
+		// with the transactions of its parent that it approves.
+		if height > 0 && parent != nil {
+			var blockTxns []*wire.MsgTx
+			if hcutil.IsFlagSet16(header.VoteBits, hcutil.BlockValid) {
+				blockTxns = append(blockTxns,
+					parent.MsgBlock().Transactions[1:]...)
+			}
+			blockTxns = append(blockTxns,
+				block.MsgBlock().STransactions...)
+			serialized := spendBucket.Get(hash[:])
+			_, err := deserializeSpendJournalEntry(serialized, blockTxns)
+			if err != nil {
+				v.report(fmt.Sprintf("invalid spend journal entry "+
+					"for main chain block %v at height %d: %v",
+					hash, height, err), false)
+			}
+		}
+		parent = block
+	}
+
+	return nil
+}
+
+// verifyIndexes finds the entries of the height and hash indexes which do not
+// belong to the main chain and deletes them when repairing.
+func (v *chainVerifier) verifyIndexes() error {
+	meta := v.dbTx.Metadata()
+	var staleHeights [][]byte
+	heightIndex := meta.Bucket(dbnamespace.HeightIndexBucketName)
+	err := heightIndex.ForEach(func(k, _ []byte) error {
+		if len(k) != 4 || dbnamespace.ByteOrder.Uint32(k) > v.state.height {
+			staleHeights = append(staleHeights, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = v.deleteKeys(dbnamespace.HeightIndexBucketName, staleHeights,
+		func(k []byte) string {
+			if len(k) != 4 {
+				return fmt.Sprintf("the height index has invalid "+
+					"key %x", k)
+			}
+			return fmt.Sprintf("the height index has an entry for "+
+				"height %d after the best height %d",
+				dbnamespace.ByteOrder.Uint32(k), v.state.height)
+		})
+	if err != nil {
+		return err
+	}
+
+	var staleHashes [][]byte
+	hashIndex := meta.Bucket(dbnamespace.HashIndexBucketName)
+	err = hashIndex.ForEach(func(k, _ []byte) error {
+		var hash chainhash.Hash
+		copy(hash[:], k)
+		if _, ok := v.mainChain[hash]; !ok || len(k) != chainhash.HashSize {
+			staleHashes = append(staleHashes, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return v.deleteKeys(dbnamespace.HashIndexBucketName, staleHashes,
+		func(k []byte) string {
+			return fmt.Sprintf("the hash index has an entry for "+
+				"block %s which is not in the main chain",
+				hashKeyString(k))
+		})
+}
+
+// verifySpendJournal finds the spend journal entries of blocks which are not
+// in the main chain and deletes them when repairing.  The entries of the main
+// chain blocks are checked by verifyMainChain.
+func (v *chainVerifier) verifySpendJournal() error {
+	var staleKeys [][]byte
+	spendBucket := v.dbTx.Metadata().Bucket(dbnamespace.SpendJournalBucketName)
+	err := spendBucket.ForEach(func(k, _ []byte) error {
+		var hash chainhash.Hash
+		copy(hash[:], k)
+		if _, ok := v.mainChain[hash]; !ok || len(k) != chainhash.HashSize {
+			staleKeys = append(staleKeys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return v.deleteKeys(dbnamespace.SpendJournalBucketName, staleKeys,
+		func(k []byte) string {
+			return fmt.Sprintf("the spend journal has an entry for "+
+				"block %s which is not in the main chain",
+				hashKeyString(k))
+		})
+}
+
+// verifyUtxoSet checks that every entry of the utxo set deserializes and was
+// created by a block of the main chain.  Fully spent entries, which are
+// normally removed when the last output is spent, are deleted when repairing.
+func (v *chainVerifier) verifyUtxoSet() error {
+	var numEntries int64
+	var spentKeys [][]byte
+	utxoBucket := v.dbTx.Metadata().Bucket(dbnamespace.UtxoSetBucketName)
+	err := utxoBucket.ForEach(func(k, serialized []byte) error {
+		numEntries++
+		if numEntries%1000000 == 0 {
+			if v.interrupted() {
+				return errVerifyInterrupted
+			}
+			log.Infof("Verified %d utxo set entries", numEntries)
+		}
+
+		if len(k) != chainhash.HashSize {
+			v.report(fmt.Sprintf("invalid key %x in the utxo set", k),
+				false)
+			return nil
+		}
+		entry, err := deserializeUtxoEntry(serialized)
+		if err != nil {
+			v.report(fmt.Sprintf("unable to deserialize the utxo "+
+				"set entry for transaction %s: %v",
+				hashKeyString(k), err), false)
+			return nil
+		}
+		if entry.BlockHeight() > int64(v.state.height) {
+			v.report(fmt.Sprintf("the utxo set entry for "+
+				"transaction %s is at height %d after the best "+
+				"height %d", hashKeyString(k), entry.BlockHeight(),
+				v.state.height), false)
+		}
+		if entry.IsFullySpent() {
+			spentKeys = append(spentKeys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Infof("Verified %d utxo set entries", numEntries)
+
+	return v.deleteKeys(dbnamespace.UtxoSetBucketName, spentKeys,
+		func(k []byte) string {
+			return fmt.Sprintf("the utxo set entry for transaction "+
+				"%s is fully spent", hashKeyString(k))
+		})
+}
+
+// VerifyChainState checks the chain state stored in the database for internal
+// consistency.  It checks the best chain state, that every block of the main
+// chain exists and connects to its parent, that the height and hash indexes
+// agree, that the spend journal entries of the main chain deserialize, that
+// the utxo set entries deserialize, and the ticket database.
+//
+// Every inconsistency found is passed to the report function.  When repair is
+// set, inconsistencies that do not require reprocessing the chain, such as
+// index and spend journal entries left behind by disconnected blocks, are
+// repaired.  Repairing requires a writable transaction.
+//
+// The verification stops with an error when the interrupt channel is closed
+// or the database is not a chain database.
+func VerifyChainState(dbTx database.Tx, repair bool, report stake.VerifyReportFunc, interrupt <-chan struct{}) error {
+	dbInfo, err := dbFetchDatabaseInfo(dbTx)
+	if err != nil {
+		return err
+	}
+	if dbInfo == nil {
+		return errors.New("the database does not contain a chain state")
+	}
+	if dbInfo.upgradeStarted {
+		report("the database began an upgrade but failed to complete "+
+			"it", false)
+	}
+
+	meta := dbTx.Metadata()
+	for _, bucketName := range [][]byte{
+		dbnamespace.HashIndexBucketName,
+		dbnamespace.HeightIndexBucketName,
+		dbnamespace.SpendJournalBucketName,
+		dbnamespace.UtxoSetBucketName,
+	} {
+		if meta.Bucket(bucketName) == nil {
+			return fmt.Errorf("the %s bucket does not exist",
+				bucketName)
+		}
+	}
+	serializedState := meta.Get(dbnamespace.ChainStateKeyName)
+	if serializedState == nil {
+		return errors.New("the chain state does not exist")
+	}
+	state, err := deserializeBestChainState(serializedState)
+	if err != nil {
+		return err
+	}
+	log.Infof("Verifying the chain state at block %v (height %d)",
+		state.hash, state.height)
+
+	v := &chainVerifier{
+		dbTx:      dbTx,
+		repair:    repair,
+		report:    report,
+		interrupt: interrupt,
+		state:     state,
+		mainChain: make(map[chainhash.Hash]int64, state.height+1),
+	}
+	if err := v.verifyMainChain(); err != nil {
+		return err
+	}
+	if err := v.verifyIndexes(); err != nil {
+		return err
+	}
+	if err := v.verifySpendJournal(); err != nil {
+		return err
+	}
+	log.Info("Verifying the utxo set")
+	if err := v.verifyUtxoSet(); err != nil {
+		return err
+	}
+	log.Info("Verifying the ticket database")
+	return stake.VerifyTicketDB(dbTx, state.hash, state.height, repair,
+		report)
+}
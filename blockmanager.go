@@ -8,7 +8,6 @@ package main
 
 import (
 	"container/list"
-	"encoding/binary"
 	"fmt"
 	"math/rand"
 	"os"
@@ -18,20 +17,69 @@ import (
 	"time"
 
 	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/bootstrap"
 	"github.com/james-ray/hcd/blockchain/stake"
 	"github.com/james-ray/hcd/chaincfg"
 	"github.com/james-ray/hcd/chaincfg/chainhash"
 	"github.com/james-ray/hcd/database"
 	"github.com/james-ray/hcd/hcutil"
 	"github.com/james-ray/hcd/mempool"
+	"github.com/james-ray/hcd/txscript"
 	"github.com/james-ray/hcd/wire"
 )
 
 const (
-	// minInFlightBlocks is the minimum number of blocks that should be
-	// in the request queue for headers-first mode before requesting
-	// more.
-	minInFlightBlocks = 10
+	// blockDownloadWindow is the maximum number of blocks past the next
+	// block to process that may be requested in headers-first mode.  The
+	// blocks in the window are requested from multiple peers at once and
+	// the window advances as they are processed in order.
+	blockDownloadWindow = 1024
+
+	// maxHeldBlockBytes is the maximum number of bytes of blocks which may
+	// be held in headers-first mode, either because they were received
+	// before the blocks that precede them or because they are still in
+	// flight, in which case they are counted at the maximum block size.
+	// No more blocks are requested once it is reached, other than the next
+	// block to process, so a peer which stalls on that block can't make
+	// the node hold the whole download window in memory.
+	maxHeldBlockBytes = 128 * wire.MaxBlockPayload
+
+	// maxUnprocessedHeaders is the number of headers whose blocks have not
+	// been processed yet at which no more headers are requested from the
+	// sync peer in headers-first mode.  Requesting headers resumes once
+	// enough of the blocks have been processed.  This bounds the memory the
+	// sync peer is able to use with headers which are only checked against
+	// the known checkpoints and difficulty rules.
+	maxUnprocessedHeaders = 4 * wire.MaxBlockHeadersPerMsg
+
+	// maxInFlightBlocksPerPeer is the maximum number of blocks that may be
+	// requested from a single peer at once in headers-first mode.
+	maxInFlightBlocksPerPeer = 16
+
+	// blockStallTimeout is the time a peer has to deliver a block that was
+	// requested in headers-first mode before it is considered stalled and
+	// disconnected.
+	blockStallTimeout = 30 * time.Second
+
+	// headersStallTimeout is the time the sync peer has to respond to a
+	// request for headers before it is considered stalled and
+	// disconnected.
+	headersStallTimeout = time.Minute
+
+	// stallSampleInterval is the interval at which the block manager checks
+	// for stalled peers.
+	stallSampleInterval = 5 * time.Second
+
+	// maxCmpctAnnouncePeers is the maximum number of peers which are asked
+	// to announce new blocks with compact blocks directly (high-bandwidth
+	// mode).
+	maxCmpctAnnouncePeers = 3
+
+	// maxPartialBlocks is the maximum number of blocks announced with
+	// compact blocks which may be waiting on missing transactions at once.
+	// Further compact blocks which can't be reconstructed right away are
+	// downloaded in full instead.
+	maxPartialBlocks = 16
 
 	// blockDbNamePrefix is the prefix for the block database name.  The
 	// database type is appended to this value to form the full block
@@ -93,6 +141,20 @@ type headersMsg struct {
 	peer    *serverPeer
 }
 
+// cmpctBlockMsg packages a hcd cmpctblock message and the peer it came from
+// together so the block handler has access to that information.
+type cmpctBlockMsg struct {
+	cmpctBlock *wire.MsgCmpctBlock
+	peer       *serverPeer
+}
+
+// blockTxnMsg packages a hcd blocktxn message and the peer it came from
+// together so the block handler has access to that information.
+type blockTxnMsg struct {
+	blockTxn *wire.MsgBlockTxn
+	peer     *serverPeer
+}
+
 // donePeerMsg signifies a newly disconnected peer to the block handler.
 type donePeerMsg struct {
 	peer *serverPeer
@@ -208,6 +270,34 @@ type forceReorganizationMsg struct {
 	reply      chan forceReorganizationResponse
 }
 
+// invalidateBlockResponse is a response sent to the reply channel of an
+// invalidateBlockMsg query.
+type invalidateBlockResponse struct {
+	err error
+}
+
+// invalidateBlockMsg is a message type to be sent across the message channel
+// for requesting that a block be marked invalid and the chain be reorganized
+// away from it.
+type invalidateBlockMsg struct {
+	hash  chainhash.Hash
+	reply chan invalidateBlockResponse
+}
+
+// reconsiderBlockResponse is a response sent to the reply channel of a
+// reconsiderBlockMsg query.
+type reconsiderBlockResponse struct {
+	err error
+}
+
+// reconsiderBlockMsg is a message type to be sent across the message channel
+// for requesting that the invalid mark of a block be removed and the chain be
+// reorganized to the best chain.
+type reconsiderBlockMsg struct {
+	hash  chainhash.Hash
+	reply chan reconsiderBlockResponse
+}
+
 // getTopBlockResponse is a response to the request for the block at HEAD of the
 // blockchain. We need to be able to obtain this from blockChain for mining
 // purposes.
@@ -321,11 +411,16 @@ type setParentTemplateMsg struct {
 type setParentTemplateResponse struct {
 }
 
-// headerNode is used as a node in a list of headers that are linked together
-// between checkpoints.
+// headerNode is used as a node in a list of validated headers whose blocks
+// are downloaded and processed in headers-first mode.  The peer is the peer
+// the block was requested from, and the block is set once it has been received
+// and is waiting for its parent to be processed.
 type headerNode struct {
-	height int64
-	hash   *chainhash.Hash
+	height      int64
+	hash        *chainhash.Hash
+	peer        *serverPeer
+	requestTime time.Time
+	block       *hcutil.Block
 }
 
 // chainState tracks the state of the best chain as blocks are inserted.  This
@@ -427,11 +522,32 @@ type blockManager struct {
 	wg                  sync.WaitGroup
 	quit                chan struct{}
 
-	// The following fields are used for headers-first mode.
+	// The following fields are used for headers-first mode.  The header
+	// list holds the validated headers whose blocks have not been
+	// processed yet, in order, and the last header is the most recent
+	// header which new headers must connect to.  The reference header is
+	// the most recent known good header, either a block of the main chain
+	// or a checkpoint, the difficulty of new headers is checked against.
+	// The held block bytes are the size of the blocks in the header list
+	// which were received but not processed yet.
 	headersFirstMode bool
 	headerList       *list.List
-	startHeader      *list.Element
+	headerIndex      map[chainhash.Hash]*list.Element
+	lastHeader       *headerNode
+	refHeader        *wire.BlockHeader
+	headersSynced    bool
+	headersPaused    bool
+	headersRequested time.Time
 	nextCheckpoint   *chaincfg.Checkpoint
+	checkpointHeight int64
+	heldBlockBytes   int
+
+	// The following fields are used for compact block relay.  The partial
+	// blocks are the blocks announced with compact blocks whose missing
+	// transactions have been requested, and the announce peers are the
+	// peers in high-bandwidth mode, least recent first.
+	partialBlocks      map[chainhash.Hash]*partialBlock
+	cmpctAnnouncePeers []*serverPeer
 
 	// lotteryDataBroadcastMutex is a mutex protecting the map
 	// that checks if block lottery data has been broadcasted
@@ -446,19 +562,37 @@ type blockManager struct {
 }
 
 // resetHeaderState sets the headers-first mode state to values appropriate for
-// syncing from a new peer.
+// syncing from a new peer.  Any headers and blocks which have not been
+// processed yet are discarded.
 func (b *blockManager) resetHeaderState(newestHash *chainhash.Hash, newestHeight int64) {
 	b.headersFirstMode = false
+	b.headersSynced = false
+	b.headersPaused = false
+	b.headersRequested = time.Time{}
 	b.headerList.Init()
-	b.startHeader = nil
-
-	// When there is a next checkpoint, add an entry for the latest known
-	// block into the header pool.  This allows the next downloaded header
-	// to prove it links to the chain properly.
-	if b.nextCheckpoint != nil {
-		node := headerNode{height: newestHeight, hash: newestHash}
-		b.headerList.PushBack(&node)
+	b.headerIndex = make(map[chainhash.Hash]*list.Element)
+	b.heldBlockBytes = 0
+	b.nextCheckpoint = b.findNextHeaderCheckpoint(newestHeight)
+	b.checkpointHeight = 0
+
+	// The latest known block is the last header.  This allows the next
+	// downloaded header to prove it links to the chain properly.
+	b.lastHeader = &headerNode{height: newestHeight, hash: newestHash}
+	b.refHeader = b.mainChainHeader(newestHash)
+}
+
+// mainChainHeader returns the header of the passed block of the main chain,
+// which is used as the reference for the difficulty of downloaded headers.  It
+// returns nil when the block can't be loaded, in which case the difficulty of
+// the headers is not checked.
+func (b *blockManager) mainChainHeader(hash *chainhash.Hash) *wire.BlockHeader {
+	block, err := b.chain.FetchBlockByHash(hash)
+	if err != nil {
+		bmgrLog.Warnf("Failed to load block %v to check the difficulty "+
+			"of downloaded headers against: %v", hash, err)
+		return nil
 	}
+	return &block.MsgBlock().Header
 }
 
 // updateChainState updates the chain state associated with the block manager.
@@ -484,6 +618,59 @@ func (b *blockManager) updateChainState(newestHash *chainhash.Hash,
 	b.chainState.curPrevHash = curPrevHash
 }
 
+// updateReorganizedChainState updates the chain state associated with the block
+// manager, along with the stake difficulty notifications and the mempool, after
+// the best chain was reorganized outside of the normal block processing.
+func (b *blockManager) updateReorganizedChainState() {
+	// Query the db for the latest best block since the reorganization
+	// changed it.
+	best := b.chain.BestSnapshot()
+
+	// Fetch the required lottery data.
+	winningTickets, poolSize, finalState, err :=
+		b.chain.LotteryDataForBlock(best.Hash)
+	if err != nil {
+		bmgrLog.Warnf("Failed to get lottery data for block %v: %v",
+			best.Hash, err)
+	}
+
+	// Update registered websocket clients on the current stake difficulty.
+	nextStakeDiff, errSDiff := b.chain.CalcNextRequiredStakeDifficulty()
+	if errSDiff != nil {
+		bmgrLog.Warnf("Failed to get next stake difficulty "+
+			"calculation: %v", errSDiff)
+	}
+	r := b.server.rpcServer
+	if r != nil && errSDiff == nil {
+		r.ntfnMgr.NotifyStakeDifficulty(
+			&StakeDifficultyNtfnData{
+				*best.Hash,
+				best.Height,
+				nextStakeDiff,
+			})
+		b.server.txMemPool.PruneStakeTx(nextStakeDiff, best.Height)
+		b.server.txMemPool.PruneExpiredTx(best.Height)
+	}
+
+	missedTickets, err := b.chain.MissedTickets()
+	if err != nil {
+		bmgrLog.Warnf("Failed to get missed tickets: %v", err)
+	}
+
+	// The blockchain should be updated, so fetch the latest snapshot.
+	best = b.chain.BestSnapshot()
+	curPrevHash := b.chain.BestPrevHash()
+
+	b.updateChainState(best.Hash,
+		best.Height,
+		finalState,
+		uint32(poolSize),
+		nextStakeDiff,
+		winningTickets,
+		missedTickets,
+		curPrevHash)
+}
+
 // findNextHeaderCheckpoint returns the next checkpoint after the passed height.
 // It returns nil when there is not one either because the height is already
 // later than the final checkpoint or some other reason such as disabled
@@ -560,61 +747,50 @@ func (b *blockManager) startSync(peers *list.List) {
 		// to send.
 		b.requestedBlocks = make(map[chainhash.Hash]struct{})
 
-		locator, err := b.chain.LatestBlockLocator()
-		if err != nil {
-			bmgrLog.Errorf("Failed to get block locator for the "+
-				"latest block: %v", err)
-			return
-		}
-
 		bmgrLog.Infof("Syncing to block height %d from peer %v",
 			bestPeer.LastBlock(), bestPeer.Addr())
-
-		// When the current height is less than a known checkpoint we
-		// can use block headers to learn about which blocks comprise
-		// the chain up to the checkpoint and perform less validation
-		// for them.  This is possible since each header contains the
-		// hash of the previous header and a merkle root.  Therefore if
-		// we validate all of the received headers link together
-		// properly and the checkpoint hashes match, we can be sure the
-		// hashes for the blocks in between are accurate.  Further, once
-		// the full blocks are downloaded, the merkle root is computed
-		// and compared against the value in the header which proves the
-		// full block hasn't been tampered with.
-		//
-		// Once we have passed the final checkpoint, or checkpoints are
-		// disabled, use standard inv messages learn about the blocks
-		// and fully validate them.  Finally, regression test mode does
-		// not support the headers-first approach so do normal block
-		// downloads when in regression test mode.
-		if b.nextCheckpoint != nil &&
-			best.Height < b.nextCheckpoint.Height &&
-			!cfg.DisableCheckpoints {
-
-			err := bestPeer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
-			if err != nil {
-				bmgrLog.Errorf("Failed to push getheadermsg for the "+
-					"latest blocks: %v", err)
-				return
-			}
-			b.headersFirstMode = true
-			bmgrLog.Infof("Downloading headers for blocks %d to "+
-				"%d from peer %s", best.Height+1,
-				b.nextCheckpoint.Height, bestPeer.Addr())
-		} else {
-			err := bestPeer.PushGetBlocksMsg(locator, &zeroHash)
-			if err != nil {
-				bmgrLog.Errorf("Failed to push getblocksmsg for the "+
-					"latest blocks: %v", err)
-				return
-			}
-		}
-		b.syncPeer = bestPeer
+		b.syncFromPeer(bestPeer)
 	} else {
 		bmgrLog.Warnf("No sync peer candidates available")
 	}
 }
 
+// syncFromPeer makes the passed peer the sync peer and starts downloading the
+// headers which follow the current best chain from it.
+//
+// The headers are always downloaded and validated before the blocks they
+// describe.  This is possible since each header contains the hash of the
+// previous header and a merkle root.  Therefore if all of the received headers
+// link together properly, have valid proof of work and match the known
+// checkpoints, the hashes of the blocks are accurate, and once the full blocks
+// are downloaded, the merkle roots are computed and compared against the
+// values in the headers which proves the blocks haven't been tampered with.
+// This allows the blocks to be requested from many peers at once while still
+// processing them in order.  Blocks up to the last verified checkpoint receive
+// less validation.
+func (b *blockManager) syncFromPeer(sp *serverPeer) {
+	locator, err := b.chain.LatestBlockLocator()
+	if err != nil {
+		bmgrLog.Errorf("Failed to get block locator for the "+
+			"latest block: %v", err)
+		return
+	}
+
+	best := b.chain.BestSnapshot()
+	b.resetHeaderState(best.Hash, best.Height)
+	b.syncPeer = sp
+	err = sp.PushGetHeadersMsg(locator, &zeroHash)
+	if err != nil {
+		bmgrLog.Errorf("Failed to push getheadermsg for the "+
+			"latest blocks: %v", err)
+		return
+	}
+	b.headersFirstMode = true
+	b.headersRequested = time.Now()
+	bmgrLog.Infof("Downloading headers for blocks after height %d from "+
+		"peer %s", best.Height, sp.Addr())
+}
+
 // isSyncCandidate returns whether or not the peer is a candidate to consider
 // syncing from.
 func (b *blockManager) isSyncCandidate(sp *serverPeer) bool {
@@ -675,12 +851,7 @@ func (b *blockManager) handleNewPeerMsg(peers *list.List, sp *serverPeer) {
 // is invoked from the syncHandler goroutine.
 func (b *blockManager) handleDonePeerMsg(peers *list.List, sp *serverPeer) {
 	// Remove the peer from the list of candidate peers.
-	for e := peers.Front(); e != nil; e = e.Next() {
-		if e.Value == sp {
-			peers.Remove(e)
-			break
-		}
-	}
+	removeSyncCandidate(peers, sp)
 
 	bmgrLog.Infof("Lost peer %s", sp)
 
@@ -698,16 +869,45 @@ func (b *blockManager) handleDonePeerMsg(peers *list.List, sp *serverPeer) {
 		delete(b.requestedBlocks, k)
 	}
 
+	// Forget the blocks being reconstructed from compact blocks announced
+	// by the peer and stop treating it as a high-bandwidth peer.
+	for hash, pb := range b.partialBlocks {
+		if pb.peer == sp {
+			delete(b.partialBlocks, hash)
+		}
+	}
+	for i, p := range b.cmpctAnnouncePeers {
+		if p == sp {
+			b.cmpctAnnouncePeers = append(b.cmpctAnnouncePeers[:i],
+				b.cmpctAnnouncePeers[i+1:]...)
+			break
+		}
+	}
+
 	// Attempt to find a new peer to sync from if the quitting peer is the
-	// sync peer.  Also, reset the headers-first state if in headers-first
-	// mode so
+	// sync peer.  The headers-first state is reset since the headers which
+	// were not processed yet came from the quitting peer.  Otherwise, any
+	// blocks in the download window which were requested from the peer are
+	// requested from the remaining peers.
 	if b.syncPeer != nil && b.syncPeer == sp {
 		b.syncPeer = nil
-		if b.headersFirstMode {
-			best := b.chain.BestSnapshot()
-			b.resetHeaderState(best.Hash, best.Height)
-		}
+		best := b.chain.BestSnapshot()
+		b.resetHeaderState(best.Hash, best.Height)
 		b.startSync(peers)
+		return
+	}
+	b.releaseHeaderBlocks(sp)
+	b.fetchHeaderBlocks(peers)
+}
+
+// removeSyncCandidate removes the passed peer from the list of candidate
+// peers, if it is in the list.
+func removeSyncCandidate(peers *list.List, sp *serverPeer) {
+	for e := peers.Front(); e != nil; e = e.Next() {
+		if e.Value == sp {
+			peers.Remove(e)
+			break
+		}
 	}
 }
 
@@ -771,6 +971,16 @@ func (b *blockManager) handleTxMsg(tmsg *txMsg) {
 		return
 	}
 
+	// Record that the peer relayed a novel transaction, which may be a
+	// vote, so it is protected from eviction.
+	if len(acceptedTxs) > 0 {
+		now := time.Now().UnixNano()
+		atomic.StoreInt64(&tmsg.peer.lastTxTime, now)
+		if stake.DetermineTxType(tmsg.tx.MsgTx()) == stake.TxTypeSSGen {
+			atomic.StoreInt64(&tmsg.peer.lastVoteTime, now)
+		}
+	}
+
 	b.server.AnnounceNewTransactions(acceptedTxs)
 }
 
@@ -975,7 +1185,7 @@ func (b *blockManager) checkBlockForHiddenVotes(block *hcutil.Block) {
 }
 
 // handleBlockMsg handles block messages from all peers.
-func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
+func (b *blockManager) handleBlockMsg(peers *list.List, bmsg *blockMsg) {
 	// If we didn't ask for this block then the peer is misbehaving.
 	blockHash := bmsg.block.Hash()
 	if _, exists := bmsg.peer.requestedBlocks[*blockHash]; !exists {
@@ -1001,40 +1211,44 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	// When in headers-first mode, if the block matches the hash of the
-	// first header in the list of headers that are being fetched, it's
-	// eligible for less validation since the headers have already been
-	// verified to link together and are valid up to the next checkpoint.
-	// Also, remove the list entry for all blocks except the checkpoint
-	// since it is needed to verify the next round of headers links
-	// properly.
-	isCheckpointBlock := false
-	behaviorFlags := blockchain.BFNone
-	if b.headersFirstMode {
-		firstNodeEl := b.headerList.Front()
-		if firstNodeEl != nil {
-			firstNode := firstNodeEl.Value.(*headerNode)
-			if blockHash.IsEqual(firstNode.hash) {
-				behaviorFlags |= blockchain.BFFastAdd
-				if firstNode.hash.IsEqual(b.nextCheckpoint.Hash) {
-					isCheckpointBlock = true
-				} else {
-					b.headerList.Remove(firstNodeEl)
-				}
-			}
-		}
-	}
-
 	// Remove block from request maps. Either chain will know about it and
 	// so we shouldn't have any more instances of trying to fetch it, or we
 	// will fail the insert and thus we'll retry next time we get an inv.
 	delete(bmsg.peer.requestedBlocks, *blockHash)
 	delete(b.requestedBlocks, *blockHash)
 
+	// When in headers-first mode, blocks which are part of the validated
+	// header chain are held until all of their ancestors have been
+	// processed so that they are processed in order.
+	if b.headersFirstMode {
+		if e, exists := b.headerIndex[*blockHash]; exists {
+			node := e.Value.(*headerNode)
+			if node.block != nil {
+				b.heldBlockBytes -= node.block.MsgBlock().SerializeSize()
+			}
+			node.peer = bmsg.peer
+			node.block = bmsg.block
+			b.heldBlockBytes += node.block.MsgBlock().SerializeSize()
+			b.processHeaderBlocks(peers)
+			b.fetchHeaderBlocks(peers)
+			return
+		}
+	}
+
+	b.processBlock(bmsg.block, bmsg.peer, blockchain.BFNone)
+}
+
+// processBlock processes the passed block, which was received from the passed
+// peer, with the provided behavior flags and updates the chain state when it
+// extends the main chain.  A reject message is sent to the peer when the block
+// is rejected and the error is returned.
+func (b *blockManager) processBlock(block *hcutil.Block, sp *serverPeer,
+	flags blockchain.BehaviorFlags) error {
+
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
-	onMainChain, isOrphan, err := b.chain.ProcessBlock(bmsg.block,
-		behaviorFlags)
+	blockHash := block.Hash()
+	onMainChain, isOrphan, err := b.chain.ProcessBlock(block, flags)
 	if err != nil {
 		// When the error is a rule error, it means the block was simply
 		// rejected as opposed to something actually going wrong, so log
@@ -1042,7 +1256,7 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		// it as an actual error.
 		if _, ok := err.(blockchain.RuleError); ok {
 			bmgrLog.Infof("Rejected block %v from %s: %v", blockHash,
-				bmsg.peer, err)
+				sp, err)
 		} else {
 			bmgrLog.Errorf("Failed to process block %v: %v",
 				blockHash, err)
@@ -1055,9 +1269,9 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		// Convert the error into an appropriate reject message and
 		// send it.
 		code, reason := mempool.ErrToRejectErr(err)
-		bmsg.peer.PushRejectMsg(wire.CmdBlock, code, reason,
+		sp.PushRejectMsg(wire.CmdBlock, code, reason,
 			blockHash, false)
-		return
+		return err
 	}
 
 	// Meta-data about the new block this peer is reporting. We use this
@@ -1079,33 +1293,32 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		// block height from the scriptSig of the coinbase transaction.
 		// Extraction is only attempted if the block's version is
 		// high enough (ver 2+).
-		header := &bmsg.block.MsgBlock().Header
+		header := &block.MsgBlock().Header
 		cbHeight := header.Height
 		heightUpdate = int64(cbHeight)
 		blkHashUpdate = blockHash
 
-		orphanRoot := b.chain.GetOrphanRoot(blockHash)
-		locator, err := b.chain.LatestBlockLocator()
-		if err != nil {
-			bmgrLog.Warnf("Failed to get block locator for the "+
-				"latest block: %v", err)
-		} else {
-			err = bmsg.peer.PushGetBlocksMsg(locator, orphanRoot)
-			if err != nil {
-				bmgrLog.Warnf("Failed to push getblocksmsg for the "+
-					"latest block: %v", err)
-			}
+		// Learn about the missing blocks between the best chain and
+		// the orphan by downloading the headers which follow the best
+		// chain from the peer, unless headers are already being
+		// downloaded.
+		if !b.headersFirstMode {
+			b.syncFromPeer(sp)
 		}
 	} else {
-		// When the block is not an orphan, log information about it and
-		// update the chain state.
-		b.progressLogger.logBlockHeight(bmsg.block)
+		// When the block is not an orphan, record that the peer relayed
+		// a novel block so it is protected from eviction, log
+		// information about it and update the chain state.
+		if sp != nil {
+			atomic.StoreInt64(&sp.lastBlockTime, time.Now().UnixNano())
+		}
+		b.progressLogger.logBlockHeight(block)
 		r := b.server.rpcServer
 
 		// Determine if this block is recent enough that we need to calculate
 		// block lottery data for it.
 		_, bestHeight := b.chainState.Best()
-		blockHeight := int64(bmsg.block.MsgBlock().Header.Height)
+		blockHeight := int64(block.MsgBlock().Header.Height)
 		tooOldForLotteryData := blockHeight <=
 			(bestHeight - maxLotteryDataBlockDelta)
 		if !tooOldForLotteryData {
@@ -1115,26 +1328,26 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 			// checkpoint.
 			winningTickets, _, _, err :=
 				b.chain.LotteryDataForBlock(blockHash)
-			if err != nil && int64(bmsg.block.MsgBlock().Header.Height) >=
+			if err != nil && int64(block.MsgBlock().Header.Height) >=
 				b.server.chainParams.StakeValidationHeight-1 {
 				bmgrLog.Errorf("Failed to get next winning tickets: %v", err)
 
 				code, reason := mempool.ErrToRejectErr(err)
-				bmsg.peer.PushRejectMsg(wire.CmdBlock, code, reason,
+				sp.PushRejectMsg(wire.CmdBlock, code, reason,
 					blockHash, false)
-				return
+				return err
 			}
 
 			// Push winning tickets notifications if we need to.
 			winningTicketsNtfn := &WinningTicketsNtfnData{
 				BlockHash:   *blockHash,
-				BlockHeight: int64(bmsg.block.MsgBlock().Header.Height),
+				BlockHeight: int64(block.MsgBlock().Header.Height),
 				Tickets:     winningTickets}
 			b.lotteryDataBroadcastMutex.Lock()
 			_, beenNotified := b.lotteryDataBroadcast[*blockHash]
 			b.lotteryDataBroadcastMutex.Unlock()
 			if !beenNotified && r != nil &&
-				int64(bmsg.block.MsgBlock().Header.Height) >
+				int64(block.MsgBlock().Header.Height) >
 					b.server.chainParams.LatestCheckpointHeight() {
 				r.ntfnMgr.NotifyWinningTickets(winningTicketsNtfn)
 
@@ -1150,8 +1363,8 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 			// validate our parent block. We should bolt these new votes
 			// into the tx tree stake of the old block template on parent.
 			svl := b.server.chainParams.StakeValidationHeight
-			if b.AggressiveMining && bmsg.block.Height() >= svl {
-				b.checkBlockForHiddenVotes(bmsg.block)
+			if b.AggressiveMining && block.Height() >= svl {
+				b.checkBlockForHiddenVotes(block)
 			}
 
 			// Query the db for the latest best block since the block
@@ -1205,6 +1418,12 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 			heightUpdate = best.Height
 			blkHashUpdate = best.Hash
 
+			// The peer was the first to deliver the new best block,
+			// so ask it to announce new blocks directly.
+			if b.current() && best.Hash.IsEqual(blockHash) {
+				b.updateCmpctAnnouncePeers(sp)
+			}
+
 			// Clear the rejected transactions.
 			b.rejectedTxns = make(map[chainhash.Hash]struct{})
 
@@ -1223,83 +1442,358 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 	// chain is "current". This avoids sending a spammy amount of messages
 	// if we're syncing the chain from scratch.
 	if blkHashUpdate != nil && heightUpdate != 0 {
-		bmsg.peer.UpdateLastBlockHeight(heightUpdate)
+		sp.UpdateLastBlockHeight(heightUpdate)
 		if isOrphan || b.current() {
 			go b.server.UpdatePeerHeights(blkHashUpdate, heightUpdate,
-				bmsg.peer)
+				sp)
 		}
 	}
 
-	// Nothing more to do if we aren't in headers-first mode.
-	if !b.headersFirstMode {
+	return nil
+}
+
+// handleCmpctBlockMsg handles cmpctblock messages from all peers.  The block is
+// reconstructed from the prefilled transactions and the mempool and processed
+// when complete.  Otherwise, the missing transactions are requested from the
+// peer.
+func (b *blockManager) handleCmpctBlockMsg(cmsg *cmpctBlockMsg) {
+	sp := cmsg.peer
+	msg := cmsg.cmpctBlock
+	blockHash := msg.Header.BlockHash()
+
+	// Compact blocks are either requested or announced by high-bandwidth
+	// peers.  Other compact blocks are ignored rather than treated as
+	// misbehavior since a peer may announce a block before learning it is
+	// no longer in high-bandwidth mode.
+	_, requested := sp.requestedBlocks[blockHash]
+	if !requested && !b.isCmpctAnnouncePeer(sp) {
+		bmgrLog.Debugf("Ignoring unrequested compact block %v from %s",
+			blockHash, sp)
 		return
 	}
+	delete(sp.requestedBlocks, blockHash)
+	delete(b.requestedBlocks, blockHash)
 
-	// This is headers-first mode, so if the block is not a checkpoint
-	// request more blocks using the header list when the request queue is
-	// getting short.
-	if !isCheckpointBlock {
-		if b.startHeader != nil &&
-			len(bmsg.peer.requestedBlocks) < minInFlightBlocks {
-			b.fetchHeaderBlocks()
-		}
+	// Blocks are only downloaded in full in headers-first mode.
+	if b.headersFirstMode {
 		return
 	}
 
-	// This is headers-first mode and the block is a checkpoint.  When
-	// there is a next checkpoint, get the next round of headers by asking
-	// for headers starting from the block after this one up to the next
-	// checkpoint.
-	prevHeight := b.nextCheckpoint.Height
-	prevHash := b.nextCheckpoint.Hash
-	b.nextCheckpoint = b.findNextHeaderCheckpoint(prevHeight)
-	if b.nextCheckpoint != nil {
-		locator := blockchain.BlockLocator([]*chainhash.Hash{prevHash})
-		err := bmsg.peer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
-		if err != nil {
-			bmgrLog.Warnf("Failed to send getheaders message to "+
-				"peer %s: %v", bmsg.peer.Addr(), err)
+	// Ignore blocks which are already known or being reconstructed.
+	if _, exists := b.partialBlocks[blockHash]; exists {
+		return
+	}
+	haveBlock, err := b.chain.HaveBlock(&blockHash)
+	if err != nil {
+		bmgrLog.Warnf("Unexpected failure when checking for existing "+
+			"block %v: %v", blockHash, err)
+		return
+	}
+	if haveBlock {
+		return
+	}
+
+	// Ensure the header has valid proof of work before spending any effort
+	// reconstructing the block.
+	err = blockchain.CheckHeaderProofOfWork(&msg.Header,
+		b.server.chainParams.PowLimit)
+	if err != nil {
+		bmgrLog.Warnf("Compact block %v from %s failed proof of work "+
+			"check: %v -- disconnecting", blockHash, sp, err)
+		sp.Disconnect()
+		return
+	}
+
+	txDescs := b.server.txMemPool.TxDescs()
+	pool := make([]*hcutil.Tx, 0, len(txDescs))
+	for _, desc := range txDescs {
+		pool = append(pool, desc.Tx)
+	}
+	pb, err := newPartialBlock(msg, pool)
+	if err == errShortIDCollision {
+		bmgrLog.Debugf("Short id collision in compact block %v -- "+
+			"requesting full block from %s", blockHash, sp)
+		b.requestFullBlock(sp, &blockHash)
+		return
+	}
+	if err != nil {
+		bmgrLog.Warnf("Invalid compact block %v from %s: %v -- "+
+			"disconnecting", blockHash, sp, err)
+		sp.Disconnect()
+		return
+	}
+
+	// Request the transactions which could not be found in the mempool.
+	if !pb.complete() {
+		if len(b.partialBlocks) >= maxPartialBlocks {
+			b.requestFullBlock(sp, &blockHash)
 			return
 		}
-		bmgrLog.Infof("Downloading headers for blocks %d to %d from "+
-			"peer %s", prevHeight+1, b.nextCheckpoint.Height,
-			b.syncPeer.Addr())
+		pb.peer = sp
+		b.partialBlocks[blockHash] = pb
+		sp.QueueMessage(wire.NewMsgGetBlockTxn(&blockHash, pb.missing,
+			pb.sMissing), nil)
 		return
 	}
 
-	// This is headers-first mode, the block is a checkpoint, and there are
-	// no more checkpoints, so switch to normal mode by requesting blocks
-	// from the block after this one up to the end of the chain (zero hash).
-	b.headersFirstMode = false
-	b.headerList.Init()
-	bmgrLog.Infof("Reached the final checkpoint -- switching to normal mode")
-	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
-	err = bmsg.peer.PushGetBlocksMsg(locator, &zeroHash)
+	b.processPartialBlock(sp, pb)
+}
+
+// handleBlockTxnMsg handles blocktxn messages from all peers.  The transactions
+// complete a block previously announced with a compact block by the peer.
+func (b *blockManager) handleBlockTxnMsg(bmsg *blockTxnMsg) {
+	sp := bmsg.peer
+	msg := bmsg.blockTxn
+	pb, exists := b.partialBlocks[msg.BlockHash]
+	if !exists || pb.peer != sp {
+		bmgrLog.Debugf("Ignoring unrequested transactions for block "+
+			"%v from %s", msg.BlockHash, sp)
+		return
+	}
+	delete(b.partialBlocks, msg.BlockHash)
+
+	err := pb.fill(msg.Transactions, msg.STransactions)
 	if err != nil {
-		bmgrLog.Warnf("Failed to send getblocks message to peer %s: %v",
-			bmsg.peer.Addr(), err)
+		bmgrLog.Warnf("Invalid transactions for block %v from %s: %v "+
+			"-- disconnecting", msg.BlockHash, sp, err)
+		sp.Disconnect()
 		return
 	}
+
+	b.processPartialBlock(sp, pb)
 }
 
-// fetchHeaderBlocks creates and sends a request to the syncPeer for the next
-// list of blocks to be downloaded based on the current list of headers.
-func (b *blockManager) fetchHeaderBlocks() {
-	// Nothing to do if there is no start header.
-	if b.startHeader == nil {
-		bmgrLog.Warnf("fetchHeaderBlocks called with no start header")
+// processPartialBlock processes a fully reconstructed block which was announced
+// by the passed peer.  The block is requested in full instead when the
+// reconstructed transactions do not match the block header.
+func (b *blockManager) processPartialBlock(sp *serverPeer, pb *partialBlock) {
+	block, err := pb.block()
+	if err != nil {
+		bmgrLog.Debugf("%v -- requesting full block from %s", err, sp)
+		blockHash := pb.header.BlockHash()
+		b.requestFullBlock(sp, &blockHash)
 		return
 	}
 
-	// Build up a getdata request for the list of blocks the headers
-	// describe.  The size hint will be limited to wire.MaxInvPerMsg by
-	// the function, so no need to double check it here.
-	gdmsg := wire.NewMsgGetDataSizeHint(uint(b.headerList.Len()))
-	numRequested := 0
-	for e := b.startHeader; e != nil; e = e.Next() {
-		node, ok := e.Value.(*headerNode)
-		if !ok {
-			bmgrLog.Warn("Header list node type is not a headerNode")
+	b.processBlock(block, sp, blockchain.BFNone)
+}
+
+// requestFullBlock requests the block with the passed hash in full from the
+// peer.  It is used when a block can't be reconstructed from a compact block.
+func (b *blockManager) requestFullBlock(sp *serverPeer, hash *chainhash.Hash) {
+	b.requestedBlocks[*hash] = struct{}{}
+	b.requestedEverBlocks[*hash] = 0
+	b.limitMap(b.requestedBlocks, maxRequestedBlocks)
+	sp.requestedBlocks[*hash] = struct{}{}
+
+	gdmsg := wire.NewMsgGetData()
+	gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, hash))
+	sp.QueueMessage(gdmsg, nil)
+}
+
+// isCmpctAnnouncePeer returns whether or not the passed peer was asked to
+// announce new blocks with compact blocks.
+func (b *blockManager) isCmpctAnnouncePeer(sp *serverPeer) bool {
+	for _, p := range b.cmpctAnnouncePeers {
+		if p == sp {
+			return true
+		}
+	}
+	return false
+}
+
+// updateCmpctAnnouncePeers asks the passed peer, which delivered a new best
+// block first, to announce new blocks with compact blocks directly.  Only the
+// most recent peers to do so are kept in high-bandwidth mode, so the least
+// recent one is asked to stop once there are too many.
+func (b *blockManager) updateCmpctAnnouncePeers(sp *serverPeer) {
+	if !sp.supportsCmpctBlocks() {
+		return
+	}
+
+	// Move a peer which is already in high-bandwidth mode to the back.
+	peers := b.cmpctAnnouncePeers
+	for i, p := range peers {
+		if p == sp {
+			copy(peers[i:], peers[i+1:])
+			peers[len(peers)-1] = sp
+			return
+		}
+	}
+
+	if len(peers) >= maxCmpctAnnouncePeers {
+		peers[0].QueueMessage(wire.NewMsgSendCmpct(false,
+			wire.CmpctBlockVersion), nil)
+		copy(peers, peers[1:])
+		peers = peers[:len(peers)-1]
+	}
+	b.cmpctAnnouncePeers = append(peers, sp)
+	sp.QueueMessage(wire.NewMsgSendCmpct(true, wire.CmpctBlockVersion),
+		nil)
+}
+
+// processHeaderBlocks processes the blocks at the front of the header list in
+// order until a block which has not been received yet is reached.  When a
+// block fails to process, the peer it was received from is disconnected.  If
+// the failure is not due to the block contents not matching its header, the
+// header chain itself leads to an invalid block, so the sync peer is dropped
+// as well and syncing restarts from another peer.
+func (b *blockManager) processHeaderBlocks(peers *list.List) {
+	for e := b.headerList.Front(); e != nil; e = b.headerList.Front() {
+		node := e.Value.(*headerNode)
+		if node.block == nil {
+			// Blocks which are already known, such as those which
+			// were relayed or are in the orphan pool, were not
+			// requested, so there is nothing to wait for.
+			haveBlock, err := b.chain.HaveBlock(node.hash)
+			if err != nil || !haveBlock {
+				break
+			}
+		} else {
+			flags := blockchain.BFNone
+			if node.height <= b.checkpointHeight {
+				flags |= blockchain.BFFastAdd
+			}
+			b.heldBlockBytes -= node.block.MsgBlock().SerializeSize()
+			err := b.processBlock(node.block, node.peer, flags)
+			if err != nil {
+				sender := node.peer
+				bmgrLog.Warnf("Failed to process block %v at height "+
+					"%d from peer %s -- disconnecting", node.hash,
+					node.height, sender.Addr())
+				sender.Disconnect()
+				removeSyncCandidate(peers, sender)
+
+				rErr, ok := err.(blockchain.RuleError)
+				if ok && rErr.ErrorCode == blockchain.ErrBadMerkleRoot &&
+					sender != b.syncPeer {
+
+					// Request the block again from another peer.
+					b.releaseHeaderBlocks(sender)
+					node.peer = nil
+					node.block = nil
+					break
+				}
+
+				if b.syncPeer != nil && b.syncPeer != sender {
+					bmgrLog.Warnf("Header chain from sync peer %s "+
+						"leads to an invalid block -- "+
+						"disconnecting", b.syncPeer.Addr())
+					b.syncPeer.Disconnect()
+					removeSyncCandidate(peers, b.syncPeer)
+				}
+				b.syncPeer = nil
+				best := b.chain.BestSnapshot()
+				b.resetHeaderState(best.Hash, best.Height)
+				b.startSync(peers)
+				return
+			}
+		}
+
+		b.headerList.Remove(e)
+		delete(b.headerIndex, *node.hash)
+	}
+
+	// Resume requesting headers from the sync peer once enough of the
+	// blocks of the headers which caused the requests to be paused have
+	// been processed.
+	if b.headersPaused && b.headerList.Len() <=
+		maxUnprocessedHeaders-wire.MaxBlockHeadersPerMsg {
+
+		b.headersPaused = false
+		b.requestMoreHeaders()
+	}
+
+	// Leave headers-first mode once all of the headers the sync peer knows
+	// about have been received and all of their blocks have been
+	// processed.  Start another round when the sync peer has learned about
+	// more blocks in the mean time.
+	if b.headersSynced && b.headerList.Len() == 0 {
+		best := b.chain.BestSnapshot()
+		b.resetHeaderState(best.Hash, best.Height)
+		bmgrLog.Infof("Processed all blocks of the header chain up to "+
+			"height %d", best.Height)
+		if b.syncPeer != nil && b.syncPeer.LastBlock() > best.Height {
+			b.syncFromPeer(b.syncPeer)
+		}
+	}
+}
+
+// releaseHeaderBlocks releases the requests for the blocks in the header list
+// which were requested from the passed peer and have not been received yet so
+// they can be requested from other peers.
+func (b *blockManager) releaseHeaderBlocks(sp *serverPeer) {
+	for e := b.headerList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*headerNode)
+		if node.peer != sp || node.block != nil {
+			continue
+		}
+		delete(sp.requestedBlocks, *node.hash)
+		delete(b.requestedBlocks, *node.hash)
+		node.peer = nil
+	}
+}
+
+// downloadPeer returns the candidate peer with the fewest blocks in flight
+// which is known to have the block at the passed height and is able to accept
+// more block requests.  It returns nil when there is no such peer.
+func (b *blockManager) downloadPeer(peers *list.List, height int64) *serverPeer {
+	var bestPeer *serverPeer
+	for e := peers.Front(); e != nil; e = e.Next() {
+		sp := e.Value.(*serverPeer)
+		if !sp.Connected() || sp.LastBlock() < height {
+			continue
+		}
+		inFlight := len(sp.requestedBlocks)
+		if inFlight >= maxInFlightBlocksPerPeer {
+			continue
+		}
+		if bestPeer == nil || inFlight < len(bestPeer.requestedBlocks) {
+			bestPeer = sp
+		}
+	}
+	return bestPeer
+}
+
+// fetchHeaderBlocks creates and sends requests for the blocks in the download
+// window, which covers the first blocks of the current list of headers, that
+// have not been requested yet.  The requests are spread across all of the
+// candidate peers which are known to have the blocks.
+func (b *blockManager) fetchHeaderBlocks(peers *list.List) {
+	if !b.headersFirstMode {
+		return
+	}
+
+	// Blocks in flight are counted at the maximum block size against the
+	// limit of bytes of blocks which may be held.
+	available := maxHeldBlockBytes - b.heldBlockBytes
+	for e := b.headerList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*headerNode)
+		if node.peer != nil && node.block == nil {
+			available -= wire.MaxBlockPayload
+		}
+	}
+
+	now := time.Now()
+	requests := make(map[*serverPeer]*wire.MsgGetData)
+	var numInWindow int
+	for e := b.headerList.Front(); e != nil; e = e.Next() {
+		if numInWindow >= blockDownloadWindow {
+			break
+		}
+		numInWindow++
+
+		node := e.Value.(*headerNode)
+		if node.peer != nil || node.block != nil {
+			continue
+		}
+
+		// The next block to process is always requested since nothing
+		// held can be released until it has been received.
+		if available < wire.MaxBlockPayload && e != b.headerList.Front() {
+			break
+		}
+		if _, exists := b.requestedBlocks[*node.hash]; exists {
 			continue
 		}
 
@@ -1311,125 +1805,223 @@ func (b *blockManager) fetchHeaderBlocks() {
 				"fetch: %v", err)
 			continue
 		}
-		if !haveInv {
-			b.requestedBlocks[*node.hash] = struct{}{}
-			b.requestedEverBlocks[*node.hash] = 0
-			b.syncPeer.requestedBlocks[*node.hash] = struct{}{}
-			err = gdmsg.AddInvVect(iv)
-			if err != nil {
-				bmgrLog.Warnf("Failed to add invvect while fetching "+
-					"block headers: %v", err)
-			}
-			numRequested++
+		if haveInv {
+			continue
 		}
-		b.startHeader = e.Next()
-		if numRequested >= wire.MaxInvPerMsg {
-			break
+
+		sp := b.downloadPeer(peers, node.height)
+		if sp == nil {
+			continue
+		}
+		gdmsg, ok := requests[sp]
+		if !ok {
+			gdmsg = wire.NewMsgGetDataSizeHint(maxInFlightBlocksPerPeer)
+			requests[sp] = gdmsg
+		}
+		err = gdmsg.AddInvVect(iv)
+		if err != nil {
+			bmgrLog.Warnf("Failed to add invvect while fetching "+
+				"block headers: %v", err)
+			continue
 		}
+		b.requestedBlocks[*node.hash] = struct{}{}
+		b.requestedEverBlocks[*node.hash] = 0
+		sp.requestedBlocks[*node.hash] = struct{}{}
+		node.peer = sp
+		node.requestTime = now
+		available -= wire.MaxBlockPayload
 	}
-	if len(gdmsg.InvList) > 0 {
-		b.syncPeer.QueueMessage(gdmsg, nil)
+	for sp, gdmsg := range requests {
+		sp.QueueMessage(gdmsg, nil)
+	}
+}
+
+// handleStallSample disconnects the sync peer when it has not responded to a
+// request for headers in time, as well as any peer which has not delivered a
+// block requested in headers-first mode in time.  The blocks requested from
+// the stalled peers are requested from the remaining peers.
+func (b *blockManager) handleStallSample(peers *list.List) {
+	if !b.headersFirstMode {
+		return
 	}
+
+	now := time.Now()
+	if b.syncPeer != nil && !b.headersRequested.IsZero() &&
+		now.Sub(b.headersRequested) > headersStallTimeout {
+
+		bmgrLog.Warnf("Sync peer %s stalled sending headers -- "+
+			"disconnecting", b.syncPeer.Addr())
+		b.headersRequested = time.Time{}
+		b.syncPeer.Disconnect()
+	}
+
+	stalled := make(map[*serverPeer]struct{})
+	for e := b.headerList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*headerNode)
+		if node.peer == nil || node.block != nil {
+			continue
+		}
+		if now.Sub(node.requestTime) > blockStallTimeout {
+			stalled[node.peer] = struct{}{}
+		}
+	}
+	if len(stalled) == 0 {
+		return
+	}
+	for sp := range stalled {
+		bmgrLog.Warnf("Peer %s stalled downloading blocks -- "+
+			"disconnecting", sp.Addr())
+		sp.Disconnect()
+		removeSyncCandidate(peers, sp)
+		b.releaseHeaderBlocks(sp)
+	}
+	b.fetchHeaderBlocks(peers)
+}
+
+// requestMoreHeaders requests the headers which follow the last header from
+// the sync peer.
+func (b *blockManager) requestMoreHeaders() {
+	locator := blockchain.BlockLocator([]*chainhash.Hash{b.lastHeader.hash})
+	err := b.syncPeer.PushGetHeadersMsg(locator, &zeroHash)
+	if err != nil {
+		bmgrLog.Warnf("Failed to send getheaders message to peer %s: %v",
+			b.syncPeer.Addr(), err)
+		return
+	}
+	b.headersRequested = time.Now()
 }
 
 // handleHeadersMsg handles headers messages from all peers.
-func (b *blockManager) handleHeadersMsg(hmsg *headersMsg) {
+func (b *blockManager) handleHeadersMsg(peers *list.List, hmsg *headersMsg) {
 	// The remote peer is misbehaving if we didn't request headers.
 	msg := hmsg.headers
 	numHeaders := len(msg.Headers)
-	if !b.headersFirstMode {
+	if !b.headersFirstMode || hmsg.peer != b.syncPeer ||
+		b.headersRequested.IsZero() {
+
 		bmgrLog.Warnf("Got %d unrequested headers from %s -- "+
 			"disconnecting", numHeaders, hmsg.peer.Addr())
 		hmsg.peer.Disconnect()
 		return
 	}
-
-	// Nothing to do for an empty headers message.
-	if numHeaders == 0 {
-		return
-	}
+	b.headersRequested = time.Time{}
 
 	// Process all of the received headers ensuring each one connects to the
-	// previous and that checkpoints match.
-	receivedCheckpoint := false
-	var finalHash *chainhash.Hash
+	// previous, has valid proof of work, has a difficulty which is sane
+	// compared to the last known good header and that checkpoints match.
+	powLimit := b.server.chainParams.PowLimit
 	for _, blockHeader := range msg.Headers {
 		blockHash := blockHeader.BlockHash()
-		finalHash = &blockHash
 
-		// Ensure there is a previous header to compare against.
-		prevNodeEl := b.headerList.Back()
-		if prevNodeEl == nil {
-			bmgrLog.Warnf("Header list does not contain a previous" +
-				"element as expected -- disconnecting peer")
+		// Ensure the header properly connects to the previous one.  The
+		// first header may instead connect to an earlier block of the
+		// main chain when the peer is on a different chain, in which
+		// case the header chain forks from there.
+		prevNode := b.lastHeader
+		if !prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
+			forkHeight, err := b.chain.BlockHeightByHash(
+				&blockHeader.PrevBlock)
+			onMainChain, _ := b.chain.MainChainHasBlock(
+				&blockHeader.PrevBlock)
+			if b.headerList.Len() != 0 || err != nil || !onMainChain {
+				bmgrLog.Warnf("Received block header that does "+
+					"not properly connect to the chain from "+
+					"peer %s -- disconnecting", hmsg.peer.Addr())
+				hmsg.peer.Disconnect()
+				return
+			}
+			prevNode = &headerNode{height: forkHeight,
+				hash: &blockHeader.PrevBlock}
+			b.nextCheckpoint = b.findNextHeaderCheckpoint(forkHeight)
+			b.refHeader = b.mainChainHeader(&blockHeader.PrevBlock)
+		}
+
+		// Ensure the height and proof of work of the header are valid.
+		height := prevNode.height + 1
+		if int64(blockHeader.Height) != height {
+			bmgrLog.Warnf("Received block header %s from peer %s "+
+				"with height %d instead of %d -- disconnecting",
+				blockHash, hmsg.peer.Addr(), blockHeader.Height,
+				height)
 			hmsg.peer.Disconnect()
 			return
 		}
-
-		// Ensure the header properly connects to the previous one and
-		// add it to the list of headers.
-		node := headerNode{hash: &blockHash}
-		prevNode := prevNodeEl.Value.(*headerNode)
-		if prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
-			node.height = prevNode.height + 1
-			e := b.headerList.PushBack(&node)
-			if b.startHeader == nil {
-				b.startHeader = e
-			}
-		} else {
-			bmgrLog.Warnf("Received block header that does not "+
-				"properly connect to the chain from peer %s "+
-				"-- disconnecting", hmsg.peer.Addr())
+		err := blockchain.CheckHeaderProofOfWork(blockHeader, powLimit)
+		if err != nil {
+			bmgrLog.Warnf("Received block header %s from peer %s "+
+				"with invalid proof of work: %v -- disconnecting",
+				blockHash, hmsg.peer.Addr(), err)
 			hmsg.peer.Disconnect()
 			return
 		}
 
+		// Ensure the claimed difficulty of the header is not lower than
+		// allowed since the last known good header.  This prevents the
+		// sync peer from making us download blocks of a cheap header
+		// chain from other peers, which don't have them.
+		if b.refHeader != nil {
+			err = b.chain.CheckHeaderDifficulty(blockHeader,
+				b.refHeader)
+			if err != nil {
+				bmgrLog.Warnf("Received block header %s from "+
+					"peer %s with invalid difficulty: %v -- "+
+					"disconnecting", blockHash,
+					hmsg.peer.Addr(), err)
+				hmsg.peer.Disconnect()
+				return
+			}
+		}
+
 		// Verify the header at the next checkpoint height matches.
-		if node.height == b.nextCheckpoint.Height {
-			if node.hash.IsEqual(b.nextCheckpoint.Hash) {
-				receivedCheckpoint = true
-				bmgrLog.Infof("Verified downloaded block "+
-					"header against checkpoint at height "+
-					"%d/hash %s", node.height, node.hash)
-			} else {
+		if b.nextCheckpoint != nil && height == b.nextCheckpoint.Height {
+			if !blockHash.IsEqual(b.nextCheckpoint.Hash) {
 				bmgrLog.Warnf("Block header at height %d/hash "+
 					"%s from peer %s does NOT match "+
 					"expected checkpoint hash of %s -- "+
-					"disconnecting", node.height,
-					node.hash, hmsg.peer.Addr(),
-					b.nextCheckpoint.Hash)
+					"disconnecting", height, blockHash,
+					hmsg.peer.Addr(), b.nextCheckpoint.Hash)
 				hmsg.peer.Disconnect()
 				return
 			}
-			break
+			bmgrLog.Infof("Verified downloaded block header against "+
+				"checkpoint at height %d/hash %s", height,
+				blockHash)
+			b.checkpointHeight = height
+			b.nextCheckpoint = b.findNextHeaderCheckpoint(height)
+			b.refHeader = blockHeader
+		}
+
+		// Add the header to the list of headers.
+		node := &headerNode{height: height, hash: &blockHash}
+		b.headerIndex[blockHash] = b.headerList.PushBack(node)
+		b.lastHeader = node
+	}
+
+	// Request the next batch of headers when the peer sent as many as it is
+	// allowed to, since it likely knows about more of them, unless too many
+	// headers are already waiting for their blocks to be processed.
+	// Otherwise, all of the headers the peer knows about have been
+	// received.
+	if numHeaders == wire.MaxBlockHeadersPerMsg {
+		if b.headerList.Len() >= maxUnprocessedHeaders {
+			bmgrLog.Debugf("Pausing header requests at height %d "+
+				"until more blocks are processed",
+				b.lastHeader.height)
+			b.headersPaused = true
+		} else {
+			b.requestMoreHeaders()
 		}
+	} else {
+		b.headersSynced = true
+		bmgrLog.Infof("Received all block headers up to height %d "+
+			"from peer %s", b.lastHeader.height, hmsg.peer.Addr())
 	}
 
-	// When this header is a checkpoint, switch to fetching the blocks for
-	// all of the headers since the last checkpoint.
-	if receivedCheckpoint {
-		// Since the first entry of the list is always the final block
-		// that is already in the database and is only used to ensure
-		// the next header links properly, it must be removed before
-		// fetching the blocks.
-		b.headerList.Remove(b.headerList.Front())
-		bmgrLog.Infof("Received %v block headers: Fetching blocks",
-			b.headerList.Len())
+	if numHeaders > 0 {
 		b.progressLogger.SetLastLogTime(time.Now())
-		b.fetchHeaderBlocks()
-		return
-	}
-
-	// This header is not a checkpoint, so request the next batch of
-	// headers starting from the latest known header and ending with the
-	// next checkpoint.
-	locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
-	err := hmsg.peer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
-	if err != nil {
-		bmgrLog.Warnf("Failed to send getheaders message to "+
-			"peer %s: %v", hmsg.peer.Addr(), err)
-		return
 	}
+	b.fetchHeaderBlocks(peers)
+	b.processHeaderBlocks(peers)
 }
 
 // haveInventory returns whether or not the inventory represented by the passed
@@ -1504,10 +2096,9 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 	}
 
 	// Request the advertised inventory if we don't already have it.  Also,
-	// request parent blocks of orphans if we receive one we already have.
-	// Finally, attempt to detect potential stalls due to long side chains
-	// we already have and request more blocks to prevent them.
-	for i, iv := range invVects {
+	// download the headers leading to orphans if we receive one we already
+	// have.
+	for _, iv := range invVects {
 		// Ignore unsupported inventory types.
 		if iv.Type != wire.InvTypeBlock && iv.Type != wire.InvTypeTx {
 			continue
@@ -1545,50 +2136,13 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 		}
 
 		if iv.Type == wire.InvTypeBlock {
-			// The block is an orphan block that we already have.
-			// When the existing orphan was processed, it requested
-			// the missing parent blocks.  When this scenario
-			// happens, it means there were more blocks missing
-			// than are allowed into a single inventory message.  As
-			// a result, once this peer requested the final
-			// advertised block, the remote peer noticed and is now
-			// resending the orphan block as an available block
-			// to signal there are more missing blocks that need to
-			// be requested.
+			// The block is an orphan block that we already have,
+			// so the peer knows about blocks between the best chain
+			// and the orphan which are still missing.  Download the
+			// headers which follow the best chain from the peer to
+			// learn about them.
 			if b.chain.IsKnownOrphan(&iv.Hash) {
-				// Request blocks starting at the latest known
-				// up to the root of the orphan that just came
-				// in.
-				orphanRoot := b.chain.GetOrphanRoot(&iv.Hash)
-				locator, err := b.chain.LatestBlockLocator()
-				if err != nil {
-					bmgrLog.Errorf("PEER: Failed to get block "+
-						"locator for the latest block: "+
-						"%v", err)
-					continue
-				}
-				err = imsg.peer.PushGetBlocksMsg(locator, orphanRoot)
-				if err != nil {
-					bmgrLog.Errorf("PEER: Failed to push getblocksmsg "+
-						"for orphan chain: %v", err)
-				}
-				continue
-			}
-
-			// We already have the final block advertised by this
-			// inventory message, so force a request for more.  This
-			// should only happen if we're on a really long side
-			// chain.
-			if i == lastBlock {
-				// Request blocks after this one up to the
-				// final one the remote peer knows about (zero
-				// stop hash).
-				locator := b.chain.BlockLocatorFromHash(&iv.Hash)
-				err = imsg.peer.PushGetBlocksMsg(locator, &zeroHash)
-				if err != nil {
-					bmgrLog.Errorf("PEER: Failed to push getblocksmsg: "+
-						"%v", err)
-				}
+				b.syncFromPeer(imsg.peer)
 			}
 		}
 	}
@@ -1606,12 +2160,18 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 		switch iv.Type {
 		case wire.InvTypeBlock:
 			// Request the block if there is not already a pending
-			// request.
+			// request.  New blocks are requested as compact blocks
+			// from peers which support them once the chain is
+			// current.
 			if _, exists := b.requestedBlocks[iv.Hash]; !exists {
 				b.requestedBlocks[iv.Hash] = struct{}{}
 				b.requestedEverBlocks[iv.Hash] = 0
 				b.limitMap(b.requestedBlocks, maxRequestedBlocks)
 				imsg.peer.requestedBlocks[iv.Hash] = struct{}{}
+				if b.current() && imsg.peer.supportsCmpctBlocks() {
+					iv = wire.NewInvVect(wire.InvTypeCmpctBlock,
+						&iv.Hash)
+				}
 				gdmsg.AddInvVect(iv)
 				numRequested++
 			}
@@ -1665,9 +2225,14 @@ func (b *blockManager) limitMap(m map[chainhash.Hash]struct{}, limit int) {
 // the fetching should proceed.
 func (b *blockManager) blockHandler() {
 	candidatePeers := list.New()
+	stallTicker := time.NewTicker(stallSampleInterval)
+	defer stallTicker.Stop()
 out:
 	for {
 		select {
+		case <-stallTicker.C:
+			b.handleStallSample(candidatePeers)
+
 		case m := <-b.msgChan:
 			switch msg := m.(type) {
 			case *newPeerMsg:
@@ -1678,14 +2243,22 @@ out:
 				msg.peer.txProcessed <- struct{}{}
 
 			case *blockMsg:
-				b.handleBlockMsg(msg)
+				b.handleBlockMsg(candidatePeers, msg)
+				msg.peer.blockProcessed <- struct{}{}
+
+			case *cmpctBlockMsg:
+				b.handleCmpctBlockMsg(msg)
+				msg.peer.blockProcessed <- struct{}{}
+
+			case *blockTxnMsg:
+				b.handleBlockTxnMsg(msg)
 				msg.peer.blockProcessed <- struct{}{}
 
 			case *invMsg:
 				b.handleInvMsg(msg)
 
 			case *headersMsg:
-				b.handleHeadersMsg(msg)
+				b.handleHeadersMsg(candidatePeers, msg)
 
 			case *donePeerMsg:
 				b.handleDonePeerMsg(candidatePeers, msg.peer)
@@ -1722,58 +2295,30 @@ out:
 				// Reorganizing has succeeded, so we need to
 				// update the chain state.
 				if err == nil {
-					// Query the db for the latest best block since
-					// the block that was processed could be on a
-					// side chain or have caused a reorg.
-					best := b.chain.BestSnapshot()
-
-					// Fetch the required lottery data.
-					winningTickets, poolSize, finalState, err :=
-						b.chain.LotteryDataForBlock(best.Hash)
+					b.updateReorganizedChainState()
+				}
 
-					// Update registered websocket clients on the
-					// current stake difficulty.
-					nextStakeDiff, errSDiff :=
-						b.chain.CalcNextRequiredStakeDifficulty()
-					if err != nil {
-						bmgrLog.Warnf("Failed to get next stake difficulty "+
-							"calculation: %v", err)
-					}
-					r := b.server.rpcServer
-					if r != nil && errSDiff == nil {
-						r.ntfnMgr.NotifyStakeDifficulty(
-							&StakeDifficultyNtfnData{
-								*best.Hash,
-								best.Height,
-								nextStakeDiff,
-							})
-						b.server.txMemPool.PruneStakeTx(nextStakeDiff,
-							best.Height)
-						b.server.txMemPool.PruneExpiredTx(best.Height)
-					}
+				msg.reply <- forceReorganizationResponse{
+					err: err,
+				}
 
-					missedTickets, err := b.chain.MissedTickets()
-					if err != nil {
-						bmgrLog.Warnf("Failed to get missed tickets"+
-							": %v", err)
-					}
+			case invalidateBlockMsg:
+				err := b.chain.InvalidateBlock(&msg.hash)
+				if err == nil {
+					b.updateReorganizedChainState()
+				}
 
-					// The blockchain should be updated, so fetch the
-					// latest snapshot.
-					best = b.chain.BestSnapshot()
-					curPrevHash := b.chain.BestPrevHash()
+				msg.reply <- invalidateBlockResponse{
+					err: err,
+				}
 
-					b.updateChainState(best.Hash,
-						best.Height,
-						finalState,
-						uint32(poolSize),
-						nextStakeDiff,
-						winningTickets,
-						missedTickets,
-						curPrevHash)
+			case reconsiderBlockMsg:
+				err := b.chain.ReconsiderBlock(&msg.hash)
+				if err == nil {
+					b.updateReorganizedChainState()
 				}
 
-				msg.reply <- forceReorganizationResponse{
+				msg.reply <- reconsiderBlockResponse{
 					err: err,
 				}
 
@@ -2044,7 +2589,7 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 
 		// Generate the inventory vector and relay it.
 		iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
-		b.server.RelayInventory(iv, block.MsgBlock().Header)
+		b.server.RelayInventory(iv, block)
 
 	// A block has been connected to the main block chain.
 	case blockchain.NTBlockConnected:
@@ -2264,6 +2809,30 @@ func (b *blockManager) QueueBlock(block *hcutil.Block, sp *serverPeer) {
 	b.msgChan <- &blockMsg{block: block, peer: sp}
 }
 
+// QueueCmpctBlock adds the passed cmpctblock message and peer to the block
+// handling queue.
+func (b *blockManager) QueueCmpctBlock(msg *wire.MsgCmpctBlock, sp *serverPeer) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		sp.blockProcessed <- struct{}{}
+		return
+	}
+
+	b.msgChan <- &cmpctBlockMsg{cmpctBlock: msg, peer: sp}
+}
+
+// QueueBlockTxn adds the passed blocktxn message and peer to the block handling
+// queue.
+func (b *blockManager) QueueBlockTxn(msg *wire.MsgBlockTxn, sp *serverPeer) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		sp.blockProcessed <- struct{}{}
+		return
+	}
+
+	b.msgChan <- &blockTxnMsg{blockTxn: msg, peer: sp}
+}
+
 // QueueInv adds the passed inv message and peer to the block handling queue.
 func (b *blockManager) QueueInv(inv *wire.MsgInv, sp *serverPeer) {
 	// No channel handling here because peers do not need to block on inv
@@ -2476,6 +3045,27 @@ func (b *blockManager) ForceReorganization(formerBest, newBest chainhash.Hash) e
 	return response.err
 }
 
+// InvalidateBlock marks the block with the passed hash as invalid and
+// reorganizes the chain away from it when it is in the main chain. It is
+// funneled through the block manager since blockchain is not safe for
+// concurrent access.
+func (b *blockManager) InvalidateBlock(hash chainhash.Hash) error {
+	reply := make(chan invalidateBlockResponse)
+	b.msgChan <- invalidateBlockMsg{hash: hash, reply: reply}
+	response := <-reply
+	return response.err
+}
+
+// ReconsiderBlock removes the invalid mark of the block with the passed hash
+// and reorganizes the chain to the best chain. It is funneled through the
+// block manager since blockchain is not safe for concurrent access.
+func (b *blockManager) ReconsiderBlock(hash chainhash.Hash) error {
+	reply := make(chan reconsiderBlockResponse)
+	b.msgChan <- reconsiderBlockMsg{hash: hash, reply: reply}
+	response := <-reply
+	return response.err
+}
+
 // GetGeneration returns the hashes of all the children of a parent for the
 // block hash that is passed to the function. It is funneled through the block
 // manager since blockchain is not safe for concurrent access.
@@ -2593,6 +3183,7 @@ func newBlockManager(s *server, indexManager blockchain.IndexManager) (*blockMan
 		requestedEverTxns:   make(map[chainhash.Hash]uint8),
 		requestedBlocks:     make(map[chainhash.Hash]struct{}),
 		requestedEverBlocks: make(map[chainhash.Hash]uint8),
+		partialBlocks:       make(map[chainhash.Hash]*partialBlock),
 		progressLogger:      newBlockProgressLogger("Processed", bmgrLog),
 		msgChan:             make(chan interface{}, cfg.MaxPeers*3),
 		headerList:          list.New(),
@@ -2615,16 +3206,14 @@ func newBlockManager(s *server, indexManager blockchain.IndexManager) (*blockMan
 	}
 	best := bm.chain.BestSnapshot()
 	bm.chain.DisableCheckpoints(cfg.DisableCheckpoints)
-	if !cfg.DisableCheckpoints {
-		// Initialize the next checkpoint based on the current height.
-		bm.nextCheckpoint = bm.findNextHeaderCheckpoint(best.Height)
-		if bm.nextCheckpoint != nil {
-			bm.resetHeaderState(best.Hash, best.Height)
-		}
-	} else {
+	if cfg.DisableCheckpoints {
 		bmgrLog.Info("Checkpoints are disabled")
 	}
 
+	// Initialize the headers-first state, including the next checkpoint,
+	// based on the current height.
+	bm.resetHeaderState(best.Hash, best.Height)
+
 	// Dump the blockchain here if asked for it, and quit.
 	if cfg.DumpBlockchain != "" {
 		err = dumpBlockChain(bm.chain, best.Height)
@@ -2685,7 +3274,7 @@ func warnMultipleDBs() {
 	// This is intentionally not using the known db types which depend
 	// on the database types compiled into the binary since we want to
 	// detect legacy db types as well.
-	dbTypes := []string{"ffldb", "leveldb", "sqlite"}
+	dbTypes := []string{"ffldb", "bboltdb", "leveldb", "sqlite"}
 	duplicateDbPaths := make([]string, 0, len(dbTypes)-1)
 	for _, dbType := range dbTypes {
 		if dbType == cfg.DbType {
@@ -2756,13 +3345,49 @@ func loadBlockDB() (database.DB, error) {
 		}
 	}
 
+	// Change the compression of newly stored blocks when requested.
+	if cfg.BlockCompression != "" {
+		if err := setBlockCompression(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
 	hcdLog.Info("Block database loaded")
 	return db, nil
 }
 
-// dumpBlockChain dumps a map of the blockchain blocks as serialized bytes.
+// setBlockCompression sets the compression of the blocks stored in the passed
+// database from now on to the one specified in the configuration.  Enabling
+// compression upgrades the chain state of the database to the version older
+// software refuses to open.
+func setBlockCompression(db database.DB) error {
+	compression, err := database.ParseBlockCompression(cfg.BlockCompression)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(dbTx database.Tx) error {
+		compressor, ok := dbTx.(database.BlockCompressor)
+		if !ok {
+			return fmt.Errorf("the %s database backend does not "+
+				"support block compression", cfg.DbType)
+		}
+		if compression != database.BlockCompressionNone {
+			err := blockchain.UpgradeForCompressedBlocks(dbTx)
+			if err != nil {
+				return err
+			}
+		}
+		hcdLog.Infof("Compressing newly stored blocks with %v",
+			compression)
+		return compressor.SetBlockCompression(compression)
+	})
+}
+
+// dumpBlockChain writes the main chain blocks, excluding the genesis block, to a
+// bootstrap file which can be imported with --importbootstrap or addblock.
 func dumpBlockChain(b *blockchain.BlockChain, height int64) error {
-	bmgrLog.Infof("Writing the blockchain to disk as a flat file, " +
+	bmgrLog.Infof("Writing the blockchain to disk as a bootstrap file, " +
 		"please wait...")
 
 	progressLogger := newBlockProgressLogger("Written", bmgrLog)
@@ -2773,48 +3398,112 @@ func dumpBlockChain(b *blockchain.BlockChain, height int64) error {
 	}
 	defer file.Close()
 
-	// Store the network ID in an array for later writing.
-	var net [4]byte
-	binary.LittleEndian.PutUint32(net[:], uint32(activeNetParams.Net))
+	w, err := bootstrap.NewWriter(file, activeNetParams.Net,
+		bootstrap.DefaultChunkSize)
+	if err != nil {
+		return err
+	}
 
 	// Write the blocks sequentially, excluding the genesis block.
-	var sz [4]byte
 	for i := int64(1); i <= height; i++ {
 		bl, err := b.BlockByHeight(i)
 		if err != nil {
 			return err
 		}
-
-		// Serialize the block for writing.
-		blB, err := bl.Bytes()
-		if err != nil {
-			return err
-		}
-
-		// Write the network ID first.
-		_, err = file.Write(net[:])
-		if err != nil {
-			return err
-		}
-
-		// Write the size of the block as a little endian uint32,
-		// then write the block itself serialized.
-		binary.LittleEndian.PutUint32(sz[:], uint32(len(blB)))
-		_, err = file.Write(sz[:])
-		if err != nil {
-			return err
-		}
-
-		_, err = file.Write(blB)
-		if err != nil {
+		if err := w.WriteBlock(bl); err != nil {
 			return err
 		}
 
 		progressLogger.logBlockHeight(bl)
 	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
 
 	bmgrLog.Infof("Successfully dumped the blockchain (%v blocks) to %v.",
 		height, cfg.DumpBlockchain)
 
 	return nil
 }
+
+// importBootstrap imports the blocks from the bootstrap file specified in the
+// configuration to the passed block database.  The optional indexes catch up
+// with the imported blocks when the server starts.
+func importBootstrap(db database.DB, interrupt <-chan struct{}) error {
+	file, err := os.Open(cfg.ImportBootstrap)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	sigCache := txscript.NewSigCache(cfg.SigCacheMaxSize)
+	timeSource := blockchain.NewMedianTime()
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: activeNetParams.Params,
+		TimeSource:  timeSource,
+		SigCache:    sigCache,
+	})
+	if err != nil {
+		return err
+	}
+	chain.DisableCheckpoints(cfg.DisableCheckpoints)
+
+	hcdLog.Infof("Importing blocks from bootstrap file %v",
+		cfg.ImportBootstrap)
+	result, err := bootstrap.Import(file, &bootstrap.ImportConfig{
+		Chain:       chain,
+		ChainParams: activeNetParams.Params,
+		TimeSource:  timeSource,
+		SigCache:    sigCache,
+		Size:        fi.Size(),
+		Interrupt:   interrupt,
+	})
+	if err != nil {
+		if bErr, ok := err.(bootstrap.Error); ok &&
+			bErr.ErrorCode == bootstrap.ErrInterrupted {
+
+			hcdLog.Infof("Bootstrap import interrupted after "+
+				"importing %d blocks -- it resumes when the file "+
+				"is imported again", result.BlocksImported)
+		}
+		return err
+	}
+
+	hcdLog.Infof("Processed a total of %d blocks (%d imported, %d already "+
+		"known) in %v", result.BlocksProcessed, result.BlocksImported,
+		result.BlocksProcessed-result.BlocksImported,
+		result.Duration.Truncate(time.Millisecond))
+	return nil
+}
+
+// reindexChain rebuilds the chain state of the passed block database from the
+// blocks stored in it.  It removes the existing chain state first unless a
+// previously interrupted reindex is being resumed.  The optional indexes catch
+// up with the rebuilt chain when the server starts.
+func reindexChain(db database.DB, interrupt <-chan struct{}) error {
+	if err := blockchain.ResetChainState(db); err != nil {
+		return err
+	}
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: activeNetParams.Params,
+		TimeSource:  blockchain.NewMedianTime(),
+		SigCache:    txscript.NewSigCache(cfg.SigCacheMaxSize),
+	})
+	if err != nil {
+		return err
+	}
+	chain.DisableCheckpoints(cfg.DisableCheckpoints)
+
+	progressLogger := newBlockProgressLogger("Reindexed", bmgrLog)
+	return chain.Reindex(interrupt, progressLogger.logBlockHeight)
+}
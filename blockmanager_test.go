@@ -0,0 +1,587 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/chaingen"
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/peer"
+	"github.com/james-ray/hcd/wire"
+)
+
+// headersFirstHarness houses a block manager backed by a fresh simnet chain
+// along with the peers it downloads from, which are connected to pipes that
+// are never read from so their messages are simply queued.
+type headersFirstHarness struct {
+	t        *testing.T
+	params   *chaincfg.Params
+	bm       *blockManager
+	peers    *list.List
+	conns    []net.Conn
+	teardown func()
+}
+
+// newHeadersFirstHarness returns a new harness with a block manager whose
+// chain only contains the simnet genesis block.
+func newHeadersFirstHarness(t *testing.T) *headersFirstHarness {
+	t.Helper()
+
+	setLogLevels("off")
+	if cfg == nil {
+		cfg = &config{}
+	}
+	params := &chaincfg.SimNetParams
+	dbPath, err := ioutil.TempDir("", "hcdbmgrtest")
+	if err != nil {
+		t.Fatalf("unable to create test db path: %v", err)
+	}
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		os.RemoveAll(dbPath)
+		t.Fatalf("unable to create test db: %v", err)
+	}
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: params,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		db.Close()
+		os.RemoveAll(dbPath)
+		t.Fatalf("unable to create test chain: %v", err)
+	}
+
+	s := &server{chainParams: params, db: db}
+	bm := &blockManager{
+		server:               s,
+		chain:                chain,
+		rejectedTxns:         make(map[chainhash.Hash]struct{}),
+		requestedTxns:        make(map[chainhash.Hash]struct{}),
+		requestedEverTxns:    make(map[chainhash.Hash]uint8),
+		requestedBlocks:      make(map[chainhash.Hash]struct{}),
+		requestedEverBlocks:  make(map[chainhash.Hash]uint8),
+		partialBlocks:        make(map[chainhash.Hash]*partialBlock),
+		progressLogger:       newBlockProgressLogger("Processed", bmgrLog),
+		headerList:           list.New(),
+		lotteryDataBroadcast: make(map[chainhash.Hash]struct{}),
+	}
+	s.blockManager = bm
+	best := chain.BestSnapshot()
+	bm.resetHeaderState(best.Hash, best.Height)
+
+	h := &headersFirstHarness{
+		t:      t,
+		params: params,
+		bm:     bm,
+		peers:  list.New(),
+	}
+	h.teardown = func() {
+		for _, conn := range h.conns {
+			conn.Close()
+		}
+		db.Close()
+		os.RemoveAll(dbPath)
+	}
+	return h
+}
+
+// addPeer returns a new connected peer which claims to have the blocks up to
+// the passed height and adds it to the sync candidates.
+func (h *headersFirstHarness) addPeer(lastBlock int64) *serverPeer {
+	h.t.Helper()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", 18555+h.peers.Len())
+	p, err := peer.NewOutboundPeer(&peer.Config{ChainParams: h.params},
+		addr)
+	if err != nil {
+		h.t.Fatalf("unable to create peer: %v", err)
+	}
+	local, remote := net.Pipe()
+	h.conns = append(h.conns, local, remote)
+	p.AssociateConnection(local)
+	p.UpdateLastBlockHeight(lastBlock)
+
+	sp := newServerPeer(h.bm.server, false)
+	sp.Peer = p
+	h.peers.PushBack(sp)
+	return sp
+}
+
+// addFakeHeaders adds headers for the passed number of blocks which follow the
+// last header to the header list.  The blocks they describe do not exist.
+func (h *headersFirstHarness) addFakeHeaders(num int) {
+	bm := h.bm
+	bm.headersFirstMode = true
+	for i := 0; i < num; i++ {
+		height := bm.lastHeader.height + 1
+		hash := chainhash.Hash{0xff, byte(height), byte(height >> 8)}
+		node := &headerNode{height: height, hash: &hash}
+		bm.headerIndex[hash] = bm.headerList.PushBack(node)
+		bm.lastHeader = node
+	}
+}
+
+// inFlight returns the number of blocks in the header list which are
+// requested from the passed peer and were not received yet.
+func (h *headersFirstHarness) inFlight(sp *serverPeer) int {
+	var n int
+	for e := h.bm.headerList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*headerNode)
+		if node.peer == sp && node.block == nil {
+			n++
+		}
+	}
+	if n != len(sp.requestedBlocks) {
+		h.t.Fatalf("peer %s has %d requested blocks, header list has %d",
+			sp.Addr(), len(sp.requestedBlocks), n)
+	}
+	return n
+}
+
+// solvedHeaders returns a chain of the passed number of headers with valid
+// proof of work at the minimum difficulty which builds on the passed header.
+func solvedHeaders(params *chaincfg.Params, prev *wire.BlockHeader, num int) []*wire.BlockHeader {
+	headers := make([]*wire.BlockHeader, 0, num)
+	for i := 0; i < num; i++ {
+		header := &wire.BlockHeader{
+			Version:   prev.Version,
+			PrevBlock: prev.BlockHash(),
+			Bits:      params.PowLimitBits,
+			Height:    prev.Height + 1,
+			Timestamp: prev.Timestamp.Add(params.TargetTimePerBlock),
+		}
+		for blockchain.CheckHeaderProofOfWork(header, params.PowLimit) != nil {
+			header.Nonce++
+		}
+		headers = append(headers, header)
+		prev = header
+	}
+	return headers
+}
+
+// TestHeadersFirstInFlightLimit ensures the blocks of the header list are
+// requested from all of the peers which have them without exceeding the
+// maximum number of blocks in flight per peer.
+func TestHeadersFirstInFlightLimit(t *testing.T) {
+	h := newHeadersFirstHarness(t)
+	defer h.teardown()
+
+	spA := h.addPeer(100)
+	spB := h.addPeer(100)
+	spShort := h.addPeer(5)
+	h.addFakeHeaders(100)
+	h.bm.fetchHeaderBlocks(h.peers)
+
+	// The peer which only has the first blocks must not be asked for any
+	// blocks after them.
+	for e := h.bm.headerList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*headerNode)
+		if node.peer == spShort && node.height > 5 {
+			t.Fatalf("block at height %d requested from peer with "+
+				"last block 5", node.height)
+		}
+	}
+	for _, sp := range []*serverPeer{spA, spB} {
+		if n := h.inFlight(sp); n != maxInFlightBlocksPerPeer {
+			t.Fatalf("peer %s has %d blocks in flight, want %d",
+				sp.Addr(), n, maxInFlightBlocksPerPeer)
+		}
+	}
+	want := 2*maxInFlightBlocksPerPeer + h.inFlight(spShort)
+	if len(h.bm.requestedBlocks) != want {
+		t.Fatalf("%d blocks requested, want %d",
+			len(h.bm.requestedBlocks), want)
+	}
+
+	// The blocks are requested in order, so the first blocks are all in
+	// flight while the remaining ones wait for a peer to become available.
+	var i int
+	for e := h.bm.headerList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*headerNode)
+		if (i < want) != (node.peer != nil) {
+			t.Fatalf("block at height %d unexpected request state -- "+
+				"peer %v", node.height, node.peer)
+		}
+		i++
+	}
+
+	// Fetching again must not request any more blocks while all of the
+	// peers are at their limit.
+	h.bm.fetchHeaderBlocks(h.peers)
+	if len(h.bm.requestedBlocks) != want {
+		t.Fatalf("%d blocks requested after refetch, want %d",
+			len(h.bm.requestedBlocks), want)
+	}
+}
+
+// TestHeadersFirstHeldBytesLimit ensures no more blocks are requested once the
+// blocks which were received out of order and the blocks in flight reach the
+// limit of bytes of blocks which may be held, other than the next block to
+// process.
+func TestHeadersFirstHeldBytesLimit(t *testing.T) {
+	h := newHeadersFirstHarness(t)
+	defer h.teardown()
+
+	// Create a block which is slightly smaller than the maximum size.
+	tx := wire.NewMsgTx()
+	tx.AddTxOut(wire.NewTxOut(0, make([]byte, wire.MaxBlockPayload-500)))
+	msgBlock := &wire.MsgBlock{Transactions: []*wire.MsgTx{tx}}
+	block := hcutil.NewBlock(msgBlock)
+	size := msgBlock.SerializeSize()
+	if size > wire.MaxBlockPayload || size < wire.MaxBlockPayload-1000 {
+		t.Fatalf("unexpected test block size %d", size)
+	}
+
+	// Hold blocks for all but the first of the first 128 headers, which
+	// leaves room for the first block only.
+	sp := h.addPeer(1000)
+	h.addFakeHeaders(200)
+	held := make([]*headerNode, 0, 127)
+	e := h.bm.headerList.Front()
+	for e = e.Next(); len(held) < cap(held); e = e.Next() {
+		node := e.Value.(*headerNode)
+		node.peer = sp
+		node.block = block
+		h.bm.heldBlockBytes += size
+		held = append(held, node)
+	}
+	h.bm.fetchHeaderBlocks(h.peers)
+	front := h.bm.headerList.Front().Value.(*headerNode)
+	if n := h.inFlight(sp); n != 1 || front.peer != sp {
+		t.Fatalf("%d blocks in flight with the first block requested "+
+			"from %v, want only the first block", n, front.peer)
+	}
+
+	// Processing the last held blocks makes room for as many blocks, which
+	// are requested in order.
+	for _, node := range held[120:] {
+		node.peer = nil
+		node.block = nil
+		h.bm.heldBlockBytes -= size
+	}
+	h.bm.fetchHeaderBlocks(h.peers)
+	if n := h.inFlight(sp); n != 8 {
+		t.Fatalf("%d blocks in flight after processing held blocks, "+
+			"want 8", n)
+	}
+	for _, node := range held[120:] {
+		if node.peer != sp {
+			t.Fatalf("block at height %d was not requested",
+				node.height)
+		}
+	}
+	if next := e.Value.(*headerNode); next.peer != nil {
+		t.Fatalf("block at height %d requested past the limit",
+			next.height)
+	}
+}
+
+// TestHeadersFirstStall ensures peers which stall delivering blocks or headers
+// are disconnected and the blocks requested from them are requested from the
+// remaining peers.
+func TestHeadersFirstStall(t *testing.T) {
+	h := newHeadersFirstHarness(t)
+	defer h.teardown()
+
+	syncPeer := h.addPeer(100)
+	h.bm.syncFromPeer(syncPeer)
+	staller := h.addPeer(100)
+	h.addFakeHeaders(3 * maxInFlightBlocksPerPeer)
+	h.bm.fetchHeaderBlocks(h.peers)
+	if h.inFlight(staller) != maxInFlightBlocksPerPeer ||
+		h.inFlight(syncPeer) != maxInFlightBlocksPerPeer {
+
+		t.Fatal("blocks were not requested from both peers")
+	}
+
+	// Nothing is stalled before the timeouts.
+	h.bm.handleStallSample(h.peers)
+	if !staller.Connected() || !syncPeer.Connected() {
+		t.Fatal("peer disconnected before stalling")
+	}
+
+	// Make the requests to the staller and the sync peer time out, but mark
+	// the requests of the sync peer as just resent.
+	stallTime := time.Now().Add(-blockStallTimeout - time.Second)
+	for e := h.bm.headerList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*headerNode)
+		if node.peer == staller {
+			node.requestTime = stallTime
+		}
+	}
+	h.bm.handleStallSample(h.peers)
+	if staller.Connected() {
+		t.Fatal("stalled peer was not disconnected")
+	}
+	if !syncPeer.Connected() {
+		t.Fatal("sync peer disconnected without stalling")
+	}
+	if h.inFlight(staller) != 0 {
+		t.Fatal("blocks still requested from stalled peer")
+	}
+	for e := h.peers.Front(); e != nil; e = e.Next() {
+		if e.Value == staller {
+			t.Fatal("stalled peer is still a sync candidate")
+		}
+	}
+
+	// The released blocks are requested from a new peer since the sync
+	// peer is already at its limit.
+	replacement := h.addPeer(100)
+	h.bm.fetchHeaderBlocks(h.peers)
+	if h.inFlight(replacement) != maxInFlightBlocksPerPeer {
+		t.Fatalf("replacement peer has %d blocks in flight, want %d",
+			h.inFlight(replacement), maxInFlightBlocksPerPeer)
+	}
+	var i int
+	for e := h.bm.headerList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*headerNode)
+		if (i < 2*maxInFlightBlocksPerPeer) != (node.peer != nil) {
+			t.Fatalf("block at height %d unexpected request state "+
+				"after stall -- peer %v", node.height, node.peer)
+		}
+		i++
+	}
+
+	// The sync peer is disconnected when it does not send the requested
+	// headers in time.
+	h.bm.headersRequested = time.Now().Add(-headersStallTimeout -
+		time.Second)
+	h.bm.handleStallSample(h.peers)
+	if syncPeer.Connected() {
+		t.Fatal("sync peer stalled sending headers was not " +
+			"disconnected")
+	}
+}
+
+// TestHeadersFirstDonePeer ensures the blocks requested from a peer which
+// disconnects are requested from the remaining peers and that losing the sync
+// peer restarts syncing from another peer.
+func TestHeadersFirstDonePeer(t *testing.T) {
+	h := newHeadersFirstHarness(t)
+	defer h.teardown()
+
+	syncPeer := h.addPeer(100)
+	h.bm.syncFromPeer(syncPeer)
+	other := h.addPeer(100)
+	h.addFakeHeaders(3 * maxInFlightBlocksPerPeer)
+	h.bm.fetchHeaderBlocks(h.peers)
+	if h.inFlight(other) != maxInFlightBlocksPerPeer {
+		t.Fatal("blocks were not requested from the other peer")
+	}
+
+	// Losing a peer which is not the sync peer keeps the header list and
+	// requests its blocks from the new peer.
+	other.Disconnect()
+	newPeer := h.addPeer(100)
+	h.bm.handleDonePeerMsg(h.peers, other)
+	if h.bm.syncPeer != syncPeer {
+		t.Fatal("sync peer changed after losing another peer")
+	}
+	if h.bm.headerList.Len() != 3*maxInFlightBlocksPerPeer {
+		t.Fatalf("header list has %d headers after losing a peer",
+			h.bm.headerList.Len())
+	}
+	if h.inFlight(newPeer) != maxInFlightBlocksPerPeer {
+		t.Fatalf("new peer has %d blocks in flight, want %d",
+			h.inFlight(newPeer), maxInFlightBlocksPerPeer)
+	}
+	if h.inFlight(other) != 0 {
+		t.Fatal("blocks still requested from lost peer")
+	}
+
+	// Losing the sync peer discards the headers it sent and starts syncing
+	// from the remaining peer.
+	syncPeer.Disconnect()
+	h.bm.handleDonePeerMsg(h.peers, syncPeer)
+	if h.bm.syncPeer != newPeer {
+		t.Fatalf("sync peer is %v after losing the sync peer, want %v",
+			h.bm.syncPeer, newPeer)
+	}
+	if h.bm.headerList.Len() != 0 || len(h.bm.headerIndex) != 0 {
+		t.Fatal("headers of the lost sync peer were not discarded")
+	}
+	if !h.bm.headersFirstMode || h.bm.headersRequested.IsZero() {
+		t.Fatal("headers were not requested from the new sync peer")
+	}
+}
+
+// TestHeadersFirstInOrder ensures blocks received out of order in
+// headers-first mode are held until their parents have been processed and are
+// then processed in order.
+func TestHeadersFirstInOrder(t *testing.T) {
+	h := newHeadersFirstHarness(t)
+	defer h.teardown()
+
+	g, err := chaingen.MakeGenerator(h.params)
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	blocks := []*wire.MsgBlock{g.CreatePremineBlock("bp", 0)}
+	for i := 2; i <= 5; i++ {
+		blocks = append(blocks, g.NextBlock(fmt.Sprintf("b%d", i), nil,
+			nil))
+		g.SaveTipCoinbaseOuts()
+	}
+
+	syncPeer := h.addPeer(int64(len(blocks)))
+	h.bm.syncFromPeer(syncPeer)
+	h.addPeer(int64(len(blocks)))
+	headers := wire.NewMsgHeaders()
+	for _, block := range blocks {
+		headers.AddBlockHeader(&block.Header)
+	}
+	h.bm.handleHeadersMsg(h.peers, &headersMsg{headers, syncPeer})
+	if !syncPeer.Connected() {
+		t.Fatal("sync peer disconnected after sending valid headers")
+	}
+	if !h.bm.headersSynced || h.bm.headerList.Len() != len(blocks) {
+		t.Fatalf("header list has %d headers, want %d",
+			h.bm.headerList.Len(), len(blocks))
+	}
+
+	deliver := func(i int) {
+		t.Helper()
+		block := hcutil.NewBlock(blocks[i])
+		e, ok := h.bm.headerIndex[*block.Hash()]
+		if !ok {
+			t.Fatalf("block %d is not in the header list", i)
+		}
+		sp := e.Value.(*headerNode).peer
+		if sp == nil {
+			t.Fatalf("block %d was not requested", i)
+		}
+		h.bm.handleBlockMsg(h.peers, &blockMsg{block: block, peer: sp})
+	}
+	wantHeight := func(height int64) {
+		t.Helper()
+		best := h.bm.chain.BestSnapshot()
+		if best.Height != height {
+			t.Fatalf("best height is %d, want %d", best.Height, height)
+		}
+	}
+
+	// Blocks whose parents have not been processed yet are held.
+	deliver(4)
+	deliver(1)
+	wantHeight(0)
+	if h.bm.headerList.Len() != len(blocks) {
+		t.Fatal("held blocks were removed from the header list")
+	}
+
+	// Processing the first block also processes the held block following
+	// it, but not the one after the next missing block.
+	deliver(0)
+	wantHeight(2)
+	deliver(3)
+	wantHeight(2)
+	deliver(2)
+	wantHeight(5)
+
+	// Headers-first mode ends once all of the blocks are processed.
+	if h.bm.headersFirstMode || h.bm.headerList.Len() != 0 {
+		t.Fatal("still in headers-first mode after processing all blocks")
+	}
+	if !syncPeer.Connected() {
+		t.Fatal("sync peer disconnected after sync")
+	}
+}
+
+// TestHeadersFirstHeaderLimits ensures headers with too low difficulty and
+// unrequested headers are rejected and that headers are no longer requested
+// while too many of them are waiting for their blocks.
+func TestHeadersFirstHeaderLimits(t *testing.T) {
+	h := newHeadersFirstHarness(t)
+	defer h.teardown()
+
+	genesis := &h.params.GenesisBlock.Header
+	headers := solvedHeaders(h.params, genesis,
+		maxUnprocessedHeaders+wire.MaxBlockHeadersPerMsg+1)
+	sendHeaders := func(sp *serverPeer, headers []*wire.BlockHeader) {
+		msg := wire.NewMsgHeaders()
+		msg.Headers = headers
+		h.bm.handleHeadersMsg(h.peers, &headersMsg{msg, sp})
+	}
+
+	// Headers which claim a lower difficulty than allowed since the
+	// reference header get the sync peer disconnected.
+	syncPeer := h.addPeer(int64(len(headers)))
+	h.bm.syncFromPeer(syncPeer)
+	refHeader := *h.bm.refHeader
+	refHeader.Bits = 0x1d00ffff
+	h.bm.refHeader = &refHeader
+	sendHeaders(syncPeer, headers[:10])
+	if syncPeer.Connected() {
+		t.Fatal("sync peer sending low difficulty headers was not " +
+			"disconnected")
+	}
+	if h.bm.headerList.Len() != 0 {
+		t.Fatal("low difficulty headers were added to the header list")
+	}
+
+	// Headers are requested until the limit is reached.
+	syncPeer = h.addPeer(int64(len(headers)))
+	h.bm.syncFromPeer(syncPeer)
+	for i := 0; i < maxUnprocessedHeaders; i += wire.MaxBlockHeadersPerMsg {
+		if h.bm.headersRequested.IsZero() {
+			t.Fatalf("headers not requested with %d headers", i)
+		}
+		sendHeaders(syncPeer, headers[i:i+wire.MaxBlockHeadersPerMsg])
+		if !syncPeer.Connected() {
+			t.Fatalf("sync peer disconnected after %d headers", i)
+		}
+	}
+	if !h.bm.headersPaused || !h.bm.headersRequested.IsZero() {
+		t.Fatal("headers still requested with too many unprocessed " +
+			"headers")
+	}
+	if h.bm.headerList.Len() != maxUnprocessedHeaders {
+		t.Fatalf("header list has %d headers, want %d",
+			h.bm.headerList.Len(), maxUnprocessedHeaders)
+	}
+
+	// Requests resume once the blocks of a batch of headers have been
+	// processed.
+	for i := 0; i < wire.MaxBlockHeadersPerMsg-1; i++ {
+		e := h.bm.headerList.Front()
+		delete(h.bm.headerIndex, *e.Value.(*headerNode).hash)
+		h.bm.headerList.Remove(e)
+	}
+	h.bm.processHeaderBlocks(h.peers)
+	if !h.bm.headersPaused {
+		t.Fatal("header requests resumed too early")
+	}
+	e := h.bm.headerList.Front()
+	delete(h.bm.headerIndex, *e.Value.(*headerNode).hash)
+	h.bm.headerList.Remove(e)
+	h.bm.processHeaderBlocks(h.peers)
+	if h.bm.headersPaused || h.bm.headersRequested.IsZero() {
+		t.Fatal("header requests did not resume")
+	}
+
+	// Headers which were not requested get the sync peer disconnected even
+	// though they connect to the last header.
+	sendHeaders(syncPeer, headers[maxUnprocessedHeaders:len(headers)-1])
+	if !syncPeer.Connected() {
+		t.Fatal("sync peer disconnected after sending requested headers")
+	}
+	sendHeaders(syncPeer, headers[len(headers)-1:])
+	if syncPeer.Connected() {
+		t.Fatal("sync peer sending unrequested headers was not " +
+			"disconnected")
+	}
+}
@@ -58,4 +58,9 @@
 // non-standard network.  As a general rule of thumb, all network parameters
 // should be unique to the network, but parameter collisions can still occur
 // (unfortunately, this is the case with regtest and testnet sharing magics).
+//
+// The parameters of such a non-standard network may also be read from a JSON
+// file with ParamsFromFile, which constructs the genesis block of the network
+// and checks the parameters for consistency.  The returned parameters
+// must be registered with Register before they are used.
 package chaincfg
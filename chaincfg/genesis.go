@@ -269,3 +269,36 @@ var simNetGenesisBlock = wire.MsgBlock{
 // simNetGenesisHash is the hash of the first block in the block chain for the
 // simulation test network.
 var simNetGenesisHash = simNetGenesisBlock.BlockHash()
+
+// RegNet -------------------------------------------------------------------------
+
+// regNetGenesisBlock defines the genesis block of the block chain which serves
+// as the public transaction ledger for the regression test network.  It only
+// differs from the simulation test network genesis block by its timestamp so
+// the two networks have distinct genesis hashes.
+var regNetGenesisBlock = wire.MsgBlock{
+	Header: wire.BlockHeader{
+		Version:      1,
+		PrevBlock:    chainhash.Hash{},
+		MerkleRoot:   simNetGenesisMerkleRoot,
+		StakeRoot:    chainhash.Hash{},
+		VoteBits:     0,
+		FinalState:   [6]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		Voters:       0,
+		FreshStake:   0,
+		Revocations:  0,
+		Timestamp:    time.Unix(1538524800, 0), // 2018-10-03 00:00:00 +0000 UTC
+		PoolSize:     0,
+		Bits:         0x207fffff, // 545259519
+		SBits:        0,
+		Nonce:        0,
+		StakeVersion: 0,
+		Height:       0,
+	},
+	Transactions:  []*wire.MsgTx{&regTestGenesisCoinbaseTx},
+	STransactions: []*wire.MsgTx{},
+}
+
+// regNetGenesisHash is the hash of the first block in the block chain for the
+// regression test network.
+var regNetGenesisHash = regNetGenesisBlock.BlockHash()
@@ -135,3 +135,46 @@ func TestSimNetGenesisBlock(t *testing.T) {
 			spew.Sdump(SimNetParams.GenesisHash))
 	}
 }
+
+// TestRegNetGenesisBlock tests the genesis block of the regression test network
+// for validity by checking the encoded bytes and hashes.
+func TestRegNetGenesisBlock(t *testing.T) {
+	// Encode the genesis block to raw bytes.
+	var buf bytes.Buffer
+	err := RegNetParams.GenesisBlock.Serialize(&buf)
+	if err != nil {
+		t.Fatalf("TestRegNetGenesisBlock: %v", err)
+	}
+
+	regNetGenesisBlockBytes, _ := hex.DecodeString("0100000000000000000" +
+		"00000000000000000000000000000000000000000000000000000f17b0b30" +
+		"e3f3b0e5e0296332d3195cdcd73e20fc284652a3cac6059b3012e92300000" +
+		"0000000000000000000000000000000000000000000000000000000000000" +
+		"000000000000000000000000000000ffff7f2000000000000000000000000" +
+		"0000000008006b45b00000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000101000000010000000000000" +
+		"000000000000000000000000000000000000000000000000000ffffffff00" +
+		"ffffffff0100000000000000000000434104678afdb0fe5548271967f1a67" +
+		"130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504" +
+		"e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000000000000" +
+		"1000000000000000000000000000000004d04ffff001d0104455468652054" +
+		"696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e2" +
+		"06272696e6b206f66207365636f6e64206261696c6f757420666f72206261" +
+		"6e6b7300")
+
+	// Ensure the encoded block matches the expected bytes.
+	if !bytes.Equal(buf.Bytes(), regNetGenesisBlockBytes) {
+		t.Fatalf("TestRegNetGenesisBlock: Genesis block does not "+
+			"appear valid - got %v, want %v",
+			spew.Sdump(buf.Bytes()),
+			spew.Sdump(regNetGenesisBlockBytes))
+	}
+
+	// Check hash of the block against expected hash.
+	hash := RegNetParams.GenesisBlock.BlockHash()
+	if !RegNetParams.GenesisHash.IsEqual(&hash) {
+		t.Fatalf("TestRegNetGenesisBlock: Genesis block hash does "+
+			"not appear valid - got %v, want %v", spew.Sdump(hash),
+			spew.Sdump(RegNetParams.GenesisHash))
+	}
+}
@@ -146,7 +146,7 @@ func validateDeployments(deployments []ConsensusDeployment) (int, error) {
 }
 
 func validateAgendas() {
-	for i := 0; i < 3; i++ {
+	for i := 0; i < 4; i++ {
 		var params Params
 		switch i {
 		case 0:
@@ -155,6 +155,8 @@ func validateAgendas() {
 			params = TestNet2Params
 		case 2:
 			params = SimNetParams
+		case 3:
+			params = RegNetParams
 		default:
 			panic("invalid net")
 		}
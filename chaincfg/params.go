@@ -37,6 +37,10 @@ var (
 	// can have for the simulation test network.  It is the value 2^255 - 1.
 	simNetPowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 255), bigOne)
 
+	// regNetPowLimit is the highest proof of work value a Hcd block
+	// can have for the regression test network.  It is the value 2^255 - 1.
+	regNetPowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 255), bigOne)
+
 	VoteBitsNotFound = fmt.Errorf("vote bits not found")
 )
 
@@ -161,6 +165,10 @@ const (
 	// VoteIDMaxBlockSize is the vote ID for the the maximum block size
 	// increase agenda used for the hard fork demo.
 	VoteIDMaxBlockSize = "maxblocksize"
+
+	// VoteIDAutoRevocations is the vote ID for the agenda which requires
+	// blocks to revoke the tickets missed or expired by their parent.
+	VoteIDAutoRevocations = "autorevocations"
 )
 
 // ConsensusDeployment defines details related to a specific consensus rule
@@ -358,6 +366,12 @@ type Params struct {
 	HDPrivateKeyID [4]byte
 	HDPublicKeyID  [4]byte
 
+	// BIP32-style hierarchical deterministic extended key magics for BLISS
+	// account keys.  These are kept distinct from the secp256k1 magics so
+	// the much larger BLISS key data can be identified when decoding.
+	HDBlissPrivateKeyID [4]byte
+	HDBlissPublicKeyID  [4]byte
+
 	// BIP44 coin type used in the hierarchical deterministic path for
 	// address generation.
 	HDCoinType uint32
@@ -499,7 +513,13 @@ var MainNetParams = Params{
 	RuleChangeActivationMultiplier: 3,    // 75%
 	RuleChangeActivationDivisor:    4,
 	RuleChangeActivationInterval:   2016 * 4, // 4 weeks
-	Deployments:                    map[uint32][]ConsensusDeployment{},
+
+	// No agendas are defined for the main network since its blocks are
+	// still generated with version 0, so no deployment could ever leave
+	// the defined state.  Rule changes such as the automatic ticket
+	// revocations agenda are only voted on the test networks until the
+	// main network block version is upgraded.
+	Deployments: map[uint32][]ConsensusDeployment{},
 
 	// Enforce current block version once majority of the network has
 	// upgraded.
@@ -529,6 +549,10 @@ var MainNetParams = Params{
 	HDPrivateKeyID: [4]byte{0x02, 0xfd, 0xa4, 0xe8}, // starts with dprv
 	HDPublicKeyID:  [4]byte{0x02, 0xfd, 0xa9, 0x26}, // starts with dpub
 
+	// BIP32-style hierarchical deterministic BLISS extended key magics
+	HDBlissPrivateKeyID: [4]byte{0x02, 0xfd, 0xb0, 0x51},
+	HDBlissPublicKeyID:  [4]byte{0x02, 0xfd, 0xb4, 0x8f},
+
 	// BIP44 coin type used in the hierarchical deterministic path for
 	// address generation.
 	HDCoinType: uint32(171),
@@ -641,6 +665,34 @@ var TestNet2Params = Params{
 			StartTime:  0,             // Always available for vote
 			ExpireTime: math.MaxInt64, // Never expires
 		}},
+		8: {{
+			Vote: Vote{
+				Id:          VoteIDAutoRevocations,
+				Description: "Require blocks to revoke the tickets missed or expired by the previous block",
+				Mask:        0x0006, // Bits 1 and 2
+				Choices: []Choice{{
+					Id:          "abstain",
+					Description: "abstain voting for change",
+					Bits:        0x0000,
+					IsAbstain:   true,
+					IsNo:        false,
+				}, {
+					Id:          "no",
+					Description: "keep revoking tickets manually",
+					Bits:        0x0002, // Bit 1
+					IsAbstain:   false,
+					IsNo:        true,
+				}, {
+					Id:          "yes",
+					Description: "revoke missed and expired tickets automatically",
+					Bits:        0x0004, // Bit 2
+					IsAbstain:   false,
+					IsNo:        false,
+				}},
+			},
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		}},
 	},
 
 	// Enforce current block version once majority of the network has
@@ -671,6 +723,10 @@ var TestNet2Params = Params{
 	HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x97}, // starts with tprv
 	HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xd1}, // starts with tpub
 
+	// BIP32-style hierarchical deterministic BLISS extended key magics
+	HDBlissPrivateKeyID: [4]byte{0x04, 0x35, 0x8e, 0x52},
+	HDBlissPublicKeyID:  [4]byte{0x04, 0x35, 0x92, 0x8c},
+
 	// BIP44 coin type used in the hierarchical deterministic path for
 	// address generation.
 	HDCoinType: uint32(171),
@@ -783,6 +839,34 @@ var SimNetParams = Params{
 			StartTime:  0,             // Always available for vote
 			ExpireTime: math.MaxInt64, // Never expires
 		}},
+		8: {{
+			Vote: Vote{
+				Id:          VoteIDAutoRevocations,
+				Description: "Require blocks to revoke the tickets missed or expired by the previous block",
+				Mask:        0x0006, // Bits 1 and 2
+				Choices: []Choice{{
+					Id:          "abstain",
+					Description: "abstain voting for change",
+					Bits:        0x0000,
+					IsAbstain:   true,
+					IsNo:        false,
+				}, {
+					Id:          "no",
+					Description: "keep revoking tickets manually",
+					Bits:        0x0002, // Bit 1
+					IsAbstain:   false,
+					IsNo:        true,
+				}, {
+					Id:          "yes",
+					Description: "revoke missed and expired tickets automatically",
+					Bits:        0x0004, // Bit 2
+					IsAbstain:   false,
+					IsNo:        false,
+				}},
+			},
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		}},
 	},
 
 	// Enforce current block version once majority of the network has
@@ -813,6 +897,10 @@ var SimNetParams = Params{
 	HDPrivateKeyID: [4]byte{0x04, 0x20, 0xb9, 0x03}, // starts with sprv
 	HDPublicKeyID:  [4]byte{0x04, 0x20, 0xbd, 0x3d}, // starts with spub
 
+	// BIP32-style hierarchical deterministic BLISS extended key magics
+	HDBlissPrivateKeyID: [4]byte{0x04, 0x20, 0xc4, 0x6e},
+	HDBlissPublicKeyID:  [4]byte{0x04, 0x20, 0xc8, 0xa8},
+
 	// BIP44 coin type used in the hierarchical deterministic path for
 	// address generation.
 	HDCoinType: uint32(171), // ASCII for s
@@ -873,12 +961,194 @@ var SimNetParams = Params{
 	OmniStartHeight:             46000,
 }
 
+// RegNetParams defines the network parameters for the regression test Hcd
+// network.  Not to be confused with the simulation test network, this network
+// is intended for automated integration tests such as those driven by the
+// rpctest harness.  It uses the minimum difficulty, has no premine and uses
+// tiny ticket pool, maturity and stake validation parameters so that blocks
+// can be generated on demand and the chain reaches stake validation height in
+// a matter of seconds.  Every consensus rule change agenda is available for
+// voting immediately and never expires.
+var RegNetParams = Params{
+	Name:        "regnet",
+	Net:         wire.RegTest,
+	DefaultPort: "15008",
+	DNSSeeds:    []string{}, // NOTE: There must NOT be any seeds.
+
+	// Chain parameters
+	GenesisBlock:             &regNetGenesisBlock,
+	GenesisHash:              &regNetGenesisHash,
+	PowLimit:                 regNetPowLimit,
+	PowLimitBits:             0x207fffff,
+	ReduceMinDifficulty:      false,
+	MinDiffReductionTime:     0, // Does not apply since ReduceMinDifficulty false
+	GenerateSupported:        true,
+	MaximumBlockSizes:        []int{1000000, 1310720},
+	MaxTxSize:                2048000,
+	TargetTimePerBlock:       time.Second,
+	WorkDiffAlpha:            1,
+	WorkDiffWindowSize:       8,
+	WorkDiffWindows:          4,
+	TargetTimespan:           time.Second * 8, // TimePerBlock * WindowSize
+	RetargetAdjustmentFactor: 4,
+
+	// Subsidy parameters.
+	BaseSubsidy:              640000000,
+	MulSubsidy:               999,
+	DivSubsidy:               1000,
+	SubsidyReductionInterval: 128,
+	WorkRewardProportion:     6,
+	StakeRewardProportion:    3,
+	BlockTaxProportion:       1,
+
+	// Checkpoints ordered from oldest to newest.
+	Checkpoints: nil,
+
+	// Consensus rule change deployments.
+	//
+	// The miner confirmation window is defined as:
+	//   target proof of work timespan / target proof of work spacing
+	RuleChangeActivationQuorum:     24, // 10 % of RuleChangeActivationInterval * TicketsPerBlock
+	RuleChangeActivationMultiplier: 3,  // 75%
+	RuleChangeActivationDivisor:    4,
+	RuleChangeActivationInterval:   48, // 2 * StakeVersionInterval
+	Deployments: map[uint32][]ConsensusDeployment{
+		7: {{
+			Vote: Vote{
+				Id:          VoteIDMaxBlockSize,
+				Description: "Change maximum allowed block size from 1MiB to 1.25MB",
+				Mask:        0x0006, // Bits 1 and 2
+				Choices: []Choice{{
+					Id:          "abstain",
+					Description: "abstain voting for change",
+					Bits:        0x0000,
+					IsAbstain:   true,
+					IsNo:        false,
+				}, {
+					Id:          "no",
+					Description: "reject changing max allowed block size",
+					Bits:        0x0002, // Bit 1
+					IsAbstain:   false,
+					IsNo:        true,
+				}, {
+					Id:          "yes",
+					Description: "accept changing max allowed block size",
+					Bits:        0x0004, // Bit 2
+					IsAbstain:   false,
+					IsNo:        false,
+				}},
+			},
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		}},
+		8: {{
+			Vote: Vote{
+				Id:          VoteIDAutoRevocations,
+				Description: "Require blocks to revoke the tickets missed or expired by the previous block",
+				Mask:        0x0006, // Bits 1 and 2
+				Choices: []Choice{{
+					Id:          "abstain",
+					Description: "abstain voting for change",
+					Bits:        0x0000,
+					IsAbstain:   true,
+					IsNo:        false,
+				}, {
+					Id:          "no",
+					Description: "keep revoking tickets manually",
+					Bits:        0x0002, // Bit 1
+					IsAbstain:   false,
+					IsNo:        true,
+				}, {
+					Id:          "yes",
+					Description: "revoke missed and expired tickets automatically",
+					Bits:        0x0004, // Bit 2
+					IsAbstain:   false,
+					IsNo:        false,
+				}},
+			},
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		}},
+	},
+
+	// Enforce current block version once majority of the network has
+	// upgraded.
+	// 51% (51 / 100)
+	// Reject previous block versions once a majority of the network has
+	// upgraded.
+	// 75% (75 / 100)
+	BlockEnforceNumRequired: 51,
+	BlockRejectNumRequired:  75,
+	BlockUpgradeNumToCheck:  100,
+
+	// Mempool parameters
+	RelayNonStdTxs: true,
+
+	// Address encoding magics
+	NetworkAddressPrefix: "R",
+	PubKeyAddrID:         [2]byte{0x25, 0xe5}, // starts with Rk
+	PubKeyBlissAddrID:    [2]byte{0x0b, 0x78}, // starts with Rk
+	PubKeyHashAddrID:     [2]byte{0x0e, 0x00}, // starts with Rs
+	PKHEdwardsAddrID:     [2]byte{0x0d, 0xe0}, // starts with Re
+	PKHSchnorrAddrID:     [2]byte{0x0d, 0xc2}, // starts with RS
+	PKHBlissAddrID:       [2]byte{0x0d, 0xd9}, // starts with Rb
+	ScriptHashAddrID:     [2]byte{0x0d, 0xdb}, // starts with Rc
+	PrivateKeyID:         [2]byte{0x22, 0xfe}, // starts with Pr
+
+	// BIP32 hierarchical deterministic extended key magics
+	HDPrivateKeyID: [4]byte{0xea, 0xb4, 0x04, 0x48}, // starts with rprv
+	HDPublicKeyID:  [4]byte{0xea, 0xb4, 0xf9, 0x87}, // starts with rpub
+
+	// BIP32-style hierarchical deterministic BLISS extended key magics
+	HDBlissPrivateKeyID: [4]byte{0xea, 0xb4, 0x0f, 0xb3},
+	HDBlissPublicKeyID:  [4]byte{0xea, 0xb5, 0x04, 0xf2},
+
+	// BIP44 coin type used in the hierarchical deterministic path for
+	// address generation.
+	HDCoinType: uint32(1), // SLIP0044, Testnet (all coins)
+
+	// Hcd PoS parameters
+	MinimumStakeDiff:        20000,
+	TicketPoolSize:          8,
+	TicketsPerBlock:         5,
+	TicketMaturity:          4,
+	TicketExpiry:            48, // 6*TicketPoolSize
+	CoinbaseMaturity:        4,
+	SStxChangeMaturity:      1,
+	TicketPoolSizeWeight:    4,
+	StakeDiffAlpha:          1,
+	StakeDiffWindowSize:     8,
+	StakeDiffWindows:        8,
+	StakeVersionInterval:    8 * 3,       // 3 stake difficulty windows
+	MaxFreshStakePerBlock:   20,          // 4*TicketsPerBlock
+	StakeEnabledHeight:      4 + 4,       // CoinbaseMaturity + TicketMaturity
+	StakeValidationHeight:   4 + (8 * 2), // CoinbaseMaturity + TicketPoolSize*2
+	StakeBaseSigScript:      []byte{0xDE, 0xAD, 0xBE, 0xEF},
+	StakeMajorityMultiplier: 3,
+	StakeMajorityDivisor:    4,
+
+	// Hcd organization related parameters
+	//
+	// The block taxes are paid to the same 3-of-3 P2SH script as the
+	// simulation test network.  See under "Hcd organization related
+	// parameters" of SimNetParams for the details of the wallet which is
+	// able to spend them.
+	OrganizationPkScript:        hexDecode("a914cbb08d6ca783b533b2c7d24a51fbca92d937bf9987"),
+	OrganizationPkScriptVersion: 0,
+	BlockOneLedger:              []*TokenPayout{}, // No premine
+}
+
 var (
 	// ErrDuplicateNet describes an error where the parameters for a Hcd
 	// network could not be set due to the network already being a standard
 	// network or previously-registered into this package.
 	ErrDuplicateNet = errors.New("duplicate Hc network")
 
+	// ErrDuplicateAddrPrefix describes an error where the parameters for a
+	// Hcd network could not be set due to its network address prefix already
+	// being used by a standard or previously-registered network.
+	ErrDuplicateAddrPrefix = errors.New("duplicate network address prefix")
+
 	// ErrUnknownHDKeyID describes an error where the provided id which
 	// is intended to identify the network for a hierarchical deterministic
 	// private extended key is not registered.
@@ -887,12 +1157,15 @@ var (
 
 var (
 	registeredNets    = make(map[wire.CurrencyNet]struct{})
+	addrPrefixNets    = make(map[string]*Params)
 	pubKeyAddrIDs     = make(map[[2]byte]struct{})
 	pubKeyHashAddrIDs = make(map[[2]byte]struct{})
 	pkhEdwardsAddrIDs = make(map[[2]byte]struct{})
 	pkhSchnorrAddrIDs = make(map[[2]byte]struct{})
 	scriptHashAddrIDs = make(map[[2]byte]struct{})
 	hdPrivToPubKeyIDs = make(map[[4]byte][]byte)
+	hdBlissKeyIDs     = make(map[[4]byte]struct{})
+	hdToBlissKeyIDs   = make(map[[4]byte][]byte)
 )
 
 // Register registers the network parameters for a Hcd network.  This may
@@ -908,11 +1181,29 @@ func Register(params *Params) error {
 	if _, ok := registeredNets[params.Net]; ok {
 		return ErrDuplicateNet
 	}
+	if _, ok := addrPrefixNets[params.NetworkAddressPrefix]; ok {
+		return ErrDuplicateAddrPrefix
+	}
 	registeredNets[params.Net] = struct{}{}
+	if params.NetworkAddressPrefix != "" {
+		addrPrefixNets[params.NetworkAddressPrefix] = params
+	}
 	pubKeyAddrIDs[params.PubKeyAddrID] = struct{}{}
 	pubKeyHashAddrIDs[params.PubKeyHashAddrID] = struct{}{}
 	scriptHashAddrIDs[params.ScriptHashAddrID] = struct{}{}
 	hdPrivToPubKeyIDs[params.HDPrivateKeyID] = params.HDPublicKeyID[:]
+
+	// Networks which do not define BLISS extended key magics simply do not
+	// support serializing BLISS extended keys.
+	var zeroID [4]byte
+	if params.HDBlissPrivateKeyID != zeroID {
+		hdPrivToPubKeyIDs[params.HDBlissPrivateKeyID] =
+			params.HDBlissPublicKeyID[:]
+		hdBlissKeyIDs[params.HDBlissPrivateKeyID] = struct{}{}
+		hdBlissKeyIDs[params.HDBlissPublicKeyID] = struct{}{}
+		hdToBlissKeyIDs[params.HDPrivateKeyID] = params.HDBlissPrivateKeyID[:]
+		hdToBlissKeyIDs[params.HDPublicKeyID] = params.HDBlissPublicKeyID[:]
+	}
 	return nil
 }
 
@@ -924,6 +1215,13 @@ func mustRegister(params *Params) {
 	}
 }
 
+// ParamsForAddrPrefix returns the parameters of the default or registered
+// network whose string encoded addresses start with the passed prefix.
+func ParamsForAddrPrefix(prefix string) (*Params, bool) {
+	params, ok := addrPrefixNets[prefix]
+	return params, ok
+}
+
 // IsPubKeyAddrID returns whether the id is an identifier known to prefix a
 // pay-to-pubkey address on any default or registered network.
 func IsPubKeyAddrID(id [2]byte) bool {
@@ -985,6 +1283,40 @@ func HDPrivateKeyToPublicKeyID(id []byte) ([]byte, error) {
 	return pubBytes, nil
 }
 
+// IsHDBlissKeyID returns whether the id is a hierarchical deterministic BLISS
+// extended key id, either private or public, known to any default or
+// registered network.
+func IsHDBlissKeyID(id []byte) bool {
+	if len(id) != 4 {
+		return false
+	}
+
+	var key [4]byte
+	copy(key[:], id)
+	_, ok := hdBlissKeyIDs[key]
+	return ok
+}
+
+// HDKeyToBlissKeyID accepts a secp256k1 hierarchical deterministic extended
+// key id, either private or public, and returns the BLISS extended key id of
+// the same kind for the same network.  When the provided id is not registered,
+// or the network does not define BLISS extended key ids, the ErrUnknownHDKeyID
+// error will be returned.
+func HDKeyToBlissKeyID(id []byte) ([]byte, error) {
+	if len(id) != 4 {
+		return nil, ErrUnknownHDKeyID
+	}
+
+	var key [4]byte
+	copy(key[:], id)
+	blissBytes, ok := hdToBlissKeyIDs[key]
+	if !ok {
+		return nil, ErrUnknownHDKeyID
+	}
+
+	return blissBytes, nil
+}
+
 // newHashFromStr converts the passed big-endian hex string into a
 // chainhash.Hash.  It only differs from the one available in chainhash in that
 // it panics on an error since it will only (and must only) be called with
@@ -1054,9 +1386,22 @@ func (p *Params) XPubKeyID() [4]byte {
 	return p.HDPublicKeyID
 }
 
+// XBlissPrivKeyID returns the hierarchical deterministic BLISS extended private
+// key magic version bytes for the network the parameters define.
+func (p *Params) XBlissPrivKeyID() [4]byte {
+	return p.HDBlissPrivateKeyID
+}
+
+// XBlissPubKeyID returns the hierarchical deterministic BLISS extended public
+// key magic version bytes for the network the parameters define.
+func (p *Params) XBlissPubKeyID() [4]byte {
+	return p.HDBlissPublicKeyID
+}
+
 func init() {
 	// Register all default networks when the package is initialized.
 	mustRegister(&MainNetParams)
 	mustRegister(&TestNet2Params)
 	mustRegister(&SimNetParams)
+	mustRegister(&RegNetParams)
 }
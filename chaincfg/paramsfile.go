@@ -0,0 +1,370 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/wire"
+)
+
+// hexBytes is a byte slice which is encoded as a hex string in parameter
+// files.
+type hexBytes []byte
+
+// UnmarshalJSON decodes the hex string in the passed JSON data.
+func (b *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// addrID is a two byte address encoding magic which is encoded as a hex string
+// in parameter files.
+type addrID [2]byte
+
+// UnmarshalJSON decodes the hex string in the passed JSON data.
+func (id *addrID) UnmarshalJSON(data []byte) error {
+	var b hexBytes
+	if err := b.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	if len(b) != len(id) {
+		return fmt.Errorf("address magic %x is not %d bytes", []byte(b),
+			len(id))
+	}
+	copy(id[:], b)
+	return nil
+}
+
+// hdKeyID is a four byte extended key magic which is encoded as a hex string in
+// parameter files.
+type hdKeyID [4]byte
+
+// UnmarshalJSON decodes the hex string in the passed JSON data.
+func (id *hdKeyID) UnmarshalJSON(data []byte) error {
+	var b hexBytes
+	if err := b.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	if len(b) != len(id) {
+		return fmt.Errorf("extended key magic %x is not %d bytes",
+			[]byte(b), len(id))
+	}
+	copy(id[:], b)
+	return nil
+}
+
+// duration is a time.Duration which is encoded as a string such as "2m30s" in
+// parameter files.
+type duration time.Duration
+
+// UnmarshalJSON decodes the duration string in the passed JSON data.
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// fileCheckpoint is a checkpoint as described in parameter files.
+type fileCheckpoint struct {
+	Height int64
+	Hash   string
+}
+
+// fileGenesis describes the header fields of the genesis block of a network in
+// parameter files.  The genesis block pays nothing and uses the same coinbase
+// transaction as the simulation and regression test networks.
+type fileGenesis struct {
+	Version   int32
+	Timestamp int64
+	Bits      uint32
+	Nonce     uint32
+}
+
+// paramsFile describes the network parameters which are read from a parameter
+// file.  The fields of the embedded parameters are read from keys with the same
+// names, while the fields which are declared here override the fields of the
+// embedded parameters which need a more convenient encoding than the one
+// provided by encoding/json.
+type paramsFile struct {
+	Params
+
+	Genesis              fileGenesis
+	PowLimit             *big.Int
+	MinDiffReductionTime duration
+	TargetTimePerBlock   duration
+	TargetTimespan       duration
+	Checkpoints          []fileCheckpoint
+
+	PubKeyAddrID      addrID
+	PubKeyBlissAddrID addrID
+	PubKeyHashAddrID  addrID
+	PKHEdwardsAddrID  addrID
+	PKHSchnorrAddrID  addrID
+	PKHBlissAddrID    addrID
+	ScriptHashAddrID  addrID
+	PrivateKeyID      addrID
+
+	HDPrivateKeyID      hdKeyID
+	HDPublicKeyID       hdKeyID
+	HDBlissPrivateKeyID hdKeyID
+	HDBlissPublicKeyID  hdKeyID
+
+	StakeBaseSigScript   hexBytes
+	OrganizationPkScript hexBytes
+
+	// GenesisBlock and GenesisHash are always constructed from Genesis.
+	GenesisBlock json.RawMessage
+	GenesisHash  json.RawMessage
+}
+
+// compactToBig converts a compact representation of a whole number to an
+// unsigned big integer.  See CompactToBig in the blockchain package for the
+// details of the representation.
+func compactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+	return bn
+}
+
+// newGenesisBlock returns a genesis block with the passed header fields which
+// pays nothing.
+func newGenesisBlock(g *fileGenesis) *wire.MsgBlock {
+	coinbaseTx := regTestGenesisCoinbaseTx
+	return &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    g.Version,
+			MerkleRoot: coinbaseTx.TxHashFull(),
+			Timestamp:  time.Unix(g.Timestamp, 0),
+			Bits:       g.Bits,
+			Nonce:      g.Nonce,
+		},
+		Transactions:  []*wire.MsgTx{&coinbaseTx},
+		STransactions: []*wire.MsgTx{},
+	}
+}
+
+// validate checks the network parameters for consistency.
+func (p *Params) validate() error {
+	switch {
+	case p.Name == "":
+		return errors.New("network name is empty")
+	case p.Net == 0:
+		return errors.New("network magic is zero")
+	case p.GenesisBlock == nil || p.GenesisHash == nil:
+		return errors.New("genesis block is not set")
+	case *p.GenesisHash != p.GenesisBlock.BlockHash():
+		return errors.New("genesis hash does not match the genesis block")
+	case p.PowLimit == nil || p.PowLimit.Sign() <= 0:
+		return errors.New("proof of work limit must be positive")
+	case compactToBig(p.PowLimitBits).Cmp(p.PowLimit) > 0:
+		return errors.New("proof of work limit bits exceed the proof " +
+			"of work limit")
+	case p.ReduceMinDifficulty && p.MinDiffReductionTime <= 0:
+		return errors.New("minimum difficulty reduction time must be " +
+			"positive when reducing the minimum difficulty")
+	case len(p.MaximumBlockSizes) == 0:
+		return errors.New("no maximum block sizes")
+	case p.MaxTxSize <= 0:
+		return errors.New("maximum transaction size must be positive")
+	case p.TargetTimePerBlock <= 0:
+		return errors.New("target time per block must be positive")
+	case p.WorkDiffWindowSize <= 0 || p.WorkDiffWindows <= 0:
+		return errors.New("work difficulty window size and number of " +
+			"windows must be positive")
+	case p.TargetTimespan != p.TargetTimePerBlock*
+		time.Duration(p.WorkDiffWindowSize):
+		return errors.New("target timespan must be the target time per " +
+			"block times the work difficulty window size")
+	case p.RetargetAdjustmentFactor <= 0:
+		return errors.New("retarget adjustment factor must be positive")
+	case p.BaseSubsidy <= 0 || p.MulSubsidy <= 0 || p.DivSubsidy <= 0:
+		return errors.New("base subsidy and subsidy reduction " +
+			"multiplier and divisor must be positive")
+	case p.SubsidyReductionInterval <= 0:
+		return errors.New("subsidy reduction interval must be positive")
+	case p.TotalSubsidyProportions() == 0:
+		return errors.New("subsidy proportions are all zero")
+	case p.BlockTaxProportion > 0 && len(p.OrganizationPkScript) == 0:
+		return errors.New("block taxes are enabled without an " +
+			"organization script")
+	case p.RuleChangeActivationInterval == 0:
+		return errors.New("rule change activation interval is zero")
+	case p.RuleChangeActivationDivisor == 0 ||
+		p.RuleChangeActivationMultiplier > p.RuleChangeActivationDivisor:
+		return errors.New("rule change activation multiplier must not " +
+			"exceed the nonzero divisor")
+	case len(p.NetworkAddressPrefix) != 1:
+		return errors.New("network address prefix must be a single " +
+			"character")
+	case p.MinimumStakeDiff <= 0:
+		return errors.New("minimum stake difficulty must be positive")
+	case p.TicketPoolSize == 0 || p.TicketsPerBlock == 0:
+		return errors.New("ticket pool size and tickets per block must " +
+			"be positive")
+	case int(p.MaxFreshStakePerBlock) < int(p.TicketsPerBlock):
+		return errors.New("maximum fresh stake per block must be at " +
+			"least the number of tickets per block")
+	case p.StakeDiffWindowSize <= 0 || p.StakeDiffWindows <= 0:
+		return errors.New("stake difficulty window size and number of " +
+			"windows must be positive")
+	case p.StakeVersionInterval <= 0:
+		return errors.New("stake version interval must be positive")
+	case p.StakeEnabledHeight <= 0 ||
+		p.StakeValidationHeight <= p.StakeEnabledHeight:
+		return errors.New("stake validation height must be after the " +
+			"positive stake enabled height")
+	case int64(p.TicketExpiry) < p.StakeEnabledHeight+p.StakeValidationHeight:
+		return errors.New("ticket expiry must be at least the stake " +
+			"enabled height plus the stake validation height")
+	case p.StakeMajorityMultiplier <= 0 || p.StakeMajorityDivisor <= 0 ||
+		p.StakeMajorityMultiplier > p.StakeMajorityDivisor:
+		return errors.New("stake majority multiplier must not exceed " +
+			"the divisor and both must be positive")
+	}
+
+	if _, err := strconv.ParseUint(p.DefaultPort, 10, 16); err != nil {
+		return fmt.Errorf("invalid default port %q", p.DefaultPort)
+	}
+	for i := 1; i < len(p.Checkpoints); i++ {
+		if p.Checkpoints[i].Height <= p.Checkpoints[i-1].Height {
+			return errors.New("checkpoints are not ordered from oldest " +
+				"to newest")
+		}
+	}
+	for version, deployments := range p.Deployments {
+		index, err := validateDeployments(deployments)
+		if err != nil {
+			return fmt.Errorf("invalid agenda version %v id %v: %v",
+				version, deployments[index].Vote.Id, err)
+		}
+		for _, deployment := range deployments {
+			if err := validateAgenda(deployment.Vote); err != nil {
+				return fmt.Errorf("invalid agenda version %v id "+
+					"%v: %v", version, deployment.Vote.Id, err)
+			}
+		}
+	}
+	for _, payout := range p.BlockOneLedger {
+		if payout == nil || payout.Address == "" || payout.Amount <= 0 {
+			return errors.New("block one ledger payouts must have an " +
+				"address and a positive amount")
+		}
+	}
+	return nil
+}
+
+// ParamsFromFile reads the parameters of a custom network from the JSON file at
+// the passed path, constructs its genesis block and checks the parameters for
+// consistency.
+//
+// The keys are the names of the Params fields and are not case sensitive.  The
+// genesis block is described by the Genesis key, which holds the Version,
+// Timestamp (in seconds since the Unix epoch), Bits and Nonce of its header,
+// while the GenesisBlock and GenesisHash fields can't be set.  The address and
+// extended key magics as well as scripts are hex strings, durations are strings
+// such as "2m30s", and checkpoint hashes are strings in the usual byte-reversed
+// order.  The proof of work limit defaults to the value of PowLimitBits.
+//
+// The returned parameters must be registered with Register before they are
+// used.
+func ParamsFromFile(path string) (*Params, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f paramsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if f.GenesisBlock != nil || f.GenesisHash != nil {
+		return nil, fmt.Errorf("%s: the genesis block is described by "+
+			"the Genesis key", path)
+	}
+
+	params := f.Params
+	if f.Genesis.Bits == 0 {
+		f.Genesis.Bits = params.PowLimitBits
+	}
+	params.GenesisBlock = newGenesisBlock(&f.Genesis)
+	genesisHash := params.GenesisBlock.BlockHash()
+	params.GenesisHash = &genesisHash
+	params.PowLimit = f.PowLimit
+	if params.PowLimit == nil {
+		params.PowLimit = compactToBig(params.PowLimitBits)
+	}
+	params.MinDiffReductionTime = time.Duration(f.MinDiffReductionTime)
+	params.TargetTimePerBlock = time.Duration(f.TargetTimePerBlock)
+	params.TargetTimespan = time.Duration(f.TargetTimespan)
+	for _, c := range f.Checkpoints {
+		hash, err := chainhash.NewHashFromStr(c.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("%s: checkpoint at height %d: %v",
+				path, c.Height, err)
+		}
+		params.Checkpoints = append(params.Checkpoints, Checkpoint{
+			Height: c.Height,
+			Hash:   hash,
+		})
+	}
+	params.PubKeyAddrID = f.PubKeyAddrID
+	params.PubKeyBlissAddrID = f.PubKeyBlissAddrID
+	params.PubKeyHashAddrID = f.PubKeyHashAddrID
+	params.PKHEdwardsAddrID = f.PKHEdwardsAddrID
+	params.PKHSchnorrAddrID = f.PKHSchnorrAddrID
+	params.PKHBlissAddrID = f.PKHBlissAddrID
+	params.ScriptHashAddrID = f.ScriptHashAddrID
+	params.PrivateKeyID = f.PrivateKeyID
+	params.HDPrivateKeyID = f.HDPrivateKeyID
+	params.HDPublicKeyID = f.HDPublicKeyID
+	params.HDBlissPrivateKeyID = f.HDBlissPrivateKeyID
+	params.HDBlissPublicKeyID = f.HDBlissPublicKeyID
+	params.StakeBaseSigScript = f.StakeBaseSigScript
+	params.OrganizationPkScript = f.OrganizationPkScript
+	if params.BlockOneLedger == nil {
+		params.BlockOneLedger = []*TokenPayout{}
+	}
+
+	if err := params.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &params, nil
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testParamsJSON describes a custom network.
+const testParamsJSON = `{
+	"Name": "privnet",
+	"Net": 3735928559,
+	"DefaultPort": "16008",
+	"DNSSeeds": [],
+	"Genesis": {"Version": 1, "Timestamp": 1538524800},
+	"PowLimitBits": 545259519,
+	"GenerateSupported": true,
+	"MaximumBlockSizes": [1000000],
+	"MaxTxSize": 1000000,
+	"TargetTimePerBlock": "30s",
+	"WorkDiffAlpha": 1,
+	"WorkDiffWindowSize": 16,
+	"WorkDiffWindows": 4,
+	"TargetTimespan": "8m",
+	"RetargetAdjustmentFactor": 4,
+	"BaseSubsidy": 640000000,
+	"MulSubsidy": 999,
+	"DivSubsidy": 1000,
+	"SubsidyReductionInterval": 1024,
+	"WorkRewardProportion": 6,
+	"StakeRewardProportion": 3,
+	"BlockTaxProportion": 1,
+	"Checkpoints": [{"Height": 10, "Hash": "00000000000000000000000000000000000000000000000000000000000000ff"}],
+	"RuleChangeActivationQuorum": 160,
+	"RuleChangeActivationMultiplier": 3,
+	"RuleChangeActivationDivisor": 4,
+	"RuleChangeActivationInterval": 320,
+	"Deployments": {"7": [{
+		"Vote": {
+			"Id": "maxblocksize",
+			"Description": "Change maximum allowed block size",
+			"Mask": 6,
+			"Choices": [
+				{"Id": "abstain", "Description": "abstain", "Bits": 0, "IsAbstain": true},
+				{"Id": "no", "Description": "no", "Bits": 2, "IsNo": true},
+				{"Id": "yes", "Description": "yes", "Bits": 4}
+			]
+		},
+		"StartTime": 0,
+		"ExpireTime": 9223372036854775807
+	}]},
+	"BlockEnforceNumRequired": 51,
+	"BlockRejectNumRequired": 75,
+	"BlockUpgradeNumToCheck": 100,
+	"RelayNonStdTxs": true,
+	"NetworkAddressPrefix": "P",
+	"PubKeyAddrID": "2000",
+	"PubKeyBlissAddrID": "2001",
+	"PubKeyHashAddrID": "2002",
+	"PKHEdwardsAddrID": "2003",
+	"PKHSchnorrAddrID": "2004",
+	"PKHBlissAddrID": "2005",
+	"ScriptHashAddrID": "2006",
+	"PrivateKeyID": "2007",
+	"HDPrivateKeyID": "01020304",
+	"HDPublicKeyID": "05060708",
+	"HDCoinType": 1,
+	"MinimumStakeDiff": 20000,
+	"TicketPoolSize": 32,
+	"TicketsPerBlock": 3,
+	"TicketMaturity": 8,
+	"TicketExpiry": 192,
+	"CoinbaseMaturity": 8,
+	"SStxChangeMaturity": 1,
+	"TicketPoolSizeWeight": 4,
+	"StakeDiffAlpha": 1,
+	"StakeDiffWindowSize": 16,
+	"StakeDiffWindows": 8,
+	"StakeVersionInterval": 64,
+	"MaxFreshStakePerBlock": 12,
+	"StakeEnabledHeight": 16,
+	"StakeValidationHeight": 72,
+	"StakeBaseSigScript": "deadbeef",
+	"StakeMajorityMultiplier": 3,
+	"StakeMajorityDivisor": 4,
+	"OrganizationPkScript": "a914cbb08d6ca783b533b2c7d24a51fbca92d937bf9987",
+	"BlockOneLedger": [{"Address": "PsAddress", "Amount": 100000000}]
+}`
+
+// TestParamsValidate ensures the parameters of the default networks are
+// consistent.
+func TestParamsValidate(t *testing.T) {
+	for _, params := range []*Params{&MainNetParams, &TestNet2Params,
+		&SimNetParams, &RegNetParams} {
+
+		if err := params.validate(); err != nil {
+			t.Errorf("%s: unexpected error: %v", params.Name, err)
+		}
+	}
+}
+
+// TestParamsFromFile ensures custom network parameters are read from JSON files
+// and inconsistent parameters are rejected.
+func TestParamsFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paramsfile")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+		return path
+	}
+
+	p, err := ParamsFromFile(writeFile("params.json", testParamsJSON))
+	if err != nil {
+		t.Fatalf("ParamsFromFile: unexpected error: %v", err)
+	}
+	if p.TargetTimespan != 8*time.Minute {
+		t.Errorf("TargetTimespan: got %v, want %v", p.TargetTimespan,
+			8*time.Minute)
+	}
+	if p.PubKeyHashAddrID != [2]byte{0x20, 0x02} {
+		t.Errorf("PubKeyHashAddrID: got %x, want 2002", p.PubKeyHashAddrID)
+	}
+	if p.PowLimit.Cmp(compactToBig(0x207fffff)) != 0 {
+		t.Errorf("PowLimit: got %v, want %v", p.PowLimit,
+			compactToBig(0x207fffff))
+	}
+	if p.GenesisBlock.Header.Bits != p.PowLimitBits {
+		t.Errorf("genesis bits: got %x, want %x",
+			p.GenesisBlock.Header.Bits, p.PowLimitBits)
+	}
+	if got := p.GenesisBlock.BlockHash(); *p.GenesisHash != got {
+		t.Errorf("GenesisHash: got %v, want %v", p.GenesisHash, got)
+	}
+	deployment := p.Deployments[7][0]
+	if deployment.Vote.Id != "maxblocksize" ||
+		len(deployment.Vote.Choices) != 3 ||
+		!deployment.Vote.Choices[1].IsNo {
+
+		t.Errorf("Deployments: unexpected deployment %+v", deployment)
+	}
+	if len(p.Checkpoints) != 1 || p.Checkpoints[0].Hash[0] != 0xff {
+		t.Errorf("Checkpoints: unexpected checkpoints %+v", p.Checkpoints)
+	}
+
+	tests := []struct {
+		name    string
+		replace string
+		with    string
+	}{
+		{"bad magic", `"PubKeyAddrID": "2000"`, `"PubKeyAddrID": "20"`},
+		{"bad duration", `"TargetTimePerBlock": "30s"`,
+			`"TargetTimePerBlock": 30`},
+		{"inconsistent timespan", `"TargetTimespan": "8m"`,
+			`"TargetTimespan": "9m"`},
+		{"stake validation height", `"StakeValidationHeight": 72`,
+			`"StakeValidationHeight": 16`},
+		{"invalid agenda", `"Mask": 6`, `"Mask": 5`},
+		{"genesis block", `"Net":`, `"GenesisHash": "", "Net":`},
+	}
+	for _, test := range tests {
+		data := strings.Replace(testParamsJSON, test.replace, test.with, 1)
+		if data == testParamsJSON {
+			t.Fatalf("%s: test data not replaced", test.name)
+		}
+		_, err := ParamsFromFile(writeFile("bad.json", data))
+		if err == nil {
+			t.Errorf("%s: ParamsFromFile did not return an error",
+				test.name)
+		}
+	}
+}
@@ -290,6 +290,16 @@ func TestRegister(t *testing.T) {
 					want: mockNetParams.HDPublicKeyID[:],
 					err:  nil,
 				},
+				{
+					priv: MainNetParams.HDBlissPrivateKeyID[:],
+					want: MainNetParams.HDBlissPublicKeyID[:],
+					err:  nil,
+				},
+				{
+					priv: SimNetParams.HDBlissPrivateKeyID[:],
+					want: SimNetParams.HDBlissPublicKeyID[:],
+					err:  nil,
+				},
 				{
 					priv: []byte{0xff, 0xff, 0xff, 0xff},
 					err:  ErrUnknownHDKeyID,
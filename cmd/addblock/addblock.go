@@ -13,6 +13,7 @@ import (
 
 	"github.com/btcsuite/btclog"
 	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/bootstrap"
 	"github.com/james-ray/hcd/blockchain/indexers"
 	"github.com/james-ray/hcd/database"
 	"github.com/james-ray/hcd/limits"
@@ -76,6 +77,7 @@ func realMain() error {
 	log = backendLogger.Logger("MAIN")
 	database.UseLogger(backendLogger.Logger("BCDB"))
 	blockchain.UseLogger(backendLogger.Logger("CHAN"))
+	bootstrap.UseLogger(backendLogger.Logger("BTSP"))
 	indexers.UseLogger(backendLogger.Logger("INDX"))
 
 	// Load the block database.
@@ -93,6 +95,29 @@ func realMain() error {
 	}
 	defer fi.Close()
 
+	// Import bootstrap files with the pipelined importer.  Flat files of
+	// blocks written by older versions of hcd are imported serially below.
+	isBootstrap, err := isBootstrapFile(fi)
+	if err != nil {
+		log.Errorf("Failed to read file %v: %v", cfg.InFile, err)
+		return err
+	}
+	if isBootstrap {
+		log.Info("Starting bootstrap import")
+		result, err := importBootstrapFile(db, fi)
+		if err != nil {
+			log.Errorf("%v", err)
+			return err
+		}
+
+		log.Infof("Processed a total of %d blocks (%d imported, %d "+
+			"already known) in %v", result.BlocksProcessed,
+			result.BlocksImported,
+			result.BlocksProcessed-result.BlocksImported,
+			result.Duration)
+		return nil
+	}
+
 	// Create a block importer for the database and input file and start it.
 	// The done channel returned from start will contain an error if
 	// anything went wrong.
@@ -0,0 +1,80 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/bootstrap"
+	"github.com/james-ray/hcd/database"
+	"github.com/james-ray/hcd/txscript"
+)
+
+// isBootstrapFile returns whether or not the passed file is a bootstrap file
+// as opposed to a flat file of blocks written by older versions of hcd.  The
+// file is rewound to its start before returning.
+func isBootstrapFile(f *os.File) (bool, error) {
+	var magic [4]byte
+	_, err := io.ReadFull(f, magic[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return bootstrap.IsBootstrap(magic[:]), nil
+}
+
+// importBootstrapFile imports the blocks of the passed bootstrap file to the
+// database.  The blocks are checked by multiple goroutines ahead of being
+// processed, and the import stops between blocks on an interrupt signal.  An
+// interrupted import is resumed by importing the same file again.
+func importBootstrapFile(db database.DB, f *os.File) (*bootstrap.ImportResult, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	sigCache := txscript.NewSigCache(cfg.SigCacheMaxSize)
+	timeSource := blockchain.NewMedianTime()
+	chain, err := newBlockChain(db, timeSource, sigCache)
+	if err != nil {
+		return nil, err
+	}
+
+	interruptChannel := make(chan os.Signal, 1)
+	signal.Notify(interruptChannel, os.Interrupt)
+	defer signal.Stop(interruptChannel)
+	interrupt := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-interruptChannel:
+			log.Info("Received interrupt signal, stopping the import")
+			close(interrupt)
+		case <-done:
+		}
+	}()
+
+	progress := -time.Duration(1)
+	if cfg.Progress > 0 {
+		progress = time.Duration(cfg.Progress) * time.Second
+	}
+	return bootstrap.Import(f, &bootstrap.ImportConfig{
+		Chain:            chain,
+		ChainParams:      activeNetParams,
+		TimeSource:       timeSource,
+		SigCache:         sigCache,
+		Workers:          cfg.Workers,
+		Size:             fi.Size(),
+		ProgressInterval: progress,
+		Interrupt:        interrupt,
+	})
+}
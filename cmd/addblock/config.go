@@ -13,6 +13,7 @@ import (
 
 	"github.com/james-ray/hcd/chaincfg"
 	"github.com/james-ray/hcd/database"
+	_ "github.com/james-ray/hcd/database/bboltdb"
 	_ "github.com/james-ray/hcd/database/ffldb"
 	"github.com/james-ray/hcd/hcutil"
 	"github.com/james-ray/hcd/wire"
@@ -23,6 +24,7 @@ const (
 	defaultDbType   = "ffldb"
 	defaultDataFile = "bootstrap.dat"
 	defaultProgress = 10
+	defaultSigCache = 100000
 )
 
 var (
@@ -45,6 +47,8 @@ type config struct {
 	TxIndex           bool   `long:"txindex" description:"Build a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
 	AddrIndex         bool   `long:"addrindex" description:"Build a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	Progress          int    `short:"p" long:"progress" description:"Show a progress message each time this number of seconds have passed -- Use 0 to disable progress announcements"`
+	Workers           int    `long:"workers" description:"Number of goroutines checking the blocks of bootstrap files ahead of processing them -- Use 0 for the number of CPUs"`
+	SigCacheMaxSize   uint   `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache used when importing bootstrap files"`
 }
 
 // filesExists reports whether the named file or directory exists.
@@ -90,10 +94,11 @@ func netName(chainParams *chaincfg.Params) string {
 func loadConfig() (*config, []string, error) {
 	// Default config.
 	cfg := config{
-		DataDir:  defaultDataDir,
-		DbType:   defaultDbType,
-		InFile:   defaultDataFile,
-		Progress: defaultProgress,
+		DataDir:         defaultDataDir,
+		DbType:          defaultDbType,
+		InFile:          defaultDataFile,
+		Progress:        defaultProgress,
+		SigCacheMaxSize: defaultSigCache,
 	}
 
 	// Parse command line options.
@@ -138,6 +143,14 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	if cfg.Workers < 0 {
+		str := "%s: the number of workers may not be negative"
+		err := fmt.Errorf(str, "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
 	// Append the network type to the data directory so it is "namespaced"
 	// per network.  In addition to the block database, there are other
 	// pieces of data that are saved to disk such as address manager state.
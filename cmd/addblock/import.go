@@ -18,6 +18,7 @@ import (
 	"github.com/james-ray/hcd/chaincfg/chainhash"
 	"github.com/james-ray/hcd/database"
 	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/txscript"
 	"github.com/james-ray/hcd/wire"
 )
 
@@ -303,9 +304,10 @@ func (bi *blockImporter) Import() chan *importResults {
 	return resultChan
 }
 
-// newBlockImporter returns a new importer for the provided file reader seeker
-// and database.
-func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
+// newBlockChain returns a chain for the provided database which builds the
+// optional indexes enabled in the configuration and uses the provided signature
+// cache, which may be nil.
+func newBlockChain(db database.DB, timeSource blockchain.MedianTimeSource, sigCache *txscript.SigCache) (*blockchain.BlockChain, error) {
 	// Create the various indexes as needed.
 	//
 	// CAUTION: the txindex needs to be first in the indexes array because
@@ -341,12 +343,19 @@ func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
 		indexManager = indexers.NewManager(db, indexes, activeNetParams)
 	}
 
-	chain, err := blockchain.New(&blockchain.Config{
+	return blockchain.New(&blockchain.Config{
 		DB:           db,
 		ChainParams:  activeNetParams,
-		TimeSource:   blockchain.NewMedianTime(),
+		TimeSource:   timeSource,
+		SigCache:     sigCache,
 		IndexManager: indexManager,
 	})
+}
+
+// newBlockImporter returns a new importer for the provided file reader seeker
+// and database.
+func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
+	chain, err := newBlockChain(db, blockchain.NewMedianTime(), nil)
 	if err != nil {
 		return nil, err
 	}
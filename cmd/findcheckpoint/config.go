@@ -13,6 +13,7 @@ import (
 
 	"github.com/james-ray/hcd/chaincfg"
 	"github.com/james-ray/hcd/database"
+	_ "github.com/james-ray/hcd/database/bboltdb"
 	_ "github.com/james-ray/hcd/database/ffldb"
 	"github.com/james-ray/hcd/hcutil"
 	"github.com/james-ray/hcd/wire"
@@ -42,7 +43,8 @@ type config struct {
 	TestNet       bool   `long:"testnet" description:"Use the test network"`
 	SimNet        bool   `long:"simnet" description:"Use the simulation test network"`
 	NumCandidates int    `short:"n" long:"numcandidates" description:"Max num of checkpoint candidates to show {1-20}"`
-	UseGoOutput   bool   `short:"g" long:"gooutput" description:"Display the candidates using Go syntax that is ready to insert into the hcchain checkpoint list"`
+	UseGoOutput   bool   `short:"g" long:"gooutput" description:"Display the checkpoint list of the network with the candidates appended using Go syntax that is ready to replace the one of the chain parameters"`
+	UseJSONOutput bool   `short:"j" long:"jsonoutput" description:"Display the checkpoint list of the network with the candidates appended as JSON that is ready to replace the one of a chain parameter file"`
 }
 
 // validDbType returns whether or not dbType is a supported database type.
@@ -143,5 +145,15 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The Go and JSON output formats are mutually exclusive.
+	if cfg.UseGoOutput && cfg.UseJSONOutput {
+		str := "%s: the gooutput and jsonoutput options can't be " +
+			"used together -- choose one of the two"
+		err := fmt.Errorf(str, "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
 	return &cfg, remainingArgs, nil
 }
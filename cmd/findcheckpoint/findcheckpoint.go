@@ -7,13 +7,13 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/james-ray/hcd/blockchain"
 	"github.com/james-ray/hcd/chaincfg"
-	"github.com/james-ray/hcd/chaincfg/chainhash"
 	"github.com/james-ray/hcd/database"
 )
 
@@ -28,7 +28,7 @@ func loadBlockDB() (database.DB, error) {
 	// The database name is based on the database type.
 	dbName := blockDbNamePrefix + "_" + cfg.DbType
 	dbPath := filepath.Join(cfg.DataDir, dbName)
-	fmt.Printf("Loading block database from '%s'\n", dbPath)
+	fmt.Fprintf(os.Stderr, "Loading block database from '%s'\n", dbPath)
 	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
 	if err != nil {
 		return nil, err
@@ -37,17 +37,11 @@ func loadBlockDB() (database.DB, error) {
 }
 
 // findCandidates searches the chain backwards for checkpoint candidates and
-// returns a slice of found candidates, if any.  It also stops searching for
-// candidates at the last checkpoint that is already hard coded into chain
-// since there is no point in finding candidates before already existing
-// checkpoints.
-func findCandidates(chain *blockchain.BlockChain, latestHash *chainhash.Hash) ([]*chaincfg.Checkpoint, error) {
-	// Start with the latest block of the main chain.
-	block, err := chain.BlockByHash(latestHash)
-	if err != nil {
-		return nil, err
-	}
-
+// returns a slice of found candidates ordered from newest to oldest, if any.
+// It stops searching for candidates at the last checkpoint that is already
+// hard coded into chain since there is no point in finding candidates before
+// already existing checkpoints.
+func findCandidates(chain *blockchain.BlockChain) ([]chaincfg.Checkpoint, error) {
 	// Get the latest known checkpoint.
 	latestCheckpoint := chain.LatestCheckpoint()
 	if latestCheckpoint == nil {
@@ -61,77 +55,83 @@ func findCandidates(chain *blockchain.BlockChain, latestHash *chainhash.Hash) ([
 
 	// The latest known block must be at least the last known checkpoint
 	// plus required checkpoint confirmations.
+	best := chain.BestSnapshot()
 	checkpointConfirmations := int64(blockchain.CheckpointConfirmations)
 	requiredHeight := latestCheckpoint.Height + checkpointConfirmations
-	if block.Height() < requiredHeight {
+	if best.Height < requiredHeight {
 		return nil, fmt.Errorf("the block database is only at height "+
 			"%d which is less than the latest checkpoint height "+
 			"of %d plus required confirmations of %d",
-			block.Height(), latestCheckpoint.Height,
+			best.Height, latestCheckpoint.Height,
 			checkpointConfirmations)
 	}
 
-	// For the first checkpoint, the required height is any block after the
-	// genesis block, so long as the chain has at least the required number
-	// of confirmations (which is enforced above).
-	if len(activeNetParams.Checkpoints) == 0 {
-		requiredHeight = 1
-	}
+	// The chain validates the candidates against the same criteria used
+	// by the getcheckpointcandidates RPC, which includes the stake
+	// specific ones such as finalized votes and no pending side chains.
+	fmt.Fprintln(os.Stderr, "Searching for candidates...")
+	return chain.CheckpointCandidates(cfg.NumCandidates)
+}
 
-	// Indeterminate progress setup.
-	numBlocksToTest := block.Height() - requiredHeight
-	progressInterval := (numBlocksToTest / 100) + 1 // min 1
-	fmt.Print("Searching for candidates")
-	defer fmt.Println()
-
-	// Loop backwards through the chain to find checkpoint candidates.
-	candidates := make([]*chaincfg.Checkpoint, 0, cfg.NumCandidates)
-	numTested := int64(0)
-	for len(candidates) < cfg.NumCandidates && block.Height() > requiredHeight {
-		// Display progress.
-		if numTested%progressInterval == 0 {
-			fmt.Print(".")
-		}
+// newCheckpointList returns the checkpoints of the active network with the
+// passed candidates appended ordered from oldest to newest, which is the order
+// the checkpoints of the chain parameters are required to be in.
+func newCheckpointList(candidates []chaincfg.Checkpoint) []chaincfg.Checkpoint {
+	checkpoints := make([]chaincfg.Checkpoint, 0,
+		len(activeNetParams.Checkpoints)+len(candidates))
+	checkpoints = append(checkpoints, activeNetParams.Checkpoints...)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		checkpoints = append(checkpoints, candidates[i])
+	}
+	return checkpoints
+}
 
-		// Determine if this block is a checkpoint candidate.
-		isCandidate, err := chain.IsCheckpointCandidate(block)
-		if err != nil {
-			return nil, err
-		}
+// showGoCheckpoints displays the checkpoints of the active network along with
+// the passed candidates using the Go syntax of the checkpoint list in the
+// chain parameters, so it is ready to replace the existing list.
+func showGoCheckpoints(candidates []chaincfg.Checkpoint) {
+	fmt.Println("\tCheckpoints: []Checkpoint{")
+	for _, checkpoint := range newCheckpointList(candidates) {
+		fmt.Printf("\t\t{%d, newHashFromStr(\"%v\")},\n",
+			checkpoint.Height, checkpoint.Hash)
+	}
+	fmt.Println("\t},")
+}
 
-		// All checks passed, so this node seems like a reasonable
-		// checkpoint candidate.
-		if isCandidate {
-			checkpoint := chaincfg.Checkpoint{
-				Height: block.Height(),
-				Hash:   block.Hash(),
-			}
-			candidates = append(candidates, &checkpoint)
-		}
+// jsonCheckpoint is a checkpoint as described in chain parameter files.
+type jsonCheckpoint struct {
+	Height int64
+	Hash   string
+}
 
-		prevHash := &block.MsgBlock().Header.PrevBlock
-		block, err = chain.BlockByHash(prevHash)
-		if err != nil {
-			return nil, err
-		}
-		numTested++
+// showJSONCheckpoints displays the checkpoints of the active network along
+// with the passed candidates as the checkpoints of a chain parameter file, so
+// it is ready to replace the existing list.
+func showJSONCheckpoints(candidates []chaincfg.Checkpoint) error {
+	checkpoints := newCheckpointList(candidates)
+	params := struct {
+		Checkpoints []jsonCheckpoint
+	}{
+		Checkpoints: make([]jsonCheckpoint, 0, len(checkpoints)),
+	}
+	for _, checkpoint := range checkpoints {
+		params.Checkpoints = append(params.Checkpoints, jsonCheckpoint{
+			Height: checkpoint.Height,
+			Hash:   checkpoint.Hash.String(),
+		})
+	}
+	b, err := json.MarshalIndent(&params, "", "  ")
+	if err != nil {
+		return err
 	}
-	return candidates, nil
+	fmt.Println(string(b))
+	return nil
 }
 
-// showCandidate display a checkpoint candidate using and output format
-// determined by the configuration parameters.  The Go syntax output
-// uses the format the chain code expects for checkpoints added to the list.
+// showCandidate displays a checkpoint candidate in a human readable format.
 func showCandidate(candidateNum int, checkpoint *chaincfg.Checkpoint) {
-	if cfg.UseGoOutput {
-		fmt.Printf("Candidate %d -- {%d, newHashFromStr(\"%v\")},\n",
-			candidateNum, checkpoint.Height, checkpoint.Hash)
-		return
-	}
-
 	fmt.Printf("Candidate %d -- Height: %d, Hash: %v\n", candidateNum,
 		checkpoint.Height, checkpoint.Hash)
-
 }
 
 func main() {
@@ -161,13 +161,15 @@ func main() {
 		return
 	}
 
-	// Get the latest block hash and height from the database and report
-	// status.
+	// Get the latest block height from the database and report status.
+	// Status is written to stderr so the Go and JSON output can be
+	// redirected to a file as is.
 	best := chain.BestSnapshot()
-	fmt.Printf("Block database loaded with block height %d\n", best.Height)
+	fmt.Fprintf(os.Stderr, "Block database loaded with block height %d\n",
+		best.Height)
 
 	// Find checkpoint candidates.
-	candidates, err := findCandidates(chain, best.Hash)
+	candidates, err := findCandidates(chain)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Unable to identify candidates:", err)
 		return
@@ -175,12 +177,21 @@ func main() {
 
 	// No candidates.
 	if len(candidates) == 0 {
-		fmt.Println("No candidates found.")
+		fmt.Fprintln(os.Stderr, "No candidates found.")
 		return
 	}
 
 	// Show the candidates.
-	for i, checkpoint := range candidates {
-		showCandidate(i+1, checkpoint)
+	switch {
+	case cfg.UseGoOutput:
+		showGoCheckpoints(candidates)
+	case cfg.UseJSONOutput:
+		if err := showJSONCheckpoints(candidates); err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to show candidates:", err)
+		}
+	default:
+		for i := range candidates {
+			showCandidate(i+1, &candidates[i])
+		}
 	}
 }
@@ -8,6 +8,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
@@ -26,8 +27,12 @@ import (
 const (
 	// unusableFlags are the command usage flags which this utility are not
 	// able to use.  In particular it doesn't support websockets and
-	// consequently notifications.
+	// consequently notifications outside of the interactive shell.
 	unusableFlags = hcjson.UFWebsocketOnly | hcjson.UFNotification
+
+	// shellUnusableFlags are the command usage flags which the interactive
+	// shell is not able to use.  Notifications are only sent by the server.
+	shellUnusableFlags = hcjson.UFNotification
 )
 
 var (
@@ -41,9 +46,9 @@ var (
 	defaultWalletCertFile  = filepath.Join(hcwalletHomeDir, "rpc.cert")
 )
 
-// listCommands categorizes and lists all of the usable commands along with
-// their one-line usage.
-func listCommands() {
+// listCommands categorizes and writes all of the commands which have none of the
+// passed unusable usage flags to w along with their one-line usage.
+func listCommands(w io.Writer, unusable hcjson.UsageFlag) {
 	const (
 		categoryChain uint8 = iota
 		categoryWallet
@@ -62,8 +67,8 @@ func listCommands() {
 			continue
 		}
 
-		// Skip the commands that aren't usable from this utility.
-		if flags&unusableFlags != 0 {
+		// Skip the commands that aren't usable.
+		if flags&unusable != 0 {
 			continue
 		}
 
@@ -91,11 +96,11 @@ func listCommands() {
 	categoryTitles[categoryWallet] = "Wallet Server Commands (--wallet):"
 	categoryTitles[categoryOmni] = "Omni Wallet Server Commands (--wallet):"
 	for category := uint8(0); category < numCategories; category++ {
-		fmt.Println(categoryTitles[category])
+		fmt.Fprintln(w, categoryTitles[category])
 		for _, usage := range categorized[category] {
-			fmt.Println(usage)
+			fmt.Fprintln(w, usage)
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 }
 
@@ -120,6 +125,7 @@ type config struct {
 	SimNet          bool   `long:"simnet" description:"Connect to the simulation test network"`
 	TLSSkipVerify   bool   `long:"skipverify" description:"Do not verify tls certificates (not recommended!)"`
 	Wallet          bool   `long:"wallet" description:"Connect to wallet"`
+	Interactive     bool   `short:"i" long:"interactive" description:"Start an interactive shell over a websocket connection -- Commands are read from standard input when it is not a terminal"`
 }
 
 // normalizeAddress returns addr with the passed default port appended if
@@ -271,7 +277,9 @@ func loadConfig() (*config, []string, error) {
 	// Show the available commands and exit if the associated flag was
 	// specified.
 	if preCfg.ListCommands {
-		listCommands()
+		listCommands(os.Stdout, unusableFlags)
+		fmt.Println("Streaming Commands:")
+		subscribeUsage(os.Stdout)
 		os.Exit(0)
 	}
 
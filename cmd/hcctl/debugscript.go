@@ -0,0 +1,66 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/james-ray/hcd/hcjson"
+)
+
+// scriptNames are the names of the scripts executed by debugscript, indexed
+// by the script index of a step.
+var scriptNames = []string{"sigscript", "pkscript", "redeemscript"}
+
+// printDebugScript writes the result of a debugscript command to out as a table
+// with one line per step showing the opcode and the stacks after it.
+func printDebugScript(out io.Writer, result []byte) error {
+	var res hcjson.DebugScriptResult
+	if err := json.Unmarshal(result, &res); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "sigscript:    %s\n", res.SigScript)
+	fmt.Fprintf(out, "pkscript:     %s\n", res.PkScript)
+	if res.RedeemScript != "" {
+		fmt.Fprintf(out, "redeemscript: %s\n", res.RedeemScript)
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SCRIPT\tPC\tOPCODE\tSTACK\tALTSTACK\tCONDSTACK")
+	for _, step := range res.Steps {
+		script := fmt.Sprintf("%d", step.Script)
+		if step.Script < len(scriptNames) {
+			script = scriptNames[step.Script]
+		}
+		opcode := step.Opcode
+		if !step.Executed {
+			opcode += " (skipped)"
+		}
+		fmt.Fprintf(w, "%s\t%04x\t%s\t[%s]\t[%s]\t[%s]\n", script,
+			step.Offset, opcode, strings.Join(step.Stack, " "),
+			strings.Join(step.AltStack, " "),
+			strings.Join(step.CondStack, " "))
+		if step.Error != "" {
+			fmt.Fprintf(w, "\t\terror: %s\t\t\t\n", step.Error)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+
+	if res.Valid {
+		fmt.Fprintln(out, "result: valid")
+	} else {
+		fmt.Fprintf(out, "result: invalid: %s\n", res.Error)
+	}
+	return nil
+}
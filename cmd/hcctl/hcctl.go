@@ -52,11 +52,36 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Run the interactive shell instead of a single command if requested.
+	if cfg.Interactive {
+		if len(args) > 0 {
+			usage("No command may be specified with the " +
+				"interactive shell")
+			os.Exit(1)
+		}
+		if err := runShell(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if len(args) < 1 {
 		usage("No command specified")
 		os.Exit(1)
 	}
 
+	// Stream notifications until interrupted when requested.
+	if args[0] == subscribeCmdName {
+		if err := runSubscribe(cfg, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, "Usage:")
+			subscribeUsage(os.Stderr)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Ensure the specified method identifies a valid registered command and
 	// is one of the usable types.
 	method := args[0]
@@ -68,7 +93,7 @@ func main() {
 	}
 	if usageFlags&unusableFlags != 0 {
 		fmt.Fprintf(os.Stderr, "The '%s' command can only be used via "+
-			"websockets\n", method)
+			"websockets -- use the interactive shell (-i)\n", method)
 		fmt.Fprintln(os.Stderr, listCmdMessage)
 		os.Exit(1)
 	}
@@ -142,27 +167,42 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := displayResult(os.Stdout, method, result, cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// displayResult writes the result of a command for the passed method to w in
+// the format best suited to its type.
+func displayResult(w io.Writer, method string, result []byte, cfg *config) error {
+	// The step-by-step execution returned by debugscript is easier to
+	// follow as a table unless the raw JSON was requested.
+	if method == "debugscript" && !cfg.PrintJSON {
+		if err := printDebugScript(w, result); err != nil {
+			return fmt.Errorf("Failed to format result: %v", err)
+		}
+		return nil
+	}
+
 	// Choose how to display the result based on its type.
 	strResult := string(result)
 	if strings.HasPrefix(strResult, "{") || strings.HasPrefix(strResult, "[") {
 		var dst bytes.Buffer
 		if err := json.Indent(&dst, result, "", "  "); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to format result: %v",
-				err)
-			os.Exit(1)
+			return fmt.Errorf("Failed to format result: %v", err)
 		}
-		fmt.Println(dst.String())
+		fmt.Fprintln(w, dst.String())
 
 	} else if strings.HasPrefix(strResult, `"`) {
 		var str string
 		if err := json.Unmarshal(result, &str); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to unmarshal result: %v",
-				err)
-			os.Exit(1)
+			return fmt.Errorf("Failed to unmarshal result: %v", err)
 		}
-		fmt.Println(str)
+		fmt.Fprintln(w, str)
 
 	} else if strResult != "null" {
-		fmt.Println(strResult)
+		fmt.Fprintln(w, strResult)
 	}
+	return nil
 }
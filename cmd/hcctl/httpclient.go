@@ -15,10 +15,10 @@ import (
 	"github.com/btcsuite/go-socks/socks"
 )
 
-// newHTTPClient returns a new HTTP client that is configured according to the
-// proxy and TLS settings in the associated connection configuration.
-func newHTTPClient(cfg *config) (*http.Client, error) {
-	// Configure proxy if needed.
+// newDialFunc returns the function used to dial the RPC server according to
+// the proxy settings in the associated connection configuration.  It returns
+// nil when no proxy is configured.
+func newDialFunc(cfg *config) func(network, addr string) (net.Conn, error) {
 	var dial func(network, addr string) (net.Conn, error)
 	if cfg.Proxy != "" {
 		proxy := &socks.Proxy{
@@ -34,8 +34,13 @@ func newHTTPClient(cfg *config) (*http.Client, error) {
 			return c, nil
 		}
 	}
+	return dial
+}
 
-	// Configure TLS if needed.
+// newTLSConfig returns the TLS configuration used to connect to the RPC server
+// according to the TLS settings in the associated connection configuration.
+// It returns nil when the default configuration should be used.
+func newTLSConfig(cfg *config) (*tls.Config, error) {
 	var tlsConfig *tls.Config
 	if !cfg.NoTLS && cfg.RPCCert != "" {
 		pem, err := ioutil.ReadFile(cfg.RPCCert)
@@ -53,6 +58,18 @@ func newHTTPClient(cfg *config) (*http.Client, error) {
 			InsecureSkipVerify: cfg.TLSSkipVerify,
 		}
 	}
+	return tlsConfig, nil
+}
+
+// newHTTPClient returns a new HTTP client that is configured according to the
+// proxy and TLS settings in the associated connection configuration.
+func newHTTPClient(cfg *config) (*http.Client, error) {
+	// Configure proxy and TLS if needed.
+	dial := newDialFunc(cfg)
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create and return the new HTTP client potentially configured with a
 	// proxy and TLS.
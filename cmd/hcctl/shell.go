@@ -0,0 +1,593 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/james-ray/hcd/hcjson"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	// shellPrompt is the prompt shown by the interactive shell.
+	shellPrompt = "hcctl> "
+
+	// keyCtrlC is the key code of Ctrl+C which discards the current line
+	// while the terminal is in raw mode.
+	keyCtrlC = 3
+
+	// completionWidth is the width the completion candidates are wrapped
+	// at when the terminal width is unknown.
+	completionWidth = 80
+)
+
+// shellBuiltins describes the commands handled by the shell itself.
+var shellBuiltins = map[string]string{
+	"exit": "exit -- Close the connection and exit the shell",
+	"help": "help (command) -- List the available commands or show the usage of a command",
+	"quit": "quit -- Close the connection and exit the shell",
+}
+
+// shell houses the state of an interactive hcctl session.  Commands are read
+// from a terminal with line editing, history and completion, or from a script
+// when standard input is not a terminal.
+type shell struct {
+	cfg     *config
+	client  *wsClient
+	methods []string
+
+	// term is the terminal commands are read from and output is written
+	// to.  It is nil when running a script, in which case outMtx
+	// serializes the output of commands and notifications.
+	term   *terminal.Terminal
+	width  int
+	outMtx sync.Mutex
+}
+
+// write writes the passed output to the terminal, or to standard output when
+// running a script.
+func (sh *shell) write(b []byte) {
+	if sh.term != nil {
+		sh.term.Write(b)
+		return
+	}
+	sh.outMtx.Lock()
+	os.Stdout.Write(b)
+	sh.outMtx.Unlock()
+}
+
+// printf formats the passed output and writes it.
+func (sh *shell) printf(format string, args ...interface{}) {
+	sh.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+// printError writes the passed error to the terminal, or to standard error
+// when running a script.
+func (sh *shell) printError(err error) {
+	if sh.term != nil {
+		sh.printf("%v\n", err)
+		return
+	}
+	sh.outMtx.Lock()
+	fmt.Fprintln(os.Stderr, err)
+	sh.outMtx.Unlock()
+}
+
+// connect establishes the websocket connection to the RPC server.
+func (sh *shell) connect() error {
+	var trace func([]byte)
+	if sh.cfg.PrintJSON {
+		trace = func(msg []byte) {
+			sh.printf("%s\n", msg)
+		}
+	}
+	client, err := newWSClient(sh.cfg, sh.notify, trace)
+	if err != nil {
+		return err
+	}
+	sh.client = client
+	return nil
+}
+
+// notify displays a notification received from the RPC server.  It is called
+// by the websocket client from a separate goroutine.
+func (sh *shell) notify(method string, params []json.RawMessage) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Notification %s:\n", method)
+	for _, param := range params {
+		if err := displayResult(&buf, method, param, sh.cfg); err != nil {
+			fmt.Fprintf(&buf, "%s\n", param)
+		}
+	}
+	sh.write(buf.Bytes())
+}
+
+// execute runs the commands of the passed line and displays the result of the
+// last one.  The result of each command of a pipeline is passed to the next.
+// It returns whether or not the shell should exit.
+func (sh *shell) execute(line string) (bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return false, nil
+	}
+	pipeline, err := splitPipeline(line)
+	if err != nil {
+		return false, err
+	}
+
+	// Builtins can't be part of a pipeline.
+	if len(pipeline) == 1 && len(pipeline[0]) > 0 {
+		switch pipeline[0][0] {
+		case "exit", "quit":
+			return true, nil
+		case "help":
+			return false, sh.help(pipeline[0][1:])
+		}
+	}
+
+	var method string
+	var result []byte
+	for i, words := range pipeline {
+		if len(words) == 0 {
+			return false, errors.New("empty command in pipeline")
+		}
+		method = words[0]
+		args := words[1:]
+		if i > 0 {
+			args, err = pipeArgs(args, result)
+			if err != nil {
+				return false, fmt.Errorf("%s command: %v", method,
+					err)
+			}
+		}
+		result, err = sh.run(method, args)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := displayResult(&buf, method, result, sh.cfg); err != nil {
+		return false, err
+	}
+	sh.write(buf.Bytes())
+	return false, nil
+}
+
+// run sends the command for the passed method and arguments to the RPC server
+// and returns its result.  The connection is reestablished first when it was
+// lost.
+func (sh *shell) run(method string, args []string) ([]byte, error) {
+	usageFlags, err := hcjson.MethodUsageFlags(method)
+	if err != nil {
+		return nil, fmt.Errorf("Unrecognized command '%s' -- type "+
+			"help for the available commands", method)
+	}
+	if usageFlags&shellUnusableFlags != 0 {
+		return nil, fmt.Errorf("The '%s' command is a notification "+
+			"sent by the server", method)
+	}
+
+	params := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		params = append(params, arg)
+	}
+	cmd, err := hcjson.NewCmd(method, params...)
+	if err != nil {
+		usage, _ := hcjson.MethodUsageText(method)
+		if jerr, ok := err.(hcjson.Error); ok {
+			return nil, fmt.Errorf("%s command: %v (code: %s)\n"+
+				"Usage:\n  %s", method, err, jerr.Code, usage)
+		}
+		return nil, fmt.Errorf("%s command: %v\nUsage:\n  %s", method,
+			err, usage)
+	}
+
+	if sh.client.isDisconnected() {
+		sh.printf("Reconnecting to %s -- notifications must be "+
+			"requested again\n", sh.cfg.RPCServer)
+		if err := sh.connect(); err != nil {
+			return nil, err
+		}
+	}
+	return sh.client.sendCmd(cmd)
+}
+
+// help displays the available commands, or the usage of the passed command.
+func (sh *shell) help(args []string) error {
+	var buf bytes.Buffer
+	switch {
+	case len(args) == 0:
+		listCommands(&buf, shellUnusableFlags)
+		fmt.Fprintln(&buf, "Shell Commands:")
+		builtins := make([]string, 0, len(shellBuiltins))
+		for name := range shellBuiltins {
+			builtins = append(builtins, name)
+		}
+		sort.Strings(builtins)
+		for _, name := range builtins {
+			fmt.Fprintln(&buf, shellBuiltins[name])
+		}
+		fmt.Fprintln(&buf)
+		fmt.Fprintln(&buf, "The result of a command is passed to the "+
+			"next command of a pipeline such as")
+		fmt.Fprintln(&buf, "'getbestblockhash | getblock'.  It replaces "+
+			"the - arguments of the next command or")
+		fmt.Fprintln(&buf, "is added as its last argument.")
+
+	default:
+		if desc, ok := shellBuiltins[args[0]]; ok {
+			fmt.Fprintln(&buf, desc)
+			break
+		}
+		usage, err := hcjson.MethodUsageText(args[0])
+		if err != nil {
+			return fmt.Errorf("Unrecognized command '%s'", args[0])
+		}
+		fmt.Fprintln(&buf, "Usage:")
+		fmt.Fprintf(&buf, "  %s\n", usage)
+	}
+	sh.write(buf.Bytes())
+	return nil
+}
+
+// complete is the completion callback of the terminal.  Tab completes the
+// command or argument under the cursor and lists the candidates when there is
+// more than one, and Ctrl+C discards the current line.
+func (sh *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	switch key {
+	case keyCtrlC:
+		sh.write([]byte("^C\n"))
+		return "", 0, true
+	case '\t':
+	default:
+		return "", 0, false
+	}
+
+	// Only the command of the pipeline the cursor is in is considered.
+	prefix := line[:pos]
+	stage := prefix[strings.LastIndex(prefix, "|")+1:]
+	words := strings.Fields(stage)
+	var partial string
+	if len(words) > 0 && !strings.HasSuffix(stage, " ") {
+		partial = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	var candidates []string
+	if len(words) == 0 {
+		candidates = matchPrefix(sh.methods, partial)
+	} else {
+		candidates = argCandidates(words[0], len(words)-1, partial)
+	}
+
+	var completion string
+	switch len(candidates) {
+	case 0:
+		if len(words) > 0 {
+			sh.showArgUsage(words[0], len(words)-1)
+		}
+		return "", 0, false
+
+	case 1:
+		completion = candidates[0] + " "
+
+	default:
+		completion = longestCommonPrefix(candidates)
+		if len(completion) <= len(partial) {
+			sh.showCandidates(candidates)
+			return "", 0, false
+		}
+	}
+
+	newPrefix := prefix[:len(prefix)-len(partial)] + completion
+	return newPrefix + line[pos:], len(newPrefix), true
+}
+
+// showArgUsage displays the usage of the passed method along with the usage of
+// the argument with the passed index.
+func (sh *shell) showArgUsage(method string, index int) {
+	usage, err := hcjson.MethodUsageText(method)
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, usage)
+	params, _ := hcjson.MethodParams(method)
+	if index < len(params) {
+		fmt.Fprintf(&buf, "  argument %d: %s\n", index+1,
+			params[index].Usage)
+	}
+	sh.write(buf.Bytes())
+}
+
+// showCandidates displays the passed completion candidates in columns.
+func (sh *shell) showCandidates(candidates []string) {
+	colWidth := 0
+	for _, candidate := range candidates {
+		if len(candidate) > colWidth {
+			colWidth = len(candidate)
+		}
+	}
+	colWidth += 2
+	width := sh.width
+	if width <= 0 {
+		width = completionWidth
+	}
+	numCols := width / colWidth
+	if numCols < 1 {
+		numCols = 1
+	}
+
+	var buf bytes.Buffer
+	for i, candidate := range candidates {
+		if (i+1)%numCols == 0 || i == len(candidates)-1 {
+			fmt.Fprintln(&buf, candidate)
+			continue
+		}
+		fmt.Fprintf(&buf, "%-*s", colWidth, candidate)
+	}
+	sh.write(buf.Bytes())
+}
+
+// argCandidates returns the values starting with the passed partial value that
+// complete the argument with the passed index of the passed method.  Only the
+// values of boolean arguments and arguments with enumerated values are known.
+func argCandidates(method string, index int, partial string) []string {
+	params, err := hcjson.MethodParams(method)
+	if err != nil || index >= len(params) {
+		return nil
+	}
+
+	param := params[index]
+	var values []string
+	switch {
+	case param.Kind == reflect.Bool:
+		values = []string{"false", "true"}
+
+	// Enumerated values are shown as "value1|value2|..." in the usage.
+	case strings.HasPrefix(param.Usage, `"`) &&
+		strings.HasSuffix(param.Usage, `"`) &&
+		strings.Contains(param.Usage, "|"):
+
+		values = strings.Split(strings.Trim(param.Usage, `"`), "|")
+	}
+	return matchPrefix(values, partial)
+}
+
+// matchPrefix returns the values which start with the passed prefix.
+func matchPrefix(values []string, prefix string) []string {
+	var matches []string
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			matches = append(matches, value)
+		}
+	}
+	return matches
+}
+
+// longestCommonPrefix returns the longest prefix shared by all passed values.
+func longestCommonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// shellMethods returns the sorted names of the commands usable from the shell
+// including the builtins.
+func shellMethods() []string {
+	var methods []string
+	for _, method := range hcjson.RegisteredCmdMethods() {
+		flags, err := hcjson.MethodUsageFlags(method)
+		if err != nil || flags&shellUnusableFlags != 0 {
+			continue
+		}
+		methods = append(methods, method)
+	}
+	for name := range shellBuiltins {
+		methods = append(methods, name)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// splitPipeline splits the passed line into the commands of a pipeline which
+// are separated by |, and each command into its words.  Words are separated by
+// spaces unless they are quoted with single or double quotes, and a backslash
+// outside of single quotes escapes the next character.
+func splitPipeline(line string) ([][]string, error) {
+	var pipeline [][]string
+	var words []string
+	var word strings.Builder
+	var inWord, escaped bool
+	var quote rune
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+
+		case quote != 0:
+			switch {
+			case r == quote:
+				quote = 0
+			case r == '\\' && quote == '"':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+
+		case r == '\\':
+			escaped = true
+			inWord = true
+
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+
+		case r == '|':
+			endWord()
+			pipeline = append(pipeline, words)
+			words = nil
+
+		case unicode.IsSpace(r):
+			endWord()
+
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, errors.New("unterminated backslash escape")
+	}
+	endWord()
+	return append(pipeline, words), nil
+}
+
+// pipeArgs returns the passed arguments with the passed result of the previous
+// command of a pipeline replacing the - arguments, or added as the last
+// argument when there are none.  String results are passed without quotes and
+// any other result as JSON.
+func pipeArgs(args []string, result []byte) ([]string, error) {
+	result = bytes.TrimSpace(result)
+	if len(result) == 0 || bytes.Equal(result, []byte("null")) {
+		return nil, errors.New("the previous command returned no result")
+	}
+	value := string(result)
+	if result[0] == '"' {
+		if err := json.Unmarshal(result, &value); err != nil {
+			return nil, err
+		}
+	}
+
+	piped := make([]string, 0, len(args)+1)
+	var replaced bool
+	for _, arg := range args {
+		if arg == "-" {
+			arg = value
+			replaced = true
+		}
+		piped = append(piped, arg)
+	}
+	if !replaced {
+		piped = append(piped, value)
+	}
+	return piped, nil
+}
+
+// runScript executes the commands read from the passed reader line by line.
+// It stops at the first command which fails.
+func (sh *shell) runScript(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line != "" {
+			exit, cmdErr := sh.execute(line)
+			if cmdErr != nil {
+				return cmdErr
+			}
+			if exit {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// runShell connects to the RPC server and runs the interactive shell until it
+// is exited.  The commands are read from a script when standard input is not a
+// terminal.
+func runShell(cfg *config) error {
+	sh := &shell{cfg: cfg, methods: shellMethods()}
+
+	// Put the terminal into raw mode for line editing before connecting so
+	// notifications are always written to it.
+	inFd := int(os.Stdin.Fd())
+	isTerminal := terminal.IsTerminal(inFd)
+	if isTerminal {
+		oldState, err := terminal.MakeRaw(inFd)
+		if err != nil {
+			return err
+		}
+		defer terminal.Restore(inFd, oldState)
+
+		rw := struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}
+		sh.term = terminal.NewTerminal(rw, shellPrompt)
+		width, height, err := terminal.GetSize(int(os.Stdout.Fd()))
+		if err == nil && width > 0 && height > 0 {
+			sh.width = width
+			sh.term.SetSize(width, height)
+		}
+		sh.term.AutoCompleteCallback = sh.complete
+	}
+
+	if err := sh.connect(); err != nil {
+		return err
+	}
+	defer func() {
+		sh.client.Close()
+	}()
+
+	if !isTerminal {
+		return sh.runScript(os.Stdin)
+	}
+
+	sh.printf("Connected to %s.  Type help for the available commands "+
+		"and exit to quit.\n", cfg.RPCServer)
+	for {
+		line, err := sh.term.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && err != terminal.ErrPasteIndicator {
+			return err
+		}
+		exit, err := sh.execute(line)
+		if err != nil {
+			sh.printError(err)
+		}
+		if exit {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSplitPipeline ensures lines are split into the commands of a pipeline
+// and their words as expected, including quoting and escaping.
+func TestSplitPipeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    [][]string
+		wantErr string
+	}{{
+		name: "empty line",
+		line: "",
+		want: [][]string{nil},
+	}, {
+		name: "single command",
+		line: "getblockhash 100",
+		want: [][]string{{"getblockhash", "100"}},
+	}, {
+		name: "extra whitespace",
+		line: " \tgetblockhash   100\t ",
+		want: [][]string{{"getblockhash", "100"}},
+	}, {
+		name: "pipeline",
+		line: "getbestblockhash | getblock - false",
+		want: [][]string{{"getbestblockhash"}, {"getblock", "-", "false"}},
+	}, {
+		name: "pipeline without spaces",
+		line: "getbestblockhash|getblock",
+		want: [][]string{{"getbestblockhash"}, {"getblock"}},
+	}, {
+		name: "empty commands in pipeline",
+		line: "| getblock |",
+		want: [][]string{nil, {"getblock"}, nil},
+	}, {
+		name: "double quotes",
+		line: `decodescript "01 02" "a|b"`,
+		want: [][]string{{"decodescript", "01 02", "a|b"}},
+	}, {
+		name: "single quotes",
+		line: `sendrawtransaction '{"a": "b c"}'`,
+		want: [][]string{{"sendrawtransaction", `{"a": "b c"}`}},
+	}, {
+		name: "quotes within a word",
+		line: `cmd ab"c d"e`,
+		want: [][]string{{"cmd", "abc de"}},
+	}, {
+		name: "empty quotes",
+		line: `cmd "" ''`,
+		want: [][]string{{"cmd", "", ""}},
+	}, {
+		name: "escaped characters",
+		line: `cmd a\ b \| \"c\\`,
+		want: [][]string{{"cmd", "a b", "|", `"c\`}},
+	}, {
+		name: "escapes within double quotes",
+		line: `cmd "a\"b\\c"`,
+		want: [][]string{{"cmd", `a"b\c`}},
+	}, {
+		name: "no escapes within single quotes",
+		line: `cmd 'a\b'`,
+		want: [][]string{{"cmd", `a\b`}},
+	}, {
+		name:    "unterminated double quote",
+		line:    `cmd "a b`,
+		wantErr: `unterminated " quote`,
+	}, {
+		name:    "unterminated single quote",
+		line:    `cmd 'a | b`,
+		wantErr: "unterminated ' quote",
+	}, {
+		name:    "unterminated escape",
+		line:    `cmd a\`,
+		wantErr: "unterminated backslash escape",
+	}}
+
+	for _, test := range tests {
+		got, err := splitPipeline(test.line)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("%s: unexpected error - got %v, want %s",
+					test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: unexpected pipeline - got %q, want %q",
+				test.name, got, test.want)
+		}
+	}
+}
+
+// TestPipeArgs ensures the result of a command is passed to the next command
+// of a pipeline as expected.
+func TestPipeArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		result  string
+		want    []string
+		wantErr bool
+	}{{
+		name:   "appended string result",
+		args:   nil,
+		result: `"0000abcd"`,
+		want:   []string{"0000abcd"},
+	}, {
+		name:   "appended after arguments",
+		args:   []string{"false"},
+		result: "\"0000abcd\"\n",
+		want:   []string{"false", "0000abcd"},
+	}, {
+		name:   "replaced placeholders",
+		args:   []string{"-", "false", "-"},
+		result: `"0000abcd"`,
+		want:   []string{"0000abcd", "false", "0000abcd"},
+	}, {
+		name:   "escaped string result",
+		args:   nil,
+		result: `"a\"b"`,
+		want:   []string{`a"b`},
+	}, {
+		name:   "number result",
+		args:   []string{"-"},
+		result: "100",
+		want:   []string{"100"},
+	}, {
+		name:   "object result",
+		args:   nil,
+		result: `{"hash": "00"}`,
+		want:   []string{`{"hash": "00"}`},
+	}, {
+		name:    "empty result",
+		args:    []string{"-"},
+		result:  " \n",
+		wantErr: true,
+	}, {
+		name:    "null result",
+		args:    []string{"-"},
+		result:  "null",
+		wantErr: true,
+	}, {
+		name:    "malformed string result",
+		args:    nil,
+		result:  `"abc`,
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		got, err := pipeArgs(test.args, []byte(test.result))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: did not receive expected error",
+					test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: unexpected arguments - got %q, want %q",
+				test.name, got, test.want)
+		}
+	}
+}
+
+// TestCompletion ensures the candidates for completing commands and their
+// arguments are found as expected.
+func TestCompletion(t *testing.T) {
+	methods := shellMethods()
+	for _, method := range []string{"getblock", "exit", "help", "quit"} {
+		if len(matchPrefix(methods, method)) == 0 {
+			t.Errorf("shell methods do not include %s", method)
+		}
+	}
+	for _, method := range methods {
+		if method == "blockconnected" {
+			t.Errorf("shell methods include notification %s", method)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		index   int
+		partial string
+		want    []string
+	}{{
+		name:    "boolean argument",
+		method:  "getblock",
+		index:   1,
+		partial: "",
+		want:    []string{"false", "true"},
+	}, {
+		name:    "partial boolean argument",
+		method:  "getblock",
+		index:   2,
+		partial: "t",
+		want:    []string{"true"},
+	}, {
+		name:    "argument without known values",
+		method:  "getblock",
+		index:   0,
+		partial: "",
+		want:    nil,
+	}, {
+		name:    "argument out of range",
+		method:  "getblock",
+		index:   3,
+		partial: "",
+		want:    nil,
+	}, {
+		name:    "unknown method",
+		method:  "bogus",
+		index:   0,
+		partial: "",
+		want:    nil,
+	}}
+
+	for _, test := range tests {
+		got := argCandidates(test.method, test.index, test.partial)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: unexpected candidates - got %q, want %q",
+				test.name, got, test.want)
+		}
+	}
+
+	prefixTests := []struct {
+		values []string
+		want   string
+	}{
+		{nil, ""},
+		{[]string{"getblock"}, "getblock"},
+		{[]string{"getblock", "getblockhash", "getblockheader"}, "getblock"},
+		{[]string{"getblock", "help"}, ""},
+	}
+	for _, test := range prefixTests {
+		got := longestCommonPrefix(test.values)
+		if got != test.want {
+			t.Errorf("longestCommonPrefix(%q): got %q, want %q",
+				test.values, got, test.want)
+		}
+	}
+}
+
+// TestShellExecuteErrors ensures that lines which can't be sent to the RPC
+// server are rejected before a connection is needed, and that the builtins,
+// comments and blank lines are handled as expected.
+func TestShellExecuteErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		wantExit bool
+		wantErr  string
+	}{{
+		name: "blank line",
+		line: "  \n",
+	}, {
+		name: "comment",
+		line: "# getblock 00",
+	}, {
+		name:     "exit",
+		line:     "exit",
+		wantExit: true,
+	}, {
+		name:     "quit with trailing space",
+		line:     "quit \n",
+		wantExit: true,
+	}, {
+		name:    "unterminated quote",
+		line:    `getblock "00`,
+		wantErr: `unterminated " quote`,
+	}, {
+		name:    "builtin in pipeline",
+		line:    "exit | getblock",
+		wantErr: "Unrecognized command 'exit'",
+	}, {
+		name:    "empty command in pipeline",
+		line:    "| getblock",
+		wantErr: "empty command in pipeline",
+	}, {
+		name:    "unknown command",
+		line:    "bogus 1",
+		wantErr: "Unrecognized command 'bogus'",
+	}, {
+		name:    "notification",
+		line:    "blockconnected 00 00",
+		wantErr: "The 'blockconnected' command is a notification",
+	}, {
+		name:    "invalid argument",
+		line:    "getblockhash abc",
+		wantErr: "getblockhash command:",
+	}, {
+		name:    "too many arguments",
+		line:    "getbestblockhash 1",
+		wantErr: "getbestblockhash command:",
+	}}
+
+	sh := &shell{cfg: &config{}}
+	for _, test := range tests {
+		exit, err := sh.execute(test.line)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: unexpected error - got %v, want %s",
+					test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if exit != test.wantExit {
+			t.Errorf("%s: unexpected exit - got %v, want %v",
+				test.name, exit, test.wantExit)
+		}
+	}
+}
+
+// TestRunScript ensures scripts are executed line by line until they end, exit
+// or a command fails.
+func TestRunScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{{
+		name:   "empty script",
+		script: "",
+	}, {
+		name:   "comments and blank lines",
+		script: "# comment\n\n   \n# getblock 00\n",
+	}, {
+		name:   "exit without trailing newline",
+		script: "# comment\nexit",
+	}, {
+		name:   "commands after exit are not run",
+		script: "exit\nbogus\n",
+	}, {
+		name:    "failing command",
+		script:  "# comment\nbogus\nexit\n",
+		wantErr: "Unrecognized command 'bogus'",
+	}, {
+		name:    "failing last line without trailing newline",
+		script:  "\ngetblockhash 'abc",
+		wantErr: "unterminated ' quote",
+	}}
+
+	sh := &shell{cfg: &config{}}
+	for _, test := range tests {
+		err := sh.runScript(strings.NewReader(test.script))
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: unexpected error - got %v, want %s",
+					test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/hcjson"
+)
+
+const (
+	// subscribeCmdName is the name of the command which streams the
+	// notifications of the RPC server.
+	subscribeCmdName = "subscribe"
+
+	// minReconnectDelay and maxReconnectDelay bound the delay before
+	// reconnecting to the RPC server after the connection failed.  The
+	// delay doubles after each failed attempt.
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// subscriptionTopic describes a topic accepted by the subscribe command.
+type subscriptionTopic struct {
+	name   string
+	ntfns  string
+	newCmd func() interface{}
+}
+
+// subscriptionTopics are the topics accepted by the subscribe command along
+// with the notifications they stream and the command which requests them.
+var subscriptionTopics = []subscriptionTopic{{
+	name:   "blocks",
+	ntfns:  "blockconnected, blockdisconnected, reorganization",
+	newCmd: func() interface{} { return hcjson.NewNotifyBlocksCmd() },
+}, {
+	name:  "newtxs",
+	ntfns: "txaccepted",
+	newCmd: func() interface{} {
+		return hcjson.NewNotifyNewTransactionsCmd(hcjson.Bool(false))
+	},
+}, {
+	name:  "newtxsverbose",
+	ntfns: "txacceptedverbose",
+	newCmd: func() interface{} {
+		return hcjson.NewNotifyNewTransactionsCmd(hcjson.Bool(true))
+	},
+}, {
+	name:   "newtickets",
+	ntfns:  "newtickets",
+	newCmd: func() interface{} { return hcjson.NewNotifyNewTicketsCmd() },
+}, {
+	name:  "spentandmissedtickets",
+	ntfns: "spentandmissedtickets",
+	newCmd: func() interface{} {
+		return hcjson.NewNotifySpentAndMissedTicketsCmd()
+	},
+}, {
+	name:   "stakedifficulty",
+	ntfns:  "stakedifficulty",
+	newCmd: func() interface{} { return hcjson.NewNotifyStakeDifficultyCmd() },
+}, {
+	name:   "winningtickets",
+	ntfns:  "winningtickets",
+	newCmd: func() interface{} { return hcjson.NewNotifyWinningTicketsCmd() },
+}}
+
+// subscribeUsage writes the usage of the subscribe command to w.
+func subscribeUsage(w io.Writer) {
+	fmt.Fprintf(w, "%s <topic|address=addr|outpoint=hash:index[:tree]...>\n",
+		subscribeCmdName)
+	fmt.Fprintln(w, "  Streams the notifications of the topics as "+
+		"line-delimited JSON.  Transactions")
+	fmt.Fprintln(w, "  paying to the addresses or spending the outpoints "+
+		"are streamed as relevanttxaccepted")
+	fmt.Fprintln(w, "  notifications and as part of blockconnected "+
+		"notifications.  Topics:")
+	for _, topic := range subscriptionTopics {
+		fmt.Fprintf(w, "    %-22s %s\n", topic.name, topic.ntfns)
+	}
+}
+
+// subscription describes the notifications requested by the arguments of the
+// subscribe command.
+type subscription struct {
+	cmds      []interface{}
+	addresses []string
+	outPoints []hcjson.OutPoint
+}
+
+// parseSubscription parses the arguments of the subscribe command.
+func parseSubscription(args []string) (*subscription, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no topics specified")
+	}
+
+	var sub subscription
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "address="):
+			sub.addresses = append(sub.addresses,
+				strings.TrimPrefix(arg, "address="))
+
+		case strings.HasPrefix(arg, "outpoint="):
+			op, err := parseOutPoint(strings.TrimPrefix(arg, "outpoint="))
+			if err != nil {
+				return nil, err
+			}
+			sub.outPoints = append(sub.outPoints, *op)
+
+		default:
+			if seen[arg] {
+				continue
+			}
+			var found bool
+			for _, topic := range subscriptionTopics {
+				if topic.name == arg {
+					sub.cmds = append(sub.cmds, topic.newCmd())
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unknown topic '%s'", arg)
+			}
+			seen[arg] = true
+		}
+	}
+	if seen["newtxs"] && seen["newtxsverbose"] {
+		return nil, errors.New("the newtxs and newtxsverbose topics " +
+			"can't be used together")
+	}
+	return &sub, nil
+}
+
+// parseOutPoint parses an outpoint in the form hash:index[:tree].  The tree
+// defaults to the regular transaction tree.
+func parseOutPoint(s string) (*hcjson.OutPoint, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("outpoint '%s' is not in the form "+
+			"hash:index[:tree]", s)
+	}
+	if _, err := chainhash.NewHashFromStr(parts[0]); err != nil {
+		return nil, fmt.Errorf("outpoint '%s' has an invalid hash: %v",
+			s, err)
+	}
+	index, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("outpoint '%s' has an invalid index: %v",
+			s, err)
+	}
+	var tree int64
+	if len(parts) == 3 {
+		tree, err = strconv.ParseInt(parts[2], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("outpoint '%s' has an invalid "+
+				"tree: %v", s, err)
+		}
+	}
+	return &hcjson.OutPoint{
+		Hash:  parts[0],
+		Index: uint32(index),
+		Tree:  int8(tree),
+	}, nil
+}
+
+// subscriptionEvent is a notification as it is streamed by the subscribe
+// command.
+type subscriptionEvent struct {
+	Time   string            `json:"time"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// register registers the subscription with the passed client.
+func (sub *subscription) register(client *wsClient) error {
+	if len(sub.addresses) > 0 || len(sub.outPoints) > 0 {
+		cmd := hcjson.NewLoadTxFilterCmd(true, sub.addresses,
+			sub.outPoints)
+		if _, err := client.sendCmd(cmd); err != nil {
+			return fmt.Errorf("loadtxfilter: %v", err)
+		}
+	}
+	for _, cmd := range sub.cmds {
+		if _, err := client.sendCmd(cmd); err != nil {
+			method, _ := hcjson.CmdMethod(cmd)
+			return fmt.Errorf("%s: %v", method, err)
+		}
+	}
+	return nil
+}
+
+// newSubscriptionWriter returns a notification handler which writes every
+// notification to the passed writer as a line-delimited JSON event.
+func newSubscriptionWriter(w io.Writer) func(method string, params []json.RawMessage) {
+	var mtx sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(method string, params []json.RawMessage) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		enc.Encode(&subscriptionEvent{
+			Time:   time.Now().UTC().Format(time.RFC3339),
+			Method: method,
+			Params: params,
+		})
+	}
+}
+
+// runSubscribe connects to the RPC server, registers the notifications
+// requested by the passed arguments and writes them to standard output as
+// line-delimited JSON until interrupted.  The connection is reestablished and
+// the notifications are registered again whenever it fails.  Status messages
+// are written to standard error.
+func runSubscribe(cfg *config, args []string) error {
+	sub, err := parseSubscription(args)
+	if err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	notify := newSubscriptionWriter(os.Stdout)
+	delay := minReconnectDelay
+	for {
+		client, err := newWSClient(cfg, notify, nil)
+		if err == nil {
+			err = sub.register(client)
+			if err == nil {
+				fmt.Fprintf(os.Stderr, "Subscribed to %s\n",
+					cfg.RPCServer)
+				delay = minReconnectDelay
+				select {
+				case <-client.disconnected:
+					err = errDisconnected
+				case <-interrupt:
+					client.Close()
+					return nil
+				}
+			}
+			client.Close()
+		}
+
+		fmt.Fprintf(os.Stderr, "%v -- reconnecting in %v\n", err, delay)
+		select {
+		case <-time.After(delay):
+		case <-interrupt:
+			return nil
+		}
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/websocket"
+	"github.com/james-ray/hcd/hcjson"
+)
+
+// testOutPointHash is a valid hash used for the outpoints in the tests.
+const testOutPointHash = "c9d9e4d0b8b1e3d2a5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8"
+
+// TestParseSubscription ensures the arguments of the subscribe command are
+// parsed into the expected notification requests.
+func TestParseSubscription(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    *subscription
+		wantErr string
+	}{{
+		name:    "no topics",
+		args:    nil,
+		wantErr: "no topics specified",
+	}, {
+		name: "topics",
+		args: []string{"blocks", "newtxsverbose", "winningtickets"},
+		want: &subscription{cmds: []interface{}{
+			hcjson.NewNotifyBlocksCmd(),
+			hcjson.NewNotifyNewTransactionsCmd(hcjson.Bool(true)),
+			hcjson.NewNotifyWinningTicketsCmd(),
+		}},
+	}, {
+		name: "duplicate topics",
+		args: []string{"newtxs", "newtickets", "newtxs"},
+		want: &subscription{cmds: []interface{}{
+			hcjson.NewNotifyNewTransactionsCmd(hcjson.Bool(false)),
+			hcjson.NewNotifyNewTicketsCmd(),
+		}},
+	}, {
+		name: "addresses and outpoints",
+		args: []string{"address=HsAddr1", "outpoint=" + testOutPointHash +
+			":1", "address=HsAddr2", "outpoint=" + testOutPointHash +
+			":4294967295:1"},
+		want: &subscription{
+			addresses: []string{"HsAddr1", "HsAddr2"},
+			outPoints: []hcjson.OutPoint{
+				{Hash: testOutPointHash, Index: 1, Tree: 0},
+				{Hash: testOutPointHash, Index: 4294967295, Tree: 1},
+			},
+		},
+	}, {
+		name:    "unknown topic",
+		args:    []string{"blocks", "bogus"},
+		wantErr: "unknown topic 'bogus'",
+	}, {
+		name:    "conflicting topics",
+		args:    []string{"newtxsverbose", "newtxs"},
+		wantErr: "can't be used together",
+	}, {
+		name:    "outpoint without index",
+		args:    []string{"outpoint=" + testOutPointHash},
+		wantErr: "is not in the form hash:index[:tree]",
+	}, {
+		name:    "outpoint with too many fields",
+		args:    []string{"outpoint=" + testOutPointHash + ":1:0:0"},
+		wantErr: "is not in the form hash:index[:tree]",
+	}, {
+		name:    "outpoint with invalid hash",
+		args:    []string{"outpoint=xyz:1"},
+		wantErr: "has an invalid hash",
+	}, {
+		name:    "outpoint with invalid index",
+		args:    []string{"outpoint=" + testOutPointHash + ":4294967296"},
+		wantErr: "has an invalid index",
+	}, {
+		name:    "outpoint with invalid tree",
+		args:    []string{"outpoint=" + testOutPointHash + ":1:128"},
+		wantErr: "has an invalid tree",
+	}}
+
+	for _, test := range tests {
+		got, err := parseSubscription(test.args)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: unexpected error - got %v, want %s",
+					test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: unexpected subscription - got %+v, want %+v",
+				test.name, got, test.want)
+		}
+	}
+}
+
+// TestSubscriptionRegister ensures a subscription sends the expected requests
+// to the RPC server and that the notifications received afterwards are written
+// as line-delimited JSON events.
+func TestSubscriptionRegister(t *testing.T) {
+	sub, err := parseSubscription([]string{"blocks", "address=HsAddr1",
+		"newtxs", "outpoint=" + testOutPointHash + ":2:1"})
+	if err != nil {
+		t.Fatalf("parseSubscription: unexpected error: %v", err)
+	}
+
+	// The server answers every request with a null result and sends a
+	// notification once all of them were received.
+	wantRequests := []string{
+		`{"jsonrpc":"1.0","method":"loadtxfilter","params":[true,` +
+			`["HsAddr1"],[{"hash":"` + testOutPointHash + `",` +
+			`"tree":1,"index":2}]],"id":1}`,
+		`{"jsonrpc":"1.0","method":"notifyblocks","params":[],"id":2}`,
+		`{"jsonrpc":"1.0","method":"notifynewtransactions",` +
+			`"params":[false],"id":3}`,
+	}
+	cfg, stop := newTestWSServer(t, func(conn *websocket.Conn) {
+		for _, want := range wantRequests {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				t.Errorf("read request: %v", err)
+				return
+			}
+			if string(msg) != want {
+				t.Errorf("unexpected request - got %s, want %s",
+					msg, want)
+			}
+			var req testRequest
+			json.Unmarshal(msg, &req)
+			reply := `{"result":null,"error":null,"id":` +
+				strconv.FormatUint(req.ID, 10) + `}`
+			err = conn.WriteMessage(websocket.TextMessage,
+				[]byte(reply))
+			if err != nil {
+				t.Errorf("write reply: %v", err)
+				return
+			}
+		}
+		ntfn := `{"jsonrpc":"1.0","method":"txaccepted","params":` +
+			`["` + testOutPointHash + `",1.5]}`
+		err := conn.WriteMessage(websocket.TextMessage, []byte(ntfn))
+		if err != nil {
+			t.Errorf("write notification: %v", err)
+			return
+		}
+		readTestRequest(conn)
+	})
+	defer stop()
+
+	var out bytes.Buffer
+	written := make(chan struct{})
+	write := newSubscriptionWriter(&out)
+	notify := func(method string, params []json.RawMessage) {
+		write(method, params)
+		close(written)
+	}
+	client, err := newWSClient(cfg, notify, nil)
+	if err != nil {
+		t.Fatalf("newWSClient: unexpected error: %v", err)
+	}
+	defer client.Close()
+	if err := sub.register(client); err != nil {
+		t.Fatalf("register: unexpected error: %v", err)
+	}
+
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatalf("notification was not written")
+	}
+	line := out.String()
+	if !strings.HasSuffix(line, "\n") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("notification is not a single line: %q", line)
+	}
+	var event subscriptionEvent
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		t.Fatalf("unable to decode event %q: %v", line, err)
+	}
+	if _, err := time.Parse(time.RFC3339, event.Time); err != nil {
+		t.Errorf("unexpected event time %q: %v", event.Time, err)
+	}
+	if event.Method != "txaccepted" || len(event.Params) != 2 ||
+		string(event.Params[0]) != `"`+testOutPointHash+`"` ||
+		string(event.Params[1]) != "1.5" {
+
+		t.Errorf("unexpected event: %s", line)
+	}
+}
+
+// TestSubscriptionRegisterError ensures a request rejected by the RPC server
+// fails the registration with an error naming the request.
+func TestSubscriptionRegisterError(t *testing.T) {
+	sub, err := parseSubscription([]string{"stakedifficulty"})
+	if err != nil {
+		t.Fatalf("parseSubscription: unexpected error: %v", err)
+	}
+
+	cfg, stop := newTestWSServer(t, func(conn *websocket.Conn) {
+		req, err := readTestRequest(conn)
+		if err != nil {
+			t.Errorf("read request: %v", err)
+			return
+		}
+		reply := `{"result":null,"error":{"code":-32601,"message":` +
+			`"Method not found"},"id":` +
+			strconv.FormatUint(req.ID, 10) + `}`
+		conn.WriteMessage(websocket.TextMessage, []byte(reply))
+		readTestRequest(conn)
+	})
+	defer stop()
+
+	client, err := newWSClient(cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("newWSClient: unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	err = sub.register(client)
+	want := "notifystakedifficulty: -32601: Method not found"
+	if err == nil || err.Error() != want {
+		t.Errorf("register: unexpected error - got %v, want %s", err,
+			want)
+	}
+}
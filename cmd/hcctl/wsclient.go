@@ -0,0 +1,222 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/btcsuite/websocket"
+	"github.com/james-ray/hcd/hcjson"
+)
+
+// errDisconnected is returned for commands which are pending or sent while the
+// websocket connection to the RPC server is lost.
+var errDisconnected = errors.New("the connection to the RPC server was lost")
+
+// wsMessage describes any message the RPC server sends over a websocket
+// connection, which is either the response to a command or a notification.
+type wsMessage struct {
+	ID     *uint64           `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  *hcjson.RPCError  `json:"error"`
+}
+
+// wsResponse houses the result of a command sent over a websocket connection.
+type wsResponse struct {
+	result []byte
+	err    error
+}
+
+// wsClient sends commands to the RPC server over a persistent websocket
+// connection and passes the notifications it receives to a handler.
+type wsClient struct {
+	conn   *websocket.Conn
+	notify func(method string, params []json.RawMessage)
+	trace  func(msg []byte)
+
+	// writeMtx serializes the writes to the connection.
+	writeMtx sync.Mutex
+
+	// These fields track the commands awaiting a response and are
+	// protected by the mutex.
+	mtx     sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *wsResponse
+
+	// disconnected is closed once the connection is lost.
+	disconnected chan struct{}
+}
+
+// newWSClient connects to the websocket endpoint of the RPC server described
+// in the passed config struct and returns a client for it.  The passed handler
+// is called from a separate goroutine for every notification received.  The
+// optional trace function is called with the raw JSON of every command sent
+// and every response received.
+func newWSClient(cfg *config, notify func(method string, params []json.RawMessage), trace func(msg []byte)) (*wsClient, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dialer := websocket.Dialer{
+		NetDial:         newDialFunc(cfg),
+		TLSClientConfig: tlsConfig,
+	}
+
+	// The RPC server authenticates the connection using the basic access
+	// authorization header of the websocket handshake.
+	scheme := "ws"
+	if !cfg.NoTLS {
+		scheme = "wss"
+	}
+	url := scheme + "://" + cfg.RPCServer + "/ws"
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(cfg.RPCUser, cfg.RPCPassword)
+	conn, resp, err := dialer.Dial(url, req.Header)
+	if err != nil {
+		if err == websocket.ErrBadHandshake && resp != nil {
+			return nil, fmt.Errorf("websocket handshake failed: %d %s",
+				resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+		return nil, err
+	}
+
+	c := &wsClient{
+		conn:         conn,
+		notify:       notify,
+		trace:        trace,
+		nextID:       1,
+		pending:      make(map[uint64]chan *wsResponse),
+		disconnected: make(chan struct{}),
+	}
+	go c.inHandler()
+	return c, nil
+}
+
+// inHandler reads the messages from the connection and dispatches responses to
+// the commands awaiting them and notifications to the notification handler
+// until the connection is lost.  It must be run as a goroutine.
+func (c *wsClient) inHandler() {
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		var m wsMessage
+		if err := json.Unmarshal(msg, &m); err != nil {
+			continue
+		}
+
+		// Notifications are requests from the server without an id.
+		if m.ID == nil {
+			if m.Method != "" && c.notify != nil {
+				c.notify(m.Method, m.Params)
+			}
+			continue
+		}
+
+		if c.trace != nil {
+			c.trace(msg)
+		}
+		c.mtx.Lock()
+		respChan, ok := c.pending[*m.ID]
+		delete(c.pending, *m.ID)
+		c.mtx.Unlock()
+		if !ok {
+			continue
+		}
+		if m.Error != nil {
+			respChan <- &wsResponse{err: m.Error}
+			continue
+		}
+		respChan <- &wsResponse{result: m.Result}
+	}
+
+	// Fail all pending commands now that no responses will arrive.
+	c.mtx.Lock()
+	close(c.disconnected)
+	for id, respChan := range c.pending {
+		respChan <- &wsResponse{err: errDisconnected}
+		delete(c.pending, id)
+	}
+	c.mtx.Unlock()
+}
+
+// sendCmd sends the passed command to the RPC server and waits for its result.
+func (c *wsClient) sendCmd(cmd interface{}) ([]byte, error) {
+	// Register the command before sending it so the response can't arrive
+	// first.  The channel is buffered so the handler never blocks.
+	respChan := make(chan *wsResponse, 1)
+	c.mtx.Lock()
+	select {
+	case <-c.disconnected:
+		c.mtx.Unlock()
+		return nil, errDisconnected
+	default:
+	}
+	id := c.nextID
+	c.nextID++
+	c.pending[id] = respChan
+	c.mtx.Unlock()
+
+	marshalledJSON, err := hcjson.MarshalCmd(id, cmd)
+	if err != nil {
+		c.removePending(id)
+		return nil, err
+	}
+	if c.trace != nil {
+		c.trace(marshalledJSON)
+	}
+
+	c.writeMtx.Lock()
+	err = c.conn.WriteMessage(websocket.TextMessage, marshalledJSON)
+	c.writeMtx.Unlock()
+	if err != nil {
+		c.removePending(id)
+		return nil, err
+	}
+
+	resp := <-respChan
+	if resp.err != nil {
+		return nil, resp.err
+	}
+	return resp.result, nil
+}
+
+// removePending stops tracking the command with the passed id.
+func (c *wsClient) removePending(id uint64) {
+	c.mtx.Lock()
+	delete(c.pending, id)
+	c.mtx.Unlock()
+}
+
+// isDisconnected returns whether or not the connection to the RPC server has
+// been lost.
+func (c *wsClient) isDisconnected() bool {
+	select {
+	case <-c.disconnected:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close closes the connection to the RPC server.
+func (c *wsClient) Close() {
+	c.writeMtx.Lock()
+	_ = c.conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.writeMtx.Unlock()
+	c.conn.Close()
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/websocket"
+	"github.com/james-ray/hcd/hcjson"
+)
+
+const (
+	// testRPCUser and testRPCPass are the credentials the test RPC server
+	// accepts.
+	testRPCUser = "user"
+	testRPCPass = "pass"
+)
+
+// testRequest is a request received by the test RPC server.
+type testRequest struct {
+	ID     uint64            `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// newTestWSServer starts a websocket RPC server which authenticates the
+// connections with the test credentials and passes them to the passed handler.
+// It returns the config to connect to it and a function to stop it.
+func newTestWSServer(t *testing.T, handler func(conn *websocket.Conn)) (*config, func()) {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if r.URL.Path != "/ws" || !ok || user != testRPCUser ||
+			pass != testRPCPass {
+
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade: unexpected error: %v", err)
+			return
+		}
+		defer conn.Close()
+		handler(conn)
+	}))
+	cfg := &config{
+		RPCServer:   strings.TrimPrefix(srv.URL, "http://"),
+		RPCUser:     testRPCUser,
+		RPCPassword: testRPCPass,
+		NoTLS:       true,
+	}
+	return cfg, srv.Close
+}
+
+// readTestRequest reads the next request sent to the test RPC server.
+func readTestRequest(conn *websocket.Conn) (*testRequest, error) {
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var req testRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// TestWSClient ensures the websocket client sends commands, decodes their
+// responses and dispatches notifications as expected.
+func TestWSClient(t *testing.T) {
+	// The server answers getblockcount with a notification, a malformed
+	// message, a response to an unknown command and then the response,
+	// fails getblockhash and closes the connection after reading the next
+	// request.
+	cfg, stop := newTestWSServer(t, func(conn *websocket.Conn) {
+		req, err := readTestRequest(conn)
+		if err != nil {
+			t.Errorf("read request: %v", err)
+			return
+		}
+		if req.Method != "getblockcount" || len(req.Params) != 0 {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		replies := []string{
+			`{"jsonrpc":"1.0","method":"blockconnected","params":["00ff","0011"]}`,
+			`not json`,
+			`{"result":1,"error":null,"id":1000}`,
+			`{"result":100,"error":null,"id":` +
+				strconv.FormatUint(req.ID, 10) + `}`,
+		}
+		for _, reply := range replies {
+			err := conn.WriteMessage(websocket.TextMessage,
+				[]byte(reply))
+			if err != nil {
+				t.Errorf("write reply: %v", err)
+				return
+			}
+		}
+
+		req, err = readTestRequest(conn)
+		if err != nil {
+			t.Errorf("read request: %v", err)
+			return
+		}
+		if req.Method != "getblockhash" || len(req.Params) != 1 ||
+			string(req.Params[0]) != "5000" {
+
+			t.Errorf("unexpected request: %+v", req)
+		}
+		reply := `{"result":null,"error":{"code":-8,"message":"Block ` +
+			`number out of range"},"id":` + strconv.FormatUint(req.ID, 10) + `}`
+		err = conn.WriteMessage(websocket.TextMessage, []byte(reply))
+		if err != nil {
+			t.Errorf("write reply: %v", err)
+			return
+		}
+
+		// Close the connection with the last command pending.
+		readTestRequest(conn)
+	})
+	defer stop()
+
+	type notification struct {
+		method string
+		params []json.RawMessage
+	}
+	notifications := make(chan notification, 1)
+	notify := func(method string, params []json.RawMessage) {
+		notifications <- notification{method, params}
+	}
+	var traced []string
+	trace := func(msg []byte) {
+		traced = append(traced, string(msg))
+	}
+	client, err := newWSClient(cfg, notify, trace)
+	if err != nil {
+		t.Fatalf("newWSClient: unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	// Ensure the result of the command is returned and the notification
+	// sent before it is dispatched.
+	result, err := client.sendCmd(hcjson.NewGetBlockCountCmd())
+	if err != nil {
+		t.Fatalf("getblockcount: unexpected error: %v", err)
+	}
+	if string(result) != "100" {
+		t.Errorf("getblockcount: unexpected result - got %s, want 100",
+			result)
+	}
+	select {
+	case n := <-notifications:
+		if n.method != "blockconnected" || len(n.params) != 2 ||
+			string(n.params[0]) != `"00ff"` ||
+			string(n.params[1]) != `"0011"` {
+
+			t.Errorf("unexpected notification: %s %s", n.method,
+				n.params)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("notification was not dispatched")
+	}
+
+	// Ensure the sent command and all responses, but not the
+	// notification, were traced.
+	if len(traced) != 3 ||
+		!strings.Contains(traced[0], `"method":"getblockcount"`) ||
+		!strings.Contains(traced[1], `"id":1000`) ||
+		!strings.Contains(traced[2], `"result":100`) {
+
+		t.Errorf("unexpected traced messages: %q", traced)
+	}
+
+	// Ensure errors returned by the server are decoded.
+	_, err = client.sendCmd(hcjson.NewGetBlockHashCmd(5000))
+	rpcErr, ok := err.(*hcjson.RPCError)
+	if !ok {
+		t.Fatalf("getblockhash: unexpected error - got %v (%T), want "+
+			"*hcjson.RPCError", err, err)
+	}
+	if rpcErr.Code != hcjson.ErrRPCInvalidParameter ||
+		rpcErr.Message != "Block number out of range" {
+
+		t.Errorf("getblockhash: unexpected error: %v", rpcErr)
+	}
+
+	// Ensure the pending command fails once the connection is lost, and
+	// that commands can't be sent afterwards.
+	_, err = client.sendCmd(hcjson.NewGetBestBlockHashCmd())
+	if err != errDisconnected {
+		t.Errorf("getbestblockhash: unexpected error - got %v, want %v",
+			err, errDisconnected)
+	}
+	if !client.isDisconnected() {
+		t.Errorf("client is not disconnected")
+	}
+	_, err = client.sendCmd(hcjson.NewGetBlockCountCmd())
+	if err != errDisconnected {
+		t.Errorf("getblockcount: unexpected error - got %v, want %v",
+			err, errDisconnected)
+	}
+}
+
+// TestWSClientAuthFailure ensures a failed authentication is reported.
+func TestWSClientAuthFailure(t *testing.T) {
+	cfg, stop := newTestWSServer(t, func(conn *websocket.Conn) {
+		t.Errorf("unexpected connection")
+	})
+	defer stop()
+
+	cfg.RPCPassword = "wrong"
+	_, err := newWSClient(cfg, nil, nil)
+	want := "websocket handshake failed: 401 Unauthorized"
+	if err == nil || err.Error() != want {
+		t.Errorf("newWSClient: unexpected error - got %v, want %s", err,
+			want)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/wire"
+)
+
+var (
+	activeNetParams = &chaincfg.MainNetParams
+
+	// Default global config.
+	cfg = &config{}
+
+	// stdout is the writer the results of the commands are written to.
+	stdout io.Writer = os.Stdout
+)
+
+// config defines the global configuration options.
+type config struct {
+	TestNet bool `long:"testnet" description:"Use the test network"`
+	SimNet  bool `long:"simnet" description:"Use the simulation test network"`
+}
+
+// setupGlobalConfig examine the global configuration options for any conditions
+// which are invalid as well as performs any addition setup necessary after the
+// initial parse.
+func setupGlobalConfig() error {
+	// Multiple networks can't be selected simultaneously.
+	// Count number of network flags passed; assign active network params
+	// while we're at it
+	numNets := 0
+	if cfg.TestNet {
+		numNets++
+		activeNetParams = &chaincfg.TestNet2Params
+	}
+	if cfg.SimNet {
+		numNets++
+		activeNetParams = &chaincfg.SimNetParams
+	}
+	if numNets > 1 {
+		return errors.New("the testnet and simnet params can't be " +
+			"used together -- choose one of the two")
+	}
+
+	return nil
+}
+
+// readTx deserializes the hex-encoded transaction passed as the first of the
+// command arguments.  The transaction is read from standard input when the
+// argument is "-".
+func readTx(args []string) (*wire.MsgTx, error) {
+	if len(args) < 1 {
+		return nil, errors.New("required transaction parameter not " +
+			"specified")
+	}
+	hexStr := args[0]
+	if hexStr == "-" {
+		b, err := ioutil.ReadAll(bufio.NewReader(os.Stdin))
+		if err != nil {
+			return nil, err
+		}
+		hexStr = string(b)
+	}
+	return decodeTx(strings.TrimSpace(hexStr))
+}
+
+// decodeTx deserializes the passed hex-encoded transaction.
+func decodeTx(hexStr string) (*wire.MsgTx, error) {
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, fmt.Errorf("transaction is not valid hex: %v", err)
+	}
+	var mtx wire.MsgTx
+	if err := mtx.Deserialize(bytes.NewReader(serializedTx)); err != nil {
+		return nil, fmt.Errorf("could not decode transaction: %v", err)
+	}
+	return &mtx, nil
+}
+
+// writeTx writes the passed transaction to standard output as hex.
+func writeTx(mtx *wire.MsgTx) error {
+	var buf bytes.Buffer
+	buf.Grow(mtx.SerializeSize())
+	if err := mtx.Serialize(&buf); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, hex.EncodeToString(buf.Bytes()))
+	return nil
+}
+
+// writeJSON writes the passed value to standard output as indented JSON.
+func writeJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, string(b))
+	return nil
+}
+
+// parseAmount parses an amount in coins.
+func parseAmount(s string) (hcutil.Amount, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount '%s'", s)
+	}
+	amt, err := hcutil.NewAmount(f)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount '%s': %v", s, err)
+	}
+	if amt < 0 || amt > hcutil.MaxAmount {
+		return 0, fmt.Errorf("amount %v is out of range", amt)
+	}
+	return amt, nil
+}
+
+// decodeAddress decodes the passed address and ensures it's a pay-to-pubkey-hash
+// or pay-to-script-hash address for the active network.
+func decodeAddress(encodedAddr string) (hcutil.Address, error) {
+	addr, err := hcutil.DecodeAddress(encodedAddr)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode address '%s': %v",
+			encodedAddr, err)
+	}
+	switch addr.(type) {
+	case *hcutil.AddressPubKeyHash:
+	case *hcutil.AddressScriptHash:
+	default:
+		return nil, fmt.Errorf("invalid address type %T for address "+
+			"'%s'", addr, encodedAddr)
+	}
+	if !addr.IsForNet(activeNetParams) {
+		return nil, fmt.Errorf("address '%s' is not for the %s network",
+			encodedAddr, activeNetParams.Name)
+	}
+	return addr, nil
+}
+
+// txInput describes an input given in the form txid:vout[:tree[:amount]].
+type txInput struct {
+	prevOut wire.OutPoint
+	amount  hcutil.Amount
+}
+
+// parseTxInput parses an input in the form txid:vout[:tree[:amount]].  The
+// tree defaults to the regular transaction tree and the amount, which is only
+// needed to compute fees, to zero.
+func parseTxInput(s string) (*txInput, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 4 {
+		return nil, fmt.Errorf("input '%s' is not in the form "+
+			"txid:vout[:tree[:amount]]", s)
+	}
+	txHash, err := chainhash.NewHashFromStr(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("input '%s' has an invalid txid: %v",
+			s, err)
+	}
+	vout, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("input '%s' has an invalid vout: %v",
+			s, err)
+	}
+	in := &txInput{
+		prevOut: wire.OutPoint{
+			Hash:  *txHash,
+			Index: uint32(vout),
+			Tree:  wire.TxTreeRegular,
+		},
+	}
+	if len(parts) > 2 {
+		tree, err := strconv.ParseInt(parts[2], 10, 8)
+		if err != nil || (int8(tree) != wire.TxTreeRegular &&
+			int8(tree) != wire.TxTreeStake) {
+			return nil, fmt.Errorf("input '%s' has an invalid "+
+				"tree -- it must be %d (regular) or %d (stake)",
+				s, wire.TxTreeRegular, wire.TxTreeStake)
+		}
+		in.prevOut.Tree = int8(tree)
+	}
+	if len(parts) > 3 {
+		in.amount, err = parseAmount(parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("input '%s': %v", s, err)
+		}
+	}
+	return in, nil
+}
+
+// newTxIn returns a transaction input spending the passed input.
+func (in *txInput) newTxIn() *wire.TxIn {
+	txIn := wire.NewTxIn(&in.prevOut, nil)
+	txIn.ValueIn = int64(in.amount)
+	return txIn
+}
+
+// parseTxOutput parses an output in the form address:amount and returns the
+// address and amount.
+func parseTxOutput(s string) (hcutil.Address, hcutil.Amount, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return nil, 0, fmt.Errorf("output '%s' is not in the form "+
+			"address:amount", s)
+	}
+	addr, err := decodeAddress(s[:i])
+	if err != nil {
+		return nil, 0, err
+	}
+	amount, err := parseAmount(s[i+1:])
+	if err != nil {
+		return nil, 0, fmt.Errorf("output '%s': %v", s, err)
+	}
+	if amount == 0 {
+		return nil, 0, fmt.Errorf("output '%s' has a zero amount", s)
+	}
+	return addr, amount, nil
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/james-ray/hcd/txscript"
+	"github.com/james-ray/hcd/wire"
+)
+
+// createCmd defines the configuration options for the create command.
+type createCmd struct {
+	Inputs   []string `long:"in" description:"Input to spend in the form txid:vout[:tree[:amount]] -- the amount in coins is only needed to compute the fee"`
+	Outputs  []string `long:"out" description:"Output in the form address:amount with the amount in coins"`
+	PayLoad  string   `long:"payload" description:"Hex-encoded data to include in a null data output"`
+	LockTime uint32   `long:"locktime" description:"Lock time of the transaction"`
+	Expiry   uint32   `long:"expiry" description:"Height after which the transaction can't be mined"`
+}
+
+// editCmd defines the configuration options for the edit command.
+type editCmd struct {
+	AddInputs     []string `long:"addin" description:"Input to add in the form txid:vout[:tree[:amount]]"`
+	AddOutputs    []string `long:"addout" description:"Output to add in the form address:amount"`
+	RemoveInputs  []int    `long:"removein" description:"Index of an input to remove"`
+	RemoveOutputs []int    `long:"removeout" description:"Index of an output to remove"`
+	ValueIns      []string `long:"valuein" description:"Amount of an input in the form index:amount -- only needed to compute the fee"`
+	LockTime      *uint32  `long:"locktime" description:"New lock time of the transaction"`
+	Expiry        *uint32  `long:"expiry" description:"New expiry height of the transaction"`
+}
+
+var (
+	// createCfg defines the configuration options for the command.
+	createCfg = createCmd{}
+
+	// editCfg defines the configuration options for the command.
+	editCfg = editCmd{}
+)
+
+// addTxOutputs adds outputs paying to the passed outputs in the form
+// address:amount to the transaction.
+func addTxOutputs(mtx *wire.MsgTx, outputs []string) error {
+	for _, output := range outputs {
+		addr, amount, err := parseTxOutput(output)
+		if err != nil {
+			return err
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return err
+		}
+		mtx.AddTxOut(wire.NewTxOut(int64(amount), pkScript))
+	}
+	return nil
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *createCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if len(cmd.Inputs) == 0 {
+		return errors.New("no inputs specified")
+	}
+	if len(cmd.Outputs) == 0 && cmd.PayLoad == "" {
+		return errors.New("no outputs specified")
+	}
+
+	mtx := wire.NewMsgTx()
+	for _, input := range cmd.Inputs {
+		in, err := parseTxInput(input)
+		if err != nil {
+			return err
+		}
+		txIn := in.newTxIn()
+		if cmd.LockTime != 0 {
+			txIn.Sequence = wire.MaxTxInSequenceNum - 1
+		}
+		mtx.AddTxIn(txIn)
+	}
+	if err := addTxOutputs(mtx, cmd.Outputs); err != nil {
+		return err
+	}
+	if cmd.PayLoad != "" {
+		payLoad, err := hex.DecodeString(cmd.PayLoad)
+		if err != nil {
+			return fmt.Errorf("payload is not valid hex: %v", err)
+		}
+		payLoadScript, err := txscript.GenerateProvablyPruneableOut(payLoad)
+		if err != nil {
+			return err
+		}
+		mtx.AddTxOut(wire.NewTxOut(0, payLoadScript))
+	}
+	mtx.LockTime = cmd.LockTime
+	mtx.Expiry = cmd.Expiry
+
+	return writeTx(mtx)
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *createCmd) Usage() string {
+	return "--in=<input> [--in=<input>...] --out=<output> [--out=<output>...]"
+}
+
+// removeIndexes returns the passed indexes sorted in descending order after
+// ensuring they are less than n and unique, so the corresponding entries can be
+// removed one after another.
+func removeIndexes(indexes []int, n int, what string) ([]int, error) {
+	sorted := make([]int, len(indexes))
+	copy(sorted, indexes)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	for i, idx := range sorted {
+		if idx < 0 || idx >= n {
+			return nil, fmt.Errorf("%s index %d is out of range", what,
+				idx)
+		}
+		if i > 0 && sorted[i-1] == idx {
+			return nil, fmt.Errorf("%s index %d is given more than "+
+				"once", what, idx)
+		}
+	}
+	return sorted, nil
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *editCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	mtx, err := readTx(args)
+	if err != nil {
+		return err
+	}
+
+	// Remove inputs and outputs before adding any so the indexes refer to
+	// the passed transaction.
+	removeIns, err := removeIndexes(cmd.RemoveInputs, len(mtx.TxIn), "input")
+	if err != nil {
+		return err
+	}
+	for _, idx := range removeIns {
+		mtx.TxIn = append(mtx.TxIn[:idx], mtx.TxIn[idx+1:]...)
+	}
+	removeOuts, err := removeIndexes(cmd.RemoveOutputs, len(mtx.TxOut),
+		"output")
+	if err != nil {
+		return err
+	}
+	for _, idx := range removeOuts {
+		mtx.TxOut = append(mtx.TxOut[:idx], mtx.TxOut[idx+1:]...)
+	}
+
+	for _, input := range cmd.AddInputs {
+		in, err := parseTxInput(input)
+		if err != nil {
+			return err
+		}
+		mtx.AddTxIn(in.newTxIn())
+	}
+	if err := addTxOutputs(mtx, cmd.AddOutputs); err != nil {
+		return err
+	}
+
+	for _, valueIn := range cmd.ValueIns {
+		parts := strings.Split(valueIn, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("input amount '%s' is not in the form "+
+				"index:amount", valueIn)
+		}
+		idx, err := strconv.Atoi(parts[0])
+		if err != nil || idx < 0 || idx >= len(mtx.TxIn) {
+			return fmt.Errorf("input index '%s' is out of range",
+				parts[0])
+		}
+		amount, err := parseAmount(parts[1])
+		if err != nil {
+			return err
+		}
+		mtx.TxIn[idx].ValueIn = int64(amount)
+	}
+
+	if cmd.LockTime != nil {
+		mtx.LockTime = *cmd.LockTime
+	}
+	if cmd.Expiry != nil {
+		mtx.Expiry = *cmd.Expiry
+	}
+
+	return writeTx(mtx)
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *editCmd) Usage() string {
+	return "<hex-tx|->"
+}
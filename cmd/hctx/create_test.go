@@ -0,0 +1,250 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainec"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/txscript"
+	"github.com/james-ray/hcd/wire"
+)
+
+// commander is implemented by the commands of the utility.
+type commander interface {
+	Execute(args []string) error
+}
+
+// runCmd executes the passed command with the passed arguments on mainnet and
+// returns what it wrote.
+func runCmd(cmd commander, args ...string) (string, error) {
+	*cfg = config{}
+	activeNetParams = &chaincfg.MainNetParams
+	var buf bytes.Buffer
+	stdout = &buf
+	err := cmd.Execute(args)
+	return strings.TrimSpace(buf.String()), err
+}
+
+// testKey returns a mainnet private key along with its WIF encoding and its
+// pay-to-pubkey-hash address.
+func testKey(t *testing.T, seed byte) (string, hcutil.Address) {
+	t.Helper()
+	privKey, pubKey := chainec.Secp256k1.PrivKeyFromBytes(
+		bytes.Repeat([]byte{seed}, 32))
+	wif, err := hcutil.NewWIF(privKey, &chaincfg.MainNetParams,
+		chainec.ECTypeSecp256k1)
+	if err != nil {
+		t.Fatalf("NewWIF: unexpected error: %v", err)
+	}
+	addr, err := hcutil.NewAddressPubKeyHash(
+		hcutil.Hash160(pubKey.SerializeCompressed()),
+		&chaincfg.MainNetParams, chainec.ECTypeSecp256k1)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+	return wif.String(), addr
+}
+
+// testPrevTx returns the hex encoding of a transaction paying the passed
+// amount to the passed address in its second output.
+func testPrevTx(t *testing.T, addr hcutil.Address, amount int64) (*wire.MsgTx, string) {
+	t.Helper()
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	prevTx := wire.NewMsgTx()
+	prevTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 7}, nil))
+	prevTx.AddTxOut(wire.NewTxOut(1, []byte{txscript.OP_TRUE}))
+	prevTx.AddTxOut(wire.NewTxOut(amount, pkScript))
+	var buf bytes.Buffer
+	if err := prevTx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	return prevTx, hex.EncodeToString(buf.Bytes())
+}
+
+// TestCreateEdit ensures transactions are created and edited from the command
+// options as expected.
+func TestCreateEdit(t *testing.T) {
+	_, addr := testKey(t, 1)
+	_, addr2 := testKey(t, 2)
+	prevTx, _ := testPrevTx(t, addr, 10e8)
+	prevHash := prevTx.TxHash().String()
+
+	create := &createCmd{
+		Inputs:   []string{prevHash + ":1:0:10", prevHash + ":0"},
+		Outputs:  []string{addr2.EncodeAddress() + ":9.99"},
+		PayLoad:  "0102",
+		LockTime: 100,
+		Expiry:   200,
+	}
+	txHex, err := runCmd(create)
+	if err != nil {
+		t.Fatalf("create: unexpected error: %v", err)
+	}
+	mtx, err := decodeTx(txHex)
+	if err != nil {
+		t.Fatalf("decodeTx: unexpected error: %v", err)
+	}
+	if len(mtx.TxIn) != 2 || len(mtx.TxOut) != 2 {
+		t.Fatalf("create: unexpected number of inputs and outputs - "+
+			"got %d and %d, want 2 and 2", len(mtx.TxIn),
+			len(mtx.TxOut))
+	}
+	wantOutPoint := wire.OutPoint{Hash: prevTx.TxHash(), Index: 1}
+	if mtx.TxIn[0].PreviousOutPoint != wantOutPoint ||
+		mtx.TxIn[0].ValueIn != 10e8 || mtx.TxIn[1].ValueIn != 0 ||
+		mtx.TxIn[0].Sequence != wire.MaxTxInSequenceNum-1 {
+
+		t.Errorf("create: unexpected input %+v", mtx.TxIn[0])
+	}
+	wantPkScript, _ := txscript.PayToAddrScript(addr2)
+	if mtx.TxOut[0].Value != 999e6 ||
+		!bytes.Equal(mtx.TxOut[0].PkScript, wantPkScript) {
+
+		t.Errorf("create: unexpected output %+v", mtx.TxOut[0])
+	}
+	if mtx.TxOut[1].Value != 0 ||
+		txscript.GetScriptClass(txscript.DefaultScriptVersion,
+			mtx.TxOut[1].PkScript) != txscript.NullDataTy {
+
+		t.Errorf("create: unexpected payload output %+v", mtx.TxOut[1])
+	}
+	if mtx.LockTime != 100 || mtx.Expiry != 200 {
+		t.Errorf("create: unexpected lock time %d and expiry %d",
+			mtx.LockTime, mtx.Expiry)
+	}
+
+	// Remove the second input and the payload, add an output and set the
+	// amount of the first input and the expiry.
+	expiry := uint32(300)
+	edit := &editCmd{
+		RemoveInputs:  []int{1},
+		RemoveOutputs: []int{1},
+		AddOutputs:    []string{addr.EncodeAddress() + ":0.005"},
+		ValueIns:      []string{"0:10.5"},
+		Expiry:        &expiry,
+	}
+	txHex, err = runCmd(edit, txHex)
+	if err != nil {
+		t.Fatalf("edit: unexpected error: %v", err)
+	}
+	mtx, err = decodeTx(txHex)
+	if err != nil {
+		t.Fatalf("decodeTx: unexpected error: %v", err)
+	}
+	if len(mtx.TxIn) != 1 || len(mtx.TxOut) != 2 ||
+		mtx.TxIn[0].PreviousOutPoint != wantOutPoint ||
+		mtx.TxIn[0].ValueIn != 1050e6 || mtx.TxOut[0].Value != 999e6 ||
+		mtx.TxOut[1].Value != 5e5 || mtx.LockTime != 100 ||
+		mtx.Expiry != 300 {
+
+		t.Errorf("edit: unexpected transaction %+v", mtx)
+	}
+}
+
+// TestCreateEditErrors ensures invalid command options are rejected.
+func TestCreateEditErrors(t *testing.T) {
+	_, addr := testKey(t, 1)
+	_, txHex := testPrevTx(t, addr, 10e8)
+	hash := strings.Repeat("ab", 32)
+	out := addr.EncodeAddress() + ":1"
+	testNetAddr, err := hcutil.NewAddressPubKeyHash(make([]byte, 20),
+		&chaincfg.TestNet2Params, chainec.ECTypeSecp256k1)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cmd     commander
+		args    []string
+		wantErr string
+	}{{
+		name:    "create without inputs",
+		cmd:     &createCmd{Outputs: []string{out}},
+		wantErr: "no inputs specified",
+	}, {
+		name:    "create without outputs",
+		cmd:     &createCmd{Inputs: []string{hash + ":0"}},
+		wantErr: "no outputs specified",
+	}, {
+		name:    "input without vout",
+		cmd:     &createCmd{Inputs: []string{hash}, Outputs: []string{out}},
+		wantErr: "is not in the form txid:vout[:tree[:amount]]",
+	}, {
+		name:    "input with invalid txid",
+		cmd:     &createCmd{Inputs: []string{"xyz:0"}, Outputs: []string{out}},
+		wantErr: "has an invalid txid",
+	}, {
+		name:    "input with invalid tree",
+		cmd:     &createCmd{Inputs: []string{hash + ":0:2"}, Outputs: []string{out}},
+		wantErr: "has an invalid tree",
+	}, {
+		name:    "input with negative amount",
+		cmd:     &createCmd{Inputs: []string{hash + ":0:0:-1"}, Outputs: []string{out}},
+		wantErr: "is out of range",
+	}, {
+		name:    "output without amount",
+		cmd:     &createCmd{Inputs: []string{hash + ":0"}, Outputs: []string{addr.EncodeAddress()}},
+		wantErr: "is not in the form address:amount",
+	}, {
+		name:    "output with zero amount",
+		cmd:     &createCmd{Inputs: []string{hash + ":0"}, Outputs: []string{addr.EncodeAddress() + ":0"}},
+		wantErr: "has a zero amount",
+	}, {
+		name:    "output for another network",
+		cmd:     &createCmd{Inputs: []string{hash + ":0"}, Outputs: []string{testNetAddr.EncodeAddress() + ":1"}},
+		wantErr: "is not for the mainnet network",
+	}, {
+		name:    "invalid payload",
+		cmd:     &createCmd{Inputs: []string{hash + ":0"}, PayLoad: "xyz"},
+		wantErr: "payload is not valid hex",
+	}, {
+		name:    "edit without transaction",
+		cmd:     &editCmd{},
+		wantErr: "required transaction parameter not specified",
+	}, {
+		name:    "edit invalid hex",
+		cmd:     &editCmd{},
+		args:    []string{"xyz"},
+		wantErr: "transaction is not valid hex",
+	}, {
+		name:    "edit truncated transaction",
+		cmd:     &editCmd{},
+		args:    []string{txHex[:len(txHex)-8]},
+		wantErr: "could not decode transaction",
+	}, {
+		name:    "edit removing a missing input",
+		cmd:     &editCmd{RemoveInputs: []int{1}},
+		args:    []string{txHex},
+		wantErr: "input index 1 is out of range",
+	}, {
+		name:    "edit removing an output twice",
+		cmd:     &editCmd{RemoveOutputs: []int{0, 0}},
+		args:    []string{txHex},
+		wantErr: "output index 0 is given more than once",
+	}, {
+		name:    "edit amount of a missing input",
+		cmd:     &editCmd{ValueIns: []string{"1:1"}},
+		args:    []string{txHex},
+		wantErr: "input index '1' is out of range",
+	}}
+
+	for _, test := range tests {
+		_, err := runCmd(test.cmd, test.args...)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: unexpected error - got %v, want %s",
+				test.name, err, test.wantErr)
+		}
+	}
+}
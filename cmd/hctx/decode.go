@@ -0,0 +1,242 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/stake"
+	"github.com/james-ray/hcd/hcjson"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/mempool"
+	"github.com/james-ray/hcd/txscript"
+	"github.com/james-ray/hcd/wire"
+)
+
+// sstxCommitmentString is the script class shown for the commitment outputs of
+// tickets.  It matches the one of the decoderawtransaction RPC.
+const sstxCommitmentString = "sstxcommitment"
+
+// decodeCmd defines the configuration options for the decode command.
+type decodeCmd struct{}
+
+// feeCmd defines the configuration options for the fee command.
+type feeCmd struct {
+	FeeRate float64 `long:"feerate" description:"Fee rate in coins/kB to compute the required fee for"`
+}
+
+var (
+	// decodeCfg defines the configuration options for the command.
+	decodeCfg = decodeCmd{}
+
+	// feeCfg defines the configuration options for the command.
+	feeCfg = feeCmd{FeeRate: mempool.DefaultMinRelayTxFee.ToCoin()}
+)
+
+// createVinList returns a slice of JSON objects for the inputs of the passed
+// transaction.
+func createVinList(mtx *wire.MsgTx) []hcjson.Vin {
+	// Coinbase transactions only have a single txin by definition.
+	vinList := make([]hcjson.Vin, len(mtx.TxIn))
+	if blockchain.IsCoinBaseTx(mtx) {
+		txIn := mtx.TxIn[0]
+		vinEntry := &vinList[0]
+		vinEntry.Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		vinEntry.Sequence = txIn.Sequence
+		vinEntry.AmountIn = hcutil.Amount(txIn.ValueIn).ToCoin()
+		vinEntry.BlockHeight = txIn.BlockHeight
+		vinEntry.BlockIndex = txIn.BlockIndex
+		return vinList
+	}
+
+	for i, txIn := range mtx.TxIn {
+		// The disassembled string will contain [error] inline
+		// if the script doesn't fully parse, so ignore the
+		// error here.
+		disbuf, _ := txscript.DisasmString(txIn.SignatureScript)
+
+		vinEntry := &vinList[i]
+		vinEntry.Txid = txIn.PreviousOutPoint.Hash.String()
+		vinEntry.Vout = txIn.PreviousOutPoint.Index
+		vinEntry.Tree = txIn.PreviousOutPoint.Tree
+		vinEntry.Sequence = txIn.Sequence
+		vinEntry.Stakebase = hex.EncodeToString(txIn.SignatureScript)
+		vinEntry.AmountIn = hcutil.Amount(txIn.ValueIn).ToCoin()
+		vinEntry.BlockHeight = txIn.BlockHeight
+		vinEntry.BlockIndex = txIn.BlockIndex
+		vinEntry.ScriptSig = &hcjson.ScriptSig{
+			Asm: disbuf,
+			Hex: hex.EncodeToString(txIn.SignatureScript),
+		}
+	}
+
+	return vinList
+}
+
+// createVoutList returns a slice of JSON objects for the outputs of the passed
+// transaction.
+func createVoutList(mtx *wire.MsgTx) []hcjson.Vout {
+	txType := stake.DetermineTxType(mtx)
+	voutList := make([]hcjson.Vout, 0, len(mtx.TxOut))
+	for i, v := range mtx.TxOut {
+		// The disassembled string will contain [error] inline if the
+		// script doesn't fully parse, so ignore the error here.
+		disbuf, _ := txscript.DisasmString(v.PkScript)
+
+		// Attempt to extract addresses from the public key script.  In
+		// the case of stake submission transactions, the odd outputs
+		// contain a commitment address, so detect that case
+		// accordingly.
+		var addrs []hcutil.Address
+		var scriptClass string
+		var reqSigs int
+		var commitAmt *hcutil.Amount
+		if txType == stake.TxTypeSStx && (i%2 != 0) {
+			scriptClass = sstxCommitmentString
+			addr, err := stake.AddrFromSStxPkScrCommitment(v.PkScript,
+				activeNetParams)
+			if err == nil {
+				addrs = []hcutil.Address{addr}
+			}
+			amt, err := stake.AmountFromSStxPkScrCommitment(v.PkScript)
+			if err == nil {
+				commitAmt = &amt
+			}
+		} else {
+			// Ignore the error here since an error means the script
+			// couldn't parse and there is no additional information
+			// about it anyways.
+			var sc txscript.ScriptClass
+			sc, addrs, reqSigs, _ = txscript.ExtractPkScriptAddrs(
+				v.Version, v.PkScript, activeNetParams)
+			scriptClass = sc.String()
+		}
+
+		encodedAddrs := make([]string, len(addrs))
+		for j, addr := range addrs {
+			encodedAddrs[j] = addr.EncodeAddress()
+		}
+
+		var vout hcjson.Vout
+		voutSPK := &vout.ScriptPubKey
+		vout.N = uint32(i)
+		vout.Value = hcutil.Amount(v.Value).ToCoin()
+		vout.Version = v.Version
+		voutSPK.Addresses = encodedAddrs
+		voutSPK.Asm = disbuf
+		voutSPK.Hex = hex.EncodeToString(v.PkScript)
+		voutSPK.Type = scriptClass
+		voutSPK.ReqSigs = int32(reqSigs)
+		if commitAmt != nil {
+			voutSPK.CommitAmt = hcjson.Float64(commitAmt.ToCoin())
+		}
+
+		voutList = append(voutList, vout)
+	}
+
+	return voutList
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *decodeCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	mtx, err := readTx(args)
+	if err != nil {
+		return err
+	}
+	return writeJSON(&hcjson.TxRawDecodeResult{
+		Txid:     mtx.TxHash().String(),
+		Version:  int32(mtx.Version),
+		Locktime: mtx.LockTime,
+		Expiry:   mtx.Expiry,
+		Vin:      createVinList(mtx),
+		Vout:     createVoutList(mtx),
+	})
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *decodeCmd) Usage() string {
+	return "<hex-tx|->"
+}
+
+// feeResult models the data written by the fee command.
+type feeResult struct {
+	Type        string  `json:"type"`
+	Size        int     `json:"size"`
+	TotalIn     float64 `json:"totalin"`
+	TotalOut    float64 `json:"totalout"`
+	Fee         float64 `json:"fee"`
+	FeeRate     float64 `json:"feerate"`
+	RequiredFee float64 `json:"requiredfee"`
+}
+
+// txTypeStrings maps the stake transaction types to the names shown by the fee
+// command.
+var txTypeStrings = map[stake.TxType]string{
+	stake.TxTypeRegular: "regular",
+	stake.TxTypeSStx:    "ticket",
+	stake.TxTypeSSGen:   "vote",
+	stake.TxTypeSSRtx:   "revocation",
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *feeCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	mtx, err := readTx(args)
+	if err != nil {
+		return err
+	}
+	feeRate, err := hcutil.NewAmount(cmd.FeeRate)
+	if err != nil || feeRate < 0 {
+		return fmt.Errorf("invalid fee rate %v", cmd.FeeRate)
+	}
+
+	// The fee is the difference between the input and output amounts, so
+	// the amount of every input must be known.
+	var totalIn, totalOut int64
+	for i, txIn := range mtx.TxIn {
+		if txIn.ValueIn <= 0 {
+			return fmt.Errorf("the amount of input %d is unknown -- "+
+				"set it with edit --valuein", i)
+		}
+		totalIn += txIn.ValueIn
+	}
+	for _, txOut := range mtx.TxOut {
+		totalOut += txOut.Value
+	}
+
+	// The size of an unsigned transaction grows by the signature scripts
+	// once it is signed, so the rates are only final for signed ones.
+	size := mtx.SerializeSize()
+	fee := totalIn - totalOut
+	requiredFee := int64(size) * int64(feeRate) / 1000
+	if requiredFee == 0 && feeRate > 0 {
+		requiredFee = int64(feeRate)
+	}
+	return writeJSON(&feeResult{
+		Type:        txTypeStrings[stake.DetermineTxType(mtx)],
+		Size:        size,
+		TotalIn:     hcutil.Amount(totalIn).ToCoin(),
+		TotalOut:    hcutil.Amount(totalOut).ToCoin(),
+		Fee:         hcutil.Amount(fee).ToCoin(),
+		FeeRate:     hcutil.Amount(fee * 1000 / int64(size)).ToCoin(),
+		RequiredFee: hcutil.Amount(requiredFee).ToCoin(),
+	})
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *feeCmd) Usage() string {
+	return "[--feerate=<coins/kB>] <hex-tx|->"
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+// realMain is the real main function for the utility.  It is necessary to work
+// around the fact that deferred functions do not run when os.Exit() is called.
+func realMain() error {
+	// Setup the parser options and commands.
+	appName := filepath.Base(os.Args[0])
+	appName = strings.TrimSuffix(appName, filepath.Ext(appName))
+	parserFlags := flags.Options(flags.HelpFlag | flags.PassDoubleDash)
+	parser := flags.NewNamedParser(appName, parserFlags)
+	parser.AddGroup("Global Options", "", cfg)
+	parser.AddCommand("create",
+		"Create a transaction spending the inputs to the outputs",
+		"Create an unsigned transaction spending the given inputs to "+
+			"the given outputs like the createrawtransaction RPC.  "+
+			"Transactions which are read from the arguments of the "+
+			"commands can be passed as - to read them from standard "+
+			"input.", &createCfg)
+	parser.AddCommand("edit",
+		"Add or remove inputs and outputs of a transaction",
+		"Add or remove inputs and outputs of a transaction and set its "+
+			"lock time, expiry and input amounts.  Inputs and "+
+			"outputs are removed before any are added.  Existing "+
+			"signatures become invalid unless their signature hash "+
+			"type allows the change.", &editCfg)
+	parser.AddCommand("createsstx",
+		"Create a ticket purchase (SStx)",
+		"Create an unsigned ticket purchase paying the ticket price to "+
+			"the voting address like the createrawsstx RPC.  Every "+
+			"input needs a commitment naming the address the "+
+			"rewards are paid to and the change returned for the "+
+			"input.", &createSStxCfg)
+	parser.AddCommand("createssgen",
+		"Create a vote (SSGen) spending a ticket",
+		"Create an unsigned vote on the given block spending the given "+
+			"ticket like the createrawssgentx RPC.  The reward is "+
+			"calculated for the height of the block voted on.",
+		&createSSGenCfg)
+	parser.AddCommand("createssrtx",
+		"Create a revocation (SSRtx) of a missed or expired ticket",
+		"Create an unsigned revocation of the given ticket like the "+
+			"createrawssrtx RPC.", &createSSRtxCfg)
+	parser.AddCommand("sign",
+		"Sign the inputs of a transaction with private keys",
+		"Sign the inputs of a transaction with the given ECDSA or "+
+			"BLISS private keys and verify the resulting signature "+
+			"scripts.  The scripts of the spent outputs are taken "+
+			"from the transactions given with --prevtx or directly "+
+			"from --prevscript.  The result is written like the "+
+			"result of the signrawtransaction RPC.", &signCfg)
+	parser.AddCommand("decode",
+		"Decode a transaction",
+		"Decode a transaction into JSON like the decoderawtransaction "+
+			"RPC.", &decodeCfg)
+	parser.AddCommand("fee",
+		"Compute the fee and fee rate of a transaction",
+		"Compute the fee of a transaction from its input and output "+
+			"amounts along with its fee rate and the fee required "+
+			"for the given fee rate.  The size of a transaction "+
+			"grows when it is signed, so run it on the signed "+
+			"transaction for final numbers.", &feeCfg)
+
+	// Parse command line and invoke the Execute function for the specified
+	// command.
+	if _, err := parser.Parse(); err != nil {
+		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func main() {
+	// Work around defer not working after os.Exit()
+	if err := realMain(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/james-ray/hcd/blockchain/stake"
+	"github.com/james-ray/hcd/chaincfg/chainec"
+	"github.com/james-ray/hcd/hcjson"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/mempool"
+	"github.com/james-ray/hcd/txscript"
+	"github.com/james-ray/hcd/wire"
+)
+
+// signCmd defines the configuration options for the sign command.
+type signCmd struct {
+	Keys          []string `long:"key" description:"WIF-encoded private key (ECDSA or BLISS) to sign with"`
+	KeyFile       string   `long:"keyfile" description:"File with a WIF-encoded private key per line to sign with"`
+	PrevTxs       []string `long:"prevtx" description:"Hex-encoded transaction whose outputs are spent by the transaction"`
+	PrevScripts   []string `long:"prevscript" description:"Public key script of the output spent by an input in the form index:script"`
+	RedeemScripts []string `long:"redeemscript" description:"Hex-encoded redeem script of a pay-to-script-hash output"`
+	SigHashType   string   `long:"sighash" description:"Signature hash type {ALL, NONE, SINGLE, ALL|ANYONECANPAY, NONE|ANYONECANPAY, SINGLE|ANYONECANPAY}"`
+}
+
+var (
+	// signCfg defines the configuration options for the command.
+	signCfg = signCmd{SigHashType: "ALL"}
+)
+
+// sigHashTypes maps the names accepted by --sighash to signature hash types.
+var sigHashTypes = map[string]txscript.SigHashType{
+	"ALL":                 txscript.SigHashAll,
+	"NONE":                txscript.SigHashNone,
+	"SINGLE":              txscript.SigHashSingle,
+	"ALL|ANYONECANPAY":    txscript.SigHashAll | txscript.SigHashAnyOneCanPay,
+	"NONE|ANYONECANPAY":   txscript.SigHashNone | txscript.SigHashAnyOneCanPay,
+	"SINGLE|ANYONECANPAY": txscript.SigHashSingle | txscript.SigHashAnyOneCanPay,
+}
+
+// keyStore holds the private keys and redeem scripts available for signing
+// keyed by the hash160 of the public key or script.
+type keyStore struct {
+	keys    map[string]*hcutil.WIF
+	scripts map[string][]byte
+}
+
+// addKey decodes and adds the passed WIF-encoded private key.
+func (ks *keyStore) addKey(encodedWIF string) error {
+	wif, err := hcutil.DecodeWIF(encodedWIF)
+	if err != nil {
+		return fmt.Errorf("invalid private key: %v", err)
+	}
+	if !wif.IsForNet(activeNetParams) {
+		return fmt.Errorf("private key is not for the %s network",
+			activeNetParams.Name)
+	}
+	ks.keys[string(hcutil.Hash160(wif.SerializePubKey()))] = wif
+	return nil
+}
+
+// lookupKey returns the private key for the passed address or nil when it is
+// not available.  Pay-to-pubkey-hash addresses commit to the hash160 of the
+// public key while pay-to-pubkey addresses commit to the public key itself.
+func (ks *keyStore) lookupKey(addr hcutil.Address) *hcutil.WIF {
+	scriptAddr := addr.ScriptAddress()
+	if _, ok := addr.(*hcutil.AddressPubKeyHash); !ok {
+		scriptAddr = hcutil.Hash160(scriptAddr)
+	}
+	return ks.keys[string(scriptAddr)]
+}
+
+// GetKey returns the private key for the passed address.  It implements the
+// txscript.KeyDB interface.
+func (ks *keyStore) GetKey(addr hcutil.Address) (chainec.PrivateKey, bool, error) {
+	wif := ks.lookupKey(addr)
+	if wif == nil {
+		return nil, false, fmt.Errorf("no private key for address %v",
+			addr)
+	}
+	return wif.PrivKey, true, nil
+}
+
+// GetScript returns the redeem script for the passed pay-to-script-hash
+// address.  It implements the txscript.ScriptDB interface.
+func (ks *keyStore) GetScript(addr hcutil.Address) ([]byte, error) {
+	script, ok := ks.scripts[string(addr.ScriptAddress())]
+	if !ok {
+		return nil, fmt.Errorf("no redeem script for address %v", addr)
+	}
+	return script, nil
+}
+
+// sigType returns the signature algorithm to sign the passed public key script
+// with, which is the one of the first key available for its addresses or
+// the addresses of its redeem script.
+func (ks *keyStore) sigType(pkScript []byte) int {
+	class, addrs, _, _ := txscript.ExtractPkScriptAddrs(
+		txscript.DefaultScriptVersion, pkScript, activeNetParams)
+	if class == txscript.ScriptHashTy && len(addrs) == 1 {
+		script, err := ks.GetScript(addrs[0])
+		if err == nil {
+			_, addrs, _, _ = txscript.ExtractPkScriptAddrs(
+				txscript.DefaultScriptVersion, script,
+				activeNetParams)
+		}
+	}
+	for _, addr := range addrs {
+		if wif := ks.lookupKey(addr); wif != nil {
+			return wif.DSA()
+		}
+	}
+	return chainec.ECTypeSecp256k1
+}
+
+// loadKeys returns a key store with the keys and redeem scripts given by the
+// command options.
+func (cmd *signCmd) loadKeys() (*keyStore, error) {
+	ks := &keyStore{
+		keys:    make(map[string]*hcutil.WIF),
+		scripts: make(map[string][]byte),
+	}
+	for _, key := range cmd.Keys {
+		if err := ks.addKey(key); err != nil {
+			return nil, err
+		}
+	}
+	if cmd.KeyFile != "" {
+		f, err := os.Open(cmd.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if err := ks.addKey(line); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(ks.keys) == 0 {
+		return nil, errors.New("no private keys specified")
+	}
+
+	for _, s := range cmd.RedeemScripts {
+		script, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("redeem script is not valid hex: "+
+				"%v", err)
+		}
+		ks.scripts[string(hcutil.Hash160(script))] = script
+	}
+	return ks, nil
+}
+
+// prevScripts returns the public key scripts of the outputs spent by the
+// inputs of the passed transaction given by the command options.
+func (cmd *signCmd) prevScripts(mtx *wire.MsgTx) (map[int][]byte, error) {
+	prevOuts := make(map[wire.OutPoint][]byte)
+	for _, prevTxHex := range cmd.PrevTxs {
+		prevTx, err := decodeTx(prevTxHex)
+		if err != nil {
+			return nil, err
+		}
+		prevOut := wire.OutPoint{Hash: prevTx.TxHash()}
+		prevOut.Tree = wire.TxTreeRegular
+		if stake.DetermineTxType(prevTx) != stake.TxTypeRegular {
+			prevOut.Tree = wire.TxTreeStake
+		}
+		for i, txOut := range prevTx.TxOut {
+			prevOut.Index = uint32(i)
+			prevOuts[prevOut] = txOut.PkScript
+		}
+	}
+
+	scripts := make(map[int][]byte)
+	for i, txIn := range mtx.TxIn {
+		if pkScript, ok := prevOuts[txIn.PreviousOutPoint]; ok {
+			scripts[i] = pkScript
+		}
+	}
+	for _, s := range cmd.PrevScripts {
+		parts := strings.Split(s, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("previous script '%s' is not in "+
+				"the form index:script", s)
+		}
+		idx, err := strconv.Atoi(parts[0])
+		if err != nil || idx < 0 || idx >= len(mtx.TxIn) {
+			return nil, fmt.Errorf("input index '%s' is out of range",
+				parts[0])
+		}
+		pkScript, err := hex.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("previous script of input %d is "+
+				"not valid hex: %v", idx, err)
+		}
+		scripts[idx] = pkScript
+	}
+	return scripts, nil
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *signCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	mtx, err := readTx(args)
+	if err != nil {
+		return err
+	}
+	hashType, ok := sigHashTypes[strings.ToUpper(cmd.SigHashType)]
+	if !ok {
+		return fmt.Errorf("invalid signature hash type '%s'",
+			cmd.SigHashType)
+	}
+	ks, err := cmd.loadKeys()
+	if err != nil {
+		return err
+	}
+	scripts, err := cmd.prevScripts(mtx)
+	if err != nil {
+		return err
+	}
+
+	// Sign every input for which the previous script is known and verify
+	// the resulting signature script.  The stake base input of a vote has
+	// nothing to sign.
+	isSSGen, _ := stake.IsSSGen(mtx)
+	var signErrors []hcjson.SignRawTransactionError
+	for i, txIn := range mtx.TxIn {
+		if isSSGen && i == 0 {
+			continue
+		}
+
+		signErr := func(err error) {
+			signErrors = append(signErrors, hcjson.SignRawTransactionError{
+				TxID:      txIn.PreviousOutPoint.Hash.String(),
+				Vout:      txIn.PreviousOutPoint.Index,
+				ScriptSig: hex.EncodeToString(txIn.SignatureScript),
+				Sequence:  txIn.Sequence,
+				Error:     err.Error(),
+			})
+		}
+
+		pkScript, ok := scripts[i]
+		if !ok {
+			signErr(errors.New("previous output script unknown -- " +
+				"specify it with --prevtx or --prevscript"))
+			continue
+		}
+		sigScript, err := txscript.SignTxOutput(activeNetParams, mtx, i,
+			pkScript, hashType, ks, ks, txIn.SignatureScript,
+			ks.sigType(pkScript))
+		if err != nil {
+			signErr(err)
+			continue
+		}
+		txIn.SignatureScript = sigScript
+
+		// Either it was already signed or we just signed it.  Find out
+		// if it is completely satisfied or still needs more.
+		vm, err := txscript.NewEngine(pkScript, mtx, i,
+			mempool.BaseStandardVerifyFlags,
+			txscript.DefaultScriptVersion, nil)
+		if err == nil {
+			err = vm.Execute()
+		}
+		if err != nil {
+			signErr(err)
+		}
+	}
+
+	var buf strings.Builder
+	buf.Grow(mtx.SerializeSize() * 2)
+	if err := mtx.Serialize(hex.NewEncoder(&buf)); err != nil {
+		return err
+	}
+	result := hcjson.SignRawTransactionResult{
+		Hex:      buf.String(),
+		Complete: len(signErrors) == 0,
+		Errors:   signErrors,
+	}
+	return writeJSON(&result)
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *signCmd) Usage() string {
+	return "--key=<wif> [--prevtx=<hex-tx>...] [--prevscript=<index:script>...] " +
+		"<hex-tx|->"
+}
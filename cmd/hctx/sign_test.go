@@ -0,0 +1,276 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainec"
+	"github.com/james-ray/hcd/hcjson"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/mempool"
+	"github.com/james-ray/hcd/txscript"
+)
+
+// runSign executes the sign command for the passed transaction and returns
+// its decoded result.
+func runSign(t *testing.T, cmd *signCmd, txHex string) *hcjson.SignRawTransactionResult {
+	t.Helper()
+	if cmd.SigHashType == "" {
+		cmd.SigHashType = "ALL"
+	}
+	out, err := runCmd(cmd, txHex)
+	if err != nil {
+		t.Fatalf("sign: unexpected error: %v", err)
+	}
+	var result hcjson.SignRawTransactionResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("unable to decode sign result %q: %v", out, err)
+	}
+	return &result
+}
+
+// TestSignRoundTrip ensures a transaction created, signed and decoded by the
+// utility spends its input with a valid signature and serializes back to the
+// same bytes.
+func TestSignRoundTrip(t *testing.T) {
+	wif, addr := testKey(t, 1)
+	_, addr2 := testKey(t, 2)
+	prevTx, prevTxHex := testPrevTx(t, addr, 10e8)
+	prevHash := prevTx.TxHash().String()
+
+	create := &createCmd{
+		Inputs:  []string{prevHash + ":1:0:10"},
+		Outputs: []string{addr2.EncodeAddress() + ":9.99"},
+	}
+	unsignedHex, err := runCmd(create)
+	if err != nil {
+		t.Fatalf("create: unexpected error: %v", err)
+	}
+
+	// Sign the input with the script taken from the previous transaction.
+	result := runSign(t, &signCmd{Keys: []string{wif},
+		PrevTxs: []string{prevTxHex}}, unsignedHex)
+	if !result.Complete || len(result.Errors) != 0 {
+		t.Fatalf("sign: unexpected incomplete result: %+v", result)
+	}
+
+	// Ensure the signed transaction spends the previous output.
+	mtx, err := decodeTx(result.Hex)
+	if err != nil {
+		t.Fatalf("decodeTx: unexpected error: %v", err)
+	}
+	vm, err := txscript.NewEngine(prevTx.TxOut[1].PkScript, mtx, 0,
+		mempool.BaseStandardVerifyFlags, txscript.DefaultScriptVersion,
+		nil)
+	if err == nil {
+		err = vm.Execute()
+	}
+	if err != nil {
+		t.Fatalf("signed input does not verify: %v", err)
+	}
+
+	// Ensure the signed transaction serializes to the same bytes and only
+	// differs from the unsigned one in its signature script.
+	var buf bytes.Buffer
+	if err := mtx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	if hex.EncodeToString(buf.Bytes()) != result.Hex {
+		t.Errorf("signed transaction does not serialize to the same " +
+			"bytes")
+	}
+	unsigned, err := decodeTx(unsignedHex)
+	if err != nil {
+		t.Fatalf("decodeTx: unexpected error: %v", err)
+	}
+	if mtx.TxHash() != unsigned.TxHash() {
+		t.Errorf("signing changed the transaction hash from %v to %v",
+			unsigned.TxHash(), mtx.TxHash())
+	}
+
+	// Signing again with the script given directly produces the same
+	// transaction since the signatures are deterministic.
+	pkScript := hex.EncodeToString(prevTx.TxOut[1].PkScript)
+	result2 := runSign(t, &signCmd{Keys: []string{wif},
+		PrevScripts: []string{"0:" + pkScript}}, unsignedHex)
+	if !result2.Complete || result2.Hex != result.Hex {
+		t.Errorf("sign with --prevscript: unexpected result %+v",
+			result2)
+	}
+
+	// Ensure the decoded transaction matches the signed one.
+	out, err := runCmd(&decodeCmd{}, result.Hex)
+	if err != nil {
+		t.Fatalf("decode: unexpected error: %v", err)
+	}
+	var decoded hcjson.TxRawDecodeResult
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("unable to decode decode result %q: %v", out, err)
+	}
+	sigScript := hex.EncodeToString(mtx.TxIn[0].SignatureScript)
+	if decoded.Txid != mtx.TxHash().String() || len(decoded.Vin) != 1 ||
+		decoded.Vin[0].Txid != prevHash || decoded.Vin[0].Vout != 1 ||
+		decoded.Vin[0].AmountIn != 10 ||
+		decoded.Vin[0].ScriptSig == nil ||
+		decoded.Vin[0].ScriptSig.Hex != sigScript ||
+		len(decoded.Vout) != 1 || decoded.Vout[0].Value != 9.99 ||
+		len(decoded.Vout[0].ScriptPubKey.Addresses) != 1 ||
+		decoded.Vout[0].ScriptPubKey.Addresses[0] != addr2.EncodeAddress() {
+
+		t.Errorf("decode: unexpected result %s", out)
+	}
+}
+
+// TestSignIncomplete ensures inputs which can't be signed are reported in the
+// result rather than failing the command.
+func TestSignIncomplete(t *testing.T) {
+	wif, addr := testKey(t, 1)
+	wif2, _ := testKey(t, 2)
+	prevTx, prevTxHex := testPrevTx(t, addr, 10e8)
+	prevHash := prevTx.TxHash().String()
+
+	create := &createCmd{
+		Inputs:  []string{prevHash + ":1", prevHash + ":5"},
+		Outputs: []string{addr.EncodeAddress() + ":9.99"},
+	}
+	unsignedHex, err := runCmd(create)
+	if err != nil {
+		t.Fatalf("create: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		cmd      *signCmd
+		wantErrs []string
+	}{{
+		name: "missing previous output",
+		cmd: &signCmd{Keys: []string{wif},
+			PrevTxs: []string{prevTxHex}},
+		wantErrs: []string{"previous output script unknown"},
+	}, {
+		name: "missing key",
+		cmd: &signCmd{Keys: []string{wif2},
+			PrevTxs: []string{prevTxHex}},
+		wantErrs: []string{"no private key for address",
+			"previous output script unknown"},
+	}, {
+		name: "missing previous transaction",
+		cmd:  &signCmd{Keys: []string{wif}},
+		wantErrs: []string{"previous output script unknown",
+			"previous output script unknown"},
+	}}
+
+	for _, test := range tests {
+		result := runSign(t, test.cmd, unsignedHex)
+		if result.Complete {
+			t.Errorf("%s: result is complete", test.name)
+		}
+		if len(result.Errors) != len(test.wantErrs) {
+			t.Errorf("%s: unexpected errors - got %+v, want %q",
+				test.name, result.Errors, test.wantErrs)
+			continue
+		}
+		for i, wantErr := range test.wantErrs {
+			if !strings.Contains(result.Errors[i].Error, wantErr) {
+				t.Errorf("%s: unexpected error %d - got %q, "+
+					"want %q", test.name, i,
+					result.Errors[i].Error, wantErr)
+			}
+		}
+
+		// Ensure the transaction is still returned.
+		if _, err := decodeTx(result.Hex); err != nil {
+			t.Errorf("%s: decodeTx: unexpected error: %v", test.name,
+				err)
+		}
+	}
+}
+
+// TestSignErrors ensures invalid sign command options are rejected.
+func TestSignErrors(t *testing.T) {
+	wif, addr := testKey(t, 1)
+	_, prevTxHex := testPrevTx(t, addr, 10e8)
+	privKey, _ := chainec.Secp256k1.PrivKeyFromBytes(
+		bytes.Repeat([]byte{1}, 32))
+	testNetWIF, err := hcutil.NewWIF(privKey, &chaincfg.TestNet2Params,
+		chainec.ECTypeSecp256k1)
+	if err != nil {
+		t.Fatalf("NewWIF: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cmd     *signCmd
+		args    []string
+		wantErr string
+	}{{
+		name:    "no transaction",
+		cmd:     &signCmd{Keys: []string{wif}, SigHashType: "ALL"},
+		wantErr: "required transaction parameter not specified",
+	}, {
+		name:    "no keys",
+		cmd:     &signCmd{SigHashType: "ALL"},
+		args:    []string{prevTxHex},
+		wantErr: "no private keys specified",
+	}, {
+		name:    "invalid key",
+		cmd:     &signCmd{Keys: []string{"xyz"}, SigHashType: "ALL"},
+		args:    []string{prevTxHex},
+		wantErr: "invalid private key",
+	}, {
+		name:    "key for another network",
+		cmd:     &signCmd{Keys: []string{testNetWIF.String()}, SigHashType: "ALL"},
+		args:    []string{prevTxHex},
+		wantErr: "private key is not for the mainnet network",
+	}, {
+		name:    "missing key file",
+		cmd:     &signCmd{KeyFile: "nonexistent-keys.txt", SigHashType: "ALL"},
+		args:    []string{prevTxHex},
+		wantErr: "nonexistent-keys.txt",
+	}, {
+		name:    "invalid signature hash type",
+		cmd:     &signCmd{Keys: []string{wif}, SigHashType: "SOME"},
+		args:    []string{prevTxHex},
+		wantErr: "invalid signature hash type 'SOME'",
+	}, {
+		name: "invalid previous transaction",
+		cmd: &signCmd{Keys: []string{wif}, SigHashType: "ALL",
+			PrevTxs: []string{"xyz"}},
+		args:    []string{prevTxHex},
+		wantErr: "transaction is not valid hex",
+	}, {
+		name: "previous script for a missing input",
+		cmd: &signCmd{Keys: []string{wif}, SigHashType: "ALL",
+			PrevScripts: []string{"1:51"}},
+		args:    []string{prevTxHex},
+		wantErr: "input index '1' is out of range",
+	}, {
+		name: "invalid previous script",
+		cmd: &signCmd{Keys: []string{wif}, SigHashType: "ALL",
+			PrevScripts: []string{"0:xyz"}},
+		args:    []string{prevTxHex},
+		wantErr: "previous script of input 0 is not valid hex",
+	}, {
+		name: "invalid redeem script",
+		cmd: &signCmd{Keys: []string{wif}, SigHashType: "ALL",
+			RedeemScripts: []string{"xyz"}},
+		args:    []string{prevTxHex},
+		wantErr: "redeem script is not valid hex",
+	}}
+
+	for _, test := range tests {
+		_, err := runCmd(test.cmd, test.args...)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: unexpected error - got %v, want %s",
+				test.name, err, test.wantErr)
+		}
+	}
+}
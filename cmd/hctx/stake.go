@@ -0,0 +1,334 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/stake"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/txscript"
+	"github.com/james-ray/hcd/wire"
+)
+
+// createSStxCmd defines the configuration options for the createsstx command.
+type createSStxCmd struct {
+	Inputs      []string `long:"in" description:"Input to spend in the form txid:vout:tree:amount with the amount in coins"`
+	Ticket      string   `long:"ticket" description:"Voting address and ticket price in the form address:amount"`
+	Commitments []string `long:"commit" description:"Reward address, change address and change amount of the input with the same position in the form address:changeaddress:changeamount"`
+}
+
+// createSSGenCmd defines the configuration options for the createssgen
+// command.
+type createSSGenCmd struct {
+	BlockHash   string `long:"blockhash" description:"Hash of the block voted on"`
+	BlockHeight uint32 `long:"blockheight" description:"Height of the block voted on"`
+	VoteBits    uint16 `long:"votebits" description:"Vote bits of the vote"`
+}
+
+// createSSRtxCmd defines the configuration options for the createssrtx
+// command.
+type createSSRtxCmd struct {
+	Fee float64 `long:"fee" description:"Fee in coins to pay from the first output large enough"`
+}
+
+var (
+	// createSStxCfg defines the configuration options for the command.
+	createSStxCfg = createSStxCmd{}
+
+	// createSSGenCfg defines the configuration options for the command.
+	createSSGenCfg = createSSGenCmd{VoteBits: 1}
+
+	// createSSRtxCfg defines the configuration options for the command.
+	createSSRtxCfg = createSSRtxCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *createSStxCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if len(cmd.Inputs) == 0 {
+		return errors.New("no inputs specified")
+	}
+	if len(cmd.Inputs) != len(cmd.Commitments) {
+		return fmt.Errorf("number of inputs should be equal to the "+
+			"number of commitments for any sstx; %v inputs given, "+
+			"but %v commitments", len(cmd.Inputs),
+			len(cmd.Commitments))
+	}
+	if cmd.Ticket == "" {
+		return errors.New("no voting address and ticket price specified")
+	}
+
+	mtx := wire.NewMsgTx()
+	inputAmts := make([]int64, len(cmd.Inputs))
+	for i, input := range cmd.Inputs {
+		in, err := parseTxInput(input)
+		if err != nil {
+			return err
+		}
+		if in.amount == 0 {
+			return fmt.Errorf("input '%s' has no amount", input)
+		}
+		mtx.AddTxIn(in.newTxIn())
+		inputAmts[i] = int64(in.amount)
+	}
+
+	// Add the SStx tagged output paying the ticket price to the voting
+	// address.
+	addr, amtTicket, err := parseTxOutput(cmd.Ticket)
+	if err != nil {
+		return err
+	}
+	pkScript, err := txscript.PayToSStx(addr)
+	if err != nil {
+		return err
+	}
+	mtx.AddTxOut(wire.NewTxOut(int64(amtTicket), pkScript))
+
+	// Parse the commitments and calculate the commitment amounts, which
+	// include the fee contributed by every input.
+	type commitment struct {
+		addr       hcutil.Address
+		changeAddr hcutil.Address
+		changeAmt  hcutil.Amount
+	}
+	commitments := make([]commitment, len(cmd.Commitments))
+	changeAmts := make([]int64, len(cmd.Commitments))
+	for i, c := range cmd.Commitments {
+		parts := strings.Split(c, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("commitment '%s' is not in the form "+
+				"address:changeaddress:changeamount", c)
+		}
+		addr, err := decodeAddress(parts[0])
+		if err != nil {
+			return err
+		}
+		changeAddr, err := decodeAddress(parts[1])
+		if err != nil {
+			return err
+		}
+		changeAmt, err := parseAmount(parts[2])
+		if err != nil {
+			return fmt.Errorf("commitment '%s': %v", c, err)
+		}
+		if int64(changeAmt) >= inputAmts[i] {
+			return fmt.Errorf("change amount %v of commitment %d is "+
+				"not less than the input amount %v", changeAmt, i,
+				hcutil.Amount(inputAmts[i]))
+		}
+		commitments[i] = commitment{addr, changeAddr, changeAmt}
+		changeAmts[i] = int64(changeAmt)
+	}
+	_, amountsCommitted, err := stake.SStxNullOutputAmounts(inputAmts,
+		changeAmts, int64(amtTicket))
+	if err != nil {
+		return err
+	}
+
+	// Add the commitment and change outputs for every input.
+	for i, c := range commitments {
+		pkScript, err := txscript.GenerateSStxAddrPush(c.addr,
+			hcutil.Amount(amountsCommitted[i]), 0x0000)
+		if err != nil {
+			return err
+		}
+		mtx.AddTxOut(wire.NewTxOut(0, pkScript))
+
+		pkScript, err = txscript.PayToSStxChange(c.changeAddr)
+		if err != nil {
+			return err
+		}
+		mtx.AddTxOut(wire.NewTxOut(int64(c.changeAmt), pkScript))
+	}
+
+	// Make sure we generated a valid SStx.
+	if _, err := stake.IsSStx(mtx); err != nil {
+		return fmt.Errorf("invalid sstx: %v", err)
+	}
+
+	return writeTx(mtx)
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *createSStxCmd) Usage() string {
+	return "--ticket=<address:amount> --in=<input> --commit=<commitment> " +
+		"[--in=<input> --commit=<commitment>...]"
+}
+
+// readTicket deserializes the ticket passed as the first of the command
+// arguments and ensures it is an SStx.
+func readTicket(args []string) (*wire.MsgTx, error) {
+	ticket, err := readTx(args)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stake.IsSStx(ticket); err != nil {
+		return nil, fmt.Errorf("transaction is not a ticket: %v", err)
+	}
+	return ticket, nil
+}
+
+// newTicketTxIn returns a transaction input spending the stake submission
+// output of the passed ticket.
+func newTicketTxIn(ticket *wire.MsgTx) *wire.TxIn {
+	ticketHash := ticket.TxHash()
+	prevOut := wire.NewOutPoint(&ticketHash, 0, wire.TxTreeStake)
+	txIn := wire.NewTxIn(prevOut, nil)
+	txIn.ValueIn = ticket.TxOut[0].Value
+	return txIn
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *createSSGenCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	ticket, err := readTicket(args)
+	if err != nil {
+		return err
+	}
+	if cmd.BlockHash == "" {
+		return errors.New("no block hash specified")
+	}
+	blockHash, err := chainhash.NewHashFromStr(cmd.BlockHash)
+	if err != nil {
+		return fmt.Errorf("invalid block hash: %v", err)
+	}
+
+	// Calculate the rewards paid to the commitments of the ticket.
+	ssgenPayTypes, ssgenPkhs, sstxAmts, _, _, _, sigTypes :=
+		stake.TxSStxStakeOutputInfo(ticket)
+	height := int64(cmd.BlockHeight)
+	stakeVoteSubsidy := blockchain.CalcStakeVoteSubsidy(
+		blockchain.NewSubsidyCache(height, activeNetParams), height,
+		activeNetParams)
+	ssgenCalcAmts := stake.CalculateRewards(sstxAmts, ticket.TxOut[0].Value,
+		stakeVoteSubsidy)
+
+	// Add the stake base and the ticket as inputs.
+	mtx := wire.NewMsgTx()
+	stakeBaseOutPoint := wire.NewOutPoint(&chainhash.Hash{},
+		uint32(0xFFFFFFFF), wire.TxTreeRegular)
+	txInStakeBase := wire.NewTxIn(stakeBaseOutPoint, nil)
+	txInStakeBase.ValueIn = stakeVoteSubsidy
+	mtx.AddTxIn(txInStakeBase)
+	mtx.AddTxIn(newTicketTxIn(ticket))
+
+	// Add the block reference and vote bits outputs followed by the reward
+	// outputs.
+	blockRefScript, err := txscript.GenerateSSGenBlockRef(*blockHash,
+		cmd.BlockHeight)
+	if err != nil {
+		return err
+	}
+	mtx.AddTxOut(wire.NewTxOut(0, blockRefScript))
+	voteBitsScript, err := txscript.GenerateSSGenVotes(cmd.VoteBits)
+	if err != nil {
+		return err
+	}
+	mtx.AddTxOut(wire.NewTxOut(0, voteBitsScript))
+	for i, ssgenPkh := range ssgenPkhs {
+		var pkScript []byte
+		if ssgenPayTypes[i] {
+			pkScript, err = txscript.PayToSSGenSHDirect(ssgenPkh,
+				int(sigTypes[i]))
+		} else {
+			pkScript, err = txscript.PayToSSGenPKHDirect(ssgenPkh,
+				int(sigTypes[i]))
+		}
+		if err != nil {
+			return err
+		}
+		mtx.AddTxOut(wire.NewTxOut(ssgenCalcAmts[i], pkScript))
+	}
+
+	// Make sure we generated a valid SSGen.
+	if _, err := stake.IsSSGen(mtx); err != nil {
+		return fmt.Errorf("invalid ssgen: %v", err)
+	}
+
+	return writeTx(mtx)
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *createSSGenCmd) Usage() string {
+	return "--blockhash=<hash> --blockheight=<height> <hex-ticket|->"
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *createSSRtxCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	ticket, err := readTicket(args)
+	if err != nil {
+		return err
+	}
+	feeAmt, err := hcutil.NewAmount(cmd.Fee)
+	if err != nil || feeAmt < 0 {
+		return fmt.Errorf("invalid fee amount %v", cmd.Fee)
+	}
+
+	// Calculate the amounts returned to the commitments of the ticket.
+	ssrtxPayTypes, ssrtxPkhs, sstxAmts, _, _, _, sigTypes :=
+		stake.TxSStxStakeOutputInfo(ticket)
+	ssrtxCalcAmts := stake.CalculateRewards(sstxAmts, ticket.TxOut[0].Value,
+		0) // No subsidy for a revocation
+
+	mtx := wire.NewMsgTx()
+	mtx.AddTxIn(newTicketTxIn(ticket))
+
+	// Add the revocation outputs and pay the fee from the first one large
+	// enough.
+	feeApplied := false
+	for i, ssrtxPkh := range ssrtxPkhs {
+		var pkScript []byte
+		if ssrtxPayTypes[i] {
+			pkScript, err = txscript.PayToSSRtxSHDirect(ssrtxPkh,
+				int(sigTypes[i]))
+		} else {
+			pkScript, err = txscript.PayToSSRtxPKHDirect(ssrtxPkh,
+				int(sigTypes[i]))
+		}
+		if err != nil {
+			return err
+		}
+		amt := ssrtxCalcAmts[i]
+		if !feeApplied && int64(feeAmt) < amt {
+			amt -= int64(feeAmt)
+			feeApplied = true
+		}
+		mtx.AddTxOut(wire.NewTxOut(amt, pkScript))
+	}
+	if !feeApplied && feeAmt != 0 {
+		return fmt.Errorf("no output is large enough to pay the fee %v",
+			feeAmt)
+	}
+
+	// Make sure we generated a valid SSRtx.
+	if _, err := stake.IsSSRtx(mtx); err != nil {
+		return fmt.Errorf("invalid ssrtx: %v", err)
+	}
+
+	return writeTx(mtx)
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *createSSRtxCmd) Usage() string {
+	return "[--fee=<amount>] <hex-ticket|->"
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/james-ray/hcd/chaincfg"
+	flags "github.com/jessevdk/go-flags"
+)
+
+const (
+	defaultNumBlocks      = 20000
+	defaultSeed           = 1
+	defaultStakedFraction = 0.5
+	defaultParticipation  = 1.0
+)
+
+// config defines the configuration options for stakesim.
+//
+// See loadConfig for details on the configuration load process.
+type config struct {
+	TestNet             bool    `long:"testnet" description:"Use the test network parameters"`
+	SimNet              bool    `long:"simnet" description:"Use the simulation test network parameters"`
+	RegNet              bool    `long:"regnet" description:"Use the regression test network parameters"`
+	ChainParams         string  `long:"chainparams" description:"Use the network parameters described by the specified JSON file"`
+	NumBlocks           int64   `short:"n" long:"blocks" description:"Number of blocks to simulate"`
+	Seed                int64   `long:"seed" description:"Seed for the pseudo random number generator used by the purchase and voting models"`
+	TicketPoolSize      uint16  `long:"ticketpoolsize" description:"Override the target ticket pool size in blocks"`
+	StakeDiffAlpha      int64   `long:"stakediffalpha" description:"Override the stake difficulty EMA calculation alpha"`
+	StakeDiffWindowSize int64   `long:"stakediffwindowsize" description:"Override the number of blocks in each stake difficulty interval"`
+	StakeDiffWindows    int64   `long:"stakediffwindows" description:"Override the number of intervals used for the stake difficulty calculation"`
+	StakedFraction      float64 `long:"stakedfraction" description:"Fraction of the coin supply stakeholders aim to lock in tickets {0.0-1.0}"`
+	BuyPeriod           int64   `long:"buyperiod" description:"Number of blocks stakeholders spread their purchases over to reach the staked fraction (default: the stake difficulty interval)"`
+	Participation       float64 `long:"participation" description:"Probability that a ticket selected by the lottery votes {0.0-1.0}"`
+	Replay              string  `long:"replay" description:"Replay the number of tickets purchased in each block from the specified CSV file instead of modelling purchases"`
+	OutFile             string  `short:"o" long:"output" description:"Write the CSV time series to the specified file instead of stdout"`
+}
+
+// loadConfig initializes and parses the config using command line options and
+// returns it along with the chain parameters to simulate.
+func loadConfig() (*config, *chaincfg.Params, error) {
+	// Default config.
+	cfg := config{
+		NumBlocks:      defaultNumBlocks,
+		Seed:           defaultSeed,
+		StakedFraction: defaultStakedFraction,
+		Participation:  defaultParticipation,
+	}
+
+	// Parse command line options.
+	parser := flags.NewParser(&cfg, flags.Default)
+	_, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return nil, nil, err
+	}
+
+	// Multiple networks can't be selected simultaneously.
+	funcName := "loadConfig"
+	numNets := 0
+	params := &chaincfg.MainNetParams
+	if cfg.TestNet {
+		numNets++
+		params = &chaincfg.TestNet2Params
+	}
+	if cfg.SimNet {
+		numNets++
+		params = &chaincfg.SimNetParams
+	}
+	if cfg.RegNet {
+		numNets++
+		params = &chaincfg.RegNetParams
+	}
+	if cfg.ChainParams != "" {
+		numNets++
+	}
+	if numNets > 1 {
+		str := "%s: the testnet, simnet, regnet and chainparams params " +
+			"can't be used together -- choose one of the four"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+	if cfg.ChainParams != "" {
+		params, err = chaincfg.ParamsFromFile(cfg.ChainParams)
+		if err != nil {
+			err = fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+	}
+
+	// Apply the parameter overrides to a copy of the parameters so the
+	// registered networks are left untouched.
+	simParams := *params
+	if cfg.TicketPoolSize != 0 {
+		simParams.TicketPoolSize = cfg.TicketPoolSize
+	}
+	if cfg.StakeDiffAlpha != 0 {
+		simParams.StakeDiffAlpha = cfg.StakeDiffAlpha
+	}
+	if cfg.StakeDiffWindowSize != 0 {
+		simParams.StakeDiffWindowSize = cfg.StakeDiffWindowSize
+	}
+	if cfg.StakeDiffWindows != 0 {
+		simParams.StakeDiffWindows = cfg.StakeDiffWindows
+	}
+	if cfg.BuyPeriod == 0 {
+		cfg.BuyPeriod = simParams.StakeDiffWindowSize
+	}
+
+	// Validate the simulation options.
+	switch {
+	case cfg.NumBlocks <= 0:
+		err = fmt.Errorf("%s: the number of blocks must be positive",
+			funcName)
+	case simParams.TicketPoolSize == 0:
+		err = fmt.Errorf("%s: the ticket pool size must be positive",
+			funcName)
+	case simParams.StakeDiffWindowSize <= 0 || simParams.StakeDiffAlpha < 0 ||
+		simParams.StakeDiffWindows < 0:
+		err = fmt.Errorf("%s: the stake difficulty window size must be "+
+			"positive and the alpha and number of windows must not be "+
+			"negative", funcName)
+	case cfg.StakedFraction < 0 || cfg.StakedFraction > 1:
+		err = fmt.Errorf("%s: the staked fraction must be between 0 and 1",
+			funcName)
+	case cfg.BuyPeriod <= 0:
+		err = fmt.Errorf("%s: the buy period must be positive", funcName)
+	case cfg.Participation < 0 || cfg.Participation > 1:
+		err = fmt.Errorf("%s: the participation must be between 0 and 1",
+			funcName)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// The alpha and number of windows only apply to the original stake
+	// difficulty algorithm which is no longer used by consensus, so warn
+	// that they do not affect the simulation.
+	if cfg.StakeDiffAlpha != 0 || cfg.StakeDiffWindows != 0 {
+		fmt.Fprintln(os.Stderr, "Warning: the stake difficulty alpha and "+
+			"number of windows are not used by the DCP0001 stake "+
+			"difficulty algorithm enforced by consensus and do not "+
+			"change the results")
+	}
+
+	return &cfg, &simParams, nil
+}
@@ -0,0 +1,323 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/stake"
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/wire"
+)
+
+// blockStats houses the values of the time series recorded for every simulated
+// block.
+type blockStats struct {
+	height    int64
+	sbits     int64
+	poolSize  uint32
+	immature  int64
+	purchased uint8
+	votes     uint16
+	missed    int
+	expired   int
+	revoked   uint8
+	supply    int64
+	locked    int64
+}
+
+// simulator drives a stake node, the ticket lottery and the stake difficulty
+// algorithm over a synthetic chain.
+type simulator struct {
+	cfg    *config
+	params *chaincfg.Params
+	rng    *rand.Rand
+
+	// subsidyCache is used to track the coin supply of the synthetic chain.
+	subsidyCache *blockchain.SubsidyCache
+
+	// node is the stake node of the current tip of the synthetic chain and
+	// tipHash is the hash of its header.
+	node    *stake.Node
+	tipHash chainhash.Hash
+
+	// sbits, poolSizes and freshStake hold the stake difficulty, header pool
+	// size and number of purchased tickets of every block by height, which
+	// is everything the stake difficulty algorithm needs.
+	sbits      []int64
+	poolSizes  []int64
+	freshStake []int64
+
+	// purchases holds the tickets purchased in every block by height so
+	// they can be added to the pool once they mature, and prices holds the
+	// purchase price of all tickets that are not voted or revoked yet.
+	purchases [][]chainhash.Hash
+	prices    map[chainhash.Hash]int64
+
+	// replay holds the replayed number of tickets purchased in every block
+	// starting at height 1 when purchases are not modelled.
+	replay []uint8
+
+	supply int64
+	locked int64
+}
+
+// newSimulator returns a simulator for the passed parameters that starts at
+// the genesis block.
+func newSimulator(cfg *config, params *chaincfg.Params) *simulator {
+	genesisHeader := &params.GenesisBlock.Header
+	return &simulator{
+		cfg:          cfg,
+		params:       params,
+		rng:          rand.New(rand.NewSource(cfg.Seed)),
+		subsidyCache: blockchain.NewSubsidyCache(0, params),
+		node:         stake.GenesisNode(params),
+		tipHash:      params.GenesisBlock.BlockHash(),
+		sbits:        []int64{genesisHeader.SBits},
+		poolSizes:    []int64{0},
+		freshStake:   []int64{0},
+		purchases:    [][]chainhash.Hash{nil},
+		prices:       make(map[chainhash.Hash]int64),
+	}
+}
+
+// loadReplay reads the number of tickets purchased in every block starting at
+// height 1 from the passed CSV data.  The last field of each record is used,
+// so both plain lists and height,count records are accepted, and a leading
+// header record is skipped.
+func (s *simulator) loadReplay(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		field := strings.TrimSpace(fields[len(fields)-1])
+		n, err := strconv.ParseUint(field, 10, 8)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return fmt.Errorf("line %d: invalid number of tickets %q",
+				line, field)
+		}
+		s.replay = append(s.replay, uint8(n))
+	}
+	return scanner.Err()
+}
+
+// sumPurchasedTickets returns the number of tickets purchased in the specified
+// number of blocks ending at the passed height.
+func (s *simulator) sumPurchasedTickets(height, numToSum int64) int64 {
+	var numPurchased int64
+	for h := height; h >= 0 && h > height-numToSum; h-- {
+		numPurchased += s.freshStake[h]
+	}
+	return numPurchased
+}
+
+// nextStakeDiff returns the stake difficulty of the block at the passed height.
+// It mirrors the chain traversal the consensus rules perform in order to
+// obtain the pool sizes for the DCP0001 stake difficulty algorithm.
+func (s *simulator) nextStakeDiff(nextHeight int64) int64 {
+	if nextHeight < int64(s.params.CoinbaseMaturity)+1 {
+		return s.params.MinimumStakeDiff
+	}
+
+	intervalSize := s.params.StakeDiffWindowSize
+	curDiff := s.sbits[nextHeight-1]
+	if nextHeight%intervalSize != 0 {
+		return curDiff
+	}
+
+	ticketMaturity := int64(s.params.TicketMaturity)
+	var prevPoolSizeAll int64
+	prevRetargetHeight := nextHeight - intervalSize - 1
+	if prevRetargetHeight >= 0 {
+		prevPoolSizeAll = s.poolSizes[prevRetargetHeight] +
+			s.sumPurchasedTickets(prevRetargetHeight, ticketMaturity)
+	}
+	if prevPoolSizeAll == 0 {
+		return curDiff
+	}
+
+	curPoolSizeAll := s.poolSizes[nextHeight-1] +
+		s.sumPurchasedTickets(nextHeight-1, ticketMaturity)
+	return blockchain.CalcNextStakeDiffFromPoolSizes(s.params, nextHeight,
+		curDiff, prevPoolSizeAll, curPoolSizeAll)
+}
+
+// numPurchases returns the number of tickets purchased in the block at the
+// passed height for the given ticket price.
+//
+// Unless purchases are replayed, stakeholders are modelled to aim at locking
+// the configured fraction of the coin supply in tickets and to spread the
+// purchases needed to reach it over the buy period, so fewer tickets are
+// bought as the price rises.
+func (s *simulator) numPurchases(height, price int64) uint8 {
+	// Tickets purchased before this height would never mature into the
+	// pool, and the stake difficulty is only defined after the coinbase
+	// maturity.
+	firstHeight := s.params.StakeEnabledHeight - int64(s.params.TicketMaturity)
+	if minHeight := int64(s.params.CoinbaseMaturity) + 1; firstHeight < minHeight {
+		firstHeight = minHeight
+	}
+	if height < firstHeight || price <= 0 {
+		return 0
+	}
+
+	maxFresh := float64(s.params.MaxFreshStakePerBlock)
+	var n float64
+	if s.replay != nil {
+		n = float64(s.replay[height-1])
+	} else {
+		deficit := s.cfg.StakedFraction*float64(s.supply) - float64(s.locked)
+		if deficit <= 0 {
+			return 0
+		}
+		n = deficit / float64(price) / float64(s.cfg.BuyPeriod)
+
+		// Round the fractional ticket randomly so the expected number of
+		// purchases matches the model.
+		whole, frac := math.Modf(n)
+		n = whole
+		if s.rng.Float64() < frac {
+			n++
+		}
+
+		// Purchases are limited by the coins that are not locked yet.
+		if affordable := float64((s.supply - s.locked) / price); n > affordable {
+			n = affordable
+		}
+	}
+	if n > maxFresh {
+		n = maxFresh
+	}
+	return uint8(n)
+}
+
+// ticketHash returns a unique synthetic hash for the ticket with the passed
+// index purchased in the block at the passed height.
+func ticketHash(height int64, index int) chainhash.Hash {
+	var b [12]byte
+	binary.LittleEndian.PutUint64(b[0:8], uint64(height))
+	binary.LittleEndian.PutUint32(b[8:12], uint32(index))
+	return chainhash.HashH(b[:])
+}
+
+// connectBlock creates the next block of the synthetic chain, connects it to
+// the current stake node and returns its statistics.
+func (s *simulator) connectBlock() (*blockStats, error) {
+	parent := s.node
+	height := int64(parent.Height()) + 1
+	sbits := s.nextStakeDiff(height)
+
+	// Tickets selected by the lottery vote with the configured probability
+	// once votes are required.
+	var voted []chainhash.Hash
+	if height >= s.params.StakeValidationHeight {
+		for _, winner := range parent.Winners() {
+			if s.rng.Float64() < s.cfg.Participation {
+				voted = append(voted, winner)
+			}
+		}
+	}
+
+	// The tickets missed or expired by the parent are revoked right away
+	// as required by the automatic ticket revocations agenda.
+	revoked := parent.NewlyMissedByBlock()
+	if len(revoked) > math.MaxUint8 {
+		revoked = revoked[:math.MaxUint8]
+	}
+
+	// Purchase new tickets and add the ones bought a ticket maturity ago to
+	// the pool.
+	numPurchased := s.numPurchases(height, sbits)
+	purchased := make([]chainhash.Hash, 0, numPurchased)
+	for i := 0; i < int(numPurchased); i++ {
+		hash := ticketHash(height, i)
+		purchased = append(purchased, hash)
+		s.prices[hash] = sbits
+	}
+	var matured []chainhash.Hash
+	purchaseHeight := height - int64(s.params.TicketMaturity)
+	if height >= s.params.StakeEnabledHeight && purchaseHeight >= 0 {
+		matured = s.purchases[purchaseHeight]
+	}
+
+	genesisTime := s.params.GenesisBlock.Header.Timestamp
+	header := wire.BlockHeader{
+		Version:     s.params.GenesisBlock.Header.Version,
+		PrevBlock:   s.tipHash,
+		FinalState:  parent.FinalState(),
+		Voters:      uint16(len(voted)),
+		FreshStake:  numPurchased,
+		Revocations: uint8(len(revoked)),
+		PoolSize:    uint32(parent.PoolSize()),
+		Bits:        s.params.PowLimitBits,
+		SBits:       sbits,
+		Height:      uint32(height),
+		Timestamp: genesisTime.Add(time.Duration(height) *
+			s.params.TargetTimePerBlock),
+		Nonce: s.rng.Uint32(),
+	}
+	node, err := parent.ConnectNode(header, voted, revoked, matured)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect block %d: %v", height,
+			err)
+	}
+
+	// Update the coin supply and the coins locked in tickets.
+	if height == 1 {
+		s.supply += s.params.BlockOneSubsidy()
+	} else {
+		s.supply += blockchain.CalcBlockWorkSubsidy(s.subsidyCache, height,
+			header.Voters, s.params)
+		s.supply += blockchain.CalcBlockTaxSubsidy(s.subsidyCache, height,
+			header.Voters, s.params)
+		s.supply += int64(header.Voters) * blockchain.CalcStakeVoteSubsidy(
+			s.subsidyCache, height, s.params)
+	}
+	s.locked += int64(numPurchased) * sbits
+	for _, hashes := range [][]chainhash.Hash{voted, revoked} {
+		for _, hash := range hashes {
+			s.locked -= s.prices[hash]
+			delete(s.prices, hash)
+		}
+	}
+
+	s.node = node
+	s.tipHash = header.BlockHash()
+	s.sbits = append(s.sbits, sbits)
+	s.poolSizes = append(s.poolSizes, int64(header.PoolSize))
+	s.freshStake = append(s.freshStake, int64(numPurchased))
+	s.purchases = append(s.purchases, purchased)
+
+	expired := len(node.ExpiredByBlock())
+	return &blockStats{
+		height:    height,
+		sbits:     sbits,
+		poolSize:  uint32(node.PoolSize()),
+		immature:  s.sumPurchasedTickets(height, int64(s.params.TicketMaturity)),
+		purchased: numPurchased,
+		votes:     header.Voters,
+		missed:    len(node.NewlyMissedByBlock()) - expired,
+		expired:   expired,
+		revoked:   header.Revocations,
+		supply:    s.supply,
+		locked:    s.locked,
+	}, nil
+}
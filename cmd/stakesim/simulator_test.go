@@ -0,0 +1,265 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/james-ray/hcd/chaincfg"
+)
+
+// TestNextStakeDiff ensures the ticket price of the simulated blocks is
+// calculated from the pool sizes and purchases of the previous blocks as
+// expected.  The expected values are manually calculated from the simnet
+// parameters, so they need to be updated if those change.
+func TestNextStakeDiff(t *testing.T) {
+	params := &chaincfg.SimNetParams
+
+	tests := []struct {
+		name       string
+		nextHeight int64
+		curDiff    int64 // ticket price of the previous block
+		prevPool   int64 // pool size at the previous retarget
+		curPool    int64 // pool size of the previous block
+		fresh      int64 // tickets purchased in every block
+		want       int64
+	}{{
+		name:       "before coinbase maturity",
+		nextHeight: 16,
+		curDiff:    5e8,
+		want:       params.MinimumStakeDiff,
+	}, {
+		name:       "not a retarget height",
+		nextHeight: 41,
+		curDiff:    123456,
+		prevPool:   300,
+		curPool:    400,
+		fresh:      5,
+		want:       123456,
+	}, {
+		name:       "no tickets at the previous retarget",
+		nextHeight: 24,
+		curDiff:    50000,
+		want:       50000,
+	}, {
+		// 1e8 * 480^2 / (300 + 16*5) / (5 * (64 + 16))
+		name:       "pool growth",
+		nextHeight: 48,
+		curDiff:    1e8,
+		prevPool:   300,
+		curPool:    400,
+		fresh:      5,
+		want:       151578947,
+	}, {
+		// 20000 * 100^2 / (300 + 16*5) / (5 * (64 + 16)) = 1315
+		name:       "clamped to the minimum",
+		nextHeight: 48,
+		curDiff:    20000,
+		prevPool:   300,
+		fresh:      5,
+		want:       params.MinimumStakeDiff,
+	}, {
+		// (2e13 + 49*6.4e8 - 2*6.4e8) / 64
+		name:       "clamped to the maximum",
+		nextHeight: 48,
+		curDiff:    1e12,
+		prevPool:   320,
+		curPool:    320,
+		fresh:      5,
+		want:       312970000000,
+	}}
+
+	for _, test := range tests {
+		s := &simulator{
+			params:     params,
+			sbits:      make([]int64, test.nextHeight),
+			poolSizes:  make([]int64, test.nextHeight),
+			freshStake: make([]int64, test.nextHeight),
+		}
+		for h := range s.freshStake {
+			s.freshStake[h] = test.fresh
+		}
+		s.sbits[test.nextHeight-1] = test.curDiff
+		prevRetargetHeight := test.nextHeight - params.StakeDiffWindowSize - 1
+		if prevRetargetHeight >= 0 {
+			s.poolSizes[prevRetargetHeight] = test.prevPool
+		}
+		s.poolSizes[test.nextHeight-1] = test.curPool
+
+		got := s.nextStakeDiff(test.nextHeight)
+		if got != test.want {
+			t.Errorf("%s: unexpected ticket price - got %d, want %d",
+				test.name, got, test.want)
+		}
+	}
+}
+
+// TestNumPurchases ensures the modelled and replayed number of tickets
+// purchased in a block is calculated as expected.
+func TestNumPurchases(t *testing.T) {
+	params := &chaincfg.SimNetParams
+
+	tests := []struct {
+		name     string
+		height   int64
+		price    int64
+		supply   int64
+		locked   int64
+		fraction float64
+		replay   []uint8
+		want     uint8
+	}{{
+		name:     "tickets would not mature",
+		height:   16,
+		price:    1e8,
+		supply:   1e12,
+		fraction: 0.5,
+		want:     0,
+	}, {
+		name:     "staked fraction reached",
+		height:   100,
+		price:    1e8,
+		supply:   1e12,
+		locked:   5e11,
+		fraction: 0.5,
+		want:     0,
+	}, {
+		// (0.5 * 1e12) / 6.25e9 / 8
+		name:     "spread over the buy period",
+		height:   100,
+		price:    6.25e9,
+		supply:   1e12,
+		fraction: 0.5,
+		want:     10,
+	}, {
+		name:     "limited to the max fresh stake",
+		height:   100,
+		price:    1e8,
+		supply:   1e12,
+		fraction: 0.5,
+		want:     uint8(params.MaxFreshStakePerBlock),
+	}, {
+		name:   "replayed",
+		height: 100,
+		price:  1e8,
+		replay: append(make([]uint8, 99), 7),
+		want:   7,
+	}, {
+		name:   "replayed limited to the max fresh stake",
+		height: 100,
+		price:  1e8,
+		replay: append(make([]uint8, 99), 255),
+		want:   uint8(params.MaxFreshStakePerBlock),
+	}}
+
+	for _, test := range tests {
+		cfg := &config{
+			StakedFraction: test.fraction,
+			BuyPeriod:      params.StakeDiffWindowSize,
+		}
+		s := newSimulator(cfg, params)
+		s.supply = test.supply
+		s.locked = test.locked
+		s.replay = test.replay
+
+		got := s.numPurchases(test.height, test.price)
+		if got != test.want {
+			t.Errorf("%s: unexpected number of purchases - got %d, "+
+				"want %d", test.name, got, test.want)
+		}
+	}
+}
+
+// TestSimulatorReplay ensures that simulating a chain with a constant number of
+// replayed purchases and full participation produces the expected ticket
+// prices, pool sizes and coin supply.  The expected values are manually
+// calculated from the simnet parameters, so they need to be updated if those
+// change.
+func TestSimulatorReplay(t *testing.T) {
+	params := &chaincfg.SimNetParams
+	cfg := &config{Participation: 1.0}
+	s := newSimulator(cfg, params)
+
+	// Purchase 5 tickets in every block.
+	replay := "height,purchased\n" + strings.Repeat("5\n", 240)
+	if err := s.loadReplay(strings.NewReader(replay)); err != nil {
+		t.Fatalf("loadReplay: unexpected error: %v", err)
+	}
+
+	// The first tickets are purchased after the coinbase maturity and added
+	// to the pool a ticket maturity later, right after the stake enabled
+	// height.  The votes at the stake validation height and later balance
+	// the maturing tickets, so the pool size stays at 5 * (143 - 32).  The
+	// work and tax subsidies of 6.4e8 * 0.7 are paid until the reduction at
+	// height 128, after which the full reduced subsidy of 638980456 is paid
+	// once votes are required.
+	tests := []struct {
+		height    int64
+		sbits     int64
+		poolSize  uint32
+		immature  int64
+		purchased uint8
+		votes     uint16
+		supply    int64
+	}{
+		{1, 20000, 0, 0, 0, 0, 20000000000000},
+		{2, 20000, 0, 0, 0, 0, 20000448000000},
+		{17, 20000, 0, 5, 5, 0, 20007168000000},
+		{32, 20000, 0, 80, 5, 0, 20013888000000},
+		{33, 20000, 5, 80, 5, 0, 20014336000000},
+		{96, 21728, 320, 80, 5, 0, 20042560000000},
+		{127, 45758, 475, 80, 5, 0, 20056448000000},
+		{128, 67851, 480, 80, 5, 0, 20056895286318},
+		{143, 107358, 555, 80, 5, 0, 20063604581088},
+		{144, 180552, 555, 80, 5, 5, 20064243561541},
+		{200, 4624138, 555, 80, 5, 5, 20100026466909},
+		{240, 46622914, 555, 80, 5, 5, 20125585685029},
+	}
+
+	var stats *blockStats
+	for _, test := range tests {
+		for stats == nil || stats.height < test.height {
+			var err error
+			stats, err = s.connectBlock()
+			if err != nil {
+				t.Fatalf("connectBlock: unexpected error: %v", err)
+			}
+		}
+
+		if stats.sbits != test.sbits {
+			t.Errorf("block %d: unexpected ticket price - got %d, "+
+				"want %d", test.height, stats.sbits, test.sbits)
+		}
+		if stats.poolSize != test.poolSize {
+			t.Errorf("block %d: unexpected pool size - got %d, "+
+				"want %d", test.height, stats.poolSize,
+				test.poolSize)
+		}
+		if stats.immature != test.immature {
+			t.Errorf("block %d: unexpected immature tickets - got "+
+				"%d, want %d", test.height, stats.immature,
+				test.immature)
+		}
+		if stats.purchased != test.purchased {
+			t.Errorf("block %d: unexpected purchased tickets - got "+
+				"%d, want %d", test.height, stats.purchased,
+				test.purchased)
+		}
+		if stats.votes != test.votes {
+			t.Errorf("block %d: unexpected votes - got %d, want %d",
+				test.height, stats.votes, test.votes)
+		}
+		if stats.missed != 0 || stats.expired != 0 || stats.revoked != 0 {
+			t.Errorf("block %d: unexpected missed tickets - got "+
+				"%d missed, %d expired, %d revoked", test.height,
+				stats.missed, stats.expired, stats.revoked)
+		}
+		if stats.supply != test.supply {
+			t.Errorf("block %d: unexpected supply - got %d, want %d",
+				test.height, stats.supply, test.supply)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/james-ray/hcd/hcutil"
+)
+
+// csvHeader is the header record of the CSV time series.
+var csvHeader = []string{"height", "ticketprice", "poolsize", "immature",
+	"purchased", "votes", "participation", "missed", "expired", "revoked",
+	"supply", "stakedfraction"}
+
+// record returns the CSV record for the passed block statistics.
+func (s *blockStats) record(ticketsPerBlock uint16) []string {
+	participation := ""
+	if s.votes > 0 || s.missed > 0 {
+		participation = strconv.FormatFloat(float64(s.votes)/
+			float64(ticketsPerBlock), 'f', 4, 64)
+	}
+	stakedFraction := 0.0
+	if s.supply > 0 {
+		stakedFraction = float64(s.locked) / float64(s.supply)
+	}
+	return []string{
+		strconv.FormatInt(s.height, 10),
+		strconv.FormatFloat(hcutil.Amount(s.sbits).ToCoin(), 'f', 8, 64),
+		strconv.FormatUint(uint64(s.poolSize), 10),
+		strconv.FormatInt(s.immature, 10),
+		strconv.FormatUint(uint64(s.purchased), 10),
+		strconv.FormatUint(uint64(s.votes), 10),
+		participation,
+		strconv.Itoa(s.missed),
+		strconv.Itoa(s.expired),
+		strconv.FormatUint(uint64(s.revoked), 10),
+		strconv.FormatFloat(hcutil.Amount(s.supply).ToCoin(), 'f', 8, 64),
+		strconv.FormatFloat(stakedFraction, 'f', 4, 64),
+	}
+}
+
+// realMain is the real main function for the utility.  It is necessary to work
+// around the fact that deferred functions do not run when os.Exit() is called.
+func realMain() error {
+	cfg, params, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	sim := newSimulator(cfg, params)
+	if cfg.Replay != "" {
+		f, err := os.Open(cfg.Replay)
+		if err != nil {
+			return err
+		}
+		err = sim.loadReplay(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", cfg.Replay, err)
+		}
+		if len(sim.replay) == 0 {
+			return fmt.Errorf("%s: no purchases to replay", cfg.Replay)
+		}
+		if int64(len(sim.replay)) < cfg.NumBlocks {
+			cfg.NumBlocks = int64(len(sim.replay))
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if cfg.OutFile != "" {
+		f, err := os.Create(cfg.OutFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+	for i := int64(0); i < cfg.NumBlocks; i++ {
+		stats, err := sim.connectBlock()
+		if err != nil {
+			out.Flush()
+			return err
+		}
+		if err := out.Write(stats.record(params.TicketsPerBlock)); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func main() {
+	if err := realMain(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
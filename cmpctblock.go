@@ -0,0 +1,192 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/wire"
+)
+
+// errShortIDCollision is returned when two transactions of a compact block
+// share the same short transaction id, in which case the block can't be
+// reconstructed and has to be downloaded in full.
+var errShortIDCollision = errors.New("short transaction id collision")
+
+// txTreeSlots houses the transactions of one transaction tree of a block which
+// is being reconstructed from a compact block along with the positions of the
+// transactions which are only known by their short ids.
+type txTreeSlots struct {
+	txns     []*wire.MsgTx
+	shortIDs map[uint64]uint32
+	collided map[uint32]struct{}
+}
+
+// newTxTreeSlots returns the slots for a transaction tree made up of the
+// passed short ids and prefilled transactions.
+func newTxTreeSlots(shortIDs []uint64, prefilled []wire.PrefilledTx) (*txTreeSlots, error) {
+	numTxns := len(shortIDs) + len(prefilled)
+	t := &txTreeSlots{
+		txns:     make([]*wire.MsgTx, numTxns),
+		shortIDs: make(map[uint64]uint32, len(shortIDs)),
+		collided: make(map[uint32]struct{}),
+	}
+	for _, ptx := range prefilled {
+		if ptx.Index >= uint32(numTxns) {
+			return nil, fmt.Errorf("prefilled transaction index %d "+
+				"is out of range for %d transactions", ptx.Index,
+				numTxns)
+		}
+		if ptx.Tx == nil || t.txns[ptx.Index] != nil {
+			return nil, fmt.Errorf("prefilled transaction index %d "+
+				"is missing its transaction or is repeated",
+				ptx.Index)
+		}
+		t.txns[ptx.Index] = ptx.Tx
+	}
+
+	// The short ids fill the positions which are not prefilled in order.
+	next := 0
+	for i := range t.txns {
+		if t.txns[i] != nil {
+			continue
+		}
+		if next >= len(shortIDs) {
+			return nil, fmt.Errorf("not enough short ids for %d "+
+				"transactions", numTxns)
+		}
+		id := shortIDs[next]
+		next++
+		if _, exists := t.shortIDs[id]; exists {
+			return nil, errShortIDCollision
+		}
+		t.shortIDs[id] = uint32(i)
+	}
+	return t, nil
+}
+
+// match places the passed transaction in the position with the passed short id,
+// if any.  A position matched by more than one transaction is left empty so
+// the transaction is requested from the peer instead.
+func (t *txTreeSlots) match(id uint64, tx *wire.MsgTx) {
+	i, ok := t.shortIDs[id]
+	if !ok {
+		return
+	}
+	if _, ok := t.collided[i]; ok {
+		return
+	}
+	if t.txns[i] != nil {
+		t.txns[i] = nil
+		t.collided[i] = struct{}{}
+		return
+	}
+	t.txns[i] = tx
+}
+
+// missing returns the positions of the transactions which are still unknown.
+func (t *txTreeSlots) missing() []uint32 {
+	var missing []uint32
+	for i, tx := range t.txns {
+		if tx == nil {
+			missing = append(missing, uint32(i))
+		}
+	}
+	return missing
+}
+
+// partialBlock houses a block announced with a compact block which is being
+// reconstructed along with the positions of the transactions in each tree that
+// still have to be requested from the peer which announced it.
+type partialBlock struct {
+	header        wire.BlockHeader
+	transactions  []*wire.MsgTx
+	sTransactions []*wire.MsgTx
+	missing       []uint32
+	sMissing      []uint32
+	peer          *serverPeer
+}
+
+// newPartialBlock reconstructs as much as possible of the block announced by
+// the passed compact block from its prefilled transactions and the passed
+// mempool transactions.  Both the regular and the stake transaction trees,
+// which includes the votes, are reconstructed.
+func newPartialBlock(msg *wire.MsgCmpctBlock, pool []*hcutil.Tx) (*partialBlock, error) {
+	regular, err := newTxTreeSlots(msg.ShortIDs, msg.PrefilledTxs)
+	if err != nil {
+		return nil, err
+	}
+	stake, err := newTxTreeSlots(msg.SShortIDs, msg.PrefilledSTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	k0, k1 := msg.ShortIDKeys()
+	for _, tx := range pool {
+		id := wire.ShortTxID(k0, k1, tx.Hash())
+		regular.match(id, tx.MsgTx())
+		stake.match(id, tx.MsgTx())
+	}
+
+	return &partialBlock{
+		header:        msg.Header,
+		transactions:  regular.txns,
+		sTransactions: stake.txns,
+		missing:       regular.missing(),
+		sMissing:      stake.missing(),
+	}, nil
+}
+
+// complete returns whether or not all of the transactions of the block are
+// known.
+func (pb *partialBlock) complete() bool {
+	return len(pb.missing) == 0 && len(pb.sMissing) == 0
+}
+
+// fill places the passed transactions, which must be the missing transactions
+// of each tree in order, in the block.
+func (pb *partialBlock) fill(txns, sTxns []*wire.MsgTx) error {
+	if len(txns) != len(pb.missing) || len(sTxns) != len(pb.sMissing) {
+		return fmt.Errorf("got %d regular and %d stake transactions, "+
+			"expected %d and %d", len(txns), len(sTxns),
+			len(pb.missing), len(pb.sMissing))
+	}
+	for i, idx := range pb.missing {
+		pb.transactions[idx] = txns[i]
+	}
+	for i, idx := range pb.sMissing {
+		pb.sTransactions[idx] = sTxns[i]
+	}
+	pb.missing = nil
+	pb.sMissing = nil
+	return nil
+}
+
+// block returns the reconstructed block.  It must only be called once the
+// block is complete.  Since short ids only commit to the transaction prefixes,
+// the merkle roots of the block are checked against its header and an error
+// is returned when they don't match.
+func (pb *partialBlock) block() (*hcutil.Block, error) {
+	block := hcutil.NewBlock(&wire.MsgBlock{
+		Header:        pb.header,
+		Transactions:  pb.transactions,
+		STransactions: pb.sTransactions,
+	})
+
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
+	if !pb.header.MerkleRoot.IsEqual(merkles[len(merkles)-1]) {
+		return nil, fmt.Errorf("reconstructed block %v has a bad "+
+			"merkle root", block.Hash())
+	}
+	merkles = blockchain.BuildMerkleTreeStore(block.STransactions())
+	if !pb.header.StakeRoot.IsEqual(merkles[len(merkles)-1]) {
+		return nil, fmt.Errorf("reconstructed block %v has a bad "+
+			"stake merkle root", block.Hash())
+	}
+	return block, nil
+}
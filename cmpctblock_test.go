@@ -0,0 +1,156 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/wire"
+)
+
+// TestPartialBlock ensures blocks announced with compact blocks are
+// reconstructed from the mempool and the transactions requested from the peer.
+func TestPartialBlock(t *testing.T) {
+	newTx := func(index uint32) *wire.MsgTx {
+		tx := wire.NewMsgTx()
+		prevOut := wire.NewOutPoint(&chainhash.Hash{0x01}, index,
+			wire.TxTreeRegular)
+		tx.AddTxIn(wire.NewTxIn(prevOut, []byte{0x51}))
+		tx.AddTxOut(wire.NewTxOut(int64(index)*1000, []byte{0x51}))
+		return tx
+	}
+
+	// Create a block with four regular and three stake transactions and
+	// set its merkle roots.
+	msgBlock := &wire.MsgBlock{Header: wire.BlockHeader{Height: 100}}
+	for i := uint32(0); i < 4; i++ {
+		msgBlock.AddTransaction(newTx(i))
+	}
+	for i := uint32(10); i < 13; i++ {
+		msgBlock.AddSTransaction(newTx(i))
+	}
+	block := hcutil.NewBlock(msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+	merkles = blockchain.BuildMerkleTreeStore(block.STransactions())
+	msgBlock.Header.StakeRoot = *merkles[len(merkles)-1]
+
+	// Only some of the transactions of each tree are in the mempool along
+	// with a transaction which is not part of the block.
+	cmpctBlock := wire.NewMsgCmpctBlock(msgBlock, 0x1234)
+	pool := []*hcutil.Tx{
+		hcutil.NewTx(msgBlock.Transactions[1]),
+		hcutil.NewTx(msgBlock.Transactions[3]),
+		hcutil.NewTx(msgBlock.STransactions[0]),
+		hcutil.NewTx(msgBlock.STransactions[2]),
+		hcutil.NewTx(newTx(20)),
+	}
+	pb, err := newPartialBlock(cmpctBlock, pool)
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	if pb.complete() {
+		t.Fatal("newPartialBlock: block unexpectedly complete")
+	}
+	if want := []uint32{2}; !reflect.DeepEqual(pb.missing, want) {
+		t.Fatalf("newPartialBlock: missing %v, want %v", pb.missing,
+			want)
+	}
+	if want := []uint32{1}; !reflect.DeepEqual(pb.sMissing, want) {
+		t.Fatalf("newPartialBlock: stake missing %v, want %v",
+			pb.sMissing, want)
+	}
+
+	// Filling the block with the wrong number of transactions must fail.
+	err = pb.fill(nil, []*wire.MsgTx{msgBlock.STransactions[1]})
+	if err == nil {
+		t.Fatal("fill: did not receive expected error")
+	}
+
+	// Filling the block with the wrong transactions must produce a block
+	// whose merkle roots don't match.
+	wrong := *pb
+	wrong.transactions = append([]*wire.MsgTx(nil), pb.transactions...)
+	wrong.sTransactions = append([]*wire.MsgTx(nil), pb.sTransactions...)
+	err = wrong.fill([]*wire.MsgTx{newTx(21)},
+		[]*wire.MsgTx{msgBlock.STransactions[1]})
+	if err != nil {
+		t.Fatalf("fill: unexpected error: %v", err)
+	}
+	if _, err := wrong.block(); err == nil {
+		t.Fatal("block: did not receive expected error")
+	}
+
+	// Filling the block with the missing transactions must reproduce the
+	// original block.
+	err = pb.fill([]*wire.MsgTx{msgBlock.Transactions[2]},
+		[]*wire.MsgTx{msgBlock.STransactions[1]})
+	if err != nil {
+		t.Fatalf("fill: unexpected error: %v", err)
+	}
+	if !pb.complete() {
+		t.Fatal("fill: block is not complete")
+	}
+	got, err := pb.block()
+	if err != nil {
+		t.Fatalf("block: unexpected error: %v", err)
+	}
+	if want := msgBlock.BlockHash(); *got.Hash() != want {
+		t.Fatalf("block: got hash %v, want %v", got.Hash(), want)
+	}
+}
+
+// TestPartialBlockMalformed ensures malformed compact blocks from a peer are
+// rejected with an error rather than causing a panic.
+func TestPartialBlockMalformed(t *testing.T) {
+	tx := wire.NewMsgTx()
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	msgBlock := &wire.MsgBlock{Header: wire.BlockHeader{Height: 100}}
+	msgBlock.AddTransaction(tx)
+	msgBlock.AddTransaction(tx.Copy())
+
+	tests := []struct {
+		name   string
+		modify func(*wire.MsgCmpctBlock)
+	}{{
+		name: "repeated prefilled index",
+		modify: func(msg *wire.MsgCmpctBlock) {
+			msg.PrefilledTxs = append(msg.PrefilledTxs,
+				msg.PrefilledTxs[0])
+		},
+	}, {
+		name: "prefilled index out of range",
+		modify: func(msg *wire.MsgCmpctBlock) {
+			msg.PrefilledTxs[0].Index = 5
+		},
+	}, {
+		name: "missing prefilled transaction",
+		modify: func(msg *wire.MsgCmpctBlock) {
+			msg.PrefilledTxs[0].Tx = nil
+		},
+	}}
+	for _, test := range tests {
+		msg := wire.NewMsgCmpctBlock(msgBlock, 0x1234)
+		test.modify(msg)
+		if _, err := newPartialBlock(msg, nil); err == nil {
+			t.Errorf("newPartialBlock (%s): did not receive expected "+
+				"error", test.name)
+		}
+	}
+
+	// Too few short ids for the non prefilled positions must not read past
+	// the end of the short ids.
+	_, err := newTxTreeSlots(nil, []wire.PrefilledTx{
+		{Index: 1, Tx: tx},
+		{Index: 1, Tx: tx},
+	})
+	if err == nil {
+		t.Error("newTxTreeSlots: did not receive expected error")
+	}
+}
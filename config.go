@@ -26,6 +26,7 @@ import (
 	"github.com/btcsuite/go-socks/socks"
 	"github.com/james-ray/hcd/connmgr"
 	"github.com/james-ray/hcd/database"
+	_ "github.com/james-ray/hcd/database/bboltdb"
 	_ "github.com/james-ray/hcd/database/ffldb"
 	"github.com/james-ray/hcd/hcutil"
 	"github.com/james-ray/hcd/mempool"
@@ -123,15 +124,23 @@ type config struct {
 	OnionProxyUser       string        `long:"onionuser" description:"Username for onion proxy server"`
 	OnionProxyPass       string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	NoOnion              bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
+	I2PProxy             string        `long:"i2p" description:"Connect to I2P destinations via SOCKS5 proxy (eg. 127.0.0.1:4447)"`
+	NoV2Transport        bool          `long:"nov2transport" description:"Disable the encrypted v2 P2P transport"`
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
 	TestNet              bool          `long:"testnet" description:"Use the test network"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
+	RegNet               bool          `long:"regnet" description:"Use the regression test network"`
+	ChainParams          string        `long:"chainparams" description:"Use the custom network described by the specified JSON parameters file"`
 	DisableCheckpoints   bool          `long:"nocheckpoints" description:"Disable built-in checkpoints.  Don't do this unless you know what you're doing."`
 	DbType               string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
+	BlockCompression     string        `long:"blockcompression" description:"Compression for newly stored blocks {none, snappy} -- only supported by ffldb (keep the stored setting)"`
 	Profile              string        `long:"profile" description:"Enable HTTP profiling on given [addr:]port -- NOTE port must be between 1024 and 65536"`
 	CPUProfile           string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
 	MemProfile           string        `long:"memprofile" description:"Write mem profile to the specified file"`
-	DumpBlockchain       string        `long:"dumpblockchain" description:"Write blockchain as a flat file of blocks for use with addblock, to the specified filename"`
+	DumpBlockchain       string        `long:"dumpblockchain" description:"Write blockchain as a bootstrap file of blocks for use with importbootstrap or addblock, to the specified filename"`
+	ImportBootstrap      string        `long:"importbootstrap" description:"Import the blocks of the specified bootstrap file before starting the server -- an interrupted import resumes when the file is imported again"`
+	Reindex              bool          `long:"reindex" description:"Rebuild the chain state and stake database from the stored blocks on start up and drop the optional indexes so the enabled ones are rebuilt -- disabled indexes are not rebuilt and an interrupted reindex resumes on the next start"`
+	ReindexChainState    bool          `long:"reindex-chainstate" description:"Rebuild the chain state and stake database from the stored blocks on start up, keeping the optional indexes -- an interrupted reindex resumes on the next start"`
 	MiningTimeOffset     int           `long:"miningtimeoffset" description:"Offset the mining timestamp of a block by this many seconds (positive values are in the past)"`
 	DebugLevel           string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
@@ -159,12 +168,15 @@ type config struct {
 	DropAddrIndex        bool          `long:"dropaddrindex" description:"Deletes the address-based transaction index from the database on start up and then exits."`
 	NoExistsAddrIndex    bool          `long:"noexistsaddrindex" description:"Disable the exists address index, which tracks whether or not an address has even been used."`
 	DropExistsAddrIndex  bool          `long:"dropexistsaddrindex" description:"Deletes the exists address index from the database on start up and then exits."`
+	TicketIndex          bool          `long:"ticketindex" description:"Maintain a ticket lifecycle index which makes the getticketinfo and getticketsbyaddress RPCs available"`
+	DropTicketIndex      bool          `long:"dropticketindex" description:"Deletes the ticket lifecycle index from the database on start up and then exits."`
 	PipeRx               uint          `long:"piperx" description:"File descriptor of read end pipe to enable parent -> child process communication"`
 	PipeTx               uint          `long:"pipetx" description:"File descriptor of write end pipe to enable parent <- child process communication"`
 	LifetimeEvents       bool          `long:"lifetimeevents" description:"Send lifetime notifications over the TX pipe"`
 	onionlookup          func(string) ([]net.IP, error)
 	lookup               func(string) ([]net.IP, error)
 	oniondial            func(string, string) (net.Conn, error)
+	i2pdial              func(string, string) (net.Conn, error)
 	dial                 func(string, string) (net.Conn, error)
 	miningAddrs          []hcutil.Address
 	minRelayTxFee        hcutil.Amount
@@ -527,7 +539,8 @@ func loadConfig() (*config, []string, error) {
 
 	// Create a default config file when one does not exist and the user did
 	// not specify an override.
-	if !preCfg.SimNet && preCfg.ConfigFile == defaultConfigFile &&
+	if !preCfg.SimNet && !preCfg.RegNet &&
+		preCfg.ConfigFile == defaultConfigFile &&
 		!fileExists(preCfg.ConfigFile) {
 
 		err := createDefaultConfigFile(preCfg.ConfigFile)
@@ -540,7 +553,7 @@ func loadConfig() (*config, []string, error) {
 	// Load additional config from file.
 	var configFileError error
 	parser := newConfigParser(&cfg, &serviceOpts, flags.Default)
-	if !cfg.SimNet || preCfg.ConfigFile != defaultConfigFile {
+	if !(cfg.SimNet || cfg.RegNet) || preCfg.ConfigFile != defaultConfigFile {
 		err := flags.NewIniParser(parser).ParseFile(preCfg.ConfigFile)
 		if err != nil {
 			if _, ok := err.(*os.PathError); !ok {
@@ -597,9 +610,27 @@ func loadConfig() (*config, []string, error) {
 		activeNetParams = &simNetParams
 		cfg.DisableDNSSeed = true
 	}
+	if cfg.RegNet {
+		numNets++
+		// Also disable dns seeding on the regression test network.
+		activeNetParams = &regNetParams
+		cfg.DisableDNSSeed = true
+	}
+	if cfg.ChainParams != "" {
+		numNets++
+		customParams, err := loadChainParams(cleanAndExpandPath(
+			cfg.ChainParams))
+		if err != nil {
+			str := "%s: unable to load chain parameters: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+		activeNetParams = customParams
+	}
 	if numNets > 1 {
-		str := "%s: the testnet and simnet params can't be " +
-			"used together -- choose one of the three"
+		str := "%s: the testnet, simnet, regnet and chainparams params " +
+			"can't be used together -- choose one of the five"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
@@ -675,6 +706,17 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Validate the block compression.
+	if cfg.BlockCompression != "" {
+		_, err := database.ParseBlockCompression(cfg.BlockCompression)
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Validate format of profile, can be an address:port, or just a port.
 	if cfg.Profile != "" {
 		// if profile is just a number, then add a default host of "127.0.0.1" such that Profile is a valid tcp address
@@ -901,6 +943,26 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --ticketindex and --dropticketindex do not mix.
+	if cfg.TicketIndex && cfg.DropTicketIndex {
+		err := fmt.Errorf("%s: the --ticketindex and --dropticketindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --reindex and --reindex-chainstate do not mix.
+	if cfg.Reindex && cfg.ReindexChainState {
+		err := fmt.Errorf("%s: the --reindex and --reindex-chainstate "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Check getwork keys are valid and saved parsed versions.
 	cfg.miningAddrs = make([]hcutil.Address, 0, len(cfg.GetWorkKeys)+
 		len(cfg.MiningAddrs))
@@ -1102,6 +1164,28 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// I2P destinations can only be reached through an I2P router, so dialing
+	// them fails unless the SOCKS5 proxy of one is specified via --i2p.
+	if cfg.I2PProxy != "" {
+		_, _, err := net.SplitHostPort(cfg.I2PProxy)
+		if err != nil {
+			str := "%s: I2P proxy address '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.I2PProxy, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+
+		cfg.i2pdial = func(a, b string) (net.Conn, error) {
+			proxy := &socks.Proxy{Addr: cfg.I2PProxy}
+			return proxy.Dial(a, b)
+		}
+	} else {
+		cfg.i2pdial = func(a, b string) (net.Conn, error) {
+			return nil, errors.New("no i2p proxy has been specified")
+		}
+	}
+
 	// Warn if old testnet directory is present.
 	for _, oldDir := range oldTestNets {
 		if fileExists(oldDir) {
@@ -1125,11 +1209,15 @@ func loadConfig() (*config, []string, error) {
 // dial function depending on the address and configuration options.  For
 // example, .onion addresses will be dialed using the onion specific proxy if
 // one was specified, but will otherwise use the normal dial function (which
-// could itself use a proxy or not).
+// could itself use a proxy or not).  I2P addresses are always dialed using the
+// I2P proxy.
 func hcdDial(addr net.Addr) (net.Conn, error) {
 	if strings.Contains(addr.String(), ".onion:") {
 		return cfg.oniondial(addr.Network(), addr.String())
 	}
+	if strings.Contains(addr.String(), ".i2p:") {
+		return cfg.i2pdial(addr.Network(), addr.String())
+	}
 	return cfg.dial(addr.Network(), addr.String())
 }
 
@@ -54,13 +54,15 @@ const (
 )
 
 // ConnReq is the connection request to a network address. If permanent, the
-// connection will be retried on disconnection.
+// connection will be retried on disconnection.  Block-relay-only connection
+// requests are maintained separately from the other outbound connections.
 type ConnReq struct {
 	// The following variables must only be used atomically.
 	id uint64
 
-	Addr      net.Addr
-	Permanent bool
+	Addr           net.Addr
+	Permanent      bool
+	BlockRelayOnly bool
 
 	conn       net.Conn
 	state      ConnState
@@ -125,6 +127,11 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOnly is the number of block-relay-only outbound
+	// network connections to maintain in addition to TargetOutbound.
+	// Defaults to 0.
+	TargetBlockRelayOnly uint32
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -141,6 +148,11 @@ type Config struct {
 	// to.  If nil, no new connections will be made automatically.
 	GetNewAddress func() (net.Addr, error)
 
+	// GetNewBlockRelayOnlyAddress is a way to get an address to make a
+	// block-relay-only network connection to.  If nil, GetNewAddress is
+	// used instead.
+	GetNewBlockRelayOnlyAddress func() (net.Addr, error)
+
 	// Dial connects to the address on the named network. It cannot be nil.
 	Dial func(net.Addr) (net.Conn, error)
 }
@@ -207,9 +219,22 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 				cm.NewConnReq()
 			})
 		} else {
-			go cm.NewConnReq()
+			go cm.newConnReq(c.BlockRelayOnly)
+		}
+	}
+}
+
+// numConns returns the number of the passed connections which are
+// block-relay-only connections when blockRelayOnly is set or the number of
+// the other connections otherwise.
+func numConns(conns map[uint64]*ConnReq, blockRelayOnly bool) uint32 {
+	var n uint32
+	for _, c := range conns {
+		if c.BlockRelayOnly == blockRelayOnly {
+			n++
 		}
 	}
+	return n
 }
 
 // connHandler handles all connection related requests.  It must be run as a
@@ -252,7 +277,11 @@ out:
 						go cm.cfg.OnDisconnection(connReq)
 					}
 
-					if uint32(len(conns)) < cm.cfg.TargetOutbound && msg.retry {
+					target := cm.cfg.TargetOutbound
+					if connReq.BlockRelayOnly {
+						target = cm.cfg.TargetBlockRelayOnly
+					}
+					if numConns(conns, connReq.BlockRelayOnly) < target && msg.retry {
 						cm.handleFailedConn(connReq)
 					}
 				} else {
@@ -278,17 +307,28 @@ out:
 // NewConnReq creates a new connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
+	cm.newConnReq(false)
+}
+
+// newConnReq creates a new connection request, which is a block-relay-only
+// request when blockRelayOnly is set, and connects to the corresponding
+// address.
+func (cm *ConnManager) newConnReq(blockRelayOnly bool) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
-	if cm.cfg.GetNewAddress == nil {
+	getNewAddress := cm.cfg.GetNewAddress
+	if blockRelayOnly && cm.cfg.GetNewBlockRelayOnlyAddress != nil {
+		getNewAddress = cm.cfg.GetNewBlockRelayOnlyAddress
+	}
+	if getNewAddress == nil {
 		return
 	}
 
-	c := &ConnReq{}
+	c := &ConnReq{BlockRelayOnly: blockRelayOnly}
 	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 
-	addr, err := cm.cfg.GetNewAddress()
+	addr, err := getNewAddress()
 	if err != nil {
 		cm.requests <- handleFailed{c, err}
 		return
@@ -379,6 +419,9 @@ func (cm *ConnManager) Start() {
 	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
 		go cm.NewConnReq()
 	}
+	for i := uint32(0); i < cm.cfg.TargetBlockRelayOnly; i++ {
+		go cm.newConnReq(true)
+	}
 }
 
 // Wait blocks until the connection manager halts gracefully.
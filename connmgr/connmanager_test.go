@@ -218,6 +218,86 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestTargetBlockRelayOnly tests the target number of block-relay-only
+// outbound connections is maintained separately from the other outbound
+// connections and with addresses from their own source.
+func TestTargetBlockRelayOnly(t *testing.T) {
+	targetOutbound := uint32(3)
+	targetBlockRelayOnly := uint32(2)
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:       targetOutbound,
+		TargetBlockRelayOnly: targetBlockRelayOnly,
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		GetNewBlockRelayOnlyAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.2"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	// checkConn ensures the passed connection request was made to the
+	// address of its kind.
+	checkConn := func(c *ConnReq) {
+		wantAddr := "127.0.0.1:18555"
+		if c.BlockRelayOnly {
+			wantAddr = "127.0.0.2:18555"
+		}
+		if c.Addr.String() != wantAddr {
+			t.Fatalf("connection %v: got address %v, want %v", c,
+				c.Addr, wantAddr)
+		}
+	}
+	var blockRelayOnly []*ConnReq
+	for i := uint32(0); i < targetOutbound+targetBlockRelayOnly; i++ {
+		c := <-connected
+		checkConn(c)
+		if c.BlockRelayOnly {
+			blockRelayOnly = append(blockRelayOnly, c)
+		}
+	}
+	if uint32(len(blockRelayOnly)) != targetBlockRelayOnly {
+		t.Fatalf("target block-relay-only: got %d connections, want %d",
+			len(blockRelayOnly), targetBlockRelayOnly)
+	}
+
+	// Disconnecting a block-relay-only connection must replace it with a
+	// new block-relay-only connection.
+	cmgr.Disconnect(blockRelayOnly[0].ID())
+	select {
+	case c := <-connected:
+		checkConn(c)
+		if !c.BlockRelayOnly {
+			t.Fatalf("replacement connection %v is not "+
+				"block-relay-only", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("block-relay-only connection was not replaced")
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("target outbound: got unexpected connection - %v", c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+	cmgr.Stop()
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -0,0 +1,140 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// BackupManifestName is the name of the manifest file written to the
+	// root of a backup directory.
+	BackupManifestName = "manifest.json"
+
+	// BackupManifestVersion is the current version of the backup manifest.
+	BackupManifestVersion = 1
+)
+
+// BackupFile describes a file of a database backup.
+type BackupFile struct {
+	// Name is the path of the file relative to the backup directory using
+	// forward slashes.
+	Name string `json:"name"`
+
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+
+	// SHA256 is the hex-encoded SHA-256 hash of the file contents.
+	SHA256 string `json:"sha256"`
+}
+
+// BackupManifest describes a database backup.  It is written to the root of the
+// backup directory so the backup can be verified before it is restored.
+type BackupManifest struct {
+	Version uint32       `json:"version"`
+	DbType  string       `json:"dbtype"`
+	Network uint32       `json:"network"`
+	Created int64        `json:"created"`
+	Files   []BackupFile `json:"files"`
+}
+
+// Backuper is implemented by the transactions of database drivers that are able
+// to write a consistent copy of the database while it remains in use.  Callers
+// obtain it with a type assertion on a Tx.
+type Backuper interface {
+	// Backup writes a copy of the database as it was when the transaction
+	// was started to the passed directory, which must not exist yet, along
+	// with a manifest describing the copied files.  Changes made by the
+	// transaction itself are not included.
+	//
+	// The directory is a complete database for the driver, so a backup is
+	// restored by verifying it with VerifyBackup and using the directory
+	// in place of the original database directory.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrDbExists if the backup directory already exists
+	//   - ErrTxClosed if the transaction has already been closed
+	Backup(backupPath string) (*BackupManifest, error)
+}
+
+// HashBackupFile returns a description of the file with the passed name
+// relative to the passed backup directory including its size and hash.
+func HashBackupFile(backupPath, name string) (BackupFile, error) {
+	f, err := os.Open(filepath.Join(backupPath, filepath.FromSlash(name)))
+	if err != nil {
+		return BackupFile{}, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return BackupFile{}, err
+	}
+	return BackupFile{
+		Name:   name,
+		Size:   size,
+		SHA256: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+// WriteBackupManifest writes the passed manifest to the passed backup directory.
+func WriteBackupManifest(backupPath string, manifest *BackupManifest) error {
+	serialized, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestPath := filepath.Join(backupPath, BackupManifestName)
+	return ioutil.WriteFile(manifestPath, serialized, 0600)
+}
+
+// VerifyBackup reads the manifest of the backup in the passed directory and
+// ensures every file it lists is present with the recorded size and hash.
+//
+// ErrCorruption is returned when a file does not match the manifest.
+func VerifyBackup(backupPath string) (*BackupManifest, error) {
+	manifestPath := filepath.Join(backupPath, BackupManifestName)
+	serialized, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(serialized, &manifest); err != nil {
+		str := fmt.Sprintf("malformed backup manifest %q: %v",
+			manifestPath, err)
+		return nil, makeError(ErrCorruption, str, err)
+	}
+	if manifest.Version != BackupManifestVersion {
+		str := fmt.Sprintf("unsupported backup manifest version %d",
+			manifest.Version)
+		return nil, makeError(ErrInvalid, str, nil)
+	}
+
+	for _, want := range manifest.Files {
+		got, err := HashBackupFile(backupPath, want.Name)
+		if err != nil {
+			str := fmt.Sprintf("unable to read backup file %q: %v",
+				want.Name, err)
+			return nil, makeError(ErrCorruption, str, err)
+		}
+		if got.Size != want.Size || got.SHA256 != want.SHA256 {
+			str := fmt.Sprintf("backup file %q does not match the "+
+				"manifest - got size %d and hash %s, want size "+
+				"%d and hash %s", want.Name, got.Size, got.SHA256,
+				want.Size, want.SHA256)
+			return nil, makeError(ErrCorruption, str, nil)
+		}
+	}
+
+	return &manifest, nil
+}
@@ -0,0 +1,1380 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Copyright (c) 2016 The Decred developers
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bboltdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/wire"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// dbFileName is the name of the bbolt database file inside the
+	// database directory.
+	dbFileName = "bbolt.db"
+
+	// blockHdrSize is the size of a block header.  This is simply the
+	// constant from wire and is only provided here for convenience since
+	// wire.MaxBlockHeaderPayload is quite long.
+	blockHdrSize = wire.MaxBlockHeaderPayload
+
+	// openTimeout is the amount of time to wait for the exclusive file lock
+	// on the database file before giving up, which happens when another
+	// process has the database open.
+	openTimeout = 5 * time.Second
+
+	// initialMmapSize is the initial size of the memory map of the database
+	// file.  Write transactions that need to grow the map have to wait for
+	// all read transactions to finish, so starting with a reasonably large
+	// map avoids most of those remaps while the database is small.
+	initialMmapSize = 64 * 1024 * 1024
+)
+
+var (
+	// byteOrder is the preferred byte order used through the database.
+	byteOrder = binary.LittleEndian
+
+	// metadataBucketName is the name of the top-level bbolt bucket that
+	// backs the metadata bucket exposed by transactions.
+	metadataBucketName = []byte("metadata")
+
+	// blocksBucketName is the name of the top-level bbolt bucket that maps
+	// block hashes to serialized blocks.
+	blocksBucketName = []byte("blocks")
+
+	// infoBucketName is the name of the top-level bbolt bucket used to
+	// store information about the database itself.
+	infoBucketName = []byte("info")
+
+	// networkKeyName is the key in the info bucket that houses the network
+	// the blocks in the database are for.
+	networkKeyName = []byte("network")
+)
+
+// Common error strings.
+const (
+	// errDbNotOpenStr is the text to use for the database.ErrDbNotOpen
+	// error code.
+	errDbNotOpenStr = "database is not open"
+
+	// errTxClosedStr is the text to use for the database.ErrTxClosed error
+	// code.
+	errTxClosedStr = "database tx is closed"
+)
+
+// makeDbErr creates a database.Error given a set of arguments.
+func makeDbErr(c database.ErrorCode, desc string, err error) database.Error {
+	return database.Error{ErrorCode: c, Description: desc, Err: err}
+}
+
+// convertErr converts the passed bbolt error into a database error with an
+// equivalent error code and the passed description.  It also sets the passed
+// error as the underlying error.
+func convertErr(desc string, boltErr error) database.Error {
+	// Use the driver-specific error code by default.  The code below will
+	// update this with the converted error if it's recognized.
+	var code = database.ErrDriverSpecific
+
+	switch boltErr {
+	// Database corruption errors.
+	case bolt.ErrInvalid, bolt.ErrChecksum, bolt.ErrVersionMismatch:
+		code = database.ErrCorruption
+
+	// Database open/create errors.
+	case bolt.ErrDatabaseNotOpen:
+		code = database.ErrDbNotOpen
+
+	// Transaction errors.
+	case bolt.ErrTxNotWritable, bolt.ErrDatabaseReadOnly:
+		code = database.ErrTxNotWritable
+	case bolt.ErrTxClosed:
+		code = database.ErrTxClosed
+
+	// Bucket and value errors.
+	case bolt.ErrBucketNotFound:
+		code = database.ErrBucketNotFound
+	case bolt.ErrBucketExists:
+		code = database.ErrBucketExists
+	case bolt.ErrBucketNameRequired:
+		code = database.ErrBucketNameRequired
+	case bolt.ErrKeyRequired:
+		code = database.ErrKeyRequired
+	case bolt.ErrIncompatibleValue, bolt.ErrKeyTooLarge:
+		code = database.ErrIncompatibleValue
+	}
+
+	return database.Error{ErrorCode: code, Description: desc, Err: boltErr}
+}
+
+// cursor is an internal type used to represent a cursor over key/value pairs
+// and nested buckets of a bucket and implements the database.Cursor interface.
+type cursor struct {
+	bucket *bucket
+	cursor *bolt.Cursor
+
+	// key and value are the pair the cursor is currently positioned at.
+	// They are both nil when the cursor is not positioned or exhausted.
+	key   []byte
+	value []byte
+
+	// deleted is set when the current pair was removed via Delete.  The
+	// underlying cursor is positioned at the pair that followed it, which
+	// is held by nextKey and nextValue, so the next move can continue from
+	// there without skipping any pairs.
+	deleted   bool
+	nextKey   []byte
+	nextValue []byte
+}
+
+// Enforce cursor implements the database.Cursor interface.
+var _ database.Cursor = (*cursor)(nil)
+
+// setPosition updates the pair the cursor is positioned at and returns whether
+// or not the pair exists.
+func (c *cursor) setPosition(k, v []byte) bool {
+	c.key, c.value = k, v
+	c.deleted, c.nextKey, c.nextValue = false, nil, nil
+	return k != nil
+}
+
+// Bucket returns the bucket the cursor was created for.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Bucket() database.Bucket {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return nil
+	}
+
+	return c.bucket
+}
+
+// Delete removes the current key/value pair the cursor is at without
+// invalidating the cursor.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrIncompatibleValue if attempted when the cursor points to a nested
+//     bucket
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Delete() error {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !c.bucket.tx.writable {
+		str := "deleting a value requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Error if the cursor is exhausted.
+	if c.key == nil {
+		str := "cursor is exhausted"
+		return makeDbErr(database.ErrIncompatibleValue, str, nil)
+	}
+
+	// The underlying cursor refuses to delete nested buckets.
+	key := make([]byte, len(c.key))
+	copy(key, c.key)
+	if err := c.cursor.Delete(); err != nil {
+		if err == bolt.ErrIncompatibleValue {
+			str := "buckets may not be deleted from a cursor"
+			return makeDbErr(database.ErrIncompatibleValue, str, err)
+		}
+		str := fmt.Sprintf("failed to delete key %q", key)
+		return convertErr(str, err)
+	}
+
+	// Reposition the underlying cursor at the pair that followed the
+	// deleted one since it does not track its position across deletes.
+	c.key, c.value = nil, nil
+	c.nextKey, c.nextValue = c.cursor.Seek(key)
+	c.deleted = true
+	return nil
+}
+
+// First positions the cursor at the first key/value pair and returns whether or
+// not the pair exists.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) First() bool {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return c.setPosition(nil, nil)
+	}
+
+	return c.setPosition(c.cursor.First())
+}
+
+// Last positions the cursor at the last key/value pair and returns whether or
+// not the pair exists.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Last() bool {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return c.setPosition(nil, nil)
+	}
+
+	return c.setPosition(c.cursor.Last())
+}
+
+// Next moves the cursor one key/value pair forward and returns whether or not
+// the pair exists.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Next() bool {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return c.setPosition(nil, nil)
+	}
+
+	// The underlying cursor is already at the pair that followed the
+	// deleted one.
+	if c.deleted {
+		return c.setPosition(c.nextKey, c.nextValue)
+	}
+
+	// Nothing to return if cursor is exhausted.
+	if c.key == nil {
+		return false
+	}
+
+	return c.setPosition(c.cursor.Next())
+}
+
+// Prev moves the cursor one key/value pair backward and returns whether or not
+// the pair exists.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Prev() bool {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return c.setPosition(nil, nil)
+	}
+
+	// The pair before the deleted one is the last pair when nothing
+	// followed it.
+	if c.deleted {
+		if c.nextKey == nil {
+			return c.setPosition(c.cursor.Last())
+		}
+		return c.setPosition(c.cursor.Prev())
+	}
+
+	// Nothing to return if cursor is exhausted.
+	if c.key == nil {
+		return false
+	}
+
+	return c.setPosition(c.cursor.Prev())
+}
+
+// Seek positions the cursor at the first key/value pair that is greater than or
+// equal to the passed seek key.  Returns false if no suitable key was found.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Seek(seek []byte) bool {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return c.setPosition(nil, nil)
+	}
+
+	return c.setPosition(c.cursor.Seek(seek))
+}
+
+// Key returns the current key the cursor is pointing to.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Key() []byte {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return nil
+	}
+
+	return c.key
+}
+
+// Value returns the current value the cursor is pointing to.  This will be nil
+// for nested buckets.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Value() []byte {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return nil
+	}
+
+	return c.value
+}
+
+// bucket is an internal type used to represent a collection of key/value pairs
+// and implements the database.Bucket interface.  It wraps a bbolt bucket.
+type bucket struct {
+	tx     *transaction
+	bucket *bolt.Bucket
+}
+
+// Enforce bucket implements the database.Bucket interface.
+var _ database.Bucket = (*bucket)(nil)
+
+// Bucket retrieves a nested bucket with the given key.  Returns nil if
+// the bucket does not exist.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Bucket(key []byte) database.Bucket {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return nil
+	}
+
+	childBucket := b.bucket.Bucket(key)
+	if childBucket == nil {
+		return nil
+	}
+	return &bucket{tx: b.tx, bucket: childBucket}
+}
+
+// CreateBucket creates and returns a new nested bucket with the given key.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBucketExists if the bucket already exists
+//   - ErrBucketNameRequired if the key is empty
+//   - ErrIncompatibleValue if the key is otherwise invalid for the particular
+//     implementation
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) CreateBucket(key []byte) (database.Bucket, error) {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !b.tx.writable {
+		str := "create bucket requires a writable database transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Ensure a key was provided.
+	if len(key) == 0 {
+		str := "create bucket requires a key"
+		return nil, makeDbErr(database.ErrBucketNameRequired, str, nil)
+	}
+
+	childBucket, err := b.bucket.CreateBucket(key)
+	if err != nil {
+		if err == bolt.ErrBucketExists {
+			str := "bucket already exists"
+			return nil, makeDbErr(database.ErrBucketExists, str, err)
+		}
+		str := fmt.Sprintf("failed to create bucket with key %q", key)
+		return nil, convertErr(str, err)
+	}
+	return &bucket{tx: b.tx, bucket: childBucket}, nil
+}
+
+// CreateBucketIfNotExists creates and returns a new nested bucket with the
+// given key if it does not already exist.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBucketNameRequired if the key is empty
+//   - ErrIncompatibleValue if the key is otherwise invalid for the particular
+//     implementation
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) CreateBucketIfNotExists(key []byte) (database.Bucket, error) {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !b.tx.writable {
+		str := "create bucket requires a writable database transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Return existing bucket if it already exists, otherwise create it.
+	if bucket := b.Bucket(key); bucket != nil {
+		return bucket, nil
+	}
+	return b.CreateBucket(key)
+}
+
+// DeleteBucket removes a nested bucket with the given key.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBucketNotFound if the specified bucket does not exist
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) DeleteBucket(key []byte) error {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !b.tx.writable {
+		str := "delete bucket requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Removing the bucket also removes all nested buckets and their keys.
+	if err := b.bucket.DeleteBucket(key); err != nil {
+		if err == bolt.ErrBucketNotFound {
+			str := fmt.Sprintf("bucket %q does not exist", key)
+			return makeDbErr(database.ErrBucketNotFound, str, err)
+		}
+		str := fmt.Sprintf("failed to delete bucket %q", key)
+		return convertErr(str, err)
+	}
+	return nil
+}
+
+// Cursor returns a new cursor, allowing for iteration over the bucket's
+// key/value pairs and nested buckets in forward or backward order.
+//
+// You must seek to a position using the First, Last, or Seek functions before
+// calling the Next, Prev, Key, or Value functions.  Failure to do so will
+// result in the same return values as an exhausted cursor, which is false for
+// the Prev and Next functions and nil for Key and Value functions.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Cursor() database.Cursor {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return &cursor{bucket: b}
+	}
+
+	return &cursor{bucket: b, cursor: b.bucket.Cursor()}
+}
+
+// ForEach invokes the passed function with every key/value pair in the bucket.
+// This does not include nested buckets or the key/value pairs within those
+// nested buckets.
+//
+// WARNING: It is not safe to mutate data while iterating with this method.
+// Doing so may cause the underlying cursor to be invalidated and return
+// unexpected keys and/or values.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// NOTE: The values returned by this function are only valid during a
+// transaction.  Attempting to access them after a transaction has ended will
+// likely result in an access violation.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) ForEach(fn func(k, v []byte) error) error {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Nested buckets are the only entries with a nil value.
+	return b.bucket.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		return fn(k, v)
+	})
+}
+
+// ForEachBucket invokes the passed function with the key of every nested bucket
+// in the current bucket.  This does not include any nested buckets within those
+// nested buckets.
+//
+// WARNING: It is not safe to mutate data while iterating with this method.
+// Doing so may cause the underlying cursor to be invalidated and return
+// unexpected keys.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// NOTE: The values returned by this function are only valid during a
+// transaction.  Attempting to access them after a transaction has ended will
+// likely result in an access violation.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) ForEachBucket(fn func(k []byte) error) error {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Nested buckets are the only entries with a nil value.
+	return b.bucket.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return nil
+		}
+		return fn(k)
+	})
+}
+
+// Writable returns whether or not the bucket is writable.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Writable() bool {
+	return b.tx.writable
+}
+
+// Put saves the specified key/value pair to the bucket.  Keys that do not
+// already exist are added and keys that already exist are overwritten.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrKeyRequired if the key is empty
+//   - ErrIncompatibleValue if the key is the same as an existing bucket
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Put(key, value []byte) error {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !b.tx.writable {
+		str := "setting a key requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Ensure a key was provided.
+	if len(key) == 0 {
+		str := "put requires a key"
+		return makeDbErr(database.ErrKeyRequired, str, nil)
+	}
+
+	// Store an empty value rather than nil since nested buckets are the
+	// only entries with nil values.
+	if value == nil {
+		value = []byte{}
+	}
+	if err := b.bucket.Put(key, value); err != nil {
+		str := fmt.Sprintf("failed to put key %q", key)
+		return convertErr(str, err)
+	}
+	return nil
+}
+
+// Get returns the value for the given key.  Returns nil if the key does not
+// exist in this bucket.  An empty slice is returned for keys that exist but
+// have no value assigned.
+//
+// NOTE: The value returned by this function is only valid during a transaction.
+// Attempting to access it after a transaction has ended results in undefined
+// behavior.  Additionally, the value must NOT be modified by the caller.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Get(key []byte) []byte {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return nil
+	}
+
+	// Nothing to return if there is no key.
+	if len(key) == 0 {
+		return nil
+	}
+
+	return b.bucket.Get(key)
+}
+
+// Delete removes the specified key from the bucket.  Deleting a key that does
+// not exist does not return an error.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrKeyRequired if the key is empty
+//   - ErrIncompatibleValue if the key is the same as an existing bucket
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Delete(key []byte) error {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !b.tx.writable {
+		str := "deleting a value requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Nothing to do if there is no key.
+	if len(key) == 0 {
+		return nil
+	}
+
+	if err := b.bucket.Delete(key); err != nil {
+		str := fmt.Sprintf("failed to delete key %q", key)
+		return convertErr(str, err)
+	}
+	return nil
+}
+
+// transaction represents a database transaction.  It can either be read-only or
+// read-write and implements the database.Tx interface.  It wraps a bbolt
+// transaction which provides both the metadata and the block storage.
+type transaction struct {
+	managed    bool         // Is the transaction managed?
+	closed     bool         // Is the transaction closed?
+	writable   bool         // Is the transaction writable?
+	db         *db          // DB instance the tx was created from.
+	boltTx     *bolt.Tx     // Underlying bbolt transaction.
+	metaBucket *bucket      // The root metadata bucket.
+	blocks     *bolt.Bucket // Bucket that maps block hashes to blocks.
+}
+
+// Enforce transaction implements the database.Tx interface.
+var _ database.Tx = (*transaction)(nil)
+
+// checkClosed returns an error if the the database or transaction is closed.
+func (tx *transaction) checkClosed() error {
+	// The transaction is no longer valid if it has been closed.
+	if tx.closed {
+		return makeDbErr(database.ErrTxClosed, errTxClosedStr, nil)
+	}
+
+	return nil
+}
+
+// Metadata returns the top-most bucket for all metadata storage.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) Metadata() database.Bucket {
+	return tx.metaBucket
+}
+
+// StoreBlock stores the provided block into the database.  There are no checks
+// to ensure the block connects to a previous block, contains double spends, or
+// any additional functionality such as transaction indexing.  It simply stores
+// the block in the database.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockExists when the block hash already exists
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) StoreBlock(block *hcutil.Block) error {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "store block requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Reject the block if it already exists.
+	blockHash := block.Hash()
+	if tx.blocks.Get(blockHash[:]) != nil {
+		str := fmt.Sprintf("block %s already exists", blockHash)
+		return makeDbErr(database.ErrBlockExists, str, nil)
+	}
+
+	blockBytes, err := block.Bytes()
+	if err != nil {
+		str := fmt.Sprintf("failed to get serialized bytes for block %s",
+			blockHash)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	if err := tx.blocks.Put(blockHash[:], blockBytes); err != nil {
+		str := fmt.Sprintf("failed to store block %s", blockHash)
+		return convertErr(str, err)
+	}
+	log.Tracef("Stored block %s", blockHash)
+
+	return nil
+}
+
+// HasBlock returns whether or not a block with the given hash exists in the
+// database.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) HasBlock(hash *chainhash.Hash) (bool, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return false, err
+	}
+
+	return tx.blocks.Get(hash[:]) != nil, nil
+}
+
+// HasBlocks returns whether or not the blocks with the provided hashes
+// exist in the database.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) HasBlocks(hashes []chainhash.Hash) ([]bool, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	results := make([]bool, len(hashes))
+	for i := range hashes {
+		results[i] = tx.blocks.Get(hashes[i][:]) != nil
+	}
+
+	return results, nil
+}
+
+// fetchBlock returns the serialized block for the provided hash.  It will
+// return ErrBlockNotFound if there is no entry.
+func (tx *transaction) fetchBlock(hash *chainhash.Hash) ([]byte, error) {
+	blockBytes := tx.blocks.Get(hash[:])
+	if blockBytes == nil {
+		str := fmt.Sprintf("block %s does not exist", hash)
+		return nil, makeDbErr(database.ErrBlockNotFound, str, nil)
+	}
+
+	return blockBytes, nil
+}
+
+// FetchBlockHeader returns the raw serialized bytes for the block header
+// identified by the given hash.  The raw bytes are in the format returned by
+// Serialize on a wire.BlockHeader.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the requested block hash does not exist
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a
+// database transaction.  Attempting to access it after a transaction
+// has ended results in undefined behavior.  This constraint prevents
+// additional data copies and allows support for memory-mapped database
+// implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlockHeader(hash *chainhash.Hash) ([]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	blockBytes, err := tx.fetchBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(blockBytes) < blockHdrSize {
+		str := fmt.Sprintf("block %s is shorter than a block header",
+			hash)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	// Notice the use of the cap on the subslice to prevent the caller from
+	// accidentally appending into the db data.
+	return blockBytes[0:blockHdrSize:blockHdrSize], nil
+}
+
+// FetchBlockHeaders returns the raw serialized bytes for the block headers
+// identified by the given hashes.  The raw bytes are in the format returned by
+// Serialize on a wire.BlockHeader.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the any of the requested block hashes do not exist
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a database
+// transaction.  Attempting to access it after a transaction has ended results
+// in undefined behavior.  This constraint prevents additional data copies and
+// allows support for memory-mapped database implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlockHeaders(hashes []chainhash.Hash) ([][]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	headers := make([][]byte, len(hashes))
+	for i := range hashes {
+		header, err := tx.FetchBlockHeader(&hashes[i])
+		if err != nil {
+			return nil, err
+		}
+		headers[i] = header
+	}
+
+	return headers, nil
+}
+
+// FetchBlock returns the raw serialized bytes for the block identified by the
+// given hash.  The raw bytes are in the format returned by Serialize on a
+// wire.MsgBlock.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the requested block hash does not exist
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a
+// database transaction.  Attempting to access it after a transaction
+// has ended results in undefined behavior.  This constraint prevents
+// additional data copies and allows support for memory-mapped database
+// implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlock(hash *chainhash.Hash) ([]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	return tx.fetchBlock(hash)
+}
+
+// FetchBlocks returns the raw serialized bytes for the blocks identified by the
+// given hashes.  The raw bytes are in the format returned by Serialize on a
+// wire.MsgBlock.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if any of the requested block hashed do not exist
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a database
+// transaction.  Attempting to access it after a transaction has ended results
+// in undefined behavior.  This constraint prevents additional data copies and
+// allows support for memory-mapped database implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlocks(hashes []chainhash.Hash) ([][]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	blocks := make([][]byte, len(hashes))
+	for i := range hashes {
+		blockBytes, err := tx.fetchBlock(&hashes[i])
+		if err != nil {
+			return nil, err
+		}
+		blocks[i] = blockBytes
+	}
+
+	return blocks, nil
+}
+
+// fetchBlockRegion returns the raw serialized bytes for the given block region
+// after ensuring it is within the bounds of the block.
+func (tx *transaction) fetchBlockRegion(region *database.BlockRegion) ([]byte, error) {
+	blockBytes, err := tx.fetchBlock(region.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure the region is within the bounds of the block.
+	endOffset := region.Offset + region.Len
+	blockLen := uint32(len(blockBytes))
+	if endOffset < region.Offset || endOffset > blockLen {
+		str := fmt.Sprintf("block %s region offset %d, length %d "+
+			"exceeds block length of %d", region.Hash,
+			region.Offset, region.Len, blockLen)
+		return nil, makeDbErr(database.ErrBlockRegionInvalid, str, nil)
+	}
+
+	return blockBytes[region.Offset:endOffset:endOffset], nil
+}
+
+// FetchBlockRegion returns the raw serialized bytes for the given block region.
+//
+// For example, it is possible to directly extract transactions and/or
+// scripts from a block with this function.  Depending on the backend
+// implementation, this can provide significant savings by avoiding the
+// need to load entire blocks.
+//
+// The raw bytes are in the format returned by Serialize on a wire.MsgBlock and
+// the Offset field in the provided BlockRegion is zero-based and relative to
+// the start of the block (byte 0).
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the requested block hash does not exist
+//   - ErrBlockRegionInvalid if the region exceeds the bounds of the associated
+//     block
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a database
+// transaction.  Attempting to access it after a transaction has ended results
+// in undefined behavior.  This constraint prevents additional data copies and
+// allows support for memory-mapped database implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlockRegion(region *database.BlockRegion) ([]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	return tx.fetchBlockRegion(region)
+}
+
+// FetchBlockRegions returns the raw serialized bytes for the given block
+// regions.
+//
+// For example, it is possible to directly extract transactions and/or scripts
+// from various blocks with this function.  Depending on the backend
+// implementation, this can provide significant savings by avoiding the need to
+// load entire blocks.
+//
+// The raw bytes are in the format returned by Serialize on a wire.MsgBlock and
+// the Offset fields in the provided BlockRegions are zero-based and relative to
+// the start of the block (byte 0).
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if any of the request block hashes do not exist
+//   - ErrBlockRegionInvalid if one or more region exceed the bounds of the
+//     associated block
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a database
+// transaction.  Attempting to access it after a transaction has ended results
+// in undefined behavior.  This constraint prevents additional data copies and
+// allows support for memory-mapped database implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	blockRegions := make([][]byte, len(regions))
+	for i := range regions {
+		regionBytes, err := tx.fetchBlockRegion(&regions[i])
+		if err != nil {
+			return nil, err
+		}
+		blockRegions[i] = regionBytes
+	}
+
+	return blockRegions, nil
+}
+
+// close marks the transaction closed then releases the transaction read lock
+// and the write lock when the transaction is writable.  The underlying bbolt
+// transaction must already be committed or rolled back.
+func (tx *transaction) close() {
+	tx.closed = true
+	tx.boltTx = nil
+	tx.metaBucket.bucket = nil
+	tx.blocks = nil
+
+	tx.db.closeLock.RUnlock()
+
+	// Release the writer lock for writable transactions to unblock any
+	// other write transaction which are possibly waiting.
+	if tx.writable {
+		tx.db.writeLock.Unlock()
+	}
+}
+
+// Commit commits all changes that have been made to the root metadata bucket
+// and all of its sub-buckets, as well as the stored blocks, to persistent
+// storage.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) Commit() error {
+	// Prevent commits on managed transactions.
+	if tx.managed {
+		_ = tx.boltTx.Rollback()
+		tx.close()
+		panic("managed transaction commit not allowed")
+	}
+
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Regardless of whether the commit succeeds, the transaction is closed
+	// on return.
+	defer tx.close()
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		_ = tx.boltTx.Rollback()
+		str := "Commit requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// The underlying transaction is rolled back if the commit fails.
+	if err := tx.boltTx.Commit(); err != nil {
+		return convertErr("failed to commit transaction", err)
+	}
+	return nil
+}
+
+// Rollback undoes all changes that have been made to the root bucket and all of
+// its sub-buckets.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) Rollback() error {
+	// Prevent rollbacks on managed transactions.
+	if tx.managed {
+		_ = tx.boltTx.Rollback()
+		tx.close()
+		panic("managed transaction rollback not allowed")
+	}
+
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	err := tx.boltTx.Rollback()
+	tx.close()
+	if err != nil {
+		return convertErr("failed to roll back transaction", err)
+	}
+	return nil
+}
+
+// db represents a collection of namespaces which are persisted and implements
+// the database.DB interface.  All database access is performed through
+// transactions which are obtained through the specific Namespace.
+type db struct {
+	writeLock sync.Mutex   // Limit to one write transaction at a time.
+	closeLock sync.RWMutex // Make database close block while txns active.
+	closed    bool         // Is the database closed?
+	bdb       *bolt.DB     // Underlying bbolt database.
+}
+
+// Enforce db implements the database.DB interface.
+var _ database.DB = (*db)(nil)
+
+// Type returns the database driver type the current database instance was
+// created with.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Type() string {
+	return dbType
+}
+
+// begin is the implementation function for the Begin database method.  See its
+// documentation for more details.
+//
+// This function is only separate because it returns the internal transaction
+// which is used by the managed transaction code while the database method
+// returns the interface.
+func (db *db) begin(writable bool) (*transaction, error) {
+	// Whenever a new writable transaction is started, grab the write lock
+	// to ensure only a single write transaction can be active at the same
+	// time.  This lock will not be released until the transaction is
+	// closed (via Rollback or Commit).
+	if writable {
+		db.writeLock.Lock()
+	}
+
+	// Whenever a new transaction is started, grab a read lock against the
+	// database to ensure Close will wait for the transaction to finish.
+	// This lock will not be released until the transaction is closed (via
+	// Rollback or Commit).
+	db.closeLock.RLock()
+	if db.closed {
+		db.closeLock.RUnlock()
+		if writable {
+			db.writeLock.Unlock()
+		}
+		return nil, makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr,
+			nil)
+	}
+
+	boltTx, err := db.bdb.Begin(writable)
+	if err != nil {
+		db.closeLock.RUnlock()
+		if writable {
+			db.writeLock.Unlock()
+		}
+
+		return nil, convertErr("failed to begin transaction", err)
+	}
+
+	tx := &transaction{
+		writable: writable,
+		db:       db,
+		boltTx:   boltTx,
+		blocks:   boltTx.Bucket(blocksBucketName),
+	}
+	tx.metaBucket = &bucket{tx: tx, bucket: boltTx.Bucket(metadataBucketName)}
+	return tx, nil
+}
+
+// Begin starts a transaction which is either read-only or read-write depending
+// on the specified flag.  Multiple read-only transactions can be started
+// simultaneously while only a single read-write transaction can be started at a
+// time.  The call will block when starting a read-write transaction when one is
+// already open.
+//
+// NOTE: The transaction must be closed by calling Rollback or Commit on it when
+// it is no longer needed.  Failure to do so will result in unclaimed memory and
+// will prevent write transactions that need to grow the database file from
+// completing.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Begin(writable bool) (database.Tx, error) {
+	return db.begin(writable)
+}
+
+// rollbackOnPanic rolls the passed transaction back if the code in the calling
+// function panics.  This is needed since the mutex on a transaction must be
+// released and a panic in called code would prevent that from happening.
+//
+// NOTE: This can only be handled manually for managed transactions since they
+// control the life-cycle of the transaction.  As the documentation on Begin
+// calls out, callers opting to use manual transactions will have to ensure the
+// transaction is rolled back on panic if it desires that functionality as well
+// or the database will fail to close since the read-lock will never be
+// released.
+func rollbackOnPanic(tx *transaction) {
+	if err := recover(); err != nil {
+		tx.managed = false
+		_ = tx.Rollback()
+		panic(err)
+	}
+}
+
+// View invokes the passed function in the context of a managed read-only
+// transaction with the root bucket for the namespace.  Any errors returned from
+// the user-supplied function are returned from this function.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) View(fn func(database.Tx) error) error {
+	// Start a read-only transaction.
+	tx, err := db.begin(false)
+	if err != nil {
+		return err
+	}
+
+	// Since the user-provided function might panic, ensure the transaction
+	// releases all mutexes and resources.  There is no guarantee the caller
+	// won't use recover and keep going.  Thus, the database must still be
+	// in a usable state on panics due to caller issues.
+	defer rollbackOnPanic(tx)
+
+	tx.managed = true
+	err = fn(tx)
+	tx.managed = false
+	if err != nil {
+		// The error is ignored here because nothing was written yet
+		// and regardless of a rollback failure, the tx is closed now
+		// anyways.
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Rollback()
+}
+
+// Update invokes the passed function in the context of a managed read-write
+// transaction with the root bucket for the namespace.  Any errors returned from
+// the user-supplied function will cause the transaction to be rolled back and
+// are returned from this function.  Otherwise, the transaction is committed
+// when the user-supplied function returns a nil error.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Update(fn func(database.Tx) error) error {
+	// Start a read-write transaction.
+	tx, err := db.begin(true)
+	if err != nil {
+		return err
+	}
+
+	// Since the user-provided function might panic, ensure the transaction
+	// releases all mutexes and resources.  There is no guarantee the caller
+	// won't use recover and keep going.  Thus, the database must still be
+	// in a usable state on panics due to caller issues.
+	defer rollbackOnPanic(tx)
+
+	tx.managed = true
+	err = fn(tx)
+	tx.managed = false
+	if err != nil {
+		// The error is ignored here because nothing was written yet
+		// and regardless of a rollback failure, the tx is closed now
+		// anyways.
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Close cleanly shuts down the database and syncs all data.  It will block
+// until all database transactions have been finalized (rolled back or
+// committed).
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Close() error {
+	// Since all transactions have a read lock on this mutex, this will
+	// cause Close to wait for all readers to complete.
+	db.closeLock.Lock()
+	defer db.closeLock.Unlock()
+
+	if db.closed {
+		return makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+	db.closed = true
+
+	if err := db.bdb.Close(); err != nil {
+		return convertErr("failed to close database", err)
+	}
+	return nil
+}
+
+// filesExists reports whether the named file or directory exists.
+func fileExists(name string) bool {
+	if _, err := os.Stat(name); err != nil {
+		if os.IsNotExist(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// initDB creates the top-level buckets used by the package and records the
+// network the database is for.
+func initDB(bdb *bolt.DB, network wire.CurrencyNet) error {
+	err := bdb.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{metadataBucketName,
+			blocksBucketName, infoBucketName} {
+
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+
+		var serializedNet [4]byte
+		byteOrder.PutUint32(serializedNet[:], uint32(network))
+		return tx.Bucket(infoBucketName).Put(networkKeyName,
+			serializedNet[:])
+	})
+	if err != nil {
+		str := fmt.Sprintf("failed to initialize database: %v", err)
+		return convertErr(str, err)
+	}
+
+	return nil
+}
+
+// checkDB ensures the top-level buckets used by the package exist and that the
+// database is for the passed network.
+func checkDB(bdb *bolt.DB, network wire.CurrencyNet) error {
+	return bdb.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{metadataBucketName,
+			blocksBucketName, infoBucketName} {
+
+			if tx.Bucket(name) == nil {
+				str := fmt.Sprintf("database is missing the %q "+
+					"bucket", name)
+				return makeDbErr(database.ErrCorruption, str, nil)
+			}
+		}
+
+		// The network must match the requested network, otherwise
+		// somebody probably pointed the database path at the data for
+		// the wrong network.
+		serializedNet := tx.Bucket(infoBucketName).Get(networkKeyName)
+		if len(serializedNet) != 4 {
+			str := "database network is missing or malformed"
+			return makeDbErr(database.ErrCorruption, str, nil)
+		}
+		if dbNet := byteOrder.Uint32(serializedNet); dbNet != uint32(network) {
+			str := fmt.Sprintf("database is for the wrong network - "+
+				"got %d, want %d", dbNet, uint32(network))
+			return makeDbErr(database.ErrDriverSpecific, str, nil)
+		}
+
+		return nil
+	})
+}
+
+// openDB opens the database at the provided path.  database.ErrDbDoesNotExist
+// is returned if the database doesn't exist and the create flag is not set.
+func openDB(dbPath string, network wire.CurrencyNet, create bool) (database.DB, error) {
+	// Error if the database doesn't exist and the create flag is not set
+	// or it already exists and the create flag is set.
+	dbFilePath := filepath.Join(dbPath, dbFileName)
+	dbExists := fileExists(dbFilePath)
+	if !create && !dbExists {
+		str := fmt.Sprintf("database %q does not exist", dbFilePath)
+		return nil, makeDbErr(database.ErrDbDoesNotExist, str, nil)
+	}
+	if create && dbExists {
+		str := fmt.Sprintf("database %q already exists", dbFilePath)
+		return nil, makeDbErr(database.ErrDbExists, str, nil)
+	}
+
+	// Ensure the full path to the database exists.
+	if !dbExists {
+		// The error can be ignored here since the call to bolt.Open
+		// will fail if the directory couldn't be created.
+		_ = os.MkdirAll(dbPath, 0700)
+	}
+
+	// Open the database file (will create it if needed).  The open times
+	// out when another process holds the lock on the file.
+	opts := bolt.Options{
+		Timeout:         openTimeout,
+		InitialMmapSize: initialMmapSize,
+	}
+	bdb, err := bolt.Open(dbFilePath, 0600, &opts)
+	if err != nil {
+		str := fmt.Sprintf("failed to open database %q: %v", dbFilePath,
+			err)
+		return nil, convertErr(str, err)
+	}
+
+	if create {
+		err = initDB(bdb, network)
+	} else {
+		err = checkDB(bdb, network)
+	}
+	if err != nil {
+		_ = bdb.Close()
+		return nil, err
+	}
+
+	return &db{bdb: bdb}, nil
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package bboltdb implements a driver for the database package that uses bbolt
+for both the backing metadata and block storage.
+
+This driver stores everything in a single copy-on-write B+tree file, which
+avoids the compactions of the leveldb based ffldb driver at the cost of larger
+writes for each committed transaction.  It is mainly provided to compare the
+behavior of the storage backends.  Existing ffldb block databases can be copied
+to it with the migrate command of dbtool.
+
+Write transactions that need to grow the memory map of the database file wait
+for all open read transactions to finish, so read transactions must not be kept
+open while waiting on a write transaction.
+
+Usage
+
+This package is a driver to the database package and provides the database type
+of "bboltdb".  The parameters the Open and Create functions take are the
+database path as a string and the block network:
+
+	db, err := database.Open("bboltdb", "path/to/database", wire.MainNet)
+	if err != nil {
+		// Handle error
+	}
+
+	db, err := database.Create("bboltdb", "path/to/database", wire.MainNet)
+	if err != nil {
+		// Handle error
+	}
+*/
+package bboltdb
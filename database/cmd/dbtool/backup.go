@@ -0,0 +1,194 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/james-ray/hcd/database"
+)
+
+// backupCmd defines the configuration options for the backup command.
+type backupCmd struct{}
+
+// restoreCmd defines the configuration options for the restore command.
+type restoreCmd struct{}
+
+var (
+	// backupCfg defines the configuration options for the backup command.
+	backupCfg = backupCmd{}
+
+	// restoreCfg defines the configuration options for the restore
+	// command.
+	restoreCfg = restoreCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *backupCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if len(args) < 1 {
+		return errors.New("required backup directory parameter not " +
+			"specified")
+	}
+	backupPath := args[0]
+
+	// Open the existing database.  Unlike the other commands, it is not
+	// created when it does not exist.
+	dbPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+cfg.DbType)
+	log.Infof("Loading block database from '%s'", dbPath)
+	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	startTime := time.Now()
+	var manifest *database.BackupManifest
+	err = db.View(func(tx database.Tx) error {
+		backuper, ok := tx.(database.Backuper)
+		if !ok {
+			return fmt.Errorf("the %s database backend does not "+
+				"support backups", cfg.DbType)
+		}
+
+		var err error
+		manifest, err = backuper.Backup(backupPath)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, file := range manifest.Files {
+		size += file.Size
+	}
+	log.Infof("Backed up %d files (%d bytes) to '%s' in %v",
+		len(manifest.Files), size, backupPath, time.Since(startTime))
+	return nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *backupCmd) Usage() string {
+	return "<backup-dir>"
+}
+
+// copyBackupFile copies the file with the passed name relative to the backup
+// directory to the same relative path in the destination directory.
+func copyBackupFile(backupPath, dstPath, name string) error {
+	relPath := filepath.FromSlash(name)
+	src, err := os.Open(filepath.Join(backupPath, relPath))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dstFilePath := filepath.Join(dstPath, relPath)
+	if err := os.MkdirAll(filepath.Dir(dstFilePath), 0700); err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(dstFilePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *restoreCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if len(args) < 1 {
+		return errors.New("required backup directory parameter not " +
+			"specified")
+	}
+	backupPath := args[0]
+
+	// Ensure the backup is intact and matches the configured database
+	// before touching the data directory.
+	log.Infof("Verifying backup in '%s'", backupPath)
+	manifest, err := database.VerifyBackup(backupPath)
+	if err != nil {
+		return err
+	}
+	if manifest.DbType != cfg.DbType {
+		return fmt.Errorf("the backup is of a %s database, but the "+
+			"specified database type is [%v]", manifest.DbType,
+			cfg.DbType)
+	}
+	if manifest.Network != uint32(activeNetParams.Net) {
+		return fmt.Errorf("the backup is for network %v, but the "+
+			"active network is %v", manifest.Network,
+			activeNetParams.Net)
+	}
+
+	// Refuse to overwrite an existing database.
+	dbPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+cfg.DbType)
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		return fmt.Errorf("the block database '%s' already exists -- "+
+			"it must be moved or removed before restoring", dbPath)
+	}
+
+	// Copy the files to a temporary directory which is renamed once
+	// everything is copied so an interrupted restore never leaves a
+	// partial database behind.
+	tmpPath := dbPath + ".restore"
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return err
+	}
+	startTime := time.Now()
+	for i, file := range manifest.Files {
+		log.Infof("Restoring %s (%d of %d)", file.Name, i+1,
+			len(manifest.Files))
+		err := copyBackupFile(backupPath, tmpPath, file.Name)
+		if err != nil {
+			_ = os.RemoveAll(tmpPath)
+			return err
+		}
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		_ = os.RemoveAll(tmpPath)
+		return err
+	}
+
+	// Ensure the restored database can be opened.
+	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	log.Infof("Restored the block database to '%s' in %v", dbPath,
+		time.Since(startTime))
+	return nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *restoreCmd) Usage() string {
+	return "<backup-dir>"
+}
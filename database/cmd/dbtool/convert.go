@@ -0,0 +1,160 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/database"
+)
+
+// convertBlocksCmd defines the configuration options for the convertblocks
+// command.
+type convertBlocksCmd struct {
+	Compression string `long:"compression" description:"Compression to use for the converted blocks {none, snappy}"`
+	BatchSize   int    `long:"batchsize" description:"Maximum number of megabytes of data to copy in each transaction"`
+}
+
+var (
+	// convertBlocksCfg defines the configuration options for the command.
+	convertBlocksCfg = convertBlocksCmd{
+		Compression: "snappy",
+		BatchSize:   64,
+	}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *convertBlocksCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Only ffldb databases store their blocks in flat files which can be
+	// compressed.
+	if cfg.DbType != "ffldb" {
+		str := "only ffldb block databases can be converted -- the " +
+			"specified database type is [%v]"
+		return fmt.Errorf(str, cfg.DbType)
+	}
+	compression, err := database.ParseBlockCompression(cmd.Compression)
+	if err != nil {
+		return err
+	}
+	if cmd.BatchSize <= 0 {
+		return errors.New("the batch size must be positive")
+	}
+
+	// Open the existing database.  Unlike the other commands, it is not
+	// created when it does not exist.
+	dbPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+cfg.DbType)
+	log.Infof("Loading block database from '%s'", dbPath)
+	src, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if src != nil {
+			src.Close()
+		}
+	}()
+
+	// The blocks are rewritten to a new database in a temporary directory
+	// which replaces the existing one once everything is copied, so there
+	// must be enough free disk space for a second copy of the database.
+	tmpPath := dbPath + ".convert"
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return err
+	}
+	log.Infof("Converting the blocks to %v compression in '%s'",
+		compression, tmpPath)
+	dst, err := database.Create(cfg.DbType, tmpPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	removeDst := func() {
+		dst.Close()
+		_ = os.RemoveAll(tmpPath)
+	}
+	err = dst.Update(func(tx database.Tx) error {
+		compressor, ok := tx.(database.BlockCompressor)
+		if !ok {
+			return fmt.Errorf("the %s database backend does not "+
+				"support block compression", cfg.DbType)
+		}
+		return compressor.SetBlockCompression(compression)
+	})
+	if err != nil {
+		removeDst()
+		return err
+	}
+
+	// Stop the conversion between batches on Ctrl+C.  The existing
+	// database is left untouched.
+	quit := make(chan struct{})
+	addInterruptHandler(func() {
+		close(quit)
+	})
+
+	m := &migrator{
+		dst:       dst,
+		quit:      quit,
+		batchSize: cmd.BatchSize * 1024 * 1024,
+	}
+	startTime := time.Now()
+	if err := m.copyFfldb(src); err != nil {
+		removeDst()
+		return err
+	}
+
+	// Older software which does not understand compressed blocks must
+	// not open the converted database, so its chain state is upgraded to
+	// the version such software refuses to open.
+	if compression != database.BlockCompressionNone {
+		err := dst.Update(blockchain.UpgradeForCompressedBlocks)
+		if err != nil {
+			removeDst()
+			return err
+		}
+	}
+
+	// Replace the existing database with the converted one.  The existing
+	// database is renamed first so it can be recovered manually should the
+	// replacement fail.
+	if err := dst.Close(); err != nil {
+		_ = os.RemoveAll(tmpPath)
+		return err
+	}
+	err = src.Close()
+	src = nil
+	if err != nil {
+		_ = os.RemoveAll(tmpPath)
+		return err
+	}
+	oldPath := dbPath + ".old"
+	if err := os.RemoveAll(oldPath); err != nil {
+		return err
+	}
+	if err := os.Rename(dbPath, oldPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		return fmt.Errorf("unable to move the converted database into "+
+			"place -- the original database was moved to '%s': %v",
+			oldPath, err)
+	}
+	if err := os.RemoveAll(oldPath); err != nil {
+		return err
+	}
+
+	log.Infof("Converted %d keys and %d blocks in %v", m.numKeys,
+		m.numBlocks, time.Since(startTime))
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/james-ray/hcd/chaincfg"
 	"github.com/james-ray/hcd/database"
+	_ "github.com/james-ray/hcd/database/bboltdb"
 	_ "github.com/james-ray/hcd/database/ffldb"
 	"github.com/james-ray/hcd/hcutil"
 )
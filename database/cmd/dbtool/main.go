@@ -12,6 +12,8 @@ import (
 	"strings"
 
 	"github.com/btcsuite/btclog"
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/stake"
 	"github.com/james-ray/hcd/database"
 	flags "github.com/jessevdk/go-flags"
 )
@@ -68,6 +70,9 @@ func realMain() error {
 	dbLog := backendLogger.Logger("BCDB")
 	dbLog.SetLevel(btclog.LevelDebug)
 	database.UseLogger(dbLog)
+	chainLog := backendLogger.Logger("CHAN")
+	blockchain.UseLogger(chainLog)
+	stake.UseLogger(chainLog)
 
 	// Setup the parser options and commands.
 	appName := filepath.Base(os.Args[0])
@@ -90,6 +95,36 @@ func realMain() error {
 	parser.AddCommand("fetchblockregion",
 		"Fetch the specified block region from the database", "",
 		&blockRegionCfg)
+	parser.AddCommand("migrate",
+		"Copy the block database to another database backend",
+		"Copy the metadata and blocks of an existing ffldb block "+
+			"database to a new block database using the backend "+
+			"given by --todbtype.", &migrateCfg)
+	parser.AddCommand("backup",
+		"Write a consistent copy of the block database to a directory",
+		"Write a consistent copy of the block database to a new "+
+			"directory along with a manifest of the size and hash "+
+			"of every file.  The backupchain RPC creates the same "+
+			"backup while hcd is running.", &backupCfg)
+	parser.AddCommand("restore",
+		"Restore the block database from a backup directory",
+		"Verify the backup in the directory against its manifest and "+
+			"copy it into place as the block database, which must "+
+			"not exist.", &restoreCfg)
+	parser.AddCommand("verify",
+		"Verify the integrity of the block files and chain state",
+		"Reread every block in the block index from the block files "+
+			"and verify the chain state buckets, reporting any "+
+			"inconsistencies.  The ones that can be fixed without "+
+			"reprocessing the chain are repaired when --repair is "+
+			"specified.", &verifyCfg)
+	parser.AddCommand("convertblocks",
+		"Rewrite the ffldb block files with another compression",
+		"Rewrite all blocks of an existing ffldb block database with "+
+			"the compression given by --compression and enable it "+
+			"for blocks stored afterwards.  The conversion needs "+
+			"enough free disk space for a second copy of the "+
+			"database.", &convertBlocksCfg)
 
 	// Parse command line and invoke the Execute function for the specified
 	// command.
@@ -0,0 +1,348 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+	"github.com/james-ray/hcd/hcutil"
+)
+
+// migrateCmd defines the configuration options for the migrate command.
+type migrateCmd struct {
+	ToDbType  string `long:"todbtype" description:"Database backend to migrate the block database to"`
+	BatchSize int    `long:"batchsize" description:"Maximum number of megabytes of data to copy in each destination database transaction"`
+}
+
+var (
+	// migrateCfg defines the configuration options for the command.
+	migrateCfg = migrateCmd{
+		ToDbType:  "bboltdb",
+		BatchSize: 64,
+	}
+
+	// errMigrateInterrupted is returned when the migration is interrupted
+	// by the user.
+	errMigrateInterrupted = errors.New("migration interrupted -- the " +
+		"partially migrated database must be removed before trying again")
+
+	// ffldbBlockIdxName is the name of the bucket the ffldb driver uses
+	// internally to track the stored blocks.  It is the only way to
+	// enumerate the blocks since the database interface does not provide
+	// one.
+	ffldbBlockIdxName = []byte("ffldb-blockidx")
+
+	// ffldbWriteLocName is the name of the metadata key the ffldb driver
+	// uses internally to store the current write cursor of the block
+	// files.
+	ffldbWriteLocName = []byte("ffldb-writeloc")
+
+	// ffldbCompressionName is the name of the metadata key the ffldb
+	// driver uses internally to store the block compression setting.
+	ffldbCompressionName = []byte("ffldb-compression")
+
+	// ffldbInternalKeys are the top-level metadata keys used internally by
+	// the ffldb driver which must not be copied to other databases.
+	ffldbInternalKeys = [][]byte{ffldbBlockIdxName, ffldbWriteLocName,
+		ffldbCompressionName}
+)
+
+// migrateEntry is a key/value pair to be written to the bucket identified by
+// the path of bucket names from the metadata bucket.  A nil key only ensures
+// the bucket exists so empty buckets are migrated as well.
+type migrateEntry struct {
+	path  [][]byte
+	key   []byte
+	value []byte
+}
+
+// migrator copies the metadata and blocks of a source database to a
+// destination database in batches of destination transactions.
+type migrator struct {
+	dst       database.DB
+	quit      chan struct{}
+	batchSize int
+
+	entries   []migrateEntry
+	blocks    []*hcutil.Block
+	batchLen  int
+	numKeys   int64
+	numBlocks int64
+}
+
+// interrupted returns whether or not the migration has been interrupted.
+func (m *migrator) interrupted() bool {
+	select {
+	case <-m.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// flush writes the pending metadata entries and blocks to the destination
+// database in a single transaction.
+func (m *migrator) flush() error {
+	if len(m.entries) == 0 && len(m.blocks) == 0 {
+		return nil
+	}
+	if m.interrupted() {
+		return errMigrateInterrupted
+	}
+
+	err := m.dst.Update(func(tx database.Tx) error {
+		var bucket database.Bucket
+		var bucketPath [][]byte
+		for _, entry := range m.entries {
+			// Resolve the destination bucket of the entry, creating
+			// it as needed, unless it is the same as the previous one.
+			if bucket == nil || !samePath(entry.path, bucketPath) {
+				bucket = tx.Metadata()
+				for _, name := range entry.path {
+					var err error
+					bucket, err = bucket.CreateBucketIfNotExists(name)
+					if err != nil {
+						return err
+					}
+				}
+				bucketPath = entry.path
+			}
+
+			if entry.key == nil {
+				continue
+			}
+			if err := bucket.Put(entry.key, entry.value); err != nil {
+				return err
+			}
+		}
+
+		for _, block := range m.blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.entries = m.entries[:0]
+	m.blocks = m.blocks[:0]
+	m.batchLen = 0
+	return nil
+}
+
+// samePath returns whether or not the passed bucket paths are the same.
+func samePath(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// copySlice returns a copy of the passed slice.  The data returned by the
+// source database is only valid during its transaction and the destination
+// database requires it to remain valid until the batch is written.
+func copySlice(slice []byte) []byte {
+	ret := make([]byte, len(slice))
+	copy(ret, slice)
+	return ret
+}
+
+// addEntry adds the passed entry to the pending batch and writes the batch
+// once it exceeds the configured size.
+func (m *migrator) addEntry(path [][]byte, key, value []byte) error {
+	m.entries = append(m.entries, migrateEntry{path, key, value})
+	m.batchLen += len(key) + len(value)
+	if key != nil {
+		m.numKeys++
+	}
+	if m.batchLen < m.batchSize {
+		return nil
+	}
+	return m.flush()
+}
+
+// copyBucket copies all key/value pairs and nested buckets of the passed source
+// bucket to the destination bucket identified by the passed path.
+func (m *migrator) copyBucket(src database.Bucket, path [][]byte, skip [][]byte) error {
+	// Ensure the bucket exists in the destination even when it is empty.
+	if len(path) > 0 {
+		if err := m.addEntry(path, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	isSkipped := func(k []byte) bool {
+		for _, skipKey := range skip {
+			if bytes.Equal(k, skipKey) {
+				return true
+			}
+		}
+		return false
+	}
+
+	err := src.ForEach(func(k, v []byte) error {
+		if isSkipped(k) {
+			return nil
+		}
+		return m.addEntry(path, copySlice(k), copySlice(v))
+	})
+	if err != nil {
+		return err
+	}
+
+	return src.ForEachBucket(func(k []byte) error {
+		if isSkipped(k) {
+			return nil
+		}
+
+		// Use a new slice for the nested path so the paths of the
+		// pending entries are never modified.
+		childPath := make([][]byte, len(path)+1)
+		copy(childPath, path)
+		childPath[len(path)] = copySlice(k)
+		return m.copyBucket(src.Bucket(k), childPath, nil)
+	})
+}
+
+// copyBlocks copies all blocks listed in the passed ffldb block index bucket
+// from the source transaction to the destination database.
+func (m *migrator) copyBlocks(tx database.Tx, blockIdx database.Bucket) error {
+	return blockIdx.ForEach(func(k, v []byte) error {
+		var hash chainhash.Hash
+		copy(hash[:], k)
+		blockBytes, err := tx.FetchBlock(&hash)
+		if err != nil {
+			return err
+		}
+		block, err := hcutil.NewBlockFromBytes(copySlice(blockBytes))
+		if err != nil {
+			return fmt.Errorf("unable to deserialize block %s: %v",
+				hash, err)
+		}
+
+		m.blocks = append(m.blocks, block)
+		m.batchLen += len(blockBytes)
+		m.numBlocks++
+		if m.numBlocks%10000 == 0 {
+			log.Infof("Copied %d blocks", m.numBlocks)
+		}
+		if m.batchLen < m.batchSize {
+			return nil
+		}
+		return m.flush()
+	})
+}
+
+// copyFfldb copies the metadata, except for the keys used internally by the
+// ffldb driver, and all blocks of the passed ffldb database to the destination
+// database.
+func (m *migrator) copyFfldb(src database.DB) error {
+	return src.View(func(tx database.Tx) error {
+		log.Info("Copying metadata")
+		err := m.copyBucket(tx.Metadata(), nil, ffldbInternalKeys)
+		if err != nil {
+			return err
+		}
+		if err := m.flush(); err != nil {
+			return err
+		}
+		log.Infof("Copied %d keys", m.numKeys)
+
+		log.Info("Copying blocks")
+		blockIdx := tx.Metadata().Bucket(ffldbBlockIdxName)
+		if blockIdx == nil {
+			return errors.New("the source database does not have a " +
+				"block index")
+		}
+		if err := m.copyBlocks(tx, blockIdx); err != nil {
+			return err
+		}
+		return m.flush()
+	})
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *migrateCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Only ffldb databases can be migrated since the stored blocks are
+	// enumerated with its internal block index.
+	if cfg.DbType != "ffldb" {
+		str := "only ffldb block databases can be migrated -- the " +
+			"specified database type is [%v]"
+		return fmt.Errorf(str, cfg.DbType)
+	}
+	if !validDbType(cmd.ToDbType) {
+		str := "the specified destination database type [%v] is " +
+			"invalid -- supported types %v"
+		return fmt.Errorf(str, cmd.ToDbType, knownDbTypes)
+	}
+	if cmd.ToDbType == cfg.DbType {
+		return errors.New("the destination database type must differ " +
+			"from the source database type")
+	}
+	if cmd.BatchSize <= 0 {
+		return errors.New("the batch size must be positive")
+	}
+
+	// Open the existing source database.  Unlike the other commands, it
+	// is not created when it does not exist.
+	srcPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+cfg.DbType)
+	log.Infof("Loading source block database from '%s'", srcPath)
+	src, err := database.Open(cfg.DbType, srcPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Create the destination database, which must not exist yet.
+	dstPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+
+		cmd.ToDbType)
+	log.Infof("Creating destination block database in '%s'", dstPath)
+	dst, err := database.Create(cmd.ToDbType, dstPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	// Stop the migration between batches on Ctrl+C.
+	quit := make(chan struct{})
+	addInterruptHandler(func() {
+		close(quit)
+	})
+
+	m := &migrator{
+		dst:       dst,
+		quit:      quit,
+		batchSize: cmd.BatchSize * 1024 * 1024,
+	}
+	startTime := time.Now()
+	if err := m.copyFfldb(src); err != nil {
+		return err
+	}
+
+	log.Infof("Migrated %d keys and %d blocks to %s in %v", m.numKeys,
+		m.numBlocks, cmd.ToDbType, time.Since(startTime))
+	log.Infof("Start hcd with --dbtype=%s to use the migrated database",
+		cmd.ToDbType)
+	return nil
+}
@@ -0,0 +1,382 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/snappy-go"
+	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+	"github.com/james-ray/hcd/wire"
+)
+
+const (
+	// ffldbBlockFilenameTemplate is the template used by the ffldb driver
+	// to name the flat block files.
+	ffldbBlockFilenameTemplate = "%09d.fdb"
+
+	// ffldbBlockLocSize is the size of the serialized block location at
+	// the start of each ffldb block index entry.  It is followed by the
+	// block header.
+	ffldbBlockLocSize = 12
+
+	// ffldbBlockLocCompressedFlag is the flag the ffldb driver sets in the
+	// block length of the serialized block location of compressed blocks.
+	ffldbBlockLocCompressedFlag = 1 << 31
+
+	// ffldbRecordOverhead is the number of bytes an ffldb block file
+	// record uses in addition to the serialized block for the network, the
+	// block length, and the checksum.
+	ffldbRecordOverhead = 12
+)
+
+var (
+	// ffldbByteOrder is the byte order the ffldb driver uses for its
+	// serialized integers.
+	ffldbByteOrder = binary.LittleEndian
+
+	// castagnoli houses the Castagnoli polynomial the ffldb driver uses
+	// for its CRC-32 checksums.
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+	// errVerifyInterrupted is returned when the verification is
+	// interrupted by the user.
+	errVerifyInterrupted = errors.New("verification interrupted")
+)
+
+// verifyCmd defines the configuration options for the verify command.
+type verifyCmd struct {
+	Repair         bool `long:"repair" description:"Repair the inconsistencies that can be repaired without reprocessing the chain"`
+	SkipChainState bool `long:"skipchainstate" description:"Do not verify the chain state and only verify the stored blocks"`
+}
+
+var (
+	// verifyCfg defines the configuration options for the command.
+	verifyCfg = verifyCmd{}
+)
+
+// verifier keeps track of the inconsistencies found by the verify command.
+type verifier struct {
+	quit        chan struct{}
+	numIssues   int
+	numRepaired int
+}
+
+// interrupted returns whether or not the verification has been interrupted.
+func (v *verifier) interrupted() bool {
+	select {
+	case <-v.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// report logs the passed inconsistency and whether or not it was repaired.
+func (v *verifier) report(issue string, repaired bool) {
+	v.numIssues++
+	if repaired {
+		v.numRepaired++
+		log.Infof("Repaired: %s", issue)
+		return
+	}
+	log.Warnf("Inconsistency: %s", issue)
+}
+
+// ffldbBlockRecord describes the location of a block in the ffldb block files
+// as recorded in the block index.
+type ffldbBlockRecord struct {
+	hash       chainhash.Hash
+	header     []byte
+	fileNum    uint32
+	offset     uint32
+	length     uint32
+	compressed bool
+}
+
+// checkBlockRecord reads the passed block record from the passed open block
+// file, decompressing it as needed, and ensures its checksum, network, length,
+// block hash, and header match.  It returns a description of the problem or an
+// empty string when the record is valid.
+func checkBlockRecord(file *os.File, rec *ffldbBlockRecord) string {
+	if rec.length < ffldbRecordOverhead {
+		return fmt.Sprintf("has invalid length %d", rec.length)
+	}
+	data := make([]byte, rec.length)
+	if _, err := file.ReadAt(data, int64(rec.offset)); err != nil {
+		return fmt.Sprintf("can't be read: %v", err)
+	}
+
+	n := len(data)
+	gotChecksum := crc32.Checksum(data[:n-4], castagnoli)
+	wantChecksum := binary.BigEndian.Uint32(data[n-4:])
+	if gotChecksum != wantChecksum {
+		return fmt.Sprintf("has checksum %08x, want %08x", gotChecksum,
+			wantChecksum)
+	}
+	if net := ffldbByteOrder.Uint32(data[0:4]); net != uint32(activeNetParams.Net) {
+		return fmt.Sprintf("is for network %d, want %d", net,
+			uint32(activeNetParams.Net))
+	}
+	blockLen := ffldbByteOrder.Uint32(data[4:8])
+	if blockLen != rec.length-ffldbRecordOverhead {
+		return fmt.Sprintf("has block length %d, want %d", blockLen,
+			rec.length-ffldbRecordOverhead)
+	}
+
+	blockBytes := data[8 : n-4]
+	if rec.compressed {
+		var err error
+		blockBytes, err = snappy.Decode(nil, blockBytes)
+		if err != nil {
+			return fmt.Sprintf("can't be decompressed: %v", err)
+		}
+	}
+	var block wire.MsgBlock
+	if err := block.FromBytes(blockBytes); err != nil {
+		return fmt.Sprintf("can't be deserialized: %v", err)
+	}
+	if hash := block.BlockHash(); hash != rec.hash {
+		return fmt.Sprintf("has block hash %v", hash)
+	}
+	if !bytes.HasPrefix(blockBytes, rec.header) {
+		return "does not match the header stored in the block index"
+	}
+	return ""
+}
+
+// loadBlockRecords returns the records of all blocks in the passed ffldb block
+// index sorted by their location.  Entries which can't be parsed are reported.
+func (v *verifier) loadBlockRecords(blockIdx database.Bucket) ([]ffldbBlockRecord, error) {
+	var records []ffldbBlockRecord
+	err := blockIdx.ForEach(func(k, val []byte) error {
+		if len(k) != chainhash.HashSize ||
+			len(val) != ffldbBlockLocSize+wire.MaxBlockHeaderPayload {
+
+			v.report(fmt.Sprintf("invalid block index entry %x", k),
+				false)
+			return nil
+		}
+
+		length := ffldbByteOrder.Uint32(val[8:12])
+		rec := ffldbBlockRecord{
+			header:     copySlice(val[ffldbBlockLocSize:]),
+			fileNum:    ffldbByteOrder.Uint32(val[0:4]),
+			offset:     ffldbByteOrder.Uint32(val[4:8]),
+			length:     length &^ ffldbBlockLocCompressedFlag,
+			compressed: length&ffldbBlockLocCompressedFlag != 0,
+		}
+		copy(rec.hash[:], k)
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort the records by location so the block files are read
+	// sequentially and overlaps are easy to detect.
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].fileNum != records[j].fileNum {
+			return records[i].fileNum < records[j].fileNum
+		}
+		return records[i].offset < records[j].offset
+	})
+	return records, nil
+}
+
+// verifyBlockFiles rereads every block listed in the ffldb block index from the
+// flat block files in the passed database directory and ensures the records
+// are intact, within the write cursor, and do not overlap.  When repair is
+// set, the block index entries of invalid blocks which are not part of the
+// main chain are removed so the blocks can be downloaded again.
+func (v *verifier) verifyBlockFiles(tx database.Tx, dbPath string, repair bool) error {
+	// Load the write cursor.  Its checksum has already been verified when
+	// the database was opened.
+	writeRow := tx.Metadata().Get(ffldbWriteLocName)
+	if len(writeRow) != 12 {
+		return errors.New("the database does not have a valid write " +
+			"cursor")
+	}
+	curFileNum := ffldbByteOrder.Uint32(writeRow[0:4])
+	curOffset := ffldbByteOrder.Uint32(writeRow[4:8])
+
+	blockIdx := tx.Metadata().Bucket(ffldbBlockIdxName)
+	if blockIdx == nil {
+		return errors.New("the database does not have a block index")
+	}
+	records, err := v.loadBlockRecords(blockIdx)
+	if err != nil {
+		return err
+	}
+	log.Infof("Verifying %d blocks in block files 0 to %d", len(records),
+		curFileNum)
+
+	var invalid []*ffldbBlockRecord
+	var invalidIssues []string
+	var file *os.File
+	var fileNum uint32
+	var prevEnd uint32
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+	for i := range records {
+		if v.interrupted() {
+			return errVerifyInterrupted
+		}
+		if i > 0 && i%10000 == 0 {
+			log.Infof("Verified %d of %d blocks", i, len(records))
+		}
+
+		rec := &records[i]
+		invalidate := func(issue string) {
+			invalid = append(invalid, rec)
+			invalidIssues = append(invalidIssues, fmt.Sprintf("block "+
+				"%v in file %d at offset %d %s", rec.hash,
+				rec.fileNum, rec.offset, issue))
+		}
+
+		// Ensure the record is before the write cursor since anything
+		// after it is rolled back when the database is opened.
+		end := uint64(rec.offset) + uint64(rec.length)
+		if rec.fileNum > curFileNum || (rec.fileNum == curFileNum &&
+			end > uint64(curOffset)) {
+
+			invalidate(fmt.Sprintf("is after the write cursor (file "+
+				"%d, offset %d)", curFileNum, curOffset))
+			continue
+		}
+
+		// Open the next block file as needed and ensure the records
+		// are contiguous.
+		if file == nil || rec.fileNum != fileNum {
+			if file != nil {
+				file.Close()
+				file = nil
+			}
+			fileNum = rec.fileNum
+			prevEnd = 0
+			name := fmt.Sprintf(ffldbBlockFilenameTemplate, fileNum)
+			file, err = os.Open(filepath.Join(dbPath, name))
+			if err != nil {
+				invalidate(fmt.Sprintf("can't be read: %v", err))
+				continue
+			}
+		}
+		switch {
+		case rec.offset < prevEnd:
+			invalidate(fmt.Sprintf("overlaps the previous block "+
+				"which ends at offset %d", prevEnd))
+			continue
+		case rec.offset > prevEnd:
+			v.report(fmt.Sprintf("block file %d has %d unreferenced "+
+				"bytes at offset %d", fileNum, rec.offset-prevEnd,
+				prevEnd), false)
+		}
+		prevEnd = uint32(end)
+
+		if issue := checkBlockRecord(file, rec); issue != "" {
+			invalidate(issue)
+		}
+	}
+	log.Infof("Verified %d blocks", len(records))
+
+	// Report the invalid blocks and remove the ones which are not part
+	// of the main chain from the block index when repairing.  Main chain
+	// blocks can't be removed without disconnecting them.
+	for i, rec := range invalid {
+		repaired := repair && !blockchain.DBMainChainHasBlock(tx, &rec.hash)
+		if repaired {
+			if err := blockIdx.Delete(rec.hash[:]); err != nil {
+				return err
+			}
+		}
+		v.report(invalidIssues[i], repaired)
+	}
+	return nil
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *verifyCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Open the existing database.  Unlike the other commands, it is not
+	// created when it does not exist.
+	dbPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+cfg.DbType)
+	log.Infof("Loading block database from '%s'", dbPath)
+	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Stop the verification on Ctrl+C.  Repairs made until then are
+	// discarded.
+	v := &verifier{quit: make(chan struct{})}
+	addInterruptHandler(func() {
+		close(v.quit)
+	})
+
+	// Repairs are made in a single transaction, so nothing is changed
+	// unless the verification completes.
+	startTime := time.Now()
+	verify := func(tx database.Tx) error {
+		// The chain state is verified first since the main chain is
+		// needed to decide which invalid blocks can be removed.
+		chainStateVerified := false
+		if !cmd.SkipChainState {
+			err := blockchain.VerifyChainState(tx, cmd.Repair, v.report,
+				v.quit)
+			if err != nil {
+				return err
+			}
+			chainStateVerified = true
+		}
+
+		if cfg.DbType != "ffldb" {
+			log.Infof("Skipping the block file checks which are "+
+				"only supported for ffldb block databases -- the "+
+				"specified database type is [%v]", cfg.DbType)
+			return nil
+		}
+		return v.verifyBlockFiles(tx, dbPath,
+			cmd.Repair && chainStateVerified)
+	}
+	if cmd.Repair {
+		err = db.Update(verify)
+	} else {
+		err = db.View(verify)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Verification finished in %v", time.Since(startTime))
+	if v.numIssues == 0 {
+		log.Info("No inconsistencies found")
+		return nil
+	}
+	if v.numRepaired == v.numIssues {
+		log.Infof("Repaired all %d inconsistencies", v.numIssues)
+		return nil
+	}
+	return fmt.Errorf("found %d inconsistencies, %d of which were "+
+		"repaired", v.numIssues, v.numRepaired)
+}
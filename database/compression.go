@@ -0,0 +1,79 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BlockCompression identifies the algorithm used to compress the blocks stored
+// in a database.
+type BlockCompression byte
+
+// These constants define the supported block compression algorithms.
+const (
+	// BlockCompressionNone stores blocks as they are serialized.
+	BlockCompressionNone BlockCompression = 0
+
+	// BlockCompressionSnappy compresses stored blocks with snappy.
+	BlockCompressionSnappy BlockCompression = 1
+)
+
+// blockCompressionStrings is a map of block compression algorithms back to
+// their constant names for pretty printing.
+var blockCompressionStrings = map[BlockCompression]string{
+	BlockCompressionNone:   "none",
+	BlockCompressionSnappy: "snappy",
+}
+
+// String returns the BlockCompression as a human-readable name.
+func (c BlockCompression) String() string {
+	if s := blockCompressionStrings[c]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown BlockCompression (%d)", byte(c))
+}
+
+// ParseBlockCompression returns the block compression algorithm with the passed
+// human-readable name.
+func ParseBlockCompression(name string) (BlockCompression, error) {
+	for c, s := range blockCompressionStrings {
+		if strings.EqualFold(name, s) {
+			return c, nil
+		}
+	}
+	return BlockCompressionNone, fmt.Errorf("unsupported block compression "+
+		"%q", name)
+}
+
+// BlockCompressor is implemented by the transactions of database drivers that
+// are able to compress the blocks they store.  Callers obtain it with a type
+// assertion on a Tx.
+//
+// Compression is transparent to the callers of the block fetching functions of
+// the Tx interface.  Changing the setting only affects blocks stored after the
+// change, so a database may hold blocks stored with different algorithms.
+type BlockCompressor interface {
+	// BlockCompression returns the algorithm used to compress blocks that
+	// are stored by the transaction.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxClosed if the transaction has already been closed
+	//   - ErrCorruption if the stored setting is invalid
+	BlockCompression() (BlockCompression, error)
+
+	// SetBlockCompression sets the algorithm used to compress blocks that
+	// are stored once the transaction is committed, including the blocks
+	// stored by the transaction itself.  The setting is persisted in the
+	// database.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	SetBlockCompression(compression BlockCompression) error
+}
@@ -15,6 +15,9 @@ The default backend, ffldb, has a strong focus on speed, efficiency, and
 robustness.  It makes use leveldb for the metadata, flat files for block
 storage, and strict checksums in key areas to ensure data integrity.
 
+The bboltdb backend keeps both the metadata and the blocks in a single bbolt
+B+tree file instead.
+
 A quick overview of the features database provides are as follows:
 
  - Key/value metadata store
@@ -0,0 +1,239 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/filter"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/james-ray/hcd/database"
+)
+
+// backupBatchSize is the number of bytes of metadata to accumulate before
+// writing them to the backup metadata database.
+const backupBatchSize = 16 * 1024 * 1024
+
+// Enforce transaction implements the database.Backuper interface.
+var _ database.Backuper = (*transaction)(nil)
+
+// backupMetadata writes all key/value pairs of the passed snapshot, which
+// includes the entries of the database cache that are not flushed yet, to a
+// new leveldb database at the passed path.
+func backupMetadata(snapshot *dbCacheSnapshot, metadataDbPath string) error {
+	opts := opt.Options{
+		ErrorIfExist: true,
+		Strict:       opt.DefaultStrict,
+		Compression:  opt.NoCompression,
+		Filter:       filter.NewBloomFilter(10),
+	}
+	ldb, err := leveldb.OpenFile(metadataDbPath, &opts)
+	if err != nil {
+		return convertErr(err.Error(), err)
+	}
+
+	iter := snapshot.NewIterator(&util.Range{})
+	defer iter.Release()
+	batch := new(leveldb.Batch)
+	var batchLen int
+	for ok := iter.First(); ok; ok = iter.Next() {
+		key, value := iter.Key(), iter.Value()
+		batch.Put(key, value)
+		batchLen += len(key) + len(value)
+		if batchLen < backupBatchSize {
+			continue
+		}
+
+		if err := ldb.Write(batch, nil); err != nil {
+			_ = ldb.Close()
+			return convertErr("failed to write backup metadata", err)
+		}
+		batch.Reset()
+		batchLen = 0
+	}
+	if err := ldb.Write(batch, nil); err != nil {
+		_ = ldb.Close()
+		return convertErr("failed to write backup metadata", err)
+	}
+
+	if err := ldb.Close(); err != nil {
+		return convertErr("failed to close backup metadata", err)
+	}
+	return nil
+}
+
+// backupBlockFile copies the passed number of bytes from the start of the
+// block file with the passed number to the backup directory and returns its
+// description for the manifest.  The entire file is copied when the number of
+// bytes is negative.
+func (s *blockStore) backupBlockFile(backupPath string, fileNum uint32, numBytes int64) (database.BackupFile, error) {
+	name := fmt.Sprintf(blockFilenameTemplate, fileNum)
+	src, err := os.Open(blockFilePath(s.basePath, fileNum))
+	if err != nil {
+		return database.BackupFile{}, makeDbErr(database.ErrDriverSpecific,
+			err.Error(), err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(filepath.Join(backupPath, name),
+		os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return database.BackupFile{}, makeDbErr(database.ErrDriverSpecific,
+			err.Error(), err)
+	}
+	defer dst.Close()
+
+	// Only copy the data that was written before the snapshot since the
+	// current write file might be appended to while it is copied.
+	hasher := sha256.New()
+	w := io.MultiWriter(dst, hasher)
+	var n int64
+	if numBytes < 0 {
+		n, err = io.Copy(w, src)
+	} else {
+		n, err = io.CopyN(w, src, numBytes)
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if err != nil {
+		str := fmt.Sprintf("failed to copy block file %d: %v", fileNum,
+			err)
+		return database.BackupFile{}, makeDbErr(database.ErrDriverSpecific,
+			str, err)
+	}
+
+	return database.BackupFile{
+		Name:   name,
+		Size:   n,
+		SHA256: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+// Backup writes a copy of the database as it was when the transaction was
+// started to the passed directory, which must not exist yet, along with a
+// manifest describing the copied files.  Changes made by the transaction itself
+// are not included.
+//
+// The metadata is copied from the snapshot the transaction reads from into a
+// new leveldb database and the flat block files are copied up to the write
+// cursor recorded in that snapshot, so other transactions, including writes,
+// can proceed while the backup is in progress.  However, closing the database
+// waits for the backup to finish like for any other open transaction.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrDbExists if the backup directory already exists
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Backuper interface implementation.
+func (tx *transaction) Backup(backupPath string) (*database.BackupManifest, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Refuse to overwrite anything.
+	if fileExists(backupPath) {
+		str := fmt.Sprintf("backup directory %q already exists",
+			backupPath)
+		return nil, makeDbErr(database.ErrDbExists, str, nil)
+	}
+
+	// Load the block file write cursor as of the snapshot.  It is read from
+	// the snapshot directly since the transaction might have pending
+	// updates when it is writable.
+	writeRow := tx.snapshot.Get(bucketizedKey(metadataBucketID,
+		writeLocKeyName))
+	if writeRow == nil {
+		str := "write cursor does not exist"
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	lastFileNum, lastFileOffset, err := deserializeWriteRow(writeRow)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(backupPath, 0700); err != nil {
+		return nil, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	manifest, err := tx.backup(backupPath, lastFileNum, lastFileOffset)
+	if err != nil {
+		// Remove the partial backup.
+		_ = os.RemoveAll(backupPath)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// backup copies the metadata and the block files up to the passed write cursor
+// to the passed existing backup directory and writes the manifest.
+func (tx *transaction) backup(backupPath string, lastFileNum, lastFileOffset uint32) (*database.BackupManifest, error) {
+	log.Infof("Backing up database metadata to %s", backupPath)
+	metadataBackupPath := filepath.Join(backupPath, metadataDbName)
+	if err := backupMetadata(tx.snapshot, metadataBackupPath); err != nil {
+		return nil, err
+	}
+	metadataFiles, err := ioutil.ReadDir(metadataBackupPath)
+	if err != nil {
+		return nil, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+
+	// The files of the backup metadata database no longer change once it
+	// is closed, so they can be hashed now.
+	manifest := &database.BackupManifest{
+		Version: database.BackupManifestVersion,
+		DbType:  dbType,
+		Network: uint32(tx.db.store.network),
+		Created: time.Now().Unix(),
+	}
+	for _, fi := range metadataFiles {
+		if fi.IsDir() {
+			continue
+		}
+		name := metadataDbName + "/" + fi.Name()
+		file, err := database.HashBackupFile(backupPath, name)
+		if err != nil {
+			return nil, makeDbErr(database.ErrDriverSpecific,
+				err.Error(), err)
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	// Copy the block files.  The last one is only copied up to the write
+	// cursor and it does not exist yet when nothing was written to it.
+	store := tx.db.store
+	for fileNum := uint32(0); fileNum <= lastFileNum; fileNum++ {
+		numBytes := int64(-1)
+		if fileNum == lastFileNum {
+			if lastFileOffset == 0 {
+				break
+			}
+			numBytes = int64(lastFileOffset)
+		}
+
+		log.Infof("Backing up block file %d of %d", fileNum+1,
+			lastFileNum+1)
+		file, err := store.backupBlockFile(backupPath, fileNum, numBytes)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	if err := database.WriteBackupManifest(backupPath, manifest); err != nil {
+		return nil, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	log.Infof("Backed up %d files to %s", len(manifest.Files), backupPath)
+	return manifest, nil
+}
@@ -59,7 +59,15 @@ const (
 	//  [0:4]  Block file (4 bytes)
 	//  [4:8]  File offset (4 bytes)
 	//  [8:12] Block length (4 bytes)
+	//
+	// The high bit of the block length is set when the block is stored
+	// compressed.  It is never set by itself since the block files are
+	// limited to maxBlockFileSize bytes.
 	blockLocSize = 12
+
+	// blockLocCompressedFlag is the flag set in the serialized block length
+	// of the block location of compressed blocks.
+	blockLocCompressedFlag = 1 << 31
 )
 
 var (
@@ -173,6 +181,11 @@ type blockStore struct {
 	// new blocks are written to.
 	writeCursor *writeCursor
 
+	// decompressedBlocks caches the most recently decompressed blocks so
+	// the regions of compressed blocks can be fetched without reading and
+	// decompressing the entire block each time.
+	decompressedBlocks decompressedBlockCache
+
 	// These functions are set to openFile, openWriteFile, and deleteFile by
 	// default, but are exposed here to allow the whitebox tests to replace
 	// them when working with mock files.
@@ -181,11 +194,14 @@ type blockStore struct {
 	deleteFileFunc    func(fileNum uint32) error
 }
 
-// blockLocation identifies a particular block file and location.
+// blockLocation identifies a particular block file and location.  The block
+// length is the length of the full record in the block file, which holds the
+// compressed block when the compressed flag is set.
 type blockLocation struct {
 	blockFileNum uint32
 	fileOffset   uint32
 	blockLen     uint32
+	compressed   bool
 }
 
 // deserializeBlockLoc deserializes the passed serialized block location
@@ -199,11 +215,13 @@ func deserializeBlockLoc(serializedLoc []byte) blockLocation {
 	//
 	//  [0:4]  Block file (4 bytes)
 	//  [4:8]  File offset (4 bytes)
-	//  [8:12] Block length (4 bytes)
+	//  [8:12] Block length (4 bytes) with the compressed flag
+	blockLen := byteOrder.Uint32(serializedLoc[8:12])
 	return blockLocation{
 		blockFileNum: byteOrder.Uint32(serializedLoc[0:4]),
 		fileOffset:   byteOrder.Uint32(serializedLoc[4:8]),
-		blockLen:     byteOrder.Uint32(serializedLoc[8:12]),
+		blockLen:     blockLen &^ blockLocCompressedFlag,
+		compressed:   blockLen&blockLocCompressedFlag != 0,
 	}
 }
 
@@ -214,11 +232,15 @@ func serializeBlockLoc(loc blockLocation) []byte {
 	//
 	//  [0:4]  Block file (4 bytes)
 	//  [4:8]  File offset (4 bytes)
-	//  [8:12] Block length (4 bytes)
+	//  [8:12] Block length (4 bytes) with the compressed flag
+	blockLen := loc.blockLen
+	if loc.compressed {
+		blockLen |= blockLocCompressedFlag
+	}
 	var serializedData [12]byte
 	byteOrder.PutUint32(serializedData[0:4], loc.blockFileNum)
 	byteOrder.PutUint32(serializedData[4:8], loc.fileOffset)
-	byteOrder.PutUint32(serializedData[8:12], loc.blockLen)
+	byteOrder.PutUint32(serializedData[8:12], blockLen)
 	return serializedData[:]
 }
 
@@ -403,6 +425,9 @@ func (s *blockStore) writeData(data []byte, fieldName string) error {
 // The write cursor will also be advanced the number of bytes actually written
 // in the event of failure.
 //
+// The bytes are written as passed, so compressed blocks must be compressed by
+// the caller, which is also responsible for flagging the returned location.
+//
 // Format: <network><block length><serialized block><checksum>
 func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 	// Compute how many bytes will be written.
@@ -505,9 +530,11 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 // and closing files as necessary to stay within the maximum allowed open files
 // limit.
 //
+// Blocks which are stored compressed are decompressed before they are returned.
+//
 // Returns ErrDriverSpecific if the data fails to read for any reason and
 // ErrCorruption if the checksum of the read data doesn't match the checksum
-// read from the file.
+// read from the file or a compressed block fails to decompress.
 //
 // Format: <network><block length><serialized block><checksum>
 func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte, error) {
@@ -555,6 +582,9 @@ func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte,
 
 	// The raw block excludes the network, length of the block, and
 	// checksum.
+	if loc.compressed {
+		return decompressBlock(hash, serializedData[8:n-4])
+	}
 	return serializedData[8 : n-4], nil
 }
 
@@ -565,6 +595,9 @@ func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte,
 // closing files as necessary to stay within the maximum allowed open files
 // limit.
 //
+// The region is read directly from the block file, so it must not be used for
+// compressed blocks.
+//
 // Returns ErrDriverSpecific if the data fails to read for any reason.
 func (s *blockStore) readBlockRegion(loc blockLocation, offset, numBytes uint32) ([]byte, error) {
 	// Get the referenced block file handle opening the file as needed.  The
@@ -0,0 +1,191 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/snappy-go"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+)
+
+const (
+	// decompressedBlockCacheSize is the number of the most recently
+	// decompressed blocks kept by the block store.
+	decompressedBlockCacheSize = 4
+)
+
+var (
+	// blockCompressionKeyName is the key used to store the algorithm used
+	// to compress newly written blocks.  Blocks are not compressed when it
+	// does not exist.
+	blockCompressionKeyName = []byte("ffldb-compression")
+)
+
+// Enforce transaction implements the database.BlockCompressor interface.
+var _ database.BlockCompressor = (*transaction)(nil)
+
+// BlockCompression returns the algorithm used to compress blocks that are
+// stored by the transaction.
+//
+// This function is part of the database.BlockCompressor interface
+// implementation.
+func (tx *transaction) BlockCompression() (database.BlockCompression, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return database.BlockCompressionNone, err
+	}
+
+	return tx.fetchBlockCompression()
+}
+
+// fetchBlockCompression loads the algorithm used to compress newly written
+// blocks from the metadata.
+func (tx *transaction) fetchBlockCompression() (database.BlockCompression, error) {
+	serialized := tx.metaBucket.Get(blockCompressionKeyName)
+	if serialized == nil {
+		return database.BlockCompressionNone, nil
+	}
+
+	if len(serialized) != 1 {
+		str := fmt.Sprintf("malformed block compression of %d bytes",
+			len(serialized))
+		return database.BlockCompressionNone,
+			makeDbErr(database.ErrCorruption, str, nil)
+	}
+	compression := database.BlockCompression(serialized[0])
+	switch compression {
+	case database.BlockCompressionNone, database.BlockCompressionSnappy:
+		return compression, nil
+	}
+	str := fmt.Sprintf("unsupported block compression %d", serialized[0])
+	return database.BlockCompressionNone,
+		makeDbErr(database.ErrCorruption, str, nil)
+}
+
+// SetBlockCompression sets the algorithm used to compress blocks that are
+// stored once the transaction is committed.  Blocks which are already stored
+// are left as they are.
+//
+// This function is part of the database.BlockCompressor interface
+// implementation.
+func (tx *transaction) SetBlockCompression(compression database.BlockCompression) error {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "setting the block compression requires a writable " +
+			"database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	switch compression {
+	case database.BlockCompressionNone, database.BlockCompressionSnappy:
+	default:
+		str := fmt.Sprintf("unsupported block compression %v",
+			compression)
+		return makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	err := tx.metaBucket.Put(blockCompressionKeyName,
+		[]byte{byte(compression)})
+	if err != nil {
+		return convertErr("failed to store block compression", err)
+	}
+	return nil
+}
+
+// compressBlock returns the passed serialized block compressed with the passed
+// algorithm along with whether or not it was compressed.  The block is left
+// uncompressed when compression does not reduce its size.
+func compressBlock(compression database.BlockCompression, rawBlock []byte) ([]byte, bool) {
+	if compression != database.BlockCompressionSnappy {
+		return rawBlock, false
+	}
+
+	compressed := snappy.Encode(nil, rawBlock)
+	if len(compressed) >= len(rawBlock) {
+		return rawBlock, false
+	}
+	return compressed, true
+}
+
+// decompressBlock returns the serialized block for the passed compressed block
+// data.
+//
+// Returns ErrCorruption if the data can't be decompressed.
+func decompressBlock(hash *chainhash.Hash, data []byte) ([]byte, error) {
+	rawBlock, err := snappy.Decode(nil, data)
+	if err != nil {
+		str := fmt.Sprintf("failed to decompress block %s: %v", hash,
+			err)
+		return nil, makeDbErr(database.ErrCorruption, str, err)
+	}
+	return rawBlock, nil
+}
+
+// decompressedBlockCache houses the most recently decompressed blocks.  Regions
+// of compressed blocks can't be read directly from the block files, so fetching
+// a region of a compressed block requires reading and decompressing the entire
+// block.  Caching the blocks avoids repeating that work when several regions of
+// the same block are fetched one at a time, such as the transactions of a block
+// looked up through the transaction index.
+//
+// The cached blocks are never modified, so they are safe to return to callers
+// of the block fetching functions.
+type decompressedBlockCache struct {
+	sync.Mutex
+	hashes [decompressedBlockCacheSize]chainhash.Hash
+	blocks [decompressedBlockCacheSize][]byte
+	next   int
+}
+
+// lookup returns the cached serialized block for the passed hash or nil when it
+// is not cached.
+//
+// This function is safe for concurrent access.
+func (c *decompressedBlockCache) lookup(hash *chainhash.Hash) []byte {
+	c.Lock()
+	defer c.Unlock()
+	for i := range c.hashes {
+		if c.blocks[i] != nil && c.hashes[i] == *hash {
+			return c.blocks[i]
+		}
+	}
+	return nil
+}
+
+// add caches the passed serialized block, replacing the least recently added
+// block once the cache is full.
+//
+// This function is safe for concurrent access.
+func (c *decompressedBlockCache) add(hash *chainhash.Hash, blockBytes []byte) {
+	c.Lock()
+	c.hashes[c.next] = *hash
+	c.blocks[c.next] = blockBytes
+	c.next = (c.next + 1) % decompressedBlockCacheSize
+	c.Unlock()
+}
+
+// readDecompressedBlock returns the serialized block for the passed location of
+// a compressed block.  The most recently decompressed blocks are returned from
+// the cache rather than being read and decompressed again.
+func (s *blockStore) readDecompressedBlock(hash *chainhash.Hash, loc blockLocation) ([]byte, error) {
+	if blockBytes := s.decompressedBlocks.lookup(hash); blockBytes != nil {
+		return blockBytes, nil
+	}
+
+	blockBytes, err := s.readBlock(hash, loc)
+	if err != nil {
+		return nil, err
+	}
+	s.decompressedBlocks.add(hash, blockBytes)
+	return blockBytes, nil
+}
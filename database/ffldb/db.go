@@ -1434,8 +1434,18 @@ func (tx *transaction) fetchPendingRegion(region *database.BlockRegion) ([]byte,
 		return nil, nil
 	}
 
+	// Return the bytes from the pending block.
+	return blockRegionBytes(region, tx.pendingBlockData[idx].bytes)
+}
+
+// blockRegionBytes returns the provided region of the passed serialized block
+// after ensuring it is within the bounds of the block.  It is used for the
+// blocks which are fully loaded in memory, such as pending and compressed
+// blocks.
+//
+// Returns ErrBlockRegionInvalid if the region is invalid.
+func blockRegionBytes(region *database.BlockRegion, blockBytes []byte) ([]byte, error) {
 	// Ensure the region is within the bounds of the block.
-	blockBytes := tx.pendingBlockData[idx].bytes
 	blockLen := uint32(len(blockBytes))
 	endOffset := region.Offset + region.Len
 	if endOffset < region.Offset || endOffset > blockLen {
@@ -1445,7 +1455,6 @@ func (tx *transaction) fetchPendingRegion(region *database.BlockRegion) ([]byte,
 		return nil, makeDbErr(database.ErrBlockRegionInvalid, str, nil)
 	}
 
-	// Return the bytes from the pending block.
 	return blockBytes[region.Offset:endOffset:endOffset], nil
 }
 
@@ -1454,7 +1463,9 @@ func (tx *transaction) fetchPendingRegion(region *database.BlockRegion) ([]byte,
 // For example, it is possible to directly extract transactions and/or scripts
 // from a block with this function.  Depending on the backend implementation,
 // this can provide significant savings by avoiding the need to load entire
-// blocks.
+// blocks.  However, regions of blocks which are stored compressed require the
+// entire block to be read and decompressed, although the most recently
+// decompressed blocks are cached.
 //
 // The raw bytes are in the format returned by Serialize on a wire.MsgBlock and
 // the Offset field in the provided BlockRegion is zero-based and relative to
@@ -1501,6 +1512,18 @@ func (tx *transaction) FetchBlockRegion(region *database.BlockRegion) ([]byte, e
 	}
 	location := deserializeBlockLoc(blockRow)
 
+	// Compressed blocks must be fully read and decompressed before the
+	// region can be extracted.  The most recently decompressed blocks are
+	// cached to avoid repeating that work for each region of the same block.
+	if location.compressed {
+		blockBytes, err := tx.db.store.readDecompressedBlock(region.Hash,
+			location)
+		if err != nil {
+			return nil, err
+		}
+		return blockRegionBytes(region, blockBytes)
+	}
+
 	// Ensure the region is within the bounds of the block.
 	endOffset := region.Offset + region.Len
 	if endOffset < region.Offset || endOffset > location.blockLen {
@@ -1527,7 +1550,9 @@ func (tx *transaction) FetchBlockRegion(region *database.BlockRegion) ([]byte, e
 // For example, it is possible to directly extract transactions and/or scripts
 // from various blocks with this function.  Depending on the backend
 // implementation, this can provide significant savings by avoiding the need to
-// load entire blocks.
+// load entire blocks.  However, regions of blocks which are stored compressed
+// require the entire block to be read and decompressed, although the most
+// recently decompressed blocks are cached.
 //
 // The raw bytes are in the format returned by Serialize on a wire.MsgBlock and
 // the Offset fields in the provided BlockRegions are zero-based and relative to
@@ -1599,9 +1624,13 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 		}
 		location := deserializeBlockLoc(blockRow)
 
-		// Ensure the region is within the bounds of the block.
+		// Ensure the region is within the bounds of the block.  The
+		// regions of compressed blocks are checked once they are
+		// decompressed.
 		endOffset := region.Offset + region.Len
-		if endOffset < region.Offset || endOffset > location.blockLen {
+		if !location.compressed && (endOffset < region.Offset ||
+			endOffset > location.blockLen) {
+
 			str := fmt.Sprintf("block %s region offset %d, length "+
 				"%d exceeds block length of %d", region.Hash,
 				region.Offset, region.Len, location.blockLen)
@@ -1612,12 +1641,29 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 	}
 	sort.Sort(bulkFetchDataSorter(fetchList))
 
-	// Read all of the regions in the fetch list and set the results.
+	// Read all of the regions in the fetch list and set the results.  The
+	// regions of compressed blocks are extracted from the entire
+	// decompressed block, which is cached so the regions of the same block,
+	// which the sorted fetch list groups together, only decompress it once.
 	for i := range fetchList {
 		fetchData := &fetchList[i]
 		ri := fetchData.replyIndex
 		region := &regions[ri]
 		location := fetchData.blockLocation
+		if location.compressed {
+			blockBytes, err := tx.db.store.readDecompressedBlock(
+				region.Hash, *location)
+			if err != nil {
+				return nil, err
+			}
+			regionBytes, err := blockRegionBytes(region, blockBytes)
+			if err != nil {
+				return nil, err
+			}
+			blockRegions[ri] = regionBytes
+			continue
+		}
+
 		regionBytes, err := tx.db.store.readBlockRegion(*location,
 			region.Offset, region.Len)
 		if err != nil {
@@ -1695,14 +1741,23 @@ func (tx *transaction) writePendingAndCommit() error {
 		tx.db.store.handleRollback(oldBlkFileNum, oldBlkOffset)
 	}
 
+	// Load the algorithm used to compress the blocks.  It is loaded from
+	// the transaction so a change made by it applies to its own blocks.
+	compression, err := tx.fetchBlockCompression()
+	if err != nil {
+		return err
+	}
+
 	// Loop through all of the pending blocks to store and write them.
 	for _, blockData := range tx.pendingBlockData {
 		log.Tracef("Storing block %s", blockData.hash)
-		location, err := tx.db.store.writeBlock(blockData.bytes)
+		data, compressed := compressBlock(compression, blockData.bytes)
+		location, err := tx.db.store.writeBlock(data)
 		if err != nil {
 			rollback()
 			return err
 		}
+		location.compressed = compressed
 
 		// Add a record in the block index for the block.  The record
 		// includes the location information needed to locate the block
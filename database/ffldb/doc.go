@@ -26,5 +26,11 @@ database path as a string and the block network:
 	if err != nil {
 		// Handle error
 	}
+
+Block Compression
+
+The transactions of the driver implement the database.BlockCompressor interface
+to optionally compress newly stored blocks with snappy.  Compressed blocks are
+decompressed transparently when they are fetched.
 */
 package ffldb
@@ -7,6 +7,7 @@
 package ffldb_test
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,6 +18,7 @@ import (
 	"github.com/james-ray/hcd/chaincfg"
 	"github.com/james-ray/hcd/database"
 	"github.com/james-ray/hcd/database/ffldb"
+	"github.com/james-ray/hcd/database/internal/dbtest"
 	"github.com/james-ray/hcd/hcutil"
 )
 
@@ -31,8 +33,8 @@ func TestCreateOpenFail(t *testing.T) {
 	// Ensure that attempting to open a database that doesn't exist returns
 	// the expected error.
 	wantErrCode := database.ErrDbDoesNotExist
-	_, err := database.Open(dbType, "noexist", blockDataNet)
-	if !checkDbError(t, "Open", err, wantErrCode) {
+	_, err := database.Open(dbType, "noexist", dbtest.BlockDataNet)
+	if !dbtest.CheckDbError(t, "Open", err, wantErrCode) {
 		return
 	}
 
@@ -51,7 +53,7 @@ func TestCreateOpenFail(t *testing.T) {
 	// the first parameter returns the expected error.
 	wantErr = fmt.Errorf("first argument to %s.Open is invalid -- "+
 		"expected database path string", dbType)
-	_, err = database.Open(dbType, 1, blockDataNet)
+	_, err = database.Open(dbType, 1, dbtest.BlockDataNet)
 	if err.Error() != wantErr.Error() {
 		t.Errorf("Open: did not receive expected error - got %v, "+
 			"want %v", err, wantErr)
@@ -84,7 +86,7 @@ func TestCreateOpenFail(t *testing.T) {
 	// the first parameter returns the expected error.
 	wantErr = fmt.Errorf("first argument to %s.Create is invalid -- "+
 		"expected database path string", dbType)
-	_, err = database.Create(dbType, 1, blockDataNet)
+	_, err = database.Create(dbType, 1, dbtest.BlockDataNet)
 	if err.Error() != wantErr.Error() {
 		t.Errorf("Create: did not receive expected error - got %v, "+
 			"want %v", err, wantErr)
@@ -106,7 +108,7 @@ func TestCreateOpenFail(t *testing.T) {
 	// error.
 	dbPath := filepath.Join(os.TempDir(), "ffldb-createfail")
 	_ = os.RemoveAll(dbPath)
-	db, err := database.Create(dbType, dbPath, blockDataNet)
+	db, err := database.Create(dbType, dbPath, dbtest.BlockDataNet)
 	if err != nil {
 		t.Errorf("Create: unexpected error: %v", err)
 		return
@@ -118,7 +120,7 @@ func TestCreateOpenFail(t *testing.T) {
 	err = db.View(func(tx database.Tx) error {
 		return nil
 	})
-	if !checkDbError(t, "View", err, wantErrCode) {
+	if !dbtest.CheckDbError(t, "View", err, wantErrCode) {
 		return
 	}
 
@@ -126,25 +128,25 @@ func TestCreateOpenFail(t *testing.T) {
 	err = db.Update(func(tx database.Tx) error {
 		return nil
 	})
-	if !checkDbError(t, "Update", err, wantErrCode) {
+	if !dbtest.CheckDbError(t, "Update", err, wantErrCode) {
 		return
 	}
 
 	wantErrCode = database.ErrDbNotOpen
 	_, err = db.Begin(false)
-	if !checkDbError(t, "Begin(false)", err, wantErrCode) {
+	if !dbtest.CheckDbError(t, "Begin(false)", err, wantErrCode) {
 		return
 	}
 
 	wantErrCode = database.ErrDbNotOpen
 	_, err = db.Begin(true)
-	if !checkDbError(t, "Begin(true)", err, wantErrCode) {
+	if !dbtest.CheckDbError(t, "Begin(true)", err, wantErrCode) {
 		return
 	}
 
 	wantErrCode = database.ErrDbNotOpen
 	err = db.Close()
-	if !checkDbError(t, "Close", err, wantErrCode) {
+	if !dbtest.CheckDbError(t, "Close", err, wantErrCode) {
 		return
 	}
 }
@@ -157,7 +159,7 @@ func TestPersistence(t *testing.T) {
 	// Create a new database to run tests against.
 	dbPath := filepath.Join(os.TempDir(), "ffldb-persistencetest")
 	_ = os.RemoveAll(dbPath)
-	db, err := database.Create(dbType, dbPath, blockDataNet)
+	db, err := database.Create(dbType, dbPath, dbtest.BlockDataNet)
 	if err != nil {
 		t.Errorf("Failed to create test database (%s) %v", dbType, err)
 		return
@@ -209,7 +211,7 @@ func TestPersistence(t *testing.T) {
 
 	// Close and reopen the database to ensure the values persist.
 	db.Close()
-	db, err = database.Open(dbType, dbPath, blockDataNet)
+	db, err = database.Open(dbType, dbPath, dbtest.BlockDataNet)
 	if err != nil {
 		t.Errorf("failed to open test database (%s) %v", dbType, err)
 		return
@@ -263,7 +265,7 @@ func TestInterface(t *testing.T) {
 	// Create a new database to run tests against.
 	dbPath := filepath.Join(os.TempDir(), "ffldb-interfacetest")
 	_ = os.RemoveAll(dbPath)
-	db, err := database.Create(dbType, dbPath, blockDataNet)
+	db, err := database.Create(dbType, dbPath, dbtest.BlockDataNet)
 	if err != nil {
 		t.Errorf("failed to create test database (%s) %v", dbType, err)
 		return
@@ -285,6 +287,314 @@ func TestInterface(t *testing.T) {
 	// Change the maximum file size to a small value to force multiple flat
 	// files with the test data set.
 	ffldb.TstRunWithMaxBlockFileSize(db, 2048, func() {
-		testInterface(t, db)
+		dbtest.TestInterface(t, db)
 	})
 }
+
+// TestBackup ensures a backup contains the database as it was when the
+// transaction was started, matches its manifest, and can be opened.
+func TestBackup(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-backuptest")
+	backupPath := filepath.Join(os.TempDir(), "ffldb-backuptest-backup")
+	_ = os.RemoveAll(dbPath)
+	_ = os.RemoveAll(backupPath)
+	db, err := database.Create(dbType, dbPath, dbtest.BlockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer os.RemoveAll(backupPath)
+	defer db.Close()
+
+	blocks, err := dbtest.LoadBlocks(t, dbtest.BlockDataFile, dbtest.BlockDataNet)
+	if err != nil {
+		t.Fatalf("dbtest.LoadBlocks: unexpected error: %v", err)
+	}
+	if len(blocks) < 2 {
+		t.Fatalf("dbtest.LoadBlocks: not enough blocks")
+	}
+
+	// Store all but the last block along with a key.
+	keyName := []byte("backupkey")
+	lastBlock := blocks[len(blocks)-1]
+	err = db.Update(func(tx database.Tx) error {
+		for _, block := range blocks[:len(blocks)-1] {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return tx.Metadata().Put(keyName, []byte("before"))
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	// Start the backup transaction and make changes that must not be part
+	// of the backup before performing it.
+	tx, err := db.Begin(false)
+	if err != nil {
+		t.Fatalf("Begin: unexpected error: %v", err)
+	}
+	err = db.Update(func(tx database.Tx) error {
+		if err := tx.StoreBlock(lastBlock); err != nil {
+			return err
+		}
+		return tx.Metadata().Put(keyName, []byte("after"))
+	})
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	manifest, err := tx.(database.Backuper).Backup(backupPath)
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("Backup: unexpected error: %v", err)
+	}
+
+	// Ensure the backup directory is not overwritten.
+	_, err = tx.(database.Backuper).Backup(backupPath)
+	if !dbtest.CheckDbError(t, "Backup", err, database.ErrDbExists) {
+		tx.Rollback()
+		return
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: unexpected error: %v", err)
+	}
+
+	// Ensure the backup matches its manifest.
+	gotManifest, err := database.VerifyBackup(backupPath)
+	if err != nil {
+		t.Fatalf("VerifyBackup: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(gotManifest, manifest) {
+		t.Fatalf("VerifyBackup: mismatched manifest - got %+v, want %+v",
+			gotManifest, manifest)
+	}
+	if manifest.DbType != dbType || manifest.Network != uint32(dbtest.BlockDataNet) {
+		t.Fatalf("Backup: unexpected manifest %+v", manifest)
+	}
+
+	// Ensure the backup can be opened and only has the data from before
+	// the backup transaction was started.
+	backupDb, err := database.Open(dbType, backupPath, dbtest.BlockDataNet)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer backupDb.Close()
+	err = backupDb.View(func(tx database.Tx) error {
+		if val := tx.Metadata().Get(keyName); string(val) != "before" {
+			return fmt.Errorf("unexpected value %q", val)
+		}
+		for _, block := range blocks[:len(blocks)-1] {
+			if _, err := tx.FetchBlock(block.Hash()); err != nil {
+				return err
+			}
+		}
+		hasBlock, err := tx.HasBlock(lastBlock.Hash())
+		if err != nil {
+			return err
+		}
+		if hasBlock {
+			return fmt.Errorf("block %s stored after the backup "+
+				"started is in the backup", lastBlock.Hash())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+}
+
+// TestBlockCompression ensures blocks stored with and without compression can
+// be fetched as a whole and in regions, also after the database is reopened.
+func TestBlockCompression(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-compressiontest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, dbtest.BlockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer func() {
+		db.Close()
+	}()
+
+	blocks, err := dbtest.LoadBlocks(t, dbtest.BlockDataFile, dbtest.BlockDataNet)
+	if err != nil {
+		t.Fatalf("dbtest.LoadBlocks: unexpected error: %v", err)
+	}
+	if len(blocks) < 2 {
+		t.Fatalf("dbtest.LoadBlocks: not enough blocks")
+	}
+
+	// Blocks are not compressed by default and the setting can't be
+	// changed by read-only transactions.
+	err = db.View(func(tx database.Tx) error {
+		compressor := tx.(database.BlockCompressor)
+		compression, err := compressor.BlockCompression()
+		if err != nil {
+			return err
+		}
+		if compression != database.BlockCompressionNone {
+			return fmt.Errorf("unexpected default compression %v",
+				compression)
+		}
+		err = compressor.SetBlockCompression(database.BlockCompressionSnappy)
+		if !dbtest.CheckDbError(t, "SetBlockCompression", err,
+			database.ErrTxNotWritable) {
+
+			return fmt.Errorf("unexpected error %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+
+	// Store the first half of the blocks compressed, including the blocks
+	// stored by the transaction enabling compression, and the rest
+	// uncompressed.
+	half := len(blocks) / 2
+	storeBlocks := func(compression database.BlockCompression, blocks []*hcutil.Block) error {
+		return db.Update(func(tx database.Tx) error {
+			compressor := tx.(database.BlockCompressor)
+			err := compressor.SetBlockCompression(compression)
+			if err != nil {
+				return err
+			}
+			for _, block := range blocks {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err := storeBlocks(database.BlockCompressionSnappy, blocks[:half]); err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	if err := storeBlocks(database.BlockCompressionNone, blocks[half:]); err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	// checkBlocks ensures all blocks are stored with the expected
+	// compression and can be fetched.
+	checkBlocks := func() error {
+		return db.View(func(tx database.Tx) error {
+			var numCompressed int
+			var regions []database.BlockRegion
+			for i, block := range blocks {
+				compressed, err := ffldb.TstBlockCompressed(tx,
+					block.Hash())
+				if err != nil {
+					return err
+				}
+				if compressed {
+					numCompressed++
+					if i >= half {
+						return fmt.Errorf("block %s is "+
+							"compressed", block.Hash())
+					}
+				}
+
+				wantBytes, err := block.Bytes()
+				if err != nil {
+					return err
+				}
+				gotBytes, err := tx.FetchBlock(block.Hash())
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(gotBytes, wantBytes) {
+					return fmt.Errorf("mismatched bytes for "+
+						"block %s", block.Hash())
+				}
+
+				// Fetch the last bytes of the block both
+				// individually and in bulk.
+				region := database.BlockRegion{
+					Hash:   block.Hash(),
+					Offset: uint32(len(wantBytes) - 10),
+					Len:    10,
+				}
+				gotBytes, err = tx.FetchBlockRegion(&region)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(gotBytes, wantBytes[region.Offset:]) {
+					return fmt.Errorf("mismatched region for "+
+						"block %s", block.Hash())
+				}
+				headerRegion := region
+				headerRegion.Offset, headerRegion.Len = 0, 10
+				regions = append(regions, region, headerRegion)
+
+				// Ensure compressed blocks are cached once
+				// decompressed for fetching their regions and
+				// that regions past their end are rejected.
+				if !compressed {
+					continue
+				}
+				if !ffldb.TstDecompressedBlockCached(db, block.Hash()) {
+					return fmt.Errorf("decompressed block %s "+
+						"is not cached", block.Hash())
+				}
+				region.Len++
+				_, err = tx.FetchBlockRegion(&region)
+				if !dbtest.CheckDbError(t, "FetchBlockRegion", err,
+					database.ErrBlockRegionInvalid) {
+
+					return fmt.Errorf("unexpected error %v", err)
+				}
+				_, err = tx.FetchBlockRegions([]database.BlockRegion{region})
+				if !dbtest.CheckDbError(t, "FetchBlockRegions", err,
+					database.ErrBlockRegionInvalid) {
+
+					return fmt.Errorf("unexpected error %v", err)
+				}
+			}
+			if numCompressed == 0 {
+				return fmt.Errorf("no blocks are compressed")
+			}
+
+			gotRegions, err := tx.FetchBlockRegions(regions)
+			if err != nil {
+				return err
+			}
+			for i := range regions {
+				wantBytes, err := blocks[i/2].Bytes()
+				if err != nil {
+					return err
+				}
+				offset := regions[i].Offset
+				wantBytes = wantBytes[offset : offset+regions[i].Len]
+				if !bytes.Equal(gotRegions[i], wantBytes) {
+					return fmt.Errorf("mismatched bulk region "+
+						"#%d", i)
+				}
+			}
+			return nil
+		})
+	}
+	if err := checkBlocks(); err != nil {
+		t.Fatalf("checkBlocks: %v", err)
+	}
+
+	// Ensure the blocks can still be fetched after reopening the database.
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	db, err = database.Open(dbType, dbPath, dbtest.BlockDataNet)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	if err := checkBlocks(); err != nil {
+		t.Fatalf("checkBlocks after reopen: %v", err)
+	}
+}
@@ -12,7 +12,10 @@ The functions are only exported while the tests are being run.
 
 package ffldb
 
-import "github.com/james-ray/hcd/database"
+import (
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/database"
+)
 
 // TstRunWithMaxBlockFileSize runs the passed function with the maximum allowed
 // file size for the database set to the provided value.  The value will be set
@@ -25,3 +28,19 @@ func TstRunWithMaxBlockFileSize(idb database.DB, size uint32, fn func()) {
 	fn()
 	ffldb.store.maxBlockFileSize = origSize
 }
+
+// TstBlockCompressed returns whether or not the block with the passed hash is
+// stored compressed according to its location in the block index.
+func TstBlockCompressed(dbTx database.Tx, hash *chainhash.Hash) (bool, error) {
+	blockRow, err := dbTx.(*transaction).fetchBlockRow(hash)
+	if err != nil {
+		return false, err
+	}
+	return deserializeBlockLoc(blockRow).compressed, nil
+}
+
+// TstDecompressedBlockCached returns whether or not the block with the passed
+// hash is in the cache of recently decompressed blocks.
+func TstDecompressedBlockCached(idb database.DB, hash *chainhash.Hash) bool {
+	return idb.(*db).store.decompressedBlocks.lookup(hash) != nil
+}
@@ -3,15 +3,11 @@
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
-// This file intended to be copied into each backend driver directory.  Each
-// driver should have their own driver_test.go file which creates a database and
-// invokes the testInterface function in this file to ensure the driver properly
-// implements the interface.
-//
-// NOTE: When copying this file into the backend driver folder, the package name
-// will need to be changed accordingly.
-
-package ffldb_test
+// Package dbtest provides the test suite shared by the backend drivers to
+// ensure they properly implement the database interface.  Each driver should
+// have its own driver_test.go file which creates a database and invokes
+// TestInterface with it.
+package dbtest
 
 import (
 	"bytes"
@@ -32,20 +28,22 @@ import (
 )
 
 var (
-	// blockDataNet is the expected network in the test block data.
-	blockDataNet = wire.SimNet
+	// BlockDataNet is the expected network in the test block data.
+	BlockDataNet = wire.SimNet
 
-	// blockDataFile is the path to a file containing the first 168 blocks
-	// of the simulation network.
-	blockDataFile = filepath.Join("..", "blockchain", "testdata", "blocks0to168.bz2")
+	// BlockDataFile is the path to a file containing the first 168 blocks
+	// of the simulation network.  It is relative to the directories of
+	// the backend drivers since tests run in the directory of the package
+	// under test.
+	BlockDataFile = filepath.Join("..", "..", "blockchain", "testdata", "blocks0to168.bz2")
 
 	// errSubTestFail is used to signal that a sub test returned false.
 	errSubTestFail = fmt.Errorf("sub test failure")
 )
 
-// loadBlocks loads the blocks contained in the testdata directory and returns
-// a slice of them.
-func loadBlocks(t *testing.T, dataFile string, network wire.CurrencyNet) ([]*hcutil.Block, error) {
+// LoadBlocks loads the blocks contained in the passed data file and returns a
+// slice of them.
+func LoadBlocks(t *testing.T, dataFile string, network wire.CurrencyNet) ([]*hcutil.Block, error) {
 	// Open the file that contains the blocks for reading.
 	fi, err := os.Open(dataFile)
 	if err != nil {
@@ -88,9 +86,9 @@ func loadBlocks(t *testing.T, dataFile string, network wire.CurrencyNet) ([]*hcu
 	return blocks, nil
 }
 
-// checkDbError ensures the passed error is a database.Error with an error code
+// CheckDbError ensures the passed error is a database.Error with an error code
 // that matches the passed  error code.
-func checkDbError(t *testing.T, testName string, gotErr error, wantErrCode database.ErrorCode) bool {
+func CheckDbError(t *testing.T, testName string, gotErr error, wantErrCode database.ErrorCode) bool {
 	dbErr, ok := gotErr.(database.Error)
 	if !ok {
 		t.Errorf("%s: unexpected error type - got %T, want %T",
@@ -480,7 +478,7 @@ func testBucketInterface(tc *testContext, bucket database.Bucket) bool {
 		// expected error.
 		wantErrCode := database.ErrBucketExists
 		_, err = bucket.CreateBucket(testBucketName)
-		if !checkDbError(tc.t, "CreateBucket", err, wantErrCode) {
+		if !CheckDbError(tc.t, "CreateBucket", err, wantErrCode) {
 			return false
 		}
 
@@ -516,7 +514,7 @@ func testBucketInterface(tc *testContext, bucket database.Bucket) bool {
 		// expected error.
 		wantErrCode = database.ErrBucketNotFound
 		err = bucket.DeleteBucket(testBucketName)
-		if !checkDbError(tc.t, "DeleteBucket", err, wantErrCode) {
+		if !CheckDbError(tc.t, "DeleteBucket", err, wantErrCode) {
 			return false
 		}
 
@@ -554,21 +552,21 @@ func testBucketInterface(tc *testContext, bucket database.Bucket) bool {
 		wantErrCode := database.ErrTxNotWritable
 		failBytes := []byte("fail")
 		err := bucket.Put(failBytes, failBytes)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
 		// Delete should fail with bucket that is not writable.
 		testName = "unwritable tx delete"
 		err = bucket.Delete(failBytes)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
 		// CreateBucket should fail with bucket that is not writable.
 		testName = "unwritable tx create bucket"
 		_, err = bucket.CreateBucket(failBytes)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
@@ -576,14 +574,14 @@ func testBucketInterface(tc *testContext, bucket database.Bucket) bool {
 		// writable.
 		testName = "unwritable tx create bucket if not exists"
 		_, err = bucket.CreateBucketIfNotExists(failBytes)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
 		// DeleteBucket should fail with bucket that is not writable.
 		testName = "unwritable tx delete bucket"
 		err = bucket.DeleteBucket(failBytes)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
@@ -657,7 +655,7 @@ func testMetadataManualTxInterface(tc *testContext) bool {
 			testName := "unwritable tx commit"
 			wantErrCode := database.ErrTxNotWritable
 			err := tx.Commit()
-			if !checkDbError(tc.t, testName, err, wantErrCode) {
+			if !CheckDbError(tc.t, testName, err, wantErrCode) {
 				_ = tx.Rollback()
 				return false
 			}
@@ -1109,7 +1107,7 @@ func testFetchBlockIOMissing(tc *testContext, tx database.Tx) bool {
 		// Ensure FetchBlock returns expected error.
 		testName := fmt.Sprintf("FetchBlock #%d on missing block", i)
 		_, err = tx.FetchBlock(blockHash)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
@@ -1117,7 +1115,7 @@ func testFetchBlockIOMissing(tc *testContext, tx database.Tx) bool {
 		testName = fmt.Sprintf("FetchBlockHeader #%d on missing block",
 			i)
 		_, err = tx.FetchBlockHeader(blockHash)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
@@ -1130,7 +1128,7 @@ func testFetchBlockIOMissing(tc *testContext, tx database.Tx) bool {
 		}
 		allBlockRegions[i] = region
 		_, err = tx.FetchBlockRegion(&region)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
@@ -1153,21 +1151,21 @@ func testFetchBlockIOMissing(tc *testContext, tx database.Tx) bool {
 	// Ensure FetchBlocks returns expected error.
 	testName := "FetchBlocks on missing blocks"
 	_, err := tx.FetchBlocks(allBlockHashes)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
 	// Ensure FetchBlockHeaders returns expected error.
 	testName = "FetchBlockHeaders on missing blocks"
 	_, err = tx.FetchBlockHeaders(allBlockHashes)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
 	// Ensure FetchBlockRegions returns expected error.
 	testName = "FetchBlockRegions on missing blocks"
 	_, err = tx.FetchBlockRegions(allBlockRegions)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
@@ -1300,7 +1298,7 @@ func testFetchBlockIO(tc *testContext, tx database.Tx) bool {
 			badBlockHash)
 		wantErrCode := database.ErrBlockNotFound
 		_, err = tx.FetchBlock(badBlockHash)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
@@ -1309,7 +1307,7 @@ func testFetchBlockIO(tc *testContext, tx database.Tx) bool {
 		testName = fmt.Sprintf("FetchBlockHeader(%s) invalid block",
 			badBlockHash)
 		_, err = tx.FetchBlockHeader(badBlockHash)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
@@ -1321,7 +1319,7 @@ func testFetchBlockIO(tc *testContext, tx database.Tx) bool {
 		region.Hash = badBlockHash
 		region.Offset = ^uint32(0)
 		_, err = tx.FetchBlockRegion(&region)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
@@ -1333,7 +1331,7 @@ func testFetchBlockIO(tc *testContext, tx database.Tx) bool {
 		region.Hash = blockHash
 		region.Offset = ^uint32(0)
 		_, err = tx.FetchBlockRegion(&region)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 	}
@@ -1443,7 +1441,7 @@ func testFetchBlockIO(tc *testContext, tx database.Tx) bool {
 	badBlockHashes[len(badBlockHashes)-1] = chainhash.Hash{}
 	wantErrCode := database.ErrBlockNotFound
 	_, err = tx.FetchBlocks(badBlockHashes)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
@@ -1451,7 +1449,7 @@ func testFetchBlockIO(tc *testContext, tx database.Tx) bool {
 	// expected error.
 	testName = "FetchBlockHeaders invalid hash"
 	_, err = tx.FetchBlockHeaders(badBlockHashes)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
@@ -1463,7 +1461,7 @@ func testFetchBlockIO(tc *testContext, tx database.Tx) bool {
 	badBlockRegions[len(badBlockRegions)-1].Hash = &chainhash.Hash{}
 	wantErrCode = database.ErrBlockNotFound
 	_, err = tx.FetchBlockRegions(badBlockRegions)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
@@ -1476,7 +1474,7 @@ func testFetchBlockIO(tc *testContext, tx database.Tx) bool {
 	}
 	wantErrCode = database.ErrBlockRegionInvalid
 	_, err = tx.FetchBlockRegions(badBlockRegions)
-	return checkDbError(tc.t, testName, err, wantErrCode)
+	return CheckDbError(tc.t, testName, err, wantErrCode)
 }
 
 // testBlockIOTxInterface ensures that the block IO interface works as expected
@@ -1490,7 +1488,7 @@ func testBlockIOTxInterface(tc *testContext) bool {
 		for i, block := range tc.blocks {
 			testName := fmt.Sprintf("StoreBlock(%d) on ro tx", i)
 			err := tx.StoreBlock(block)
-			if !checkDbError(tc.t, testName, err, wantErrCode) {
+			if !CheckDbError(tc.t, testName, err, wantErrCode) {
 				return errSubTestFail
 			}
 		}
@@ -1527,7 +1525,7 @@ func testBlockIOTxInterface(tc *testContext) bool {
 			testName := fmt.Sprintf("duplicate block entry #%d "+
 				"(before commit)", i)
 			err := tx.StoreBlock(block)
-			if !checkDbError(tc.t, testName, err, wantErrCode) {
+			if !CheckDbError(tc.t, testName, err, wantErrCode) {
 				return errSubTestFail
 			}
 		}
@@ -1585,7 +1583,7 @@ func testBlockIOTxInterface(tc *testContext) bool {
 				"(before commit)", i)
 			wantErrCode := database.ErrBlockExists
 			err := tx.StoreBlock(block)
-			if !checkDbError(tc.t, testName, err, wantErrCode) {
+			if !CheckDbError(tc.t, testName, err, wantErrCode) {
 				return errSubTestFail
 			}
 		}
@@ -1639,7 +1637,7 @@ func testBlockIOTxInterface(tc *testContext) bool {
 			testName := fmt.Sprintf("duplicate block entry #%d "+
 				"(before commit)", i)
 			err := tx.StoreBlock(block)
-			if !checkDbError(tc.t, testName, err, wantErrCode) {
+			if !CheckDbError(tc.t, testName, err, wantErrCode) {
 				return errSubTestFail
 			}
 		}
@@ -1679,42 +1677,42 @@ func testClosedTxInterface(tc *testContext, tx database.Tx) bool {
 	// Ensure CreateBucket returns expected error.
 	testName := "CreateBucket on closed tx"
 	_, err := bucket.CreateBucket(bucketName)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
 	// Ensure CreateBucketIfNotExists returns expected error.
 	testName = "CreateBucketIfNotExists on closed tx"
 	_, err = bucket.CreateBucketIfNotExists(bucketName)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
 	// Ensure Delete returns expected error.
 	testName = "Delete on closed tx"
 	err = bucket.Delete(keyName)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
 	// Ensure DeleteBucket returns expected error.
 	testName = "DeleteBucket on closed tx"
 	err = bucket.DeleteBucket(bucketName)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
 	// Ensure ForEach returns expected error.
 	testName = "ForEach on closed tx"
 	err = bucket.ForEach(nil)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
 	// Ensure ForEachBucket returns expected error.
 	testName = "ForEachBucket on closed tx"
 	err = bucket.ForEachBucket(nil)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
@@ -1728,7 +1726,7 @@ func testClosedTxInterface(tc *testContext, tx database.Tx) bool {
 	// Ensure Put returns expected error.
 	testName = "Put on closed tx"
 	err = bucket.Put(keyName, []byte("test"))
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
@@ -1746,7 +1744,7 @@ func testClosedTxInterface(tc *testContext, tx database.Tx) bool {
 	// Ensure Cursor.Delete returns expected error.
 	testName = "Cursor.Delete on closed tx"
 	err = cursor.Delete()
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
@@ -1828,21 +1826,21 @@ func testClosedTxInterface(tc *testContext, tx database.Tx) bool {
 		// Ensure StoreBlock returns expected error.
 		testName = "StoreBlock on closed tx"
 		err = tx.StoreBlock(block)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
 		// Ensure FetchBlock returns expected error.
 		testName = fmt.Sprintf("FetchBlock #%d on closed tx", i)
 		_, err = tx.FetchBlock(blockHash)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
 		// Ensure FetchBlockHeader returns expected error.
 		testName = fmt.Sprintf("FetchBlockHeader #%d on closed tx", i)
 		_, err = tx.FetchBlockHeader(blockHash)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
@@ -1855,14 +1853,14 @@ func testClosedTxInterface(tc *testContext, tx database.Tx) bool {
 		}
 		allBlockRegions[i] = region
 		_, err = tx.FetchBlockRegion(&region)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 
 		// Ensure HasBlock returns expected error.
 		testName = fmt.Sprintf("HasBlock #%d on closed tx", i)
 		_, err = tx.HasBlock(blockHash)
-		if !checkDbError(tc.t, testName, err, wantErrCode) {
+		if !CheckDbError(tc.t, testName, err, wantErrCode) {
 			return false
 		}
 	}
@@ -1874,28 +1872,28 @@ func testClosedTxInterface(tc *testContext, tx database.Tx) bool {
 	// Ensure FetchBlocks returns expected error.
 	testName = "FetchBlocks on closed tx"
 	_, err = tx.FetchBlocks(allBlockHashes)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
 	// Ensure FetchBlockHeaders returns expected error.
 	testName = "FetchBlockHeaders on closed tx"
 	_, err = tx.FetchBlockHeaders(allBlockHashes)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
 	// Ensure FetchBlockRegions returns expected error.
 	testName = "FetchBlockRegions on closed tx"
 	_, err = tx.FetchBlockRegions(allBlockRegions)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
 	// Ensure HasBlocks returns expected error.
 	testName = "HasBlocks on closed tx"
 	_, err = tx.HasBlocks(allBlockHashes)
-	if !checkDbError(tc.t, testName, err, wantErrCode) {
+	if !CheckDbError(tc.t, testName, err, wantErrCode) {
 		return false
 	}
 
@@ -1906,11 +1904,11 @@ func testClosedTxInterface(tc *testContext, tx database.Tx) bool {
 	// Ensure that attempting to rollback or commit a transaction that is
 	// already closed returns the expected error.
 	err = tx.Rollback()
-	if !checkDbError(tc.t, "closed tx rollback", err, wantErrCode) {
+	if !CheckDbError(tc.t, "closed tx rollback", err, wantErrCode) {
 		return false
 	}
 	err = tx.Commit()
-	return checkDbError(tc.t, "closed tx commit", err, wantErrCode)
+	return CheckDbError(tc.t, "closed tx commit", err, wantErrCode)
 }
 
 // testTxClosed ensures that both the metadata and block IO API functions behave
@@ -2229,17 +2227,17 @@ func testConcurrentClose(tc *testContext) bool {
 	return true
 }
 
-// testInterface tests performs tests for the various interfaces of the database
+// TestInterface performs tests for the various interfaces of the database
 // package which require state in the database for the given database type.
-func testInterface(t *testing.T, db database.DB) {
+func TestInterface(t *testing.T, db database.DB) {
 	// Create a test context to pass around.
 	context := testContext{t: t, db: db}
 
 	// Load the test blocks and store in the test context for use throughout
 	// the tests.
-	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	blocks, err := LoadBlocks(t, BlockDataFile, BlockDataNet)
 	if err != nil {
-		t.Errorf("loadBlocks: Unexpected error: %v", err)
+		t.Errorf("LoadBlocks: Unexpected error: %v", err)
 		return
 	}
 	context.blocks = blocks
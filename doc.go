@@ -70,19 +70,40 @@ Application Options:
       --onionuser=          Username for onion proxy server
       --onionpass=          Password for onion proxy server
       --noonion             Disable connecting to tor hidden services
+      --i2p=                Connect to I2P destinations via SOCKS5 proxy
+                            (eg. 127.0.0.1:4447)
+      --nov2transport       Disable the encrypted v2 P2P transport
       --torisolation        Enable Tor stream isolation by randomizing user
                             credentials for each connection.
       --testnet             Use the test network
       --simnet              Use the simulation test network
+      --regnet              Use the regression test network
+      --chainparams=        Use the custom network described by the specified
+                            JSON parameters file
       --nocheckpoints       Disable built-in checkpoints.  Don't do this unless
                             you know what you're doing.
       --dbtype=             Database backend to use for the Block Chain (ffldb)
+      --blockcompression=   Compression for newly stored blocks {none, snappy}
+                            -- only supported by ffldb (keep the stored setting)
       --profile=            Enable HTTP profiling on given [addr:]port -- NOTE: port
                             must be between 1024 and 65536
       --cpuprofile=         Write CPU profile to the specified file
       --memprofile=         Write mem profile to the specified file
-      --dumpblockchain=     Write blockchain as a gob-encoded map to the
-                            specified file
+      --dumpblockchain=     Write blockchain as a bootstrap file of blocks for
+                            use with importbootstrap or addblock, to the
+                            specified filename
+      --importbootstrap=    Import the blocks of the specified bootstrap file
+                            before starting the server -- an interrupted
+                            import resumes when the file is imported again
+      --reindex             Rebuild the chain state and stake database from
+                            the stored blocks on start up and drop the
+                            optional indexes so the enabled ones are rebuilt
+                            -- disabled indexes are not rebuilt and an
+                            interrupted reindex resumes on the next start
+      --reindex-chainstate  Rebuild the chain state and stake database from
+                            the stored blocks on start up, keeping the
+                            optional indexes -- an interrupted reindex
+                            resumes on the next start
       --miningtimeoffset=   Offset the mining timestamp of a block by this many
                             seconds (positive values are in the past)
   -d, --debuglevel=         Logging level for all subsystems {trace, debug,
@@ -0,0 +1,172 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/james-ray/hcd/addrmgr"
+)
+
+const (
+	// evictProtectNetGroups is the number of inbound peers in distinct net
+	// groups which are protected from eviction.  The net groups are
+	// selected using a secret key so an attacker can't predict which ones
+	// are protected.
+	evictProtectNetGroups = 4
+
+	// evictProtectPing is the number of inbound peers with the lowest ping
+	// times which are protected from eviction.
+	evictProtectPing = 8
+
+	// evictProtectVotes is the number of inbound peers which most recently
+	// relayed novel votes which are protected from eviction.
+	evictProtectVotes = 4
+
+	// evictProtectTxns is the number of inbound peers which most recently
+	// relayed novel transactions which are protected from eviction.
+	evictProtectTxns = 4
+
+	// evictProtectBlocks is the number of inbound peers which most recently
+	// relayed novel blocks which are protected from eviction.
+	evictProtectBlocks = 4
+)
+
+// evictionCandidate houses the details of an inbound peer which are used to
+// select the peer to evict when all inbound slots are taken.
+type evictionCandidate struct {
+	sp            *serverPeer
+	netGroup      string
+	netGroupHash  uint64
+	connTime      time.Time
+	pingMicros    int64
+	lastBlockTime int64
+	lastTxTime    int64
+	lastVoteTime  int64
+}
+
+// newEvictionCandidate returns the eviction candidate for the passed peer
+// using the passed key to hash its net group.
+func newEvictionCandidate(sp *serverPeer, netGroupKey []byte) evictionCandidate {
+	stats := sp.StatsSnapshot()
+	netGroup := addrmgr.GroupKey(sp.NA())
+	hash := sha256.Sum256(append(netGroupKey[:len(netGroupKey):len(netGroupKey)],
+		netGroup...))
+	return evictionCandidate{
+		sp:            sp,
+		netGroup:      netGroup,
+		netGroupHash:  binary.LittleEndian.Uint64(hash[:]),
+		connTime:      stats.ConnTime,
+		pingMicros:    stats.LastPingMicros,
+		lastBlockTime: atomic.LoadInt64(&sp.lastBlockTime),
+		lastTxTime:    atomic.LoadInt64(&sp.lastTxTime),
+		lastVoteTime:  atomic.LoadInt64(&sp.lastVoteTime),
+	}
+}
+
+// protectCandidates removes up to n of the passed candidates which are the
+// first ones when sorted by the passed less function and returns the remaining
+// candidates.  Only candidates which are eligible according to the passed
+// function are removed.
+func protectCandidates(candidates []evictionCandidate, n int,
+	less func(a, b *evictionCandidate) bool,
+	eligible func(c *evictionCandidate) bool) []evictionCandidate {
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return less(&candidates[i], &candidates[j])
+	})
+	protected := 0
+	remaining := candidates[:0]
+	for i := range candidates {
+		if protected < n && eligible(&candidates[i]) {
+			protected++
+			continue
+		}
+		remaining = append(remaining, candidates[i])
+	}
+	return remaining
+}
+
+// selectPeerToEvict returns the peer to evict from the passed inbound peers or
+// nil when all of them are protected.  Peers which are useful to us, such as
+// the ones which recently relayed novel blocks, transactions and votes or have
+// low latency, and peers from diverse net groups are protected first so an
+// attacker has to excel in all of those areas in order to take over all of the
+// inbound slots.  Half of the remaining peers, which are the ones which have
+// been connected the longest, are protected as well.  Of the rest, the newest
+// peer of the net group with the most peers is selected.
+func selectPeerToEvict(candidates []evictionCandidate) *serverPeer {
+	always := func(c *evictionCandidate) bool { return true }
+
+	candidates = protectCandidates(candidates, evictProtectNetGroups,
+		func(a, b *evictionCandidate) bool {
+			return a.netGroupHash > b.netGroupHash
+		}, always)
+	candidates = protectCandidates(candidates, evictProtectPing,
+		func(a, b *evictionCandidate) bool {
+			return a.pingMicros < b.pingMicros
+		}, func(c *evictionCandidate) bool {
+			return c.pingMicros > 0
+		})
+	candidates = protectCandidates(candidates, evictProtectVotes,
+		func(a, b *evictionCandidate) bool {
+			return a.lastVoteTime > b.lastVoteTime
+		}, func(c *evictionCandidate) bool {
+			return c.lastVoteTime != 0
+		})
+	candidates = protectCandidates(candidates, evictProtectTxns,
+		func(a, b *evictionCandidate) bool {
+			return a.lastTxTime > b.lastTxTime
+		}, func(c *evictionCandidate) bool {
+			return c.lastTxTime != 0
+		})
+	candidates = protectCandidates(candidates, evictProtectBlocks,
+		func(a, b *evictionCandidate) bool {
+			return a.lastBlockTime > b.lastBlockTime
+		}, func(c *evictionCandidate) bool {
+			return c.lastBlockTime != 0
+		})
+	candidates = protectCandidates(candidates, len(candidates)/2,
+		func(a, b *evictionCandidate) bool {
+			return a.connTime.Before(b.connTime)
+		}, always)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Find the net group with the most peers, preferring the one with the
+	// newest peer in case of a tie, along with its newest peer.
+	type netGroupInfo struct {
+		count  int
+		newest *evictionCandidate
+	}
+	netGroups := make(map[string]*netGroupInfo)
+	var evictGroup *netGroupInfo
+	for i := range candidates {
+		c := &candidates[i]
+		info, ok := netGroups[c.netGroup]
+		if !ok {
+			info = &netGroupInfo{newest: c}
+			netGroups[c.netGroup] = info
+		}
+		info.count++
+		if c.connTime.After(info.newest.connTime) {
+			info.newest = c
+		}
+	}
+	for _, info := range netGroups {
+		if evictGroup == nil || info.count > evictGroup.count ||
+			(info.count == evictGroup.count &&
+				info.newest.connTime.After(evictGroup.newest.connTime)) {
+
+			evictGroup = info
+		}
+	}
+	return evictGroup.newest.sp
+}
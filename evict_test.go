@@ -0,0 +1,80 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestSelectPeerToEvict ensures the peer to evict is selected from the net
+// group with the most peers and that useful peers are protected.
+func TestSelectPeerToEvict(t *testing.T) {
+	now := time.Now()
+
+	// Too few peers to evict any since all of them are protected.
+	var candidates []evictionCandidate
+	for i := 0; i < evictProtectNetGroups; i++ {
+		candidates = append(candidates, evictionCandidate{
+			sp:           &serverPeer{},
+			netGroup:     fmt.Sprintf("10.%d", i),
+			netGroupHash: uint64(i),
+			connTime:     now,
+		})
+	}
+	if sp := selectPeerToEvict(candidates); sp != nil {
+		t.Fatal("selectPeerToEvict: unexpectedly selected a peer")
+	}
+
+	// An attacker fills the inbound slots from a single net group with
+	// slow peers which don't relay anything while a few honest peers
+	// recently relayed blocks, transactions and votes.
+	var honest []*serverPeer
+	candidates = nil
+	for i := 0; i < 4; i++ {
+		sp := &serverPeer{}
+		honest = append(honest, sp)
+		c := evictionCandidate{
+			sp:           sp,
+			netGroup:     fmt.Sprintf("10.%d", i),
+			netGroupHash: 0,
+			connTime:     now,
+		}
+		switch i {
+		case 0:
+			c.lastBlockTime = now.UnixNano()
+		case 1:
+			c.lastTxTime = now.UnixNano()
+		case 2:
+			c.lastVoteTime = now.UnixNano()
+		case 3:
+			c.pingMicros = 100
+		}
+		candidates = append(candidates, c)
+	}
+	var newest *serverPeer
+	for i := 0; i < 40; i++ {
+		sp := &serverPeer{}
+		newest = sp
+		candidates = append(candidates, evictionCandidate{
+			sp:           sp,
+			netGroup:     "192.168",
+			netGroupHash: 1,
+			connTime:     now.Add(time.Duration(i) * time.Second),
+			pingMicros:   100000 + int64(i),
+		})
+	}
+	sp := selectPeerToEvict(candidates)
+	for _, h := range honest {
+		if sp == h {
+			t.Fatal("selectPeerToEvict: selected a protected peer")
+		}
+	}
+	if sp != newest {
+		t.Fatal("selectPeerToEvict: did not select the newest peer of " +
+			"the largest net group")
+	}
+}
@@ -14,9 +14,12 @@ import (
 	"runtime"
 	"runtime/debug"
 	"runtime/pprof"
+	"strings"
 	"time"
 
+	"github.com/james-ray/hcd/blockchain"
 	"github.com/james-ray/hcd/blockchain/indexers"
+	"github.com/james-ray/hcd/database"
 	"github.com/james-ray/hcd/limits"
 )
 
@@ -164,6 +167,92 @@ func hcdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropTicketIndex {
+		if err := indexers.DropTicketIndex(db); err != nil {
+			hcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+
+	// Rebuild the chain state from the stored blocks before starting the
+	// server if requested.  A previously interrupted reindex is always
+	// resumed since the chain state is incomplete until it finishes.
+	//
+	// NOTE: The optional indexes are dropped for a full reindex so the
+	// enabled ones are rebuilt from scratch when the server starts, while
+	// the disabled ones are not recreated.  Dropping the tx index also drops
+	// the address index since it relies on it.
+	reindexPending, err := blockchain.IsReindexPending(db)
+	if err != nil {
+		hcdLog.Errorf("%v", err)
+		return err
+	}
+	if cfg.Reindex {
+		// Warn about the indexes that are dropped but not rebuilt since
+		// they are not enabled.
+		var disabledIndexes []string
+		if !cfg.TxIndex {
+			disabledIndexes = append(disabledIndexes, "transaction")
+		}
+		if !cfg.AddrIndex {
+			disabledIndexes = append(disabledIndexes, "address")
+		}
+		if cfg.NoExistsAddrIndex {
+			disabledIndexes = append(disabledIndexes, "exists address")
+		}
+		if !cfg.TicketIndex {
+			disabledIndexes = append(disabledIndexes, "ticket")
+		}
+		hcdLog.Infof("Dropping the optional indexes to rebuild them " +
+			"after the reindex")
+		if len(disabledIndexes) > 0 {
+			hcdLog.Warnf("The %s indexes are dropped by the reindex "+
+				"and will not be rebuilt since they are disabled",
+				strings.Join(disabledIndexes, ", "))
+		}
+
+		dropIndexes := []func(database.DB) error{
+			indexers.DropTxIndex,
+			indexers.DropExistsAddrIndex,
+			indexers.DropTicketIndex,
+		}
+		for _, dropIndex := range dropIndexes {
+			if err := dropIndex(db); err != nil {
+				hcdLog.Errorf("%v", err)
+				return err
+			}
+		}
+	}
+	if cfg.Reindex || cfg.ReindexChainState || reindexPending {
+		if err := reindexChain(db, ctx.Done()); err != nil {
+			if interruptRequested(ctx) {
+				return nil
+			}
+			hcdLog.Errorf("Unable to reindex the chain: %v", err)
+			return err
+		}
+		if interruptRequested(ctx) {
+			return nil
+		}
+	}
+
+	// Import the blocks of a bootstrap file before starting the server if
+	// requested.  An interrupted import is resumed by importing the same
+	// file again.
+	if cfg.ImportBootstrap != "" {
+		if err := importBootstrap(db, ctx.Done()); err != nil {
+			if interruptRequested(ctx) {
+				return nil
+			}
+			hcdLog.Errorf("Unable to import bootstrap file: %v", err)
+			return err
+		}
+		if interruptRequested(ctx) {
+			return nil
+		}
+	}
 
 	// Create server and start it.
 	lifetimeNotifier.notifyStartupEvent(lifetimeEventP2PServer)
@@ -0,0 +1,704 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/james-ray/hcd/hcec/ed25519/edwards25519"
+)
+
+// References:
+//   [MuSig]: Simple Schnorr Multi-Signatures with Applications to Bitcoin
+//   https://eprint.iacr.org/2018/068
+//
+// This is the Ed25519 counterpart of the MuSig implementation in the
+// secp256k1 schnorr package.  CombinePubkeys and SchnorrPartialSign in
+// threshold.go sum the public keys of the signers directly and are therefore
+// vulnerable to rogue-key attacks.  The MuSig protocol weights each public key
+// with a coefficient which commits to the entire set of public keys and
+// requires every signer to commit to its public nonce before any public nonce
+// is revealed.
+//
+// The protocol for n signers with public keys P_1 ... P_n is:
+//
+//   L   = SHA512(P_1 || ... || P_n)
+//   a_i = SHA512("HcMuSig/coefficient" || L || P_i) mod N
+//   X   = a_1*P_1 + ... + a_n*P_n                       (aggregate public key)
+//
+//   Round 1: each signer picks a secret nonce k_i, computes R_i = k_i*G and
+//            sends the commitment t_i = SHA512/256("HcMuSig/nonce" || R_i).
+//   Round 2: once all commitments are received, each signer reveals R_i and
+//            checks the revealed nonces against the commitments.
+//   Round 3: R = R_1 + ... + R_n.  Each signer sends the partial signature
+//              s_i = k_i + h*a_i*x_i mod N, where h = SHA512(R || X || m)
+//            and every partial signature is verified with
+//              s_i*G == R_i + h*a_i*P_i.
+//
+// The final signature (R, s_1 + ... + s_n) is a standard Ed25519 signature for
+// the aggregate public key X, so it is accepted by Verify.
+
+const (
+	// MuSigCommitmentSize is the size of a serialized MuSig nonce
+	// commitment.
+	MuSigCommitmentSize = sha512.Size256
+
+	// MuSigPartialSigSize is the size of a serialized MuSig partial
+	// signature.
+	MuSigPartialSigSize = PrivScalarSize
+
+	// MaxMuSigSigners is the maximum number of signers supported by a MuSig
+	// session.
+	MaxMuSigSigners = 255
+
+	// muSigSessionVersion is the version of the serialized MuSig session
+	// state.
+	muSigSessionVersion = 1
+)
+
+var (
+	// muSigCoefficientTag is the domain separation tag used when computing
+	// the key coefficients.
+	muSigCoefficientTag = []byte("HcMuSig/coefficient")
+
+	// muSigNonceTag is the domain separation tag used when committing to a
+	// public nonce.
+	muSigNonceTag = []byte("HcMuSig/nonce")
+
+	// muSigSecretNonceTag is the domain separation tag used when deriving a
+	// secret nonce.
+	muSigSecretNonceTag = []byte("HcMuSig/secretnonce")
+)
+
+// hashToScalar returns SHA512 of the concatenation of the passed byte slices
+// reduced modulo the order of the curve.
+func hashToScalar(data ...[]byte) *big.Int {
+	h := sha512.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	var digest [64]byte
+	h.Sum(digest[:0])
+	var reduced [32]byte
+	edwards25519.ScReduce(&reduced, &digest)
+	return EncodedBytesToBigInt(&reduced)
+}
+
+// muSigKeyCoefficients returns the MuSig key coefficient for each of the
+// passed public keys.  The coefficients commit to the entire ordered set of
+// public keys.
+func muSigKeyCoefficients(pks []*PublicKey) []*big.Int {
+	h := sha512.New()
+	for _, pk := range pks {
+		h.Write(pk.Serialize())
+	}
+	l := h.Sum(nil)
+
+	coefficients := make([]*big.Int, len(pks))
+	for i, pk := range pks {
+		coefficients[i] = hashToScalar(muSigCoefficientTag, l,
+			pk.Serialize())
+	}
+
+	return coefficients
+}
+
+// muSigCombinePubkeys returns the MuSig aggregate public key for the passed
+// public keys and key coefficients.
+func muSigCombinePubkeys(curve *TwistedEdwardsCurve, pks []*PublicKey,
+	coefficients []*big.Int) (*PublicKey, error) {
+	var sumX, sumY *big.Int
+	for i, pk := range pks {
+		x, y := curve.ScalarMult(pk.GetX(), pk.GetY(),
+			coefficients[i].Bytes())
+		if x == nil {
+			return nil, fmt.Errorf("pubkey %v could not be weighted", i)
+		}
+		if sumX == nil {
+			sumX, sumY = x, y
+			continue
+		}
+		sumX, sumY = curve.Add(sumX, sumY, x, y)
+	}
+
+	// The neutral element is (0, 1).
+	if sumX.Sign() == 0 && sumY.Cmp(one) == 0 {
+		return nil, fmt.Errorf("aggregate public key is the neutral " +
+			"element")
+	}
+	if !curve.IsOnCurve(sumX, sumY) {
+		return nil, fmt.Errorf("aggregate public key is off curve")
+	}
+
+	return NewPublicKey(curve, sumX, sumY), nil
+}
+
+// checkMuSigPubkeys ensures the passed public keys are usable for a MuSig
+// session.
+func checkMuSigPubkeys(curve *TwistedEdwardsCurve, pks []*PublicKey) error {
+	if len(pks) < 1 || len(pks) > MaxMuSigSigners {
+		return fmt.Errorf("invalid number of public keys (got %v, want "+
+			"1 to %v)", len(pks), MaxMuSigSigners)
+	}
+	for i, pk := range pks {
+		if pk == nil {
+			return fmt.Errorf("nil pubkey %v", i)
+		}
+		if !curve.IsOnCurve(pk.GetX(), pk.GetY()) {
+			return fmt.Errorf("pubkey %v is off curve", i)
+		}
+	}
+
+	return nil
+}
+
+// MuSigCombinePubkeys returns the MuSig aggregate public key for the passed
+// ordered set of public keys.  Unlike CombinePubkeys, each public key is
+// weighted by a coefficient which commits to the whole set, so the aggregate
+// key is not vulnerable to rogue-key attacks.  All signers must use the same
+// order of public keys.
+func MuSigCombinePubkeys(curve *TwistedEdwardsCurve,
+	pks []*PublicKey) (*PublicKey, error) {
+	if err := checkMuSigPubkeys(curve, pks); err != nil {
+		return nil, err
+	}
+
+	return muSigCombinePubkeys(curve, pks, muSigKeyCoefficients(pks))
+}
+
+// muSigNonceCommitment returns the commitment to the passed public nonce.
+func muSigNonceCommitment(pubNonce *PublicKey) []byte {
+	hashInput := make([]byte, 0, len(muSigNonceTag)+PubKeyBytesLen)
+	hashInput = append(hashInput, muSigNonceTag...)
+	hashInput = append(hashInput, pubNonce.Serialize()...)
+	commitment := sha512.Sum512_256(hashInput)
+	return commitment[:]
+}
+
+// muSigPrivScalar returns the private scalar of the passed private key.  The D
+// value of a key created from an Ed25519 secret holds the little endian bytes
+// of the clamped scalar as a big endian integer, while the D value of a key
+// created from a scalar is the scalar itself.
+func muSigPrivScalar(priv *PrivateKey) *big.Int {
+	if priv.secret == nil {
+		return new(big.Int).Set(priv.GetD())
+	}
+	return EncodedBytesToBigInt(copyBytes(priv.GetD().Bytes()))
+}
+
+// checkPrivKey ensures the passed private key corresponds to the passed public
+// key.
+func checkPrivKey(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	pub *PublicKey) error {
+	if priv == nil {
+		return fmt.Errorf("nil private key")
+	}
+	x := muSigPrivScalar(priv)
+	pkx, pky := curve.ScalarBaseMult(x.Bytes())
+	x.SetInt64(0)
+	if pkx == nil || pkx.Cmp(pub.GetX()) != 0 || pky.Cmp(pub.GetY()) != 0 {
+		return fmt.Errorf("private key does not match pubkey")
+	}
+
+	return nil
+}
+
+// MuSigSession houses the state of one signer taking part in a MuSig signing
+// session.  The session advances through the nonce commitment, public nonce
+// and partial signature rounds described above, and the state may be
+// serialized between rounds with Serialize and restored with
+// ParseMuSigSession.
+//
+// The secret nonce of the signer is part of the serialized state until the
+// partial signature is created, so the serialized state must be protected like
+// a private key.  A session must never be restored more than once, since
+// signing two different messages or nonce sets with the same secret nonce
+// reveals the private key.
+type MuSigSession struct {
+	curve        *TwistedEdwardsCurve
+	pubKeys      []*PublicKey
+	coefficients []*big.Int
+	aggPubKey    *PublicKey
+	signerIdx    int
+	msg          []byte
+
+	// privNonce is the big endian secret nonce of the signer.  It is
+	// zeroed and set to nil once the partial signature of the signer is
+	// created.
+	privNonce []byte
+
+	commitments [][]byte
+	pubNonces   []*PublicKey
+	partialSigs []*big.Int
+}
+
+// NewMuSigSession creates a new MuSig session for the signer with the passed
+// private key, which must correspond to the public key at index signerIdx, to
+// sign msg with the aggregate public key of pubKeys.  The secret nonce is
+// derived from the private key, the message, the set of public keys and 32
+// bytes read from the passed random source, which should be crypto/rand.Reader
+// and is used when nil.
+func NewMuSigSession(curve *TwistedEdwardsCurve, pubKeys []*PublicKey,
+	signerIdx int, priv *PrivateKey, msg []byte,
+	randSource io.Reader) (*MuSigSession, error) {
+	if len(msg) != PrivScalarSize {
+		return nil, fmt.Errorf("wrong size for message (got %v, want %v)",
+			len(msg), PrivScalarSize)
+	}
+	if err := checkMuSigPubkeys(curve, pubKeys); err != nil {
+		return nil, err
+	}
+	if signerIdx < 0 || signerIdx >= len(pubKeys) {
+		return nil, fmt.Errorf("signer index %v is out of range",
+			signerIdx)
+	}
+	if err := checkPrivKey(curve, priv, pubKeys[signerIdx]); err != nil {
+		return nil, err
+	}
+
+	coefficients := muSigKeyCoefficients(pubKeys)
+	aggPubKey, err := muSigCombinePubkeys(curve, pubKeys, coefficients)
+	if err != nil {
+		return nil, err
+	}
+
+	// Derive the secret nonce.  Mixing the private key and message into
+	// the random data protects against a weak random source.
+	if randSource == nil {
+		randSource = rand.Reader
+	}
+	var privNonce []byte
+	for {
+		var randBytes [32]byte
+		if _, err := io.ReadFull(randSource, randBytes[:]); err != nil {
+			return nil, err
+		}
+		x := muSigPrivScalar(priv)
+		privBytes := BigIntToEncodedBytes(x)
+		x.SetInt64(0)
+		k := hashToScalar(muSigSecretNonceTag, randBytes[:],
+			privBytes[:], msg, aggPubKey.Serialize())
+		zeroSlice(privBytes[:])
+		if k.Sign() != 0 {
+			kBytes := BigIntToEncodedBytesNoReverse(k)
+			k.SetInt64(0)
+			privNonce = kBytes[:]
+			break
+		}
+	}
+
+	n := len(pubKeys)
+	s := &MuSigSession{
+		curve:        curve,
+		pubKeys:      pubKeys,
+		coefficients: coefficients,
+		aggPubKey:    aggPubKey,
+		signerIdx:    signerIdx,
+		msg:          copyBytesSlice(msg),
+		privNonce:    privNonce,
+		commitments:  make([][]byte, n),
+		pubNonces:    make([]*PublicKey, n),
+		partialSigs:  make([]*big.Int, n),
+	}
+	pubNonceX, pubNonceY := curve.ScalarBaseMult(privNonce)
+	s.pubNonces[signerIdx] = NewPublicKey(curve, pubNonceX, pubNonceY)
+	s.commitments[signerIdx] = muSigNonceCommitment(s.pubNonces[signerIdx])
+	return s, nil
+}
+
+// AggregatePubKey returns the MuSig aggregate public key the session signs
+// for.
+func (s *MuSigSession) AggregatePubKey() *PublicKey {
+	return s.aggPubKey
+}
+
+// NonceCommitment returns the nonce commitment of the signer to send to all
+// other signers in the first round.
+func (s *MuSigSession) NonceCommitment() []byte {
+	return copyBytesSlice(s.commitments[s.signerIdx])
+}
+
+// AddNonceCommitment adds the nonce commitment of the signer at the passed
+// index.
+func (s *MuSigSession) AddNonceCommitment(idx int, commitment []byte) error {
+	if err := s.checkPeerIdx(idx); err != nil {
+		return err
+	}
+	if len(commitment) != MuSigCommitmentSize {
+		return fmt.Errorf("wrong size for nonce commitment (got %v, "+
+			"want %v)", len(commitment), MuSigCommitmentSize)
+	}
+	if s.commitments[idx] != nil {
+		return fmt.Errorf("nonce commitment %v already set", idx)
+	}
+
+	s.commitments[idx] = copyBytesSlice(commitment)
+	return nil
+}
+
+// PublicNonce returns the public nonce of the signer to send to all other
+// signers in the second round.  It may only be revealed once the nonce
+// commitments of all signers have been added.
+func (s *MuSigSession) PublicNonce() (*PublicKey, error) {
+	for i, commitment := range s.commitments {
+		if commitment == nil {
+			return nil, fmt.Errorf("missing nonce commitment %v", i)
+		}
+	}
+
+	return s.pubNonces[s.signerIdx], nil
+}
+
+// AddPublicNonce adds the public nonce of the signer at the passed index after
+// checking it against the nonce commitment previously added for that signer.
+func (s *MuSigSession) AddPublicNonce(idx int, pubNonce *PublicKey) error {
+	if err := s.checkPeerIdx(idx); err != nil {
+		return err
+	}
+	if pubNonce == nil {
+		return fmt.Errorf("nil public nonce")
+	}
+	if s.commitments[idx] == nil {
+		return fmt.Errorf("missing nonce commitment %v", idx)
+	}
+	if s.pubNonces[idx] != nil {
+		return fmt.Errorf("public nonce %v already set", idx)
+	}
+	if !s.curve.IsOnCurve(pubNonce.GetX(), pubNonce.GetY()) {
+		return fmt.Errorf("public nonce %v is off curve", idx)
+	}
+	if !bytes.Equal(muSigNonceCommitment(pubNonce), s.commitments[idx]) {
+		return fmt.Errorf("public nonce %v does not match its "+
+			"commitment", idx)
+	}
+
+	s.pubNonces[idx] = pubNonce
+	return nil
+}
+
+// combinedNonce returns the encoded sum of all public nonces and the challenge
+// hash h = SHA512(R || X || m) reduced modulo the order of the curve.
+func (s *MuSigSession) combinedNonce() (*[32]byte, *big.Int, error) {
+	var rx, ry *big.Int
+	for i, pubNonce := range s.pubNonces {
+		if pubNonce == nil {
+			return nil, nil, fmt.Errorf("missing public nonce %v", i)
+		}
+		if rx == nil {
+			rx, ry = pubNonce.GetX(), pubNonce.GetY()
+			continue
+		}
+		rx, ry = s.curve.Add(rx, ry, pubNonce.GetX(), pubNonce.GetY())
+	}
+	if !s.curve.IsOnCurve(rx, ry) {
+		return nil, nil, fmt.Errorf("combined public nonce is off curve")
+	}
+
+	encodedR := BigIntPointToEncodedBytes(rx, ry)
+	h := hashToScalar(encodedR[:], s.aggPubKey.Serialize(), s.msg)
+	return encodedR, h, nil
+}
+
+// PartialSign creates the partial signature of the signer to send to all other
+// signers in the third round.  It may only be created once the public nonces
+// of all signers have been added, and only once per session since the secret
+// nonce is erased afterwards.
+func (s *MuSigSession) PartialSign(priv *PrivateKey) ([]byte, error) {
+	if s.privNonce == nil {
+		return nil, fmt.Errorf("secret nonce already used")
+	}
+	err := checkPrivKey(s.curve, priv, s.pubKeys[s.signerIdx])
+	if err != nil {
+		return nil, err
+	}
+
+	_, h, err := s.combinedNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	// s_i = k_i + h*a_i*x_i
+	k := new(big.Int).SetBytes(s.privNonce)
+	x := muSigPrivScalar(priv)
+	sig := new(big.Int).Mul(h, s.coefficients[s.signerIdx])
+	sig.Mul(sig, x)
+	sig.Add(sig, k)
+	sig.Mod(sig, s.curve.N)
+	k.SetInt64(0)
+	x.SetInt64(0)
+
+	// The secret nonce must never be used again.
+	zeroSlice(s.privNonce)
+	s.privNonce = nil
+
+	s.partialSigs[s.signerIdx] = sig
+	encoded := BigIntToEncodedBytes(sig)
+	return encoded[:], nil
+}
+
+// AddPartialSig adds the little endian partial signature of the signer at the
+// passed index after verifying it against the public key and public nonce of
+// that signer.
+func (s *MuSigSession) AddPartialSig(idx int, partialSig []byte) error {
+	if err := s.checkPeerIdx(idx); err != nil {
+		return err
+	}
+	if len(partialSig) != MuSigPartialSigSize {
+		return fmt.Errorf("wrong size for partial signature (got %v, "+
+			"want %v)", len(partialSig), MuSigPartialSigSize)
+	}
+	if s.partialSigs[idx] != nil {
+		return fmt.Errorf("partial signature %v already set", idx)
+	}
+	sig := EncodedBytesToBigInt(copyBytes(partialSig))
+	if sig.Cmp(s.curve.N) >= 0 {
+		return fmt.Errorf("partial signature %v is out of bounds", idx)
+	}
+	if err := s.verifyPartialSig(idx, sig); err != nil {
+		return err
+	}
+
+	s.partialSigs[idx] = sig
+	return nil
+}
+
+// verifyPartialSig verifies the partial signature of the signer at the passed
+// index by checking s_i*G == R_i + h*a_i*P_i.
+func (s *MuSigSession) verifyPartialSig(idx int, sig *big.Int) error {
+	_, h, err := s.combinedNonce()
+	if err != nil {
+		return err
+	}
+
+	pub := s.pubKeys[idx]
+	e := new(big.Int).Mul(h, s.coefficients[idx])
+	e.Mod(e, s.curve.N)
+	lx, ly := s.curve.ScalarBaseMult(sig.Bytes())
+	ex, ey := s.curve.ScalarMult(pub.GetX(), pub.GetY(), e.Bytes())
+	if lx == nil || ex == nil {
+		return fmt.Errorf("partial signature %v is invalid", idx)
+	}
+	rx, ry := s.curve.Add(s.pubNonces[idx].GetX(), s.pubNonces[idx].GetY(),
+		ex, ey)
+	if lx.Cmp(rx) != 0 || ly.Cmp(ry) != 0 {
+		return fmt.Errorf("partial signature %v is invalid", idx)
+	}
+
+	return nil
+}
+
+// CombinedSignature returns the final Ed25519 signature for the aggregate
+// public key once the partial signatures of all signers have been added.  The
+// signature is verified before it is returned.
+func (s *MuSigSession) CombinedSignature() (*Signature, error) {
+	encodedR, _, err := s.combinedNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	sigS := new(big.Int)
+	for i, partialSig := range s.partialSigs {
+		if partialSig == nil {
+			return nil, fmt.Errorf("missing partial signature %v", i)
+		}
+		sigS.Add(sigS, partialSig)
+	}
+	sigS.Mod(sigS, s.curve.N)
+
+	encodedS := BigIntToEncodedBytes(sigS)
+	sig, err := ParseSignature(s.curve, append(encodedR[:], encodedS[:]...))
+	if err != nil {
+		return nil, err
+	}
+	if !Verify(s.aggPubKey, s.msg, sig.GetR(), sig.GetS()) {
+		return nil, fmt.Errorf("combined signature is invalid")
+	}
+
+	return sig, nil
+}
+
+// checkPeerIdx ensures the passed index refers to a signer other than the
+// signer of the session.
+func (s *MuSigSession) checkPeerIdx(idx int) error {
+	if idx < 0 || idx >= len(s.pubKeys) {
+		return fmt.Errorf("signer index %v is out of range", idx)
+	}
+	if idx == s.signerIdx {
+		return fmt.Errorf("signer index %v is the local signer", idx)
+	}
+
+	return nil
+}
+
+// Flags which mark the per-signer fields present in a serialized session.
+const (
+	muSigHasCommitment = 1 << iota
+	muSigHasPubNonce
+	muSigHasPartialSig
+)
+
+// Serialize returns the serialized state of the session.  See the
+// MuSigSession documentation for the care required when storing it.
+//
+// The serialized format is:
+//   version (1) || number of signers (1) || signer index (1) ||
+//   message (32) || has secret nonce (1) || [secret nonce (32)] ||
+//   for each signer:
+//     public key (32) || flags (1) || [nonce commitment (32)] ||
+//     [public nonce (32)] || [partial signature (32)]
+func (s *MuSigSession) Serialize() []byte {
+	n := len(s.pubKeys)
+	b := make([]byte, 0, 4+PrivScalarSize*2+n*(1+PubKeyBytesLen*2+
+		MuSigCommitmentSize+MuSigPartialSigSize))
+	b = append(b, muSigSessionVersion, byte(n), byte(s.signerIdx))
+	b = append(b, s.msg...)
+	if s.privNonce != nil {
+		b = append(b, 1)
+		b = append(b, s.privNonce...)
+	} else {
+		b = append(b, 0)
+	}
+	for i, pk := range s.pubKeys {
+		b = append(b, pk.Serialize()...)
+		var flags byte
+		if s.commitments[i] != nil {
+			flags |= muSigHasCommitment
+		}
+		if s.pubNonces[i] != nil {
+			flags |= muSigHasPubNonce
+		}
+		if s.partialSigs[i] != nil {
+			flags |= muSigHasPartialSig
+		}
+		b = append(b, flags)
+		if s.commitments[i] != nil {
+			b = append(b, s.commitments[i]...)
+		}
+		if s.pubNonces[i] != nil {
+			b = append(b, s.pubNonces[i].Serialize()...)
+		}
+		if s.partialSigs[i] != nil {
+			b = append(b, BigIntToEncodedBytes(s.partialSigs[i])[:]...)
+		}
+	}
+
+	return b
+}
+
+// ParseMuSigSession restores a session from the serialized state returned by
+// Serialize.
+func ParseMuSigSession(curve *TwistedEdwardsCurve, b []byte) (*MuSigSession,
+	error) {
+	errShort := fmt.Errorf("serialized session is truncated")
+	if len(b) < 4+PrivScalarSize {
+		return nil, errShort
+	}
+	if b[0] != muSigSessionVersion {
+		return nil, fmt.Errorf("unsupported session version %v", b[0])
+	}
+	n, signerIdx := int(b[1]), int(b[2])
+	if n == 0 || signerIdx >= n {
+		return nil, fmt.Errorf("invalid signer index %v for %v signers",
+			signerIdx, n)
+	}
+	msg := copyBytesSlice(b[3 : 3+PrivScalarSize])
+	b = b[3+PrivScalarSize:]
+
+	var privNonce []byte
+	hasPrivNonce := b[0] == 1
+	b = b[1:]
+	if hasPrivNonce {
+		if len(b) < PrivScalarSize {
+			return nil, errShort
+		}
+		privNonce = copyBytesSlice(b[:PrivScalarSize])
+		b = b[PrivScalarSize:]
+	}
+
+	s := &MuSigSession{
+		curve:       curve,
+		pubKeys:     make([]*PublicKey, n),
+		signerIdx:   signerIdx,
+		msg:         msg,
+		privNonce:   privNonce,
+		commitments: make([][]byte, n),
+		pubNonces:   make([]*PublicKey, n),
+		partialSigs: make([]*big.Int, n),
+	}
+	for i := 0; i < n; i++ {
+		if len(b) < PubKeyBytesLen+1 {
+			return nil, errShort
+		}
+		pk, err := ParsePubKey(curve, b[:PubKeyBytesLen])
+		if err != nil {
+			return nil, err
+		}
+		s.pubKeys[i] = pk
+		flags := b[PubKeyBytesLen]
+		b = b[PubKeyBytesLen+1:]
+
+		if flags&muSigHasCommitment != 0 {
+			if len(b) < MuSigCommitmentSize {
+				return nil, errShort
+			}
+			s.commitments[i] = copyBytesSlice(b[:MuSigCommitmentSize])
+			b = b[MuSigCommitmentSize:]
+		}
+		if flags&muSigHasPubNonce != 0 {
+			if len(b) < PubKeyBytesLen {
+				return nil, errShort
+			}
+			pubNonce, err := ParsePubKey(curve, b[:PubKeyBytesLen])
+			if err != nil {
+				return nil, err
+			}
+			s.pubNonces[i] = pubNonce
+			b = b[PubKeyBytesLen:]
+		}
+		if flags&muSigHasPartialSig != 0 {
+			if len(b) < MuSigPartialSigSize {
+				return nil, errShort
+			}
+			s.partialSigs[i] = EncodedBytesToBigInt(
+				copyBytes(b[:MuSigPartialSigSize]))
+			b = b[MuSigPartialSigSize:]
+		}
+	}
+	if len(b) != 0 {
+		return nil, fmt.Errorf("%v trailing bytes in serialized session",
+			len(b))
+	}
+
+	// The local signer always knows its own nonce commitment and public
+	// nonce.
+	if s.commitments[signerIdx] == nil || s.pubNonces[signerIdx] == nil {
+		return nil, fmt.Errorf("serialized session is missing the local " +
+			"public nonce")
+	}
+
+	if err := checkMuSigPubkeys(curve, s.pubKeys); err != nil {
+		return nil, err
+	}
+	s.coefficients = muSigKeyCoefficients(s.pubKeys)
+	aggPubKey, err := muSigCombinePubkeys(curve, s.pubKeys, s.coefficients)
+	if err != nil {
+		return nil, err
+	}
+	s.aggPubKey = aggPubKey
+
+	return s, nil
+}
+
+// copyBytesSlice returns a copy of the passed byte slice.
+func copyBytesSlice(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+)
+
+// muSigTestVector is a MuSig test vector.  The private keys are Ed25519
+// secrets and the secret nonce of signer i is derived using 32 bytes of value
+// byte(i+1) as the random source.
+type muSigTestVector struct {
+	secrets   []string
+	msg       string
+	aggPubKey string
+	sig       string
+}
+
+var muSigTestVectors = []muSigTestVector{
+	{
+		secrets: []string{
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"0000000000000000000000000000000000000000000000000000000000000002",
+		},
+		msg:       "0101010101010101010101010101010101010101010101010101010101010101",
+		aggPubKey: "df14dea30d9179959931fbdbdc8d8b3752f3603d73aa8c7347e5124a40f9852e",
+		sig:       "029783506bb7af89abe4fb93f28fbf4ba574c29a3630e26e4e37926db093a2a04ec2437c7da0711b7fefa4cbe37340f98ece1152a231e3a347054b6dec7cc304",
+	},
+	{
+		secrets: []string{
+			"7A39B587A29317AFD793F701D26542E3081D93AB58EA28968C491D7E066A1798",
+			"A1FABBBA0C76DD5F255F1A1228D57ADBEBB935C6A80B2D877D3E153DBF946C33",
+			"41BB6B56F127607218B6C0D4DDC9595A6639172ECAFBB73134B16EF6852627DD",
+		},
+		msg:       "E63D5EE254ECC86A8BD6439E6434148BD02823E678B96816B08581EB33D2192A",
+		aggPubKey: "9d4607c731b553c2cee232a975c706f35baae4a6f4bcd32b34eb4db99fb472b8",
+		sig:       "d8505fbf7519a66a0f480ce5fd13301ff95e7af1e9e556c6cc46417e0cec9ca79169010f6bc72dcb92e35a2e1ef140db4347442f4698b8c694e2794aef2b5f04",
+	},
+}
+
+// runMuSig runs the full MuSig protocol for the passed private keys, exchanging
+// the data of each round between the sessions and round tripping every session
+// through its serialized state between rounds.  The random source of signer i
+// returns bytes of value byte(i+1).
+func runMuSig(t *testing.T, curve *TwistedEdwardsCurve, privKeys []*PrivateKey,
+	msg []byte) ([]*MuSigSession, *Signature) {
+	pubKeys := make([]*PublicKey, len(privKeys))
+	for i, priv := range privKeys {
+		x, y := priv.Public()
+		pubKeys[i] = NewPublicKey(curve, x, y)
+	}
+
+	reserialize := func(sessions []*MuSigSession) {
+		for i, s := range sessions {
+			parsed, err := ParseMuSigSession(curve, s.Serialize())
+			if err != nil {
+				t.Fatalf("ParseMuSigSession %d: unexpected error: %v",
+					i, err)
+			}
+			if !bytes.Equal(parsed.Serialize(), s.Serialize()) {
+				t.Fatalf("ParseMuSigSession %d: mismatched round "+
+					"trip serialization", i)
+			}
+			sessions[i] = parsed
+		}
+	}
+
+	sessions := make([]*MuSigSession, len(privKeys))
+	for i, priv := range privKeys {
+		randSource := bytes.NewReader(bytes.Repeat([]byte{byte(i + 1)}, 32))
+		s, err := NewMuSigSession(curve, pubKeys, i, priv, msg, randSource)
+		if err != nil {
+			t.Fatalf("NewMuSigSession %d: unexpected error: %v", i, err)
+		}
+		sessions[i] = s
+	}
+	reserialize(sessions)
+
+	// Round 1: exchange nonce commitments.
+	for i, s := range sessions {
+		for j, peer := range sessions {
+			if i == j {
+				continue
+			}
+			err := peer.AddNonceCommitment(i, s.NonceCommitment())
+			if err != nil {
+				t.Fatalf("AddNonceCommitment %d->%d: unexpected "+
+					"error: %v", i, j, err)
+			}
+		}
+	}
+	reserialize(sessions)
+
+	// Round 2: exchange public nonces.
+	for i, s := range sessions {
+		pubNonce, err := s.PublicNonce()
+		if err != nil {
+			t.Fatalf("PublicNonce %d: unexpected error: %v", i, err)
+		}
+		for j, peer := range sessions {
+			if i == j {
+				continue
+			}
+			if err := peer.AddPublicNonce(i, pubNonce); err != nil {
+				t.Fatalf("AddPublicNonce %d->%d: unexpected error: "+
+					"%v", i, j, err)
+			}
+		}
+	}
+	reserialize(sessions)
+
+	// Round 3: exchange partial signatures.
+	for i, s := range sessions {
+		partialSig, err := s.PartialSign(privKeys[i])
+		if err != nil {
+			t.Fatalf("PartialSign %d: unexpected error: %v", i, err)
+		}
+		for j, peer := range sessions {
+			if i == j {
+				continue
+			}
+			if err := peer.AddPartialSig(i, partialSig); err != nil {
+				t.Fatalf("AddPartialSig %d->%d: unexpected error: "+
+					"%v", i, j, err)
+			}
+		}
+	}
+	reserialize(sessions)
+
+	var sig *Signature
+	for i, s := range sessions {
+		combined, err := s.CombinedSignature()
+		if err != nil {
+			t.Fatalf("CombinedSignature %d: unexpected error: %v", i,
+				err)
+		}
+		if sig != nil && !bytes.Equal(sig.Serialize(),
+			combined.Serialize()) {
+			t.Fatalf("CombinedSignature %d: signers disagree on the "+
+				"signature", i)
+		}
+		sig = combined
+	}
+
+	return sessions, sig
+}
+
+// TestMuSigVectors ensures the MuSig protocol produces the expected aggregate
+// public keys and signatures, and that the signatures verify as standard
+// Ed25519 signatures.
+func TestMuSigVectors(t *testing.T) {
+	curve := Edwards()
+	for i, test := range muSigTestVectors {
+		privKeys := make([]*PrivateKey, len(test.secrets))
+		for j, secretHex := range test.secrets {
+			secret, _ := hex.DecodeString(secretHex)
+			privKeys[j], _ = PrivKeyFromSecret(curve, secret)
+		}
+		msg, _ := hex.DecodeString(test.msg)
+
+		sessions, sig := runMuSig(t, curve, privKeys, msg)
+		aggPubKey := sessions[0].AggregatePubKey()
+		gotAggPubKey := hex.EncodeToString(aggPubKey.Serialize())
+		if gotAggPubKey != test.aggPubKey {
+			t.Errorf("test #%d: mismatched aggregate pubkey -- want "+
+				"%s, got %s", i, test.aggPubKey, gotAggPubKey)
+		}
+		gotSig := hex.EncodeToString(sig.Serialize())
+		if gotSig != test.sig {
+			t.Errorf("test #%d: mismatched signature -- want %s, "+
+				"got %s", i, test.sig, gotSig)
+		}
+		if !Verify(aggPubKey, msg, sig.GetR(), sig.GetS()) {
+			t.Errorf("test #%d: signature failed to verify", i)
+		}
+	}
+}
+
+// TestMuSigRandom runs the MuSig protocol for random sets of signers using
+// both secret and scalar private keys.
+func TestMuSigRandom(t *testing.T) {
+	curve := Edwards()
+	tRand := rand.New(rand.NewSource(54321))
+	for i := 0; i < 6; i++ {
+		numSigners := tRand.Intn(4) + 1
+		privKeys := randPrivKeyList(curve, numSigners)
+		if i%2 == 1 {
+			privKeys = randPrivScalarKeyList(curve, numSigners)
+		}
+		msg := make([]byte, 32)
+		tRand.Read(msg)
+
+		sessions, sig := runMuSig(t, curve, privKeys, msg)
+		aggPubKey := sessions[0].AggregatePubKey()
+		if !Verify(aggPubKey, msg, sig.GetR(), sig.GetS()) {
+			t.Fatalf("test #%d: signature failed to verify", i)
+		}
+
+		pubKeys := make([]*PublicKey, numSigners)
+		for j, priv := range privKeys {
+			x, y := priv.Public()
+			pubKeys[j] = NewPublicKey(curve, x, y)
+		}
+		combined, err := MuSigCombinePubkeys(curve, pubKeys)
+		if err != nil {
+			t.Fatalf("test #%d: MuSigCombinePubkeys: unexpected "+
+				"error: %v", i, err)
+		}
+		if !bytes.Equal(combined.Serialize(), aggPubKey.Serialize()) {
+			t.Fatalf("test #%d: mismatched aggregate pubkey", i)
+		}
+	}
+}
+
+// TestMuSigErrors ensures the MuSig session rejects out of order rounds,
+// nonces which do not match their commitments and invalid partial signatures.
+func TestMuSigErrors(t *testing.T) {
+	curve := Edwards()
+	privKeys := randPrivKeyList(curve, 2)
+	pubKeys := make([]*PublicKey, 2)
+	for i, priv := range privKeys {
+		x, y := priv.Public()
+		pubKeys[i] = NewPublicKey(curve, x, y)
+	}
+	msg := bytes.Repeat([]byte{0x42}, 32)
+
+	// The private key must match the signer index.
+	_, err := NewMuSigSession(curve, pubKeys, 1, privKeys[0], msg, nil)
+	if err == nil {
+		t.Errorf("mismatched private key: expected error")
+	}
+
+	s0, err := NewMuSigSession(curve, pubKeys, 0, privKeys[0], msg, nil)
+	if err != nil {
+		t.Fatalf("NewMuSigSession: unexpected error: %v", err)
+	}
+	s1, err := NewMuSigSession(curve, pubKeys, 1, privKeys[1], msg, nil)
+	if err != nil {
+		t.Fatalf("NewMuSigSession: unexpected error: %v", err)
+	}
+
+	// Public nonces may not be revealed before all commitments are known.
+	if _, err := s0.PublicNonce(); err == nil {
+		t.Errorf("early public nonce: expected error")
+	}
+
+	s0.AddNonceCommitment(1, s1.NonceCommitment())
+	s1.AddNonceCommitment(0, s0.NonceCommitment())
+	if err := s0.AddNonceCommitment(1, s1.NonceCommitment()); err == nil {
+		t.Errorf("duplicate commitment: expected error")
+	}
+
+	// A public nonce which does not match the commitment is rejected.
+	s0Nonce, _ := s0.PublicNonce()
+	if err := s0.AddPublicNonce(1, s0Nonce); err == nil {
+		t.Errorf("mismatched public nonce: expected error")
+	}
+
+	// Partial signatures may not be created before all nonces are known.
+	if _, err := s0.PartialSign(privKeys[0]); err == nil {
+		t.Errorf("early partial signature: expected error")
+	}
+
+	s1Nonce, _ := s1.PublicNonce()
+	s0.AddPublicNonce(1, s1Nonce)
+	s1.AddPublicNonce(0, s0Nonce)
+
+	// A corrupted partial signature is rejected.
+	partialSig1, err := s1.PartialSign(privKeys[1])
+	if err != nil {
+		t.Fatalf("PartialSign: unexpected error: %v", err)
+	}
+	corrupt := make([]byte, len(partialSig1))
+	copy(corrupt, partialSig1)
+	corrupt[0] ^= 0x01
+	if err := s0.AddPartialSig(1, corrupt); err == nil {
+		t.Errorf("corrupt partial signature: expected error")
+	}
+	if err := s0.AddPartialSig(1, partialSig1); err != nil {
+		t.Fatalf("AddPartialSig: unexpected error: %v", err)
+	}
+
+	// The secret nonce may only be used once.
+	if _, err := s1.PartialSign(privKeys[1]); err == nil {
+		t.Errorf("reused secret nonce: expected error")
+	}
+
+	if _, err := s0.CombinedSignature(); err == nil {
+		t.Errorf("missing partial signature: expected error")
+	}
+	if _, err := s0.PartialSign(privKeys[0]); err != nil {
+		t.Fatalf("PartialSign: unexpected error: %v", err)
+	}
+	sig, err := s0.CombinedSignature()
+	if err != nil {
+		t.Fatalf("CombinedSignature: unexpected error: %v", err)
+	}
+
+	// The signature does not verify for the naive sum of the public keys.
+	naive := CombinePubkeys(curve, pubKeys)
+	if Verify(naive, msg, sig.GetR(), sig.GetS()) {
+		t.Errorf("signature verified for the naive key sum")
+	}
+}
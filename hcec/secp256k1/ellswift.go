@@ -0,0 +1,232 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package secp256k1
+
+import (
+	"errors"
+	"io"
+	"math/big"
+)
+
+// EllSwiftPubKeyLen is the length of an ElligatorSwift encoded public key.
+const EllSwiftPubKeyLen = 64
+
+var (
+	// feSeven is the constant B of the secp256k1 curve equation.
+	feSeven = big.NewInt(7)
+
+	// feMinus3Sqrt is a square root of -3 modulo the field prime.
+	feMinus3Sqrt = func() *big.Int {
+		p := S256().P
+		minus3 := new(big.Int).Sub(p, big.NewInt(3))
+		return feSqrt(minus3)
+	}()
+)
+
+// feMod reduces x modulo the field prime in place and returns it.
+func feMod(x *big.Int) *big.Int {
+	return x.Mod(x, S256().P)
+}
+
+// feInv returns the multiplicative inverse of x modulo the field prime.
+func feInv(x *big.Int) *big.Int {
+	return new(big.Int).ModInverse(x, S256().P)
+}
+
+// feSqrt returns a square root of x modulo the field prime or nil when x is
+// not a square.
+func feSqrt(x *big.Int) *big.Int {
+	curve := S256()
+	r := new(big.Int).Exp(x, curve.QPlus1Div4(), curve.P)
+	if feMod(new(big.Int).Mul(r, r)).Cmp(feMod(new(big.Int).Set(x))) != 0 {
+		return nil
+	}
+	return r
+}
+
+// feIsValidX returns whether or not x is the x coordinate of a point on the
+// curve, that is whether x^3 + 7 is a square.
+func feIsValidX(x *big.Int) bool {
+	y2 := new(big.Int).Mul(x, x)
+	y2.Mul(y2, x)
+	y2.Add(y2, feSeven)
+	return big.Jacobi(feMod(y2), S256().P) >= 0
+}
+
+// xSwiftEC maps the field elements u and t to the x coordinate of a point on
+// the curve.  Every pair of field elements maps to a valid x coordinate.
+func xSwiftEC(u, t *big.Int) *big.Int {
+	u, t = new(big.Int).Set(u), new(big.Int).Set(t)
+	if u.Sign() == 0 {
+		u.SetInt64(1)
+	}
+	if t.Sign() == 0 {
+		t.SetInt64(1)
+	}
+
+	// t = 2t when u^3 + t^2 + 7 = 0.
+	u3b := new(big.Int).Mul(u, u)
+	u3b.Mul(u3b, u)
+	u3b.Add(u3b, feSeven)
+	feMod(u3b)
+	t2 := feMod(new(big.Int).Mul(t, t))
+	if feMod(new(big.Int).Add(u3b, t2)).Sign() == 0 {
+		feMod(t.Lsh(t, 1))
+		t2 = feMod(new(big.Int).Mul(t, t))
+	}
+
+	// X = (u^3 + 7 - t^2) / 2t
+	// Y = (X + t) / (sqrt(-3) * u)
+	x := new(big.Int).Sub(u3b, t2)
+	x.Mul(x, feInv(feMod(new(big.Int).Lsh(t, 1))))
+	feMod(x)
+	y := new(big.Int).Add(x, t)
+	y.Mul(y, feInv(feMod(new(big.Int).Mul(feMinus3Sqrt, u))))
+	feMod(y)
+
+	// The candidates are u + 4Y^2, (-X/Y - u) / 2 and (X/Y - u) / 2, the
+	// first of which that is on the curve is returned.
+	half := feInv(big.NewInt(2))
+	xDivY := feMod(new(big.Int).Mul(x, feInv(y)))
+	candidates := [3]*big.Int{
+		feMod(new(big.Int).Add(u, new(big.Int).Lsh(new(big.Int).Mul(y, y), 2))),
+		feMod(new(big.Int).Mul(new(big.Int).Sub(new(big.Int).Neg(xDivY), u), half)),
+		feMod(new(big.Int).Mul(new(big.Int).Sub(xDivY, u), half)),
+	}
+	for _, c := range candidates {
+		if feIsValidX(c) {
+			return c
+		}
+	}
+
+	// Not reachable since one of the candidates is always on the curve.
+	panic("xSwiftEC: no valid x coordinate")
+}
+
+// xSwiftECInv returns a field element t such that xSwiftEC(u, t) = x, or nil
+// when there is none for the passed case, which selects one of up to eight
+// possible solutions.
+func xSwiftECInv(x, u *big.Int, c byte) *big.Int {
+	u2 := feMod(new(big.Int).Mul(u, u))
+	u3b := feMod(new(big.Int).Add(new(big.Int).Mul(u2, u), feSeven))
+
+	var s, v *big.Int
+	if c&2 == 0 {
+		// The third candidate of xSwiftEC is only returned when the
+		// second one is not on the curve.
+		if feIsValidX(feMod(new(big.Int).Sub(new(big.Int).Neg(x), u))) {
+			return nil
+		}
+		v = x
+		den := new(big.Int).Add(u2, new(big.Int).Mul(u, v))
+		den.Add(den, new(big.Int).Mul(v, v))
+		feMod(den)
+		if den.Sign() == 0 {
+			return nil
+		}
+		s = new(big.Int).Neg(u3b)
+		s.Mul(s, feInv(den))
+		feMod(s)
+	} else {
+		s = feMod(new(big.Int).Sub(x, u))
+		if s.Sign() == 0 {
+			return nil
+		}
+		r := new(big.Int).Mul(big.NewInt(3), s)
+		r.Mul(r, u2)
+		r.Add(r, new(big.Int).Lsh(u3b, 2))
+		r.Mul(r, new(big.Int).Neg(s))
+		r = feSqrt(feMod(r))
+		if r == nil {
+			return nil
+		}
+		if c&1 != 0 && r.Sign() == 0 {
+			return nil
+		}
+		v = new(big.Int).Mul(r, feInv(s))
+		v.Sub(v, u)
+		v.Mul(v, feInv(big.NewInt(2)))
+		feMod(v)
+	}
+
+	w := feSqrt(s)
+	if w == nil {
+		return nil
+	}
+
+	// t = ±w * (u * (1 ± sqrt(-3)) / 2 + v)
+	m := big.NewInt(1)
+	if c&1 == 0 {
+		m.Sub(m, feMinus3Sqrt)
+	} else {
+		m.Add(m, feMinus3Sqrt)
+	}
+	t := new(big.Int).Mul(u, m)
+	t.Mul(t, feInv(big.NewInt(2)))
+	t.Add(t, v)
+	t.Mul(t, w)
+	if c&5 == 0 || c&5 == 5 {
+		t.Neg(t)
+	}
+	return feMod(t)
+}
+
+// EllSwiftEncode returns an ElligatorSwift encoding of the passed public key
+// using randomness read from rand.  The encoding is 64 bytes which are
+// indistinguishable from uniformly random data and only commits to the x
+// coordinate of the public key.
+func EllSwiftEncode(pubKey *PublicKey, rand io.Reader) ([EllSwiftPubKeyLen]byte, error) {
+	var enc [EllSwiftPubKeyLen]byte
+	x := pubKey.X
+	if !feIsValidX(x) {
+		return enc, errors.New("public key is not on the curve")
+	}
+
+	var buf [33]byte
+	for {
+		if _, err := io.ReadFull(rand, buf[:]); err != nil {
+			return enc, err
+		}
+		u := feMod(new(big.Int).SetBytes(buf[:32]))
+		if u.Sign() == 0 {
+			continue
+		}
+		t := xSwiftECInv(x, u, buf[32]&7)
+		if t == nil || xSwiftEC(u, t).Cmp(x) != 0 {
+			continue
+		}
+
+		u.FillBytes(enc[:32])
+		t.FillBytes(enc[32:])
+		return enc, nil
+	}
+}
+
+// EllSwiftDecode returns the public key with an even y coordinate whose x
+// coordinate is encoded by the passed ElligatorSwift encoding.  Every 64 byte
+// string is a valid encoding.
+func EllSwiftDecode(enc *[EllSwiftPubKeyLen]byte) *PublicKey {
+	curve := S256()
+	u := feMod(new(big.Int).SetBytes(enc[:32]))
+	t := feMod(new(big.Int).SetBytes(enc[32:]))
+	x := xSwiftEC(u, t)
+	y, err := decompressPoint(curve, x, false)
+	if err != nil {
+		// Not reachable since the x coordinate is always valid.
+		panic(err)
+	}
+	return NewPublicKey(curve, x, y)
+}
+
+// EllSwiftECDH returns the x coordinate of the point resulting from the
+// multiplication of the public key encoded by the passed ElligatorSwift
+// encoding with the private key.
+func EllSwiftECDH(privKey *PrivateKey, theirs *[EllSwiftPubKeyLen]byte) [32]byte {
+	var secret [32]byte
+	pubKey := EllSwiftDecode(theirs)
+	x, _ := pubKey.Curve.ScalarMult(pubKey.X, pubKey.Y, privKey.D.Bytes())
+	x.FillBytes(secret[:])
+	return secret
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package secp256k1
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+// TestEllSwift ensures ElligatorSwift encodings decode to the encoded public
+// keys and that both sides of an ECDH exchange using them agree on the secret.
+func TestEllSwift(t *testing.T) {
+	c := S256()
+	for i := 0; i < 32; i++ {
+		privKey1, err := GeneratePrivateKey(c)
+		if err != nil {
+			t.Fatalf("private key generation error: %v", err)
+		}
+		privKey2, err := GeneratePrivateKey(c)
+		if err != nil {
+			t.Fatalf("private key generation error: %v", err)
+		}
+
+		pk1x, pk1y := privKey1.Public()
+		pk1 := NewPublicKey(c, pk1x, pk1y)
+		pk2x, pk2y := privKey2.Public()
+		pk2 := NewPublicKey(c, pk2x, pk2y)
+
+		enc1, err := EllSwiftEncode(pk1, rand.Reader)
+		if err != nil {
+			t.Fatalf("EllSwiftEncode: unexpected error: %v", err)
+		}
+		enc2, err := EllSwiftEncode(pk2, rand.Reader)
+		if err != nil {
+			t.Fatalf("EllSwiftEncode: unexpected error: %v", err)
+		}
+		if got := EllSwiftDecode(&enc1); got.X.Cmp(pk1.X) != 0 {
+			t.Fatalf("EllSwiftDecode: got x %x, want %x", got.X, pk1.X)
+		}
+
+		secret1 := EllSwiftECDH(privKey1, &enc2)
+		secret2 := EllSwiftECDH(privKey2, &enc1)
+		if secret1 != secret2 {
+			t.Fatalf("EllSwiftECDH: secrets mismatch - first: %x, "+
+				"second: %x", secret1, secret2)
+		}
+	}
+
+	// Every 64 byte string, including ones made up of values which are not
+	// reduced modulo the field prime, must decode to a point on the curve.
+	var encs [3][EllSwiftPubKeyLen]byte
+	for i := range encs[1] {
+		encs[1][i] = 0xff
+	}
+	rand.Read(encs[2][:])
+	for _, enc := range encs {
+		pk := EllSwiftDecode(&enc)
+		if !c.IsOnCurve(pk.X, pk.Y) {
+			t.Fatalf("EllSwiftDecode: %x decoded to a point which is "+
+				"not on the curve", enc)
+		}
+	}
+}
+
+// TestXSwiftEC ensures ElligatorSwift encodings decode to the expected x
+// coordinates, including the special cases of the BIP324 mapping, values which
+// are not reduced modulo the field prime and each of the three candidates.
+func TestXSwiftEC(t *testing.T) {
+	tests := []struct {
+		name string
+		enc  string
+		x    string
+	}{{
+		name: "all zeros",
+		enc: "0000000000000000000000000000000000000000000000000000000000000000" +
+			"0000000000000000000000000000000000000000000000000000000000000000",
+		x: "edd1fd3e327ce90cc7a3542614289aee9682003e9cf7dcc9cf2ca9743be5aa0c",
+	}, {
+		name: "u is zero",
+		enc: "0000000000000000000000000000000000000000000000000000000000000000" +
+			"177743ca78937308b729ed18f795c827dbbfa6dfb76691142b15e2da971029da",
+		x: "052401db51a0c883d47563c6f57ee9ec63bd0ad7270bbf54e56f140f47a61d8b",
+	}, {
+		name: "t is zero",
+		enc: "811991e143be2ddd01dc3471127aad5e57f338c2d44c4b9b6ccd394b88f43c73" +
+			"0000000000000000000000000000000000000000000000000000000000000000",
+		x: "cb80f09c82d42d77af4e6e91741459b9f3d3c13b11719270bdf228d5de6919b6",
+	}, {
+		name: "u and t not reduced",
+		enc: "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f" +
+			"fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc30",
+		x: "edd1fd3e327ce90cc7a3542614289aee9682003e9cf7dcc9cf2ca9743be5aa0c",
+	}, {
+		name: "u and t all ones",
+		enc: "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" +
+			"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		x: "a9d2410259b9697cce4599ef2f96fbe8b47d53dcdff28ba28810f0607b89a740",
+	}, {
+		name: "u^3 + t^2 + 7 is zero",
+		enc: "8d3e42890c677d9d7d48a64aa933ea19295d70e7d912dfab416e666eb7839fb0" +
+			"f30b780eeea9f1d5fdf99171dbea9fdf5421fcd5ddc5aa25efe81b399fc000a0",
+		x: "72f57f1c32fbc28b72aff842fda0baf0fd38de308ab23a036998fcfb17c9ffe5",
+	}, {
+		name: "first candidate",
+		enc: "28854da342c16b2ff3ad732828a1bdcd0ad3f8ba8b906266449308e3fe85593c" +
+			"989e55979894a27a7ef122d5c4dc91fc51936b37fd08e22ba7c2f630daa77b65",
+		x: "6673334737dbc4db6d12e66ca5acfbcc0b41042b7897fdeae3720a61774371c8",
+	}, {
+		name: "second candidate",
+		enc: "61fd5bfbc27c3d9f55c723224c2158944b8aa5cc206b950c41a0f164a3a89a4e" +
+			"745ca3680f7cf12752eef6c11636b54b9ccb3775160bcd87e27bb9133c642bb0",
+		x: "5b9a351ad7e81216f8042e8648da6d48847f08697c5f021cab3bf281d48ad9af",
+	}, {
+		name: "third candidate",
+		enc: "94fbede5e95e51dbf34e5e828836d8faf00013d2e28256589e5023b56cfbb496" +
+			"064a751b885f722a89a59fba5103b631aef293ca0bb86814cba19f8e95c1f3bd",
+		x: "6c52bb243405273d13098938f08a3bb7ba478b5386220e7a67f1a62cb4cc1e27",
+	}}
+
+	for _, test := range tests {
+		var enc [EllSwiftPubKeyLen]byte
+		copy(enc[:], decodeHex(test.enc))
+		pk := EllSwiftDecode(&enc)
+		if pk.X.Cmp(fromHex(test.x)) != 0 {
+			t.Errorf("%s: unexpected x - got %064x, want %s", test.name,
+				pk.X, test.x)
+			continue
+		}
+		if pk.Y.Bit(0) != 0 {
+			t.Errorf("%s: y coordinate is odd", test.name)
+		}
+	}
+}
+
+// TestXSwiftECInv ensures each of the eight cases of the inverse mapping finds
+// the expected solution, or none, and that together they find every t which
+// maps to x.
+func TestXSwiftECInv(t *testing.T) {
+	tests := []struct {
+		name string
+		u    string
+		x    string
+		ts   [8]string
+	}{{
+		name: "eight solutions",
+		u:    "c19d3616b7c5e2c9a56566cee97a236f069f2c8e55a19a01014411df7f987b95",
+		x:    "3385e481db7ed554095489f156bf31365dced3584768d10ddbae6c1738a79b1f",
+		ts: [8]string{
+			"7da88c904438030cfd46fc77da75617c1096a88d8a338a8cfed0cd2a25a3bcb0",
+			"267a95524642c71a3d8ecdde05982550dc44ac1768552753da44c32312ef7e97",
+			"6fdeee634f96e8ca35b586bf92286c4911d3a7a5e5187e572db69e80064af6f1",
+			"61705ed1e435cd3eb8429450fb4274f837b79418542c76b02b171b8b5e72a51b",
+			"8257736fbbc7fcf302b90388258a9e83ef69577275cc7573012f32d4da5c3f7f",
+			"d9856aadb9bd38e5c2713221fa67daaf23bb53e897aad8ac25bb3cdbed107d98",
+			"9021119cb0691735ca4a79406dd793b6ee2c585a1ae781a8d249617ef9b5053e",
+			"9e8fa12e1bca32c147bd6baf04bd8b07c8486be7abd3894fd4e8e473a18d5714",
+		},
+	}, {
+		name: "-x - u is a valid x coordinate",
+		u:    "64559d960169d65c88d324fc0406dd0c03df4663c5ef179ecab98cfcaf0bc64c",
+		x:    "47d8b80535f12640fb76663c4f2724d944ece5247e013d5dd8bf2b9f968fa572",
+		ts: [8]string{
+			"",
+			"",
+			"e95c36cc45b4404cd12e1405230190756844733132c39b3688dc012228cb7ab4",
+			"c8ec50163aa778c376ed2b23877c2bad2a71901dd2fc53b5a67bade7163c0d21",
+			"",
+			"",
+			"16a3c933ba4bbfb32ed1ebfadcfe6f8a97bb8ccecd3c64c97723fedcd734817b",
+			"3713afe9c558873c8912d4dc7883d452d58e6fe22d03ac4a59845217e9c3ef0e",
+		},
+	}, {
+		name: "x equals u",
+		u:    "ed063adf34d7320dce78ad4ec5b149e32d370a31aeb59de0faf571cf20d46b09",
+		x:    "ed063adf34d7320dce78ad4ec5b149e32d370a31aeb59de0faf571cf20d46b09",
+		ts: [8]string{
+			"3d81d7c569355b8a4f3823f63147cd52f6e826f417feee61b7e86ba6bd15a6d6",
+			"0b1475d55921094cb49fee3f615cab3b5402bafc1c5f23cd473e6362ee9c965a",
+			"",
+			"",
+			"c27e283a96caa475b0c7dc09ceb832ad0917d90be801119e4817945842ea5559",
+			"f4eb8a2aa6def6b34b6011c09ea354c4abfd4503e3a0dc32b8c19c9c116365d5",
+			"",
+			"",
+		},
+	}, {
+		name: "r is zero",
+		u:    "2d9ef55a12b2ab86f794ad6f1f76f82be3fe9720f3e04f27c3e84098b24f7f11",
+		x:    "d2d644eb2df8b830e2d08400532ccaa6dddbcf1fea8d6fa4b3ce37f30d5d8488",
+		ts: [8]string{
+			"65a0e61c87fb0d6c6cc30c32e8b027513490e184fa73f4df8df32195f76642cb",
+			"689bd4782cec27beed0de6a0e4fa3b7c6f56fd6b914d5999fa187821eebdea18",
+			"78980410578da9fe44f4de42dab2dee3776af5c2b3eff093c8f80e5ccdc06b1e",
+			"",
+			"9a5f19e37804f293933cf3cd174fd8aecb6f1e7b058c0b20720cde690899b964",
+			"97642b87d313d84112f2195f1b05c48390a902946eb2a66605e787dd11421217",
+			"8767fbefa8725601bb0b21bd254d211c88950a3d4c100f6c3707f1a2323f9111",
+			"",
+		},
+	}}
+
+	for _, test := range tests {
+		u, x := fromHex(test.u), fromHex(test.x)
+		for c, want := range test.ts {
+			got := xSwiftECInv(x, u, byte(c))
+			if want == "" {
+				if got != nil {
+					t.Errorf("%s: case %d: unexpected solution %064x",
+						test.name, c, got)
+				}
+				continue
+			}
+			if got == nil || got.Cmp(fromHex(want)) != 0 {
+				t.Errorf("%s: case %d: unexpected solution - got %064x, "+
+					"want %s", test.name, c, got, want)
+				continue
+			}
+			if xSwiftEC(u, got).Cmp(x) != 0 {
+				t.Errorf("%s: case %d: solution does not map to x",
+					test.name, c)
+			}
+		}
+	}
+}
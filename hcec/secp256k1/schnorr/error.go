@@ -62,22 +62,38 @@ const (
 	// ErrNonmatchingR indicates that all signatures to be combined in a
 	// threshold signature failed to have a matching R value.
 	ErrNonmatchingR
+
+	// ErrMuSigRound indicates that a MuSig session operation was attempted
+	// before the previous round of the protocol completed, or after the
+	// data it provides was already set.
+	ErrMuSigRound
+
+	// ErrBadNonceCommitment indicates that a public nonce revealed by a
+	// MuSig signer does not match the nonce commitment it sent earlier.
+	ErrBadNonceCommitment
+
+	// ErrBadPartialSig indicates that a MuSig partial signature failed to
+	// verify against the public key and public nonce of its signer.
+	ErrBadPartialSig
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
 var errorCodeStrings = map[ErrorCode]string{
-	ErrBadInputSize:      "BadInputSize",
-	ErrInputValue:        "ErrInputValue",
-	ErrSchnorrHashValue:  "ErrSchnorrHashValue",
-	ErrPointNotOnCurve:   "ErrPointNotOnCurve",
-	ErrBadSigRYValue:     "ErrBadSigRYValue",
-	ErrBadSigRNotOnCurve: "ErrBadSigRNotOnCurve",
-	ErrRegenerateRPoint:  "ErrRegenerateRPoint",
-	ErrPubKeyOffCurve:    "ErrPubKeyOffCurve",
-	ErrRegenSig:          "ErrRegenSig",
-	ErrBadNonce:          "ErrBadNonce",
-	ErrZeroSigS:          "ErrZeroSigS",
-	ErrNonmatchingR:      "ErrNonmatchingR",
+	ErrBadInputSize:       "BadInputSize",
+	ErrInputValue:         "ErrInputValue",
+	ErrSchnorrHashValue:   "ErrSchnorrHashValue",
+	ErrPointNotOnCurve:    "ErrPointNotOnCurve",
+	ErrBadSigRYValue:      "ErrBadSigRYValue",
+	ErrBadSigRNotOnCurve:  "ErrBadSigRNotOnCurve",
+	ErrRegenerateRPoint:   "ErrRegenerateRPoint",
+	ErrPubKeyOffCurve:     "ErrPubKeyOffCurve",
+	ErrRegenSig:           "ErrRegenSig",
+	ErrBadNonce:           "ErrBadNonce",
+	ErrZeroSigS:           "ErrZeroSigS",
+	ErrNonmatchingR:       "ErrNonmatchingR",
+	ErrMuSigRound:         "ErrMuSigRound",
+	ErrBadNonceCommitment: "ErrBadNonceCommitment",
+	ErrBadPartialSig:      "ErrBadPartialSig",
 }
 
 // String returns the ErrorCode as a human-readable name.
@@ -0,0 +1,738 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package schnorr
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/hcec/secp256k1"
+)
+
+// References:
+//   [MuSig]: Simple Schnorr Multi-Signatures with Applications to Bitcoin
+//   https://eprint.iacr.org/2018/068
+//
+// The naive CombinePubkeys, PartialSign and CombineSigs functions in
+// threshold.go sum the public keys of the signers directly, which allows a
+// signer to choose its public key as a function of the other public keys and
+// control the group key (a rogue-key attack), and they require the signers to
+// exchange public nonces in a trusted way.  The MuSig protocol implemented here
+// prevents both by weighting each public key with a coefficient that commits
+// to the entire set of public keys and by requiring every signer to commit to
+// its public nonce before any public nonce is revealed.
+//
+// The protocol for n signers with public keys P_1 ... P_n is:
+//
+//   L   = BLAKE256(P_1 || ... || P_n)
+//   a_i = BLAKE256("HcMuSig/coefficient" || L || P_i) mod N
+//   X   = a_1*P_1 + ... + a_n*P_n                       (aggregate public key)
+//
+//   Round 1: each signer picks a secret nonce k_i, computes R_i = k_i*G and
+//            sends the commitment t_i = BLAKE256("HcMuSig/nonce" || R_i).
+//   Round 2: once all commitments are received, each signer reveals R_i and
+//            checks the revealed nonces against the commitments.
+//   Round 3: R = R_1 + ... + R_n.  When the y coordinate of R is odd, every
+//            signer negates its secret nonce so that R has an even y
+//            coordinate as required by the signature verification.  Each
+//            signer then sends the partial signature
+//              s_i = k_i - h*a_i*x_i mod N, where h = BLAKE256(R.x || m)
+//            and every partial signature is verified with
+//              s_i*G + h*a_i*P_i == R_i.
+//
+// The final signature (R.x, s_1 + ... + s_n) is a standard secp256k1 Schnorr
+// signature for the aggregate public key X, so it is accepted by Verify and
+// therefore by OP_CHECKSIGALT.
+
+const (
+	// MuSigCommitmentSize is the size of a serialized MuSig nonce
+	// commitment.
+	MuSigCommitmentSize = chainhash.HashSize
+
+	// MuSigPartialSigSize is the size of a serialized MuSig partial
+	// signature.
+	MuSigPartialSigSize = scalarSize
+
+	// MaxMuSigSigners is the maximum number of signers supported by a MuSig
+	// session.
+	MaxMuSigSigners = 255
+
+	// muSigSessionVersion is the version of the serialized MuSig session
+	// state.
+	muSigSessionVersion = 1
+)
+
+var (
+	// muSigCoefficientTag is the domain separation tag used when computing
+	// the key coefficients.
+	muSigCoefficientTag = []byte("HcMuSig/coefficient")
+
+	// muSigNonceTag is the domain separation tag used when committing to a
+	// public nonce.
+	muSigNonceTag = []byte("HcMuSig/nonce")
+
+	// muSigSecretNonceTag is the domain separation tag used when deriving a
+	// secret nonce.
+	muSigSecretNonceTag = []byte("HcMuSig/secretnonce")
+)
+
+// muSigKeyCoefficients returns the MuSig key coefficient for each of the
+// passed public keys.  The coefficients commit to the entire ordered set of
+// public keys.
+func muSigKeyCoefficients(curve *secp256k1.KoblitzCurve,
+	pks []*secp256k1.PublicKey) []*big.Int {
+	keySet := make([]byte, 0, len(pks)*PubKeyBytesLen)
+	for _, pk := range pks {
+		keySet = append(keySet, pk.SerializeCompressed()...)
+	}
+	l := chainhash.HashB(keySet)
+
+	coefficients := make([]*big.Int, len(pks))
+	for i, pk := range pks {
+		hashInput := make([]byte, 0, len(muSigCoefficientTag)+
+			len(l)+PubKeyBytesLen)
+		hashInput = append(hashInput, muSigCoefficientTag...)
+		hashInput = append(hashInput, l...)
+		hashInput = append(hashInput, pk.SerializeCompressed()...)
+		a := new(big.Int).SetBytes(chainhash.HashB(hashInput))
+		coefficients[i] = a.Mod(a, curve.N)
+	}
+
+	return coefficients
+}
+
+// muSigCombinePubkeys returns the MuSig aggregate public key for the passed
+// public keys and key coefficients.
+func muSigCombinePubkeys(curve *secp256k1.KoblitzCurve,
+	pks []*secp256k1.PublicKey, coefficients []*big.Int) (*secp256k1.PublicKey,
+	error) {
+	var sumX, sumY *big.Int
+	for i, pk := range pks {
+		x, y := curve.ScalarMult(pk.GetX(), pk.GetY(),
+			coefficients[i].Bytes())
+		if sumX == nil {
+			sumX, sumY = x, y
+			continue
+		}
+		sumX, sumY = curve.Add(sumX, sumY, x, y)
+	}
+
+	if sumX.Sign() == 0 && sumY.Sign() == 0 {
+		str := fmt.Sprintf("aggregate public key is the point at infinity")
+		return nil, schnorrError(ErrInputValue, str)
+	}
+	if !curve.IsOnCurve(sumX, sumY) {
+		str := fmt.Sprintf("aggregate public key is off curve")
+		return nil, schnorrError(ErrPointNotOnCurve, str)
+	}
+
+	return secp256k1.NewPublicKey(curve, sumX, sumY), nil
+}
+
+// checkMuSigPubkeys ensures the passed public keys are usable for a MuSig
+// session.
+func checkMuSigPubkeys(curve *secp256k1.KoblitzCurve,
+	pks []*secp256k1.PublicKey) error {
+	if len(pks) < 1 || len(pks) > MaxMuSigSigners {
+		str := fmt.Sprintf("invalid number of public keys (got %v, want "+
+			"1 to %v)", len(pks), MaxMuSigSigners)
+		return schnorrError(ErrInputValue, str)
+	}
+	for i, pk := range pks {
+		if pk == nil {
+			str := fmt.Sprintf("nil pubkey %v", i)
+			return schnorrError(ErrInputValue, str)
+		}
+		if !curve.IsOnCurve(pk.GetX(), pk.GetY()) {
+			str := fmt.Sprintf("pubkey %v is off curve", i)
+			return schnorrError(ErrPointNotOnCurve, str)
+		}
+	}
+
+	return nil
+}
+
+// MuSigCombinePubkeys returns the MuSig aggregate public key for the passed
+// ordered set of public keys.  Unlike CombinePubkeys, each public key is
+// weighted by a coefficient which commits to the whole set, so the aggregate
+// key is not vulnerable to rogue-key attacks.  All signers must use the same
+// order of public keys.
+func MuSigCombinePubkeys(curve *secp256k1.KoblitzCurve,
+	pks []*secp256k1.PublicKey) (*secp256k1.PublicKey, error) {
+	if err := checkMuSigPubkeys(curve, pks); err != nil {
+		return nil, err
+	}
+
+	return muSigCombinePubkeys(curve, pks, muSigKeyCoefficients(curve, pks))
+}
+
+// muSigNonceCommitment returns the commitment to the passed public nonce.
+func muSigNonceCommitment(pubNonce *secp256k1.PublicKey) []byte {
+	hashInput := make([]byte, 0, len(muSigNonceTag)+PubKeyBytesLen)
+	hashInput = append(hashInput, muSigNonceTag...)
+	hashInput = append(hashInput, pubNonce.SerializeCompressed()...)
+	return chainhash.HashB(hashInput)
+}
+
+// MuSigSession houses the state of one signer taking part in a MuSig signing
+// session.  The session advances through the nonce commitment, public nonce
+// and partial signature rounds described above, and the state may be
+// serialized between rounds with Serialize and restored with
+// ParseMuSigSession.
+//
+// The secret nonce of the signer is part of the serialized state until the
+// partial signature is created, so the serialized state must be protected like
+// a private key.  A session must never be restored more than once, since
+// signing two different messages or nonce sets with the same secret nonce
+// reveals the private key.
+type MuSigSession struct {
+	curve        *secp256k1.KoblitzCurve
+	pubKeys      []*secp256k1.PublicKey
+	coefficients []*big.Int
+	aggPubKey    *secp256k1.PublicKey
+	signerIdx    int
+	msg          []byte
+
+	// privNonce is the secret nonce of the signer.  It is zeroed and set
+	// to nil once the partial signature of the signer is created.
+	privNonce []byte
+
+	commitments [][]byte
+	pubNonces   []*secp256k1.PublicKey
+	partialSigs []*big.Int
+}
+
+// NewMuSigSession creates a new MuSig session for the signer with the passed
+// private key, which must correspond to the public key at index signerIdx, to
+// sign msg with the aggregate public key of pubKeys.  The secret nonce is
+// derived from the private key, the message, the set of public keys and 32
+// bytes read from the passed random source, which should be crypto/rand.Reader
+// and is used when nil.
+func NewMuSigSession(curve *secp256k1.KoblitzCurve,
+	pubKeys []*secp256k1.PublicKey, signerIdx int, priv *secp256k1.PrivateKey,
+	msg []byte, randSource io.Reader) (*MuSigSession, error) {
+	if len(msg) != scalarSize {
+		str := fmt.Sprintf("wrong size for message (got %v, want %v)",
+			len(msg), scalarSize)
+		return nil, schnorrError(ErrBadInputSize, str)
+	}
+	if err := checkMuSigPubkeys(curve, pubKeys); err != nil {
+		return nil, err
+	}
+	if signerIdx < 0 || signerIdx >= len(pubKeys) {
+		str := fmt.Sprintf("signer index %v is out of range", signerIdx)
+		return nil, schnorrError(ErrInputValue, str)
+	}
+	if priv == nil {
+		str := fmt.Sprintf("nil private key")
+		return nil, schnorrError(ErrInputValue, str)
+	}
+	pub := pubKeys[signerIdx]
+	pkx, pky := curve.ScalarBaseMult(priv.Serialize())
+	if pkx.Cmp(pub.GetX()) != 0 || pky.Cmp(pub.GetY()) != 0 {
+		str := fmt.Sprintf("private key does not match pubkey %v",
+			signerIdx)
+		return nil, schnorrError(ErrInputValue, str)
+	}
+
+	coefficients := muSigKeyCoefficients(curve, pubKeys)
+	aggPubKey, err := muSigCombinePubkeys(curve, pubKeys, coefficients)
+	if err != nil {
+		return nil, err
+	}
+
+	// Derive the secret nonce.  Mixing the private key and message into
+	// the random data protects against a weak random source.
+	if randSource == nil {
+		randSource = rand.Reader
+	}
+	var privNonce []byte
+	for {
+		var randBytes [32]byte
+		if _, err := io.ReadFull(randSource, randBytes[:]); err != nil {
+			return nil, err
+		}
+		privBytes := priv.Serialize()
+		hashInput := make([]byte, 0, len(muSigSecretNonceTag)+
+			len(randBytes)+len(privBytes)+len(msg)+PubKeyBytesLen)
+		hashInput = append(hashInput, muSigSecretNonceTag...)
+		hashInput = append(hashInput, randBytes[:]...)
+		hashInput = append(hashInput, privBytes...)
+		hashInput = append(hashInput, msg...)
+		hashInput = append(hashInput, aggPubKey.SerializeCompressed()...)
+		zeroSlice(privBytes)
+		k := chainhash.HashB(hashInput)
+		for i := range hashInput {
+			hashInput[i] = 0x00
+		}
+		bigK := new(big.Int).SetBytes(k)
+		if bigK.Sign() != 0 && bigK.Cmp(curve.N) < 0 {
+			bigK.SetInt64(0)
+			privNonce = k
+			break
+		}
+		bigK.SetInt64(0)
+	}
+
+	n := len(pubKeys)
+	s := &MuSigSession{
+		curve:        curve,
+		pubKeys:      pubKeys,
+		coefficients: coefficients,
+		aggPubKey:    aggPubKey,
+		signerIdx:    signerIdx,
+		msg:          msg,
+		privNonce:    privNonce,
+		commitments:  make([][]byte, n),
+		pubNonces:    make([]*secp256k1.PublicKey, n),
+		partialSigs:  make([]*big.Int, n),
+	}
+	pubNonceX, pubNonceY := curve.ScalarBaseMult(privNonce)
+	s.pubNonces[signerIdx] = secp256k1.NewPublicKey(curve, pubNonceX,
+		pubNonceY)
+	s.commitments[signerIdx] = muSigNonceCommitment(s.pubNonces[signerIdx])
+	return s, nil
+}
+
+// AggregatePubKey returns the MuSig aggregate public key the session signs
+// for.
+func (s *MuSigSession) AggregatePubKey() *secp256k1.PublicKey {
+	return s.aggPubKey
+}
+
+// NonceCommitment returns the nonce commitment of the signer to send to all
+// other signers in the first round.
+func (s *MuSigSession) NonceCommitment() []byte {
+	return copyBytesSlice(s.commitments[s.signerIdx])
+}
+
+// AddNonceCommitment adds the nonce commitment of the signer at the passed
+// index.
+func (s *MuSigSession) AddNonceCommitment(idx int, commitment []byte) error {
+	if err := s.checkPeerIdx(idx); err != nil {
+		return err
+	}
+	if len(commitment) != MuSigCommitmentSize {
+		str := fmt.Sprintf("wrong size for nonce commitment (got %v, "+
+			"want %v)", len(commitment), MuSigCommitmentSize)
+		return schnorrError(ErrBadInputSize, str)
+	}
+	if s.commitments[idx] != nil {
+		str := fmt.Sprintf("nonce commitment %v already set", idx)
+		return schnorrError(ErrMuSigRound, str)
+	}
+
+	s.commitments[idx] = copyBytesSlice(commitment)
+	return nil
+}
+
+// PublicNonce returns the public nonce of the signer to send to all other
+// signers in the second round.  It may only be revealed once the nonce
+// commitments of all signers have been added.
+func (s *MuSigSession) PublicNonce() (*secp256k1.PublicKey, error) {
+	for i, commitment := range s.commitments {
+		if commitment == nil {
+			str := fmt.Sprintf("missing nonce commitment %v", i)
+			return nil, schnorrError(ErrMuSigRound, str)
+		}
+	}
+
+	return s.pubNonces[s.signerIdx], nil
+}
+
+// AddPublicNonce adds the public nonce of the signer at the passed index after
+// checking it against the nonce commitment previously added for that signer.
+func (s *MuSigSession) AddPublicNonce(idx int,
+	pubNonce *secp256k1.PublicKey) error {
+	if err := s.checkPeerIdx(idx); err != nil {
+		return err
+	}
+	if pubNonce == nil {
+		str := fmt.Sprintf("nil public nonce")
+		return schnorrError(ErrInputValue, str)
+	}
+	if s.commitments[idx] == nil {
+		str := fmt.Sprintf("missing nonce commitment %v", idx)
+		return schnorrError(ErrMuSigRound, str)
+	}
+	if s.pubNonces[idx] != nil {
+		str := fmt.Sprintf("public nonce %v already set", idx)
+		return schnorrError(ErrMuSigRound, str)
+	}
+	if !s.curve.IsOnCurve(pubNonce.GetX(), pubNonce.GetY()) {
+		str := fmt.Sprintf("public nonce %v is off curve", idx)
+		return schnorrError(ErrPointNotOnCurve, str)
+	}
+	if !bytes.Equal(muSigNonceCommitment(pubNonce), s.commitments[idx]) {
+		str := fmt.Sprintf("public nonce %v does not match its "+
+			"commitment", idx)
+		return schnorrError(ErrBadNonceCommitment, str)
+	}
+
+	s.pubNonces[idx] = pubNonce
+	return nil
+}
+
+// combinedNonce returns the sum of all public nonces, whether the y coordinate
+// of the sum is odd, and the challenge hash h = BLAKE256(R.x || m).
+func (s *MuSigSession) combinedNonce() (*big.Int, bool, *big.Int, error) {
+	var rx, ry *big.Int
+	for i, pubNonce := range s.pubNonces {
+		if pubNonce == nil {
+			str := fmt.Sprintf("missing public nonce %v", i)
+			return nil, false, nil, schnorrError(ErrMuSigRound, str)
+		}
+		if rx == nil {
+			rx, ry = pubNonce.GetX(), pubNonce.GetY()
+			continue
+		}
+		rx, ry = s.curve.Add(rx, ry, pubNonce.GetX(), pubNonce.GetY())
+	}
+	if !s.curve.IsOnCurve(rx, ry) {
+		str := fmt.Sprintf("combined public nonce is off curve")
+		return nil, false, nil, schnorrError(ErrPointNotOnCurve, str)
+	}
+
+	rxb := BigIntToEncodedBytes(rx)
+	hashInput := make([]byte, 0, scalarSize*2)
+	hashInput = append(hashInput, rxb[:]...)
+	hashInput = append(hashInput, s.msg...)
+	h := new(big.Int).SetBytes(chainhash.HashB(hashInput))
+
+	// The signature would not verify with a hash larger than the order of
+	// the curve, so the session must be restarted with new nonces.
+	if h.Cmp(s.curve.N) >= 0 {
+		str := fmt.Sprintf("hash of (R || m) too big")
+		return nil, false, nil, schnorrError(ErrSchnorrHashValue, str)
+	}
+
+	return rx, ry.Bit(0) == 1, h, nil
+}
+
+// PartialSign creates the partial signature of the signer to send to all other
+// signers in the third round.  It may only be created once the public nonces
+// of all signers have been added, and only once per session since the secret
+// nonce is erased afterwards.
+func (s *MuSigSession) PartialSign(priv *secp256k1.PrivateKey) ([]byte,
+	error) {
+	if s.privNonce == nil {
+		str := fmt.Sprintf("secret nonce already used")
+		return nil, schnorrError(ErrMuSigRound, str)
+	}
+	if priv == nil {
+		str := fmt.Sprintf("nil private key")
+		return nil, schnorrError(ErrInputValue, str)
+	}
+	pub := s.pubKeys[s.signerIdx]
+	pkx, pky := s.curve.ScalarBaseMult(priv.Serialize())
+	if pkx.Cmp(pub.GetX()) != 0 || pky.Cmp(pub.GetY()) != 0 {
+		str := fmt.Sprintf("private key does not match pubkey %v",
+			s.signerIdx)
+		return nil, schnorrError(ErrInputValue, str)
+	}
+
+	_, negate, h, err := s.combinedNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	// s_i = k_i - h*a_i*x_i, negating k_i when R has an odd y coordinate.
+	k := new(big.Int).SetBytes(s.privNonce)
+	if negate {
+		k.Sub(s.curve.N, k)
+	}
+	x := new(big.Int).Set(priv.GetD())
+	sig := new(big.Int).Mul(h, s.coefficients[s.signerIdx])
+	sig.Mul(sig, x)
+	sig.Sub(k, sig)
+	sig.Mod(sig, s.curve.N)
+	k.SetInt64(0)
+	x.SetInt64(0)
+
+	// The secret nonce must never be used again.
+	zeroSlice(s.privNonce)
+	s.privNonce = nil
+
+	s.partialSigs[s.signerIdx] = sig
+	encoded := BigIntToEncodedBytes(sig)
+	return encoded[:], nil
+}
+
+// AddPartialSig adds the partial signature of the signer at the passed index
+// after verifying it against the public key and public nonce of that signer.
+func (s *MuSigSession) AddPartialSig(idx int, partialSig []byte) error {
+	if err := s.checkPeerIdx(idx); err != nil {
+		return err
+	}
+	if len(partialSig) != MuSigPartialSigSize {
+		str := fmt.Sprintf("wrong size for partial signature (got %v, "+
+			"want %v)", len(partialSig), MuSigPartialSigSize)
+		return schnorrError(ErrBadInputSize, str)
+	}
+	if s.partialSigs[idx] != nil {
+		str := fmt.Sprintf("partial signature %v already set", idx)
+		return schnorrError(ErrMuSigRound, str)
+	}
+	sig := new(big.Int).SetBytes(partialSig)
+	if sig.Cmp(s.curve.N) >= 0 {
+		str := fmt.Sprintf("partial signature %v is out of bounds", idx)
+		return schnorrError(ErrInputValue, str)
+	}
+	if err := s.verifyPartialSig(idx, sig); err != nil {
+		return err
+	}
+
+	s.partialSigs[idx] = sig
+	return nil
+}
+
+// verifyPartialSig verifies the partial signature of the signer at the passed
+// index by checking s_i*G + h*a_i*P_i == R_i, where R_i is negated when the
+// combined public nonce has an odd y coordinate.
+func (s *MuSigSession) verifyPartialSig(idx int, sig *big.Int) error {
+	_, negate, h, err := s.combinedNonce()
+	if err != nil {
+		return err
+	}
+
+	pub := s.pubKeys[idx]
+	e := new(big.Int).Mul(h, s.coefficients[idx])
+	e.Mod(e, s.curve.N)
+	lx, ly := s.curve.ScalarMult(pub.GetX(), pub.GetY(), e.Bytes())
+	rx, ry := s.curve.ScalarBaseMult(sig.Bytes())
+	sumX, sumY := s.curve.Add(lx, ly, rx, ry)
+
+	wantX, wantY := s.pubNonces[idx].GetX(), s.pubNonces[idx].GetY()
+	if negate {
+		wantY = new(big.Int).Sub(s.curve.P, wantY)
+	}
+	if sumX.Cmp(wantX) != 0 || sumY.Cmp(wantY) != 0 {
+		str := fmt.Sprintf("partial signature %v is invalid", idx)
+		return schnorrError(ErrBadPartialSig, str)
+	}
+
+	return nil
+}
+
+// CombinedSignature returns the final Schnorr signature for the aggregate
+// public key once the partial signatures of all signers have been added.  The
+// signature is verified before it is returned.
+func (s *MuSigSession) CombinedSignature() (*Signature, error) {
+	rx, _, _, err := s.combinedNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	sigS := new(big.Int)
+	for i, partialSig := range s.partialSigs {
+		if partialSig == nil {
+			str := fmt.Sprintf("missing partial signature %v", i)
+			return nil, schnorrError(ErrMuSigRound, str)
+		}
+		sigS.Add(sigS, partialSig)
+	}
+	sigS.Mod(sigS, s.curve.N)
+	if sigS.Sign() == 0 {
+		str := fmt.Sprintf("combined sig s %v is zero", sigS)
+		return nil, schnorrError(ErrZeroSigS, str)
+	}
+
+	sig := NewSignature(rx, sigS)
+	_, err = schnorrVerify(s.curve, sig.Serialize(), s.aggPubKey, s.msg,
+		chainhash.HashB)
+	if err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// checkPeerIdx ensures the passed index refers to a signer other than the
+// signer of the session.
+func (s *MuSigSession) checkPeerIdx(idx int) error {
+	if idx < 0 || idx >= len(s.pubKeys) {
+		str := fmt.Sprintf("signer index %v is out of range", idx)
+		return schnorrError(ErrInputValue, str)
+	}
+	if idx == s.signerIdx {
+		str := fmt.Sprintf("signer index %v is the local signer", idx)
+		return schnorrError(ErrInputValue, str)
+	}
+
+	return nil
+}
+
+// Flags which mark the per-signer fields present in a serialized session.
+const (
+	muSigHasCommitment = 1 << iota
+	muSigHasPubNonce
+	muSigHasPartialSig
+)
+
+// Serialize returns the serialized state of the session.  See the
+// MuSigSession documentation for the care required when storing it.
+//
+// The serialized format is:
+//   version (1) || number of signers (1) || signer index (1) ||
+//   message (32) || has secret nonce (1) || [secret nonce (32)] ||
+//   for each signer:
+//     public key (33) || flags (1) || [nonce commitment (32)] ||
+//     [public nonce (33)] || [partial signature (32)]
+func (s *MuSigSession) Serialize() []byte {
+	n := len(s.pubKeys)
+	b := make([]byte, 0, 4+scalarSize*2+n*(1+PubKeyBytesLen*2+scalarSize*2))
+	b = append(b, muSigSessionVersion, byte(n), byte(s.signerIdx))
+	b = append(b, s.msg...)
+	if s.privNonce != nil {
+		b = append(b, 1)
+		b = append(b, s.privNonce...)
+	} else {
+		b = append(b, 0)
+	}
+	for i, pk := range s.pubKeys {
+		b = append(b, pk.SerializeCompressed()...)
+		var flags byte
+		if s.commitments[i] != nil {
+			flags |= muSigHasCommitment
+		}
+		if s.pubNonces[i] != nil {
+			flags |= muSigHasPubNonce
+		}
+		if s.partialSigs[i] != nil {
+			flags |= muSigHasPartialSig
+		}
+		b = append(b, flags)
+		if s.commitments[i] != nil {
+			b = append(b, s.commitments[i]...)
+		}
+		if s.pubNonces[i] != nil {
+			b = append(b, s.pubNonces[i].SerializeCompressed()...)
+		}
+		if s.partialSigs[i] != nil {
+			b = append(b, BigIntToEncodedBytes(s.partialSigs[i])[:]...)
+		}
+	}
+
+	return b
+}
+
+// ParseMuSigSession restores a session from the serialized state returned by
+// Serialize.
+func ParseMuSigSession(curve *secp256k1.KoblitzCurve, b []byte) (*MuSigSession,
+	error) {
+	errShort := schnorrError(ErrBadInputSize, "serialized session is "+
+		"truncated")
+	if len(b) < 4+scalarSize {
+		return nil, errShort
+	}
+	if b[0] != muSigSessionVersion {
+		str := fmt.Sprintf("unsupported session version %v", b[0])
+		return nil, schnorrError(ErrInputValue, str)
+	}
+	n, signerIdx := int(b[1]), int(b[2])
+	if n == 0 || signerIdx >= n {
+		str := fmt.Sprintf("invalid signer index %v for %v signers",
+			signerIdx, n)
+		return nil, schnorrError(ErrInputValue, str)
+	}
+	msg := copyBytesSlice(b[3 : 3+scalarSize])
+	b = b[3+scalarSize:]
+
+	var privNonce []byte
+	hasPrivNonce := b[0] == 1
+	b = b[1:]
+	if hasPrivNonce {
+		if len(b) < scalarSize {
+			return nil, errShort
+		}
+		privNonce = copyBytesSlice(b[:scalarSize])
+		b = b[scalarSize:]
+	}
+
+	s := &MuSigSession{
+		curve:       curve,
+		pubKeys:     make([]*secp256k1.PublicKey, n),
+		signerIdx:   signerIdx,
+		msg:         msg,
+		privNonce:   privNonce,
+		commitments: make([][]byte, n),
+		pubNonces:   make([]*secp256k1.PublicKey, n),
+		partialSigs: make([]*big.Int, n),
+	}
+	for i := 0; i < n; i++ {
+		if len(b) < PubKeyBytesLen+1 {
+			return nil, errShort
+		}
+		pk, err := ParsePubKey(curve, b[:PubKeyBytesLen])
+		if err != nil {
+			return nil, err
+		}
+		s.pubKeys[i] = pk
+		flags := b[PubKeyBytesLen]
+		b = b[PubKeyBytesLen+1:]
+
+		if flags&muSigHasCommitment != 0 {
+			if len(b) < MuSigCommitmentSize {
+				return nil, errShort
+			}
+			s.commitments[i] = copyBytesSlice(b[:MuSigCommitmentSize])
+			b = b[MuSigCommitmentSize:]
+		}
+		if flags&muSigHasPubNonce != 0 {
+			if len(b) < PubKeyBytesLen {
+				return nil, errShort
+			}
+			pubNonce, err := ParsePubKey(curve, b[:PubKeyBytesLen])
+			if err != nil {
+				return nil, err
+			}
+			s.pubNonces[i] = pubNonce
+			b = b[PubKeyBytesLen:]
+		}
+		if flags&muSigHasPartialSig != 0 {
+			if len(b) < MuSigPartialSigSize {
+				return nil, errShort
+			}
+			s.partialSigs[i] = new(big.Int).SetBytes(
+				b[:MuSigPartialSigSize])
+			b = b[MuSigPartialSigSize:]
+		}
+	}
+	if len(b) != 0 {
+		str := fmt.Sprintf("%v trailing bytes in serialized session",
+			len(b))
+		return nil, schnorrError(ErrBadInputSize, str)
+	}
+
+	// The local signer always knows its own nonce commitment and public
+	// nonce.
+	if s.commitments[signerIdx] == nil || s.pubNonces[signerIdx] == nil {
+		str := "serialized session is missing the local public nonce"
+		return nil, schnorrError(ErrInputValue, str)
+	}
+
+	if err := checkMuSigPubkeys(curve, s.pubKeys); err != nil {
+		return nil, err
+	}
+	s.coefficients = muSigKeyCoefficients(curve, s.pubKeys)
+	aggPubKey, err := muSigCombinePubkeys(curve, s.pubKeys, s.coefficients)
+	if err != nil {
+		return nil, err
+	}
+	s.aggPubKey = aggPubKey
+
+	return s, nil
+}
+
+// copyBytesSlice returns a copy of the passed byte slice.
+func copyBytesSlice(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
@@ -0,0 +1,308 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package schnorr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+
+	"github.com/james-ray/hcd/hcec/secp256k1"
+)
+
+// muSigTestVector is a MuSig test vector.  The secret nonce of signer i is
+// derived using 32 bytes of value byte(i+1) as the random source.
+type muSigTestVector struct {
+	privKeys  []string
+	msg       string
+	aggPubKey string
+	sig       string
+}
+
+var muSigTestVectors = []muSigTestVector{
+	{
+		privKeys: []string{
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"0000000000000000000000000000000000000000000000000000000000000002",
+		},
+		msg:       "0101010101010101010101010101010101010101010101010101010101010101",
+		aggPubKey: "02425ca1c083946a58fc7f53081c7957cbaef24053ba3184520b60ffeeea3db2a1",
+		sig:       "e10678e000323d8cc58930c451c1b58737022a0ac298ce97e3c52cf6e0940ba8d197d8a45dce87b61e42a839265d9aeb06ec6b3d21b04a4268cc750f1d8d6ddf",
+	},
+	{
+		privKeys: []string{
+			"7A39B587A29317AFD793F701D26542E3081D93AB58EA28968C491D7E066A1798",
+			"A1FABBBA0C76DD5F255F1A1228D57ADBEBB935C6A80B2D877D3E153DBF946C33",
+			"41BB6B56F127607218B6C0D4DDC9595A6639172ECAFBB73134B16EF6852627DD",
+		},
+		msg:       "E63D5EE254ECC86A8BD6439E6434148BD02823E678B96816B08581EB33D2192A",
+		aggPubKey: "02c96f4c355c2010ad6865def58ac5b37110422b66229821a198ac064f1c76ee46",
+		sig:       "08109b0dc8967cd48d80760f5be804e0d64f45bd2f2789409dbd14bba7e83387421469928e5bfa568561cc0d0a8cad109db00a806a10da5f50ae241738b4158c",
+	},
+}
+
+// runMuSig runs the full MuSig protocol for the passed private keys, exchanging
+// the data of each round between the sessions and round tripping every session
+// through its serialized state between rounds.  The random source of signer i
+// returns bytes of value byte(i+1).
+func runMuSig(t *testing.T, curve *secp256k1.KoblitzCurve,
+	privKeys []*secp256k1.PrivateKey, msg []byte) ([]*MuSigSession,
+	*Signature) {
+	pubKeys := make([]*secp256k1.PublicKey, len(privKeys))
+	for i, priv := range privKeys {
+		pubKeys[i] = secp256k1.NewPublicKey(curve, priv.PublicKey.X,
+			priv.PublicKey.Y)
+	}
+
+	reserialize := func(sessions []*MuSigSession) {
+		for i, s := range sessions {
+			parsed, err := ParseMuSigSession(curve, s.Serialize())
+			if err != nil {
+				t.Fatalf("ParseMuSigSession %d: unexpected error: %v",
+					i, err)
+			}
+			if !bytes.Equal(parsed.Serialize(), s.Serialize()) {
+				t.Fatalf("ParseMuSigSession %d: mismatched round "+
+					"trip serialization", i)
+			}
+			sessions[i] = parsed
+		}
+	}
+
+	sessions := make([]*MuSigSession, len(privKeys))
+	for i, priv := range privKeys {
+		randSource := bytes.NewReader(bytes.Repeat([]byte{byte(i + 1)}, 32))
+		s, err := NewMuSigSession(curve, pubKeys, i, priv, msg, randSource)
+		if err != nil {
+			t.Fatalf("NewMuSigSession %d: unexpected error: %v", i, err)
+		}
+		sessions[i] = s
+	}
+	reserialize(sessions)
+
+	// Round 1: exchange nonce commitments.
+	for i, s := range sessions {
+		for j, peer := range sessions {
+			if i == j {
+				continue
+			}
+			err := peer.AddNonceCommitment(i, s.NonceCommitment())
+			if err != nil {
+				t.Fatalf("AddNonceCommitment %d->%d: unexpected "+
+					"error: %v", i, j, err)
+			}
+		}
+	}
+	reserialize(sessions)
+
+	// Round 2: exchange public nonces.
+	for i, s := range sessions {
+		pubNonce, err := s.PublicNonce()
+		if err != nil {
+			t.Fatalf("PublicNonce %d: unexpected error: %v", i, err)
+		}
+		for j, peer := range sessions {
+			if i == j {
+				continue
+			}
+			if err := peer.AddPublicNonce(i, pubNonce); err != nil {
+				t.Fatalf("AddPublicNonce %d->%d: unexpected error: "+
+					"%v", i, j, err)
+			}
+		}
+	}
+	reserialize(sessions)
+
+	// Round 3: exchange partial signatures.
+	for i, s := range sessions {
+		partialSig, err := s.PartialSign(privKeys[i])
+		if err != nil {
+			t.Fatalf("PartialSign %d: unexpected error: %v", i, err)
+		}
+		for j, peer := range sessions {
+			if i == j {
+				continue
+			}
+			if err := peer.AddPartialSig(i, partialSig); err != nil {
+				t.Fatalf("AddPartialSig %d->%d: unexpected error: "+
+					"%v", i, j, err)
+			}
+		}
+	}
+	reserialize(sessions)
+
+	var sig *Signature
+	for i, s := range sessions {
+		combined, err := s.CombinedSignature()
+		if err != nil {
+			t.Fatalf("CombinedSignature %d: unexpected error: %v", i,
+				err)
+		}
+		if sig != nil && !bytes.Equal(sig.Serialize(),
+			combined.Serialize()) {
+			t.Fatalf("CombinedSignature %d: signers disagree on the "+
+				"signature", i)
+		}
+		sig = combined
+	}
+
+	return sessions, sig
+}
+
+// TestMuSigVectors ensures the MuSig protocol produces the expected aggregate
+// public keys and signatures, and that the signatures verify with the standard
+// Schnorr verification used by OP_CHECKSIGALT.
+func TestMuSigVectors(t *testing.T) {
+	curve := secp256k1.S256()
+	for i, test := range muSigTestVectors {
+		privKeys := make([]*secp256k1.PrivateKey, len(test.privKeys))
+		for j, privHex := range test.privKeys {
+			privBytes, _ := hex.DecodeString(privHex)
+			privKeys[j], _ = secp256k1.PrivKeyFromBytes(curve, privBytes)
+		}
+		msg, _ := hex.DecodeString(test.msg)
+
+		sessions, sig := runMuSig(t, curve, privKeys, msg)
+		aggPubKey := sessions[0].AggregatePubKey()
+		gotAggPubKey := hex.EncodeToString(aggPubKey.SerializeCompressed())
+		if gotAggPubKey != test.aggPubKey {
+			t.Errorf("test #%d: mismatched aggregate pubkey -- want "+
+				"%s, got %s", i, test.aggPubKey, gotAggPubKey)
+		}
+		gotSig := hex.EncodeToString(sig.Serialize())
+		if gotSig != test.sig {
+			t.Errorf("test #%d: mismatched signature -- want %s, "+
+				"got %s", i, test.sig, gotSig)
+		}
+		if !Verify(curve, aggPubKey, msg, sig.GetR(), sig.GetS()) {
+			t.Errorf("test #%d: signature failed to verify", i)
+		}
+	}
+}
+
+// TestMuSigRandom runs the MuSig protocol for random sets of signers.
+func TestMuSigRandom(t *testing.T) {
+	curve := secp256k1.S256()
+	tRand := rand.New(rand.NewSource(54321))
+	for i := 0; i < 10; i++ {
+		numSigners := tRand.Intn(5) + 1
+		privKeys := randPrivKeyList(curve, numSigners)
+		msg := make([]byte, 32)
+		tRand.Read(msg)
+
+		sessions, sig := runMuSig(t, curve, privKeys, msg)
+		aggPubKey := sessions[0].AggregatePubKey()
+		if !Verify(curve, aggPubKey, msg, sig.GetR(), sig.GetS()) {
+			t.Fatalf("test #%d: signature failed to verify", i)
+		}
+
+		pubKeys := make([]*secp256k1.PublicKey, numSigners)
+		for j, priv := range privKeys {
+			pubKeys[j] = secp256k1.NewPublicKey(curve,
+				priv.PublicKey.X, priv.PublicKey.Y)
+		}
+		combined, err := MuSigCombinePubkeys(curve, pubKeys)
+		if err != nil {
+			t.Fatalf("test #%d: MuSigCombinePubkeys: unexpected "+
+				"error: %v", i, err)
+		}
+		if !bytes.Equal(combined.SerializeCompressed(),
+			aggPubKey.SerializeCompressed()) {
+			t.Fatalf("test #%d: mismatched aggregate pubkey", i)
+		}
+	}
+}
+
+// TestMuSigErrors ensures the MuSig session rejects out of order rounds,
+// nonces which do not match their commitments and invalid partial signatures.
+func TestMuSigErrors(t *testing.T) {
+	curve := secp256k1.S256()
+	privKeys := randPrivKeyList(curve, 2)
+	pubKeys := make([]*secp256k1.PublicKey, 2)
+	for i, priv := range privKeys {
+		pubKeys[i] = secp256k1.NewPublicKey(curve, priv.PublicKey.X,
+			priv.PublicKey.Y)
+	}
+	msg := bytes.Repeat([]byte{0x42}, 32)
+
+	checkCode := func(name string, err error, code ErrorCode) {
+		t.Helper()
+		serr, ok := err.(Error)
+		if !ok || serr.GetCode() != code {
+			t.Errorf("%s: mismatched error -- want %v, got %v", name,
+				code, err)
+		}
+	}
+
+	// The private key must match the signer index.
+	_, err := NewMuSigSession(curve, pubKeys, 1, privKeys[0], msg, nil)
+	checkCode("mismatched private key", err, ErrInputValue)
+
+	s0, err := NewMuSigSession(curve, pubKeys, 0, privKeys[0], msg, nil)
+	if err != nil {
+		t.Fatalf("NewMuSigSession: unexpected error: %v", err)
+	}
+	s1, err := NewMuSigSession(curve, pubKeys, 1, privKeys[1], msg, nil)
+	if err != nil {
+		t.Fatalf("NewMuSigSession: unexpected error: %v", err)
+	}
+
+	// Public nonces may not be revealed before all commitments are known.
+	_, err = s0.PublicNonce()
+	checkCode("early public nonce", err, ErrMuSigRound)
+
+	s0.AddNonceCommitment(1, s1.NonceCommitment())
+	s1.AddNonceCommitment(0, s0.NonceCommitment())
+	err = s0.AddNonceCommitment(1, s1.NonceCommitment())
+	checkCode("duplicate commitment", err, ErrMuSigRound)
+
+	// A public nonce which does not match the commitment is rejected.
+	s0Nonce, _ := s0.PublicNonce()
+	err = s0.AddPublicNonce(1, s0Nonce)
+	checkCode("mismatched public nonce", err, ErrBadNonceCommitment)
+
+	// Partial signatures may not be created before all nonces are known.
+	_, err = s0.PartialSign(privKeys[0])
+	checkCode("early partial signature", err, ErrMuSigRound)
+
+	s1Nonce, _ := s1.PublicNonce()
+	s0.AddPublicNonce(1, s1Nonce)
+	s1.AddPublicNonce(0, s0Nonce)
+
+	// A corrupted partial signature is rejected.
+	partialSig1, err := s1.PartialSign(privKeys[1])
+	if err != nil {
+		t.Fatalf("PartialSign: unexpected error: %v", err)
+	}
+	corrupt := make([]byte, len(partialSig1))
+	copy(corrupt, partialSig1)
+	corrupt[31] ^= 0x01
+	err = s0.AddPartialSig(1, corrupt)
+	checkCode("corrupt partial signature", err, ErrBadPartialSig)
+	if err := s0.AddPartialSig(1, partialSig1); err != nil {
+		t.Fatalf("AddPartialSig: unexpected error: %v", err)
+	}
+
+	// The secret nonce may only be used once.
+	_, err = s1.PartialSign(privKeys[1])
+	checkCode("reused secret nonce", err, ErrMuSigRound)
+
+	_, err = s0.CombinedSignature()
+	checkCode("missing partial signature", err, ErrMuSigRound)
+	if _, err := s0.PartialSign(privKeys[0]); err != nil {
+		t.Fatalf("PartialSign: unexpected error: %v", err)
+	}
+	sig, err := s0.CombinedSignature()
+	if err != nil {
+		t.Fatalf("CombinedSignature: unexpected error: %v", err)
+	}
+
+	// The signature does not verify for the naive sum of the public keys.
+	naive := CombinePubkeys(curve, pubKeys)
+	if Verify(curve, naive, msg, sig.GetR(), sig.GetS()) {
+		t.Errorf("signature verified for the naive key sum")
+	}
+}
@@ -76,6 +76,43 @@ func NewCreateRawTransactionCmd(inputs []TransactionInput, amounts map[string]fl
 	}
 }
 
+// CombinePsbtCmd defines the combinepsbt JSON-RPC command.
+type CombinePsbtCmd struct {
+	Txs []string
+}
+
+// NewCombinePsbtCmd returns a new instance which can be used to issue a
+// combinepsbt JSON-RPC command.
+func NewCombinePsbtCmd(txs []string) *CombinePsbtCmd {
+	return &CombinePsbtCmd{
+		Txs: txs,
+	}
+}
+
+// DebugScriptCmd defines the debugscript JSON-RPC command.
+type DebugScriptCmd struct {
+	HexTx         string
+	Index         uint32
+	PkScript      string
+	ScriptVersion *uint16 `jsonrpcdefault:"0"`
+}
+
+// NewDebugScriptCmd returns a new instance which can be used to issue a
+// debugscript JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewDebugScriptCmd(hexTx string, index uint32, pkScript string,
+	scriptVersion *uint16) *DebugScriptCmd {
+
+	return &DebugScriptCmd{
+		HexTx:         hexTx,
+		Index:         index,
+		PkScript:      pkScript,
+		ScriptVersion: scriptVersion,
+	}
+}
+
 // DecodeRawTransactionCmd defines the decoderawtransaction JSON-RPC command.
 type DecodeRawTransactionCmd struct {
 	HexTx string
@@ -89,6 +126,19 @@ func NewDecodeRawTransactionCmd(hexTx string) *DecodeRawTransactionCmd {
 	}
 }
 
+// DecodePsbtCmd defines the decodepsbt JSON-RPC command.
+type DecodePsbtCmd struct {
+	Psbt string
+}
+
+// NewDecodePsbtCmd returns a new instance which can be used to issue a
+// decodepsbt JSON-RPC command.
+func NewDecodePsbtCmd(psbt string) *DecodePsbtCmd {
+	return &DecodePsbtCmd{
+		Psbt: psbt,
+	}
+}
+
 // DecodeScriptCmd defines the decodescript JSON-RPC command.
 type DecodeScriptCmd struct {
 	HexScript string
@@ -741,7 +791,10 @@ func init() {
 	flags := UsageFlag(0)
 
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("combinepsbt", (*CombinePsbtCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("debugscript", (*DebugScriptCmd)(nil), flags)
+	MustRegisterCmd("decodepsbt", (*DecodePsbtCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
 	MustRegisterCmd("estimatefee", (*EstimateFeeCmd)(nil), flags)
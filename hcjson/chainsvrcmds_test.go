@@ -184,6 +184,44 @@ func TestChainSvrCmds(t *testing.T) {
 		// 				LockTime: hcjson.Int64(12312333333),
 		// 			},
 		// 		},
+		{
+			name: "combinepsbt",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("combinepsbt", `["aGNwc2J0","aGNwc2J0"]`)
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewCombinePsbtCmd([]string{"aGNwc2J0", "aGNwc2J0"})
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"combinepsbt","params":[["aGNwc2J0","aGNwc2J0"]],"id":1}`,
+			unmarshalled: &hcjson.CombinePsbtCmd{Txs: []string{"aGNwc2J0", "aGNwc2J0"}},
+		},
+		{
+			name: "debugscript",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("debugscript", "0100", 1, "51")
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewDebugScriptCmd("0100", 1, "51", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"debugscript","params":["0100",1,"51"],"id":1}`,
+			unmarshalled: &hcjson.DebugScriptCmd{
+				HexTx:         "0100",
+				Index:         1,
+				PkScript:      "51",
+				ScriptVersion: hcjson.Uint16(0),
+			},
+		},
+		{
+			name: "decodepsbt",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("decodepsbt", "aGNwc2J0")
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewDecodePsbtCmd("aGNwc2J0")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"decodepsbt","params":["aGNwc2J0"],"id":1}`,
+			unmarshalled: &hcjson.DecodePsbtCmd{Psbt: "aGNwc2J0"},
+		},
 		{
 			name: "decoderawtransaction",
 			newCmd: func() (interface{}, error) {
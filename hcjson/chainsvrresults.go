@@ -84,6 +84,92 @@ type DecodeScriptResult struct {
 	P2sh      string   `json:"p2sh"`
 }
 
+// DebugScriptStep models a single step of the script execution returned by
+// the debugscript command.
+type DebugScriptStep struct {
+	Script    int      `json:"script"`
+	Offset    int      `json:"offset"`
+	Opcode    string   `json:"opcode"`
+	Executed  bool     `json:"executed"`
+	Stack     []string `json:"stack"`
+	AltStack  []string `json:"altstack"`
+	CondStack []string `json:"condstack"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// DebugScriptResult models the data returned from the debugscript command.
+type DebugScriptResult struct {
+	Valid        bool              `json:"valid"`
+	Error        string            `json:"error,omitempty"`
+	SigScript    string            `json:"sigscript"`
+	PkScript     string            `json:"pkscript"`
+	RedeemScript string            `json:"redeemscript,omitempty"`
+	Steps        []DebugScriptStep `json:"steps"`
+}
+
+// PsbtScriptResult models a script of a partially signed transaction as
+// returned by the decodepsbt command.
+type PsbtScriptResult struct {
+	Asm  string `json:"asm"`
+	Hex  string `json:"hex"`
+	Type string `json:"type"`
+}
+
+// PsbtPrevOutResult models the previous output spent by an input of a
+// partially signed transaction as returned by the decodepsbt command.
+type PsbtPrevOutResult struct {
+	Amount       float64            `json:"amount"`
+	Version      uint16             `json:"version"`
+	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
+// PsbtPartialSigResult models a partial signature of an input of a partially
+// signed transaction as returned by the decodepsbt command.
+type PsbtPartialSigResult struct {
+	PubKey    string `json:"pubkey"`
+	SigType   string `json:"sigtype"`
+	Signature string `json:"signature"`
+}
+
+// PsbtBip32DerivResult models a key derivation of an input or output of a
+// partially signed transaction as returned by the decodepsbt command.
+type PsbtBip32DerivResult struct {
+	PubKey            string `json:"pubkey"`
+	MasterFingerprint string `json:"master_fingerprint"`
+	Path              string `json:"path"`
+}
+
+// PsbtInputResult models an input of a partially signed transaction as
+// returned by the decodepsbt command.
+type PsbtInputResult struct {
+	Utxo              *TxRawDecodeResult     `json:"utxo,omitempty"`
+	PrevOut           *PsbtPrevOutResult     `json:"prevout,omitempty"`
+	PartialSignatures []PsbtPartialSigResult `json:"partial_signatures,omitempty"`
+	SigHash           string                 `json:"sighash,omitempty"`
+	RedeemScript      *PsbtScriptResult      `json:"redeem_script,omitempty"`
+	Bip32Derivs       []PsbtBip32DerivResult `json:"bip32_derivs,omitempty"`
+	FinalScriptSig    *ScriptSig             `json:"final_scriptSig,omitempty"`
+	Unknown           map[string]string      `json:"unknown,omitempty"`
+}
+
+// PsbtOutputResult models an output of a partially signed transaction as
+// returned by the decodepsbt command.
+type PsbtOutputResult struct {
+	RedeemScript *PsbtScriptResult      `json:"redeem_script,omitempty"`
+	Bip32Derivs  []PsbtBip32DerivResult `json:"bip32_derivs,omitempty"`
+	Unknown      map[string]string      `json:"unknown,omitempty"`
+}
+
+// DecodePsbtResult models the data returned from the decodepsbt command.
+type DecodePsbtResult struct {
+	Tx       TxRawDecodeResult  `json:"tx"`
+	Unknown  map[string]string  `json:"unknown"`
+	Inputs   []PsbtInputResult  `json:"inputs"`
+	Outputs  []PsbtOutputResult `json:"outputs"`
+	Fee      *float64           `json:"fee,omitempty"`
+	Complete bool               `json:"complete"`
+}
+
 // GetAddedNodeInfoResultAddr models the data of the addresses portion of the
 // getaddednodeinfo command.
 type GetAddedNodeInfoResultAddr struct {
@@ -217,6 +303,8 @@ type GetPeerInfoResult struct {
 	CurrentHeight  int64   `json:"currentheight,omitempty"`
 	BanScore       int32   `json:"banscore"`
 	SyncNode       bool    `json:"syncnode"`
+	Transport      string  `json:"transport"`
+	SessionID      string  `json:"sessionid,omitempty"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -220,6 +220,62 @@ func methodUsageText(rtp reflect.Type, defaults map[int]reflect.Value, method st
 	return usageStr
 }
 
+// MethodParam describes a positional parameter of a command method.
+type MethodParam struct {
+	// Name is the lowercase name of the parameter.
+	Name string
+
+	// Usage is the usage of the parameter as shown in the one-line usage
+	// of the method.
+	Usage string
+
+	// Kind is the kind of the parameter's type with any pointer removed.
+	Kind reflect.Kind
+
+	// Optional specifies whether or not the parameter may be omitted.
+	Optional bool
+}
+
+// MethodParams returns a description of each positional parameter of the
+// provided method in order.  The provided method must be associated with a
+// registered type.  All commands provided by this package are registered by
+// default.
+func MethodParams(method string) ([]MethodParam, error) {
+	// Look up details about the provided method and error out if not
+	// registered.
+	registerLock.RLock()
+	rtp, ok := methodToConcreteType[method]
+	info := methodToInfo[method]
+	registerLock.RUnlock()
+	if !ok {
+		str := fmt.Sprintf("%q is not registered", method)
+		return nil, makeError(ErrUnregisteredMethod, str)
+	}
+
+	rt := rtp.Elem()
+	params := make([]MethodParam, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		rtf := rt.Field(i)
+		fieldType := rtf.Type
+		isOptional := fieldType.Kind() == reflect.Ptr
+		if isOptional {
+			fieldType = fieldType.Elem()
+		}
+
+		var defaultVal *reflect.Value
+		if defVal, ok := info.defaults[i]; ok {
+			defaultVal = &defVal
+		}
+		params = append(params, MethodParam{
+			Name:     strings.ToLower(rtf.Name),
+			Usage:    fieldUsage(rtf, defaultVal),
+			Kind:     fieldType.Kind(),
+			Optional: isOptional,
+		})
+	}
+	return params, nil
+}
+
 // MethodUsageText returns a one-line usage string for the provided method.  The
 // provided method must be associated with a registered type.  All commands
 // provided by this package are registered by default.
@@ -8,6 +8,19 @@
 
 package hcjson
 
+// BackupChainCmd defines the backupchain JSON-RPC command.
+type BackupChainCmd struct {
+	Dir string
+}
+
+// NewBackupChainCmd returns a new instance which can be used to issue a
+// backupchain JSON-RPC command.
+func NewBackupChainCmd(dir string) *BackupChainCmd {
+	return &BackupChainCmd{
+		Dir: dir,
+	}
+}
+
 // EstimateStakeDiffCmd defines the eststakedifficulty JSON-RPC command.
 type EstimateStakeDiffCmd struct {
 	Tickets *uint32
@@ -111,6 +124,23 @@ func NewExistsMempoolTxsCmd(txHashBlob string) *ExistsMempoolTxsCmd {
 	}
 }
 
+// GetCheckpointCandidatesCmd defines the getcheckpointcandidates JSON-RPC
+// command.
+type GetCheckpointCandidatesCmd struct {
+	Count *int `jsonrpcdefault:"5"`
+}
+
+// NewGetCheckpointCandidatesCmd returns a new instance which can be used to
+// issue a getcheckpointcandidates JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetCheckpointCandidatesCmd(count *int) *GetCheckpointCandidatesCmd {
+	return &GetCheckpointCandidatesCmd{
+		Count: count,
+	}
+}
+
 // GetCoinSupplyCmd defines the getcoinsupply JSON-RPC command.
 type GetCoinSupplyCmd struct{}
 
@@ -160,6 +190,19 @@ func NewGetStakeVersionsCmd(hash string, count int32) *GetStakeVersionsCmd {
 	}
 }
 
+// GetTicketInfoCmd defines the getticketinfo JSON-RPC command.
+type GetTicketInfoCmd struct {
+	TxHash string
+}
+
+// NewGetTicketInfoCmd returns a new instance which can be used to issue a
+// getticketinfo JSON-RPC command.
+func NewGetTicketInfoCmd(txHash string) *GetTicketInfoCmd {
+	return &GetTicketInfoCmd{
+		TxHash: txHash,
+	}
+}
+
 // GetTicketPoolValueCmd defines the getticketpoolvalue JSON-RPC command.
 type GetTicketPoolValueCmd struct{}
 
@@ -169,6 +212,26 @@ func NewGetTicketPoolValueCmd() *GetTicketPoolValueCmd {
 	return &GetTicketPoolValueCmd{}
 }
 
+// GetTicketsByAddressCmd defines the getticketsbyaddress JSON-RPC command.
+type GetTicketsByAddressCmd struct {
+	Address     string
+	StartHeight *uint32 `jsonrpcdefault:"0"`
+	EndHeight   *uint32
+}
+
+// NewGetTicketsByAddressCmd returns a new instance which can be used to issue
+// a getticketsbyaddress JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetTicketsByAddressCmd(address string, startHeight, endHeight *uint32) *GetTicketsByAddressCmd {
+	return &GetTicketsByAddressCmd{
+		Address:     address,
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+	}
+}
+
 // GetVoteInfoCmd returns voting results over a range of blocks.  Count
 // indicates how many blocks are walked backwards.
 type GetVoteInfoCmd struct {
@@ -183,6 +246,19 @@ func NewGetVoteInfoCmd(version uint32) *GetVoteInfoCmd {
 	}
 }
 
+// InvalidateBlockCmd defines the invalidateblock JSON-RPC command.
+type InvalidateBlockCmd struct {
+	BlockHash string
+}
+
+// NewInvalidateBlockCmd returns a new instance which can be used to issue an
+// invalidateblock JSON-RPC command.
+func NewInvalidateBlockCmd(blockHash string) *InvalidateBlockCmd {
+	return &InvalidateBlockCmd{
+		BlockHash: blockHash,
+	}
+}
+
 // LiveTicketsCmd is a type handling custom marshaling and
 // unmarshaling of livetickets JSON RPC commands.
 type LiveTicketsCmd struct{}
@@ -223,6 +299,19 @@ func NewRebroadcastWinnersCmd() *RebroadcastWinnersCmd {
 	return &RebroadcastWinnersCmd{}
 }
 
+// ReconsiderBlockCmd defines the reconsiderblock JSON-RPC command.
+type ReconsiderBlockCmd struct {
+	BlockHash string
+}
+
+// NewReconsiderBlockCmd returns a new instance which can be used to issue a
+// reconsiderblock JSON-RPC command.
+func NewReconsiderBlockCmd(blockHash string) *ReconsiderBlockCmd {
+	return &ReconsiderBlockCmd{
+		BlockHash: blockHash,
+	}
+}
+
 // TicketFeeInfoCmd defines the ticketsfeeinfo JSON-RPC command.
 type TicketFeeInfoCmd struct {
 	Blocks  *uint32
@@ -292,6 +381,7 @@ func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
 
+	MustRegisterCmd("backupchain", (*BackupChainCmd)(nil), flags)
 	MustRegisterCmd("estimatestakediff", (*EstimateStakeDiffCmd)(nil), flags)
 	MustRegisterCmd("existsaddress", (*ExistsAddressCmd)(nil), flags)
 	MustRegisterCmd("existsaddresses", (*ExistsAddressesCmd)(nil), flags)
@@ -300,16 +390,21 @@ func init() {
 	MustRegisterCmd("existsliveticket", (*ExistsLiveTicketCmd)(nil), flags)
 	MustRegisterCmd("existslivetickets", (*ExistsLiveTicketsCmd)(nil), flags)
 	MustRegisterCmd("existsmempooltxs", (*ExistsMempoolTxsCmd)(nil), flags)
+	MustRegisterCmd("getcheckpointcandidates", (*GetCheckpointCandidatesCmd)(nil), flags)
 	MustRegisterCmd("getcoinsupply", (*GetCoinSupplyCmd)(nil), flags)
 	MustRegisterCmd("getstakedifficulty", (*GetStakeDifficultyCmd)(nil), flags)
 	MustRegisterCmd("getstakeversioninfo", (*GetStakeVersionInfoCmd)(nil), flags)
 	MustRegisterCmd("getstakeversions", (*GetStakeVersionsCmd)(nil), flags)
+	MustRegisterCmd("getticketinfo", (*GetTicketInfoCmd)(nil), flags)
 	MustRegisterCmd("getticketpoolvalue", (*GetTicketPoolValueCmd)(nil), flags)
+	MustRegisterCmd("getticketsbyaddress", (*GetTicketsByAddressCmd)(nil), flags)
 	MustRegisterCmd("getvoteinfo", (*GetVoteInfoCmd)(nil), flags)
+	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
 	MustRegisterCmd("livetickets", (*LiveTicketsCmd)(nil), flags)
 	MustRegisterCmd("missedtickets", (*MissedTicketsCmd)(nil), flags)
 	MustRegisterCmd("rebroadcastmissed", (*RebroadcastMissedCmd)(nil), flags)
 	MustRegisterCmd("rebroadcastwinners", (*RebroadcastWinnersCmd)(nil), flags)
+	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
 	MustRegisterCmd("ticketfeeinfo", (*TicketFeeInfoCmd)(nil), flags)
 	MustRegisterCmd("ticketsforaddress", (*TicketsForAddressCmd)(nil), flags)
 	MustRegisterCmd("ticketvwap", (*TicketVWAPCmd)(nil), flags)
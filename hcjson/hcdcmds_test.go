@@ -30,6 +30,19 @@ func TestDcrdCmds(t *testing.T) {
 		marshalled   string
 		unmarshalled interface{}
 	}{
+		{
+			name: "backupchain",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("backupchain", "/tmp/backup")
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewBackupChainCmd("/tmp/backup")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"backupchain","params":["/tmp/backup"],"id":1}`,
+			unmarshalled: &hcjson.BackupChainCmd{
+				Dir: "/tmp/backup",
+			},
+		},
 		{
 			name: "debuglevel",
 			newCmd: func() (interface{}, error) {
@@ -43,6 +56,32 @@ func TestDcrdCmds(t *testing.T) {
 				LevelSpec: "trace",
 			},
 		},
+		{
+			name: "getcheckpointcandidates",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("getcheckpointcandidates")
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewGetCheckpointCandidatesCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcheckpointcandidates","params":[],"id":1}`,
+			unmarshalled: &hcjson.GetCheckpointCandidatesCmd{
+				Count: hcjson.Int(5),
+			},
+		},
+		{
+			name: "getcheckpointcandidates optional",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("getcheckpointcandidates", 2)
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewGetCheckpointCandidatesCmd(hcjson.Int(2))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcheckpointcandidates","params":[2],"id":1}`,
+			unmarshalled: &hcjson.GetCheckpointCandidatesCmd{
+				Count: hcjson.Int(2),
+			},
+		},
 		{
 			name: "getstakeversions",
 			newCmd: func() (interface{}, error) {
@@ -57,6 +96,50 @@ func TestDcrdCmds(t *testing.T) {
 				Count: 1,
 			},
 		},
+		{
+			name: "getticketinfo",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("getticketinfo", "123")
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewGetTicketInfoCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getticketinfo","params":["123"],"id":1}`,
+			unmarshalled: &hcjson.GetTicketInfoCmd{
+				TxHash: "123",
+			},
+		},
+		{
+			name: "getticketsbyaddress",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("getticketsbyaddress", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewGetTicketsByAddressCmd("1Address", nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getticketsbyaddress","params":["1Address"],"id":1}`,
+			unmarshalled: &hcjson.GetTicketsByAddressCmd{
+				Address:     "1Address",
+				StartHeight: hcjson.Uint32(0),
+				EndHeight:   nil,
+			},
+		},
+		{
+			name: "getticketsbyaddress optional",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("getticketsbyaddress", "1Address", 10, 20)
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewGetTicketsByAddressCmd("1Address",
+					hcjson.Uint32(10), hcjson.Uint32(20))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getticketsbyaddress","params":["1Address",10,20],"id":1}`,
+			unmarshalled: &hcjson.GetTicketsByAddressCmd{
+				Address:     "1Address",
+				StartHeight: hcjson.Uint32(10),
+				EndHeight:   hcjson.Uint32(20),
+			},
+		},
 		{
 			name: "getvoteinfo",
 			newCmd: func() (interface{}, error) {
@@ -70,6 +153,32 @@ func TestDcrdCmds(t *testing.T) {
 				Version: 1,
 			},
 		},
+		{
+			name: "invalidateblock",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("invalidateblock", "000000000000437482b6d47f82f374cde539440ddb108b0a76886f0d87d126b9")
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewInvalidateBlockCmd("000000000000437482b6d47f82f374cde539440ddb108b0a76886f0d87d126b9")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"invalidateblock","params":["000000000000437482b6d47f82f374cde539440ddb108b0a76886f0d87d126b9"],"id":1}`,
+			unmarshalled: &hcjson.InvalidateBlockCmd{
+				BlockHash: "000000000000437482b6d47f82f374cde539440ddb108b0a76886f0d87d126b9",
+			},
+		},
+		{
+			name: "reconsiderblock",
+			newCmd: func() (interface{}, error) {
+				return hcjson.NewCmd("reconsiderblock", "000000000000437482b6d47f82f374cde539440ddb108b0a76886f0d87d126b9")
+			},
+			staticCmd: func() interface{} {
+				return hcjson.NewReconsiderBlockCmd("000000000000437482b6d47f82f374cde539440ddb108b0a76886f0d87d126b9")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"reconsiderblock","params":["000000000000437482b6d47f82f374cde539440ddb108b0a76886f0d87d126b9"],"id":1}`,
+			unmarshalled: &hcjson.ReconsiderBlockCmd{
+				BlockHash: "000000000000437482b6d47f82f374cde539440ddb108b0a76886f0d87d126b9",
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -6,6 +6,21 @@
 
 package hcjson
 
+// BackupChainResult models the data returned from the backupchain command.
+type BackupChainResult struct {
+	Dir    string `json:"dir"`
+	DbType string `json:"dbtype"`
+	Files  int    `json:"files"`
+	Size   int64  `json:"size"`
+}
+
+// CheckpointCandidate models a checkpoint candidate returned from the
+// getcheckpointcandidates command.
+type CheckpointCandidate struct {
+	Height int64  `json:"height"`
+	Hash   string `json:"hash"`
+}
+
 // GetStakeDifficultyResult models the data returned from the
 // getstakedifficulty command.
 type GetStakeDifficultyResult struct {
@@ -56,6 +71,24 @@ type GetStakeVersionsResult struct {
 	StakeVersions []StakeVersions `json:"stakeversions"`
 }
 
+// GetTicketInfoResult models the data returned from the getticketinfo command
+// and for each ticket returned from the getticketsbyaddress command.
+type GetTicketInfoResult struct {
+	Hash           string  `json:"hash"`
+	Status         string  `json:"status"`
+	Price          float64 `json:"price"`
+	PurchaseBlock  string  `json:"purchaseblock"`
+	PurchaseHeight int64   `json:"purchaseheight"`
+	MaturityHeight int64   `json:"maturityheight"`
+	ExpiryHeight   int64   `json:"expiryheight"`
+	MissedBlock    string  `json:"missedblock,omitempty"`
+	MissedHeight   int64   `json:"missedheight,omitempty"`
+	SpendBlock     string  `json:"spendblock,omitempty"`
+	SpendHeight    int64   `json:"spendheight,omitempty"`
+	SpendTx        string  `json:"spendtx,omitempty"`
+	Reward         float64 `json:"reward,omitempty"`
+}
+
 // Choice models an individual choice inside an Agenda.
 type Choice struct {
 	Id          string  `json:"id"`
@@ -53,6 +53,16 @@ func TestHelpers(t *testing.T) {
 				return &val
 			}(),
 		},
+		{
+			name: "uint16",
+			f: func() interface{} {
+				return hcjson.Uint16(5)
+			},
+			expected: func() interface{} {
+				val := uint16(5)
+				return &val
+			}(),
+		},
 		{
 			name: "int32",
 			f: func() interface{} {
@@ -218,6 +218,13 @@ func detectNetworkForAddress(addr string) (*chaincfg.Params, error) {
 		return &chaincfg.TestNet2Params, nil
 	case chaincfg.SimNetParams.NetworkAddressPrefix:
 		return &chaincfg.SimNetParams, nil
+	case chaincfg.RegNetParams.NetworkAddressPrefix:
+		return &chaincfg.RegNetParams, nil
+	}
+
+	// Fall back to networks with custom parameters.
+	if params, ok := chaincfg.ParamsForAddrPrefix(networkChar); ok {
+		return params, nil
 	}
 
 	return nil, fmt.Errorf("unknown network type in string encoded address")
@@ -38,6 +38,15 @@ package provides the NewMaster function to create it from a cryptographically
 random seed.  The GenerateSeed function is provided as a convenient way to
 create a random seed for use with the NewMaster function.
 
+Mnemonic Seeds
+
+Seeds may also be backed up as BIP0039 English mnemonics.  GenerateMnemonic
+creates a new mnemonic from random entropy, ValidateMnemonic checks its words
+and checksum, and NewSeedFromMnemonic derives the 64-byte seed from a mnemonic
+and an optional passphrase.  The derived seed is passed to NewMaster, and the
+resulting master node is the root of both the secp256k1 and the BLISS account
+trees.
+
 Deriving Children
 
 Once you have created a tree root (or have deserialized an extended key as
@@ -76,6 +85,17 @@ looks like the following:
 	public key:   xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw
 	private key:  xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7
 
+BLISS Extended Keys
+
+Post-quantum BLISS account keys are derived from a secp256k1 private extended
+key with the SwitchChild function.  BLISS extended keys are serialized with
+their own version bytes, defined per network by the HDBlissPrivateKeyID and
+HDBlissPublicKeyID chain parameters, followed by the depth, the algorithm type,
+the parent fingerprint, the child number, the chain code, the much larger BLISS
+key data and a checksum.  NewKeyFromString recognizes the BLISS version bytes,
+so BLISS extended keys can be backed up and restored the same way as secp256k1
+ones.
+
 Network
 
 Extended keys are much like normal Hcd addresses in that they have version
@@ -52,16 +52,37 @@ const (
 	MaxSeedBytes = 64 // 512 bits
 
 	// serializedKeyLen is the length of a serialized public or private
-	// extended key.  It consists of 4 bytes version, 1 byte depth, 4 bytes
-	// fingerprint, 4 bytes child number, 32 bytes chain code, and 33 bytes
-	// public/private key data.
-	serializedKeyLen                = 4 + 1 + 1 + 4 + 4 + 32 + 33 // 79 bytes
-	serializedKeyLenForTest         = 4 + 1 + 4 + 4 + 32 + 33     // 78 bytes
-	blissserializedPubKeyLen        = 4 + 1 + 1 + 4 + 4 + 32 + 897
-	blissserializedPrivKeyLen       = 4 + 1 + 1 + 4 + 4 + 32 + 386
-	keyEc                     uint8 = 0
-	keyBliss                  uint8 = 1
-	BlissPubKeyLen                  = 897
+	// extended key which carries an explicit algorithm type byte.  It
+	// consists of 4 bytes version, 1 byte depth, 1 byte algorithm type, 4
+	// bytes fingerprint, 4 bytes child number, 32 bytes chain code, and 33
+	// bytes public/private key data.
+	serializedKeyLen = 4 + 1 + 1 + 4 + 4 + 32 + 33 // 79 bytes
+
+	// serializedKeyLenForTest is the length of a standard [BIP32] serialized
+	// public or private secp256k1 extended key which does not carry an
+	// algorithm type byte.
+	serializedKeyLenForTest = 4 + 1 + 4 + 4 + 32 + 33 // 78 bytes
+
+	// blissserializedPubKeyLen is the length of a serialized public BLISS
+	// extended key.  It consists of 4 bytes version, 1 byte depth, 1 byte
+	// algorithm type, 4 bytes fingerprint, 4 bytes child number, 32 bytes
+	// chain code, and 897 bytes public key data.
+	blissserializedPubKeyLen = 4 + 1 + 1 + 4 + 4 + 32 + BlissPubKeyLen
+
+	// blissserializedPrivKeyLen is the length of a serialized private BLISS
+	// extended key.  It has the same layout as a public BLISS extended key
+	// except the key data is a 0x00 padding byte followed by the 385 byte
+	// serialized private key.
+	blissserializedPrivKeyLen = 4 + 1 + 1 + 4 + 4 + 32 + 1 + BlissPrivKeyLen
+
+	keyEc    uint8 = 0
+	keyBliss uint8 = 1
+
+	// BlissPubKeyLen is the length of a serialized BLISS public key.
+	BlissPubKeyLen = 897
+
+	// BlissPrivKeyLen is the length of a serialized BLISS private key.
+	BlissPrivKeyLen = 385
 )
 
 var (
@@ -106,6 +127,17 @@ var (
 	// key is not the expected length.
 	ErrInvalidKeyLen = errors.New("the provided serialized extended key " +
 		"length is invalid")
+
+	// ErrWrongKeyType describes an error in which the algorithm type encoded
+	// with a serialized extended key does not match its version bytes.
+	ErrWrongKeyType = errors.New("the provided serialized extended key " +
+		"algorithm type does not match its version")
+
+	// ErrInvalidPadding describes an error in which the padding byte which
+	// precedes the private key data of a serialized BLISS extended key is
+	// not 0x00.
+	ErrInvalidPadding = errors.New("the provided serialized extended key " +
+		"has invalid private key padding")
 )
 
 // masterKey is the master key used along with a random seed used to generate
@@ -443,6 +475,9 @@ func paddedAppend(size uint, dst, src []byte) []byte {
 }
 
 // String returns the extended key as a human-readable base58-encoded string.
+//
+// BLISS extended keys are serialized with the BLISS extended key version bytes
+// of the network the key is associated with.
 func (k *ExtendedKey) String() (string, error) {
 	if len(k.key) == 0 {
 		return "", fmt.Errorf("zeroed extended key")
@@ -453,11 +488,29 @@ func (k *ExtendedKey) String() (string, error) {
 	typeByte := byte(k.algtype)
 	binary.BigEndian.PutUint32(childNumBytes[:], k.childNum)
 
+	version := k.version
+	if k.algtype == keyBliss && !chaincfg.IsHDBlissKeyID(version) {
+		// Keys derived or decoded before BLISS extended key version
+		// bytes existed carry the secp256k1 version, so map it to the
+		// BLISS version for the same network.
+		var err error
+		version, err = chaincfg.HDKeyToBlissKeyID(version)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	// The serialized format is:
 	//   version (4) || depth (1) || parent fingerprint (4)) ||
 	//   child num (4) || chain code (32) || key data (33) || checksum (4)
-	serializedBytes := make([]byte, 0, serializedKeyLen+4)
-	serializedBytes = append(serializedBytes, k.version...)
+	//
+	// BLISS extended keys additionally carry the algorithm type after the
+	// depth and the key data is either the 897 byte public key or a 0x00
+	// padding byte followed by the 385 byte private key:
+	//   version (4) || depth (1) || algtype (1) || parent fingerprint (4) ||
+	//   child num (4) || chain code (32) || key data (897/386) || checksum (4)
+	serializedBytes := make([]byte, 0, blissserializedPubKeyLen+4)
+	serializedBytes = append(serializedBytes, version...)
 	serializedBytes = append(serializedBytes, depthByte)
 	if k.algtype == keyBliss {
 		serializedBytes = append(serializedBytes, typeByte)
@@ -488,15 +541,22 @@ func (k *ExtendedKey) String() (string, error) {
 // passed hcd network.
 func (k *ExtendedKey) IsForNet(net *chaincfg.Params) bool {
 	return bytes.Equal(k.version, net.HDPrivateKeyID[:]) ||
-		bytes.Equal(k.version, net.HDPublicKeyID[:])
+		bytes.Equal(k.version, net.HDPublicKeyID[:]) ||
+		bytes.Equal(k.version, net.HDBlissPrivateKeyID[:]) ||
+		bytes.Equal(k.version, net.HDBlissPublicKeyID[:])
 }
 
 // SetNet associates the extended key, and any child keys yet to be derived from
 // it, with the passed network.
 func (k *ExtendedKey) SetNet(net *chaincfg.Params) {
-	if k.isPrivate {
+	switch {
+	case k.algtype == keyBliss && k.isPrivate:
+		k.version = net.HDBlissPrivateKeyID[:]
+	case k.algtype == keyBliss:
+		k.version = net.HDBlissPublicKeyID[:]
+	case k.isPrivate:
 		k.version = net.HDPrivateKeyID[:]
-	} else {
+	default:
 		k.version = net.HDPublicKeyID[:]
 	}
 }
@@ -573,8 +633,57 @@ func NewMaster(seed []byte, net *chaincfg.Params) (*ExtendedKey, error) {
 		parentFP, 0, 0, true, 0), nil
 }
 
+// newBlissKeyFromPayload returns a new BLISS extended key instance from a
+// checksum-verified serialized BLISS extended key payload.  The version bytes
+// of the payload must already be known to identify a BLISS extended key.
+func newBlissKeyFromPayload(payload []byte) (*ExtendedKey, error) {
+	// The serialized format is:
+	//   version (4) || depth (1) || algtype (1) || parent fingerprint (4) ||
+	//   child num (4) || chain code (32) || key data (897/386)
+	version := payload[:4]
+	_, err := chaincfg.HDPrivateKeyToPublicKeyID(version)
+	isPrivate := err == nil
+	switch {
+	case isPrivate && len(payload) != blissserializedPrivKeyLen:
+		return nil, ErrInvalidKeyLen
+	case !isPrivate && len(payload) != blissserializedPubKeyLen:
+		return nil, ErrInvalidKeyLen
+	}
+	if payload[5] != keyBliss {
+		return nil, ErrWrongKeyType
+	}
+
+	depth := uint16(payload[4])
+	parentFP := payload[6:10]
+	childNum := binary.BigEndian.Uint32(payload[10:14])
+	chainCode := payload[14:46]
+	keyData := payload[46:]
+	if isPrivate {
+		// The private key data is prefixed with a 0x00 padding byte.
+		if keyData[0] != 0x00 {
+			return nil, ErrInvalidPadding
+		}
+		keyData = keyData[1:]
+
+		// Ensure the private key deserializes correctly.
+		_, err := bliss.DeserializePrivateKey(keyData)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Ensure the public key parses correctly.
+		_, err := hccrypto.Bliss.ParsePubKey(keyData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newExtendedKey(version, keyData, chainCode, parentFP, depth,
+		childNum, isPrivate, keyBliss), nil
+}
+
 // NewKeyFromString returns a new extended key instance from a base58-encoded
-// extended key.
+// extended key.  Both secp256k1 and BLISS extended keys are supported.
 func NewKeyFromString(key string) (*ExtendedKey, error) {
 	// The base58-decoded extended key must consist of a serialized payload
 	// plus an additional 4 bytes for the checksum.
@@ -595,6 +704,11 @@ func NewKeyFromString(key string) (*ExtendedKey, error) {
 		return nil, ErrBadChecksum
 	}
 
+	// BLISS extended keys are identified by their dedicated version bytes.
+	if chaincfg.IsHDBlissKeyID(payload[:4]) {
+		return newBlissKeyFromPayload(payload)
+	}
+
 	// Deserialize each of the payload fields.
 	version := payload[:4]
 	depth := uint16(payload[4:5][0])
@@ -629,7 +743,10 @@ func NewKeyFromString(key string) (*ExtendedKey, error) {
 				return nil, ErrUnusableSeed
 			}
 		case algtype == keyBliss:
-			//TODO
+			_, err := bliss.DeserializePrivateKey(keyData)
+			if err != nil {
+				return nil, err
+			}
 		default:
 			return nil, ErrUnknownAlg
 		}
@@ -643,7 +760,6 @@ func NewKeyFromString(key string) (*ExtendedKey, error) {
 				return nil, err
 			}
 		case algtype == keyBliss:
-			// TODO
 			_, err := hccrypto.Bliss.ParsePubKey(keyData)
 			if err != nil {
 				return nil, err
@@ -685,6 +801,17 @@ func (k *ExtendedKey) SwitchChild(i uint32, acctype uint8) (*ExtendedKey, error)
 	if k.algtype != keyEc && !k.isPrivate {
 		return nil, ErrDerivePublicFromPublic
 	}
+
+	// BLISS children are associated with the BLISS extended key version
+	// bytes of the parent's network.
+	version := k.version
+	if acctype == keyBliss && !chaincfg.IsHDBlissKeyID(version) {
+		var err error
+		version, err = chaincfg.HDKeyToBlissKeyID(version)
+		if err != nil {
+			return nil, err
+		}
+	}
 	keyLen := 33
 	data := make([]byte, keyLen+4)
 	copy(data[1:], k.key)
@@ -730,6 +857,6 @@ func (k *ExtendedKey) SwitchChild(i uint32, acctype uint8) (*ExtendedKey, error)
 	// The fingerprint of the parent for the derived child is the first 4
 	// bytes of the RIPEMD160(SHA256(parentPubKey)).
 	parentFP := hcutil.Hash160(k.pubKeyBytes())[:4]
-	return newExtendedKey(k.version, childKey, childChainCode, parentFP,
+	return newExtendedKey(version, childKey, childChainCode, parentFP,
 		k.depth+1, i, isPrivate, acctype), nil
 }
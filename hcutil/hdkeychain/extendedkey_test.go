@@ -18,6 +18,8 @@ import (
 	"testing"
 
 	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/hcutil/base58"
 	"github.com/james-ray/hcd/hcutil/hdkeychain"
 )
 
@@ -982,6 +984,108 @@ func TestZero(t *testing.T) {
 	}
 }
 
+// TestBlissSerialization ensures BLISS extended keys serialize with the BLISS
+// extended key version bytes of their network and round trip through
+// NewKeyFromString.
+func TestBlissSerialization(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x2a}, hdkeychain.RecommendedSeedLen)
+	nets := []*chaincfg.Params{&chaincfg.MainNetParams,
+		&chaincfg.TestNet2Params, &chaincfg.SimNetParams}
+	for _, net := range nets {
+		master, err := hdkeychain.NewMaster(seed, net)
+		if err != nil {
+			t.Errorf("NewMaster (%s): unexpected error: %v", net.Name,
+				err)
+			continue
+		}
+		blissKey, err := master.SwitchChild(hdkeychain.HardenedKeyStart, 1)
+		if err != nil {
+			t.Errorf("SwitchChild (%s): unexpected error: %v", net.Name,
+				err)
+			continue
+		}
+		if !blissKey.IsForNet(net) {
+			t.Errorf("IsForNet (%s): BLISS key is not for its network",
+				net.Name)
+			continue
+		}
+
+		pubKey, err := blissKey.Neuter()
+		if err != nil {
+			t.Errorf("Neuter (%s): unexpected error: %v", net.Name, err)
+			continue
+		}
+
+		for _, key := range []*hdkeychain.ExtendedKey{blissKey, pubKey} {
+			serialized, err := key.String()
+			if err != nil {
+				t.Errorf("String (%s): unexpected error: %v",
+					net.Name, err)
+				continue
+			}
+			decoded, err := hdkeychain.NewKeyFromString(serialized)
+			if err != nil {
+				t.Errorf("NewKeyFromString (%s): unexpected error: %v",
+					net.Name, err)
+				continue
+			}
+			if decoded.GetAlgType() != 1 {
+				t.Errorf("NewKeyFromString (%s): mismatched algtype "+
+					"-- want 1, got %d", net.Name,
+					decoded.GetAlgType())
+				continue
+			}
+			if decoded.IsPrivate() != key.IsPrivate() {
+				t.Errorf("NewKeyFromString (%s): mismatched private "+
+					"flag -- want %v, got %v", net.Name,
+					key.IsPrivate(), decoded.IsPrivate())
+				continue
+			}
+			reserialized, err := decoded.String()
+			if err != nil {
+				t.Errorf("String (%s): unexpected error: %v",
+					net.Name, err)
+				continue
+			}
+			if reserialized != serialized {
+				t.Errorf("String (%s): mismatched round trip "+
+					"serialization", net.Name)
+				continue
+			}
+		}
+
+		// Corrupting the checksum must be detected.
+		serialized, _ := pubKey.String()
+		corrupt := []byte(serialized)
+		if corrupt[len(corrupt)-1] == '1' {
+			corrupt[len(corrupt)-1] = '2'
+		} else {
+			corrupt[len(corrupt)-1] = '1'
+		}
+		_, err = hdkeychain.NewKeyFromString(string(corrupt))
+		if err != hdkeychain.ErrBadChecksum {
+			t.Errorf("NewKeyFromString (%s): mismatched error -- "+
+				"want %v, got %v", net.Name,
+				hdkeychain.ErrBadChecksum, err)
+		}
+
+		// A private key whose padding byte is not 0x00 must be
+		// rejected as malformed.
+		serialized, _ = blissKey.String()
+		decoded := base58.Decode(serialized)
+		payload := decoded[:len(decoded)-4]
+		payload[46] = 0x01
+		payload = append(payload, chainhash.HashB(chainhash.HashB(
+			payload))[:4]...)
+		_, err = hdkeychain.NewKeyFromString(base58.Encode(payload))
+		if err != hdkeychain.ErrInvalidPadding {
+			t.Errorf("NewKeyFromString (%s): mismatched error -- "+
+				"want %v, got %v", net.Name,
+				hdkeychain.ErrInvalidPadding, err)
+		}
+	}
+}
+
 // mockNetParams implements the NetworkParams interface and is used throughout
 // the tests to mock multiple networks.
 type mockNetParams struct {
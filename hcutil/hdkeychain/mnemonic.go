@@ -0,0 +1,221 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdkeychain
+
+// References:
+//   [BIP39]: BIP0039 - Mnemonic code for generating deterministic keys
+//   https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// MinMnemonicEntropyBytes is the minimum number of bytes of entropy
+	// allowed for a mnemonic.  It encodes to 12 words.
+	MinMnemonicEntropyBytes = 16 // 128 bits
+
+	// MaxMnemonicEntropyBytes is the maximum number of bytes of entropy
+	// allowed for a mnemonic.  It encodes to 24 words.
+	MaxMnemonicEntropyBytes = 32 // 256 bits
+
+	// RecommendedMnemonicEntropyLen is the recommended number of bytes of
+	// entropy for a mnemonic.  It encodes to 24 words.
+	RecommendedMnemonicEntropyLen = 32 // 256 bits
+
+	// MnemonicSeedLen is the length in bytes of a seed derived from a
+	// mnemonic.  It is in the range allowed by NewMaster.
+	MnemonicSeedLen = 64 // 512 bits
+
+	// mnemonicSeedIterations is the number of PBKDF2 iterations used to
+	// derive a seed from a mnemonic per [BIP39].
+	mnemonicSeedIterations = 2048
+
+	// mnemonicBitsPerWord is the number of bits each mnemonic word encodes.
+	mnemonicBitsPerWord = 11
+)
+
+var (
+	// ErrInvalidEntropyLen describes an error in which the provided entropy
+	// for a mnemonic is not in the allowed range or is not a multiple of 32
+	// bits.
+	ErrInvalidEntropyLen = fmt.Errorf("mnemonic entropy length must be a "+
+		"multiple of 32 bits between %d and %d bits",
+		MinMnemonicEntropyBytes*8, MaxMnemonicEntropyBytes*8)
+
+	// ErrInvalidMnemonicLen describes an error in which the provided
+	// mnemonic does not contain a valid number of words.
+	ErrInvalidMnemonicLen = errors.New("mnemonic must contain 12, 15, 18, " +
+		"21, or 24 words")
+
+	// ErrMnemonicChecksum describes an error in which the checksum encoded
+	// in a mnemonic does not match the calculated value.
+	ErrMnemonicChecksum = errors.New("bad mnemonic checksum")
+)
+
+// wordIndexes maps each word of the English word list to its index.  It is
+// populated on package init.
+var wordIndexes = make(map[string]uint16, len(englishWordList))
+
+func init() {
+	for i, word := range englishWordList {
+		wordIndexes[word] = uint16(i)
+	}
+}
+
+// NewMnemonic returns the [BIP39] English mnemonic which encodes the provided
+// entropy.  The entropy length must be a multiple of 4 bytes between 16 and 32
+// bytes (128 to 256 bits).
+//
+// The mnemonic consists of the entropy followed by the first ENT/32 bits of its
+// SHA256 hash as a checksum, split into 11-bit groups which each select a word
+// from the word list.
+func NewMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < MinMnemonicEntropyBytes ||
+		len(entropy) > MaxMnemonicEntropyBytes || len(entropy)%4 != 0 {
+		return "", ErrInvalidEntropyLen
+	}
+
+	// Append the checksum byte.  At most 8 bits of it are used since the
+	// entropy is at most 256 bits.
+	checksum := sha256.Sum256(entropy)
+	data := make([]byte, len(entropy)+1)
+	copy(data, entropy)
+	data[len(entropy)] = checksum[0]
+
+	numWords := (len(entropy)*8 + len(entropy)/4) / mnemonicBitsPerWord
+	words := make([]string, numWords)
+	for i := range words {
+		words[i] = englishWordList[extractBits(data, i*mnemonicBitsPerWord,
+			mnemonicBitsPerWord)]
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// GenerateMnemonic returns a new [BIP39] English mnemonic which encodes
+// cryptographically secure random entropy of the given length in bytes.  The
+// length must be a multiple of 4 between 16 and 32 (128 to 256 bits).  The
+// recommended length is 32 (256 bits) as defined by the
+// RecommendedMnemonicEntropyLen constant.
+func GenerateMnemonic(length uint8) (string, error) {
+	if length < MinMnemonicEntropyBytes ||
+		length > MaxMnemonicEntropyBytes || length%4 != 0 {
+		return "", ErrInvalidEntropyLen
+	}
+
+	// GenerateSeed enforces a wider range than required for mnemonic
+	// entropy, so the length checked above is always valid.
+	entropy, err := GenerateSeed(length)
+	if err != nil {
+		return "", err
+	}
+	defer zero(entropy)
+
+	return NewMnemonic(entropy)
+}
+
+// MnemonicToEntropy decodes the provided [BIP39] English mnemonic and returns
+// the entropy it encodes.  Words may be separated by any amount of whitespace
+// and are matched case-insensitively.  An error is returned when the mnemonic
+// has an invalid number of words, contains a word which is not in the word
+// list, or fails the checksum.
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, ErrInvalidMnemonicLen
+	}
+
+	// Pack the 11-bit word indexes back into the entropy followed by the
+	// checksum bits.
+	totalBits := len(words) * mnemonicBitsPerWord
+	checksumBits := totalBits / 33
+	entropyLen := (totalBits - checksumBits) / 8
+	data := make([]byte, (totalBits+7)/8)
+	for i, word := range words {
+		idx, ok := wordIndexes[word]
+		if !ok {
+			return nil, fmt.Errorf("word #%d (%q) is not in the "+
+				"mnemonic word list", i, word)
+		}
+		putBits(data, i*mnemonicBitsPerWord, mnemonicBitsPerWord, idx)
+	}
+
+	entropy := data[:entropyLen]
+	checksum := sha256.Sum256(entropy)
+	want := extractBits(checksum[:], 0, checksumBits)
+	got := extractBits(data, entropyLen*8, checksumBits)
+	if want != got {
+		zero(data)
+		return nil, ErrMnemonicChecksum
+	}
+
+	return entropy, nil
+}
+
+// ValidateMnemonic returns an error when the provided [BIP39] English mnemonic
+// is invalid.  See MnemonicToEntropy for the checks performed.
+func ValidateMnemonic(mnemonic string) error {
+	entropy, err := MnemonicToEntropy(mnemonic)
+	if err != nil {
+		return err
+	}
+	zero(entropy)
+	return nil
+}
+
+// NewSeedFromMnemonic validates the provided [BIP39] English mnemonic and
+// derives a 64-byte seed from it and the optional passphrase using
+// PBKDF2-HMAC-SHA512 with 2048 iterations and the salt "mnemonic" followed by
+// the passphrase.  The returned seed may be passed directly to NewMaster, and
+// the resulting master node is the root of both the secp256k1 and the BLISS
+// account trees.
+//
+// NOTE: [BIP39] requires the mnemonic and passphrase to be NFKD normalized.
+// The word list is ASCII, so the mnemonic is normalized by lowercasing and
+// joining its words with single spaces, however the passphrase is used as is
+// and callers must normalize any non-ASCII passphrase themselves.
+func NewSeedFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+
+	normalized := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	salt := []byte("mnemonic" + passphrase)
+	return pbkdf2.Key([]byte(normalized), salt, mnemonicSeedIterations,
+		MnemonicSeedLen, sha512.New), nil
+}
+
+// extractBits returns the n bits (at most 16) of data starting at the provided
+// bit offset as an integer, most significant bit first.
+func extractBits(data []byte, offset, n int) uint16 {
+	var v uint16
+	for i := offset; i < offset+n; i++ {
+		v <<= 1
+		if data[i/8]&(0x80>>uint(i%8)) != 0 {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// putBits sets the n bits of data starting at the provided bit offset to the
+// low n bits of v, most significant bit first.
+func putBits(data []byte, offset, n int, v uint16) {
+	for i := 0; i < n; i++ {
+		if v&(1<<uint(n-1-i)) != 0 {
+			bit := offset + i
+			data[bit/8] |= 0x80 >> uint(bit%8)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdkeychain_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/james-ray/hcd/chaincfg"
+	"github.com/james-ray/hcd/hcutil/hdkeychain"
+)
+
+// TestMnemonicVectors tests the mnemonic encoding, decoding and seed derivation
+// against the [BIP39] reference vectors which use the passphrase "TREZOR".
+func TestMnemonicVectors(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			entropy:  "00000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+			seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			entropy:  "ffffffffffffffffffffffffffffffff",
+			mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			seed:     "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			entropy:  "000000000000000000000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon agent",
+			seed:     "035895f2f481b1b0f01fcf8c289c794660b289981a78f8106447707fdd9666ca06da5a9a565181599b79f53b844d8a71dd9f439c52a3d7b3e8a79c906ac845fa",
+		},
+		{
+			entropy:  "0000000000000000000000000000000000000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			seed:     "bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+		{
+			entropy:  "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+			seed:     "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+		},
+		{
+			entropy:  "77c2b00716cec7213839159e404db50d",
+			mnemonic: "jelly better achieve collect unaware mountain thought cargo oxygen act hood bridge",
+			seed:     "b5b6d0127db1a9d2226af0c3346031d77af31e918dba64287a1b44b8ebf63cdd52676f672a290aae502472cf2d602c051f3e6f18055e84e4c43897fc4e51a6ff",
+		},
+		{
+			entropy:  "15da872c95a13dd738fbf50e427583ad61f18fd99f628c417a61cf8343c90419",
+			mnemonic: "beyond stage sleep clip because twist token leaf atom beauty genius food business side grid unable middle armed observe pair crouch tonight away coconut",
+			seed:     "b15509eaa2d09d3efd3e006ef42151b30367dc6e3aa5e44caba3fe4d3e352e65101fbdb86a96776b91946ff06f8eac594dc6ee1d3e82a42dfe1b40fef6bcc3fd",
+		},
+	}
+
+	for i, test := range tests {
+		entropy, err := hex.DecodeString(test.entropy)
+		if err != nil {
+			t.Errorf("DecodeString #%d: unexpected error: %v", i, err)
+			continue
+		}
+
+		mnemonic, err := hdkeychain.NewMnemonic(entropy)
+		if err != nil {
+			t.Errorf("NewMnemonic #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if mnemonic != test.mnemonic {
+			t.Errorf("NewMnemonic #%d: mismatched mnemonic -- want "+
+				"%s, got %s", i, test.mnemonic, mnemonic)
+			continue
+		}
+
+		gotEntropy, err := hdkeychain.MnemonicToEntropy(test.mnemonic)
+		if err != nil {
+			t.Errorf("MnemonicToEntropy #%d: unexpected error: %v", i,
+				err)
+			continue
+		}
+		if !bytes.Equal(gotEntropy, entropy) {
+			t.Errorf("MnemonicToEntropy #%d: mismatched entropy -- "+
+				"want %x, got %x", i, entropy, gotEntropy)
+			continue
+		}
+
+		seed, err := hdkeychain.NewSeedFromMnemonic(test.mnemonic,
+			"TREZOR")
+		if err != nil {
+			t.Errorf("NewSeedFromMnemonic #%d: unexpected error: %v",
+				i, err)
+			continue
+		}
+		if hex.EncodeToString(seed) != test.seed {
+			t.Errorf("NewSeedFromMnemonic #%d: mismatched seed -- "+
+				"want %s, got %x", i, test.seed, seed)
+			continue
+		}
+
+		// The derived seed must be usable as a master node seed.
+		if _, err := hdkeychain.NewMaster(seed,
+			&chaincfg.MainNetParams); err != nil {
+			t.Errorf("NewMaster #%d: unexpected error: %v", i, err)
+			continue
+		}
+	}
+}
+
+// TestMnemonicErrors ensures invalid entropy lengths and invalid mnemonics are
+// rejected with the expected errors.
+func TestMnemonicErrors(t *testing.T) {
+	badEntropyLens := []int{0, 15, 17, 21, 33, 64}
+	for _, n := range badEntropyLens {
+		_, err := hdkeychain.NewMnemonic(make([]byte, n))
+		if err != hdkeychain.ErrInvalidEntropyLen {
+			t.Errorf("NewMnemonic (len %d): mismatched error -- want "+
+				"%v, got %v", n, hdkeychain.ErrInvalidEntropyLen, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		mnemonic string
+		err      error
+	}{
+		{
+			name:     "too few words",
+			mnemonic: "abandon abandon abandon abandon abandon about",
+			err:      hdkeychain.ErrInvalidMnemonicLen,
+		},
+		{
+			name: "bad checksum",
+			mnemonic: "abandon abandon abandon abandon abandon " +
+				"abandon abandon abandon abandon abandon abandon " +
+				"abandon",
+			err: hdkeychain.ErrMnemonicChecksum,
+		},
+		{
+			name: "extra whitespace and case",
+			mnemonic: "  Abandon abandon abandon abandon abandon " +
+				"abandon abandon abandon abandon\tabandon abandon " +
+				"ABOUT ",
+			err: nil,
+		},
+	}
+	for _, test := range tests {
+		err := hdkeychain.ValidateMnemonic(test.mnemonic)
+		if err != test.err {
+			t.Errorf("ValidateMnemonic (%s): mismatched error -- want "+
+				"%v, got %v", test.name, test.err, err)
+		}
+	}
+
+	// Words outside of the word list must be rejected.
+	err := hdkeychain.ValidateMnemonic("abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon abandon abandon hcash")
+	if err == nil {
+		t.Errorf("ValidateMnemonic: unknown word was not rejected")
+	}
+}
+
+// TestGenerateMnemonic ensures generated mnemonics round trip to entropy of the
+// requested length.
+func TestGenerateMnemonic(t *testing.T) {
+	for _, n := range []uint8{16, 20, 24, 28, 32} {
+		mnemonic, err := hdkeychain.GenerateMnemonic(n)
+		if err != nil {
+			t.Errorf("GenerateMnemonic (len %d): unexpected error: %v",
+				n, err)
+			continue
+		}
+		entropy, err := hdkeychain.MnemonicToEntropy(mnemonic)
+		if err != nil {
+			t.Errorf("MnemonicToEntropy (len %d): unexpected error: "+
+				"%v", n, err)
+			continue
+		}
+		if len(entropy) != int(n) {
+			t.Errorf("MnemonicToEntropy (len %d): mismatched entropy "+
+				"length %d", n, len(entropy))
+		}
+	}
+
+	_, err := hdkeychain.GenerateMnemonic(18)
+	if err != hdkeychain.ErrInvalidEntropyLen {
+		t.Errorf("GenerateMnemonic: mismatched error -- want %v, got %v",
+			hdkeychain.ErrInvalidEntropyLen, err)
+	}
+}
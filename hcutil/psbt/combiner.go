@@ -0,0 +1,184 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// The Combiner merges several PSBTs for the same transaction, such as the
+// copies returned by the different signers of a multisig input, into a single
+// PSBT which contains the union of their key-value pairs.
+
+import (
+	"bytes"
+
+	"github.com/james-ray/hcd/wire"
+)
+
+// Combine merges the passed packets, which must all be for the same unsigned
+// transaction, into a new packet.  Transactions are considered the same when
+// their prefix hashes match, so packets may differ in the value in of their
+// inputs.  Conflicting values for the same key result in ErrNotCombinable.
+// None of the passed packets are modified.
+func Combine(packets ...*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, ErrNotCombinable
+	}
+
+	first := packets[0]
+	txHash := first.UnsignedTx.TxHash()
+	for _, p := range packets[1:] {
+		if p.UnsignedTx.TxHash() != txHash {
+			return nil, ErrNotCombinable
+		}
+	}
+
+	combined, err := NewFromUnsignedTx(first.UnsignedTx.Copy())
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packets {
+		if err := p.SanityCheck(); err != nil {
+			return nil, err
+		}
+
+		for i := range p.Inputs {
+			err := combineInput(&combined.Inputs[i], &p.Inputs[i])
+			if err != nil {
+				return nil, err
+			}
+
+			// Keep any known value in, which is not part of the
+			// transaction prefix.
+			valueIn := p.UnsignedTx.TxIn[i].ValueIn
+			if valueIn != wire.NullValueIn {
+				combined.UnsignedTx.TxIn[i].ValueIn = valueIn
+			}
+		}
+		for i := range p.Outputs {
+			err := combineOutput(&combined.Outputs[i], &p.Outputs[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+		combined.Unknowns = combineUnknowns(combined.Unknowns,
+			p.Unknowns)
+	}
+
+	if err := combined.SanityCheck(); err != nil {
+		return nil, err
+	}
+
+	return combined, nil
+}
+
+// combineBytes merges the value src into dst, returning ErrNotCombinable if
+// both are set and differ.
+func combineBytes(dst *[]byte, src []byte) error {
+	switch {
+	case src == nil:
+	case *dst == nil:
+		*dst = src
+	case !bytes.Equal(*dst, src):
+		return ErrNotCombinable
+	}
+
+	return nil
+}
+
+// combineInput merges the key-value pairs of the input src into dst.
+func combineInput(dst, src *PInput) error {
+	if dst.Utxo == nil {
+		dst.Utxo = src.Utxo
+	}
+	if dst.PrevOut == nil {
+		dst.PrevOut = src.PrevOut
+	}
+	if src.SighashType != 0 {
+		if dst.SighashType != 0 && dst.SighashType != src.SighashType {
+			return ErrNotCombinable
+		}
+		dst.SighashType = src.SighashType
+	}
+	if err := combineBytes(&dst.RedeemScript, src.RedeemScript); err != nil {
+		return err
+	}
+	err := combineBytes(&dst.FinalScriptSig, src.FinalScriptSig)
+	if err != nil {
+		return err
+	}
+
+	for _, ps := range src.PartialSigs {
+		found := false
+		for _, x := range dst.PartialSigs {
+			if bytes.Equal(x.PubKey, ps.PubKey) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst.PartialSigs = append(dst.PartialSigs, ps)
+		}
+	}
+	dst.Bip32Derivation = combineBip32(dst.Bip32Derivation,
+		src.Bip32Derivation)
+	dst.Unknowns = combineUnknowns(dst.Unknowns, src.Unknowns)
+
+	// A finalized input no longer carries the data used to sign it.
+	if dst.FinalScriptSig != nil {
+		dst.PartialSigs = nil
+		dst.SighashType = 0
+		dst.RedeemScript = nil
+		dst.Bip32Derivation = nil
+	}
+
+	return nil
+}
+
+// combineOutput merges the key-value pairs of the output src into dst.
+func combineOutput(dst, src *POutput) error {
+	if err := combineBytes(&dst.RedeemScript, src.RedeemScript); err != nil {
+		return err
+	}
+	dst.Bip32Derivation = combineBip32(dst.Bip32Derivation,
+		src.Bip32Derivation)
+	dst.Unknowns = combineUnknowns(dst.Unknowns, src.Unknowns)
+
+	return nil
+}
+
+// combineBip32 returns the union of the passed key derivations, keyed by
+// public key.
+func combineBip32(dst, src []*Bip32Derivation) []*Bip32Derivation {
+	for _, d := range src {
+		found := false
+		for _, x := range dst {
+			if bytes.Equal(x.PubKey, d.PubKey) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, d)
+		}
+	}
+
+	return dst
+}
+
+// combineUnknowns returns the union of the passed unknown key-value pairs.
+func combineUnknowns(dst, src []*Unknown) []*Unknown {
+	for _, u := range src {
+		found := false
+		for _, x := range dst {
+			if bytes.Equal(x.Key, u.Key) && bytes.Equal(x.Value, u.Value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, u)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2018 The btcsuite developers
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"github.com/james-ray/hcd/wire"
+)
+
+// New on provision of an input and output 'skeleton' for the transaction, a
+// new partially populated PSBT packet is created.  The Creator role fills
+// only the transaction prefix; the signature scripts of all inputs are left
+// empty and any information needed for signing is added by an Updater.
+//
+// The inputs are passed as a list of outpoints and the outputs as a list of
+// transaction outputs.  The nSequences slice, if non-empty, must have one
+// entry per input.  The expiry is the block height after which the
+// transaction can no longer be mined, or 0 for no expiry.
+func New(inputs []*wire.OutPoint, outputs []*wire.TxOut, version uint16,
+	nLockTime uint32, expiry uint32, nSequences []uint32) (*Packet, error) {
+
+	// Create the new struct; the input and output lists will be empty, the
+	// unsignedTx object must be constructed and serialized, and that
+	// serialization should be entered as the only entry for the
+	// globalKVPairs list.
+	//
+	// Ensure that the version of the transaction is greater then our
+	// minimum allowed transaction version.  There must be one sequence
+	// number per input.
+	if version < MinTxVersion ||
+		(len(nSequences) != 0 && len(nSequences) != len(inputs)) {
+
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	unsignedTx := wire.NewMsgTx()
+	unsignedTx.Version = version
+	unsignedTx.LockTime = nLockTime
+	unsignedTx.Expiry = expiry
+	for i, in := range inputs {
+		txIn := wire.NewTxIn(in, nil)
+		if len(nSequences) != 0 {
+			txIn.Sequence = nSequences[i]
+		}
+		unsignedTx.AddTxIn(txIn)
+	}
+	for _, out := range outputs {
+		unsignedTx.AddTxOut(out)
+	}
+
+	// The input and output lists are empty, but there is a list of those
+	// two lists, and each one must be of length matching the unsigned
+	// transaction; the unknown list can be nil.
+	pInputs := make([]PInput, len(unsignedTx.TxIn))
+	pOutputs := make([]POutput, len(unsignedTx.TxOut))
+
+	// This new Psbt is "raw" and contains no key-value fields, so sanity
+	// checking is not required.
+	return &Packet{
+		UnsignedTx: unsignedTx,
+		Inputs:     pInputs,
+		Outputs:    pOutputs,
+		Unknowns:   nil,
+	}, nil
+}
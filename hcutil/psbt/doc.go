@@ -0,0 +1,46 @@
+// Copyright (c) 2018 The btcsuite developers
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package psbt provides an implementation of partially signed HC transactions,
+modeled after the BIP174 format used by Bitcoin.
+
+A partially signed transaction, or packet, carries an unsigned transaction
+together with everything needed to sign each of its inputs: the previous
+output being spent, redeem scripts, key derivations and the signatures which
+have been gathered so far.  This allows a transaction to be passed between
+wallets, hardware signers and the cosigners of a multisig output until it can
+be finalized and broadcast.
+
+Roles
+
+The package follows the roles defined by BIP174:
+
+  - Creator: New builds a packet from a list of outpoints and outputs.
+  - Updater: adds previous outputs, redeem scripts, key derivations and
+    verified signatures to a packet.
+  - Combiner: Combine merges several packets for the same transaction.
+  - Finalizer: MaybeFinalize and MaybeFinalizeAll build the final signature
+    scripts once enough signatures are present.
+  - Extractor: Extract returns the signed transaction.
+
+Differences From BIP174
+
+The serialization starts with the magic bytes "hcpsbt" followed by 0xff, and
+the global unsigned transaction is serialized with the full HC transaction
+serialization, so the value in of each input is carried along with the
+transaction prefix.  Segregated witness key types do not exist.
+
+Every partial signature records its signature type, which is one of
+secp256k1, Ed25519, secp256k1 Schnorr or BLISS as used by the alternative
+signature scripts.  The previous output of an input may be provided either as
+the full previous transaction or as just the output being spent.
+
+Stake tagged outputs of ticket purchases, votes and revocations are signed
+like their untagged pay-to-pubkey-hash and pay-to-script-hash forms.  The
+stakebase input of a vote already carries its signature script in the
+unsigned transaction and is always considered finalized.
+*/
+package psbt
@@ -0,0 +1,163 @@
+// Copyright (c) 2018 The btcsuite developers
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// The Finalizer requires provision of a single PSBT input in which all
+// necessary signatures are encoded, and uses it to construct a fully valid
+// final signature script.  The partial signatures, redeem script, sighash
+// type and key derivations of the input are dropped once it is finalized.
+
+import (
+	"bytes"
+
+	"github.com/james-ray/hcd/txscript"
+)
+
+// isFinalizable checks whether the structure of the entry for the input of
+// the psbt.Packet at index inIndex contains sufficient information to
+// finalize this input.
+func isFinalizable(p *Packet, inIndex int) bool {
+	_, err := finalScriptSig(p, inIndex)
+	return err == nil
+}
+
+// MaybeFinalize attempts to finalize the input at index inIndex in the PSBT
+// p, returning true with no error if it succeeds, OR if the input has
+// already been finalized.
+func MaybeFinalize(p *Packet, inIndex int) (bool, error) {
+	if p.IsInputFinalized(inIndex) {
+		return true, nil
+	}
+
+	if !isFinalizable(p, inIndex) {
+		return false, ErrNotFinalizable
+	}
+
+	if err := Finalize(p, inIndex); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// MaybeFinalizeAll attempts to finalize all inputs of the psbt.Packet that
+// are not already finalized, and returns an error if it fails to do so.
+func MaybeFinalizeAll(p *Packet) error {
+	for i := range p.UnsignedTx.TxIn {
+		success, err := MaybeFinalize(p, i)
+		if err != nil || !success {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Finalize assumes that the provided psbt.Packet struct has all partial
+// signatures and redeem scripts necessary to finalize the input at index
+// inIndex, and builds its final signature script.  It returns an error if
+// the input is already finalized or the required data is missing.
+func Finalize(p *Packet, inIndex int) error {
+	if p.IsInputFinalized(inIndex) {
+		return ErrInputAlreadyFinalized
+	}
+
+	sigScript, err := finalScriptSig(p, inIndex)
+	if err != nil {
+		return err
+	}
+
+	// The input is complete, so the data which was only needed to sign it
+	// is removed.
+	pInput := &p.Inputs[inIndex]
+	pInput.FinalScriptSig = sigScript
+	pInput.PartialSigs = nil
+	pInput.SighashType = 0
+	pInput.RedeemScript = nil
+	pInput.Bip32Derivation = nil
+
+	return p.SanityCheck()
+}
+
+// finalScriptSig builds the final signature script of the input at index
+// inIndex from its partial signatures.  Pay to pubkey, pay to pubkey hash and
+// their alternative signature and stake tagged forms are supported, as are
+// pay to script hash outputs with a pay to pubkey, pay to pubkey hash or
+// multisig redeem script.
+func finalScriptSig(p *Packet, inIndex int) ([]byte, error) {
+	script, class, err := p.signingScript(inIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	pInput := &p.Inputs[inIndex]
+	isP2SH := pInput.RedeemScript != nil &&
+		bytes.Equal(script, pInput.RedeemScript)
+
+	switch class {
+	case txscript.StakeSubmissionTy, txscript.StakeGenTy,
+		txscript.StakeRevocationTy, txscript.StakeSubChangeTy:
+
+		// Stake tagged pay to script hash outputs were resolved to the
+		// class of their redeem script, so only the pay to pubkey hash
+		// forms remain.
+		class = txscript.PubKeyHashTy
+	}
+
+	builder := txscript.NewScriptBuilder()
+	switch class {
+	case txscript.PubKeyTy, txscript.PubkeyAltTy:
+		if len(pInput.PartialSigs) != 1 {
+			return nil, ErrNotFinalizable
+		}
+		builder.AddData(pInput.PartialSigs[0].Signature)
+
+	case txscript.PubKeyHashTy, txscript.PubkeyHashAltTy:
+		if len(pInput.PartialSigs) != 1 {
+			return nil, ErrNotFinalizable
+		}
+		builder.AddData(pInput.PartialSigs[0].Signature)
+		builder.AddData(pInput.PartialSigs[0].PubKey)
+
+	case txscript.MultiSigTy:
+		nRequired, _, err := txscript.GetMultisigMandN(script)
+		if err != nil {
+			return nil, err
+		}
+		pubKeys, err := txscript.PushedData(script)
+		if err != nil {
+			return nil, err
+		}
+
+		// The signatures must be in the same order as the public keys
+		// of the redeem script.  There is no dummy element in HC.
+		signed := 0
+		for _, pubKey := range pubKeys {
+			if signed == int(nRequired) {
+				break
+			}
+			for _, ps := range pInput.PartialSigs {
+				if bytes.Equal(ps.PubKey, pubKey) {
+					builder.AddData(ps.Signature)
+					signed++
+					break
+				}
+			}
+		}
+		if signed != int(nRequired) {
+			return nil, ErrNotFinalizable
+		}
+
+	default:
+		return nil, ErrUnsupportedScriptType
+	}
+
+	if isP2SH {
+		builder.AddData(pInput.RedeemScript)
+	}
+
+	return builder.Script()
+}
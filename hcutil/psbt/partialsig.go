@@ -0,0 +1,126 @@
+// Copyright (c) 2018 The btcsuite developers
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+
+	"github.com/james-ray/hcd/chaincfg/chainec"
+	hccrypto "github.com/james-ray/hcd/crypto"
+	bs "github.com/james-ray/hcd/crypto/bliss"
+)
+
+// Signature types which may be used for a partial signature.  They are the
+// values used by the alternative signature scripts and OP_CHECKSIGALT.
+const (
+	SigTypeSecp256k1  = uint8(chainec.ECTypeSecp256k1)
+	SigTypeEdwards    = uint8(chainec.ECTypeEdwards)
+	SigTypeSecSchnorr = uint8(chainec.ECTypeSecSchnorr)
+	SigTypeBliss      = uint8(bs.BSTypeBliss)
+)
+
+// PartialSig encapsulate a (HC public key, signature) pair, along with the
+// signature scheme used to create the signature.  The Signature includes the
+// trailing hash type byte.
+type PartialSig struct {
+	PubKey    []byte
+	SigType   uint8
+	Signature []byte
+}
+
+// PartialSigSorter implements sort.Interface for PartialSig.
+type PartialSigSorter []*PartialSig
+
+func (s PartialSigSorter) Len() int { return len(s) }
+
+func (s PartialSigSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s PartialSigSorter) Less(i, j int) bool {
+	return bytes.Compare(s[i].PubKey, s[j].PubKey) < 0
+}
+
+// validatePubkey checks if pubKey is a valid public key for the passed
+// signature type.
+func validatePubkey(sigType uint8, pubKey []byte) bool {
+	_, err := parsePubKey(sigType, pubKey)
+	return err == nil
+}
+
+// parsePubKey parses a public key of the passed signature type.
+func parsePubKey(sigType uint8, pubKey []byte) (chainec.PublicKey, error) {
+	switch sigType {
+	case SigTypeSecp256k1:
+		return chainec.Secp256k1.ParsePubKey(pubKey)
+	case SigTypeEdwards:
+		return chainec.Edwards.ParsePubKey(pubKey)
+	case SigTypeSecSchnorr:
+		return chainec.SecSchnorr.ParsePubKey(pubKey)
+	case SigTypeBliss:
+		return bs.Bliss.ParsePubKey(pubKey)
+	}
+
+	return nil, ErrInvalidKeydata
+}
+
+// parseSignature parses a signature, without the trailing hash type byte, of
+// the passed signature type.
+func parseSignature(sigType uint8, sig []byte) (hccrypto.Signature, error) {
+	switch sigType {
+	case SigTypeSecp256k1:
+		return chainec.Secp256k1.ParseDERSignature(sig)
+	case SigTypeEdwards:
+		return chainec.Edwards.ParseSignature(sig)
+	case SigTypeSecSchnorr:
+		return chainec.SecSchnorr.ParseSignature(sig)
+	case SigTypeBliss:
+		return bs.Bliss.ParseSignature(sig)
+	}
+
+	return nil, ErrInvalidKeydata
+}
+
+// validateSignature checks that the passed byte slice is a valid signature of
+// the passed signature type followed by a hash type byte.
+func validateSignature(sigType uint8, sig []byte) bool {
+	if len(sig) < 2 {
+		return false
+	}
+	_, err := parseSignature(sigType, sig[:len(sig)-1])
+	return err == nil
+}
+
+// verifySignature verifies the passed signature, without the trailing hash
+// type byte, over hash for the public key.
+func verifySignature(sigType uint8, pubKey, sig, hash []byte) bool {
+	pk, err := parsePubKey(sigType, pubKey)
+	if err != nil {
+		return false
+	}
+	s, err := parseSignature(sigType, sig)
+	if err != nil {
+		return false
+	}
+
+	switch sigType {
+	case SigTypeSecp256k1:
+		return chainec.Secp256k1.Verify(pk, hash, s.GetR(), s.GetS())
+	case SigTypeEdwards:
+		return chainec.Edwards.Verify(pk, hash, s.GetR(), s.GetS())
+	case SigTypeSecSchnorr:
+		return chainec.SecSchnorr.Verify(pk, hash, s.GetR(), s.GetS())
+	case SigTypeBliss:
+		return bs.Bliss.Verify(pk, hash, s)
+	}
+
+	return false
+}
+
+// checkValid checks that both the pubkey and the signature are valid for the
+// signature type of the partial signature.
+func (ps *PartialSig) checkValid() bool {
+	return validatePubkey(ps.SigType, ps.PubKey) &&
+		validateSignature(ps.SigType, ps.Signature)
+}
@@ -0,0 +1,481 @@
+// Copyright (c) 2018 The btcsuite developers
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+
+	"github.com/james-ray/hcd/blockchain/stake"
+	"github.com/james-ray/hcd/wire"
+)
+
+// psbtMagicLength is the length of the magic bytes used to signal the start
+// of a serialized packet.
+const psbtMagicLength = 7
+
+var (
+	// psbtMagic is the separator.
+	psbtMagic = [psbtMagicLength]byte{0x68, 0x63, 0x70, 0x73, 0x62, 0x74, 0xff}
+)
+
+// MaxPsbtValueLength is the size of the largest transaction serialization
+// that could be passed in a NonWitnessUtxo field.  This is definitely less
+// than 4M.
+const MaxPsbtValueLength = 4000000
+
+// MaxPsbtKeyLength is the length of the largest key that we'll successfully
+// deserialize from the wire.  Anything more will return ErrInvalidKeydata.  It
+// leaves room for BLISS public keys, which are 897 bytes.
+const MaxPsbtKeyLength = 10000
+
+// MinTxVersion is the lowest transaction version that we'll permit.
+const MinTxVersion = 1
+
+var (
+	// ErrInvalidPsbtFormat is a generic error for any situation in which a
+	// provided Psbt serialization does not conform to the rules of the
+	// format.
+	ErrInvalidPsbtFormat = errors.New("Invalid PSBT serialization format")
+
+	// ErrDuplicateKey indicates that a passed Psbt serialization is invalid
+	// due to having the same key repeated in the same key-value pair.
+	ErrDuplicateKey = errors.New("Invalid Psbt due to duplicate key")
+
+	// ErrInvalidKeydata indicates that a key-value pair in the PSBT
+	// serialization contains data in the key which is not valid.
+	ErrInvalidKeydata = errors.New("Invalid key data")
+
+	// ErrInvalidMagicBytes indicates that a passed Psbt serialization is
+	// invalid due to having incorrect magic bytes.
+	ErrInvalidMagicBytes = errors.New("Invalid Psbt due to incorrect " +
+		"magic bytes")
+
+	// ErrInvalidRawTxSigned indicates that the raw serialized transaction
+	// in the global section of the passed Psbt serialization is invalid
+	// because it contains signature scripts, which is not allowed by the
+	// format.  The stakebase input of a vote is exempt.
+	ErrInvalidRawTxSigned = errors.New("Invalid Psbt, raw transaction " +
+		"must be unsigned.")
+
+	// ErrInvalidPrevOutNonMatch indicates that the transaction provided as
+	// the utxo of an input does not match the outpoint it spends, or that
+	// the previous output provided does not match that transaction.
+	ErrInvalidPrevOutNonMatch = errors.New("Prevout hash does not match " +
+		"the provided utxo")
+
+	// ErrInvalidSignatureForInput indicates that the signature the user is
+	// trying to append to the PSBT is invalid, either because it does not
+	// correspond to the previous transaction hash, or redeem script, or
+	// pubkey, or because the sig type does not match the script.
+	ErrInvalidSignatureForInput = errors.New("Signature does not " +
+		"correspond to this input")
+
+	// ErrInputAlreadyFinalized indicates that the PSBT passed to a
+	// Finalizer already contains the finalized scriptSig.
+	ErrInputAlreadyFinalized = errors.New("Cannot finalize PSBT, " +
+		"finalized scriptSig already exists")
+
+	// ErrIncompletePSBT indicates that the Extractor object was unable to
+	// successfully extract the passed Psbt struct because it is not
+	// complete.
+	ErrIncompletePSBT = errors.New("PSBT cannot be extracted as it is " +
+		"incomplete")
+
+	// ErrNotFinalizable indicates that the PSBT struct does not have
+	// sufficient data (e.g. signatures) for finalization.
+	ErrNotFinalizable = errors.New("PSBT is not finalizable")
+
+	// ErrUnsupportedScriptType indicates that the redeem script or
+	// previous output script of an input is of a type that the finalizer
+	// does not know how to satisfy.
+	ErrUnsupportedScriptType = errors.New("Unsupported script type")
+
+	// ErrNotCombinable indicates that the packets passed to Combine are
+	// not for the same unsigned transaction, or carry conflicting data.
+	ErrNotCombinable = errors.New("PSBTs cannot be combined")
+)
+
+// Unknown is a struct encapsulating a key-value pair for which the key type
+// is unknown by this package; these fields are allowed in both the 'Global'
+// and the 'Input' section of a PSBT.
+type Unknown struct {
+	Key   []byte
+	Value []byte
+}
+
+// Packet is the actual psbt representation.  It is a set of 1 + N + M
+// key-value pair lists, 1 global, defining the unsigned transaction structure
+// with N inputs and M outputs.  These key-value pairs can contain scripts,
+// signatures, key derivations and other transaction-defining data.
+type Packet struct {
+	// UnsignedTx is the decoded unsigned transaction for this PSBT.  The
+	// value in, block height and block index of each input are carried in
+	// its witness data.
+	UnsignedTx *wire.MsgTx // Deserialization of unsigned tx
+
+	// Inputs contains all the information needed to properly sign this
+	// target input within the above transaction.
+	Inputs []PInput
+
+	// Outputs contains all information required to spend any outputs
+	// produced by this PSBT.
+	Outputs []POutput
+
+	// Unknowns are the set of custom types (global only) within this PSBT.
+	Unknowns []*Unknown
+}
+
+// isStakeBaseInput returns whether the input at the passed index of tx is the
+// stakebase input of a vote.  Its signature script is created along with the
+// transaction rather than by signing and is therefore allowed in the unsigned
+// transaction.
+func isStakeBaseInput(tx *wire.MsgTx, idx int) bool {
+	if idx != 0 {
+		return false
+	}
+	isVote, _ := stake.IsSSGen(tx)
+	return isVote
+}
+
+// validateUnsignedTx returns true if the transaction is unsigned.  Note that
+// more basic sanity requirements, such as the presence of inputs and outputs,
+// is implicitly checked in the call to MsgTx.Deserialize().
+func validateUnsignedTX(tx *wire.MsgTx) bool {
+	for i, tin := range tx.TxIn {
+		if len(tin.SignatureScript) != 0 && !isStakeBaseInput(tx, i) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewFromUnsignedTx creates a new Psbt struct, without any signatures (i.e.
+// only the global section is non-empty) using the passed unsigned transaction.
+func NewFromUnsignedTx(tx *wire.MsgTx) (*Packet, error) {
+	if !validateUnsignedTX(tx) {
+		return nil, ErrInvalidRawTxSigned
+	}
+
+	inSlice := make([]PInput, len(tx.TxIn))
+	outSlice := make([]POutput, len(tx.TxOut))
+	unknownSlice := make([]*Unknown, 0)
+
+	return &Packet{
+		UnsignedTx: tx,
+		Inputs:     inSlice,
+		Outputs:    outSlice,
+		Unknowns:   unknownSlice,
+	}, nil
+}
+
+// NewFromRawBytes returns a new instance of a Packet struct created by reading
+// from a byte slice.  If the format is invalid, an error is returned.  If the
+// argument b64 is true, the passed byte slice is decoded from base64 encoding
+// before processing.
+//
+// NOTE: To create a Packet from one's own data, rather than reading in a
+// serialization from a counterparty, one should use a psbt.New.
+func NewFromRawBytes(r io.Reader, b64 bool) (*Packet, error) {
+	// If the PSBT is encoded in bas64, then we'll create a new wrapper
+	// reader that'll allow us to incrementally decode the contents of the
+	// io.Reader.
+	if b64 {
+		based64EncodedReader := r
+		r = base64.NewDecoder(base64.StdEncoding, based64EncodedReader)
+	}
+
+	// The Packet struct does not store the fixed magic bytes, but they
+	// must be present or the serialization must be explicitly rejected.
+	var magic [psbtMagicLength]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic != psbtMagic {
+		return nil, ErrInvalidMagicBytes
+	}
+
+	// Next we parse the GLOBAL section.  There is currently only 1 known
+	// key type, UnsignedTx.  We insist this exists first; unknowns are
+	// allowed, but only after.
+	keyint, keydata, err := getKey(r)
+	if err != nil {
+		return nil, err
+	}
+	if GlobalType(keyint) != UnsignedTxType || keydata != nil {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	// Now that we've verified the global type is present, we'll decode it
+	// into a proper unsigned transaction, and validate it.
+	value, err := wire.ReadVarBytes(r, 0, MaxPsbtValueLength,
+		"PSBT value")
+	if err != nil {
+		return nil, err
+	}
+	msgTx := wire.NewMsgTx()
+	if err := msgTx.Deserialize(bytes.NewReader(value)); err != nil {
+		return nil, err
+	}
+	if !validateUnsignedTX(msgTx) {
+		return nil, ErrInvalidRawTxSigned
+	}
+
+	// Next we parse any unknowns that may be present, making sure that we
+	// break at the separator.
+	var unknownSlice []*Unknown
+	for {
+		keyint, keydata, err := getKey(r)
+		if err != nil {
+			return nil, ErrInvalidPsbtFormat
+		}
+		if keyint == -1 {
+			break
+		}
+
+		value, err := wire.ReadVarBytes(r, 0, MaxPsbtValueLength,
+			"PSBT value")
+		if err != nil {
+			return nil, err
+		}
+
+		keyintanddata := []byte{byte(keyint)}
+		keyintanddata = append(keyintanddata, keydata...)
+
+		newUnknown := &Unknown{
+			Key:   keyintanddata,
+			Value: value,
+		}
+		unknownSlice = append(unknownSlice, newUnknown)
+	}
+
+	// Next we parse the INPUT section.
+	inSlice := make([]PInput, len(msgTx.TxIn))
+	for i := range msgTx.TxIn {
+		input := PInput{}
+		err = input.deserialize(r)
+		if err != nil {
+			return nil, err
+		}
+
+		inSlice[i] = input
+	}
+
+	// Next we parse the OUTPUT section.
+	outSlice := make([]POutput, len(msgTx.TxOut))
+	for i := range msgTx.TxOut {
+		output := POutput{}
+		err = output.deserialize(r)
+		if err != nil {
+			return nil, err
+		}
+
+		outSlice[i] = output
+	}
+
+	// Populate the new Packet object
+	newPsbt := Packet{
+		UnsignedTx: msgTx,
+		Inputs:     inSlice,
+		Outputs:    outSlice,
+		Unknowns:   unknownSlice,
+	}
+
+	// Extended sanity checking is applied here to make sure the
+	// externally-passed Packet follows all the rules.
+	if err = newPsbt.SanityCheck(); err != nil {
+		return nil, err
+	}
+
+	return &newPsbt, nil
+}
+
+// Serialize creates a binary serialization of the referenced Packet struct
+// with lexicographical ordering (by key) of the subsections.
+func (p *Packet) Serialize(w io.Writer) error {
+	// First we write out the precise set of magic bytes that identify a
+	// valid PSBT transaction.
+	if _, err := w.Write(psbtMagic[:]); err != nil {
+		return err
+	}
+
+	// Next we prep to write out the unsigned transaction by first
+	// serializing it into an intermediate buffer.
+	serializedTx := bytes.NewBuffer(
+		make([]byte, 0, p.UnsignedTx.SerializeSize()),
+	)
+	if err := p.UnsignedTx.Serialize(serializedTx); err != nil {
+		return err
+	}
+
+	// Now that we have the serialized transaction, we'll write it out to
+	// the proper global type.
+	err := serializeKVPairWithType(
+		w, uint8(UnsignedTxType), nil, serializedTx.Bytes(),
+	)
+	if err != nil {
+		return err
+	}
+
+	// With that our global section is done, so we'll write out the
+	// unknowns and then the separator.
+	for _, kv := range p.Unknowns {
+		if err := serializeKVpair(w, kv.Key, kv.Value); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write([]byte{0x00}); err != nil {
+		return err
+	}
+
+	for _, pInput := range p.Inputs {
+		err := pInput.serialize(w)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write([]byte{0x00}); err != nil {
+			return err
+		}
+	}
+
+	for _, pOutput := range p.Outputs {
+		err := pOutput.serialize(w)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write([]byte{0x00}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// B64Encode returns the base64 encoding of the serialization of the current
+// PSBT, or an error if the encoding fails.
+func (p *Packet) B64Encode() (string, error) {
+	var b bytes.Buffer
+	if err := p.Serialize(&b); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(b.Bytes()), nil
+}
+
+// IsInputFinalized returns whether the input at the passed index is ready to
+// be extracted, which is the case once it has a final signature script or
+// when it is the stakebase input of a vote.
+func (p *Packet) IsInputFinalized(inIndex int) bool {
+	return p.Inputs[inIndex].FinalScriptSig != nil ||
+		isStakeBaseInput(p.UnsignedTx, inIndex)
+}
+
+// IsComplete returns true only if all of the inputs are finalized; this is
+// particularly important in that it decides whether the final extraction to
+// a network serialized signed transaction will be possible.
+func (p *Packet) IsComplete() bool {
+	for i := 0; i < len(p.UnsignedTx.TxIn); i++ {
+		if !p.IsInputFinalized(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// SanityCheck checks conditions on a PSBT to ensure that it obeys the rules
+// of the format, and that the utxo data provided for each input matches the
+// outpoint it spends.
+func (p *Packet) SanityCheck() error {
+	if !validateUnsignedTX(p.UnsignedTx) {
+		return ErrInvalidRawTxSigned
+	}
+	if len(p.Inputs) != len(p.UnsignedTx.TxIn) ||
+		len(p.Outputs) != len(p.UnsignedTx.TxOut) {
+
+		return ErrInvalidPsbtFormat
+	}
+
+	for i, tin := range p.Inputs {
+		if !tin.IsSane() {
+			return ErrInvalidPsbtFormat
+		}
+		err := checkUtxo(&p.UnsignedTx.TxIn[i].PreviousOutPoint,
+			tin.Utxo, tin.PrevOut)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkUtxo ensures the previous transaction and previous output provided for
+// an input, when present, match the outpoint it spends and each other.
+func checkUtxo(prevOutPoint *wire.OutPoint, utxo *wire.MsgTx,
+	prevOut *wire.TxOut) error {
+	if utxo == nil {
+		return nil
+	}
+	if utxo.TxHash() != prevOutPoint.Hash ||
+		int(prevOutPoint.Index) >= len(utxo.TxOut) {
+
+		return ErrInvalidPrevOutNonMatch
+	}
+	if prevOut == nil {
+		return nil
+	}
+	txOut := utxo.TxOut[prevOutPoint.Index]
+	if txOut.Value != prevOut.Value || txOut.Version != prevOut.Version ||
+		!bytes.Equal(txOut.PkScript, prevOut.PkScript) {
+
+		return ErrInvalidPrevOutNonMatch
+	}
+
+	return nil
+}
+
+// GetTxFee returns the transaction fee.  An error is returned if the amount
+// spent by an input is not known, either from the previous output or the
+// previous transaction of that input, or from the value in of the unsigned
+// transaction input.
+func (p *Packet) GetTxFee() (int64, error) {
+	var sumInputs int64
+	for i := range p.UnsignedTx.TxIn {
+		amount, ok := p.inputAmount(i)
+		if !ok {
+			return 0, ErrInvalidPsbtFormat
+		}
+		sumInputs += amount
+	}
+
+	var sumOutputs int64
+	for _, txOut := range p.UnsignedTx.TxOut {
+		sumOutputs += txOut.Value
+	}
+
+	return sumInputs - sumOutputs, nil
+}
+
+// inputAmount returns the amount spent by the input at the passed index and
+// whether it is known.
+func (p *Packet) inputAmount(inIndex int) (int64, bool) {
+	pInput := &p.Inputs[inIndex]
+	switch {
+	case pInput.PrevOut != nil:
+		return pInput.PrevOut.Value, true
+	case pInput.Utxo != nil:
+		idx := p.UnsignedTx.TxIn[inIndex].PreviousOutPoint.Index
+		return pInput.Utxo.TxOut[idx].Value, true
+	}
+
+	valueIn := p.UnsignedTx.TxIn[inIndex].ValueIn
+	return valueIn, valueIn != wire.NullValueIn
+}
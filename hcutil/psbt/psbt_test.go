@@ -0,0 +1,311 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/james-ray/hcd/chaincfg/chainec"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/txscript"
+	"github.com/james-ray/hcd/wire"
+)
+
+// testKey is a secp256k1 key pair used to sign the test packets.
+type testKey struct {
+	priv   chainec.PrivateKey
+	pubKey []byte
+}
+
+// newTestKey generates a new secp256k1 key pair.
+func newTestKey(t *testing.T) *testKey {
+	keyBytes, _, _, err := chainec.Secp256k1.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	priv, pub := chainec.Secp256k1.PrivKeyFromBytes(keyBytes)
+	return &testKey{priv: priv, pubKey: pub.SerializeCompressed()}
+}
+
+// p2pkhScript returns a pay-to-pubkey-hash script for the key, prefixed with
+// the passed stake opcode when it is not zero.
+func p2pkhScript(t *testing.T, key *testKey, stakeOp byte) []byte {
+	builder := txscript.NewScriptBuilder()
+	if stakeOp != 0 {
+		builder.AddOp(stakeOp)
+	}
+	script, err := builder.AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).
+		AddData(hcutil.Hash160(key.pubKey)).
+		AddOp(txscript.OP_EQUALVERIFY).
+		AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+	return script
+}
+
+// multisigScripts returns a 2-of-3 multisig redeem script for the keys and
+// the pay-to-script-hash script paying to it.
+func multisigScripts(t *testing.T, keys []*testKey) ([]byte, []byte) {
+	builder := txscript.NewScriptBuilder().AddOp(txscript.OP_2)
+	for _, key := range keys {
+		builder.AddData(key.pubKey)
+	}
+	redeemScript, err := builder.AddOp(txscript.OP_3).
+		AddOp(txscript.OP_CHECKMULTISIG).
+		Script()
+	if err != nil {
+		t.Fatalf("unable to build redeem script: %v", err)
+	}
+	pkScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_HASH160).
+		AddData(hcutil.Hash160(redeemScript)).
+		AddOp(txscript.OP_EQUAL).
+		Script()
+	if err != nil {
+		t.Fatalf("unable to build p2sh script: %v", err)
+	}
+	return redeemScript, pkScript
+}
+
+// newTestPacket creates a packet spending a single output with the passed
+// public key script and amount.
+func newTestPacket(t *testing.T, prevOut *wire.TxOut) *Packet {
+	var hash chainhash.Hash
+	hash[0] = 0x01
+	inputs := []*wire.OutPoint{wire.NewOutPoint(&hash, 1, wire.TxTreeRegular)}
+	outputs := []*wire.TxOut{wire.NewTxOut(prevOut.Value-10000,
+		prevOut.PkScript)}
+	p, err := New(inputs, outputs, 1, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("unable to create packet: %v", err)
+	}
+	u, err := NewUpdater(p)
+	if err != nil {
+		t.Fatalf("unable to create updater: %v", err)
+	}
+	if err := u.AddInPrevOut(prevOut, 0); err != nil {
+		t.Fatalf("unable to add previous output: %v", err)
+	}
+	return p
+}
+
+// signInput signs the first input of the packet with the passed key.
+func signInput(t *testing.T, p *Packet, key *testKey, script,
+	redeemScript []byte) {
+
+	sig, err := txscript.RawTxInSignature(p.UnsignedTx, 0, script,
+		txscript.SigHashAll, key.priv)
+	if err != nil {
+		t.Fatalf("unable to sign input: %v", err)
+	}
+	u, err := NewUpdater(p)
+	if err != nil {
+		t.Fatalf("unable to create updater: %v", err)
+	}
+	outcome, err := u.Sign(0, sig, key.pubKey, SigTypeSecp256k1,
+		redeemScript)
+	if err != nil || outcome != SignSuccesful {
+		t.Fatalf("unable to add signature: %v (outcome %v)", err,
+			outcome)
+	}
+}
+
+// roundTrip serializes the packet to base64 and parses it again, checking
+// that the serialization is stable.
+func roundTrip(t *testing.T, p *Packet) *Packet {
+	b64, err := p.B64Encode()
+	if err != nil {
+		t.Fatalf("unable to encode packet: %v", err)
+	}
+	parsed, err := NewFromRawBytes(strings.NewReader(b64), true)
+	if err != nil {
+		t.Fatalf("unable to parse packet: %v", err)
+	}
+	b64Again, err := parsed.B64Encode()
+	if err != nil {
+		t.Fatalf("unable to encode parsed packet: %v", err)
+	}
+	if b64 != b64Again {
+		t.Fatalf("serialization changed after round trip:\n%s\n%s",
+			b64, b64Again)
+	}
+	return parsed
+}
+
+// finalizeAndExecute finalizes and extracts the packet and runs the script
+// engine on its only input.
+func finalizeAndExecute(t *testing.T, p *Packet, pkScript []byte) {
+	if err := MaybeFinalizeAll(p); err != nil {
+		t.Fatalf("unable to finalize packet: %v", err)
+	}
+	if !p.IsComplete() {
+		t.Fatalf("packet not complete after finalizing")
+	}
+	p = roundTrip(t, p)
+
+	tx, err := Extract(p)
+	if err != nil {
+		t.Fatalf("unable to extract transaction: %v", err)
+	}
+	vm, err := txscript.NewEngine(pkScript, tx, 0, txscript.ScriptBip16,
+		txscript.DefaultScriptVersion, nil)
+	if err != nil {
+		t.Fatalf("unable to create engine: %v", err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("extracted transaction does not verify: %v", err)
+	}
+}
+
+// TestPayToPubKeyHash ensures a packet spending both untagged and stake
+// tagged pay-to-pubkey-hash outputs can be signed, finalized and extracted.
+func TestPayToPubKeyHash(t *testing.T) {
+	key := newTestKey(t)
+	tests := []struct {
+		name    string
+		stakeOp byte
+	}{
+		{name: "p2pkh", stakeOp: 0},
+		{name: "stakegen p2pkh", stakeOp: txscript.OP_SSGEN},
+		{name: "stakerevoke p2pkh", stakeOp: txscript.OP_SSRTX},
+	}
+
+	for _, test := range tests {
+		pkScript := p2pkhScript(t, key, test.stakeOp)
+		p := newTestPacket(t, wire.NewTxOut(100000000, pkScript))
+
+		fee, err := p.GetTxFee()
+		if err != nil || fee != 10000 {
+			t.Fatalf("%s: unexpected fee %d (err %v)", test.name,
+				fee, err)
+		}
+
+		signInput(t, p, key, pkScript, nil)
+		if p.IsComplete() {
+			t.Fatalf("%s: packet complete before finalizing",
+				test.name)
+		}
+		p = roundTrip(t, p)
+		finalizeAndExecute(t, p, pkScript)
+	}
+}
+
+// TestMultisigCombine ensures the signatures of two cosigners of a 2-of-3
+// multisig output, each added to their own copy of a packet, can be combined
+// into a complete transaction.
+func TestMultisigCombine(t *testing.T) {
+	keys := []*testKey{newTestKey(t), newTestKey(t), newTestKey(t)}
+	redeemScript, pkScript := multisigScripts(t, keys)
+	p := newTestPacket(t, wire.NewTxOut(100000000, pkScript))
+
+	b64, err := p.B64Encode()
+	if err != nil {
+		t.Fatalf("unable to encode packet: %v", err)
+	}
+	copies := make([]*Packet, 2)
+	for i := range copies {
+		copies[i], err = NewFromRawBytes(strings.NewReader(b64), true)
+		if err != nil {
+			t.Fatalf("unable to parse packet: %v", err)
+		}
+	}
+
+	// Sign with the last two keys so that the finalizer must put the
+	// signatures in the order of the public keys.
+	signInput(t, copies[0], keys[2], redeemScript, redeemScript)
+	signInput(t, copies[1], keys[1], redeemScript, redeemScript)
+	if _, err := MaybeFinalize(copies[0], 0); err != ErrNotFinalizable {
+		t.Fatalf("finalized with a single signature: %v", err)
+	}
+
+	combined, err := Combine(copies[0], copies[1])
+	if err != nil {
+		t.Fatalf("unable to combine packets: %v", err)
+	}
+	if len(combined.Inputs[0].PartialSigs) != 2 {
+		t.Fatalf("unexpected number of partial signatures: %d",
+			len(combined.Inputs[0].PartialSigs))
+	}
+	finalizeAndExecute(t, combined, pkScript)
+
+	// The copies must not have been modified.
+	if copies[0].Inputs[0].FinalScriptSig != nil ||
+		len(copies[0].Inputs[0].PartialSigs) != 1 {
+		t.Fatalf("combine modified the passed packet")
+	}
+}
+
+// TestInvalidSignature ensures signatures which do not commit to the input
+// are rejected.
+func TestInvalidSignature(t *testing.T) {
+	key := newTestKey(t)
+	otherKey := newTestKey(t)
+	pkScript := p2pkhScript(t, key, 0)
+	p := newTestPacket(t, wire.NewTxOut(100000000, pkScript))
+
+	sig, err := txscript.RawTxInSignature(p.UnsignedTx, 0, pkScript,
+		txscript.SigHashAll, otherKey.priv)
+	if err != nil {
+		t.Fatalf("unable to sign input: %v", err)
+	}
+	u, err := NewUpdater(p)
+	if err != nil {
+		t.Fatalf("unable to create updater: %v", err)
+	}
+	outcome, err := u.Sign(0, sig, key.pubKey, SigTypeSecp256k1, nil)
+	if err != ErrInvalidSignatureForInput || outcome != SignInvalid {
+		t.Fatalf("signature with the wrong key accepted: %v", err)
+	}
+
+	u.AddInSighashType(txscript.SigHashSingle, 0)
+	sig, err = txscript.RawTxInSignature(p.UnsignedTx, 0, pkScript,
+		txscript.SigHashAll, key.priv)
+	if err != nil {
+		t.Fatalf("unable to sign input: %v", err)
+	}
+	outcome, err = u.Sign(0, sig, key.pubKey, SigTypeSecp256k1, nil)
+	if err != ErrInvalidSignatureForInput || outcome != SignInvalid {
+		t.Fatalf("signature with the wrong hash type accepted: %v",
+			err)
+	}
+}
+
+// TestDeserializeInvalid ensures malformed serializations are rejected.
+func TestDeserializeInvalid(t *testing.T) {
+	key := newTestKey(t)
+	p := newTestPacket(t, wire.NewTxOut(100000000, p2pkhScript(t, key, 0)))
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		t.Fatalf("unable to serialize packet: %v", err)
+	}
+	serialized := buf.Bytes()
+
+	badMagic := append([]byte{}, serialized...)
+	badMagic[0] ^= 0xff
+	_, err := NewFromRawBytes(bytes.NewReader(badMagic), false)
+	if err != ErrInvalidMagicBytes {
+		t.Fatalf("unexpected error for bad magic: %v", err)
+	}
+
+	truncated := serialized[:len(serialized)-1]
+	_, err = NewFromRawBytes(bytes.NewReader(truncated), false)
+	if err == nil {
+		t.Fatalf("truncated packet accepted")
+	}
+
+	// A signed transaction may not be used as the unsigned transaction.
+	signed := p.UnsignedTx.Copy()
+	signed.TxIn[0].SignatureScript = []byte{txscript.OP_TRUE}
+	if _, err := NewFromUnsignedTx(signed); err != ErrInvalidRawTxSigned {
+		t.Fatalf("unexpected error for signed transaction: %v", err)
+	}
+}
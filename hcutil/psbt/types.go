@@ -0,0 +1,72 @@
+// Copyright (c) 2018 The btcsuite developers
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// GlobalType is the set of types that are used at the global scope level
+// within the PSBT.
+type GlobalType uint8
+
+const (
+	// UnsignedTxType is the global scope key that houses the unsigned
+	// transaction of the PSBT.  The value is a transaction in full
+	// serialization format, including the stake tree of every previous
+	// outpoint.  The transaction MUST have empty signature scripts, with
+	// the exception of the stakebase input of a vote.
+	UnsignedTxType GlobalType = 0
+)
+
+// InputType is the set of types that are defined for each input included
+// within the PSBT.
+type InputType uint8
+
+const (
+	// UtxoType has no key data and houses the transaction in full
+	// serialization format which contains the output spent by the input.
+	UtxoType InputType = 0
+
+	// PrevOutType has no key data and houses the output spent by the
+	// input, serialized as its value, script version and public key
+	// script.  It is sufficient to sign and finalize the input.
+	PrevOutType InputType = 1
+
+	// PartialSigType is used to include a partial signature with key data
+	// of the serialized public key.  The value is the signature type byte
+	// (one of the chainec or bliss types) followed by the signature with
+	// its hash type.
+	PartialSigType InputType = 2
+
+	// SighashType is an empty key that houses the sighash type to be used
+	// when signing the input, as a 32-bit unsigned integer.
+	SighashType InputType = 3
+
+	// RedeemScriptInputType is an empty key that houses the redeem script
+	// of a pay-to-script-hash output, including the stake tagged ones.
+	RedeemScriptInputType InputType = 4
+
+	// Bip32DerivationInputType is a type that carries the pubkey along
+	// with the key data.  The value is the master key fingerprint
+	// followed by the derivation path of the public key.
+	Bip32DerivationInputType InputType = 6
+
+	// FinalScriptSigType is an empty key that houses the final signature
+	// script of the input once it has been finalized.
+	FinalScriptSigType InputType = 7
+)
+
+// OutputType is the set of types defined per output within the PSBT.
+type OutputType uint8
+
+const (
+	// RedeemScriptOutputType is an empty key that houses the redeem script
+	// of a pay-to-script-hash output.
+	RedeemScriptOutputType OutputType = 0
+
+	// Bip32DerivationOutputType is used to communicate derivation
+	// information needed to spend this output.  The key data is the
+	// public key, while the value is the master key fingerprint followed
+	// by the derivation path.
+	Bip32DerivationOutputType OutputType = 2
+)
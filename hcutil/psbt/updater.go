@@ -0,0 +1,299 @@
+// Copyright (c) 2018 The btcsuite developers
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// The Updater requires provision of a single PSBT and is able to add data to
+// both input and output sections.  It can be called repeatedly to add more
+// data.  It also allows addition of signatures via the Sign method, which
+// checks each signature against the data of the input before adding it.
+
+import (
+	"bytes"
+
+	"github.com/james-ray/hcd/txscript"
+	"github.com/james-ray/hcd/wire"
+)
+
+// SignOutcome is a enum-like value that expresses the outcome of a call to
+// the Sign method.
+type SignOutcome int
+
+const (
+	// SignSuccesful indicates that the partial signature was successfully
+	// attached.
+	SignSuccesful SignOutcome = 0
+
+	// SignFinalized indicates that this input is already finalized, so the
+	// provided signature was *not* attached
+	SignFinalized SignOutcome = 1
+
+	// SignInvalid indicates that the provided signature data was not
+	// valid.  In this case an error will also be returned.
+	SignInvalid SignOutcome = -1
+)
+
+// Updater encapsulates the role 'Updater' as specified in BIP174; it accepts
+// Psbt structs and has methods to add fields to the inputs and outputs.
+type Updater struct {
+	Upsbt *Packet
+}
+
+// NewUpdater returns a new instance of Updater, if the passed Psbt struct is
+// in a valid form, else an error.
+func NewUpdater(p *Packet) (*Updater, error) {
+	if err := p.SanityCheck(); err != nil {
+		return nil, err
+	}
+
+	return &Updater{Upsbt: p}, nil
+}
+
+// AddInUtxo adds the full transaction being spent by the input at the passed
+// index.  The value in of the input of the unsigned transaction is set to the
+// amount of the spent output.
+func (u *Updater) AddInUtxo(tx *wire.MsgTx, inIndex int) error {
+	prevOutPoint := &u.Upsbt.UnsignedTx.TxIn[inIndex].PreviousOutPoint
+	err := checkUtxo(prevOutPoint, tx, u.Upsbt.Inputs[inIndex].PrevOut)
+	if err != nil {
+		return err
+	}
+
+	u.Upsbt.Inputs[inIndex].Utxo = tx
+	u.Upsbt.UnsignedTx.TxIn[inIndex].ValueIn =
+		tx.TxOut[prevOutPoint.Index].Value
+	return u.Upsbt.SanityCheck()
+}
+
+// AddInPrevOut adds only the output being spent by the input at the passed
+// index.  This is sufficient for signing, since the amount is also carried by
+// the value in of the input of the unsigned transaction, which is set to the
+// amount of the spent output.
+func (u *Updater) AddInPrevOut(txout *wire.TxOut, inIndex int) error {
+	prevOutPoint := &u.Upsbt.UnsignedTx.TxIn[inIndex].PreviousOutPoint
+	err := checkUtxo(prevOutPoint, u.Upsbt.Inputs[inIndex].Utxo, txout)
+	if err != nil {
+		return err
+	}
+
+	u.Upsbt.Inputs[inIndex].PrevOut = txout
+	u.Upsbt.UnsignedTx.TxIn[inIndex].ValueIn = txout.Value
+	return u.Upsbt.SanityCheck()
+}
+
+// Sign allows the caller to sign a PSBT at a particular input; they must
+// provide a signature, with the hash type appended, and a pubkey, both as
+// byte slices, along with the signature type they belong to.  The redeem
+// script must be provided for inputs spending pay-to-script-hash outputs,
+// including stake tagged ones, and may be nil otherwise.  The previous output
+// of the input must be known.
+//
+// The signature is verified against the signature hash of the input before it
+// is attached.  If the input already has a final signature script, the
+// signature is not attached and SignFinalized is returned.
+func (u *Updater) Sign(inIndex int, sig []byte, pubKey []byte, sigType uint8,
+	redeemScript []byte) (SignOutcome, error) {
+
+	if u.Upsbt.IsInputFinalized(inIndex) {
+		return SignFinalized, nil
+	}
+
+	pInput := &u.Upsbt.Inputs[inIndex]
+	if redeemScript != nil {
+		if pInput.RedeemScript != nil &&
+			!bytes.Equal(pInput.RedeemScript, redeemScript) {
+
+			return SignInvalid, ErrInvalidSignatureForInput
+		}
+		pInput.RedeemScript = redeemScript
+	}
+
+	newPartialSig := &PartialSig{
+		PubKey:    pubKey,
+		SigType:   sigType,
+		Signature: sig,
+	}
+	if !newPartialSig.checkValid() {
+		return SignInvalid, ErrInvalidSignatureForInput
+	}
+	if err := u.verifyPartialSig(inIndex, newPartialSig); err != nil {
+		return SignInvalid, err
+	}
+
+	// Replace any existing signature for the same public key.
+	for i, x := range pInput.PartialSigs {
+		if bytes.Equal(x.PubKey, pubKey) {
+			pInput.PartialSigs[i] = newPartialSig
+			return SignSuccesful, nil
+		}
+	}
+	pInput.PartialSigs = append(pInput.PartialSigs, newPartialSig)
+
+	return SignSuccesful, nil
+}
+
+// signingScript returns the script which is committed to by the signatures of
+// the input at the passed index along with its script class.  For pay to
+// script hash outputs, including stake tagged ones, this is the redeem script.
+func (p *Packet) signingScript(inIndex int) ([]byte, txscript.ScriptClass, error) {
+	pInput := &p.Inputs[inIndex]
+	var prevOut *wire.TxOut
+	switch {
+	case pInput.PrevOut != nil:
+		prevOut = pInput.PrevOut
+	case pInput.Utxo != nil:
+		idx := p.UnsignedTx.TxIn[inIndex].PreviousOutPoint.Index
+		prevOut = pInput.Utxo.TxOut[idx]
+	default:
+		return nil, txscript.NonStandardTy, ErrInvalidPsbtFormat
+	}
+
+	class := txscript.GetScriptClass(prevOut.Version, prevOut.PkScript)
+	subClass := class
+	if txscript.IsStakeOutput(prevOut.PkScript) {
+		var err error
+		subClass, err = txscript.GetStakeOutSubclass(prevOut.PkScript)
+		if err != nil {
+			return nil, txscript.NonStandardTy, err
+		}
+	}
+	if subClass != txscript.ScriptHashTy {
+		return prevOut.PkScript, class, nil
+	}
+
+	if pInput.RedeemScript == nil {
+		return nil, txscript.NonStandardTy, ErrNotFinalizable
+	}
+	redeemClass := txscript.GetScriptClass(txscript.DefaultScriptVersion,
+		pInput.RedeemScript)
+	return pInput.RedeemScript, redeemClass, nil
+}
+
+// verifyPartialSig checks the passed partial signature against the signature
+// hash of the input at the passed index.
+func (u *Updater) verifyPartialSig(inIndex int, ps *PartialSig) error {
+	script, _, err := u.Upsbt.signingScript(inIndex)
+	if err != nil {
+		return err
+	}
+
+	hashType := txscript.SigHashType(ps.Signature[len(ps.Signature)-1])
+	pInput := &u.Upsbt.Inputs[inIndex]
+	if pInput.SighashType != 0 && pInput.SighashType != hashType {
+		return ErrInvalidSignatureForInput
+	}
+
+	pops, err := txscript.ParseScript(script)
+	if err != nil {
+		return err
+	}
+	hash, err := txscript.CalcSignatureHash(pops, hashType,
+		u.Upsbt.UnsignedTx, inIndex, nil)
+	if err != nil {
+		return err
+	}
+
+	sig := ps.Signature[:len(ps.Signature)-1]
+	if !verifySignature(ps.SigType, ps.PubKey, sig, hash) {
+		return ErrInvalidSignatureForInput
+	}
+
+	return nil
+}
+
+// AddInSighashType adds the sighash type information for an input.  The
+// sighash type is passed as a 32 bit unsigned integer, along with the index
+// for the input.  An error is returned if addition of this key-value pair to
+// the Psbt fails.
+func (u *Updater) AddInSighashType(sighashType txscript.SigHashType,
+	inIndex int) error {
+
+	u.Upsbt.Inputs[inIndex].SighashType = sighashType
+	return u.Upsbt.SanityCheck()
+}
+
+// AddInRedeemScript adds the redeem script information for an input.  The
+// redeem script is passed serialized, as a byte slice, along with the index
+// of the input.  An error is returned if addition of this key-value pair to
+// the Psbt fails.
+func (u *Updater) AddInRedeemScript(redeemScript []byte, inIndex int) error {
+	u.Upsbt.Inputs[inIndex].RedeemScript = redeemScript
+	return u.Upsbt.SanityCheck()
+}
+
+// AddInBip32Derivation takes a master key fingerprint as defined in BIP32, a
+// BIP32 path as a slice of uint32 values, and a serialized pubkey as a byte
+// slice, along with the integer index of the input, and inserts this data
+// into that input.
+//
+// NOTE: This can be called multiple times for the same input.  An error is
+// returned if addition of this key-value pair to the Psbt fails.
+func (u *Updater) AddInBip32Derivation(masterKeyFingerprint uint32,
+	bip32Path []uint32, pubKeyData []byte, inIndex int) error {
+
+	bip32Derivation := Bip32Derivation{
+		PubKey:               pubKeyData,
+		MasterKeyFingerprint: masterKeyFingerprint,
+		Bip32Path:            bip32Path,
+	}
+	if !bip32Derivation.checkValid() {
+		return ErrInvalidPsbtFormat
+	}
+
+	// Don't allow duplicate keys
+	for _, x := range u.Upsbt.Inputs[inIndex].Bip32Derivation {
+		if bytes.Equal(x.PubKey, bip32Derivation.PubKey) {
+			return ErrDuplicateKey
+		}
+	}
+
+	u.Upsbt.Inputs[inIndex].Bip32Derivation = append(
+		u.Upsbt.Inputs[inIndex].Bip32Derivation, &bip32Derivation,
+	)
+
+	return u.Upsbt.SanityCheck()
+}
+
+// AddOutBip32Derivation takes a master key fingerprint as defined in BIP32, a
+// BIP32 path as a slice of uint32 values, and a serialized pubkey as a byte
+// slice, along with the integer index of the output, and inserts this data
+// into that output.
+//
+// NOTE: That this can be called multiple times for the same output.  An
+// error is returned if addition of this key-value pair to the Psbt fails.
+func (u *Updater) AddOutBip32Derivation(masterKeyFingerprint uint32,
+	bip32Path []uint32, pubKeyData []byte, outIndex int) error {
+
+	bip32Derivation := Bip32Derivation{
+		PubKey:               pubKeyData,
+		MasterKeyFingerprint: masterKeyFingerprint,
+		Bip32Path:            bip32Path,
+	}
+	if !bip32Derivation.checkValid() {
+		return ErrInvalidPsbtFormat
+	}
+
+	// Don't allow duplicate keys
+	for _, x := range u.Upsbt.Outputs[outIndex].Bip32Derivation {
+		if bytes.Equal(x.PubKey, bip32Derivation.PubKey) {
+			return ErrDuplicateKey
+		}
+	}
+
+	u.Upsbt.Outputs[outIndex].Bip32Derivation = append(
+		u.Upsbt.Outputs[outIndex].Bip32Derivation, &bip32Derivation,
+	)
+
+	return u.Upsbt.SanityCheck()
+}
+
+// AddOutRedeemScript takes a redeem script as a byte slice and appends it to
+// the output at index outIndex.
+func (u *Updater) AddOutRedeemScript(redeemScript []byte,
+	outIndex int) error {
+
+	u.Upsbt.Outputs[outIndex].RedeemScript = redeemScript
+	return u.Upsbt.SanityCheck()
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018 The btcsuite developers
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/james-ray/hcd/wire"
+)
+
+// getKey retrieves a single key - both the key type and the keydata (if
+// present) from the stream and returns the key type as an integer, or -1 if
+// the key was of zero length.  This integer is is used to indicate the
+// presence of a separator byte which indicates the end of a given key-value
+// pair list, and the keydata as a byte slice or nil if none is present.
+func getKey(r io.Reader) (int, []byte, error) {
+	// For the key, we read the varint separately, instead of using the
+	// available ReadVarBytes, because we have a specific treatment of 0x00
+	// here:
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return -1, nil, ErrInvalidPsbtFormat
+	}
+	if count == 0 {
+		// A separator indicates end of key-value pair list.
+		return -1, nil, nil
+	}
+
+	// Check that we don't attempt to decode a dangerously large key.
+	if count > MaxPsbtKeyLength {
+		return -1, nil, ErrInvalidKeydata
+	}
+
+	// Next, we ready out the designated number of bytes, which may include
+	// a type, key, and optional data.
+	keyTypeAndData := make([]byte, count)
+	if _, err := io.ReadFull(r, keyTypeAndData[:]); err != nil {
+		return -1, nil, err
+	}
+
+	keyType := int(keyTypeAndData[0])
+
+	// Note that the second return value will usually be empty, since most
+	// keys contain no more than the key type byte.
+	if len(keyTypeAndData) == 1 {
+		return keyType, nil, nil
+	}
+
+	// Otherwise, we return the key, along with any data that it may
+	// contain.
+	return keyType, keyTypeAndData[1:], nil
+}
+
+// readTxOut is a limited version of the wire transaction output decoding used
+// for the previous output of an input.
+func readTxOut(txout []byte) (*wire.TxOut, error) {
+	// The serialization is value (8), version (2), then the varint length
+	// of the public key script followed by the script.
+	if len(txout) < 11 {
+		return nil, ErrInvalidPsbtFormat
+	}
+	valueSer := binary.LittleEndian.Uint64(txout[:8])
+	version := binary.LittleEndian.Uint16(txout[8:10])
+	scriptPubKey, err := wire.ReadVarBytes(bytes.NewReader(txout[10:]), 0,
+		MaxPsbtValueLength, "PSBT previous output script")
+	if err != nil {
+		return nil, ErrInvalidPsbtFormat
+	}
+	size := 10 + wire.VarIntSerializeSize(uint64(len(scriptPubKey))) +
+		len(scriptPubKey)
+	if size != len(txout) {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	return &wire.TxOut{
+		Value:    int64(valueSer),
+		Version:  version,
+		PkScript: scriptPubKey,
+	}, nil
+}
+
+// writeTxOut serializes the passed transaction output in the format read by
+// readTxOut.
+func writeTxOut(txout *wire.TxOut) ([]byte, error) {
+	var buf bytes.Buffer
+	var scratch [10]byte
+	binary.LittleEndian.PutUint64(scratch[:8], uint64(txout.Value))
+	binary.LittleEndian.PutUint16(scratch[8:], txout.Version)
+	buf.Write(scratch[:])
+	if err := wire.WriteVarBytes(&buf, 0, txout.PkScript); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// serializeKVpair writes out a kv pair using a varbyte prefix for each.
+func serializeKVpair(w io.Writer, key []byte, value []byte) error {
+	if err := wire.WriteVarBytes(w, 0, key); err != nil {
+		return err
+	}
+
+	return wire.WriteVarBytes(w, 0, value)
+}
+
+// serializeKVPairWithType writes out to the passed writer a type coupled with
+// a key.
+func serializeKVPairWithType(w io.Writer, kt uint8, keydata []byte,
+	value []byte) error {
+
+	// If the key has no data, then we write a blank slice.
+	if keydata == nil {
+		keydata = []byte{}
+	}
+
+	// The final key to be written is: {type} || {keyData}
+	serializedKey := append([]byte{kt}, keydata...)
+	return serializeKVpair(w, serializedKey, value)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/btcsuite/btclog"
 	"github.com/james-ray/hcd/addrmgr"
 	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/bootstrap"
 	"github.com/james-ray/hcd/blockchain/indexers"
 	"github.com/james-ray/hcd/blockchain/stake"
 	"github.com/james-ray/hcd/connmgr"
@@ -63,6 +64,7 @@ var (
 	cmgrLog = backendLog.Logger("CMGR")
 	bcdbLog = backendLog.Logger("BCDB")
 	bmgrLog = backendLog.Logger("BMGR")
+	btspLog = backendLog.Logger("BTSP")
 	hcdLog  = backendLog.Logger("HC")
 	chanLog = backendLog.Logger("CHAN")
 	discLog = backendLog.Logger("DISC")
@@ -82,6 +84,7 @@ func init() {
 	connmgr.UseLogger(cmgrLog)
 	database.UseLogger(bcdbLog)
 	blockchain.UseLogger(chanLog)
+	bootstrap.UseLogger(btspLog)
 	indexers.UseLogger(indxLog)
 	peer.UseLogger(peerLog)
 	txscript.UseLogger(scrpLog)
@@ -96,6 +99,7 @@ var subsystemLoggers = map[string]btclog.Logger{
 	"CMGR": cmgrLog,
 	"BCDB": bcdbLog,
 	"BMGR": bmgrLog,
+	"BTSP": btspLog,
 	"HC":   hcdLog,
 	"CHAN": chanLog,
 	"DISC": discLog,
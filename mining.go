@@ -36,7 +36,7 @@ const (
 
 	// generatedBlockVersionTest is the version of the block being generated
 	// for networks other than the main network.
-	generatedBlockVersionTest = 7
+	generatedBlockVersionTest = 8
 
 	// blockHeaderOverhead is the max number of bytes it takes to serialize
 	// a block header and max possible transaction count.
@@ -1801,27 +1801,87 @@ mempoolLoop:
 		}
 	}
 
-	// Get the ticket revocations (SSRtx tx) and store them and their number.
+	// Automatically create revocations for the tickets that were missed or
+	// expired by the previous block when the automatic ticket revocations
+	// agenda is active since blocks are required to revoke all of them.
+	//
+	// They are added before any revocations from the memory pool so they
+	// are never left out due to the revocation limit below.  This is safe
+	// because the number of tickets missed or expired by a single block is
+	// bounded by the tickets per block plus the max fresh stake per block,
+	// which is far below the max revocations per block allowed by the
+	// consensus rules and the header.
 	revocations := 0
+	revoked := make(map[chainhash.Hash]struct{})
+	autoRevocationsActive, err :=
+		blockManager.chain.IsAutoRevocationsAgendaActive()
+	if err != nil {
+		return nil, miningRuleError(ErrGetTopBlock, "couldn't determine "+
+			"automatic ticket revocations agenda state: "+err.Error())
+	}
+	if autoRevocationsActive && nextBlockHeight >= stakeValidationHeight {
+		ticketsToRevoke, err := blockManager.chain.TicketsToRevoke()
+		if err != nil {
+			return nil, miningRuleError(ErrGetTopBlock, "couldn't get "+
+				"tickets to revoke: "+err.Error())
+		}
+		for i := range ticketsToRevoke {
+			ticketHash := &ticketsToRevoke[i]
+			entry, err := blockManager.chain.FetchUtxoEntry(ticketHash)
+			if err != nil || entry == nil {
+				str := fmt.Sprintf("unable to fetch ticket %v to "+
+					"revoke: %v", ticketHash, err)
+				return nil, miningRuleError(ErrGetTopBlock, str)
+			}
+			minimalOutputs := blockchain.ConvertUtxosToMinimalOutputs(entry)
+			revocation, err := stake.CreateAutoRevocation(ticketHash,
+				minimalOutputs)
+			if err != nil {
+				str := fmt.Sprintf("unable to create revocation for "+
+					"ticket %v: %v", ticketHash, err)
+				return nil, miningRuleError(ErrGetTopBlock, str)
+			}
+			tx := hcutil.NewTx(revocation)
+			tx.SetTree(wire.TxTreeStake)
+			if !maybeInsertStakeTx(blockManager, tx, treeValid) {
+				str := fmt.Sprintf("unable to insert revocation "+
+					"for ticket %v", ticketHash)
+				return nil, miningRuleError(ErrGetTopBlock, str)
+			}
+			blockTxnsStake = append(blockTxnsStake, tx)
+			revoked[*ticketHash] = struct{}{}
+			revocations++
+		}
+	}
+
+	// Get the ticket revocations (SSRtx tx) and store them and their number.
 	for _, tx := range blockTxns {
 		if nextBlockHeight < stakeValidationHeight {
 			break // No SSRtx should be present before this height.
 		}
 
+		// Don't let this overflow.
+		if revocations >= math.MaxUint8 {
+			break
+		}
+
 		msgTx := tx.MsgTx()
 		isSSRtx, _ := stake.IsSSRtx(msgTx)
 		if tx.Tree() == wire.TxTreeStake && isSSRtx {
+			// Skip revocations of tickets that were already revoked
+			// automatically above.
+			ticketHash := msgTx.TxIn[0].PreviousOutPoint.Hash
+			if _, ok := revoked[ticketHash]; ok {
+				continue
+			}
+
 			txCopy := hcutil.NewTxDeepTxIns(msgTx)
 			if maybeInsertStakeTx(blockManager, txCopy, treeValid) {
 				blockTxnsStake = append(blockTxnsStake, txCopy)
+				revoked[ticketHash] = struct{}{}
 				revocations++
 			}
 		}
-
-		// Don't let this overflow.
-		if revocations >= math.MaxUint8 {
-			break
-		}
 	}
 
 	// Create a standard coinbase transaction paying to the provided
@@ -7,6 +7,8 @@
 package main
 
 import (
+	"strconv"
+
 	"github.com/james-ray/hcd/chaincfg"
 	"github.com/james-ray/hcd/wire"
 )
@@ -47,6 +49,35 @@ var simNetParams = params{
 	rpcPort: "13009",
 }
 
+// regNetParams contains parameters specific to the regression test network
+// (wire.RegTest).
+var regNetParams = params{
+	Params:  &chaincfg.RegNetParams,
+	rpcPort: "15009",
+}
+
+// loadChainParams reads the parameters of a custom network from the JSON file at
+// the passed path and registers them.  The RPC port of the network is the port
+// after its default peer-to-peer port, which matches the convention of the
+// default networks.
+func loadChainParams(path string) (*params, error) {
+	chainParams, err := chaincfg.ParamsFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := chaincfg.Register(chainParams); err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(chainParams.DefaultPort, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	return &params{
+		Params:  chainParams,
+		rpcPort: strconv.FormatUint(port+1, 10),
+	}, nil
+}
+
 // netName returns the name used when referring to a hcd network.  At the
 // time of writing, hcd currently places blocks for testnet version 0 in the
 // data and log directory "testnet", which does not match the Name field of the
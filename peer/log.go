@@ -156,6 +156,9 @@ func messageSummary(msg wire.Message) string {
 	case *wire.MsgAddr:
 		return fmt.Sprintf("%d addr", len(msg.AddrList))
 
+	case *wire.MsgAddrV2:
+		return fmt.Sprintf("%d addr", len(msg.AddrList))
+
 	case *wire.MsgPing:
 		// No summary - perhaps add nonce.
 
@@ -196,6 +199,23 @@ func messageSummary(msg wire.Message) string {
 	case *wire.MsgHeaders:
 		return fmt.Sprintf("num %d", len(msg.Headers))
 
+	case *wire.MsgSendCmpct:
+		return fmt.Sprintf("announce %v, ver %d", msg.Announce,
+			msg.Version)
+
+	case *wire.MsgCmpctBlock:
+		return fmt.Sprintf("hash %s, %d short ids, %d stake short ids",
+			msg.Header.BlockHash(), len(msg.ShortIDs),
+			len(msg.SShortIDs))
+
+	case *wire.MsgGetBlockTxn:
+		return fmt.Sprintf("hash %s, %d tx, %d stx", msg.BlockHash,
+			len(msg.TxIndexes), len(msg.STxIndexes))
+
+	case *wire.MsgBlockTxn:
+		return fmt.Sprintf("hash %s, %d tx, %d stx", msg.BlockHash,
+			len(msg.Transactions), len(msg.STransactions))
+
 	case *wire.MsgReject:
 		// Ensure the variable length strings don't contain any
 		// characters which are even remotely dangerous such as HTML
@@ -9,6 +9,7 @@ package peer
 import (
 	"bytes"
 	"container/list"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -29,7 +30,7 @@ import (
 
 const (
 	// MaxProtocolVersion is the max protocol version the peer supports.
-	MaxProtocolVersion = wire.FeeFilterVersion
+	MaxProtocolVersion = wire.AddrV2Version
 
 	// outputBufferSize is the number of elements the output channels use.
 	outputBufferSize = 5000
@@ -175,6 +176,28 @@ type MessageListeners struct {
 	// message.
 	OnSendHeaders func(p *Peer, msg *wire.MsgSendHeaders)
 
+	// OnSendAddrV2 is invoked when a peer receives a sendaddrv2 wire
+	// message.
+	OnSendAddrV2 func(p *Peer, msg *wire.MsgSendAddrV2)
+
+	// OnAddrV2 is invoked when a peer receives an addrv2 wire message.
+	OnAddrV2 func(p *Peer, msg *wire.MsgAddrV2)
+
+	// OnSendCmpct is invoked when a peer receives a sendcmpct wire
+	// message.
+	OnSendCmpct func(p *Peer, msg *wire.MsgSendCmpct)
+
+	// OnCmpctBlock is invoked when a peer receives a cmpctblock wire
+	// message.
+	OnCmpctBlock func(p *Peer, msg *wire.MsgCmpctBlock)
+
+	// OnGetBlockTxn is invoked when a peer receives a getblocktxn wire
+	// message.
+	OnGetBlockTxn func(p *Peer, msg *wire.MsgGetBlockTxn)
+
+	// OnBlockTxn is invoked when a peer receives a blocktxn wire message.
+	OnBlockTxn func(p *Peer, msg *wire.MsgBlockTxn)
+
 	// OnRead is invoked when a peer receives a wire message.  It consists
 	// of the number of bytes read, the message, and whether or not an error
 	// in the read occurred.  Typically, callers will opt to use the
@@ -237,6 +260,12 @@ type Config struct {
 	// not send inv messages for transactions.
 	DisableRelayTx bool
 
+	// V2Transport specifies whether or not to use the encrypted and
+	// authenticated v2 transport.  Outbound peers require the remote peer
+	// to complete the v2 handshake, while inbound peers fall back to the
+	// plaintext v1 transport when the remote peer doesn't attempt it.
+	V2Transport bool
+
 	// Listeners houses callback functions to be invoked on receiving peer
 	// messages.
 	Listeners MessageListeners
@@ -342,6 +371,8 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+	Transport      string
+	SessionID      string
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -393,6 +424,12 @@ type Peer struct {
 
 	conn net.Conn
 
+	// These fields are set while negotiating the transport and only used
+	// by the goroutines which read from and write to the connection
+	// afterwards.
+	connReader io.Reader
+	v2         *v2Transport
+
 	// These fields are set at creation time and never modified, so they are
 	// safe to read from concurrently without a mutex.
 	addr    string
@@ -408,6 +445,9 @@ type Peer struct {
 	advertisedProtoVer   uint32 // protocol version advertised by remote
 	protocolVersion      uint32 // negotiated protocol version
 	sendHeadersPreferred bool   // peer sent a sendheaders message
+	wantsAddrV2          bool   // peer sent a sendaddrv2 message
+	v2SessionID          string // session id of the v2 transport if any
+	v2Rejected           bool   // peer closed the conn during the v2 handshake
 	versionSent          bool
 	verAckReceived       bool
 
@@ -485,6 +525,14 @@ func (p *Peer) AddKnownInventory(invVect *wire.InvVect) {
 	p.knownInventory.Add(invVect)
 }
 
+// HasKnownInventory returns whether or not the passed inventory is in the
+// cache of known inventory for the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) HasKnownInventory(invVect *wire.InvVect) bool {
+	return p.knownInventory.Exists(invVect)
+}
+
 // StatsSnapshot returns a snapshot of the current peer flags and statistics.
 //
 // This function is safe for concurrent access.
@@ -497,8 +545,14 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 	userAgent := p.userAgent
 	services := p.services
 	protocolVersion := p.advertisedProtoVer
+	sessionID := p.v2SessionID
 	p.flagsMtx.Unlock()
 
+	transport := "v1"
+	if sessionID != "" {
+		transport = "v2"
+	}
+
 	// Get a copy of all relevant flags and stats.
 	statsSnap := &StatsSnap{
 		ID:             id,
@@ -518,6 +572,8 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingNonce:  p.lastPingNonce,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+		Transport:      transport,
+		SessionID:      sessionID,
 	}
 
 	p.statsMtx.RUnlock()
@@ -750,6 +806,43 @@ func (p *Peer) WantsHeaders() bool {
 	return sendHeadersPreferred
 }
 
+// WantsAddrV2 returns if the peer wants addresses to be relayed with addrv2
+// messages instead of addr messages.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsAddrV2() bool {
+	p.flagsMtx.Lock()
+	wantsAddrV2 := p.wantsAddrV2
+	p.flagsMtx.Unlock()
+
+	return wantsAddrV2
+}
+
+// V2Transport returns whether or not the connection to the peer uses the
+// encrypted v2 transport.
+//
+// This function is safe for concurrent access.
+func (p *Peer) V2Transport() bool {
+	p.flagsMtx.Lock()
+	v2 := p.v2SessionID != ""
+	p.flagsMtx.Unlock()
+
+	return v2
+}
+
+// V2Rejected returns whether or not the remote peer of an outbound connection
+// closed it before sending its public key when the v2 transport was attempted,
+// which means it likely only supports the v1 transport.
+//
+// This function is safe for concurrent access.
+func (p *Peer) V2Rejected() bool {
+	p.flagsMtx.Lock()
+	rejected := p.v2Rejected
+	p.flagsMtx.Unlock()
+
+	return rejected
+}
+
 // localVersionMsg creates a version message that can be used to send to the
 // remote peer.
 func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
@@ -836,35 +929,49 @@ func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
 // addresses.  This function is useful over manually sending the message via
 // QueueMessage since it automatically limits the addresses to the maximum
 // number allowed by the message and randomizes the chosen addresses when there
-// are too many.  It returns the addresses that were actually sent and no
+// are too many.  An addrv2 message is sent instead when the peer asked for
+// them, otherwise the addresses which can only be relayed with addrv2 messages
+// are skipped.  It returns the addresses that were actually sent and no
 // message will be sent if there are no entries in the provided addresses slice.
 //
 // This function is safe for concurrent access.
 func (p *Peer) PushAddrMsg(addresses []*wire.NetAddress) ([]*wire.NetAddress, error) {
+	wantsAddrV2 := p.WantsAddrV2()
+	addrList := make([]*wire.NetAddress, 0, len(addresses))
+	for _, na := range addresses {
+		if !wantsAddrV2 && na.NeedsAddrV2() {
+			continue
+		}
+		addrList = append(addrList, na)
+	}
 
 	// Nothing to send.
-	if len(addresses) == 0 {
+	if len(addrList) == 0 {
 		return nil, nil
 	}
 
-	msg := wire.NewMsgAddr()
-	msg.AddrList = make([]*wire.NetAddress, len(addresses))
-	copy(msg.AddrList, addresses)
-
 	// Randomize the addresses sent if there are more than the maximum allowed.
-	if len(msg.AddrList) > wire.MaxAddrPerMsg {
+	if len(addrList) > wire.MaxAddrPerMsg {
 		// Shuffle the address list.
-		for i := range msg.AddrList {
+		for i := range addrList {
 			j := rand.Intn(i + 1)
-			msg.AddrList[i], msg.AddrList[j] = msg.AddrList[j], msg.AddrList[i]
+			addrList[i], addrList[j] = addrList[j], addrList[i]
 		}
 
 		// Truncate it to the maximum size.
-		msg.AddrList = msg.AddrList[:wire.MaxAddrPerMsg]
+		addrList = addrList[:wire.MaxAddrPerMsg]
 	}
 
-	p.QueueMessage(msg, nil)
-	return msg.AddrList, nil
+	if wantsAddrV2 {
+		msg := wire.NewMsgAddrV2()
+		msg.AddrList = addrList
+		p.QueueMessage(msg, nil)
+	} else {
+		msg := wire.NewMsgAddr()
+		msg.AddrList = addrList
+		p.QueueMessage(msg, nil)
+	}
+	return addrList, nil
 }
 
 // PushGetBlocksMsg sends a getblocks message for the provided block locator
@@ -1081,8 +1188,16 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 
 // readMessage reads the next wire message from the peer with logging.
 func (p *Peer) readMessage() (wire.Message, []byte, error) {
-	n, msg, buf, err := wire.ReadMessageN(p.conn, p.ProtocolVersion(),
-		p.cfg.ChainParams.Net)
+	var n int
+	var msg wire.Message
+	var buf []byte
+	var err error
+	if p.v2 != nil {
+		n, msg, buf, err = p.v2.readMessage(p.ProtocolVersion())
+	} else {
+		n, msg, buf, err = wire.ReadMessageN(p.connReader,
+			p.ProtocolVersion(), p.cfg.ChainParams.Net)
+	}
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
@@ -1144,8 +1259,14 @@ func (p *Peer) writeMessage(msg wire.Message) error {
 	}))
 
 	// Write the message to the peer.
-	n, err := wire.WriteMessageN(p.conn, msg, p.ProtocolVersion(),
-		p.cfg.ChainParams.Net)
+	var n int
+	var err error
+	if p.v2 != nil {
+		n, err = p.v2.writeMessage(msg, p.ProtocolVersion())
+	} else {
+		n, err = wire.WriteMessageN(p.conn, msg, p.ProtocolVersion(),
+			p.cfg.ChainParams.Net)
+	}
 	atomic.AddUint64(&p.bytesSent, uint64(n))
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
@@ -1201,11 +1322,16 @@ func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msgCmd st
 		pendingResponses[wire.CmdInv] = deadline
 
 	case wire.CmdGetData:
-		// Expects a block, tx, or notfound message.
+		// Expects a block, cmpctblock, tx, or notfound message.
 		pendingResponses[wire.CmdBlock] = deadline
+		pendingResponses[wire.CmdCmpctBlock] = deadline
 		pendingResponses[wire.CmdTx] = deadline
 		pendingResponses[wire.CmdNotFound] = deadline
 
+	case wire.CmdGetBlockTxn:
+		// Expects a blocktxn message.
+		pendingResponses[wire.CmdBlockTxn] = deadline
+
 	case wire.CmdGetHeaders:
 		// Expects a headers message.  Use a longer deadline since it
 		// can take a while for the remote peer to load all of the
@@ -1262,10 +1388,13 @@ out:
 				switch msgCmd := msg.message.Command(); msgCmd {
 				case wire.CmdBlock:
 					fallthrough
+				case wire.CmdCmpctBlock:
+					fallthrough
 				case wire.CmdTx:
 					fallthrough
 				case wire.CmdNotFound:
 					delete(pendingResponses, wire.CmdBlock)
+					delete(pendingResponses, wire.CmdCmpctBlock)
 					delete(pendingResponses, wire.CmdTx)
 					delete(pendingResponses, wire.CmdNotFound)
 
@@ -1443,6 +1572,26 @@ out:
 				p.cfg.Listeners.OnAddr(p, msg)
 			}
 
+		case *wire.MsgSendAddrV2:
+			// The message is only valid before the verack message.
+			if p.VerAckReceived() {
+				log.Debugf("Ignoring sendaddrv2 received after "+
+					"verack from %v", p)
+				break
+			}
+			p.flagsMtx.Lock()
+			p.wantsAddrV2 = true
+			p.flagsMtx.Unlock()
+
+			if p.cfg.Listeners.OnSendAddrV2 != nil {
+				p.cfg.Listeners.OnSendAddrV2(p, msg)
+			}
+
+		case *wire.MsgAddrV2:
+			if p.cfg.Listeners.OnAddrV2 != nil {
+				p.cfg.Listeners.OnAddrV2(p, msg)
+			}
+
 		case *wire.MsgPing:
 			p.handlePingMsg(msg)
 			if p.cfg.Listeners.OnPing != nil {
@@ -1549,6 +1698,26 @@ out:
 				p.cfg.Listeners.OnSendHeaders(p, msg)
 			}
 
+		case *wire.MsgSendCmpct:
+			if p.cfg.Listeners.OnSendCmpct != nil {
+				p.cfg.Listeners.OnSendCmpct(p, msg)
+			}
+
+		case *wire.MsgCmpctBlock:
+			if p.cfg.Listeners.OnCmpctBlock != nil {
+				p.cfg.Listeners.OnCmpctBlock(p, msg)
+			}
+
+		case *wire.MsgGetBlockTxn:
+			if p.cfg.Listeners.OnGetBlockTxn != nil {
+				p.cfg.Listeners.OnGetBlockTxn(p, msg)
+			}
+
+		case *wire.MsgBlockTxn:
+			if p.cfg.Listeners.OnBlockTxn != nil {
+				p.cfg.Listeners.OnBlockTxn(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
@@ -1866,6 +2035,7 @@ func (p *Peer) AssociateConnection(conn net.Conn) {
 	}
 
 	p.conn = conn
+	p.connReader = conn
 	p.timeConnected = time.Now()
 
 	if p.inbound {
@@ -1920,6 +2090,10 @@ func (p *Peer) start() error {
 
 	negotiateErr := make(chan error)
 	go func() {
+		if err := p.negotiateTransport(); err != nil {
+			negotiateErr <- err
+			return
+		}
 		if p.inbound {
 			negotiateErr <- p.negotiateInboundProtocol()
 		} else {
@@ -1945,7 +2119,12 @@ func (p *Peer) start() error {
 	go p.queueHandler()
 	go p.outHandler()
 
-	// Send our verack message now that the IO processing machinery has started.
+	// Signal support for addrv2 messages, which must be done before the
+	// verack message, and send our verack message now that the IO
+	// processing machinery has started.
+	if p.ProtocolVersion() >= wire.AddrV2Version {
+		p.QueueMessage(wire.NewMsgSendAddrV2(), nil)
+	}
 	p.QueueMessage(wire.NewMsgVerAck(), nil)
 	return nil
 }
@@ -2006,6 +2185,42 @@ func (p *Peer) writeLocalVersionMsg() error {
 	return nil
 }
 
+// negotiateTransport performs the v2 transport handshake when the v2
+// transport is enabled.  Inbound peers continue with the v1 transport when the
+// remote peer sent a v1 version message instead, in which case the bytes read
+// during the handshake are replayed to the v1 message reader.
+func (p *Peer) negotiateTransport() error {
+	if !p.cfg.V2Transport {
+		return nil
+	}
+
+	t, v1Bytes, bytesRead, bytesWritten, err := v2Handshake(p.conn,
+		p.conn, !p.inbound, p.cfg.ChainParams.Net)
+	atomic.AddUint64(&p.bytesSent, uint64(bytesWritten))
+	if err != nil {
+		atomic.AddUint64(&p.bytesReceived, uint64(bytesRead))
+		if !p.inbound && isV2Rejection(err, bytesRead) {
+			p.flagsMtx.Lock()
+			p.v2Rejected = true
+			p.flagsMtx.Unlock()
+		}
+		return err
+	}
+	if t == nil {
+		log.Debugf("Using v1 transport with %s", p)
+		p.connReader = io.MultiReader(bytes.NewReader(v1Bytes), p.conn)
+		return nil
+	}
+	atomic.AddUint64(&p.bytesReceived, uint64(bytesRead))
+
+	p.v2 = t
+	p.flagsMtx.Lock()
+	p.v2SessionID = hex.EncodeToString(t.sessionID[:])
+	p.flagsMtx.Unlock()
+	log.Debugf("Using v2 transport with %s (session id %x)", p, t.sessionID)
+	return nil
+}
+
 // negotiateInboundProtocol waits to receive a version message from the peer
 // then sends our version message. If the events do not occur in that order then
 // it returns an error.
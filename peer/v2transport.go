@@ -0,0 +1,400 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/james-ray/hcd/hcec/secp256k1"
+	"github.com/james-ray/hcd/wire"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// v2RekeyInterval is the number of packets after which the ciphers of
+	// the v2 transport derive a new key in order to provide forward
+	// secrecy.
+	v2RekeyInterval = 224
+
+	// v2LengthLen is the length of the encrypted length field which
+	// precedes every v2 transport packet.
+	v2LengthLen = 3
+
+	// v2HeaderLen is the length of the header which precedes the contents
+	// of every v2 transport packet.
+	v2HeaderLen = 1
+
+	// v2TagLen is the length of the authentication tag which follows the
+	// encrypted header and contents of every v2 transport packet.
+	v2TagLen = 16
+
+	// v2IgnoreBit is the header bit which marks decoy packets that must
+	// be ignored by the receiver.
+	v2IgnoreBit = 0x80
+
+	// v2MaxContentsLen is the maximum length of the contents of a v2
+	// transport packet, which is a message type and a message payload.
+	v2MaxContentsLen = 1 + wire.CommandSize + wire.MaxMessagePayload
+
+	// v1PrefixLen is the number of bytes which are inspected by the
+	// responder of a v2 transport handshake in order to detect peers which
+	// only support the v1 transport.
+	v1PrefixLen = 4 + wire.CommandSize
+)
+
+// errV2PacketAuth is returned when a v2 transport packet fails to
+// authenticate, which indicates it was tampered with.
+var errV2PacketAuth = errors.New("v2 transport packet authentication failed")
+
+// isV2Rejection returns whether or not the passed error of a v2 transport
+// handshake initiated by us, which read the passed number of bytes, means the
+// remote peer closed the connection before sending its public key.  That is
+// how peers which only support the v1 transport respond to the handshake, so
+// other errors must not be taken as a lack of support.
+func isV2Rejection(err error, bytesRead int) bool {
+	return bytesRead < secp256k1.EllSwiftPubKeyLen &&
+		(err == io.EOF || err == io.ErrUnexpectedEOF)
+}
+
+// v1Prefix returns the first bytes of a v1 version message on the passed
+// network, which is what a peer that only supports the v1 transport sends
+// first.
+func v1Prefix(net wire.CurrencyNet) []byte {
+	prefix := make([]byte, v1PrefixLen)
+	binary.LittleEndian.PutUint32(prefix, uint32(net))
+	copy(prefix[4:], wire.CmdVersion)
+	return prefix
+}
+
+// fsChaCha20 is a ChaCha20 stream cipher which encrypts the length fields of
+// v2 transport packets and replaces its key every v2RekeyInterval chunks.
+type fsChaCha20 struct {
+	key    [chacha20.KeySize]byte
+	stream *chacha20.Cipher
+	chunks uint32
+	rekeys uint64
+}
+
+// crypt encrypts or decrypts the passed chunk in place.
+func (c *fsChaCha20) crypt(chunk []byte) {
+	if c.stream == nil {
+		var nonce [chacha20.NonceSize]byte
+		binary.LittleEndian.PutUint64(nonce[4:], c.rekeys)
+		c.stream, _ = chacha20.NewUnauthenticatedCipher(c.key[:],
+			nonce[:])
+	}
+	c.stream.XORKeyStream(chunk, chunk)
+
+	// The next key is the keystream which follows the last chunk.
+	c.chunks++
+	if c.chunks == v2RekeyInterval {
+		var key [chacha20.KeySize]byte
+		c.stream.XORKeyStream(key[:], key[:])
+		c.key = key
+		c.stream = nil
+		c.chunks = 0
+		c.rekeys++
+	}
+}
+
+// fsChaCha20Poly1305 is a ChaCha20-Poly1305 AEAD which encrypts and
+// authenticates the headers and contents of v2 transport packets and replaces
+// its key every v2RekeyInterval packets.
+type fsChaCha20Poly1305 struct {
+	aead    cipher.AEAD
+	packets uint32
+	rekeys  uint64
+}
+
+// newFSChaCha20Poly1305 returns a new AEAD using the passed initial key.
+func newFSChaCha20Poly1305(key []byte) *fsChaCha20Poly1305 {
+	aead, _ := chacha20poly1305.New(key)
+	return &fsChaCha20Poly1305{aead: aead}
+}
+
+// nonce returns the nonce for the packet with the passed number within the
+// current key.
+func (c *fsChaCha20Poly1305) nonce(packet uint32) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint32(nonce, packet)
+	binary.LittleEndian.PutUint64(nonce[4:], c.rekeys)
+	return nonce
+}
+
+// next advances to the next packet and replaces the key once the rekey
+// interval is reached.  The next key is the encryption of zeros with a nonce
+// that is never used for packets.
+func (c *fsChaCha20Poly1305) next() {
+	c.packets++
+	if c.packets == v2RekeyInterval {
+		var zeros [chacha20poly1305.KeySize]byte
+		key := c.aead.Seal(nil, c.nonce(0xffffffff), zeros[:], nil)
+		c.aead, _ = chacha20poly1305.New(key[:chacha20poly1305.KeySize])
+		c.packets = 0
+		c.rekeys++
+	}
+}
+
+// seal encrypts and authenticates the passed plaintext.
+func (c *fsChaCha20Poly1305) seal(plaintext []byte) []byte {
+	ciphertext := c.aead.Seal(nil, c.nonce(c.packets), plaintext, nil)
+	c.next()
+	return ciphertext
+}
+
+// open decrypts and authenticates the passed ciphertext.
+func (c *fsChaCha20Poly1305) open(ciphertext []byte) ([]byte, error) {
+	plaintext, err := c.aead.Open(nil, c.nonce(c.packets), ciphertext, nil)
+	if err != nil {
+		return nil, errV2PacketAuth
+	}
+	c.next()
+	return plaintext, nil
+}
+
+// v2Transport houses the state of an established v2 transport, which encrypts
+// and authenticates all messages exchanged with a peer.  Each packet consists
+// of its encrypted length followed by its encrypted header and contents and
+// an authentication tag.  Sending and receiving are independent so they may
+// be used concurrently.
+type v2Transport struct {
+	r         io.Reader
+	w         io.Writer
+	sendL     fsChaCha20
+	sendP     *fsChaCha20Poly1305
+	recvL     fsChaCha20
+	recvP     *fsChaCha20Poly1305
+	sessionID [32]byte
+}
+
+// taggedHash returns the SHA-256 hash of the passed data prefixed with the
+// hash of the passed tag twice.
+func taggedHash(tag string, data ...[]byte) [32]byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	var hash [32]byte
+	copy(hash[:], h.Sum(nil))
+	return hash
+}
+
+// newV2Transport derives the keys of a v2 transport from the shared secret
+// of the ECDH exchange of the passed ElligatorSwift encoded public keys.
+func newV2Transport(r io.Reader, w io.Writer, initiator bool, net wire.CurrencyNet,
+	privKey *secp256k1.PrivateKey, ours, theirs *[secp256k1.EllSwiftPubKeyLen]byte) *v2Transport {
+
+	ellI, ellR := ours, theirs
+	if !initiator {
+		ellI, ellR = theirs, ours
+	}
+	ecdh := secp256k1.EllSwiftECDH(privKey, theirs)
+	secret := taggedHash("hc_v2_ellswift_xonly_ecdh", ellI[:], ellR[:],
+		ecdh[:])
+
+	var magic [4]byte
+	binary.LittleEndian.PutUint32(magic[:], uint32(net))
+	salt := append([]byte("hc_v2_shared_secret"), magic[:]...)
+	prk := hkdf.Extract(sha256.New, secret[:], salt)
+	expand := func(info string) []byte {
+		key := make([]byte, 32)
+		io.ReadFull(hkdf.Expand(sha256.New, prk, []byte(info)), key)
+		return key
+	}
+
+	t := &v2Transport{r: r, w: w}
+	initiatorL, initiatorP := expand("initiator_L"), expand("initiator_P")
+	responderL, responderP := expand("responder_L"), expand("responder_P")
+	if initiator {
+		copy(t.sendL.key[:], initiatorL)
+		copy(t.recvL.key[:], responderL)
+		t.sendP = newFSChaCha20Poly1305(initiatorP)
+		t.recvP = newFSChaCha20Poly1305(responderP)
+	} else {
+		copy(t.sendL.key[:], responderL)
+		copy(t.recvL.key[:], initiatorL)
+		t.sendP = newFSChaCha20Poly1305(responderP)
+		t.recvP = newFSChaCha20Poly1305(initiatorP)
+	}
+	copy(t.sessionID[:], expand("session_id"))
+	return t
+}
+
+// v2Handshake performs the v2 transport handshake over the passed reader and
+// writer.  Both sides send an ElligatorSwift encoded ephemeral public key,
+// derive the keys of the transport from their ECDH exchange and then send a
+// version packet, which proves the other side derived the same keys.
+//
+// When the passed side is the responder and the initiator turns out to have
+// sent a v1 version message instead of a public key, no handshake is
+// performed and the bytes that were already read are returned instead so the
+// connection can continue with the v1 transport.
+func v2Handshake(r io.Reader, w io.Writer, initiator bool, net wire.CurrencyNet) (*v2Transport, []byte, int, int, error) {
+	var bytesRead, bytesWritten int
+	privKey, err := secp256k1.GeneratePrivateKey(secp256k1.S256())
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	pkx, pky := privKey.Public()
+	pubKey := secp256k1.NewPublicKey(secp256k1.S256(), pkx, pky)
+
+	// Encode the public key ensuring the encoding can't be mistaken for a
+	// v1 version message.
+	prefix := v1Prefix(net)
+	var ours [secp256k1.EllSwiftPubKeyLen]byte
+	for {
+		ours, err = secp256k1.EllSwiftEncode(pubKey, rand.Reader)
+		if err != nil {
+			return nil, nil, 0, 0, err
+		}
+		if !bytes.Equal(ours[:v1PrefixLen], prefix) {
+			break
+		}
+	}
+
+	var theirs [secp256k1.EllSwiftPubKeyLen]byte
+	if initiator {
+		n, err := w.Write(ours[:])
+		bytesWritten += n
+		if err != nil {
+			return nil, nil, bytesRead, bytesWritten, err
+		}
+		n, err = io.ReadFull(r, theirs[:])
+		bytesRead += n
+		if err != nil {
+			return nil, nil, bytesRead, bytesWritten, err
+		}
+	} else {
+		n, err := io.ReadFull(r, theirs[:v1PrefixLen])
+		bytesRead += n
+		if err != nil {
+			return nil, nil, bytesRead, bytesWritten, err
+		}
+		if bytes.Equal(theirs[:v1PrefixLen], prefix) {
+			return nil, theirs[:v1PrefixLen], bytesRead,
+				bytesWritten, nil
+		}
+		n, err = io.ReadFull(r, theirs[v1PrefixLen:])
+		bytesRead += n
+		if err != nil {
+			return nil, nil, bytesRead, bytesWritten, err
+		}
+		n, err = w.Write(ours[:])
+		bytesWritten += n
+		if err != nil {
+			return nil, nil, bytesRead, bytesWritten, err
+		}
+	}
+
+	// Exchange the version packets.  Their contents are reserved for
+	// future extensions and ignored.
+	t := newV2Transport(r, w, initiator, net, privKey, &ours, &theirs)
+	n, err := t.writePacket(nil, false)
+	bytesWritten += n
+	if err != nil {
+		return nil, nil, bytesRead, bytesWritten, err
+	}
+	n, _, err = t.readPacket()
+	bytesRead += n
+	if err != nil {
+		return nil, nil, bytesRead, bytesWritten, err
+	}
+
+	return t, nil, bytesRead, bytesWritten, nil
+}
+
+// writePacket encrypts the passed contents and writes them as a packet, which
+// is marked as a decoy when ignore is set.  It returns the number of bytes
+// written.
+func (t *v2Transport) writePacket(contents []byte, ignore bool) (int, error) {
+	if len(contents) > v2MaxContentsLen {
+		return 0, fmt.Errorf("v2 transport packet contents of %d bytes "+
+			"exceed the maximum of %d bytes", len(contents),
+			v2MaxContentsLen)
+	}
+
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(contents)))
+	t.sendL.crypt(length[:v2LengthLen])
+
+	plaintext := make([]byte, v2HeaderLen, v2HeaderLen+len(contents))
+	if ignore {
+		plaintext[0] = v2IgnoreBit
+	}
+	plaintext = append(plaintext, contents...)
+
+	packet := append(length[:v2LengthLen], t.sendP.seal(plaintext)...)
+	return t.w.Write(packet)
+}
+
+// readPacket reads the next packet which is not a decoy and returns its
+// decrypted contents along with the number of bytes read.
+func (t *v2Transport) readPacket() (int, []byte, error) {
+	var bytesRead int
+	for {
+		var length [4]byte
+		n, err := io.ReadFull(t.r, length[:v2LengthLen])
+		bytesRead += n
+		if err != nil {
+			return bytesRead, nil, err
+		}
+		t.recvL.crypt(length[:v2LengthLen])
+		contentsLen := binary.LittleEndian.Uint32(length[:])
+		if contentsLen > v2MaxContentsLen {
+			return bytesRead, nil, fmt.Errorf("v2 transport packet "+
+				"contents of %d bytes exceed the maximum of %d "+
+				"bytes", contentsLen, v2MaxContentsLen)
+		}
+
+		ciphertext := make([]byte, v2HeaderLen+int(contentsLen)+
+			v2TagLen)
+		n, err = io.ReadFull(t.r, ciphertext)
+		bytesRead += n
+		if err != nil {
+			return bytesRead, nil, err
+		}
+		plaintext, err := t.recvP.open(ciphertext)
+		if err != nil {
+			return bytesRead, nil, err
+		}
+		if plaintext[0]&v2IgnoreBit != 0 {
+			continue
+		}
+		return bytesRead, plaintext[v2HeaderLen:], nil
+	}
+}
+
+// writeMessage writes the passed message as a packet and returns the number
+// of bytes written.
+func (t *v2Transport) writeMessage(msg wire.Message, pver uint32) (int, error) {
+	contents, err := wire.EncodeV2Message(msg, pver)
+	if err != nil {
+		return 0, err
+	}
+	return t.writePacket(contents, false)
+}
+
+// readMessage reads the next message from the transport.  It returns the
+// number of bytes read in addition to the parsed message and its raw payload.
+func (t *v2Transport) readMessage(pver uint32) (int, wire.Message, []byte, error) {
+	n, contents, err := t.readPacket()
+	if err != nil {
+		return n, nil, nil, err
+	}
+	msg, payload, err := wire.DecodeV2Message(contents, pver)
+	return n, msg, payload, err
+}
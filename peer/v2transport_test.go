@@ -0,0 +1,234 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/james-ray/hcd/wire"
+)
+
+// handshakeResult houses the result of one side of a v2 transport handshake.
+type handshakeResult struct {
+	t       *v2Transport
+	v1Bytes []byte
+	err     error
+}
+
+// v2HandshakePipe performs a v2 transport handshake between both ends of a
+// loopback connection and returns the transports of the initiator and the
+// responder.  A real connection is used rather than an unbuffered in-memory
+// pipe since both sides write their version packets before reading.
+func v2HandshakePipe(t *testing.T) (*v2Transport, *v2Transport) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: unexpected error: %v", err)
+	}
+	defer listener.Close()
+	initConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: unexpected error: %v", err)
+	}
+	respConn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept: unexpected error: %v", err)
+	}
+
+	results := make(chan handshakeResult)
+	go func() {
+		tr, v1Bytes, _, _, err := v2Handshake(respConn, respConn, false,
+			wire.SimNet)
+		results <- handshakeResult{tr, v1Bytes, err}
+	}()
+	initiator, _, _, _, err := v2Handshake(initConn, initConn, true,
+		wire.SimNet)
+	if err != nil {
+		t.Fatalf("v2Handshake: unexpected initiator error: %v", err)
+	}
+	resp := <-results
+	if resp.err != nil {
+		t.Fatalf("v2Handshake: unexpected responder error: %v", resp.err)
+	}
+	if initiator.sessionID != resp.t.sessionID {
+		t.Fatalf("v2Handshake: session ids mismatch - initiator: %x, "+
+			"responder: %x", initiator.sessionID, resp.t.sessionID)
+	}
+	return initiator, resp.t
+}
+
+// TestV2Transport ensures messages are exchanged over the v2 transport in both
+// directions including across rekeys and decoy packets.
+func TestV2Transport(t *testing.T) {
+	initiator, responder := v2HandshakePipe(t)
+
+	pver := wire.ProtocolVersion
+	send := func(from, to *v2Transport, msg wire.Message, decoy bool) {
+		errChan := make(chan error, 1)
+		go func() {
+			if decoy {
+				if _, err := from.writePacket([]byte{1, 2}, true); err != nil {
+					errChan <- err
+					return
+				}
+			}
+			_, err := from.writeMessage(msg, pver)
+			errChan <- err
+		}()
+		_, got, _, err := to.readMessage(pver)
+		if err != nil {
+			t.Fatalf("readMessage: unexpected error: %v", err)
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("writeMessage: unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, msg) {
+			t.Fatalf("readMessage: got %v, want %v", got, msg)
+		}
+	}
+	for i := uint64(0); i < 2*v2RekeyInterval+1; i++ {
+		send(initiator, responder, wire.NewMsgPing(i), i%100 == 0)
+		send(responder, initiator, wire.NewMsgPong(i), false)
+	}
+}
+
+// TestFSChaCha20Poly1305Rekey ensures a sequence of packets which crosses the
+// rekey interval twice is encrypted to the expected ciphertexts and decrypts
+// back to the original packets.
+func TestFSChaCha20Poly1305Rekey(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	want := map[int]string{
+		0:                     "68d9215ac89286e18d1fb0f478b68be36a8c9436f90bf471",
+		v2RekeyInterval - 1:   "9dfd2166aec405b2ee994ded18b34a082a3fd403d0cdc07b4a7b",
+		v2RekeyInterval:       "55c7418601121cc120e09dcc74c1a23f6a5b45a2b449a948d9a9",
+		2*v2RekeyInterval - 1: "17374d7c14fab00feb27ceb1199bd7a2c9c07751783de4e3c157",
+		2 * v2RekeyInterval:   "19a3b4ec104439f3b59284ee3c79a8659017618ecbfab8d67a1a",
+	}
+
+	sender := newFSChaCha20Poly1305(key)
+	receiver := newFSChaCha20Poly1305(key)
+	for i := 0; i <= 2*v2RekeyInterval; i++ {
+		packet := []byte(fmt.Sprintf("packet %d", i))
+		ciphertext := sender.seal(packet)
+		if w, ok := want[i]; ok && hex.EncodeToString(ciphertext) != w {
+			t.Errorf("seal: unexpected ciphertext of packet %d - got "+
+				"%x, want %s", i, ciphertext, w)
+		}
+		plaintext, err := receiver.open(ciphertext)
+		if err != nil {
+			t.Fatalf("open: unexpected error for packet %d: %v", i, err)
+		}
+		if !bytes.Equal(plaintext, packet) {
+			t.Fatalf("open: unexpected plaintext of packet %d - got "+
+				"%q, want %q", i, plaintext, packet)
+		}
+	}
+	if sender.rekeys != 2 || sender.packets != 1 {
+		t.Errorf("unexpected state after %d packets - got %d rekeys and "+
+			"packet %d, want 2 rekeys and packet 1",
+			2*v2RekeyInterval+1, sender.rekeys, sender.packets)
+	}
+}
+
+// TestV2TransportTampering ensures modified packets fail to authenticate.
+func TestV2TransportTampering(t *testing.T) {
+	initiator, responder := v2HandshakePipe(t)
+
+	var buf bytes.Buffer
+	initiator.w = &buf
+	if _, err := initiator.writeMessage(wire.NewMsgPing(1), 0); err != nil {
+		t.Fatalf("writeMessage: unexpected error: %v", err)
+	}
+	packet := buf.Bytes()
+	packet[len(packet)-1] ^= 0x01
+	responder.r = bytes.NewReader(packet)
+	_, _, _, err := responder.readMessage(wire.ProtocolVersion)
+	if err != errV2PacketAuth {
+		t.Fatalf("readMessage: got error %v, want %v", err,
+			errV2PacketAuth)
+	}
+}
+
+// TestV2TransportRejection ensures only handshakes which the remote peer closed
+// before sending its public key are considered rejected.
+func TestV2TransportRejection(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote []byte
+		want   bool
+	}{{
+		name:   "closed without sending anything",
+		remote: nil,
+		want:   true,
+	}, {
+		name:   "closed while sending the public key",
+		remote: bytes.Repeat([]byte{0x01}, 32),
+		want:   true,
+	}, {
+		name:   "closed after sending the public key",
+		remote: bytes.Repeat([]byte{0x01}, 64),
+		want:   false,
+	}}
+
+	for _, test := range tests {
+		r := bytes.NewReader(test.remote)
+		_, _, bytesRead, _, err := v2Handshake(r, ioutil.Discard, true,
+			wire.SimNet)
+		if err == nil {
+			t.Errorf("%s: v2Handshake: unexpected success", test.name)
+			continue
+		}
+		if got := isV2Rejection(err, bytesRead); got != test.want {
+			t.Errorf("%s: unexpected rejection - got %v, want %v "+
+				"(err: %v)", test.name, got, test.want, err)
+		}
+	}
+
+	// Errors other than the remote peer closing the connection are not
+	// rejections.
+	if isV2Rejection(errV2PacketAuth, 0) {
+		t.Error("authentication failure: unexpected rejection")
+	}
+}
+
+// TestV2TransportV1Fallback ensures the responder of a v2 transport handshake
+// detects v1 version messages and returns the bytes it read so the message can
+// be read with the v1 transport.
+func TestV2TransportV1Fallback(t *testing.T) {
+	var buf bytes.Buffer
+	me := wire.NewNetAddressIPPort(net.ParseIP("127.0.0.1"), 8333, 0)
+	msgVersion := wire.NewMsgVersion(me, me, 1, 0)
+	err := wire.WriteMessage(&buf, msgVersion, wire.ProtocolVersion,
+		wire.SimNet)
+	if err != nil {
+		t.Fatalf("WriteMessage: unexpected error: %v", err)
+	}
+
+	tr, v1Bytes, _, _, err := v2Handshake(&buf, ioutil.Discard, false,
+		wire.SimNet)
+	if err != nil {
+		t.Fatalf("v2Handshake: unexpected error: %v", err)
+	}
+	if tr != nil {
+		t.Fatal("v2Handshake: unexpectedly negotiated v2 transport")
+	}
+	r := io.MultiReader(bytes.NewReader(v1Bytes), &buf)
+	msg, _, err := wire.ReadMessage(r, wire.ProtocolVersion, wire.SimNet)
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected error: %v", err)
+	}
+	if _, ok := msg.(*wire.MsgVersion); !ok {
+		t.Fatalf("ReadMessage: got %T, want *wire.MsgVersion", msg)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -37,6 +38,7 @@ import (
 
 	"github.com/HCashOrg/bitset"
 	"github.com/james-ray/hcd/blockchain"
+	"github.com/james-ray/hcd/blockchain/indexers"
 	"github.com/james-ray/hcd/blockchain/stake"
 	"github.com/james-ray/hcd/chaincfg"
 	"github.com/james-ray/hcd/chaincfg/chainec"
@@ -45,6 +47,8 @@ import (
 	"github.com/james-ray/hcd/database"
 	"github.com/james-ray/hcd/hcjson"
 	"github.com/james-ray/hcd/hcutil"
+	"github.com/james-ray/hcd/hcutil/hdkeychain"
+	"github.com/james-ray/hcd/hcutil/psbt"
 	"github.com/james-ray/hcd/mempool"
 	"github.com/james-ray/hcd/mining"
 	"github.com/james-ray/hcd/txscript"
@@ -177,76 +181,85 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 // a dependency loop.
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
-	"addnode":               handleAddNode,
-	"createrawsstx":         handleCreateRawSStx,
-	"createrawssgentx":      handleCreateRawSSGenTx,
-	"createrawssrtx":        handleCreateRawSSRtx,
-	"createrawtransaction":  handleCreateRawTransaction,
-	"debuglevel":            handleDebugLevel,
-	"decoderawtransaction":  handleDecodeRawTransaction,
-	"decodescript":          handleDecodeScript,
-	"estimatefee":           handleEstimateFee,
-	"estimatestakediff":     handleEstimateStakeDiff,
-	"existsaddress":         handleExistsAddress,
-	"existsaddresses":       handleExistsAddresses,
-	"existsmissedtickets":   handleExistsMissedTickets,
-	"existsexpiredtickets":  handleExistsExpiredTickets,
-	"existsliveticket":      handleExistsLiveTicket,
-	"existslivetickets":     handleExistsLiveTickets,
-	"existsmempooltxs":      handleExistsMempoolTxs,
-	"generate":              handleGenerate,
-	"getaddednodeinfo":      handleGetAddedNodeInfo,
-	"getbestblock":          handleGetBestBlock,
-	"getbestblockhash":      handleGetBestBlockHash,
-	"getblock":              handleGetBlock,
-	"getblockcount":         handleGetBlockCount,
-	"getblockhash":          handleGetBlockHash,
-	"getblockheader":        handleGetBlockHeader,
-	"getblocksubsidy":       handleGetBlockSubsidy,
-	"getcoinsupply":         handleGetCoinSupply,
-	"getconnectioncount":    handleGetConnectionCount,
-	"getcurrentnet":         handleGetCurrentNet,
-	"getdifficulty":         handleGetDifficulty,
-	"getgenerate":           handleGetGenerate,
-	"gethashespersec":       handleGetHashesPerSec,
-	"getheaders":            handleGetHeaders,
-	"getinfo":               handleGetInfo,
-	"getblockchaininfo":     handleGetBlockchainInfo,
-	"getmempoolinfo":        handleGetMempoolInfo,
-	"getmininginfo":         handleGetMiningInfo,
-	"getnettotals":          handleGetNetTotals,
-	"getnetworkhashps":      handleGetNetworkHashPS,
-	"getpeerinfo":           handleGetPeerInfo,
-	"getrawmempool":         handleGetRawMempool,
-	"getrawtransaction":     handleGetRawTransaction,
-	"getstakedifficulty":    handleGetStakeDifficulty,
-	"getstakeversioninfo":   handleGetStakeVersionInfo,
-	"getstakeversions":      handleGetStakeVersions,
-	"getticketpoolvalue":    handleGetTicketPoolValue,
-	"getvoteinfo":           handleGetVoteInfo,
-	"gettxout":              handleGetTxOut,
-	"getwork":               handleGetWork,
-	"help":                  handleHelp,
-	"livetickets":           handleLiveTickets,
-	"missedtickets":         handleMissedTickets,
-	"node":                  handleNode,
-	"ping":                  handlePing,
-	"searchrawtransactions": handleSearchRawTransactions,
-	"rebroadcastmissed":     handleRebroadcastMissed,
-	"rebroadcastwinners":    handleRebroadcastWinners,
-	"sendrawtransaction":    handleSendRawTransaction,
-	"setgenerate":           handleSetGenerate,
-	"stop":                  handleStop,
-	"submitblock":           handleSubmitBlock,
-	"ticketfeeinfo":         handleTicketFeeInfo,
-	"ticketsforaddress":     handleTicketsForAddress,
-	"ticketvwap":            handleTicketVWAP,
-	"txfeeinfo":             handleTxFeeInfo,
-	"validateaddress":       handleValidateAddress,
-	"verifychain":           handleVerifyChain,
-	"verifymessage":         handleVerifyMessage,
-	"verifyblissmessage":    handleVerifyBlissMessage,
-	"version":               handleVersion,
+	"addnode":                 handleAddNode,
+	"backupchain":             handleBackupChain,
+	"combinepsbt":             handleCombinePsbt,
+	"createrawsstx":           handleCreateRawSStx,
+	"createrawssgentx":        handleCreateRawSSGenTx,
+	"createrawssrtx":          handleCreateRawSSRtx,
+	"createrawtransaction":    handleCreateRawTransaction,
+	"debuglevel":              handleDebugLevel,
+	"debugscript":             handleDebugScript,
+	"decodepsbt":              handleDecodePsbt,
+	"decoderawtransaction":    handleDecodeRawTransaction,
+	"decodescript":            handleDecodeScript,
+	"estimatefee":             handleEstimateFee,
+	"estimatestakediff":       handleEstimateStakeDiff,
+	"existsaddress":           handleExistsAddress,
+	"existsaddresses":         handleExistsAddresses,
+	"existsmissedtickets":     handleExistsMissedTickets,
+	"existsexpiredtickets":    handleExistsExpiredTickets,
+	"existsliveticket":        handleExistsLiveTicket,
+	"existslivetickets":       handleExistsLiveTickets,
+	"existsmempooltxs":        handleExistsMempoolTxs,
+	"generate":                handleGenerate,
+	"getaddednodeinfo":        handleGetAddedNodeInfo,
+	"getbestblock":            handleGetBestBlock,
+	"getbestblockhash":        handleGetBestBlockHash,
+	"getblock":                handleGetBlock,
+	"getblockcount":           handleGetBlockCount,
+	"getblockhash":            handleGetBlockHash,
+	"getblockheader":          handleGetBlockHeader,
+	"getblocksubsidy":         handleGetBlockSubsidy,
+	"getcheckpointcandidates": handleGetCheckpointCandidates,
+	"getcoinsupply":           handleGetCoinSupply,
+	"getconnectioncount":      handleGetConnectionCount,
+	"getcurrentnet":           handleGetCurrentNet,
+	"getdifficulty":           handleGetDifficulty,
+	"getgenerate":             handleGetGenerate,
+	"gethashespersec":         handleGetHashesPerSec,
+	"getheaders":              handleGetHeaders,
+	"getinfo":                 handleGetInfo,
+	"getblockchaininfo":       handleGetBlockchainInfo,
+	"getmempoolinfo":          handleGetMempoolInfo,
+	"getmininginfo":           handleGetMiningInfo,
+	"getnettotals":            handleGetNetTotals,
+	"getnetworkhashps":        handleGetNetworkHashPS,
+	"getpeerinfo":             handleGetPeerInfo,
+	"getrawmempool":           handleGetRawMempool,
+	"getrawtransaction":       handleGetRawTransaction,
+	"getstakedifficulty":      handleGetStakeDifficulty,
+	"getstakeversioninfo":     handleGetStakeVersionInfo,
+	"getstakeversions":        handleGetStakeVersions,
+	"getticketinfo":           handleGetTicketInfo,
+	"getticketpoolvalue":      handleGetTicketPoolValue,
+	"getticketsbyaddress":     handleGetTicketsByAddress,
+	"getvoteinfo":             handleGetVoteInfo,
+	"gettxout":                handleGetTxOut,
+	"getwork":                 handleGetWork,
+	"help":                    handleHelp,
+	"invalidateblock":         handleInvalidateBlock,
+	"livetickets":             handleLiveTickets,
+	"missedtickets":           handleMissedTickets,
+	"node":                    handleNode,
+	"ping":                    handlePing,
+	"searchrawtransactions":   handleSearchRawTransactions,
+	"rebroadcastmissed":       handleRebroadcastMissed,
+	"rebroadcastwinners":      handleRebroadcastWinners,
+	"reconsiderblock":         handleReconsiderBlock,
+	"sendrawtransaction":      handleSendRawTransaction,
+	"setgenerate":             handleSetGenerate,
+	"stop":                    handleStop,
+	"submitblock":             handleSubmitBlock,
+	"ticketfeeinfo":           handleTicketFeeInfo,
+	"ticketsforaddress":       handleTicketsForAddress,
+	"ticketvwap":              handleTicketVWAP,
+	"txfeeinfo":               handleTxFeeInfo,
+	"validateaddress":         handleValidateAddress,
+	"verifychain":             handleVerifyChain,
+	"verifymessage":           handleVerifyMessage,
+	"verifyblissmessage":      handleVerifyBlissMessage,
+	"version":                 handleVersion,
 }
 
 // list of commands that we recognize, but for which hcd has no support because
@@ -323,7 +336,9 @@ var rpcLimited = map[string]struct{}{
 	"help": {},
 
 	// HTTP/S-only commands
+	"combinepsbt":           {},
 	"createrawtransaction":  {},
+	"decodepsbt":            {},
 	"decoderawtransaction":  {},
 	"decodescript":          {},
 	"getbestblock":          {},
@@ -338,6 +353,8 @@ var rpcLimited = map[string]struct{}{
 	"getnetworkhashps":      {},
 	"getrawmempool":         {},
 	"getrawtransaction":     {},
+	"getticketinfo":         {},
+	"getticketsbyaddress":   {},
 	"gettxout":              {},
 	"searchrawtransactions": {},
 	"sendrawtransaction":    {},
@@ -513,6 +530,51 @@ func handleAddNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 	return nil, nil
 }
 
+// handleBackupChain implements the backupchain command.
+func handleBackupChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*hcjson.BackupChainCmd)
+
+	// Relative paths are relative to the data directory.
+	backupDir := cleanAndExpandPath(c.Dir)
+	if !filepath.IsAbs(backupDir) {
+		backupDir = filepath.Join(cfg.DataDir, backupDir)
+	}
+
+	// The backup is taken from a read transaction, so block processing
+	// continues while the files are copied.
+	var manifest *database.BackupManifest
+	err := s.server.db.View(func(dbTx database.Tx) error {
+		backuper, ok := dbTx.(database.Backuper)
+		if !ok {
+			return fmt.Errorf("the %s database backend does not "+
+				"support online backups", cfg.DbType)
+		}
+
+		var err error
+		manifest, err = backuper.Backup(backupDir)
+		return err
+	})
+	if err != nil {
+		if dbErr, ok := err.(database.Error); ok && dbErr.ErrorCode ==
+			database.ErrDbExists {
+
+			return nil, rpcInvalidError("Backup directory %q already "+
+				"exists", backupDir)
+		}
+		return nil, rpcInternalError(err.Error(), "Backup chain")
+	}
+
+	result := &hcjson.BackupChainResult{
+		Dir:    backupDir,
+		DbType: manifest.DbType,
+		Files:  len(manifest.Files),
+	}
+	for _, file := range manifest.Files {
+		result.Size += file.Size
+	}
+	return result, nil
+}
+
 // handleNode handles node commands.
 func handleNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*hcjson.NodeCmd)
@@ -616,6 +678,35 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleCombinePsbt handles combinepsbt commands.
+func handleCombinePsbt(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*hcjson.CombinePsbtCmd)
+
+	if len(c.Txs) == 0 {
+		return nil, rpcInvalidError("At least one PSBT must be provided")
+	}
+	packets := make([]*psbt.Packet, 0, len(c.Txs))
+	for i, b64 := range c.Txs {
+		packet, err := psbt.NewFromRawBytes(strings.NewReader(b64), true)
+		if err != nil {
+			return nil, rpcDeserializationError("Could not decode "+
+				"PSBT %d: %v", i, err)
+		}
+		packets = append(packets, packet)
+	}
+
+	combined, err := psbt.Combine(packets...)
+	if err != nil {
+		return nil, rpcInvalidError("Could not combine PSBTs: %v", err)
+	}
+	b64, err := combined.B64Encode()
+	if err != nil {
+		return nil, rpcInternalError(err.Error(), "Failed to encode PSBT")
+	}
+
+	return b64, nil
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
 func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*hcjson.CreateRawTransactionCmd)
@@ -1393,6 +1484,125 @@ func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx, txHash str
 	return txReply, nil
 }
 
+// handleDebugScript handles debugscript commands.
+func handleDebugScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*hcjson.DebugScriptCmd)
+
+	// Deserialize the transaction.
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, rpcDeserializationError("Could not decode Tx: %v",
+			err)
+	}
+	if int(c.Index) >= len(mtx.TxIn) {
+		return nil, rpcInvalidError("Input index %d is out of range "+
+			"for a transaction with %d inputs", c.Index,
+			len(mtx.TxIn))
+	}
+
+	// Convert the previous public key script to bytes.
+	hexStr = c.PkScript
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	pkScript, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+
+	// The disassembled strings will contain [error] inline if the scripts
+	// don't fully parse, so ignore the errors here.
+	sigScript := mtx.TxIn[c.Index].SignatureScript
+	reply := hcjson.DebugScriptResult{Steps: []hcjson.DebugScriptStep{}}
+	reply.SigScript, _ = txscript.DisasmString(sigScript)
+	reply.PkScript, _ = txscript.DisasmString(pkScript)
+
+	// Show the redeem script of pay-to-script-hash spends, including stake
+	// tagged ones, which is the last push of the signature script.
+	scriptVersion := *c.ScriptVersion
+	class := txscript.GetScriptClass(scriptVersion, pkScript)
+	if txscript.IsStakeOutput(pkScript) {
+		class, _ = txscript.GetStakeOutSubclass(pkScript)
+	}
+	if class == txscript.ScriptHashTy && len(sigScript) > 0 {
+		redeemScript, err := txscript.ScriptFromScriptSig(sigScript)
+		if err == nil {
+			reply.RedeemScript, _ = txscript.DisasmString(redeemScript)
+		}
+	}
+
+	// Execute the scripts with the same flags used for transactions
+	// accepted to the memory pool while recording every step.
+	flags, err := standardScriptVerifyFlags(s.chain)
+	if err != nil {
+		return nil, rpcInternalError(err.Error(),
+			"Could not determine script flags")
+	}
+	vm, err := txscript.NewEngine(pkScript, &mtx, int(c.Index), flags,
+		scriptVersion, nil)
+	if err != nil {
+		reply.Error = err.Error()
+		return reply, nil
+	}
+	vm.EnableTrace()
+	err = vm.Execute()
+	if err == txscript.ErrTraceTooLarge {
+		return nil, rpcInvalidError("The execution trace exceeds %d "+
+			"steps or %d bytes of stack items", txscript.MaxTraceSteps,
+			txscript.MaxTraceBytes)
+	}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	reply.Valid = err == nil
+
+	hexStack := func(stk [][]byte) []string {
+		strs := make([]string, len(stk))
+		for i, data := range stk {
+			strs[i] = hex.EncodeToString(data)
+		}
+		return strs
+	}
+	for _, step := range vm.Trace() {
+		condStack := make([]string, len(step.CondStack))
+		for i, cond := range step.CondStack {
+			switch cond {
+			case txscript.OpCondTrue:
+				condStack[i] = "true"
+			case txscript.OpCondFalse:
+				condStack[i] = "false"
+			default:
+				condStack[i] = "skip"
+			}
+		}
+		var stepErr string
+		if step.Err != nil {
+			stepErr = step.Err.Error()
+		}
+		reply.Steps = append(reply.Steps, hcjson.DebugScriptStep{
+			Script:    step.ScriptIdx,
+			Offset:    step.ScriptOff,
+			Opcode:    step.Opcode,
+			Executed:  step.Executed,
+			Stack:     hexStack(step.Stack),
+			AltStack:  hexStack(step.AltStack),
+			CondStack: condStack,
+			Error:     stepErr,
+		})
+	}
+
+	return reply, nil
+}
+
 // handleDecodeRawTransaction handles decoderawtransaction commands.
 func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*hcjson.DecodeRawTransactionCmd)
@@ -1414,15 +1624,206 @@ func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan
 	}
 
 	// Create and return the result.
-	txReply := hcjson.TxRawDecodeResult{
+	return createTxRawDecodeResult(&mtx, s.server.chainParams), nil
+}
+
+// createTxRawDecodeResult returns the JSON object for the passed transaction
+// as returned by the decoderawtransaction command.
+func createTxRawDecodeResult(mtx *wire.MsgTx, chainParams *chaincfg.Params) hcjson.TxRawDecodeResult {
+	return hcjson.TxRawDecodeResult{
 		Txid:     mtx.TxHash().String(),
 		Version:  int32(mtx.Version),
 		Locktime: mtx.LockTime,
 		Expiry:   mtx.Expiry,
-		Vin:      createVinList(&mtx),
-		Vout:     createVoutList(&mtx, s.server.chainParams, nil),
+		Vin:      createVinList(mtx),
+		Vout:     createVoutList(mtx, chainParams, nil),
+	}
+}
+
+// psbtSigHashString returns the name of the passed signature hash type as
+// shown by the decodepsbt command.
+func psbtSigHashString(hashType txscript.SigHashType) string {
+	var str string
+	switch hashType &^ txscript.SigHashAnyOneCanPay {
+	case txscript.SigHashAll:
+		str = "ALL"
+	case txscript.SigHashNone:
+		str = "NONE"
+	case txscript.SigHashSingle:
+		str = "SINGLE"
+	case txscript.SigHashAllValue:
+		str = "ALLVALUE"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", hashType)
 	}
-	return txReply, nil
+	if hashType&txscript.SigHashAnyOneCanPay != 0 {
+		str += "|ANYONECANPAY"
+	}
+	return str
+}
+
+// psbtSigTypeString returns the name of the signature type of a partial
+// signature as shown by the decodepsbt command.
+func psbtSigTypeString(sigType uint8) string {
+	switch sigType {
+	case psbt.SigTypeSecp256k1:
+		return "secp256k1"
+	case psbt.SigTypeEdwards:
+		return "edwards"
+	case psbt.SigTypeSecSchnorr:
+		return "schnorr"
+	case psbt.SigTypeBliss:
+		return "bliss"
+	}
+	return fmt.Sprintf("unknown(%d)", sigType)
+}
+
+// createPsbtScriptResult returns the JSON object for a script of a partially
+// signed transaction, or nil when there is no script.
+func createPsbtScriptResult(script []byte) *hcjson.PsbtScriptResult {
+	if script == nil {
+		return nil
+	}
+
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(script)
+	class := txscript.GetScriptClass(txscript.DefaultScriptVersion, script)
+	return &hcjson.PsbtScriptResult{
+		Asm:  disbuf,
+		Hex:  hex.EncodeToString(script),
+		Type: class.String(),
+	}
+}
+
+// createPsbtBip32Derivs returns the JSON objects for the key derivations of an
+// input or output of a partially signed transaction.
+func createPsbtBip32Derivs(derivs []*psbt.Bip32Derivation) []hcjson.PsbtBip32DerivResult {
+	if len(derivs) == 0 {
+		return nil
+	}
+
+	results := make([]hcjson.PsbtBip32DerivResult, 0, len(derivs))
+	for _, d := range derivs {
+		var fingerprint [4]byte
+		binary.LittleEndian.PutUint32(fingerprint[:], d.MasterKeyFingerprint)
+		path := "m"
+		for _, index := range d.Bip32Path {
+			if index >= hdkeychain.HardenedKeyStart {
+				path += fmt.Sprintf("/%d'", index-hdkeychain.HardenedKeyStart)
+				continue
+			}
+			path += fmt.Sprintf("/%d", index)
+		}
+		results = append(results, hcjson.PsbtBip32DerivResult{
+			PubKey:            hex.EncodeToString(d.PubKey),
+			MasterFingerprint: hex.EncodeToString(fingerprint[:]),
+			Path:              path,
+		})
+	}
+	return results
+}
+
+// createPsbtUnknowns returns the hex encoded unknown key-value pairs of a
+// partially signed transaction.
+func createPsbtUnknowns(unknowns []*psbt.Unknown) map[string]string {
+	result := make(map[string]string, len(unknowns))
+	for _, u := range unknowns {
+		result[hex.EncodeToString(u.Key)] = hex.EncodeToString(u.Value)
+	}
+	return result
+}
+
+// handleDecodePsbt handles decodepsbt commands.
+func handleDecodePsbt(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*hcjson.DecodePsbtCmd)
+
+	packet, err := psbt.NewFromRawBytes(strings.NewReader(c.Psbt), true)
+	if err != nil {
+		return nil, rpcDeserializationError("Could not decode PSBT: %v",
+			err)
+	}
+
+	chainParams := s.server.chainParams
+	reply := hcjson.DecodePsbtResult{
+		Tx:       createTxRawDecodeResult(packet.UnsignedTx, chainParams),
+		Unknown:  createPsbtUnknowns(packet.Unknowns),
+		Inputs:   make([]hcjson.PsbtInputResult, len(packet.Inputs)),
+		Outputs:  make([]hcjson.PsbtOutputResult, len(packet.Outputs)),
+		Complete: packet.IsComplete(),
+	}
+	if fee, err := packet.GetTxFee(); err == nil {
+		feeCoin := hcutil.Amount(fee).ToCoin()
+		reply.Fee = &feeCoin
+	}
+
+	for i := range packet.Inputs {
+		pInput := &packet.Inputs[i]
+		input := &reply.Inputs[i]
+		if pInput.Utxo != nil {
+			utxo := createTxRawDecodeResult(pInput.Utxo, chainParams)
+			input.Utxo = &utxo
+		}
+		if pInput.PrevOut != nil {
+			// Ignore the error here since an error means the
+			// script couldn't parse and there is no additional
+			// information about it anyways.
+			prevOut := pInput.PrevOut
+			disbuf, _ := txscript.DisasmString(prevOut.PkScript)
+			class, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
+				prevOut.Version, prevOut.PkScript, chainParams)
+			addresses := make([]string, len(addrs))
+			for j, addr := range addrs {
+				addresses[j] = addr.EncodeAddress()
+			}
+			input.PrevOut = &hcjson.PsbtPrevOutResult{
+				Amount:  hcutil.Amount(prevOut.Value).ToCoin(),
+				Version: prevOut.Version,
+				ScriptPubKey: hcjson.ScriptPubKeyResult{
+					Asm:       disbuf,
+					Hex:       hex.EncodeToString(prevOut.PkScript),
+					ReqSigs:   int32(reqSigs),
+					Type:      class.String(),
+					Addresses: addresses,
+				},
+			}
+		}
+		for _, ps := range pInput.PartialSigs {
+			input.PartialSignatures = append(input.PartialSignatures,
+				hcjson.PsbtPartialSigResult{
+					PubKey:    hex.EncodeToString(ps.PubKey),
+					SigType:   psbtSigTypeString(ps.SigType),
+					Signature: hex.EncodeToString(ps.Signature),
+				})
+		}
+		if pInput.SighashType != 0 {
+			input.SigHash = psbtSigHashString(pInput.SighashType)
+		}
+		input.RedeemScript = createPsbtScriptResult(pInput.RedeemScript)
+		input.Bip32Derivs = createPsbtBip32Derivs(pInput.Bip32Derivation)
+		if pInput.FinalScriptSig != nil {
+			disbuf, _ := txscript.DisasmString(pInput.FinalScriptSig)
+			input.FinalScriptSig = &hcjson.ScriptSig{
+				Asm: disbuf,
+				Hex: hex.EncodeToString(pInput.FinalScriptSig),
+			}
+		}
+		if len(pInput.Unknowns) != 0 {
+			input.Unknown = createPsbtUnknowns(pInput.Unknowns)
+		}
+	}
+
+	for i := range packet.Outputs {
+		pOutput := &packet.Outputs[i]
+		output := &reply.Outputs[i]
+		output.RedeemScript = createPsbtScriptResult(pOutput.RedeemScript)
+		output.Bip32Derivs = createPsbtBip32Derivs(pOutput.Bip32Derivation)
+		if len(pOutput.Unknowns) != 0 {
+			output.Unknown = createPsbtUnknowns(pOutput.Unknowns)
+		}
+	}
+
+	return reply, nil
 }
 
 // handleDecodeScript handles decodescript commands.
@@ -2944,7 +3345,7 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *hcjson.TemplateRequest
 	// way to relay a found block or receive transactions to work on.
 	// However, allow this state when running in the regression test or
 	// simulation test mode.
-	if !cfg.SimNet && s.server.ConnectedCount() == 0 {
+	if !cfg.SimNet && !cfg.RegNet && s.server.ConnectedCount() == 0 {
 		return nil, &hcjson.RPCError{
 			Code:    hcjson.ErrRPCClientNotConnected,
 			Message: "Hcd is not connected",
@@ -3167,6 +3568,34 @@ func handleGetBlockTemplate(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return nil, rpcInvalidError("Invalid mode: %v", mode)
 }
 
+// handleGetCheckpointCandidates implements the getcheckpointcandidates command.
+func handleGetCheckpointCandidates(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*hcjson.GetCheckpointCandidatesCmd)
+	count := 5
+	if c.Count != nil {
+		count = *c.Count
+	}
+	if count < 1 || count > 20 {
+		return nil, rpcInvalidError("Count must be in the range 1-20, "+
+			"got %d", count)
+	}
+
+	candidates, err := s.chain.CheckpointCandidates(count)
+	if err != nil {
+		return nil, rpcInternalError(err.Error(),
+			"Could not find checkpoint candidates")
+	}
+
+	result := make([]hcjson.CheckpointCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		result = append(result, hcjson.CheckpointCandidate{
+			Height: candidate.Height,
+			Hash:   candidate.Hash.String(),
+		})
+	}
+	return result, nil
+}
+
 // handleGetCoinSupply implements the getcoinsupply command.
 func handleGetCoinSupply(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return s.chain.TotalSubsidy(), nil
@@ -3477,6 +3906,8 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			CurrentHeight:  statsSnap.LastBlock,
 			BanScore:       int32(p.banScore.Int()),
 			SyncNode:       p == syncPeer,
+			Transport:      statsSnap.Transport,
+			SessionID:      statsSnap.SessionID,
 		}
 		if p.LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
@@ -3919,6 +4350,110 @@ func handleGetTicketPoolValue(s *rpcServer, cmd interface{}, closeChan <-chan st
 	return amt.ToCoin(), nil
 }
 
+// ticketInfoResult converts the passed ticket lifecycle from the ticket index
+// to its RPC representation.  Live tickets which have not reached maturity as
+// of the passed best height are reported as immature.
+func ticketInfoResult(info *indexers.TicketInfo, bestHeight int64) *hcjson.GetTicketInfoResult {
+	status := info.Status.String()
+	if info.Status == indexers.TicketLive &&
+		bestHeight < int64(info.MaturityHeight) {
+		status = "immature"
+	}
+
+	result := &hcjson.GetTicketInfoResult{
+		Hash:           info.Hash.String(),
+		Status:         status,
+		Price:          hcutil.Amount(info.Price).ToCoin(),
+		PurchaseBlock:  info.PurchaseBlock.String(),
+		PurchaseHeight: int64(info.PurchaseHeight),
+		MaturityHeight: int64(info.MaturityHeight),
+		ExpiryHeight:   int64(info.ExpiryHeight),
+	}
+	switch info.Status {
+	case indexers.TicketMissed, indexers.TicketExpired,
+		indexers.TicketRevoked:
+		result.MissedBlock = info.MissedBlock.String()
+		result.MissedHeight = int64(info.MissedHeight)
+	}
+	switch info.Status {
+	case indexers.TicketVoted, indexers.TicketRevoked:
+		result.SpendBlock = info.SpendBlock.String()
+		result.SpendHeight = int64(info.SpendHeight)
+		result.SpendTx = info.SpendTx.String()
+		result.Reward = hcutil.Amount(info.Reward).ToCoin()
+	}
+	return result
+}
+
+// handleGetTicketInfo implements the getticketinfo command.
+func handleGetTicketInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	ticketIndex := s.server.ticketIndex
+	if ticketIndex == nil {
+		return nil, rpcInternalError("Ticket index disabled",
+			"Configuration")
+	}
+
+	c := cmd.(*hcjson.GetTicketInfoCmd)
+	hash, err := chainhash.NewHashFromStr(c.TxHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxHash)
+	}
+
+	info, err := ticketIndex.TicketInfo(hash)
+	if err != nil {
+		return nil, rpcInternalError(err.Error(),
+			"Could not query ticket index")
+	}
+	if info == nil {
+		return nil, rpcNoTxInfoError(hash)
+	}
+
+	return ticketInfoResult(info, s.chain.BestSnapshot().Height), nil
+}
+
+// handleGetTicketsByAddress implements the getticketsbyaddress command.
+func handleGetTicketsByAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	ticketIndex := s.server.ticketIndex
+	if ticketIndex == nil {
+		return nil, rpcInternalError("Ticket index disabled",
+			"Configuration")
+	}
+
+	c := cmd.(*hcjson.GetTicketsByAddressCmd)
+	addr, err := hcutil.DecodeAddress(c.Address)
+	if err != nil {
+		return nil, rpcAddressKeyError("Could not decode address: %v",
+			err)
+	}
+
+	bestHeight := s.chain.BestSnapshot().Height
+	var startHeight uint32
+	if c.StartHeight != nil {
+		startHeight = *c.StartHeight
+	}
+	endHeight := uint32(bestHeight)
+	if c.EndHeight != nil {
+		endHeight = *c.EndHeight
+	}
+	if startHeight > endHeight {
+		return nil, rpcInvalidError("Start height %d is greater than "+
+			"end height %d", startHeight, endHeight)
+	}
+
+	tickets, err := ticketIndex.TicketsForAddress(addr, startHeight,
+		endHeight)
+	if err != nil {
+		return nil, rpcInternalError(err.Error(),
+			"Could not query ticket index")
+	}
+
+	results := make([]*hcjson.GetTicketInfoResult, 0, len(tickets))
+	for _, info := range tickets {
+		results = append(results, ticketInfoResult(info, bestHeight))
+	}
+	return results, nil
+}
+
 // handleGetVoteInfo implements the getvoteinfo command.
 func handleGetVoteInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c, ok := cmd.(*hcjson.GetVoteInfoCmd)
@@ -4460,7 +4995,7 @@ func handleGetWork(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 	// way to relay a found block or receive transactions to work on.
 	// However, allow this state when running in the regression test or
 	// simulation test mode.
-	if !cfg.SimNet && s.server.ConnectedCount() == 0 {
+	if !cfg.SimNet && !cfg.RegNet && s.server.ConnectedCount() == 0 {
 		return nil, &hcjson.RPCError{
 			Code:    hcjson.ErrRPCClientNotConnected,
 			Message: "Hcd is not connected",
@@ -4530,6 +5065,22 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// handleInvalidateBlock implements the invalidateblock command.
+func handleInvalidateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*hcjson.InvalidateBlockCmd)
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+
+	err = s.server.blockManager.InvalidateBlock(*hash)
+	if err != nil {
+		return nil, rpcInternalError(err.Error(), "Could not invalidate block")
+	}
+
+	return nil, nil
+}
+
 // handleLiveTickets implements the livetickets command.
 func handleLiveTickets(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	lt, err := s.server.blockManager.chain.LiveTickets()
@@ -4632,6 +5183,22 @@ func handleRebroadcastWinners(s *rpcServer, cmd interface{}, closeChan <-chan st
 	return nil, nil
 }
 
+// handleReconsiderBlock implements the reconsiderblock command.
+func handleReconsiderBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*hcjson.ReconsiderBlockCmd)
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+
+	err = s.server.blockManager.ReconsiderBlock(*hash)
+	if err != nil {
+		return nil, rpcInternalError(err.Error(), "Could not reconsider block")
+	}
+
+	return nil, nil
+}
+
 // retrievedTx represents a transaction that was either loaded from the
 // transaction memory pool or from the database.  When a transaction is loaded
 // from the database, it is loaded with the raw serialized bytes while the
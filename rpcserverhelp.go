@@ -35,6 +35,17 @@ var helpDescsEnUS = map[string]string{
 	"addnode-addr":      "IP address and port of the peer to operate on",
 	"addnode-subcmd":    "'add' to add a persistent peer, 'remove' to remove a persistent peer, or 'onetry' to try a single connection to a peer",
 
+	// BackupChainCmd help.
+	"backupchain--synopsis": "Writes a consistent copy of the chain database to a new directory while block processing continues.\n" +
+		"The directory contains a manifest with the size and SHA-256 hash of every file and can be restored with the dbtool restore command.",
+	"backupchain-dir": "The directory to write the backup to, which must not exist yet (relative paths are relative to the data directory)",
+
+	// BackupChainResult help.
+	"backupchainresult-dir":    "The absolute path of the backup directory",
+	"backupchainresult-dbtype": "The database backend of the backup",
+	"backupchainresult-files":  "The number of files in the backup",
+	"backupchainresult-size":   "The total size of the files in the backup in bytes",
+
 	// NodeCmd help.
 	"node--synopsis":     "Attempts to add or remove a peer.",
 	"node-subcmd":        "'disconnect' to remove all matching non-persistent peers, 'remove' to remove a persistent peer, or 'connect' to connect to a peer",
@@ -147,6 +158,93 @@ var helpDescsEnUS = map[string]string{
 	"txrawdecoderesult-vout":     "The transaction outputs as JSON objects",
 	"txrawdecoderesult-expiry":   "The transaction expiry",
 
+	// DebugScriptCmd help.
+	"debugscript--synopsis": "Executes the scripts which spend an input of the provided transaction and returns every step of the execution.\n" +
+		"The scripts are executed with the standard verification flags used for transactions accepted to the memory pool.",
+	"debugscript-hextx":         "Serialized, hex-encoded transaction",
+	"debugscript-index":         "The index of the input to execute",
+	"debugscript-pkscript":      "Hex-encoded public key script of the output spent by the input",
+	"debugscript-scriptversion": "The script version of the output spent by the input",
+
+	// DebugScriptStep help.
+	"debugscriptstep-script":    "The script of the opcode (0 for the signature script, 1 for the public key script and 2 for the redeem script)",
+	"debugscriptstep-offset":    "The offset of the opcode in the script",
+	"debugscriptstep-opcode":    "Disassembly of the opcode",
+	"debugscriptstep-executed":  "Whether the opcode was executed or skipped by an inactive conditional branch",
+	"debugscriptstep-stack":     "The hex-encoded data stack after the step with the top item last",
+	"debugscriptstep-altstack":  "The hex-encoded alt stack after the step with the top item last",
+	"debugscriptstep-condstack": "The condition stack after the step (true, false or skip for each level)",
+	"debugscriptstep-error":     "The error of the step, if it failed",
+
+	// DebugScriptResult help.
+	"debugscriptresult-valid":        "Whether the scripts executed successfully",
+	"debugscriptresult-error":        "The error which caused the execution to fail, if any",
+	"debugscriptresult-sigscript":    "Disassembly of the signature script",
+	"debugscriptresult-pkscript":     "Disassembly of the public key script",
+	"debugscriptresult-redeemscript": "Disassembly of the redeem script of a pay-to-script-hash spend",
+	"debugscriptresult-steps":        "The steps of the execution",
+
+	// CombinePsbtCmd help.
+	"combinepsbt--synopsis": "Combines multiple partially signed transactions for the same transaction into one, merging their signatures and other input and output data.",
+	"combinepsbt-txs":       "The base64-encoded partially signed transactions to combine",
+	"combinepsbt--result0":  "The combined partially signed transaction encoded with base64",
+
+	// PsbtScriptResult help.
+	"psbtscriptresult-asm":  "Disassembly of the script",
+	"psbtscriptresult-hex":  "Hex-encoded bytes of the script",
+	"psbtscriptresult-type": "The type of the script (e.g. 'multisig')",
+
+	// PsbtPrevOutResult help.
+	"psbtprevoutresult-amount":       "The amount of the previous output in HC",
+	"psbtprevoutresult-version":      "The script version of the previous output",
+	"psbtprevoutresult-scriptPubKey": "The public key script of the previous output",
+
+	// PsbtPartialSigResult help.
+	"psbtpartialsigresult-pubkey":    "The hex-encoded public key",
+	"psbtpartialsigresult-sigtype":   "The signature scheme (secp256k1, edwards, schnorr or bliss)",
+	"psbtpartialsigresult-signature": "The hex-encoded signature including the trailing hash type byte",
+
+	// PsbtBip32DerivResult help.
+	"psbtbip32derivresult-pubkey":             "The hex-encoded public key",
+	"psbtbip32derivresult-master_fingerprint": "The fingerprint of the master key",
+	"psbtbip32derivresult-path":               "The derivation path of the key",
+
+	// PsbtInputResult help.
+	"psbtinputresult-utxo":               "The decoded transaction containing the spent output, if provided",
+	"psbtinputresult-prevout":            "The spent output, if provided",
+	"psbtinputresult-partial_signatures": "The partial signatures gathered for the input",
+	"psbtinputresult-sighash":            "The signature hash type signers must use, if any",
+	"psbtinputresult-redeem_script":      "The redeem script of a pay-to-script-hash input",
+	"psbtinputresult-bip32_derivs":       "The derivations of the keys which can sign the input",
+	"psbtinputresult-final_scriptSig":    "The final signature script of a finalized input",
+	"psbtinputresult-unknown":            "The unknown key-value pairs of the input",
+	"psbtinputresult-unknown--key":       "key",
+	"psbtinputresult-unknown--value":     "value",
+	"psbtinputresult-unknown--desc":      "The hex-encoded key as the key and the hex-encoded value as the value",
+
+	// PsbtOutputResult help.
+	"psbtoutputresult-redeem_script":  "The redeem script of a pay-to-script-hash output",
+	"psbtoutputresult-bip32_derivs":   "The derivations of the keys the output pays to",
+	"psbtoutputresult-unknown":        "The unknown key-value pairs of the output",
+	"psbtoutputresult-unknown--key":   "key",
+	"psbtoutputresult-unknown--value": "value",
+	"psbtoutputresult-unknown--desc":  "The hex-encoded key as the key and the hex-encoded value as the value",
+
+	// DecodePsbtResult help.
+	"decodepsbtresult-tx":             "The decoded unsigned transaction",
+	"decodepsbtresult-unknown":        "The unknown global key-value pairs",
+	"decodepsbtresult-unknown--key":   "key",
+	"decodepsbtresult-unknown--value": "value",
+	"decodepsbtresult-unknown--desc":  "The hex-encoded key as the key and the hex-encoded value as the value",
+	"decodepsbtresult-inputs":         "The data attached to each input",
+	"decodepsbtresult-outputs":        "The data attached to each output",
+	"decodepsbtresult-fee":            "The transaction fee in HC, if the amounts of all inputs are known",
+	"decodepsbtresult-complete":       "Whether all inputs are finalized",
+
+	// DecodePsbtCmd help.
+	"decodepsbt--synopsis": "Returns a JSON object representing the provided base64-encoded partially signed transaction.",
+	"decodepsbt-psbt":      "The base64-encoded partially signed transaction",
+
 	// DecodeRawTransactionCmd help.
 	"decoderawtransaction--synopsis": "Returns a JSON object representing the provided serialized, hex-encoded transaction.",
 	"decoderawtransaction-hextx":     "Serialized, hex-encoded transaction",
@@ -583,6 +681,8 @@ var helpDescsEnUS = map[string]string{
 	"getpeerinforesult-currentheight":  "The current height of the peer",
 	"getpeerinforesult-banscore":       "The ban score",
 	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-transport":      "The transport used with the peer (v1 or the encrypted v2)",
+	"getpeerinforesult-sessionid":      "The session id of the v2 transport which both peers can compare to detect a man in the middle",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
@@ -612,6 +712,31 @@ var helpDescsEnUS = map[string]string{
 	"getrawtransaction--condition1": "verbose=true",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
 
+	// GetTicketInfoCmd help.
+	"getticketinfo--synopsis": "Returns the lifecycle of a ticket from the ticket index (requires --ticketindex).",
+	"getticketinfo-txhash":    "The hash of the ticket purchase transaction",
+
+	// GetTicketInfoResult help.
+	"getticketinforesult-hash":           "The hash of the ticket purchase transaction",
+	"getticketinforesult-status":         "The ticket status (immature, live, voted, missed, expired or revoked)",
+	"getticketinforesult-price":          "The amount locked by the ticket in HC",
+	"getticketinforesult-purchaseblock":  "The hash of the block which mined the ticket purchase",
+	"getticketinforesult-purchaseheight": "The height of the block which mined the ticket purchase",
+	"getticketinforesult-maturityheight": "The height at which the ticket becomes live",
+	"getticketinforesult-expiryheight":   "The height at which the ticket expires unless it is called to vote before",
+	"getticketinforesult-missedblock":    "The hash of the block which missed or expired the ticket",
+	"getticketinforesult-missedheight":   "The height of the block which missed or expired the ticket",
+	"getticketinforesult-spendblock":     "The hash of the block which included the vote or revocation",
+	"getticketinforesult-spendheight":    "The height of the block which included the vote or revocation",
+	"getticketinforesult-spendtx":        "The hash of the vote (SSGen) or revocation (SSRtx) transaction",
+	"getticketinforesult-reward":         "The amount paid out by the vote or revocation minus the ticket price in HC",
+
+	// GetTicketsByAddressCmd help.
+	"getticketsbyaddress--synopsis":   "Returns the lifecycles of the tickets which commit to an address and were purchased in a range of heights (requires --ticketindex).",
+	"getticketsbyaddress-address":     "The commitment address of the tickets",
+	"getticketsbyaddress-startheight": "The lowest purchase height of the returned tickets",
+	"getticketsbyaddress-endheight":   "The highest purchase height of the returned tickets (default: the current height)",
+
 	// GetTicketPoolValue help.
 	"getticketpoolvalue--synopsis": "Return the current value of all locked funds in the ticket pool",
 	"getticketpoolvalue--result0":  "Total value of ticket pool",
@@ -651,6 +776,11 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// InvalidateBlockCmd help.
+	"invalidateblock--synopsis": "Permanently marks a block as invalid, as if it violated a consensus rule.\n" +
+		"When the block is in the main chain, the chain is reorganized to the best chain which does not include it.",
+	"invalidateblock-blockhash": "The hash of the block to mark as invalid",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
@@ -661,6 +791,11 @@ var helpDescsEnUS = map[string]string{
 	// RebroadcastWinnerCmd help.
 	"rebroadcastwinners--synopsis": "Asks the daemon to rebroadcast the winners of the voting lottery.\n",
 
+	// ReconsiderBlockCmd help.
+	"reconsiderblock--synopsis": "Removes the invalid mark set by invalidateblock from a block and its invalidated ancestors and descendants.\n" +
+		"The chain is reorganized to the best chain when it changed as a result.",
+	"reconsiderblock-blockhash": "The hash of the block to reconsider",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -797,6 +932,15 @@ var helpDescsEnUS = map[string]string{
 	"estimatestakediffresult-expected": "Expected estimate for stake difficulty",
 	"estimatestakediffresult-user":     "Estimate for stake difficulty with the passed user amount of tickets",
 
+	// GetCheckpointCandidates help.
+	"getcheckpointcandidates--synopsis": "Returns the blocks of the main chain which are suitable as checkpoints ordered from newest to oldest.\n" +
+		"A candidate has enough confirmations, a timestamp ordered with its neighbors, only standard transactions, was finalized by the votes of the next block and has no pending side chain competing with it.",
+	"getcheckpointcandidates-count": "The maximum number of candidates to return (1-20)",
+
+	// CheckpointCandidate help.
+	"checkpointcandidate-height": "The height of the block",
+	"checkpointcandidate-hash":   "The hash of the block",
+
 	// GetCoinSupply help
 	"getcoinsupply--synopsis": "Returns current total coin supply in atoms",
 	"getcoinsupply--result0":  "Current coin supply in atoms",
@@ -891,77 +1035,86 @@ var helpDescsEnUS = map[string]string{
 // This information is used to generate the help.  Each result type must be a
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
-	"addnode":               nil,
-	"createrawsstx":         {(*string)(nil)},
-	"createrawssgentx":      {(*string)(nil)},
-	"createrawssrtx":        {(*string)(nil)},
-	"createrawtransaction":  {(*string)(nil)},
-	"debuglevel":            {(*string)(nil), (*string)(nil)},
-	"decoderawtransaction":  {(*hcjson.TxRawDecodeResult)(nil)},
-	"decodescript":          {(*hcjson.DecodeScriptResult)(nil)},
-	"estimatefee":           {(*float64)(nil)},
-	"estimatestakediff":     {(*hcjson.EstimateStakeDiffResult)(nil)},
-	"existsaddress":         {(*bool)(nil)},
-	"existsaddresses":       {(*string)(nil)},
-	"existsmissedtickets":   {(*string)(nil)},
-	"existsexpiredtickets":  {(*string)(nil)},
-	"existsliveticket":      {(*bool)(nil)},
-	"existslivetickets":     {(*string)(nil)},
-	"existsmempooltxs":      {(*string)(nil)},
-	"getaddednodeinfo":      {(*[]string)(nil), (*[]hcjson.GetAddedNodeInfoResult)(nil)},
-	"getbestblock":          {(*hcjson.GetBestBlockResult)(nil)},
-	"generate":              {(*[]string)(nil)},
-	"getbestblockhash":      {(*string)(nil)},
-	"getblock":              {(*string)(nil), (*hcjson.GetBlockVerboseResult)(nil)},
-	"getblockcount":         {(*int64)(nil)},
-	"getblockhash":          {(*string)(nil)},
-	"getblockheader":        {(*string)(nil), (*hcjson.GetBlockHeaderVerboseResult)(nil)},
-	"getblocksubsidy":       {(*hcjson.GetBlockSubsidyResult)(nil)},
-	"getblocktemplate":      {(*hcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
-	"getconnectioncount":    {(*int32)(nil)},
-	"getcurrentnet":         {(*uint32)(nil)},
-	"getdifficulty":         {(*float64)(nil)},
-	"getstakedifficulty":    {(*hcjson.GetStakeDifficultyResult)(nil)},
-	"getstakeversioninfo":   {(*hcjson.GetStakeVersionInfoResult)(nil)},
-	"getblockchaininfo":     {(*hcjson.GetBlockChainInfoResult)(nil)},
-	"getstakeversions":      {(*hcjson.GetStakeVersionsResult)(nil)},
-	"getgenerate":           {(*bool)(nil)},
-	"gethashespersec":       {(*float64)(nil)},
-	"getheaders":            {(*hcjson.GetHeadersResult)(nil)},
-	"getinfo":               {(*hcjson.InfoChainResult)(nil)},
-	"getmempoolinfo":        {(*hcjson.GetMempoolInfoResult)(nil)},
-	"getmininginfo":         {(*hcjson.GetMiningInfoResult)(nil)},
-	"getnettotals":          {(*hcjson.GetNetTotalsResult)(nil)},
-	"getnetworkhashps":      {(*int64)(nil)},
-	"getpeerinfo":           {(*[]hcjson.GetPeerInfoResult)(nil)},
-	"getrawmempool":         {(*[]string)(nil), (*hcjson.GetRawMempoolVerboseResult)(nil)},
-	"getrawtransaction":     {(*string)(nil), (*hcjson.TxRawResult)(nil)},
-	"getticketpoolvalue":    {(*float64)(nil)},
-	"gettxout":              {(*hcjson.GetTxOutResult)(nil)},
-	"getvoteinfo":           {(*hcjson.GetVoteInfoResult)(nil)},
-	"getwork":               {(*hcjson.GetWorkResult)(nil), (*bool)(nil)},
-	"getcoinsupply":         {(*int64)(nil)},
-	"help":                  {(*string)(nil), (*string)(nil)},
-	"livetickets":           {(*hcjson.LiveTicketsResult)(nil)},
-	"missedtickets":         {(*hcjson.MissedTicketsResult)(nil)},
-	"node":                  nil,
-	"ping":                  nil,
-	"rebroadcastmissed":     nil,
-	"rebroadcastwinners":    nil,
-	"searchrawtransactions": {(*string)(nil), (*[]hcjson.SearchRawTransactionsResult)(nil)},
-	"sendrawtransaction":    {(*string)(nil)},
-	"setgenerate":           nil,
-	"stop":                  {(*string)(nil)},
-	"submitblock":           {nil, (*string)(nil)},
-	"ticketfeeinfo":         {(*hcjson.TicketFeeInfoResult)(nil)},
-	"ticketsforaddress":     {(*hcjson.TicketsForAddressResult)(nil)},
-	"ticketvwap":            {(*float64)(nil)},
-	"txfeeinfo":             {(*hcjson.TxFeeInfoResult)(nil)},
-	"validateaddress":       {(*hcjson.ValidateAddressChainResult)(nil)},
-	"verifychain":           {(*bool)(nil)},
-	"verifymessage":         {(*bool)(nil)},
-	"verifyblissmessage":    {(*bool)(nil)},
-	"version":               {(*map[string]hcjson.VersionResult)(nil)},
+	"addnode":                 nil,
+	"backupchain":             {(*hcjson.BackupChainResult)(nil)},
+	"combinepsbt":             {(*string)(nil)},
+	"createrawsstx":           {(*string)(nil)},
+	"createrawssgentx":        {(*string)(nil)},
+	"createrawssrtx":          {(*string)(nil)},
+	"createrawtransaction":    {(*string)(nil)},
+	"debuglevel":              {(*string)(nil), (*string)(nil)},
+	"debugscript":             {(*hcjson.DebugScriptResult)(nil)},
+	"decodepsbt":              {(*hcjson.DecodePsbtResult)(nil)},
+	"decoderawtransaction":    {(*hcjson.TxRawDecodeResult)(nil)},
+	"decodescript":            {(*hcjson.DecodeScriptResult)(nil)},
+	"estimatefee":             {(*float64)(nil)},
+	"estimatestakediff":       {(*hcjson.EstimateStakeDiffResult)(nil)},
+	"existsaddress":           {(*bool)(nil)},
+	"existsaddresses":         {(*string)(nil)},
+	"existsmissedtickets":     {(*string)(nil)},
+	"existsexpiredtickets":    {(*string)(nil)},
+	"existsliveticket":        {(*bool)(nil)},
+	"existslivetickets":       {(*string)(nil)},
+	"existsmempooltxs":        {(*string)(nil)},
+	"getaddednodeinfo":        {(*[]string)(nil), (*[]hcjson.GetAddedNodeInfoResult)(nil)},
+	"getbestblock":            {(*hcjson.GetBestBlockResult)(nil)},
+	"generate":                {(*[]string)(nil)},
+	"getbestblockhash":        {(*string)(nil)},
+	"getblock":                {(*string)(nil), (*hcjson.GetBlockVerboseResult)(nil)},
+	"getblockcount":           {(*int64)(nil)},
+	"getblockhash":            {(*string)(nil)},
+	"getblockheader":          {(*string)(nil), (*hcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblocksubsidy":         {(*hcjson.GetBlockSubsidyResult)(nil)},
+	"getblocktemplate":        {(*hcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
+	"getconnectioncount":      {(*int32)(nil)},
+	"getcurrentnet":           {(*uint32)(nil)},
+	"getdifficulty":           {(*float64)(nil)},
+	"getstakedifficulty":      {(*hcjson.GetStakeDifficultyResult)(nil)},
+	"getstakeversioninfo":     {(*hcjson.GetStakeVersionInfoResult)(nil)},
+	"getblockchaininfo":       {(*hcjson.GetBlockChainInfoResult)(nil)},
+	"getstakeversions":        {(*hcjson.GetStakeVersionsResult)(nil)},
+	"getgenerate":             {(*bool)(nil)},
+	"gethashespersec":         {(*float64)(nil)},
+	"getheaders":              {(*hcjson.GetHeadersResult)(nil)},
+	"getinfo":                 {(*hcjson.InfoChainResult)(nil)},
+	"getmempoolinfo":          {(*hcjson.GetMempoolInfoResult)(nil)},
+	"getmininginfo":           {(*hcjson.GetMiningInfoResult)(nil)},
+	"getnettotals":            {(*hcjson.GetNetTotalsResult)(nil)},
+	"getnetworkhashps":        {(*int64)(nil)},
+	"getpeerinfo":             {(*[]hcjson.GetPeerInfoResult)(nil)},
+	"getrawmempool":           {(*[]string)(nil), (*hcjson.GetRawMempoolVerboseResult)(nil)},
+	"getrawtransaction":       {(*string)(nil), (*hcjson.TxRawResult)(nil)},
+	"getticketinfo":           {(*hcjson.GetTicketInfoResult)(nil)},
+	"getticketpoolvalue":      {(*float64)(nil)},
+	"getticketsbyaddress":     {(*[]hcjson.GetTicketInfoResult)(nil)},
+	"gettxout":                {(*hcjson.GetTxOutResult)(nil)},
+	"getvoteinfo":             {(*hcjson.GetVoteInfoResult)(nil)},
+	"getwork":                 {(*hcjson.GetWorkResult)(nil), (*bool)(nil)},
+	"getcheckpointcandidates": {(*[]hcjson.CheckpointCandidate)(nil)},
+	"getcoinsupply":           {(*int64)(nil)},
+	"help":                    {(*string)(nil), (*string)(nil)},
+	"invalidateblock":         nil,
+	"livetickets":             {(*hcjson.LiveTicketsResult)(nil)},
+	"missedtickets":           {(*hcjson.MissedTicketsResult)(nil)},
+	"node":                    nil,
+	"ping":                    nil,
+	"rebroadcastmissed":       nil,
+	"rebroadcastwinners":      nil,
+	"reconsiderblock":         nil,
+	"searchrawtransactions":   {(*string)(nil), (*[]hcjson.SearchRawTransactionsResult)(nil)},
+	"sendrawtransaction":      {(*string)(nil)},
+	"setgenerate":             nil,
+	"stop":                    {(*string)(nil)},
+	"submitblock":             {nil, (*string)(nil)},
+	"ticketfeeinfo":           {(*hcjson.TicketFeeInfoResult)(nil)},
+	"ticketsforaddress":       {(*hcjson.TicketsForAddressResult)(nil)},
+	"ticketvwap":              {(*float64)(nil)},
+	"txfeeinfo":               {(*hcjson.TxFeeInfoResult)(nil)},
+	"validateaddress":         {(*hcjson.ValidateAddressChainResult)(nil)},
+	"verifychain":             {(*bool)(nil)},
+	"verifymessage":           {(*bool)(nil)},
+	"verifyblissmessage":      {(*bool)(nil)},
+	"version":                 {(*map[string]hcjson.VersionResult)(nil)},
 
 	// Websocket commands.
 	"loadtxfilter":                nil,
@@ -18,8 +18,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/james-ray/hcd/wire"
-
+	"github.com/james-ray/hcd/chaincfg"
 	"github.com/james-ray/hcd/hcutil"
 	rpc "github.com/james-ray/hcrpcclient"
 )
@@ -38,6 +37,7 @@ type nodeConfig struct {
 	debugLevel string
 	extra      []string
 	prefix     string
+	activeNet  *chaincfg.Params
 
 	exe          string
 	endpoint     string
@@ -46,8 +46,11 @@ type nodeConfig struct {
 	certificates []byte
 }
 
-// newConfig returns a newConfig with all default values.
-func newConfig(prefix, certFile, keyFile string, extra []string) (*nodeConfig, error) {
+// newConfig returns a newConfig with all default values for a node which runs
+// on the passed network.
+func newConfig(prefix, certFile, keyFile string, extra []string,
+	activeNet *chaincfg.Params) (*nodeConfig, error) {
+
 	a := &nodeConfig{
 		listen:    "127.0.0.1:18555",
 		rpcListen: "127.0.0.1:18556",
@@ -55,6 +58,7 @@ func newConfig(prefix, certFile, keyFile string, extra []string) (*nodeConfig, e
 		rpcPass:   "pass",
 		extra:     extra,
 		prefix:    prefix,
+		activeNet: activeNet,
 
 		exe:      "hcd",
 		endpoint: "ws",
@@ -85,8 +89,9 @@ func (n *nodeConfig) setDefaults() error {
 // process.
 func (n *nodeConfig) arguments() []string {
 	args := []string{}
-	// --simnet
-	args = append(args, fmt.Sprintf("--%s", strings.ToLower(wire.SimNet.String())))
+	// --simnet or --regnet
+	args = append(args, fmt.Sprintf("--%s",
+		strings.ToLower(n.activeNet.Net.String())))
 	if n.rpcUser != "" {
 		// --rpcuser
 		args = append(args, fmt.Sprintf("--rpcuser=%s", n.rpcUser))
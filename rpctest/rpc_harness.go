@@ -63,8 +63,9 @@ var (
 
 // Harness fully encapsulates an active hcd process to provide a unified
 // platform for creating rpc driven integration tests involving hcd. The
-// active hcd node will typically be run in simnet mode in order to allow for
-// easy generation of test blockchains.  The active hcd process is fully
+// active hcd node will typically be run in simnet or regnet mode in order to
+// allow for easy generation of test blockchains.  The regnet mode is preferred
+// for tests which need to quickly reach stake validation height.  The active hcd process is fully
 // managed by Harness, which handles the necessary initialization, and teardown
 // of the process along with any temporary directories created as a result.
 // Multiple Harness instances may be run concurrently, in order to allow for
@@ -128,7 +129,8 @@ func New(activeNet *chaincfg.Params, handlers *hcrpcclient.NotificationHandlers,
 	miningAddr := fmt.Sprintf("--miningaddr=%s", wallet.coinbaseAddr)
 	extraArgs = append(extraArgs, miningAddr)
 
-	config, err := newConfig(nodeTestData, certFile, keyFile, extraArgs)
+	config, err := newConfig(nodeTestData, certFile, keyFile, extraArgs,
+		activeNet)
 	if err != nil {
 		return nil, err
 	}
@@ -136,7 +138,7 @@ func New(activeNet *chaincfg.Params, handlers *hcrpcclient.NotificationHandlers,
 	// Generate p2p+rpc listening addresses.
 	config.listen, config.rpcListen = generateListeningAddresses()
 
-	// Create the testing node bounded to the simnet.
+	// Create the testing node bounded to the active network.
 	node, err := newNode(config, nodeTestData)
 	if err != nil {
 		return nil, err
@@ -191,9 +193,9 @@ func New(activeNet *chaincfg.Params, handlers *hcrpcclient.NotificationHandlers,
 }
 
 // SetUp initializes the rpc test state. Initialization includes: starting up a
-// simnet node, creating a websockets client and connecting to the started
-// node, and finally: optionally generating and submitting a testchain with a
-// configurable number of mature coinbase outputs coinbase outputs.
+// simnet or regnet node, creating a websockets client and connecting to the
+// started node, and finally: optionally generating and submitting a testchain
+// with a configurable number of mature coinbase outputs coinbase outputs.
 //
 // NOTE: This method and TearDown should always be called from the same
 // goroutine as they are not concurrent safe.
@@ -426,6 +426,7 @@ var harnessTestCases = []HarnessTestCase{
 	testJoinMempools, // Depends on results of testJoinBlocks
 	testMemWalletReorg,
 	testMemWalletLockedOutputs,
+	testRegNetHarness,
 }
 
 var mainHarness *Harness
@@ -517,6 +518,47 @@ func TestHarness(t *testing.T) {
 	testTearDownAll(t)
 }
 
+func testRegNetHarness(r *Harness, t *testing.T) {
+	// Create a fresh test harness on the regression test network with a
+	// few mature coinbase outputs.
+	params := &chaincfg.RegNetParams
+	harness, err := New(params, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const numOutputs = 4
+	if err := harness.SetUp(true, numOutputs); err != nil {
+		t.Fatalf("unable to complete rpctest setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	// Ensure the node is running on the regression test network.
+	genesisHash, err := harness.Node.GetBlockHash(0)
+	if err != nil {
+		t.Fatalf("unable to get genesis hash: %v", err)
+	}
+	if *genesisHash != *params.GenesisHash {
+		t.Fatalf("genesis hash is %v, should be %v", genesisHash,
+			params.GenesisHash)
+	}
+
+	// The stake validation height should be within reach of a few more
+	// generated blocks.
+	_, height, err := harness.Node.GetBestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	expectedHeight := int64(params.CoinbaseMaturity) + numOutputs + 1
+	if height != expectedHeight {
+		t.Fatalf("chain height is %v, should be %v", height,
+			expectedHeight)
+	}
+	if height >= params.StakeValidationHeight {
+		t.Fatalf("chain height %v is not below the stake validation "+
+			"height %v", height, params.StakeValidationHeight)
+	}
+}
+
 func testNodesConnected(r *Harness, t *testing.T) {
 	// Create a fresh test harness.
 	harness, err := New(&chaincfg.SimNetParams, nil, nil)
@@ -32,6 +32,14 @@ const FileContents = `[Application Options]
 ; Use simnet.
 ; simnet=1
 
+; Use regnet.
+; regnet=1
+
+; Use the custom network described by a JSON parameters file.  The keys are the
+; names of the fields of the chaincfg.Params type.  See the ParamsFromFile
+; function of the chaincfg package for the details.
+; chainparams=~/.hcd/privnet.json
+
 ; Connect via a SOCKS5 proxy.  NOTE: Specifying a proxy will disable listening
 ; for incoming connections unless listen addresses are provided via the 'listen'
 ; option.
@@ -275,6 +283,10 @@ const FileContents = `[Application Options]
 ; searchrawtransactions RPC available.
 ; addrindex=1
 
+; Build and maintain a ticket lifecycle index which makes the getticketinfo and
+; getticketsbyaddress RPCs available.
+; ticketindex=1
+
 
 ; ------------------------------------------------------------------------------
 ; Signature Verification Cache
@@ -51,13 +51,34 @@ const (
 	// target.
 	defaultTargetOutbound = 8
 
+	// defaultBlockRelayOnlyOutbound is the default number of
+	// block-relay-only outbound peers to target in addition to the other
+	// outbound peers.  These peers neither relay transactions nor addresses,
+	// which makes them hard to detect and therefore hard to eclipse.
+	defaultBlockRelayOnlyOutbound = 2
+
 	// connectionRetryInterval is the base amount of time to wait in between
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
 
 	// maxProtocolVersion is the max protocol version the server supports.
-	maxProtocolVersion = wire.MaxBlockSizeVersion
+	maxProtocolVersion = wire.CompactBlocksVersion
+
+	// maxCmpctBlockDepth is the maximum depth from the best chain tip of a
+	// block which is served as a compact block or whose transactions are
+	// served in response to a getblocktxn message.  Deeper blocks are sent
+	// in full instead since they are unlikely to be reconstructed from the
+	// peer's mempool.
+	maxCmpctBlockDepth = 10
+
+	// maxV1OnlyAddrs is the maximum number of addresses of peers which
+	// rejected the v2 transport to remember.
+	maxV1OnlyAddrs = 1000
+
+	// v1OnlyExpiry is how long peers which rejected the v2 transport are
+	// only connected to with the v1 transport before it is attempted again.
+	v1OnlyExpiry = time.Hour * 24
 )
 
 var (
@@ -174,6 +195,23 @@ type server struct {
 	timeSource           blockchain.MedianTimeSource
 	services             wire.ServiceFlag
 
+	// v1OnlyAddrs houses the addresses of outbound peers which rejected
+	// the v2 transport along with when they may be attempted with it
+	// again.  They are only connected to with the v1 transport until then.
+	// It is protected by the v1OnlyMtx.
+	v1OnlyMtx   sync.Mutex
+	v1OnlyAddrs map[string]time.Time
+
+	// anchors houses the addresses of the block-relay-only peers saved at
+	// the last shutdown which have not been connected to yet.  It is
+	// protected by the anchorsMtx.
+	anchorsMtx sync.Mutex
+	anchors    []string
+
+	// netGroupKey is a secret key used to select the net groups of the
+	// inbound peers which are protected from eviction.
+	netGroupKey []byte
+
 	// The following fields are used for optional indexes.  They will be nil
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
@@ -181,11 +219,20 @@ type server struct {
 	txIndex         *indexers.TxIndex
 	addrIndex       *indexers.AddrIndex
 	existsAddrIndex *indexers.ExistsAddrIndex
+	ticketIndex     *indexers.TicketIndex
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
 // the blockmanager.
 type serverPeer struct {
+	// The following variables must only be used atomically.  They are the
+	// unix times, in nanoseconds, at which the peer last relayed a novel
+	// block, transaction and vote and are used to protect peers which are
+	// useful to us from eviction.
+	lastBlockTime int64
+	lastTxTime    int64
+	lastVoteTime  int64
+
 	*peer.Peer
 
 	connReq         *connmgr.ConnReq
@@ -211,6 +258,23 @@ type serverPeer struct {
 	// The following chans are used to sync blockmanager and server.
 	txProcessed    chan struct{}
 	blockProcessed chan struct{}
+
+	// The following fields track whether the peer has signalled support
+	// for compact blocks and whether it wants new blocks announced with
+	// cmpctblock messages directly (high-bandwidth mode).  They are
+	// protected by the cmpctMtx.
+	cmpctMtx       sync.Mutex
+	cmpctSupported bool
+	cmpctAnnounce  bool
+
+	// v2Attempted is set when the outbound connection to the peer
+	// attempted to negotiate the v2 transport.
+	v2Attempted bool
+
+	// blockRelayOnly is set for outbound peers which are only used to
+	// relay blocks, so neither transactions nor addresses are exchanged
+	// with them.
+	blockRelayOnly bool
 }
 
 // Only respond with addresses once per connection
@@ -277,6 +341,37 @@ func (sp *serverPeer) relayTxDisabled() bool {
 	return isDisabled
 }
 
+// setCmpctBlocks records the compact block relay mode requested by the peer.
+// It is safe for concurrent access.
+func (sp *serverPeer) setCmpctBlocks(supported, announce bool) {
+	sp.cmpctMtx.Lock()
+	sp.cmpctSupported = supported
+	sp.cmpctAnnounce = supported && announce
+	sp.cmpctMtx.Unlock()
+}
+
+// supportsCmpctBlocks returns whether or not the peer has signalled it is able
+// to serve and receive compact blocks.
+// It is safe for concurrent access.
+func (sp *serverPeer) supportsCmpctBlocks() bool {
+	sp.cmpctMtx.Lock()
+	supported := sp.cmpctSupported
+	sp.cmpctMtx.Unlock()
+
+	return supported
+}
+
+// wantsCmpctAnnounce returns whether or not the peer requested new blocks be
+// announced to it with cmpctblock messages instead of inventory.
+// It is safe for concurrent access.
+func (sp *serverPeer) wantsCmpctAnnounce() bool {
+	sp.cmpctMtx.Lock()
+	announce := sp.cmpctAnnounce
+	sp.cmpctMtx.Unlock()
+
+	return announce
+}
+
 // pushAddrMsg sends an addr message to the connected peer using the provided
 // addresses.
 func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
@@ -348,9 +443,9 @@ func (sp *serverPeer) OnVersion(p *peer.Peer, msg *wire.MsgVersion) {
 	// Update the address manager with the advertised services for outbound
 	// connections in case they have changed.  This is not done for inbound
 	// connections to help prevent malicious behavior and is skipped when
-	// running on the simulation test network since it is only intended to
-	// connect to specified peers and actively avoids advertising and
-	// connecting to discovered peers.
+	// running on the simulation and regression test networks since they
+	// are only intended to connect to specified peers and actively avoid
+	// advertising and connecting to discovered peers.
 	//
 	// NOTE: This is done before rejecting peers that are too old to ensure
 	// it is updated regardless in the case a new minimum protocol version is
@@ -358,7 +453,7 @@ func (sp *serverPeer) OnVersion(p *peer.Peer, msg *wire.MsgVersion) {
 	addrManager := sp.server.addrManager
 	isInbound := sp.Inbound()
 	remoteAddr := sp.NA()
-	if !cfg.SimNet && !isInbound {
+	if !cfg.SimNet && !cfg.RegNet && !isInbound {
 		addrManager.SetServices(remoteAddr, msg.Services)
 	}
 	// Ignore peers that have a protcol version that is too old.  The peer
@@ -424,18 +519,28 @@ func (sp *serverPeer) OnVersion(p *peer.Peer, msg *wire.MsgVersion) {
 	}
 
 	// Choose whether or not to relay transactions before a filter command
-	// is received.
-	sp.setDisableRelayTx(msg.DisableRelayTx)
+	// is received.  Transactions are never relayed to block-relay-only
+	// peers.
+	sp.setDisableRelayTx(msg.DisableRelayTx || sp.blockRelayOnly)
+
+	// Signal support for compact blocks in low-bandwidth mode.  The block
+	// manager later asks the peers which are quickest to deliver new blocks
+	// to announce them directly.
+	if p.ProtocolVersion() >= wire.CompactBlocksVersion {
+		p.QueueMessage(wire.NewMsgSendCmpct(false,
+			wire.CmpctBlockVersion), nil)
+	}
 
 	// Update the address manager and request known addresses from the
 	// remote peer for outbound connections.  This is skipped when running
-	// on the simulation test network since it is only intended to connect
-	// to specified peers and actively avoids advertising and connecting to
-	// discovered peers.
-	if !cfg.SimNet {
+	// on the simulation and regression test networks since they are only
+	// intended to connect to specified peers and actively avoid advertising
+	// and connecting to discovered peers.  Addresses are not exchanged with
+	// block-relay-only peers.
+	if !cfg.SimNet && !cfg.RegNet {
 		//addrManager := sp.server.addrManager
 		// Outbound connections.
-		if !p.Inbound() {
+		if !p.Inbound() && !sp.blockRelayOnly {
 			// TODO(davec): Only do this if not doing the initial block
 			// download and the local address is routable.
 			//if !cfg.DisableListen /* && isCurrent? */ {
@@ -625,6 +730,11 @@ func (sp *serverPeer) OnTx(p *peer.Peer, msg *wire.MsgTx) {
 			msg.TxHash(), p)
 		return
 	}
+	if sp.blockRelayOnly {
+		peerLog.Tracef("Ignoring tx %v from block-relay-only peer %v",
+			msg.TxHash(), p)
+		return
+	}
 
 	// Add the transaction to the known inventory for the peer.
 	// Convert the raw MsgTx to a hcutil.Tx which provides some convenience
@@ -671,7 +781,7 @@ func (sp *serverPeer) OnBlock(p *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(p *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	if !cfg.BlocksOnly && !sp.blockRelayOnly {
 		if len(msg.InvList) > 0 {
 			sp.server.blockManager.QueueInv(msg, sp)
 		}
@@ -704,6 +814,82 @@ func (sp *serverPeer) OnHeaders(p *peer.Peer, msg *wire.MsgHeaders) {
 	sp.server.blockManager.QueueHeaders(msg, sp)
 }
 
+// OnSendCmpct is invoked when a peer receives a sendcmpct wire message.  It
+// records whether the peer wants to use compact block relay and, if so,
+// whether new blocks should be announced to it with cmpctblock messages.
+func (sp *serverPeer) OnSendCmpct(p *peer.Peer, msg *wire.MsgSendCmpct) {
+	// Ignore versions of the protocol which are not understood.
+	if msg.Version != wire.CmpctBlockVersion {
+		peerLog.Debugf("Ignoring sendcmpct version %d from %s",
+			msg.Version, sp)
+		return
+	}
+
+	sp.setCmpctBlocks(true, msg.Announce)
+}
+
+// OnCmpctBlock is invoked when a peer receives a cmpctblock wire message.  It
+// blocks until the compact block has been reconstructed and processed or the
+// missing transactions have been requested.
+func (sp *serverPeer) OnCmpctBlock(p *peer.Peer, msg *wire.MsgCmpctBlock) {
+	// Add the block to the known inventory for the peer.
+	blockHash := msg.Header.BlockHash()
+	iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
+	p.AddKnownInventory(iv)
+
+	sp.server.blockManager.QueueCmpctBlock(msg, sp)
+	<-sp.blockProcessed
+}
+
+// OnGetBlockTxn is invoked when a peer receives a getblocktxn wire message.
+// It responds with the requested transactions of a recent block.
+func (sp *serverPeer) OnGetBlockTxn(p *peer.Peer, msg *wire.MsgGetBlockTxn) {
+	bm := sp.server.blockManager
+	block, err := bm.chain.FetchBlockByHash(&msg.BlockHash)
+	if err != nil {
+		peerLog.Debugf("Unable to fetch block %v requested by %s: %v",
+			msg.BlockHash, sp, err)
+		return
+	}
+
+	// Serve the full block instead when it is too deep in the chain to
+	// have been announced as a compact block.
+	best := bm.chain.BestSnapshot()
+	if block.Height() < best.Height-maxCmpctBlockDepth {
+		sp.QueueMessage(block.MsgBlock(), nil)
+		return
+	}
+
+	// Requesting transactions which are not part of the block is a
+	// protocol violation.
+	msgBlock := block.MsgBlock()
+	txns := make([]*wire.MsgTx, 0, len(msg.TxIndexes))
+	for _, idx := range msg.TxIndexes {
+		if idx >= uint32(len(msgBlock.Transactions)) {
+			sp.addBanScore(100, 0, "getblocktxn index out of range")
+			return
+		}
+		txns = append(txns, msgBlock.Transactions[idx])
+	}
+	sTxns := make([]*wire.MsgTx, 0, len(msg.STxIndexes))
+	for _, idx := range msg.STxIndexes {
+		if idx >= uint32(len(msgBlock.STransactions)) {
+			sp.addBanScore(100, 0, "getblocktxn index out of range")
+			return
+		}
+		sTxns = append(sTxns, msgBlock.STransactions[idx])
+	}
+
+	sp.QueueMessage(wire.NewMsgBlockTxn(&msg.BlockHash, txns, sTxns), nil)
+}
+
+// OnBlockTxn is invoked when a peer receives a blocktxn wire message.  It
+// blocks until the block the transactions complete has been processed.
+func (sp *serverPeer) OnBlockTxn(p *peer.Peer, msg *wire.MsgBlockTxn) {
+	sp.server.blockManager.QueueBlockTxn(msg, sp)
+	<-sp.blockProcessed
+}
+
 // handleGetData is invoked when a peer receives a getdata wire message and is
 // used to deliver block and transaction information.
 func (sp *serverPeer) OnGetData(p *peer.Peer, msg *wire.MsgGetData) {
@@ -747,6 +933,8 @@ func (sp *serverPeer) OnGetData(p *peer.Peer, msg *wire.MsgGetData) {
 			err = sp.server.pushTxMsg(sp, &iv.Hash, c, waitChan)
 		case wire.InvTypeBlock:
 			err = sp.server.pushBlockMsg(sp, &iv.Hash, c, waitChan)
+		case wire.InvTypeCmpctBlock:
+			err = sp.server.pushCmpctBlockMsg(sp, &iv.Hash, c, waitChan)
 		default:
 			peerLog.Warnf("Unknown type %d in inventory request from %s",
 				iv.Type, sp)
@@ -1050,11 +1238,11 @@ func (sp *serverPeer) OnFilterLoad(p *peer.Peer, msg *wire.MsgFilterLoad) {
 // OnGetAddr is invoked when a peer receives a getaddr wire message and is used
 // to provide the peer with known addresses from the address manager.
 func (sp *serverPeer) OnGetAddr(p *peer.Peer, msg *wire.MsgGetAddr) {
-	// Don't return any addresses when running on the simulation test
-	// network.  This helps prevent the network from becoming another
-	// public test network since it will not be able to learn about other
-	// peers that have not specifically been provided.
-	if cfg.SimNet {
+	// Don't return any addresses when running on the simulation or
+	// regression test networks.  This helps prevent the network from
+	// becoming another public test network since it will not be able to
+	// learn about other peers that have not specifically been provided.
+	if cfg.SimNet || cfg.RegNet {
 		return
 	}
 
@@ -1074,24 +1262,43 @@ func (sp *serverPeer) OnGetAddr(p *peer.Peer, msg *wire.MsgGetAddr) {
 // OnAddr is invoked when a peer receives an addr wire message and is used to
 // notify the server about advertised addresses.
 func (sp *serverPeer) OnAddr(p *peer.Peer, msg *wire.MsgAddr) {
-	// Ignore addresses when running on the simulation test network.  This
-	// helps prevent the network from becoming another public test network
-	// since it will not be able to learn about other peers that have not
-	// specifically been provided.
-	if cfg.SimNet {
+	sp.addAddresses(p, msg.Command(), msg.AddrList)
+}
+
+// OnAddrV2 is invoked when a peer receives an addrv2 wire message and is used
+// to notify the server about advertised addresses, which may include addresses
+// on networks such as Tor v3, I2P and CJDNS.
+func (sp *serverPeer) OnAddrV2(p *peer.Peer, msg *wire.MsgAddrV2) {
+	sp.addAddresses(p, msg.Command(), msg.AddrList)
+}
+
+// addAddresses adds the addresses advertised by the peer in the passed command
+// to the known addresses of the peer and the server address manager.
+func (sp *serverPeer) addAddresses(p *peer.Peer, cmd string, addrList []*wire.NetAddress) {
+	// Ignore addresses when running on the simulation or regression test
+	// networks.  This helps prevent the network from becoming another
+	// public test network since it will not be able to learn about other
+	// peers that have not specifically been provided.
+	if cfg.SimNet || cfg.RegNet {
+		return
+	}
+
+	// Ignore addresses from block-relay-only peers since they are not
+	// supposed to relay them.
+	if sp.blockRelayOnly {
 		return
 	}
 
 	// A message that has no addresses is invalid.
-	if len(msg.AddrList) == 0 {
+	if len(addrList) == 0 {
 		peerLog.Errorf("Command [%s] from %s does not contain any addresses",
-			msg.Command(), p)
+			cmd, p)
 		p.Disconnect()
 		return
 	}
 
 	now := time.Now()
-	for _, na := range msg.AddrList {
+	for _, na := range addrList {
 		// Don't add more address if we're disconnecting.
 		if !p.Connected() {
 			return
@@ -1114,7 +1321,7 @@ func (sp *serverPeer) OnAddr(p *peer.Peer, msg *wire.MsgAddr) {
 	// addresses, and last seen updates.
 	// XXX bitcoind gives a 2 hour time penalty here, do we want to do the
 	// same?
-	sp.server.addrManager.AddAddresses(msg.AddrList, p.NA())
+	sp.server.addrManager.AddAddresses(addrList, p.NA())
 }
 
 // OnRead is invoked when a peer receives a message and it is used to update
@@ -1269,6 +1476,45 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 	return nil
 }
 
+// pushCmpctBlockMsg sends a cmpctblock message for the provided block hash to
+// the connected peer.  Blocks which are too deep in the chain to be
+// reconstructed from the peer's mempool are sent in full instead.  An error is
+// returned if the block hash is not known.
+func (s *server) pushCmpctBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{}, waitChan <-chan struct{}) error {
+	chain := sp.server.blockManager.chain
+	block, err := chain.FetchBlockByHash(hash)
+	if err != nil {
+		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
+			hash, err)
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+
+	// Once we have fetched data wait for any previous operation to finish.
+	if waitChan != nil {
+		<-waitChan
+	}
+
+	best := chain.BestSnapshot()
+	if block.Height() < best.Height-maxCmpctBlockDepth {
+		sp.QueueMessage(block.MsgBlock(), doneChan)
+		return nil
+	}
+
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+	sp.QueueMessage(wire.NewMsgCmpctBlock(block.MsgBlock(), nonce), doneChan)
+	return nil
+}
+
 // handleUpdatePeerHeight updates the heights of all peers who were known to
 // announce a block we recently accepted.
 func (s *server) handleUpdatePeerHeights(state *peerState, umsg updatePeerHeightsMsg) {
@@ -1333,8 +1579,11 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	// TODO: Check for max peers from a single IP.
 
 	// Limit max number of total peers.
-	// allow whitelisted inbound peers regardless.
-	if state.Count() >= cfg.MaxPeers && !(sp.Inbound() && sp.isWhitelisted) {
+	// allow whitelisted inbound peers regardless.  Room is made for new
+	// inbound peers by evicting an existing inbound peer when possible.
+	if state.Count() >= cfg.MaxPeers && !(sp.Inbound() && sp.isWhitelisted) &&
+		!(sp.Inbound() && s.evictInboundPeer(state)) {
+
 		srvrLog.Infof("Max peers reached [%d] - disconnecting peer %s",
 			cfg.MaxPeers, sp)
 		sp.Disconnect()
@@ -1359,6 +1608,31 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	return true
 }
 
+// evictInboundPeer disconnects and removes an inbound peer in order to make
+// room for a new inbound peer.  Whitelisted peers are never evicted.  It
+// returns whether or not a peer was evicted.  It is invoked from the
+// peerHandler goroutine.
+func (s *server) evictInboundPeer(state *peerState) bool {
+	candidates := make([]evictionCandidate, 0, len(state.inboundPeers))
+	for _, sp := range state.inboundPeers {
+		if sp.isWhitelisted {
+			continue
+		}
+		candidates = append(candidates, newEvictionCandidate(sp,
+			s.netGroupKey))
+	}
+	evict := selectPeerToEvict(candidates)
+	if evict == nil {
+		return false
+	}
+
+	srvrLog.Infof("Evicting inbound peer %s to make room for a new peer",
+		evict)
+	delete(state.inboundPeers, evict.ID())
+	evict.Disconnect()
+	return true
+}
+
 // handleDonePeerMsg deals with peers that have signalled they are done.  It is
 // invoked from the peerHandler goroutine.
 func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
@@ -1413,23 +1687,55 @@ func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
 // known to have it.  It is invoked from the peerHandler goroutine.
 func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
+	// The compact block announced to high-bandwidth peers is only created
+	// once, when the first such peer is found.
+	var cmpctBlock *wire.MsgCmpctBlock
+
 	state.forAllPeers(func(sp *serverPeer) {
 		if !sp.Connected() {
 			return
 		}
 
+		// If the inventory is a block and the peer asked for new blocks
+		// to be announced with compact blocks, send it a cmpctblock
+		// message directly instead of an inventory message.
+		if msg.invVect.Type == wire.InvTypeBlock && sp.wantsCmpctAnnounce() {
+			if sp.HasKnownInventory(msg.invVect) {
+				return
+			}
+			block, ok := msg.data.(*hcutil.Block)
+			if !ok {
+				peerLog.Warnf("Underlying data for compact " +
+					"block relay is not a block")
+				return
+			}
+			if cmpctBlock == nil {
+				nonce, err := wire.RandomUint64()
+				if err != nil {
+					peerLog.Errorf("Failed to generate compact "+
+						"block nonce: %v", err)
+					return
+				}
+				cmpctBlock = wire.NewMsgCmpctBlock(block.MsgBlock(),
+					nonce)
+			}
+			sp.AddKnownInventory(msg.invVect)
+			sp.QueueMessage(cmpctBlock, nil)
+			return
+		}
+
 		// If the inventory is a block and the peer prefers headers,
 		// generate and send a headers message instead of an inventory
 		// message.
 		if msg.invVect.Type == wire.InvTypeBlock && sp.WantsHeaders() {
-			blockHeader, ok := msg.data.(wire.BlockHeader)
+			block, ok := msg.data.(*hcutil.Block)
 			if !ok {
 				peerLog.Warnf("Underlying data for headers" +
-					" is not a block header")
+					" is not a block")
 				return
 			}
 			msgHeaders := wire.NewMsgHeaders()
-			if err := msgHeaders.AddBlockHeader(&blockHeader); err != nil {
+			if err := msgHeaders.AddBlockHeader(&block.MsgBlock().Header); err != nil {
 				peerLog.Errorf("Failed to add block"+
 					" header: %v", err)
 				return
@@ -1666,6 +1972,10 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnBlock:          sp.OnBlock,
 			OnInv:            sp.OnInv,
 			OnHeaders:        sp.OnHeaders,
+			OnSendCmpct:      sp.OnSendCmpct,
+			OnCmpctBlock:     sp.OnCmpctBlock,
+			OnGetBlockTxn:    sp.OnGetBlockTxn,
+			OnBlockTxn:       sp.OnBlockTxn,
 			OnGetData:        sp.OnGetData,
 			OnGetBlocks:      sp.OnGetBlocks,
 			OnGetHeaders:     sp.OnGetHeaders,
@@ -1674,6 +1984,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnFilterLoad:     sp.OnFilterLoad,
 			OnGetAddr:        sp.OnGetAddr,
 			OnAddr:           sp.OnAddr,
+			OnAddrV2:         sp.OnAddrV2,
 			OnRead:           sp.OnRead,
 			OnWrite:          sp.OnWrite,
 		},
@@ -1686,7 +1997,39 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		Services:         sp.server.services,
 		DisableRelayTx:   cfg.BlocksOnly,
 		ProtocolVersion:  maxProtocolVersion,
+		V2Transport:      !cfg.NoV2Transport,
+	}
+}
+
+// attemptV2Transport returns whether or not the outbound connection to the
+// passed address should negotiate the v2 transport.  This is the case when the
+// address is known to support it or is a persistent peer, which is reconnected
+// to with the v1 transport should the peer reject it, unless the peer recently
+// rejected it.
+func (s *server) attemptV2Transport(addr string, permanent bool) bool {
+	if cfg.NoV2Transport {
+		return false
+	}
+
+	s.v1OnlyMtx.Lock()
+	expiry, v1Only := s.v1OnlyAddrs[addr]
+	if v1Only && !time.Now().Before(expiry) {
+		delete(s.v1OnlyAddrs, addr)
+		v1Only = false
+	}
+	s.v1OnlyMtx.Unlock()
+	if v1Only {
+		return false
+	}
+	if permanent {
+		return true
+	}
+
+	na, err := s.addrManager.DeserializeNetAddress(addr)
+	if err != nil {
+		return false
 	}
+	return s.addrManager.Services(na)&wire.SFNodeP2PV2 != 0
 }
 
 // inboundPeerConnected is invoked by the connection manager when a new inbound
@@ -1708,7 +2051,12 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
-	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
+	sp.blockRelayOnly = c.BlockRelayOnly
+	peerCfg := newPeerConfig(sp)
+	peerCfg.V2Transport = s.attemptV2Transport(c.Addr.String(), c.Permanent)
+	peerCfg.DisableRelayTx = peerCfg.DisableRelayTx || sp.blockRelayOnly
+	sp.v2Attempted = peerCfg.V2Transport
+	p, err := peer.NewOutboundPeer(peerCfg, c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
 		s.connManager.Disconnect(c.ID())
@@ -1722,10 +2070,48 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	s.addrManager.Attempt(sp.NA())
 }
 
+// addV1OnlyAddr records that the passed address rejected the v2 transport so it
+// is only connected to with the v1 transport for the next v1OnlyExpiry.  Expired
+// addresses are removed once the maximum number of addresses is reached, after
+// which a random address is evicted if there is still no room.
+func (s *server) addV1OnlyAddr(addr string) {
+	s.v1OnlyMtx.Lock()
+	defer s.v1OnlyMtx.Unlock()
+
+	now := time.Now()
+	if _, ok := s.v1OnlyAddrs[addr]; !ok &&
+		len(s.v1OnlyAddrs)+1 > maxV1OnlyAddrs {
+
+		for a, expiry := range s.v1OnlyAddrs {
+			if !now.Before(expiry) {
+				delete(s.v1OnlyAddrs, a)
+			}
+		}
+		if len(s.v1OnlyAddrs)+1 > maxV1OnlyAddrs {
+			// The iteration order is not important here, see
+			// limitMap.
+			for a := range s.v1OnlyAddrs {
+				delete(s.v1OnlyAddrs, a)
+				break
+			}
+		}
+	}
+	s.v1OnlyAddrs[addr] = now.Add(v1OnlyExpiry)
+}
+
 // peerDoneHandler handles peer disconnects by notifiying the server that it's
 // done.
 func (s *server) peerDoneHandler(sp *serverPeer) {
 	sp.WaitForDisconnect()
+
+	// Fall back to the v1 transport for future connections to the peer
+	// when it rejected the v2 transport.
+	if sp.v2Attempted && sp.V2Rejected() {
+		s.addV1OnlyAddr(sp.Addr())
+		srvrLog.Debugf("Falling back to the v1 transport for %s",
+			sp.Addr())
+	}
+
 	s.donePeers <- sp
 
 	// Only tell block manager we are gone if we ever told it we existed.
@@ -1802,6 +2188,18 @@ out:
 			s.handleQuery(state, qmsg)
 
 		case <-s.quit:
+			// Save the block-relay-only peers as anchors to connect
+			// to at the next startup.
+			var anchors []string
+			for _, sp := range state.outboundPeers {
+				if sp.blockRelayOnly && sp.VerAckReceived() {
+					anchors = append(anchors, sp.Addr())
+				}
+			}
+			if len(anchors) > 0 {
+				saveAnchors(cfg.DataDir, anchors)
+			}
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -2242,6 +2640,9 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	if cfg.NoPeerBloomFilters {
 		services &^= wire.SFNodeBloom
 	}
+	if !cfg.NoV2Transport {
+		services |= wire.SFNodeP2PV2
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, hcdLookup)
 
@@ -2385,8 +2786,13 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		db:                   db,
 		timeSource:           blockchain.NewMedianTime(),
 		services:             services,
+		v1OnlyAddrs:          make(map[string]time.Time),
+		netGroupKey:          make([]byte, 32),
 		sigCache:             txscript.NewSigCache(cfg.SigCacheMaxSize),
 	}
+	if _, err := rand.Read(s.netGroupKey); err != nil {
+		return nil, err
+	}
 
 	// Create the transaction and address indexes if needed.
 	//
@@ -2419,6 +2825,11 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		s.existsAddrIndex = indexers.NewExistsAddrIndex(db, chainParams)
 		indexes = append(indexes, s.existsAddrIndex)
 	}
+	if cfg.TicketIndex {
+		indxLog.Info("Ticket index is enabled")
+		s.ticketIndex = indexers.NewTicketIndex(db, chainParams)
+		indexes = append(indexes, s.ticketIndex)
+	}
 
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
@@ -2478,13 +2889,18 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	s.cpuMiner = newCPUMiner(&policy, &s)
 
 	// Only setup a function to return new addresses to connect to when
-	// not running in connect-only mode.  The simulation network is always
-	// in connect-only mode since it is only intended to connect to
-	// specified peers and actively avoid advertising and connecting to
-	// discovered peers in order to prevent it from becoming a public test
-	// network.
+	// not running in connect-only mode.  The simulation and regression test
+	// networks are always in connect-only mode since they are only intended
+	// to connect to specified peers and actively avoid advertising and
+	// connecting to discovered peers in order to prevent them from becoming
+	// public test networks.
 	var newAddressFunc func() (net.Addr, error)
-	if !cfg.SimNet && len(cfg.ConnectPeers) == 0 {
+	if !cfg.SimNet && !cfg.RegNet && len(cfg.ConnectPeers) == 0 {
+		// Tor addresses can only be dialed through a proxy and I2P
+		// addresses through the proxy of an I2P router.
+		torReachable := !cfg.NoOnion &&
+			(cfg.OnionProxy != "" || cfg.Proxy != "")
+		i2pReachable := cfg.I2PProxy != ""
 		newAddressFunc = func() (net.Addr, error) {
 			for tries := 0; tries < 100; tries++ {
 				addr := s.addrManager.GetAddress()
@@ -2492,6 +2908,14 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 					break
 				}
 
+				// Skip addresses on networks which can't be
+				// reached with the current configuration.
+				na := addr.NetAddress()
+				if (!torReachable && addrmgr.IsTor(na)) ||
+					(!i2pReachable && addrmgr.IsI2P(na)) {
+					continue
+				}
+
 				// Address will not be invalid, local or unroutable
 				// because addrmanager rejects those on addition.
 				// Just check that we don't already have an address
@@ -2523,19 +2947,48 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		}
 	}
 
+	// Block-relay-only peers are only connected to when new addresses are
+	// connected to automatically.  The anchors saved at the last shutdown
+	// are connected to first.
+	var newBlockRelayOnlyAddressFunc func() (net.Addr, error)
+	if newAddressFunc != nil {
+		s.anchors = loadAnchors(cfg.DataDir)
+		newBlockRelayOnlyAddressFunc = func() (net.Addr, error) {
+			s.anchorsMtx.Lock()
+			if len(s.anchors) > 0 {
+				anchor := s.anchors[0]
+				s.anchors = s.anchors[1:]
+				s.anchorsMtx.Unlock()
+				srvrLog.Debugf("Connecting to anchor %s", anchor)
+				return addrStringToNetAddr(anchor)
+			}
+			s.anchorsMtx.Unlock()
+			return newAddressFunc()
+		}
+	}
+
 	// Create a connection manager.
 	targetOutbound := defaultTargetOutbound
 	if cfg.MaxPeers < targetOutbound {
 		targetOutbound = cfg.MaxPeers
 	}
+	targetBlockRelayOnly := 0
+	if newBlockRelayOnlyAddressFunc != nil {
+		targetBlockRelayOnly = defaultBlockRelayOnlyOutbound
+		if cfg.MaxPeers-targetOutbound < targetBlockRelayOnly {
+			targetBlockRelayOnly = cfg.MaxPeers - targetOutbound
+		}
+	}
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: uint32(targetOutbound),
-		Dial:           hcdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:                   listeners,
+		OnAccept:                    s.inboundPeerConnected,
+		RetryDuration:               connectionRetryInterval,
+		TargetOutbound:              uint32(targetOutbound),
+		TargetBlockRelayOnly:        uint32(targetBlockRelayOnly),
+		Dial:                        hcdDial,
+		OnConnection:                s.outboundPeerConnected,
+		GetNewAddress:               newAddressFunc,
+		GetNewBlockRelayOnlyAddress: newBlockRelayOnlyAddressFunc,
 	})
 	if err != nil {
 		return nil, err
@@ -2584,6 +3037,15 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 		return nil, err
 	}
 
+	// Tor and I2P addresses don't resolve to an IP address, so they are
+	// left as is to be dialed through the respective proxy by hcdDial.
+	if strings.HasSuffix(host, ".onion") || strings.HasSuffix(host, ".i2p") {
+		if cfg.NoOnion && strings.HasSuffix(host, ".onion") {
+			return nil, errors.New("tor has been disabled")
+		}
+		return proxiedAddr(addr), nil
+	}
+
 	// Attempt to look up an IP address associated with the parsed host.
 	// The hcdLookup function will transparently handle performing the
 	// lookup over Tor if necessary.
@@ -2606,6 +3068,24 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 	}, nil
 }
 
+// proxiedAddr is a net.Addr for a host which can only be reached through a
+// proxy, such as a Tor hidden service or an I2P destination.
+type proxiedAddr string
+
+// Network returns the network of the address.
+//
+// This is part of the net.Addr interface.
+func (a proxiedAddr) Network() string {
+	return "tcp"
+}
+
+// String returns the host and port of the address.
+//
+// This is part of the net.Addr interface.
+func (a proxiedAddr) String() string {
+	return string(a)
+}
+
 // isWhitelisted returns whether the IP address is included in the whitelisted
 // networks and IPs.
 func isWhitelisted(addr net.Addr) bool {
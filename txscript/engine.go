@@ -111,6 +111,53 @@ type Engine struct {
 	sigCache        *SigCache
 	bip16           bool     // treat execution as pay-to-script-hash
 	savedFirstStack [][]byte // stack from first script for bip16 scripts
+
+	// tracing is set when every step is recorded in trace for debugging.
+	// traceBytes is the total size of the stack items in the trace.
+	tracing    bool
+	trace      []StepTrace
+	traceBytes int
+}
+
+const (
+	// MaxTraceSteps is the maximum number of steps which are recorded
+	// when tracing is enabled.
+	MaxTraceSteps = 10000
+
+	// MaxTraceBytes is the maximum total size of the stack items recorded
+	// in the steps of a trace.  Every step holds a copy of both stacks, so
+	// scripts which grow the stacks would otherwise make the trace far
+	// larger than the scripts themselves.
+	MaxTraceBytes = 8 * 1024 * 1024
+)
+
+// StepTrace describes the state of the engine after a single opcode was
+// stepped while tracing is enabled.
+type StepTrace struct {
+	// ScriptIdx and ScriptOff are the program counter of the opcode.
+	// Index 0 is the signature script, 1 is the public key script and 2
+	// is the redeem script of a pay-to-script-hash spend.
+	ScriptIdx int
+	ScriptOff int
+
+	// Opcode is the disassembly of the opcode.
+	Opcode string
+
+	// Executed is false when the opcode was skipped because it is in a
+	// conditional branch which is not executing.
+	Executed bool
+
+	// Stack and AltStack are the contents of the data and alt stacks after
+	// the step, where the last item is the top of the stack.
+	Stack    [][]byte
+	AltStack [][]byte
+
+	// CondStack is the condition stack after the step, made up of the
+	// OpCondFalse, OpCondTrue and OpCondSkip values.
+	CondStack []int
+
+	// Err is the error returned by the step, if any.
+	Err error
 }
 
 // hasFlag returns whether the script engine instance has the passed flag set.
@@ -279,6 +326,21 @@ func (vm *Engine) Step() (done bool, err error) {
 	}
 	opcode := &vm.scripts[vm.scriptIdx][vm.scriptOff]
 
+	// Record the state once the step is done when tracing.
+	if vm.tracing {
+		step := StepTrace{
+			ScriptIdx: vm.scriptIdx,
+			ScriptOff: vm.scriptOff,
+			Opcode:    opcode.print(false),
+			Executed:  vm.isBranchExecuting(),
+		}
+		defer func() {
+			if traceErr := vm.recordStep(&step, err); traceErr != nil {
+				done, err = true, traceErr
+			}
+		}()
+	}
+
 	// Execute the opcode while taking into account several things such as
 	// disabled opcodes, illegal opcodes, maximum allowed operations per
 	// script, maximum script element sizes, and conditionals.
@@ -387,6 +449,48 @@ func (vm *Engine) Execute() (err error) {
 	return vm.CheckErrorCondition(true)
 }
 
+// EnableTrace makes the engine record the state after every step, which
+// can later be retrieved with Trace.  It must be called before the scripts
+// are executed.
+func (vm *Engine) EnableTrace() {
+	vm.tracing = true
+}
+
+// Trace returns the steps executed so far when tracing is enabled.
+func (vm *Engine) Trace() []StepTrace {
+	return vm.trace
+}
+
+// recordStep completes the passed step with the current stacks and the error
+// of the step and appends it to the trace.  ErrTraceTooLarge is returned
+// without recording the step when the trace would exceed MaxTraceSteps or
+// MaxTraceBytes.
+func (vm *Engine) recordStep(step *StepTrace, err error) error {
+	size := 0
+	for _, stk := range [][][]byte{vm.dstack.stk, vm.astack.stk} {
+		for _, data := range stk {
+			size += len(data)
+		}
+	}
+	if len(vm.trace) >= MaxTraceSteps || vm.traceBytes+size > MaxTraceBytes {
+		return ErrTraceTooLarge
+	}
+	vm.traceBytes += size
+
+	copyStack := func(stk [][]byte) [][]byte {
+		for i := range stk {
+			stk[i] = append([]byte(nil), stk[i]...)
+		}
+		return stk
+	}
+	step.Stack = copyStack(vm.GetStack())
+	step.AltStack = copyStack(vm.GetAltStack())
+	step.CondStack = append([]int(nil), vm.condStack...)
+	step.Err = err
+	vm.trace = append(vm.trace, *step)
+	return nil
+}
+
 // subScript returns the script since the last OP_CODESEPARATOR.
 func (vm *Engine) subScript() []parsedOpcode {
 	return vm.scripts[vm.scriptIdx][vm.lastCodeSep:]
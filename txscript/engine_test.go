@@ -7,6 +7,7 @@
 package txscript_test
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/james-ray/hcd/chaincfg/chainhash"
@@ -474,3 +475,165 @@ func TestCheckErrorCondition(t *testing.T) {
 		t.Errorf("unexpected error %v on final check", err)
 	}
 }
+
+// TestEngineTrace ensures the steps recorded when tracing is enabled reflect
+// the program counter, stacks and conditional execution of each opcode.
+func TestEngineTrace(t *testing.T) {
+	t.Parallel()
+
+	tx := &wire.MsgTx{
+		SerType: wire.TxSerializeFull,
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{},
+			SignatureScript:  []byte{txscript.OP_2, txscript.OP_3},
+			Sequence:         4294967295,
+		}},
+		TxOut: []*wire.TxOut{{Value: 1000000000}},
+	}
+	pkScript := []byte{
+		txscript.OP_TOALTSTACK,
+		txscript.OP_0,
+		txscript.OP_IF,
+		txscript.OP_RETURN,
+		txscript.OP_ENDIF,
+		txscript.OP_FROMALTSTACK,
+		txscript.OP_ADD,
+		txscript.OP_5,
+		txscript.OP_EQUAL,
+	}
+	vm, err := txscript.NewEngine(pkScript, tx, 0, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+	vm.EnableTrace()
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("failed to execute script: %v", err)
+	}
+
+	trace := vm.Trace()
+	if len(trace) != 11 {
+		t.Fatalf("unexpected number of steps: got %d, want 11",
+			len(trace))
+	}
+	for i, step := range trace {
+		wantIdx, wantOff := 0, i
+		if i >= 2 {
+			wantIdx, wantOff = 1, i-2
+		}
+		if step.ScriptIdx != wantIdx || step.ScriptOff != wantOff {
+			t.Errorf("step %d: unexpected pc %d:%d", i,
+				step.ScriptIdx, step.ScriptOff)
+		}
+		if step.Err != nil {
+			t.Errorf("step %d: unexpected error %v", i, step.Err)
+		}
+	}
+
+	// OP_TOALTSTACK moves the 3 to the alt stack.
+	if len(trace[2].Stack) != 1 || len(trace[2].AltStack) != 1 ||
+		trace[2].AltStack[0][0] != 3 {
+
+		t.Errorf("unexpected stacks after OP_TOALTSTACK: %v %v",
+			trace[2].Stack, trace[2].AltStack)
+	}
+
+	// The OP_RETURN is skipped inside the false branch.
+	if trace[4].CondStack[0] != txscript.OpCondFalse {
+		t.Errorf("unexpected condition stack after OP_IF: %v",
+			trace[4].CondStack)
+	}
+	if trace[5].Opcode != "OP_RETURN" || trace[5].Executed {
+		t.Errorf("OP_RETURN not skipped: %+v", trace[5])
+	}
+	if len(trace[6].CondStack) != 0 {
+		t.Errorf("unexpected condition stack after OP_ENDIF: %v",
+			trace[6].CondStack)
+	}
+
+	// The final step leaves only the result of OP_EQUAL.
+	final := trace[len(trace)-1]
+	if len(final.Stack) != 1 || len(final.AltStack) != 0 {
+		t.Errorf("unexpected final stacks: %v %v", final.Stack,
+			final.AltStack)
+	}
+
+	// A failing script records the error of the step which failed.
+	pkScript = []byte{txscript.OP_RETURN}
+	vm, err = txscript.NewEngine(pkScript, tx, 0, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+	vm.EnableTrace()
+	if err := vm.Execute(); err == nil {
+		t.Fatalf("OP_RETURN script succeeded")
+	}
+	trace = vm.Trace()
+	if len(trace) != 3 || trace[2].Err != txscript.ErrStackEarlyReturn {
+		t.Fatalf("unexpected trace for failing script: %+v", trace)
+	}
+}
+
+// TestEngineTraceLimits ensures execution fails with ErrTraceTooLarge once the
+// trace would exceed the maximum number of steps or bytes of stack items.
+func TestEngineTraceLimits(t *testing.T) {
+	t.Parallel()
+
+	item := bytes.Repeat([]byte{0x01}, 2048)
+	itemScript, err := txscript.NewScriptBuilder().AddData(item).Script()
+	if err != nil {
+		t.Fatalf("failed to build signature script: %v", err)
+	}
+
+	// Duplicating a large item grows the stacks recorded by every step,
+	// while pushes skipped inside a false branch only add steps.
+	dups := bytes.Repeat([]byte{txscript.OP_DUP}, 100)
+	skipped := append([]byte{txscript.OP_0, txscript.OP_IF},
+		bytes.Repeat([]byte{txscript.OP_1}, txscript.MaxTraceSteps)...)
+	skipped = append(skipped, txscript.OP_ENDIF)
+	tests := []struct {
+		name      string
+		sigScript []byte
+		pkScript  []byte
+		wantSteps int
+	}{{
+		name:      "stack bytes",
+		sigScript: itemScript,
+		pkScript:  dups,
+		wantSteps: 90,
+	}, {
+		name:      "steps",
+		sigScript: []byte{txscript.OP_1},
+		pkScript:  skipped,
+		wantSteps: txscript.MaxTraceSteps,
+	}}
+
+	for _, test := range tests {
+		tx := &wire.MsgTx{
+			SerType: wire.TxSerializeFull,
+			Version: 1,
+			TxIn: []*wire.TxIn{{
+				PreviousOutPoint: wire.OutPoint{},
+				SignatureScript:  test.sigScript,
+				Sequence:         4294967295,
+			}},
+			TxOut: []*wire.TxOut{{Value: 1000000000}},
+		}
+		vm, err := txscript.NewEngine(test.pkScript, tx, 0, 0, 0, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create script: %v", test.name, err)
+		}
+		vm.EnableTrace()
+		err = vm.Execute()
+		if err != txscript.ErrTraceTooLarge {
+			t.Errorf("%s: unexpected error - got %v, want %v",
+				test.name, err, txscript.ErrTraceTooLarge)
+			continue
+		}
+		if len(vm.Trace()) != test.wantSteps {
+			t.Errorf("%s: unexpected number of steps - got %d, "+
+				"want %d", test.name, len(vm.Trace()),
+				test.wantSteps)
+		}
+	}
+}
@@ -136,6 +136,10 @@ var (
 	// is set and the script contains push operations that do not use
 	// the minimal opcode required.
 	ErrStackMinimalData = errors.New("non-minimally encoded script number")
+
+	// ErrTraceTooLarge is returned when tracing is enabled and recording a
+	// step would make the trace exceed MaxTraceSteps or MaxTraceBytes.
+	ErrTraceTooLarge = errors.New("script execution trace is too large")
 )
 
 // Engine script errors.
@@ -55,6 +55,12 @@ const (
 	CmdReject         = "reject"
 	CmdSendHeaders    = "sendheaders"
 	CmdFeeFilter      = "feefilter"
+	CmdSendCmpct      = "sendcmpct"
+	CmdCmpctBlock     = "cmpctblock"
+	CmdGetBlockTxn    = "getblocktxn"
+	CmdBlockTxn       = "blocktxn"
+	CmdSendAddrV2     = "sendaddrv2"
+	CmdAddrV2         = "addrv2"
 )
 
 // Message is an interface that describes a HC message.  A type that
@@ -145,6 +151,24 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdFeeFilter:
 		msg = &MsgFeeFilter{}
 
+	case CmdSendCmpct:
+		msg = &MsgSendCmpct{}
+
+	case CmdCmpctBlock:
+		msg = &MsgCmpctBlock{}
+
+	case CmdGetBlockTxn:
+		msg = &MsgGetBlockTxn{}
+
+	case CmdBlockTxn:
+		msg = &MsgBlockTxn{}
+
+	case CmdSendAddrV2:
+		msg = &MsgSendAddrV2{}
+
+	case CmdAddrV2:
+		msg = &MsgAddrV2{}
+
 	default:
 		return nil, fmt.Errorf("unhandled command [%s]", command)
 	}
@@ -0,0 +1,150 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestAddrV2Wire tests the MsgAddrV2 wire encode and decode for addresses on
+// all of the supported networks.
+func TestAddrV2Wire(t *testing.T) {
+	timestamp := time.Unix(0x495fab29, 0) // 2009-01-03 12:15:05 -0600 CST
+	torV3 := bytes.Repeat([]byte{0xab}, 32)
+	i2p := bytes.Repeat([]byte{0xcd}, 32)
+	cjdns := net.ParseIP("fc00::1")
+
+	addrs := []*NetAddress{
+		NewNetAddressTimestamp(timestamp, SFNodeNetwork,
+			net.ParseIP("127.0.0.1"), 8333),
+		NewNetAddressTimestamp(timestamp, SFNodeNetwork,
+			net.ParseIP("2001:db8::1"), 8333),
+		NewNetAddressTimestamp(timestamp, SFNodeNetwork,
+			net.ParseIP("fd87:d87e:eb43:102:304:506:708:90a"), 8333),
+		NewNetAddressV2(NetworkTorV3, torV3, 9108, SFNodeNetwork),
+		NewNetAddressV2(NetworkI2P, i2p, 0, SFNodeNetwork),
+		NewNetAddressV2(NetworkCJDNS, cjdns, 9108, SFNodeNetwork),
+	}
+	for _, na := range addrs[3:] {
+		na.Timestamp = timestamp
+	}
+	wantNetworks := []NetworkID{NetworkIPv4, NetworkIPv6, NetworkTorV2,
+		NetworkTorV3, NetworkI2P, NetworkCJDNS}
+	for i, na := range addrs {
+		if na.NetworkID() != wantNetworks[i] {
+			t.Errorf("NetworkID #%d: got %v, want %v", i,
+				na.NetworkID(), wantNetworks[i])
+		}
+		if na.NeedsAddrV2() != (i >= 3) {
+			t.Errorf("NeedsAddrV2 #%d: got %v", i, na.NeedsAddrV2())
+		}
+	}
+
+	msg := NewMsgAddrV2()
+	if err := msg.AddAddresses(addrs...); err != nil {
+		t.Fatalf("AddAddresses: unexpected error %v", err)
+	}
+
+	// Encoding and decoding must fail with protocol versions before addrv2.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, CompactBlocksVersion); err == nil {
+		t.Errorf("BtcEncode: did not receive expected error")
+	}
+
+	buf.Reset()
+	if err := msg.BtcEncode(&buf, ProtocolVersion); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	encoded := buf.Bytes()
+	var readMsg MsgAddrV2
+	err := readMsg.BtcDecode(bytes.NewReader(encoded), ProtocolVersion)
+	if err != nil {
+		t.Fatalf("BtcDecode: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Errorf("BtcDecode: mismatched message - got %v, want %v",
+			spew.Sdump(&readMsg), spew.Sdump(msg))
+	}
+	err = readMsg.BtcDecode(bytes.NewReader(encoded), CompactBlocksVersion)
+	if err == nil {
+		t.Errorf("BtcDecode: did not receive expected error")
+	}
+
+	// Addresses on unknown networks are dropped while addresses with the
+	// wrong size for a known network are rejected.
+	unknown := []byte{
+		0x02,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,       // Services
+		0x63,       // Unknown network id
+		0x02, 1, 2, // Address
+		0x20, 0x8d, // Port 8333 in big-endian
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,             // Services
+		0x01,             // IPv4 network id
+		0x04, 1, 2, 3, 4, // Address
+		0x20, 0x8d, // Port 8333 in big-endian
+	}
+	err = readMsg.BtcDecode(bytes.NewReader(unknown), ProtocolVersion)
+	if err != nil {
+		t.Fatalf("BtcDecode: unexpected error %v", err)
+	}
+	if len(readMsg.AddrList) != 1 ||
+		!readMsg.AddrList[0].IP.Equal(net.IPv4(1, 2, 3, 4)) {
+		t.Errorf("BtcDecode: unexpected addresses %v",
+			spew.Sdump(readMsg.AddrList))
+	}
+	badSize := []byte{
+		0x01,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,          // Services
+		0x04,          // Tor v3 network id
+		0x03, 1, 2, 3, // Address
+		0x20, 0x8d, // Port 8333 in big-endian
+	}
+	err = readMsg.BtcDecode(bytes.NewReader(badSize), ProtocolVersion)
+	if err == nil {
+		t.Errorf("BtcDecode: did not receive expected error")
+	}
+
+	// Addresses must have the size of their network to be encoded.
+	bad := NewMsgAddrV2()
+	bad.AddAddress(NewNetAddressV2(NetworkTorV3, []byte{1}, 0, 0))
+	if err := bad.BtcEncode(&buf, ProtocolVersion); err == nil {
+		t.Errorf("BtcEncode: did not receive expected error")
+	}
+}
+
+// TestSendAddrV2Wire tests the MsgSendAddrV2 wire encode and decode for
+// various protocol versions.
+func TestSendAddrV2Wire(t *testing.T) {
+	msg := NewMsgSendAddrV2()
+	if cmd := msg.Command(); cmd != "sendaddrv2" {
+		t.Errorf("Command: got %q, want %q", cmd, "sendaddrv2")
+	}
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, ProtocolVersion); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("BtcEncode: unexpected payload %x", buf.Bytes())
+	}
+	if err := msg.BtcDecode(&buf, ProtocolVersion); err != nil {
+		t.Errorf("BtcDecode: unexpected error %v", err)
+	}
+	if err := msg.BtcEncode(&buf, CompactBlocksVersion); err == nil {
+		t.Errorf("BtcEncode: did not receive expected error")
+	}
+	if err := msg.BtcDecode(&buf, CompactBlocksVersion); err == nil {
+		t.Errorf("BtcDecode: did not receive expected error")
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+)
+
+// MsgBlockTxn implements the Message interface and represents a hcd blocktxn
+// message.  It is used to deliver the transactions of a block which were
+// requested with a getblocktxn message (MsgGetBlockTxn), in the order they
+// were requested.
+//
+// This message was not added until protocol versions starting with
+// CompactBlocksVersion.
+type MsgBlockTxn struct {
+	BlockHash     chainhash.Hash
+	Transactions  []*MsgTx
+	STransactions []*MsgTx
+}
+
+// readBlockTxns deserializes a list of transactions from r.
+func readBlockTxns(r io.Reader, pver uint32) ([]*MsgTx, error) {
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return nil, err
+	}
+	maxTxPerTree := MaxTxPerTxTree(pver)
+	if count > maxTxPerTree {
+		str := fmt.Sprintf("too many transactions for message "+
+			"[count %d, max %d]", count, maxTxPerTree)
+		return nil, messageError("MsgBlockTxn.BtcDecode", str)
+	}
+
+	txs := make([]*MsgTx, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var tx MsgTx
+		if err := tx.BtcDecode(r, pver); err != nil {
+			return nil, err
+		}
+		txs = append(txs, &tx)
+	}
+	return txs, nil
+}
+
+// writeBlockTxns serializes the passed transactions to w.
+func writeBlockTxns(w io.Writer, pver uint32, txs []*MsgTx) error {
+	err := WriteVarInt(w, pver, uint64(len(txs)))
+	if err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		if err := tx.BtcEncode(w, pver); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BtcDecode decodes r using the hcd protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgBlockTxn) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("blocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgBlockTxn.BtcDecode", str)
+	}
+
+	err := readElement(r, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+	msg.Transactions, err = readBlockTxns(r, pver)
+	if err != nil {
+		return err
+	}
+	msg.STransactions, err = readBlockTxns(r, pver)
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the hcd protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgBlockTxn) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("blocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgBlockTxn.BtcEncode", str)
+	}
+
+	err := writeElement(w, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+	err = writeBlockTxns(w, pver, msg.Transactions)
+	if err != nil {
+		return err
+	}
+	return writeBlockTxns(w, pver, msg.STransactions)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgBlockTxn) Command() string {
+	return CmdBlockTxn
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgBlockTxn) MaxPayloadLength(pver uint32) uint32 {
+	// The transactions are a subset of those of a block.
+	return chainhash.HashSize + MaxBlockPayload
+}
+
+// NewMsgBlockTxn returns a new hcd blocktxn message which delivers the passed
+// transactions of the regular and stake transaction trees of the block with
+// the passed hash.  See MsgBlockTxn for details.
+func NewMsgBlockTxn(blockHash *chainhash.Hash, txs, sTxs []*MsgTx) *MsgBlockTxn {
+	return &MsgBlockTxn{
+		BlockHash:     *blockHash,
+		Transactions:  txs,
+		STransactions: sTxs,
+	}
+}
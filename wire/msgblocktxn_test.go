@@ -0,0 +1,48 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestBlockTxn tests the MsgBlockTxn wire encode and decode.
+func TestBlockTxn(t *testing.T) {
+	pver := ProtocolVersion
+	block := cmpctTestBlock()
+	blockHash := block.BlockHash()
+	msg := NewMsgBlockTxn(&blockHash, block.Transactions[1:],
+		block.STransactions)
+
+	// Ensure the command is expected value.
+	wantCmd := "blocktxn"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	var readMsg MsgBlockTxn
+	if err := readMsg.BtcDecode(&buf, pver); err != nil {
+		t.Fatalf("BtcDecode: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Errorf("BtcDecode: mismatched message - got %v, want %v",
+			spew.Sdump(&readMsg), spew.Sdump(msg))
+	}
+
+	// The message is not valid for older protocol versions.
+	if err := msg.BtcEncode(&buf, FeeFilterVersion); err == nil {
+		t.Errorf("BtcEncode: did not fail for protocol version %d",
+			FeeFilterVersion)
+	}
+}
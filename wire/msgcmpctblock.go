@@ -0,0 +1,299 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+)
+
+// CmpctBlockVersion is the version of the compact block encoding used by the
+// cmpctblock, getblocktxn and blocktxn messages.
+const CmpctBlockVersion = 1
+
+// ShortTxIDSize is the number of bytes of a short transaction ID.
+const ShortTxIDSize = 6
+
+// shortTxIDMask is the mask which selects the bits of a short transaction ID.
+const shortTxIDMask = 1<<(ShortTxIDSize*8) - 1
+
+// PrefilledTx is a transaction which is sent in full as part of a compact
+// block along with its index in the transaction tree of the block.
+type PrefilledTx struct {
+	Index uint32
+	Tx    *MsgTx
+}
+
+// MsgCmpctBlock implements the Message interface and represents a hcd
+// cmpctblock message.  It is used to relay a block to a peer which is likely
+// to have most of its transactions in its memory pool already.  Each
+// transaction of both the regular and the stake transaction trees is either
+// identified by a short transaction ID or prefilled in full.  The peer
+// reconstructs the block from its memory pool and requests any transactions it
+// is missing with a getblocktxn message (MsgGetBlockTxn).
+//
+// The transactions of a tree are ordered by filling the indexes which are not
+// taken by prefilled transactions with the short transaction IDs in order.
+//
+// This message was not added until protocol versions starting with
+// CompactBlocksVersion.
+type MsgCmpctBlock struct {
+	Header        BlockHeader
+	Nonce         uint64
+	ShortIDs      []uint64
+	PrefilledTxs  []PrefilledTx
+	SShortIDs     []uint64
+	PrefilledSTxs []PrefilledTx
+}
+
+// ShortIDKeys returns the SipHash keys used to calculate the short
+// transaction IDs of the compact block.  They are derived from the hash of the
+// block header and the nonce so they differ for every block and connection.
+func (msg *MsgCmpctBlock) ShortIDKeys() (uint64, uint64) {
+	var buf bytes.Buffer
+	buf.Grow(MaxBlockHeaderPayload + 8)
+	_ = writeBlockHeader(&buf, 0, &msg.Header)
+	_ = writeElement(&buf, msg.Nonce)
+	hash := chainhash.HashB(buf.Bytes())
+	return littleEndian.Uint64(hash[0:8]), littleEndian.Uint64(hash[8:16])
+}
+
+// ShortTxID returns the short transaction ID of the transaction with the
+// passed hash for the SipHash keys returned by ShortIDKeys.
+func ShortTxID(k0, k1 uint64, txHash *chainhash.Hash) uint64 {
+	return sipHash(k0, k1, txHash[:]) & shortTxIDMask
+}
+
+// NumTransactions returns the number of transactions in the regular
+// transaction tree of the block.
+func (msg *MsgCmpctBlock) NumTransactions() int {
+	return len(msg.ShortIDs) + len(msg.PrefilledTxs)
+}
+
+// NumSTransactions returns the number of transactions in the stake
+// transaction tree of the block.
+func (msg *MsgCmpctBlock) NumSTransactions() int {
+	return len(msg.SShortIDs) + len(msg.PrefilledSTxs)
+}
+
+// writeShortIDs serializes the passed short transaction IDs to w.
+func writeShortIDs(w io.Writer, pver uint32, ids []uint64) error {
+	err := WriteVarInt(w, pver, uint64(len(ids)))
+	if err != nil {
+		return err
+	}
+
+	var buf [8]byte
+	for _, id := range ids {
+		littleEndian.PutUint64(buf[:], id)
+		if _, err := w.Write(buf[:ShortTxIDSize]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readShortIDs deserializes a list of short transaction IDs from r.
+func readShortIDs(r io.Reader, pver uint32, op string) ([]uint64, error) {
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return nil, err
+	}
+	maxTxPerTree := MaxTxPerTxTree(pver)
+	if count > maxTxPerTree {
+		str := fmt.Sprintf("too many short transaction ids for message "+
+			"[count %d, max %d]", count, maxTxPerTree)
+		return nil, messageError(op, str)
+	}
+
+	ids := make([]uint64, 0, count)
+	var buf [8]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, buf[:ShortTxIDSize]); err != nil {
+			return nil, err
+		}
+		ids = append(ids, littleEndian.Uint64(buf[:]))
+	}
+	return ids, nil
+}
+
+// writePrefilledTxs serializes the passed prefilled transactions to w.  Their
+// indexes must be in increasing order and are encoded differentially.
+func writePrefilledTxs(w io.Writer, pver uint32, txs []PrefilledTx, op string) error {
+	indexes := make([]uint32, 0, len(txs))
+	for _, ptx := range txs {
+		indexes = append(indexes, ptx.Index)
+	}
+	diffs, err := diffEncodeIndexes(indexes, op)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(txs)))
+	if err != nil {
+		return err
+	}
+	for i, ptx := range txs {
+		err := WriteVarInt(w, pver, uint64(diffs[i]))
+		if err != nil {
+			return err
+		}
+		if err := ptx.Tx.BtcEncode(w, pver); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readPrefilledTxs deserializes a list of prefilled transactions from r.
+func readPrefilledTxs(r io.Reader, pver uint32, op string) ([]PrefilledTx, error) {
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return nil, err
+	}
+	maxTxPerTree := MaxTxPerTxTree(pver)
+	if count > maxTxPerTree {
+		str := fmt.Sprintf("too many prefilled transactions for "+
+			"message [count %d, max %d]", count, maxTxPerTree)
+		return nil, messageError(op, str)
+	}
+
+	txs := make([]PrefilledTx, 0, count)
+	var index uint64
+	for i := uint64(0); i < count; i++ {
+		diff, err := ReadVarInt(r, pver)
+		if err != nil {
+			return nil, err
+		}
+		index, err = diffDecodeIndex(index, diff, i == 0, maxTxPerTree, op)
+		if err != nil {
+			return nil, err
+		}
+
+		var tx MsgTx
+		if err := tx.BtcDecode(r, pver); err != nil {
+			return nil, err
+		}
+		txs = append(txs, PrefilledTx{Index: uint32(index), Tx: &tx})
+	}
+	return txs, nil
+}
+
+// BtcDecode decodes r using the hcd protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	err := readBlockHeader(r, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+	err = readElement(r, &msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	const op = "MsgCmpctBlock.BtcDecode"
+	msg.ShortIDs, err = readShortIDs(r, pver, op)
+	if err != nil {
+		return err
+	}
+	msg.PrefilledTxs, err = readPrefilledTxs(r, pver, op)
+	if err != nil {
+		return err
+	}
+	msg.SShortIDs, err = readShortIDs(r, pver, op)
+	if err != nil {
+		return err
+	}
+	msg.PrefilledSTxs, err = readPrefilledTxs(r, pver, op)
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the hcd protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcEncode", str)
+	}
+
+	err := writeBlockHeader(w, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+	err = writeElement(w, msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	const op = "MsgCmpctBlock.BtcEncode"
+	err = writeShortIDs(w, pver, msg.ShortIDs)
+	if err != nil {
+		return err
+	}
+	err = writePrefilledTxs(w, pver, msg.PrefilledTxs, op)
+	if err != nil {
+		return err
+	}
+	err = writeShortIDs(w, pver, msg.SShortIDs)
+	if err != nil {
+		return err
+	}
+	return writePrefilledTxs(w, pver, msg.PrefilledSTxs, op)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCmpctBlock) Command() string {
+	return CmdCmpctBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) MaxPayloadLength(pver uint32) uint32 {
+	// Short transaction IDs are smaller than any transaction, so a compact
+	// block is never larger than the block it describes apart from the
+	// nonce and the indexes of the prefilled transactions.
+	return MaxBlockPayload + 8 +
+		uint32(MaxTxPerTxTree(pver))*2*MaxVarIntPayload
+}
+
+// NewMsgCmpctBlock returns a new hcd cmpctblock message for the passed block
+// which uses the passed nonce to derive its short transaction IDs.  The
+// coinbase is prefilled since the receiver can never have it, while all other
+// transactions of both trees, including the votes, are identified by their
+// short transaction IDs.  See MsgCmpctBlock for details.
+func NewMsgCmpctBlock(block *MsgBlock, nonce uint64) *MsgCmpctBlock {
+	msg := &MsgCmpctBlock{
+		Header: block.Header,
+		Nonce:  nonce,
+	}
+	k0, k1 := msg.ShortIDKeys()
+
+	msg.ShortIDs = make([]uint64, 0, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		if i == 0 {
+			msg.PrefilledTxs = []PrefilledTx{{Index: 0, Tx: tx}}
+			continue
+		}
+		txHash := tx.TxHash()
+		msg.ShortIDs = append(msg.ShortIDs, ShortTxID(k0, k1, &txHash))
+	}
+	msg.SShortIDs = make([]uint64, 0, len(block.STransactions))
+	for _, tx := range block.STransactions {
+		txHash := tx.TxHash()
+		msg.SShortIDs = append(msg.SShortIDs, ShortTxID(k0, k1, &txHash))
+	}
+	return msg
+}
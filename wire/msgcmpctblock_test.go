@@ -0,0 +1,191 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+)
+
+// cmpctTestTx returns a simple transaction which spends the passed outpoint
+// index so that every call with a different index has a different hash.
+func cmpctTestTx(index uint32) *MsgTx {
+	tx := NewMsgTx()
+	prevOut := NewOutPoint(&chainhash.Hash{0x01}, index, TxTreeRegular)
+	tx.AddTxIn(NewTxIn(prevOut, []byte{0x51}))
+	tx.AddTxOut(NewTxOut(int64(index)*1000, []byte{0x76, 0xa9}))
+	return tx
+}
+
+// cmpctTestBlock returns a block with three regular and two stake
+// transactions.
+func cmpctTestBlock() *MsgBlock {
+	block := &MsgBlock{
+		Header: BlockHeader{
+			Version:   1,
+			Height:    100,
+			Timestamp: time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST
+			Nonce:     0x12345678,
+		},
+	}
+	for i := uint32(0); i < 3; i++ {
+		block.AddTransaction(cmpctTestTx(i))
+	}
+	for i := uint32(10); i < 12; i++ {
+		block.AddSTransaction(cmpctTestTx(i))
+	}
+	return block
+}
+
+// TestSipHash ensures the SipHash-2-4 implementation matches the test vectors
+// of the reference implementation.
+func TestSipHash(t *testing.T) {
+	const k0, k1 = 0x0706050403020100, 0x0f0e0d0c0b0a0908
+	data := make([]byte, 15)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	tests := []struct {
+		data []byte
+		want uint64
+	}{
+		{nil, 0x726fdb47dd0e0e31},
+		{data[:8], 0x93f5f5799a932462},
+		{data, 0xa129ca6149be45e5},
+	}
+	for i, test := range tests {
+		got := sipHash(k0, k1, test.data)
+		if got != test.want {
+			t.Errorf("sipHash #%d: got %x, want %x", i, got,
+				test.want)
+		}
+	}
+}
+
+// TestCmpctBlock tests the MsgCmpctBlock API along with its wire encoding and
+// decoding.
+func TestCmpctBlock(t *testing.T) {
+	pver := ProtocolVersion
+	block := cmpctTestBlock()
+	msg := NewMsgCmpctBlock(block, 0xdeadbeef)
+
+	// Ensure the command is expected value.
+	wantCmd := "cmpctblock"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgCmpctBlock: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure the coinbase is prefilled and all other transactions of both
+	// trees are identified by their short transaction IDs.
+	if msg.NumTransactions() != len(block.Transactions) ||
+		msg.NumSTransactions() != len(block.STransactions) {
+		t.Fatalf("NewMsgCmpctBlock: wrong number of transactions - "+
+			"got %d/%d", msg.NumTransactions(),
+			msg.NumSTransactions())
+	}
+	if len(msg.PrefilledTxs) != 1 || msg.PrefilledTxs[0].Index != 0 ||
+		msg.PrefilledTxs[0].Tx != block.Transactions[0] {
+		t.Errorf("NewMsgCmpctBlock: coinbase is not prefilled")
+	}
+	k0, k1 := msg.ShortIDKeys()
+	for i, tx := range block.STransactions {
+		txHash := tx.TxHash()
+		if id := ShortTxID(k0, k1, &txHash); id != msg.SShortIDs[i] {
+			t.Errorf("NewMsgCmpctBlock: wrong short id for stake "+
+				"transaction %d - got %x, want %x", i,
+				msg.SShortIDs[i], id)
+		}
+		if msg.SShortIDs[i]>>(ShortTxIDSize*8) != 0 {
+			t.Errorf("NewMsgCmpctBlock: short id %x is too large",
+				msg.SShortIDs[i])
+		}
+	}
+
+	// The keys, and therefore the short ids, must depend on the nonce.
+	other := NewMsgCmpctBlock(block, 0)
+	if reflect.DeepEqual(other.ShortIDs, msg.ShortIDs) {
+		t.Errorf("NewMsgCmpctBlock: short ids do not depend on nonce")
+	}
+
+	// Test encode and decode with latest protocol version.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	if uint32(buf.Len()) > msg.MaxPayloadLength(pver) {
+		t.Errorf("BtcEncode: payload of %d bytes exceeds max of %d",
+			buf.Len(), msg.MaxPayloadLength(pver))
+	}
+	var readMsg MsgCmpctBlock
+	if err := readMsg.BtcDecode(&buf, pver); err != nil {
+		t.Fatalf("BtcDecode: unexpected error %v", err)
+	}
+	if len(readMsg.PrefilledSTxs) == 0 {
+		readMsg.PrefilledSTxs = nil
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Errorf("BtcDecode: mismatched message - got %v, want %v",
+			spew.Sdump(&readMsg), spew.Sdump(msg))
+	}
+
+	// Prefilled transactions must be in increasing order.
+	msg.PrefilledTxs = append(msg.PrefilledTxs, msg.PrefilledTxs[0])
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("BtcEncode: did not fail with unordered prefilled " +
+			"transactions")
+	}
+
+	// The message is not valid for older protocol versions.
+	pver = FeeFilterVersion
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("BtcEncode: did not fail for protocol version %d",
+			pver)
+	}
+	if err := readMsg.BtcDecode(&buf, pver); err == nil {
+		t.Errorf("BtcDecode: did not fail for protocol version %d",
+			pver)
+	}
+}
+
+// TestPrefilledTxsOverflow ensures a differentially encoded prefilled
+// transaction index which wraps around to a previous index is rejected instead
+// of being decoded as a repeated index.
+func TestPrefilledTxsOverflow(t *testing.T) {
+	pver := ProtocolVersion
+	tx := cmpctTestTx(0)
+
+	// Encode two prefilled transactions where the second difference is the
+	// maximum uint64, which wraps the second index back to the first.
+	var buf bytes.Buffer
+	WriteVarInt(&buf, pver, 2)
+	WriteVarInt(&buf, pver, 0)
+	tx.BtcEncode(&buf, pver)
+	WriteVarInt(&buf, pver, ^uint64(0))
+	tx.BtcEncode(&buf, pver)
+
+	txs, err := readPrefilledTxs(&buf, pver, "test")
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("readPrefilledTxs: unexpected error %v for wrapped "+
+			"index (decoded %d transactions)", err, len(txs))
+	}
+
+	// The same applies to the indexes of a getblocktxn message.
+	buf.Reset()
+	WriteVarInt(&buf, pver, 2)
+	WriteVarInt(&buf, pver, 5)
+	WriteVarInt(&buf, pver, ^uint64(0))
+	indexes, err := readTxIndexes(&buf, pver, "test")
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("readTxIndexes: unexpected error %v for wrapped "+
+			"index (decoded %v)", err, indexes)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+)
+
+// diffEncodeIndexes returns the passed transaction indexes encoded
+// differentially, where every index after the first is replaced by its
+// distance to the previous index minus one.  The indexes must be strictly
+// increasing.
+func diffEncodeIndexes(indexes []uint32, op string) ([]uint32, error) {
+	diffs := make([]uint32, 0, len(indexes))
+	for i, index := range indexes {
+		if i == 0 {
+			diffs = append(diffs, index)
+			continue
+		}
+		prev := indexes[i-1]
+		if index <= prev {
+			str := fmt.Sprintf("transaction indexes are not in "+
+				"increasing order [%d after %d]", index, prev)
+			return nil, messageError(op, str)
+		}
+		diffs = append(diffs, index-prev-1)
+	}
+	return diffs, nil
+}
+
+// diffDecodeIndex returns the transaction index which follows the passed
+// previous index for the passed differentially encoded value.  The first index
+// of a list is encoded as is.  An error is returned when the index is not
+// below the passed maximum.
+//
+// The difference is bounded by the maximum before it is added to the previous
+// index, which is itself below the maximum, so the sum can't overflow and the
+// decoded indexes are always strictly increasing.
+func diffDecodeIndex(prev, diff uint64, first bool, max uint64, op string) (uint64, error) {
+	index := diff
+	if !first {
+		if diff >= max || prev >= max {
+			str := fmt.Sprintf("transaction index out of range "+
+				"[max %d]", max)
+			return 0, messageError(op, str)
+		}
+		index = prev + diff + 1
+	}
+	if index >= max {
+		str := fmt.Sprintf("transaction index out of range [max %d]",
+			max)
+		return 0, messageError(op, str)
+	}
+	return index, nil
+}
+
+// writeTxIndexes serializes the passed transaction indexes to w.
+func writeTxIndexes(w io.Writer, pver uint32, indexes []uint32, op string) error {
+	diffs, err := diffEncodeIndexes(indexes, op)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(diffs)))
+	if err != nil {
+		return err
+	}
+	for _, diff := range diffs {
+		if err := WriteVarInt(w, pver, uint64(diff)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTxIndexes deserializes a list of transaction indexes from r.
+func readTxIndexes(r io.Reader, pver uint32, op string) ([]uint32, error) {
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return nil, err
+	}
+	maxTxPerTree := MaxTxPerTxTree(pver)
+	if count > maxTxPerTree {
+		str := fmt.Sprintf("too many transaction indexes for message "+
+			"[count %d, max %d]", count, maxTxPerTree)
+		return nil, messageError(op, str)
+	}
+
+	indexes := make([]uint32, 0, count)
+	var index uint64
+	for i := uint64(0); i < count; i++ {
+		diff, err := ReadVarInt(r, pver)
+		if err != nil {
+			return nil, err
+		}
+		index, err = diffDecodeIndex(index, diff, i == 0, maxTxPerTree, op)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// MsgGetBlockTxn implements the Message interface and represents a hcd
+// getblocktxn message.  It is used to request the transactions of a block
+// which was relayed with a cmpctblock message (MsgCmpctBlock) that could not
+// be found in the memory pool.  The transactions are identified by their
+// indexes in the regular and stake transaction trees of the block, and are
+// returned via a blocktxn message (MsgBlockTxn).
+//
+// This message was not added until protocol versions starting with
+// CompactBlocksVersion.
+type MsgGetBlockTxn struct {
+	BlockHash  chainhash.Hash
+	TxIndexes  []uint32
+	STxIndexes []uint32
+}
+
+// BtcDecode decodes r using the hcd protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcDecode(r io.Reader, pver uint32) error {
+	const op = "MsgGetBlockTxn.BtcDecode"
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("getblocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError(op, str)
+	}
+
+	err := readElement(r, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+	msg.TxIndexes, err = readTxIndexes(r, pver, op)
+	if err != nil {
+		return err
+	}
+	msg.STxIndexes, err = readTxIndexes(r, pver, op)
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the hcd protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcEncode(w io.Writer, pver uint32) error {
+	const op = "MsgGetBlockTxn.BtcEncode"
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("getblocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError(op, str)
+	}
+
+	err := writeElement(w, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+	err = writeTxIndexes(w, pver, msg.TxIndexes, op)
+	if err != nil {
+		return err
+	}
+	return writeTxIndexes(w, pver, msg.STxIndexes, op)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetBlockTxn) Command() string {
+	return CmdGetBlockTxn
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + two lists of indexes, each with a count followed by at
+	// most the maximum number of transactions per tree.
+	return chainhash.HashSize +
+		2*(MaxVarIntPayload+uint32(MaxTxPerTxTree(pver))*MaxVarIntPayload)
+}
+
+// NewMsgGetBlockTxn returns a new hcd getblocktxn message which requests the
+// transactions at the passed indexes of the regular and stake transaction
+// trees of the block with the passed hash.  See MsgGetBlockTxn for details.
+func NewMsgGetBlockTxn(blockHash *chainhash.Hash, txIndexes, sTxIndexes []uint32) *MsgGetBlockTxn {
+	return &MsgGetBlockTxn{
+		BlockHash:  *blockHash,
+		TxIndexes:  txIndexes,
+		STxIndexes: sTxIndexes,
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/james-ray/hcd/chaincfg/chainhash"
+)
+
+// TestGetBlockTxnWire tests the MsgGetBlockTxn wire encode and decode for
+// various indexes.
+func TestGetBlockTxnWire(t *testing.T) {
+	pver := ProtocolVersion
+	hash := chainhash.Hash{0x01, 0x02}
+
+	tests := []struct {
+		in  *MsgGetBlockTxn
+		buf []byte
+	}{
+		{
+			NewMsgGetBlockTxn(&hash, nil, nil),
+			append(hash[:], 0x00, 0x00),
+		},
+		{
+			NewMsgGetBlockTxn(&hash, []uint32{1, 2, 5},
+				[]uint32{0, 300}),
+			append(hash[:], 0x03, 0x01, 0x00, 0x02, 0x02, 0x00,
+				0xfd, 0x2b, 0x01),
+		},
+	}
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := test.in.BtcEncode(&buf, pver); err != nil {
+			t.Errorf("BtcEncode #%d: unexpected error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d:\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		var msg MsgGetBlockTxn
+		err := msg.BtcDecode(bytes.NewReader(test.buf), pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d: unexpected error %v", i, err)
+			continue
+		}
+		if len(msg.TxIndexes) == 0 {
+			msg.TxIndexes = nil
+		}
+		if len(msg.STxIndexes) == 0 {
+			msg.STxIndexes = nil
+		}
+		if !reflect.DeepEqual(&msg, test.in) {
+			t.Errorf("BtcDecode #%d:\n got: %s want: %s", i,
+				spew.Sdump(&msg), spew.Sdump(test.in))
+		}
+	}
+
+	// Indexes must be in increasing order.
+	msg := NewMsgGetBlockTxn(&hash, []uint32{2, 2}, nil)
+	if err := msg.BtcEncode(&bytes.Buffer{}, pver); err == nil {
+		t.Errorf("BtcEncode: did not fail with duplicate indexes")
+	}
+
+	// Indexes which overflow the maximum number of transactions must be
+	// rejected.
+	buf := append(hash[:], 0x02, 0xfe, 0xff, 0xff, 0xff, 0x7f, 0xfe,
+		0xff, 0xff, 0xff, 0x7f)
+	err := msg.BtcDecode(bytes.NewReader(buf), pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: unexpected error for out of range "+
+			"indexes: %v", err)
+	}
+}
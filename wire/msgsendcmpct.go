@@ -0,0 +1,72 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgSendCmpct implements the Message interface and represents a hcd
+// sendcmpct message.  It is used to signal support for compact block relay
+// with the given version and to select the announcement mode.  When Announce
+// is set, the sender requests the peer to relay new blocks to it with
+// cmpctblock messages (MsgCmpctBlock) right away, without announcing them
+// first, which is known as the high-bandwidth mode.  Otherwise, new blocks are
+// announced as usual and compact blocks are only sent when requested.
+//
+// This message was not added until protocol versions starting with
+// CompactBlocksVersion.
+type MsgSendCmpct struct {
+	Announce bool
+	Version  uint64
+}
+
+// BtcDecode decodes r using the hcd protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSendCmpct) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("sendcmpct message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgSendCmpct.BtcDecode", str)
+	}
+
+	return readElements(r, &msg.Announce, &msg.Version)
+}
+
+// BtcEncode encodes the receiver to w using the hcd protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSendCmpct) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("sendcmpct message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgSendCmpct.BtcEncode", str)
+	}
+
+	return writeElements(w, msg.Announce, msg.Version)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSendCmpct) Command() string {
+	return CmdSendCmpct
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSendCmpct) MaxPayloadLength(pver uint32) uint32 {
+	// Announce flag 1 byte + version 8 bytes.
+	return 9
+}
+
+// NewMsgSendCmpct returns a new hcd sendcmpct message that conforms to the
+// Message interface using the passed parameters.  See MsgSendCmpct for
+// details.
+func NewMsgSendCmpct(announce bool, version uint64) *MsgSendCmpct {
+	return &MsgSendCmpct{
+		Announce: announce,
+		Version:  version,
+	}
+}
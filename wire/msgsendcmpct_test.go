@@ -0,0 +1,73 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// TestSendCmpctWire tests the MsgSendCmpct wire encode and decode for various
+// protocol versions.
+func TestSendCmpctWire(t *testing.T) {
+	tests := []struct {
+		in   *MsgSendCmpct // Message to encode
+		buf  []byte        // Wire encoding
+		pver uint32        // Protocol version for wire encoding
+		fail bool          // Whether encoding and decoding should fail
+	}{
+		{
+			NewMsgSendCmpct(true, CmpctBlockVersion),
+			[]byte{0x01, 0x01, 0, 0, 0, 0, 0, 0, 0},
+			ProtocolVersion,
+			false,
+		},
+		{
+			NewMsgSendCmpct(false, CmpctBlockVersion),
+			[]byte{0x00, 0x01, 0, 0, 0, 0, 0, 0, 0},
+			CompactBlocksVersion,
+			false,
+		},
+		{
+			NewMsgSendCmpct(false, CmpctBlockVersion),
+			nil,
+			FeeFilterVersion,
+			true,
+		},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver)
+		if (err != nil) != test.fail {
+			t.Errorf("BtcEncode #%d: unexpected error %v", i, err)
+			continue
+		}
+		if test.fail {
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d: got %x, want %x", i,
+				buf.Bytes(), test.buf)
+			continue
+		}
+		if uint32(buf.Len()) != test.in.MaxPayloadLength(test.pver) {
+			t.Errorf("MaxPayloadLength #%d: got %d, want %d", i,
+				test.in.MaxPayloadLength(test.pver), buf.Len())
+		}
+
+		var msg MsgSendCmpct
+		err = msg.BtcDecode(bytes.NewReader(test.buf), test.pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.in) {
+			t.Errorf("BtcDecode #%d: got %v, want %v", i, msg,
+				test.in)
+		}
+	}
+}
@@ -7,8 +7,10 @@
 package wire
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"time"
@@ -30,8 +32,78 @@ func maxNetAddressPayload(pver uint32) uint32 {
 	return plen
 }
 
+// NetworkID identifies the network an address belongs to in addrv2 messages.
+// The values are the network ids defined by BIP155.
+type NetworkID uint8
+
+const (
+	// NetworkIPv4 identifies an IPv4 address.
+	NetworkIPv4 NetworkID = 1
+
+	// NetworkIPv6 identifies an IPv6 address.
+	NetworkIPv6 NetworkID = 2
+
+	// NetworkTorV2 identifies a Tor v2 hidden service address.  Such
+	// addresses are stored in IP using the OnionCat mapping.
+	NetworkTorV2 NetworkID = 3
+
+	// NetworkTorV3 identifies a Tor v3 hidden service address.
+	NetworkTorV3 NetworkID = 4
+
+	// NetworkI2P identifies an I2P address.
+	NetworkI2P NetworkID = 5
+
+	// NetworkCJDNS identifies a CJDNS address.
+	NetworkCJDNS NetworkID = 6
+)
+
+// Map of network ids back to their constant names for pretty printing.
+var networkIDStrings = map[NetworkID]string{
+	NetworkIPv4:  "NetworkIPv4",
+	NetworkIPv6:  "NetworkIPv6",
+	NetworkTorV2: "NetworkTorV2",
+	NetworkTorV3: "NetworkTorV3",
+	NetworkI2P:   "NetworkI2P",
+	NetworkCJDNS: "NetworkCJDNS",
+}
+
+// String returns the NetworkID in human-readable form.
+func (id NetworkID) String() string {
+	if s, ok := networkIDStrings[id]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown NetworkID (%d)", uint8(id))
+}
+
+// addrSize returns the size of the encoded addresses of the network and
+// whether or not the network is known.
+func (id NetworkID) addrSize() (int, bool) {
+	switch id {
+	case NetworkIPv4:
+		return 4, true
+	case NetworkIPv6, NetworkCJDNS:
+		return 16, true
+	case NetworkTorV2:
+		return 10, true
+	case NetworkTorV3, NetworkI2P:
+		return 32, true
+	}
+	return 0, false
+}
+
+// maxAddrV2Size is the maximum size of an encoded address in an addrv2
+// message as defined by BIP155.
+const maxAddrV2Size = 512
+
+// onionCatPrefix is the prefix of the IPv6 range used to map Tor v2 hidden
+// service addresses to IPv6 addresses (fd87:d87e:eb43::/48).
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
 // NetAddress defines information about a peer on the network including the time
 // it was last seen, the services it supports, its IP address, and port.
+//
+// Addresses on networks which can't be represented by an IPv4 or IPv6 address
+// set Network and can only be relayed with addrv2 messages (MsgAddrV2).
 type NetAddress struct {
 	// Last time the address was seen.  This is, unfortunately, encoded as a
 	// uint32 on the wire and therefore is limited to 2106.  This field is
@@ -48,6 +120,51 @@ type NetAddress struct {
 	// Port the peer is using.  This is encoded in big endian on the wire
 	// which differs from most everything else.
 	Port uint16
+
+	// Network is the network of the address when it is not an IPv4 or
+	// IPv6 address, namely NetworkTorV3, NetworkI2P or NetworkCJDNS.  It
+	// is zero otherwise.  CJDNS addresses are stored in IP.
+	Network NetworkID
+
+	// Addr is the encoded address for the Tor v3 and I2P networks.  It is
+	// the ed25519 public key of a Tor v3 hidden service and the SHA256
+	// hash of an I2P destination.
+	Addr []byte
+}
+
+// NetworkID returns the network the address belongs to.
+func (na *NetAddress) NetworkID() NetworkID {
+	if na.Network != 0 {
+		return na.Network
+	}
+	if na.IP.To4() != nil {
+		return NetworkIPv4
+	}
+	if bytes.HasPrefix(na.IP.To16(), onionCatPrefix) {
+		return NetworkTorV2
+	}
+	return NetworkIPv6
+}
+
+// NeedsAddrV2 returns whether or not the address can only be relayed with
+// addrv2 messages since it can't be represented by an IPv6 address.
+func (na *NetAddress) NeedsAddrV2() bool {
+	return na.Network != 0
+}
+
+// NewNetAddressV2 returns a new NetAddress on the provided network which is not
+// IPv4 or IPv6 using the provided encoded address, port, and supported services
+// with defaults for the remaining fields.  CJDNS addresses are stored in the IP
+// of the returned address.
+func NewNetAddressV2(network NetworkID, addr []byte, port uint16, services ServiceFlag) *NetAddress {
+	na := NewNetAddressIPPort(nil, port, services)
+	na.Network = network
+	if network == NetworkCJDNS {
+		na.IP = net.IP(addr)
+	} else {
+		na.Addr = addr
+	}
+	return na
 }
 
 // HasService returns whether the specified service is supported by the address.
@@ -160,3 +277,109 @@ func writeNetAddress(w io.Writer, pver uint32, na *NetAddress, ts bool) error {
 	// Sigh.  Hcd protocol mixes little and big endian.
 	return binary.Write(w, bigEndian, na.Port)
 }
+
+// readNetAddressV2 reads an encoded NetAddress from r as it appears in addrv2
+// messages.  Addresses on unknown networks are read but have the unknown
+// network set and no address.  An error is returned when the address of a
+// known network has an invalid size.
+func readNetAddressV2(r io.Reader, pver uint32, na *NetAddress) error {
+	err := readElement(r, (*uint32Time)(&na.Timestamp))
+	if err != nil {
+		return err
+	}
+	services, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	var network NetworkID
+	if err := readElement(r, (*uint8)(&network)); err != nil {
+		return err
+	}
+	addr, err := ReadVarBytes(r, pver, maxAddrV2Size, "addrv2 address")
+	if err != nil {
+		return err
+	}
+	// Sigh.  Hcd protocol mixes little and big endian.
+	port, err := binarySerializer.Uint16(r, bigEndian)
+	if err != nil {
+		return err
+	}
+
+	*na = NetAddress{
+		Timestamp: na.Timestamp,
+		Services:  ServiceFlag(services),
+		Port:      port,
+	}
+	size, known := network.addrSize()
+	if !known {
+		na.Network = network
+		return nil
+	}
+	if len(addr) != size {
+		str := fmt.Sprintf("%v address is %d bytes instead of %d",
+			network, len(addr), size)
+		return messageError("readNetAddressV2", str)
+	}
+	switch network {
+	case NetworkIPv4:
+		na.IP = net.IPv4(addr[0], addr[1], addr[2], addr[3])
+	case NetworkIPv6:
+		na.IP = net.IP(addr)
+	case NetworkTorV2:
+		na.IP = net.IP(append(append([]byte{}, onionCatPrefix...),
+			addr...))
+	case NetworkCJDNS:
+		if addr[0] != 0xfc {
+			str := "CJDNS address is not in the fc00::/8 range"
+			return messageError("readNetAddressV2", str)
+		}
+		na.Network = network
+		na.IP = net.IP(addr)
+	default:
+		na.Network = network
+		na.Addr = addr
+	}
+	return nil
+}
+
+// writeNetAddressV2 serializes a NetAddress to w as it appears in addrv2
+// messages.
+func writeNetAddressV2(w io.Writer, pver uint32, na *NetAddress) error {
+	err := writeElement(w, uint32(na.Timestamp.Unix()))
+	if err != nil {
+		return err
+	}
+	if err := WriteVarInt(w, pver, uint64(na.Services)); err != nil {
+		return err
+	}
+
+	network := na.NetworkID()
+	var addr []byte
+	switch network {
+	case NetworkIPv4:
+		addr = na.IP.To4()
+	case NetworkTorV2:
+		addr = na.IP.To16()[len(onionCatPrefix):]
+	case NetworkIPv6, NetworkCJDNS:
+		// Ensure to always write 16 bytes even if the ip is nil.
+		addr = make([]byte, 16)
+		copy(addr, na.IP.To16())
+	default:
+		addr = na.Addr
+	}
+	size, known := network.addrSize()
+	if !known || len(addr) != size {
+		str := fmt.Sprintf("%v address is %d bytes instead of %d",
+			network, len(addr), size)
+		return messageError("writeNetAddressV2", str)
+	}
+	if err := writeElement(w, uint8(network)); err != nil {
+		return err
+	}
+	if err := WriteVarBytes(w, pver, addr); err != nil {
+		return err
+	}
+
+	// Sigh.  Hcd protocol mixes little and big endian.
+	return binary.Write(w, bigEndian, na.Port)
+}
@@ -18,7 +18,7 @@ const (
 	InitialProcotolVersion uint32 = 1
 
 	// ProtocolVersion is the latest protocol version this package supports.
-	ProtocolVersion uint32 = 5
+	ProtocolVersion uint32 = 7
 
 	// BIP0111Version is the protocol version which added the SFNodeBloom
 	// service flag.
@@ -35,6 +35,14 @@ const (
 	// FeeFilterVersion is the protocol version which added a new
 	// feefilter message.
 	FeeFilterVersion uint32 = 5
+
+	// CompactBlocksVersion is the protocol version which added the new
+	// sendcmpct, cmpctblock, getblocktxn and blocktxn messages.
+	CompactBlocksVersion uint32 = 6
+
+	// AddrV2Version is the protocol version which added the new sendaddrv2
+	// and addrv2 messages.
+	AddrV2Version uint32 = 7
 )
 
 // ServiceFlag identifies services supported by a hcd peer.
@@ -47,12 +55,17 @@ const (
 	// SFNodeBloom is a flag used to indiciate a peer supports bloom
 	// filtering.
 	SFNodeBloom
+
+	// SFNodeP2PV2 is a flag used to indicate a peer supports the encrypted
+	// v2 transport.
+	SFNodeP2PV2
 )
 
 // Map of service flags back to their constant names for pretty printing.
 var sfStrings = map[ServiceFlag]string{
 	SFNodeNetwork: "SFNodeNetwork",
 	SFNodeBloom:   "SFNodeBloom",
+	SFNodeP2PV2:   "SFNodeP2PV2",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -60,6 +73,7 @@ var sfStrings = map[ServiceFlag]string{
 var orderedSFStrings = []ServiceFlag{
 	SFNodeNetwork,
 	SFNodeBloom,
+	SFNodeP2PV2,
 }
 
 // String returns the ServiceFlag in human-readable form.
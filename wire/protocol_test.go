@@ -17,7 +17,8 @@ func TestServiceFlagStringer(t *testing.T) {
 		{0, "0x0"},
 		{SFNodeNetwork, "SFNodeNetwork"},
 		{SFNodeBloom, "SFNodeBloom"},
-		{0xffffffff, "SFNodeNetwork|SFNodeBloom|0xfffffffc"},
+		{SFNodeP2PV2, "SFNodeP2PV2"},
+		{0xffffffff, "SFNodeNetwork|SFNodeBloom|SFNodeP2PV2|0xfffffff8"},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -0,0 +1,173 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// v2MessageCommands houses the commands of the messages which are identified
+// by a one byte message type id in the v2 transport indexed by their id.  Id
+// zero is reserved to indicate that the full command follows, which is how
+// any message not in this list is identified.  New commands must only ever be
+// appended to the list.
+var v2MessageCommands = [...]string{
+	1:  CmdAddr,
+	2:  CmdBlock,
+	3:  CmdFeeFilter,
+	4:  CmdFilterAdd,
+	5:  CmdFilterClear,
+	6:  CmdFilterLoad,
+	7:  CmdGetBlocks,
+	8:  CmdGetBlockTxn,
+	9:  CmdBlockTxn,
+	10: CmdGetData,
+	11: CmdGetHeaders,
+	12: CmdHeaders,
+	13: CmdInv,
+	14: CmdMemPool,
+	15: CmdMerkleBlock,
+	16: CmdNotFound,
+	17: CmdPing,
+	18: CmdPong,
+	19: CmdSendCmpct,
+	20: CmdCmpctBlock,
+	21: CmdTx,
+	22: CmdGetMiningState,
+	23: CmdMiningState,
+	24: CmdSendHeaders,
+	25: CmdReject,
+	26: CmdAddrV2,
+	27: CmdSendAddrV2,
+}
+
+// v2MessageIDs maps commands to their one byte message type id in the v2
+// transport.
+var v2MessageIDs = func() map[string]uint8 {
+	ids := make(map[string]uint8, len(v2MessageCommands))
+	for id, cmd := range v2MessageCommands {
+		if cmd != "" {
+			ids[cmd] = uint8(id)
+		}
+	}
+	return ids
+}()
+
+// EncodeV2Message returns the contents of the v2 transport packet which
+// carries the passed message.  The contents consist of the message type, which
+// is either a one byte message type id or a zero byte followed by the padded
+// command, and the message payload.  Unlike the v1 message header, there is
+// no network magic, length or checksum since the transport already provides
+// them.
+func EncodeV2Message(msg Message, pver uint32) ([]byte, error) {
+	cmd := msg.Command()
+	if len(cmd) > CommandSize {
+		str := fmt.Sprintf("command [%s] is too long [max %v]",
+			cmd, CommandSize)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	var bw bytes.Buffer
+	if id, ok := v2MessageIDs[cmd]; ok {
+		bw.WriteByte(id)
+	} else {
+		var command [CommandSize]byte
+		copy(command[:], cmd)
+		bw.WriteByte(0)
+		bw.Write(command[:])
+	}
+	typeLen := bw.Len()
+
+	// Encode the message payload.
+	err := msg.BtcEncode(&bw, pver)
+	if err != nil {
+		return nil, err
+	}
+	lenp := bw.Len() - typeLen
+
+	// Enforce maximum overall message payload.
+	if lenp > MaxMessagePayload {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload is %d bytes",
+			lenp, MaxMessagePayload)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	// Enforce maximum message payload based on the message type.
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(lenp) > mpl {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload size for "+
+			"messages of type [%s] is %d.", lenp, cmd, mpl)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	return bw.Bytes(), nil
+}
+
+// DecodeV2Message parses the message carried by the passed contents of a v2
+// transport packet for the provided protocol version.  It returns the parsed
+// Message and the raw bytes of its payload.
+func DecodeV2Message(contents []byte, pver uint32) (Message, []byte, error) {
+	if len(contents) == 0 {
+		return nil, nil, messageError("DecodeV2Message",
+			"missing message type")
+	}
+
+	// Determine the command from the message type.
+	var command string
+	payload := contents[1:]
+	if id := contents[0]; id != 0 {
+		if int(id) >= len(v2MessageCommands) ||
+			v2MessageCommands[id] == "" {
+
+			str := fmt.Sprintf("unknown message type id %d", id)
+			return nil, nil, messageError("DecodeV2Message", str)
+		}
+		command = v2MessageCommands[id]
+	} else {
+		if len(payload) < CommandSize {
+			return nil, nil, messageError("DecodeV2Message",
+				"short command")
+		}
+		command = string(bytes.TrimRight(payload[:CommandSize],
+			string(0)))
+		payload = payload[CommandSize:]
+	}
+
+	// Check for malformed commands.
+	if !utf8.ValidString(command) {
+		str := fmt.Sprintf("invalid command %v", []byte(command))
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	// Create struct of appropriate message type based on the command.
+	msg, err := makeEmptyMessage(command)
+	if err != nil {
+		return nil, nil, messageError("DecodeV2Message", err.Error())
+	}
+
+	// Check for maximum length based on the message type.
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(len(payload)) > mpl {
+		str := fmt.Sprintf("payload exceeds max length - packet "+
+			"contains %v bytes, but max payload size for "+
+			"messages of type [%v] is %v.", len(payload), command,
+			mpl)
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	// Unmarshal message.  NOTE: This must be a *bytes.Buffer since the
+	// MsgVersion BtcDecode function requires it.
+	pr := bytes.NewBuffer(payload)
+	err = msg.BtcDecode(pr, pver)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return msg, payload, nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018-2020 The Hc developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestV2Message tests encoding and decoding messages as the contents of v2
+// transport packets with both short message type ids and full commands.
+func TestV2Message(t *testing.T) {
+	tests := []struct {
+		msg     Message
+		typeLen int
+	}{
+		{NewMsgPing(0x1234567890), 1},
+		{NewMsgSendHeaders(), 1},
+		{NewMsgVerAck(), 1 + CommandSize},
+	}
+
+	for i, test := range tests {
+		contents, err := EncodeV2Message(test.msg, ProtocolVersion)
+		if err != nil {
+			t.Errorf("EncodeV2Message #%d: unexpected error %v", i,
+				err)
+			continue
+		}
+		if test.typeLen == 1 && contents[0] == 0 ||
+			test.typeLen != 1 && contents[0] != 0 {
+
+			t.Errorf("EncodeV2Message #%d: unexpected message type "+
+				"%d", i, contents[0])
+			continue
+		}
+
+		msg, payload, err := DecodeV2Message(contents, ProtocolVersion)
+		if err != nil {
+			t.Errorf("DecodeV2Message #%d: unexpected error %v", i,
+				err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.msg) {
+			t.Errorf("DecodeV2Message #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.msg))
+			continue
+		}
+		if len(payload) != len(contents)-test.typeLen {
+			t.Errorf("DecodeV2Message #%d: got payload len %d, "+
+				"want %d", i, len(payload),
+				len(contents)-test.typeLen)
+		}
+	}
+
+	// Decoding must fail for unknown message type ids, short commands and
+	// unknown commands.
+	bad := [][]byte{
+		nil,
+		{0xff},
+		{0x00, 'p', 'i', 'n', 'g'},
+		append([]byte{0x00}, []byte("unknowncmd\x00\x00")...),
+	}
+	for i, contents := range bad {
+		_, _, err := DecodeV2Message(contents, ProtocolVersion)
+		if _, ok := err.(*MessageError); !ok {
+			t.Errorf("DecodeV2Message #%d: got error %v, want a "+
+				"MessageError", i, err)
+		}
+	}
+}